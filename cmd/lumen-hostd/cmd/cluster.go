@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/internal"
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils/netaddr"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// clusterSettle is how long the node list must stay unchanged, with every
+// node's tasks known, before `cluster test` starts probing.
+const clusterSettle = time.Second
+
+// NewClusterCommand groups commands that act on every node of the cluster.
+func NewClusterCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Check the nodes of the cluster",
+	}
+	cmd.AddCommand(newClusterTestCommand())
+	return cmd
+}
+
+type clusterTestOptions struct {
+	configFile string
+	brokerURL  string
+	direct     bool
+	wait       time.Duration
+	timeout    time.Duration
+	asJSON     bool
+}
+
+func newClusterTestCommand() *cobra.Command {
+	var opts clusterTestOptions
+
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Send one request per task to every node serving it and report which pass",
+		Long: `Discover the nodes of the cluster and send each node one small request
+for every task it serves that has a built-in probe: a short text for text
+embedding, a tiny generated image for image embedding, classification, OCR
+and face recognition, and a short prompt capped at 8 tokens for text
+generation. Each request is pinned to its node, bypassing selection.
+
+Nodes are discovered through the running Broker unless --direct is set, in
+which case the configured mDNS and static nodes are used. The command exits
+non-zero when any probe fails.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runClusterTest(cmd.Context(), cmd.OutOrStdout(), opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	cmd.Flags().StringVar(&opts.brokerURL, "broker-url", "", "Broker to discover nodes through: host, host:port or http://host:port (default: the local Broker)")
+	cmd.Flags().BoolVar(&opts.direct, "direct", false, "Discover nodes with the configured mDNS and static nodes instead of through a Broker")
+	cmd.Flags().DurationVar(&opts.wait, "wait", 5*time.Second, "How long to wait for nodes to be discovered")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 30*time.Second, "Timeout of each probe request")
+	cmd.Flags().BoolVar(&opts.asJSON, "json", false, "Print the results as JSON")
+	return cmd
+}
+
+// probeResult is the outcome of one probe request.
+type probeResult struct {
+	Node      string `json:"node"`
+	Task      string `json:"task"`
+	Pass      bool   `json:"pass"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// clusterReport is everything `cluster test` found.
+type clusterReport struct {
+	Nodes   []string      `json:"nodes"`
+	Results []probeResult `json:"results"`
+	// Skipped are the advertised tasks without a built-in probe.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+func (r *clusterReport) failures() int {
+	n := 0
+	for _, res := range r.Results {
+		if !res.Pass {
+			n++
+		}
+	}
+	return n
+}
+
+func runClusterTest(ctx context.Context, out io.Writer, opts clusterTestOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cfg, _, err := internal.LoadConfig(opts.configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// The CLI is a short-lived client: it never accepts pushed nodes, and
+	// by default reads the nodes the Broker republishes rather than
+	// discovering them again.
+	testCfg := *cfg
+	testCfg.Discovery.Enabled = true
+	testCfg.Discovery.Push.Enabled = false
+	if !opts.direct {
+		var brokerURL string
+		if opts.brokerURL != "" {
+			addr, err := netaddr.ParseAddress(opts.brokerURL, cfg.Broker.Port)
+			if err != nil {
+				return fmt.Errorf("invalid --broker-url: %w", err)
+			}
+			brokerURL = addr.URL("http")
+		} else {
+			endpoint, err := internal.ResolveBrokerEndpoint(cfg, "")
+			if err != nil {
+				return err
+			}
+			if endpoint.Socket != "" {
+				return fmt.Errorf("the Broker listens on %s, which discovery cannot subscribe to: pass --broker-url or --direct", endpoint.String())
+			}
+			brokerURL = endpoint.URL("")
+		}
+		testCfg.Discovery.BrokerURL = brokerURL
+		testCfg.Discovery.MDNSEnabled = false
+		testCfg.Discovery.StaticNodes = nil
+	} else {
+		testCfg.Discovery.BrokerURL = ""
+	}
+
+	c, err := client.NewLumenClient(&testCfg, zap.NewNop())
+	if err != nil {
+		return fmt.Errorf("failed to create Lumen client: %w", err)
+	}
+	defer c.Close()
+	if err := c.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start Lumen client: %w", err)
+	}
+
+	nodes := waitForNodes(ctx, c, opts.wait)
+	if len(nodes) == 0 {
+		return fmt.Errorf("no nodes discovered within %s", opts.wait)
+	}
+	report := probeCluster(ctx, c, nodes, opts.timeout)
+
+	if opts.asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printClusterReport(out, report)
+	}
+	if n := report.failures(); n > 0 {
+		return fmt.Errorf("%d of %d probes failed", n, len(report.Results))
+	}
+	return nil
+}
+
+// waitForNodes returns the discovered nodes once their list has settled:
+// unchanged for clusterSettle with every node's tasks known, or at wait.
+func waitForNodes(ctx context.Context, c *client.LumenClient, wait time.Duration) []*discovery.NodeInfo {
+	deadline := time.Now().Add(wait)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	var nodes []*discovery.NodeInfo
+	var last string
+	stableSince := time.Now()
+	for {
+		nodes = c.GetNodes()
+		sig := nodesSignature(nodes)
+		if sig != last {
+			last, stableSince = sig, time.Now()
+		}
+		settled := len(nodes) > 0 && time.Since(stableSince) >= clusterSettle && !slices.ContainsFunc(nodes, func(n *discovery.NodeInfo) bool {
+			return len(n.Tasks) == 0
+		})
+		if settled || !time.Now().Before(deadline) {
+			return nodes
+		}
+		select {
+		case <-ctx.Done():
+			return nodes
+		case <-ticker.C:
+		}
+	}
+}
+
+// nodesSignature identifies the nodes and how many tasks each serves.
+func nodesSignature(nodes []*discovery.NodeInfo) string {
+	parts := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		parts = append(parts, fmt.Sprintf("%s/%d", n.ID, len(n.Tasks)))
+	}
+	slices.Sort(parts)
+	return fmt.Sprint(parts)
+}
+
+// probeCluster sends each node one probe per task it serves that has one,
+// one at a time so latencies are not skewed by each other.
+func probeCluster(ctx context.Context, c *client.LumenClient, nodes []*discovery.NodeInfo, timeout time.Duration) *clusterReport {
+	slices.SortFunc(nodes, func(a, b *discovery.NodeInfo) int { return strings.Compare(a.ID, b.ID) })
+	report := &clusterReport{Nodes: make([]string, 0, len(nodes)), Results: []probeResult{}}
+	skipped := make(map[string]bool)
+	for _, node := range nodes {
+		report.Nodes = append(report.Nodes, node.ID)
+		var served []string
+		for _, t := range node.Tasks {
+			if t != nil && !slices.Contains(served, t.GetName()) {
+				served = append(served, t.GetName())
+			}
+		}
+		slices.Sort(served)
+		for _, task := range served {
+			probe, ok := probeFor(task)
+			if !ok {
+				skipped[task] = true
+				continue
+			}
+			report.Results = append(report.Results, runProbe(ctx, c, node.ID, task, probe, timeout))
+		}
+	}
+	for task := range skipped {
+		report.Skipped = append(report.Skipped, task)
+	}
+	slices.Sort(report.Skipped)
+	return report
+}
+
+func runProbe(ctx context.Context, c *client.LumenClient, nodeID, task string, probe taskProbe, timeout time.Duration) probeResult {
+	res := probeResult{Node: nodeID, Task: task}
+	req, err := probe(task)
+	if err != nil {
+		res.Error = fmt.Sprintf("building request: %v", err)
+		return res
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := c.InferOnNode(probeCtx, nodeID, req)
+	res.LatencyMS = time.Since(start).Milliseconds()
+	switch {
+	case err != nil:
+		res.Error = err.Error()
+	case resp.GetError() != nil:
+		res.Error = fmt.Sprintf("%s: %s", resp.GetError().GetCode(), resp.GetError().GetMessage())
+	default:
+		res.Pass = true
+	}
+	return res
+}
+
+// printClusterReport prints a node × task matrix, then the skipped tasks
+// and the error of every failed probe.
+func printClusterReport(out io.Writer, report *clusterReport) {
+	var tasks []string
+	cells := make(map[string]map[string]probeResult)
+	for _, res := range report.Results {
+		if !slices.Contains(tasks, res.Task) {
+			tasks = append(tasks, res.Task)
+		}
+		if cells[res.Node] == nil {
+			cells[res.Node] = make(map[string]probeResult)
+		}
+		cells[res.Node][res.Task] = res
+	}
+	slices.Sort(tasks)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "NODE")
+	for _, task := range tasks {
+		fmt.Fprintf(w, "\t%s", task)
+	}
+	fmt.Fprintln(w)
+	for _, node := range report.Nodes {
+		fmt.Fprint(w, node)
+		for _, task := range tasks {
+			res, ok := cells[node][task]
+			switch {
+			case !ok:
+				fmt.Fprint(w, "\t-")
+			case res.Pass:
+				fmt.Fprintf(w, "\tok %dms", res.LatencyMS)
+			default:
+				fmt.Fprint(w, "\tFAIL")
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	if len(report.Skipped) > 0 {
+		fmt.Fprintf(out, "\nSkipped (no built-in probe): %s\n", strings.Join(report.Skipped, ", "))
+	}
+	if report.failures() > 0 {
+		fmt.Fprintln(out, "\nFailures:")
+		for _, res := range report.Results {
+			if !res.Pass {
+				fmt.Fprintf(out, "  %s %s: %s\n", res.Node, res.Task, res.Error)
+			}
+		}
+	}
+	fmt.Fprintf(out, "\n%d of %d probes passed\n", len(report.Results)-report.failures(), len(report.Results))
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/internal"
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+// NewConfigCommand groups the commands for writing, inspecting and checking
+// configuration files. Without --config, files are looked up in the
+// locations listed by config.SearchPaths.
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Create, inspect and validate configuration files",
+		Long: "Create, inspect and validate configuration files.\n\n" +
+			"Without --config, the first existing file of these is used:\n  " +
+			strings.Join(config.SearchPaths(), "\n  "),
+	}
+	cmd.AddCommand(newConfigInitCommand(), newConfigShowCommand(), newConfigValidateCommand())
+	return cmd
+}
+
+func newConfigInitCommand() *cobra.Command {
+	var preset, path string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Write a commented starter configuration file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runConfigInit(cmd.OutOrStdout(), preset, path, force)
+		},
+	}
+	cmd.Flags().StringVar(&preset, "preset", config.PresetBasic, "Preset to start from ("+strings.Join(config.PresetNames(), ", ")+")")
+	cmd.Flags().StringVar(&path, "path", config.SearchPaths()[0], "File to write")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing file")
+	return cmd
+}
+
+func runConfigInit(out io.Writer, preset, path string, force bool) error {
+	cfg, err := config.PresetConfig(preset)
+	if err != nil {
+		return err
+	}
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+	if err := cfg.SaveConfig(path); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	fmt.Fprintf(out, "Wrote %s preset to %s\n", preset, path)
+	return nil
+}
+
+func newConfigShowCommand() *cobra.Command {
+	var configFile string
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration (file + environment)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigShow(cmd.OutOrStdout(), cmd.ErrOrStderr(), configFile)
+		},
+	}
+	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	return cmd
+}
+
+func runConfigShow(out, errOut io.Writer, configFile string) error {
+	eff, err := internal.ResolveEffectiveConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	if eff.Path == "" {
+		fmt.Fprintln(out, "# No config file found; showing defaults with environment overrides.")
+	} else {
+		fmt.Fprintf(out, "# Loaded from %s\n", eff.Path)
+	}
+	data, err := eff.Config.AnnotatedYAML(func(path string) string {
+		switch eff.Sources[path] {
+		case internal.SourceFile:
+			return "from " + eff.Path
+		case internal.SourceEnv:
+			return "from environment"
+		}
+		return ""
+	})
+	if err != nil {
+		return err
+	}
+	out.Write(data)
+
+	if err := eff.Config.Validate(); err != nil {
+		fmt.Fprintln(errOut, "warning: the effective configuration is invalid:")
+		printValidationErrors(errOut, err)
+	}
+	return nil
+}
+
+func newConfigValidateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate <path>",
+		Short: "Check a configuration file and report every problem",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runConfigValidate(cmd.OutOrStdout(), args[0])
+		},
+	}
+}
+
+func runConfigValidate(out io.Writer, path string) error {
+	cfg, err := config.LoadFile(path)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		n := printValidationErrors(out, err)
+		return fmt.Errorf("%s: %d problem(s) found", path, n)
+	}
+	fmt.Fprintf(out, "%s: OK\n", path)
+	return nil
+}
+
+// printValidationErrors lists each violation in err on its own line and
+// returns how many there were.
+func printValidationErrors(out io.Writer, err error) int {
+	var list config.ValidationErrors
+	if !errors.As(err, &list) {
+		list = config.ValidationErrors{err}
+	}
+	for _, e := range list {
+		fmt.Fprintf(out, "  - %v\n", e)
+	}
+	return len(list)
+}
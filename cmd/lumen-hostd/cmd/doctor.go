@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/internal"
@@ -24,16 +25,17 @@ import (
 // "auth token readable" check here even though the plan's original doctor
 // spec includes one.
 func NewDoctorCommand() *cobra.Command {
-	var configFile string
+	var configFile, socket string
 
 	cmd := &cobra.Command{
 		Use:   "doctor",
 		Short: "Diagnose the local Host Broker installation",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runDoctor(configFile)
+			return runDoctor(configFile, socket)
 		},
 	}
 	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file to check against")
+	cmd.Flags().StringVar(&socket, "socket", "", "Unix socket of a socket-bound Broker (default $"+internal.SocketEnv+" or broker.socket)")
 	return cmd
 }
 
@@ -43,8 +45,8 @@ type doctorResult struct {
 	detail string
 }
 
-func runDoctor(configFile string) error {
-	cfg, err := internal.LoadConfig(configFile)
+func runDoctor(configFile, socket string) error {
+	cfg, _, err := internal.LoadConfig(configFile)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
@@ -53,12 +55,15 @@ func runDoctor(configFile string) error {
 	results = append(results, checkServiceInstalled())
 	results = append(results, checkNetworkInterfaces())
 
-	addr := fmt.Sprintf("%s:%d", loopbackHost(cfg.Broker.Host), cfg.Broker.Port)
-	healthResult, reachable := checkBrokerPort(addr)
+	endpoint, err := internal.ResolveBrokerEndpoint(cfg, socket)
+	if err != nil {
+		return err
+	}
+	healthResult, reachable := checkBrokerPort(endpoint)
 	results = append(results, healthResult)
 
 	if reachable {
-		results = append(results, checkDiscoveredNodes(addr)...)
+		results = append(results, checkDiscoveredNodes(endpoint)...)
 	}
 
 	results = append(results, dockerHostGuidance())
@@ -99,9 +104,9 @@ func checkNetworkInterfaces() doctorResult {
 	return doctorResult{name: "network interfaces", pass: true, detail: fmt.Sprintf("%d active: %v", len(up), up)}
 }
 
-func checkBrokerPort(addr string) (doctorResult, bool) {
-	client := &http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Get(fmt.Sprintf("http://%s/v1/health", addr))
+func checkBrokerPort(endpoint internal.BrokerEndpoint) (doctorResult, bool) {
+	addr := endpoint.String()
+	resp, err := endpoint.HTTPClient(2 * time.Second).Get(endpoint.URL("/v1/health"))
 	if err != nil {
 		return doctorResult{name: "broker port", pass: false, detail: fmt.Sprintf("%s unreachable: %v", addr, err)}, false
 	}
@@ -109,12 +114,22 @@ func checkBrokerPort(addr string) (doctorResult, bool) {
 	if resp.StatusCode != http.StatusOK {
 		return doctorResult{name: "broker port", pass: false, detail: fmt.Sprintf("%s returned HTTP %d", addr, resp.StatusCode)}, false
 	}
+	var health struct {
+		Status    string                     `json:"status"`
+		Discovery *discovery.DiscoveryStatus `json:"discovery"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&health) == nil && health.Status == "degraded" && health.Discovery != nil {
+		var failed []string
+		for _, f := range health.Discovery.Failed {
+			failed = append(failed, f.Backend+": "+f.Error)
+		}
+		return doctorResult{name: "broker port", pass: true, detail: fmt.Sprintf("%s reachable, discovery degraded (%s)", addr, strings.Join(failed, "; "))}, true
+	}
 	return doctorResult{name: "broker port", pass: true, detail: addr + " reachable"}, true
 }
 
-func checkDiscoveredNodes(addr string) []doctorResult {
-	client := &http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Get(fmt.Sprintf("http://%s/v1/nodes", addr))
+func checkDiscoveredNodes(endpoint internal.BrokerEndpoint) []doctorResult {
+	resp, err := endpoint.HTTPClient(2 * time.Second).Get(endpoint.URL("/v1/nodes"))
 	if err != nil {
 		return []doctorResult{{name: "discovered nodes", pass: false, detail: err.Error()}}
 	}
@@ -164,15 +179,6 @@ func dockerHostGuidance() doctorResult {
 	}
 }
 
-// loopbackHost substitutes a connectable address for a bind-only host like
-// "0.0.0.0" or an empty string, so doctor's own local check can dial it.
-func loopbackHost(host string) string {
-	if host == "" || host == "0.0.0.0" {
-		return "127.0.0.1"
-	}
-	return host
-}
-
 func printDoctorResults(results []doctorResult) {
 	for _, r := range results {
 		mark := "FAIL"
@@ -0,0 +1,311 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/internal"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+
+	"github.com/spf13/cobra"
+)
+
+// NewNodeCommand groups commands that manage the nodes the running Host
+// Broker routes to.
+func NewNodeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "node",
+		Short: "Manage the nodes the Broker routes to",
+	}
+	cmd.AddCommand(newNodeListCommand(), newNodeInfoCommand(), newNodeDrainCommand(), newNodeUndrainCommand())
+	return cmd
+}
+
+type nodeListOptions struct {
+	status, task, runtime, labels string
+	offset, limit                 int
+}
+
+func newNodeListCommand() *cobra.Command {
+	var configFile, socket string
+	var asJSON bool
+	var opts nodeListOptions
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the nodes the Broker knows, sorted by ID",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runNodeList(cmd.OutOrStdout(), configFile, socket, opts, asJSON)
+		},
+	}
+	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	cmd.Flags().StringVar(&socket, "socket", "", "Unix socket of a socket-bound Broker (default $"+internal.SocketEnv+" or broker.socket)")
+	cmd.Flags().StringVar(&opts.status, "status", "", "Only nodes with this status (e.g. active, draining, ejected)")
+	cmd.Flags().StringVar(&opts.task, "task", "", "Only nodes serving this task")
+	cmd.Flags().StringVar(&opts.runtime, "runtime", "", "Only nodes with a service whose runtime contains this")
+	cmd.Flags().StringVar(&opts.labels, "labels", "", "Only nodes whose metadata matches these comma-separated selectors (key=value, key!=value, key)")
+	cmd.Flags().IntVar(&opts.offset, "offset", 0, "Skip this many matching nodes")
+	cmd.Flags().IntVar(&opts.limit, "limit", 0, "Show at most this many nodes (0: all)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the raw response as JSON")
+	return cmd
+}
+
+func runNodeList(out io.Writer, configFile, socket string, opts nodeListOptions, asJSON bool) error {
+	cfg, _, err := internal.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	endpoint, err := internal.ResolveBrokerEndpoint(cfg, socket)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	for name, v := range map[string]string{"status": opts.status, "task": opts.task, "runtime": opts.runtime, "labels": opts.labels} {
+		if v != "" {
+			params.Set(name, v)
+		}
+	}
+	if opts.offset > 0 {
+		params.Set("offset", strconv.Itoa(opts.offset))
+	}
+	if opts.limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.limit))
+	}
+	path := "/v1/nodes"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	resp, err := endpoint.HTTPClient(5 * time.Second).Get(endpoint.URL(path))
+	if err != nil {
+		return fmt.Errorf("broker %s unreachable: %w", endpoint.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("broker returned HTTP %d: %s", resp.StatusCode, body.Error)
+	}
+
+	var body struct {
+		Nodes []*discovery.NodeInfo `json:"nodes"`
+		Total int                   `json:"total"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("could not parse nodes response: %w", err)
+	}
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&body)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tADDRESS\tSTATUS\tREASON\tSINCE\tIN FLIGHT\tTASKS")
+	for _, node := range body.Nodes {
+		names := make([]string, 0, len(node.Tasks))
+		for _, t := range node.Tasks {
+			names = append(names, t.GetName())
+		}
+		reason := string(node.StatusReason)
+		if reason == "" {
+			reason = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", node.ID, node.Address, node.Status, reason,
+			statusAge(node.StatusSince, time.Now()), node.InFlight, strings.Join(names, ","))
+	}
+	w.Flush()
+	if len(body.Nodes) > 0 && len(body.Nodes) < body.Total {
+		fmt.Fprintf(out, "\nShowing %d-%d of %d nodes.\n", opts.offset+1, opts.offset+len(body.Nodes), body.Total)
+	}
+	return nil
+}
+
+func newNodeInfoCommand() *cobra.Command {
+	var configFile, socket string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "info <id>",
+		Short: "Show a node's status and its most recent errors",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runNodeInfo(cmd.OutOrStdout(), configFile, socket, args[0], asJSON)
+		},
+	}
+	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	cmd.Flags().StringVar(&socket, "socket", "", "Unix socket of a socket-bound Broker (default $"+internal.SocketEnv+" or broker.socket)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the raw node as JSON")
+	return cmd
+}
+
+func runNodeInfo(out io.Writer, configFile, socket, id string, asJSON bool) error {
+	cfg, _, err := internal.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	endpoint, err := internal.ResolveBrokerEndpoint(cfg, socket)
+	if err != nil {
+		return err
+	}
+
+	resp, err := endpoint.HTTPClient(5 * time.Second).Get(endpoint.URL("/v1/nodes/" + url.PathEscape(id)))
+	if err != nil {
+		return fmt.Errorf("broker %s unreachable: %w", endpoint.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("broker returned HTTP %d: %s", resp.StatusCode, body.Error)
+	}
+
+	var node discovery.NodeInfo
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return fmt.Errorf("could not parse node response: %w", err)
+	}
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&node)
+	}
+	printNodeInfo(out, &node)
+	return nil
+}
+
+func printNodeInfo(out io.Writer, node *discovery.NodeInfo) {
+	fmt.Fprintf(out, "Node:      %s (%s)\n", node.ID, node.Address)
+	fmt.Fprintf(out, "Status:    %s\n", nodeStatusLine(node))
+	fmt.Fprintf(out, "In flight: %d\n", node.InFlight)
+	fmt.Fprintf(out, "Failures:  %d requests, %d health checks in a row\n", node.RequestFailures, node.HealthCheckFailures)
+	if len(node.RecentErrors) == 0 {
+		fmt.Fprintln(out, "\nNo recent errors.")
+		return
+	}
+	fmt.Fprintf(out, "\nRecent errors (%d, oldest first):\n", len(node.RecentErrors))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "AT\tOP\tCODE\tCORRELATION\tMESSAGE")
+	for _, e := range node.RecentErrors {
+		correlation := e.CorrelationID
+		if correlation == "" {
+			correlation = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.At.Format(time.RFC3339), e.Op, e.Code, correlation, e.Message)
+	}
+	w.Flush()
+}
+
+// nodeStatusLine is a node's status with its reason and since when, as in
+// "error (health_check_failed, since 2026-01-02T15:04:05Z)".
+func nodeStatusLine(node *discovery.NodeInfo) string {
+	if node.StatusReason == "" {
+		return string(node.Status)
+	}
+	if node.StatusSince.IsZero() {
+		return fmt.Sprintf("%s (%s)", node.Status, node.StatusReason)
+	}
+	return fmt.Sprintf("%s (%s, since %s)", node.Status, node.StatusReason, node.StatusSince.Format(time.RFC3339))
+}
+
+// statusAge is how long ago since was, to the second, or "-" when unknown.
+func statusAge(since, now time.Time) string {
+	if since.IsZero() {
+		return "-"
+	}
+	return now.Sub(since).Truncate(time.Second).String() + " ago"
+}
+
+func newNodeDrainCommand() *cobra.Command {
+	var configFile, socket string
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "drain <id>",
+		Short: "Stop routing new requests to a node while its in-flight requests finish",
+		Long: "Stop routing new requests to a node while its in-flight requests finish.\n" +
+			"The node stays drained until `node undrain`, or until --timeout elapses.\n" +
+			"Once it reports 0 in flight it can be stopped without failing a request.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			path := "/v1/nodes/" + url.PathEscape(args[0]) + "/drain"
+			if timeout > 0 {
+				path += "?timeout=" + url.QueryEscape(timeout.String())
+			}
+			return runNodeAction(cmd.OutOrStdout(), configFile, socket, path)
+		},
+	}
+	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	cmd.Flags().StringVar(&socket, "socket", "", "Unix socket of a socket-bound Broker (default $"+internal.SocketEnv+" or broker.socket)")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Undrain the node automatically after this long (default: never)")
+	return cmd
+}
+
+func newNodeUndrainCommand() *cobra.Command {
+	var configFile, socket string
+
+	cmd := &cobra.Command{
+		Use:   "undrain <id>",
+		Short: "Return a drained node to selection",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runNodeAction(cmd.OutOrStdout(), configFile, socket, "/v1/nodes/"+url.PathEscape(args[0])+"/undrain")
+		},
+	}
+	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	cmd.Flags().StringVar(&socket, "socket", "", "Unix socket of a socket-bound Broker (default $"+internal.SocketEnv+" or broker.socket)")
+	return cmd
+}
+
+// runNodeAction POSTs to path on the Broker and prints the node it returns.
+func runNodeAction(out io.Writer, configFile, socket, path string) error {
+	cfg, _, err := internal.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	endpoint, err := internal.ResolveBrokerEndpoint(cfg, socket)
+	if err != nil {
+		return err
+	}
+
+	resp, err := endpoint.HTTPClient(5*time.Second).Post(endpoint.URL(path), "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("broker %s unreachable: %w", endpoint.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("broker returned HTTP %d: %s", resp.StatusCode, body.Error)
+	}
+
+	var node discovery.NodeInfo
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return fmt.Errorf("could not parse node response: %w", err)
+	}
+	fmt.Fprintf(out, "Node:      %s (%s)\n", node.ID, node.Address)
+	fmt.Fprintf(out, "Status:    %s\n", node.Status)
+	if !node.DrainingSince.IsZero() {
+		fmt.Fprintf(out, "Draining:  since %s\n", node.DrainingSince.Format(time.RFC3339))
+	}
+	fmt.Fprintf(out, "In flight: %d\n", node.InFlight)
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/tasks"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// taskProbe builds the smoke-test request for task, a name a node
+// advertises.
+type taskProbe func(task string) (*pb.InferRequest, error)
+
+// builtinProbes are the tasks `cluster test` knows how to exercise, keyed
+// by task name without version. Image tasks get probeImage; generation a
+// short prompt capped at a few tokens.
+var builtinProbes = map[string]taskProbe{
+	types.TaskSemanticTextEmbed: func(task string) (*pb.InferRequest, error) {
+		return types.NewInferRequest(task).ForSemanticTextEmbed("lumen cluster smoke test").Build(), nil
+	},
+	types.TaskSemanticImageEmbed: func(task string) (*pb.InferRequest, error) {
+		return types.NewInferRequest(task).ForSemanticImageEmbed(probeImage(), "image/png").Build(), nil
+	},
+	types.TaskBioCLIPClassify: func(task string) (*pb.InferRequest, error) {
+		req, err := types.NewClassificationRequest(probeImage(), types.WithTopK(1))
+		if err != nil {
+			return nil, err
+		}
+		return types.NewInferRequest(task).ForClassification(req, task).Build(), nil
+	},
+	types.TaskOCR: func(task string) (*pb.InferRequest, error) {
+		req, err := types.NewOCRRequest(probeImage())
+		if err != nil {
+			return nil, err
+		}
+		return types.NewInferRequest(task).ForOCR(req, task).Build(), nil
+	},
+	types.TaskFaceRecognition: func(task string) (*pb.InferRequest, error) {
+		req, err := types.NewFaceRecognitionRequest(probeImage())
+		if err != nil {
+			return nil, err
+		}
+		return types.NewInferRequest(task).ForFaceDetection(req, task).Build(), nil
+	},
+	types.TaskTextGeneration: func(task string) (*pb.InferRequest, error) {
+		req, err := types.NewTextGenerationRequest("Reply with one word: ready?", types.WithMaxTokens(8))
+		if err != nil {
+			return nil, err
+		}
+		return types.NewInferRequest(task).ForTextGeneration(req, task).Build(), nil
+	},
+}
+
+// probeFor returns the built-in probe of the advertised task, if any.
+func probeFor(task string) (taskProbe, bool) {
+	if tasks.IsPattern(task) {
+		return nil, false
+	}
+	probe, ok := builtinProbes[tasks.Name(task)]
+	return probe, ok
+}
+
+// probeImage is a 64x64 PNG, a colour gradient: enough for every image
+// task to decode and run, small enough to send in one chunk.
+var probeImage = sync.OnceValue(func() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := range 64 {
+		for x := range 64 {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 4), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+})
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/tabwriter"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/internal"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+
+	"github.com/spf13/cobra"
+)
+
+// NewQueueCommand groups commands that inspect and manage the running Host
+// Broker's durable request queue.
+func NewQueueCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Inspect and manage the durable request queue",
+	}
+	cmd.AddCommand(newQueueStatusCommand(), newQueueRetryDeadCommand())
+	return cmd
+}
+
+func newQueueStatusCommand() *cobra.Command {
+	var configFile, socket string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show queue depth, dispatch rate and dead letters",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runQueueStatus(cmd.OutOrStdout(), configFile, socket, asJSON)
+		},
+	}
+	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	cmd.Flags().StringVar(&socket, "socket", "", "Unix socket of a socket-bound Broker (default $"+internal.SocketEnv+" or broker.socket)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the raw queue status as JSON")
+	return cmd
+}
+
+func newQueueRetryDeadCommand() *cobra.Command {
+	var configFile, socket string
+
+	cmd := &cobra.Command{
+		Use:   "retry-dead",
+		Short: "Queue every dead letter again with a fresh attempt count",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runQueueRetryDead(cmd.OutOrStdout(), configFile, socket)
+		},
+	}
+	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	cmd.Flags().StringVar(&socket, "socket", "", "Unix socket of a socket-bound Broker (default $"+internal.SocketEnv+" or broker.socket)")
+	return cmd
+}
+
+// queueRequest sends method to path on the Broker and decodes a 200 answer
+// into out.
+func queueRequest(configFile, socket, method, path string, out any) error {
+	cfg, _, err := internal.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	endpoint, err := internal.ResolveBrokerEndpoint(cfg, socket)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, endpoint.URL(path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := endpoint.HTTPClient(5 * time.Second).Do(req)
+	if err != nil {
+		return fmt.Errorf("broker %s unreachable: %w", endpoint.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("broker returned HTTP %d: %s", resp.StatusCode, body.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("could not parse queue response: %w", err)
+	}
+	return nil
+}
+
+func runQueueStatus(out io.Writer, configFile, socket string, asJSON bool) error {
+	var status discovery.QueueStatus
+	if err := queueRequest(configFile, socket, http.MethodGet, "/v1/queue", &status); err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+	printQueueStatus(out, &status)
+	return nil
+}
+
+func runQueueRetryDead(out io.Writer, configFile, socket string) error {
+	var body struct {
+		Retried int `json:"retried"`
+	}
+	if err := queueRequest(configFile, socket, http.MethodPost, "/v1/queue/retry-dead", &body); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Retried %d dead letter(s)\n", body.Retried)
+	return nil
+}
+
+func printQueueStatus(out io.Writer, s *discovery.QueueStatus) {
+	fmt.Fprintf(out, "Depth:       %d (%d in flight)\n", s.Depth, s.InFlight)
+	fmt.Fprintf(out, "Undelivered: %d\n", s.Undelivered)
+	fmt.Fprintf(out, "Dead:        %d\n", s.Dead)
+	if s.MaxBytes > 0 {
+		fmt.Fprintf(out, "Disk:        %d of %d bytes\n", s.Bytes, s.MaxBytes)
+	} else {
+		fmt.Fprintf(out, "Disk:        %d bytes\n", s.Bytes)
+	}
+	fmt.Fprintf(out, "Rate:        %.2f/s over the last minute\n", s.DispatchRate)
+	fmt.Fprintf(out, "Since start: %d dispatched, %d completed, %d failed, %d dead-lettered\n",
+		s.Dispatched, s.Completed, s.Failed, s.DeadLettered)
+	if len(s.DeadLetters) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTASK\tTOPIC\tATTEMPTS\tQUEUED\tLAST ERROR")
+	for _, item := range s.DeadLetters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", item.ID, item.Task, item.Topic, item.Attempts,
+			item.EnqueuedAt.Format(time.RFC3339), item.LastError)
+	}
+	w.Flush()
+}
@@ -3,10 +3,15 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/internal"
 	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/service"
 	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/version"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -16,22 +21,80 @@ import (
 // service managers (launchd, systemd, Task Scheduler) invoke this directly;
 // it does not detach, fork, or write a PID file — the OS service manager
 // owns restart and lifecycle instead.
-func NewServeCommand(build service.BuildInfo) *cobra.Command {
+func NewServeCommand(build version.Info) *cobra.Command {
 	var configFile string
+	var validateOnly bool
 
 	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Run the Host Broker in the foreground",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if validateOnly {
+				cmd.SilenceUsage = true
+				return runValidateOnly(cmd.OutOrStdout(), configFile)
+			}
 			return runServe(configFile, build)
 		},
 	}
-	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file")
+	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	cmd.Flags().BoolVar(&validateOnly, "validate-only", false, "Load and validate the configuration with environment overrides, print a summary and exit")
 	return cmd
 }
 
-func runServe(configFile string, build service.BuildInfo) error {
-	cfg, err := internal.LoadConfig(configFile)
+// runValidateOnly checks the configuration serve would run with, listing
+// every problem in it, and summarises what it sets up without starting.
+func runValidateOnly(out io.Writer, configFile string) error {
+	eff, err := internal.ResolveEffectiveConfig(configFile)
+	if err == nil {
+		err = eff.Config.Validate()
+	}
+	if err != nil {
+		n := printValidationErrors(out, err)
+		if path, _ := config.Locate(configFile); path != "" {
+			return fmt.Errorf("%s: %d problem(s) found", path, n)
+		}
+		return fmt.Errorf("configuration has %d problem(s)", n)
+	}
+
+	cfg := eff.Config
+	source := eff.Path
+	if source == "" {
+		source = "none found, using defaults"
+	}
+	fmt.Fprintf(out, "Config:    %s\n", source)
+
+	var discovery []string
+	if cfg.Discovery.Enabled {
+		if cfg.Discovery.MDNSEnabled {
+			discovery = append(discovery, "mDNS "+cfg.Discovery.ServiceType)
+		}
+		if url := cfg.Discovery.EffectiveBrokerURL(); url != "" {
+			discovery = append(discovery, "broker "+url)
+		}
+		if n := len(cfg.Discovery.StaticNodes); n > 0 {
+			discovery = append(discovery, fmt.Sprintf("%d static node(s)", n))
+		}
+	}
+	if len(discovery) == 0 {
+		discovery = append(discovery, "disabled")
+	}
+	fmt.Fprintf(out, "Discovery: %s\n", strings.Join(discovery, ", "))
+
+	switch {
+	case !cfg.Broker.Enabled:
+		fmt.Fprintln(out, "Broker:    disabled")
+	case cfg.Broker.Socket != "":
+		fmt.Fprintf(out, "Broker:    unix socket %s\n", cfg.Broker.Socket)
+	default:
+		fmt.Fprintf(out, "Broker:    %s\n", net.JoinHostPort(cfg.Broker.Host, strconv.Itoa(cfg.Broker.Port)))
+	}
+	fmt.Fprintf(out, "Logging:   %s, %s to %s\n", cfg.Logging.Level, cfg.Logging.Format, cfg.Logging.Output)
+	fmt.Fprintln(out, "Configuration is valid.")
+	return nil
+}
+
+func runServe(configFile string, build version.Info) error {
+	cfg, configPath, err := internal.LoadConfig(configFile)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
@@ -46,6 +109,7 @@ func runServe(configFile string, build service.BuildInfo) error {
 	if err != nil {
 		return fmt.Errorf("failed to create hostd service: %w", err)
 	}
+	hostdService.SetConfigFile(configFile)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -55,8 +119,8 @@ func runServe(configFile string, build service.BuildInfo) error {
 	}
 
 	logger.Info("Lumen Host Broker started successfully",
-		zap.String("config", configFile),
-		zap.String("version", build.Version))
+		zap.String("config", configPath),
+		zap.Stringer("build", build))
 
 	hostdService.WaitForShutdown()
 
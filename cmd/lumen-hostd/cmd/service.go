@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"time"
 
+	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/internal"
 	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/internal/native"
+	"github.com/edwinzhancn/lumen-sdk/pkg/hostbroker"
+	"github.com/edwinzhancn/lumen-sdk/pkg/version"
 
 	"github.com/spf13/cobra"
 )
@@ -40,22 +47,104 @@ func NewStopCommand() *cobra.Command {
 }
 
 // NewStatusCommand reports whether the background service is installed and
-// running.
-func NewStatusCommand() *cobra.Command {
-	return &cobra.Command{
+// running, and which build the CLI and the running daemon are. It warns when
+// they differ, e.g. after upgrading the binary without restarting the
+// service. With leader election configured it also reports who holds the
+// Broker lease.
+func NewStatusCommand(build version.Info) *cobra.Command {
+	var configFile, socket string
+
+	cmd := &cobra.Command{
 		Use:   "status",
-		Short: "Show whether the Host Broker service is installed and running",
+		Short: "Show whether the Host Broker service is installed and running, and its version",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
 			st, err := native.New().Status()
 			if err != nil {
 				return fmt.Errorf("query service status: %w", err)
 			}
-			fmt.Printf("Installed: %v\n", st.Installed)
-			fmt.Printf("Running:   %v\n", st.Running)
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Installed: %v\n", st.Installed)
+			fmt.Fprintf(out, "Running:   %v\n", st.Running)
 			if st.Detail != "" {
-				fmt.Printf("Detail:    %s\n", st.Detail)
+				fmt.Fprintf(out, "Detail:    %s\n", st.Detail)
 			}
+			printVersions(out, build, configFile, socket)
+			printElection(out, configFile)
 			return nil
 		},
 	}
+	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	cmd.Flags().StringVar(&socket, "socket", "", "Unix socket of a socket-bound Broker (default $"+internal.SocketEnv+" or broker.socket)")
+	return cmd
+}
+
+// printVersions prints the CLI's build and, when the Broker answers, the
+// daemon's.
+func printVersions(out io.Writer, build version.Info, configFile, socket string) {
+	fmt.Fprintf(out, "CLI:       %s\n", build)
+	cfg, _, err := internal.LoadConfig(configFile)
+	if err != nil {
+		fmt.Fprintf(out, "Daemon:    unknown (%v)\n", err)
+		return
+	}
+	endpoint, err := internal.ResolveBrokerEndpoint(cfg, socket)
+	if err != nil {
+		fmt.Fprintf(out, "Daemon:    unknown (%v)\n", err)
+		return
+	}
+	daemon, err := fetchBrokerVersion(endpoint)
+	if err != nil {
+		fmt.Fprintf(out, "Daemon:    unknown (%v)\n", err)
+		return
+	}
+	fmt.Fprintf(out, "Daemon:    %s\n", daemon)
+	if buildsDiffer(build, daemon) {
+		fmt.Fprintf(out, "\nWarning: the CLI (%s) and the running daemon (%s) are different builds; restart the service to run this binary.\n",
+			build.Version, daemon.Version)
+	}
+}
+
+// printElection prints the holder of the Broker lease when leader election
+// is enabled.
+func printElection(out io.Writer, configFile string) {
+	cfg, _, err := internal.LoadConfig(configFile)
+	if err != nil || !cfg.Broker.Election.Enabled {
+		return
+	}
+	rec, err := hostbroker.ReadLease(cfg.Broker.Election.LeaseFile)
+	switch {
+	case err != nil:
+		fmt.Fprintf(out, "Leader:    unknown (%v)\n", err)
+	case !rec.Live(time.Now()):
+		fmt.Fprintf(out, "Leader:    none (lease %s not held; a standby takes over within %s)\n",
+			cfg.Broker.Election.LeaseFile, cfg.Broker.Election.LeaseTimeout)
+	default:
+		fmt.Fprintf(out, "Leader:    %s (lease expires %s unless renewed)\n", rec.Holder, rec.Expires.Format(time.RFC3339))
+	}
+}
+
+func fetchBrokerVersion(endpoint internal.BrokerEndpoint) (version.Info, error) {
+	var info version.Info
+	resp, err := endpoint.HTTPClient(2 * time.Second).Get(endpoint.URL("/v1/version"))
+	if err != nil {
+		return info, fmt.Errorf("broker %s unreachable: %w", endpoint.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return info, fmt.Errorf("broker %s returned HTTP %d", endpoint.String(), resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return info, fmt.Errorf("could not parse version response: %w", err)
+	}
+	return info, nil
+}
+
+// buildsDiffer reports whether a and b are different builds. Commits are
+// compared only when both are known.
+func buildsDiffer(a, b version.Info) bool {
+	if a.Version != b.Version {
+		return true
+	}
+	return a.Commit != "unknown" && b.Commit != "unknown" && a.Commit != b.Commit
 }
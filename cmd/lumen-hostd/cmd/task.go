@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/tabwriter"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/internal"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+
+	"github.com/spf13/cobra"
+)
+
+// NewTaskCommand groups commands that inspect how the running Host Broker
+// routes tasks.
+func NewTaskCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "task",
+		Short: "Inspect how tasks are routed to nodes",
+	}
+	cmd.AddCommand(newTaskExplainCommand())
+	return cmd
+}
+
+func newTaskExplainCommand() *cobra.Command {
+	var configFile, socket string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "explain <task>",
+		Short: "Show which node the next request for a task would go to, and why the others were passed over",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runTaskExplain(cmd.OutOrStdout(), configFile, socket, args[0], asJSON)
+		},
+	}
+	cmd.Flags().StringVar(&configFile, "config", "", "Path to configuration file (default: search standard locations)")
+	cmd.Flags().StringVar(&socket, "socket", "", "Unix socket of a socket-bound Broker (default $"+internal.SocketEnv+" or broker.socket)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the raw explanation as JSON")
+	return cmd
+}
+
+func runTaskExplain(out io.Writer, configFile, socket, task string, asJSON bool) error {
+	cfg, _, err := internal.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	endpoint, err := internal.ResolveBrokerEndpoint(cfg, socket)
+	if err != nil {
+		return err
+	}
+
+	resp, err := endpoint.HTTPClient(5 * time.Second).Get(endpoint.URL("/v1/tasks/" + url.PathEscape(task) + "/explain"))
+	if err != nil {
+		return fmt.Errorf("broker %s unreachable: %w", endpoint.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("broker returned HTTP %d: %s", resp.StatusCode, body.Error)
+	}
+
+	var exp discovery.SelectionExplanation
+	if err := json.NewDecoder(resp.Body).Decode(&exp); err != nil {
+		return fmt.Errorf("could not parse explain response: %w", err)
+	}
+	if asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(exp)
+	}
+	printExplanation(out, &exp)
+	return nil
+}
+
+func printExplanation(out io.Writer, exp *discovery.SelectionExplanation) {
+	fmt.Fprintf(out, "Task:     %s\n", exp.Task)
+	fmt.Fprintf(out, "Strategy: %s\n", exp.Strategy)
+	switch {
+	case exp.Pick == "":
+		fmt.Fprintf(out, "Pick:     none (%s)\n", exp.Error)
+	case exp.Probe:
+		fmt.Fprintf(out, "Pick:     %s (probe: no Ready node qualifies)\n", exp.Pick)
+	default:
+		fmt.Fprintf(out, "Pick:     %s\n", exp.Pick)
+	}
+	if d := exp.LastPick; d != nil {
+		fmt.Fprintf(out, "Last:     %s at %s (%d eligible)\n", d.NodeID, d.At.Format(time.RFC3339), d.Candidates)
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tADDRESS\tSTATE\tELIGIBLE\tREASON")
+	for _, c := range exp.Candidates {
+		reason := string(c.Reason)
+		if !c.CooldownUntil.IsZero() {
+			reason += " until " + c.CooldownUntil.Format(time.RFC3339)
+		}
+		if c.Quarantined {
+			reason += " (quarantined)"
+		}
+		if n := len(c.RecentErrors); n > 0 {
+			last := c.RecentErrors[n-1]
+			reason += fmt.Sprintf("; last error: %s %s", last.Op, last.Code)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\n", c.NodeID, c.Address, c.State, c.Eligible, reason)
+	}
+	w.Flush()
+}
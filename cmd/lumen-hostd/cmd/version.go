@@ -2,15 +2,14 @@ package cmd
 
 import (
 	"fmt"
-	"runtime"
 
-	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/service"
+	"github.com/edwinzhancn/lumen-sdk/pkg/version"
 
 	"github.com/spf13/cobra"
 )
 
 // NewVersionCommand prints build version information.
-func NewVersionCommand(build service.BuildInfo) *cobra.Command {
+func NewVersionCommand(build version.Info) *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
 		Short: "Print version information",
@@ -18,8 +17,8 @@ func NewVersionCommand(build service.BuildInfo) *cobra.Command {
 			fmt.Printf("Lumen Host Broker %s\n", build.Version)
 			fmt.Printf("Commit: %s\n", build.Commit)
 			fmt.Printf("Built: %s\n", build.BuildTime)
-			fmt.Printf("Go: %s\n", runtime.Version())
-			fmt.Printf("OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+			fmt.Printf("Go: %s\n", build.GoVersion)
+			fmt.Printf("OS/Arch: %s/%s\n", build.OS, build.Arch)
 			return nil
 		},
 	}
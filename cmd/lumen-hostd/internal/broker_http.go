@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils/netaddr"
+)
+
+// SocketEnv overrides the Broker socket the CLI talks to, like --socket.
+const SocketEnv = "LUMEN_HOSTD_SOCKET"
+
+// BrokerEndpoint is how the CLI reaches a running Broker: over a unix socket
+// when Socket is set, otherwise over TCP at Addr.
+type BrokerEndpoint struct {
+	Socket string
+	Addr   string
+}
+
+// ResolveBrokerEndpoint picks the Broker endpoint from, in order, the
+// --socket flag, LUMEN_HOSTD_SOCKET, and the configuration. A broker.host
+// that does not parse is an error rather than a fallback to localhost.
+func ResolveBrokerEndpoint(cfg *config.Config, socketFlag string) (BrokerEndpoint, error) {
+	socket := socketFlag
+	if socket == "" {
+		socket = os.Getenv(SocketEnv)
+	}
+	if socket == "" {
+		socket = cfg.Broker.Socket
+	}
+	if socket != "" {
+		return BrokerEndpoint{Socket: socket}, nil
+	}
+	host, err := loopbackHost(cfg.Broker.Host)
+	if err != nil {
+		return BrokerEndpoint{}, fmt.Errorf("broker.host: %w", err)
+	}
+	return BrokerEndpoint{Addr: net.JoinHostPort(host, strconv.Itoa(cfg.Broker.Port))}, nil
+}
+
+// BrokerListenAddr is the TCP address the Broker binds: broker.host, empty
+// for every interface, at broker.port.
+func BrokerListenAddr(cfg *config.Config) (string, error) {
+	host := cfg.Broker.Host
+	if host != "" {
+		var err error
+		if host, err = netaddr.ParseHost(host); err != nil {
+			return "", fmt.Errorf("broker.host: %w", err)
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(cfg.Broker.Port)), nil
+}
+
+// String describes the endpoint for diagnostics.
+func (e BrokerEndpoint) String() string {
+	if e.Socket != "" {
+		return "unix:" + e.Socket
+	}
+	return e.Addr
+}
+
+// URL returns the absolute URL of path on the Broker. Socket endpoints use a
+// placeholder host; the client from HTTPClient ignores it when dialing.
+func (e BrokerEndpoint) URL(path string) string {
+	if e.Socket != "" {
+		return "http://unix" + path
+	}
+	return "http://" + e.Addr + path
+}
+
+// HTTPClient returns an http.Client that reaches the endpoint.
+func (e BrokerEndpoint) HTTPClient(timeout time.Duration) *http.Client {
+	if e.Socket == "" {
+		return &http.Client{Timeout: timeout}
+	}
+	socket := e.Socket
+	var dialer net.Dialer
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// loopbackHost substitutes a connectable address for a bind-only host like
+// "0.0.0.0", "::" or an empty string, so local checks can dial it.
+func loopbackHost(host string) (string, error) {
+	if host == "" {
+		return "127.0.0.1", nil
+	}
+	host, err := netaddr.ParseHost(host)
+	if err != nil {
+		return "", err
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && ip.IsUnspecified() {
+		if ip.Is6() {
+			return "::1", nil
+		}
+		return "127.0.0.1", nil
+	}
+	return host, nil
+}
@@ -4,15 +4,116 @@ import (
 	"fmt"
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfig loads configuration from cfgFile or, when it is empty, from the
+// first file found in config.SearchPaths. It returns the path that was
+// loaded, or "" when the defaults are in use. Environment variable overrides
+// are applied after loading.
+func LoadConfig(cfgFile string) (*config.Config, string, error) {
+	cfg, path, err := config.LocateAndLoad(cfgFile)
+	if err != nil {
+		return nil, path, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg, path, nil
+}
+
+// Config value sources reported by EffectiveConfig.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
 )
 
-// LoadConfig loads configuration from the provided config file path.
-// If cfgFile is empty, the default configuration is used.
-// Environment variable overrides are applied after loading.
-func LoadConfig(cfgFile string) (*config.Config, error) {
-	cfg, err := config.LoadConfig(cfgFile)
+// EffectiveConfig is the configuration lumen-hostd would run with, along
+// with where each field's value came from.
+type EffectiveConfig struct {
+	Config *config.Config
+	// Path is the file that was loaded, or "" when none was found.
+	Path string
+	// Sources maps dotted YAML paths of leaf fields to SourceDefault,
+	// SourceFile or SourceEnv.
+	Sources map[string]string
+}
+
+// ResolveEffectiveConfig locates and reads the config file like LoadConfig
+// and applies environment overrides, but does not validate, so that a broken
+// configuration can still be inspected.
+func ResolveEffectiveConfig(cfgFile string) (*EffectiveConfig, error) {
+	path, err := config.Locate(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	fromFile, err := config.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	effective := *fromFile
+	if err := effective.LoadFromEnv(); err != nil {
+		return nil, fmt.Errorf("env overrides: %w", err)
+	}
+
+	defaults, err := flattenConfig(config.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	file, err := flattenConfig(fromFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load config: %w", err)
+		return nil, err
+	}
+	final, err := flattenConfig(&effective)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]string, len(final))
+	for key, value := range final {
+		switch {
+		case value != file[key]:
+			sources[key] = SourceEnv
+		case value != defaults[key]:
+			sources[key] = SourceFile
+		default:
+			sources[key] = SourceDefault
+		}
+	}
+	return &EffectiveConfig{Config: &effective, Path: path, Sources: sources}, nil
+}
+
+// flattenConfig renders every leaf field as YAML text keyed by its dotted
+// path, so values compare the way they would be written to a file.
+func flattenConfig(cfg *config.Config) (map[string]string, error) {
+	var root yaml.Node
+	if err := root.Encode(cfg); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	var walk func(n *yaml.Node, prefix string) error
+	walk = func(n *yaml.Node, prefix string) error {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			path := key.Value
+			if prefix != "" {
+				path = prefix + "." + key.Value
+			}
+			if value.Kind == yaml.MappingNode {
+				if err := walk(value, path); err != nil {
+					return err
+				}
+				continue
+			}
+			text, err := yaml.Marshal(value)
+			if err != nil {
+				return err
+			}
+			out[path] = string(text)
+		}
+		return nil
+	}
+	if err := walk(&root, ""); err != nil {
+		return nil, err
 	}
-	return cfg, nil
+	return out, nil
 }
@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveEffectiveConfigSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lumen.yaml")
+	if err := os.WriteFile(path, []byte("broker:\n  port: 7001\nlogging:\n  level: debug\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LUMEN_LOG_LEVEL", "warn")
+
+	eff, err := ResolveEffectiveConfig(path)
+	if err != nil {
+		t.Fatalf("ResolveEffectiveConfig: %v", err)
+	}
+	if eff.Path != path || eff.Config.Logging.Level != "warn" {
+		t.Fatalf("effective config = %q level %q, want %q level warn", eff.Path, eff.Config.Logging.Level, path)
+	}
+	want := map[string]string{
+		"broker.port":    SourceFile,
+		"logging.level":  SourceEnv,
+		"logging.format": SourceDefault,
+	}
+	for key, source := range want {
+		if got := eff.Sources[key]; got != source {
+			t.Errorf("source of %s = %q, want %q", key, got, source)
+		}
+	}
+}
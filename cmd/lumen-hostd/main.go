@@ -5,20 +5,13 @@ import (
 	"os"
 
 	hostdcmd "github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/cmd"
-	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/service"
+	"github.com/edwinzhancn/lumen-sdk/pkg/version"
 
 	"github.com/spf13/cobra"
 )
 
-// Build information, populated by -ldflags at build time.
-var (
-	Version   = "dev"
-	Commit    = "unknown"
-	BuildTime = "unknown"
-)
-
 func main() {
-	build := service.BuildInfo{Version: Version, Commit: Commit, BuildTime: BuildTime}
+	build := version.Get()
 
 	root := &cobra.Command{
 		Use:   "lumen-hostd",
@@ -32,8 +25,13 @@ func main() {
 		hostdcmd.NewUninstallCommand(),
 		hostdcmd.NewStartCommand(),
 		hostdcmd.NewStopCommand(),
-		hostdcmd.NewStatusCommand(),
+		hostdcmd.NewStatusCommand(build),
 		hostdcmd.NewDoctorCommand(),
+		hostdcmd.NewConfigCommand(),
+		hostdcmd.NewTaskCommand(),
+		hostdcmd.NewNodeCommand(),
+		hostdcmd.NewQueueCommand(),
+		hostdcmd.NewClusterCommand(),
 	)
 
 	if err := root.Execute(); err != nil {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -13,32 +14,36 @@ import (
 	"github.com/edwinzhancn/lumen-sdk/pkg/config"
 	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
 	"github.com/edwinzhancn/lumen-sdk/pkg/hostbroker"
+	"github.com/edwinzhancn/lumen-sdk/pkg/version"
 
 	"go.uber.org/zap"
 )
 
-// BuildInfo carries ldflags-injected version metadata, set by main and
-// surfaced at GET /v1/version.
-type BuildInfo struct {
-	Version   string
-	Commit    string
-	BuildTime string
-}
-
 // HostdService manages the Host Broker daemon's lifecycle: an internal
 // discovery client that aggregates mDNS/static node events, republished over
 // a discovery-only pkg/hostbroker server. It never serves inference.
+//
+// With broker.election enabled the service is one of an active/standby
+// pair: the client keeps its catalog warm either way, but only the elected
+// leader binds the Broker.
 type HostdService struct {
-	config    *config.Config
-	logger    *zap.Logger
-	build     BuildInfo
-	client    *client.LumenClient
-	broker    *hostbroker.Server
-	startTime time.Time
+	config   *config.Config
+	logger   *zap.Logger
+	build    version.Info
+	client   *client.LumenClient
+	election *hostbroker.LeaseElector
+	// stopElection ends the election and waits for it to release the lease.
+	stopElection func()
+	startTime    time.Time
+	// configFile is the --config flag, searched again on SIGHUP.
+	configFile string
+
+	brokerMu sync.Mutex
+	broker   *hostbroker.Server
 }
 
 // NewHostdService creates a new Host Broker service instance.
-func NewHostdService(cfg *config.Config, build BuildInfo, logger *zap.Logger) (*HostdService, error) {
+func NewHostdService(cfg *config.Config, build version.Info, logger *zap.Logger) (*HostdService, error) {
 	return &HostdService{
 		config: cfg,
 		build:  build,
@@ -46,6 +51,12 @@ func NewHostdService(cfg *config.Config, build BuildInfo, logger *zap.Logger) (*
 	}, nil
 }
 
+// SetConfigFile records the --config flag the service was started with, so
+// a reload on SIGHUP reads the same file.
+func (s *HostdService) SetConfigFile(path string) {
+	s.configFile = path
+}
+
 // Start starts the Host Broker service.
 func (s *HostdService) Start(ctx context.Context) error {
 	s.logger.Info("Starting Lumen Host Broker...")
@@ -60,17 +71,56 @@ func (s *HostdService) Start(ctx context.Context) error {
 	}
 	s.client = lumenClient
 
-	if err := s.startBroker(ctx); err != nil {
+	if s.config.Broker.Enabled && s.config.Broker.Election.Enabled {
+		s.startElection()
+	} else if err := s.startBroker(ctx); err != nil {
 		return fmt.Errorf("failed to start broker server: %w", err)
 	}
 
 	s.startTime = time.Now()
 	s.logger.Info("Lumen Host Broker started successfully",
-		zap.String("version", s.build.Version))
+		zap.Stringer("build", s.build),
+		zap.Object("startup", lumenClient.GetStartupReport()))
 
 	return nil
 }
 
+// startElection contends for the Broker lease in the background, serving
+// the Broker only while this service is leader.
+func (s *HostdService) startElection() {
+	cfg := s.config.Broker.Election
+	id := cfg.ID
+	if id == "" {
+		id = defaultElectionID()
+	}
+	s.election = hostbroker.NewLeaseElector(cfg.LeaseFile, id, cfg.LeaseTimeout, s.logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.election.Run(ctx, func() {
+			if err := s.startBroker(ctx); err != nil {
+				s.logger.Error("Failed to start broker server as leader", zap.Error(err))
+			}
+		}, s.stopBroker)
+	}()
+	s.stopElection = func() {
+		cancel()
+		<-done
+	}
+}
+
+// defaultElectionID names this process in the lease when
+// broker.election.id is unset.
+func defaultElectionID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "hostd"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
 func (s *HostdService) startBroker(ctx context.Context) error {
 	_ = ctx
 
@@ -78,19 +128,46 @@ func (s *HostdService) startBroker(ctx context.Context) error {
 		return nil
 	}
 
-	version := hostbroker.VersionInfo{
-		Version:   s.build.Version,
-		Commit:    s.build.Commit,
-		BuildTime: s.build.BuildTime,
+	opts := hostbroker.ServerOptions{
+		Docs:         s.config.Broker.Docs,
+		ReadTimeout:  s.config.Broker.ReadTimeout,
+		WriteTimeout: s.config.Broker.WriteTimeout,
+		IdleTimeout:  s.config.Broker.IdleTimeout,
+		PushToken:    s.config.Discovery.Push.Token,
+	}
+	if s.election != nil {
+		opts.Election = s.election.Status
 	}
-	broker := hostbroker.NewServer(s.client, version, s.logger)
+	broker := hostbroker.NewServerWithOptions(s.client, s.build, opts, s.logger)
+	s.brokerMu.Lock()
 	s.broker = broker
+	s.brokerMu.Unlock()
 
 	// The goroutine below closes over the local broker variable, not
 	// s.broker: if Stop() runs before this goroutine is scheduled, it nils
 	// out s.broker, and reading that (rather than the stable local) here
 	// would nil-pointer panic on broker.Start.
-	addr := fmt.Sprintf("%s:%d", s.config.Broker.Host, s.config.Broker.Port)
+	if socket := s.config.Broker.Socket; socket != "" {
+		mode, err := s.config.Broker.SocketFileMode()
+		if err != nil {
+			return err
+		}
+		ln, err := hostbroker.ListenUnix(socket, mode)
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := broker.Serve(ln); err != nil {
+				s.logger.Error("Broker server stopped with error", zap.Error(err))
+			}
+		}()
+		return nil
+	}
+
+	addr, err := internal.BrokerListenAddr(s.config)
+	if err != nil {
+		return err
+	}
 	go func() {
 		if err := broker.Start(addr); err != nil {
 			s.logger.Error("Broker server stopped with error", zap.Error(err))
@@ -104,15 +181,12 @@ func (s *HostdService) startBroker(ctx context.Context) error {
 func (s *HostdService) Stop() error {
 	s.logger.Info("Stopping Lumen Host Broker...")
 
-	if s.broker != nil {
-		if err := s.broker.ShutdownWithTimeout(5 * time.Second); err != nil {
-			s.logger.Error("Failed to stop broker server", zap.Error(err))
-		}
-		// ShutdownWithTimeout does not track hijacked connections (the
-		// /v1/nodes/watch WebSocket upgrade), so close those separately.
-		s.broker.Close()
-		s.broker = nil
+	if s.stopElection != nil {
+		// Stops the Broker if this service was leader.
+		s.stopElection()
+		s.stopElection = nil
 	}
+	s.stopBroker()
 
 	if err := internal.CloseClient(); err != nil {
 		s.logger.Error("Failed to close internal client", zap.Error(err))
@@ -124,14 +198,38 @@ func (s *HostdService) Stop() error {
 	return nil
 }
 
+// stopBroker shuts the Broker server down, if it is running.
+func (s *HostdService) stopBroker() {
+	s.brokerMu.Lock()
+	broker := s.broker
+	s.broker = nil
+	s.brokerMu.Unlock()
+	if broker == nil {
+		return
+	}
+	if err := broker.ShutdownWithTimeout(5 * time.Second); err != nil {
+		s.logger.Error("Failed to stop broker server", zap.Error(err))
+	}
+	// ShutdownWithTimeout does not track hijacked connections (the
+	// /v1/nodes/watch WebSocket upgrade), so close those separately.
+	broker.Close()
+}
+
 // WaitForShutdown blocks until a shutdown signal is received, then stops.
+// SIGHUP reloads the discovery node filters instead; see ReloadNodeFilters.
 func (s *HostdService) WaitForShutdown() {
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	s.logger.Info("Waiting for shutdown signal...")
 
 	sig := <-sigCh
+	for sig == syscall.SIGHUP {
+		if err := s.ReloadNodeFilters(); err != nil {
+			s.logger.Error("Failed to reload discovery node filters", zap.Error(err))
+		}
+		sig = <-sigCh
+	}
 	s.logger.Info("Received shutdown signal", zap.String("signal", sig.String()))
 
 	if err := s.Stop(); err != nil {
@@ -139,6 +237,28 @@ func (s *HostdService) WaitForShutdown() {
 	}
 }
 
+// ReloadNodeFilters reads the configuration again and applies its
+// discovery allow/deny lists, dropping nodes they now reject. Other changed
+// settings take effect only after a restart.
+func (s *HostdService) ReloadNodeFilters() error {
+	if s.client == nil {
+		return fmt.Errorf("service is not running")
+	}
+	cfg, path, err := internal.LoadConfig(s.configFile)
+	if err != nil {
+		return err
+	}
+	if err := s.client.SetNodeFilters(cfg.Discovery); err != nil {
+		return err
+	}
+	s.logger.Info("Reloaded discovery node filters",
+		zap.String("config", path),
+		zap.Strings("allow_nodes", cfg.Discovery.AllowNodes),
+		zap.Strings("deny_nodes", cfg.Discovery.DenyNodes),
+		zap.Strings("push_allow_nodes", cfg.Discovery.Push.AllowNodes))
+	return nil
+}
+
 // GetUptime returns the service uptime.
 func (s *HostdService) GetUptime() time.Duration {
 	if s.startTime.IsZero() {
@@ -167,6 +287,10 @@ func (s *HostdService) GetStatus() map[string]interface{} {
 			"total":  len(nodes),
 			"active": countActiveNodes(nodes),
 		}
+		status["stats"] = s.client.SystemStats()
+	}
+	if s.election != nil {
+		status["election"] = s.election.Status()
 	}
 
 	return status
@@ -2,14 +2,20 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 	"time"
 
 	"github.com/edwinzhancn/lumen-sdk/cmd/lumen-hostd/internal"
 	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/hostbroker"
+	"github.com/edwinzhancn/lumen-sdk/pkg/version"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
@@ -57,7 +63,7 @@ func newTestService(t *testing.T) (*HostdService, *config.Config) {
 	t.Cleanup(func() { _ = internal.CloseClient() })
 
 	cfg := newTestConfig(t)
-	svc, err := NewHostdService(cfg, BuildInfo{Version: "test"}, zap.NewNop())
+	svc, err := NewHostdService(cfg, version.Info{Version: "test"}, zap.NewNop())
 	if err != nil {
 		t.Fatalf("NewHostdService: %v", err)
 	}
@@ -105,7 +111,7 @@ func TestHostdServiceStartFailsWithoutDiscoveryBackend(t *testing.T) {
 		Discovery: config.DiscoveryConfig{Enabled: true}, // no mDNS, BrokerURL, or StaticNodes
 		Broker:    config.BrokerConfig{Enabled: false},
 	}
-	svc, err := NewHostdService(cfg, BuildInfo{Version: "test"}, zap.NewNop())
+	svc, err := NewHostdService(cfg, version.Info{Version: "test"}, zap.NewNop())
 	if err != nil {
 		t.Fatalf("NewHostdService: %v", err)
 	}
@@ -131,7 +137,7 @@ func TestHostdServiceInternalClientNeverUsesConfiguredBrokerURL(t *testing.T) {
 	// websocket instead of just using the configured static node.
 	cfg.Discovery.BrokerURL = "http://127.0.0.1:1"
 
-	svc, err := NewHostdService(cfg, BuildInfo{Version: "test"}, zap.NewNop())
+	svc, err := NewHostdService(cfg, version.Info{Version: "test"}, zap.NewNop())
 	if err != nil {
 		t.Fatalf("NewHostdService: %v", err)
 	}
@@ -272,3 +278,133 @@ func waitForCondition(t *testing.T, cond func() bool) {
 	}
 	t.Fatal("condition not met within deadline")
 }
+
+func TestHostdServiceServesOnUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not used on Windows")
+	}
+	svc, cfg := newTestService(t)
+	dir, err := os.MkdirTemp("", "lhd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	cfg.Broker.Port = 0
+	cfg.Broker.Socket = filepath.Join(dir, "hostd.sock")
+
+	if err := svc.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer svc.Stop()
+
+	endpoint, err := internal.ResolveBrokerEndpoint(cfg, "")
+	if err != nil {
+		t.Fatalf("ResolveBrokerEndpoint: %v", err)
+	}
+	if endpoint.Socket != cfg.Broker.Socket {
+		t.Fatalf("endpoint = %+v, want the configured socket", endpoint)
+	}
+	client := endpoint.HTTPClient(time.Second)
+	waitForCondition(t, func() bool {
+		resp, err := client.Get(endpoint.URL("/v1/health"))
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	})
+}
+
+func TestHostdServiceServesOnlyAsLeader(t *testing.T) {
+	svc, cfg := newTestService(t)
+	lease := filepath.Join(t.TempDir(), "broker.lease")
+	cfg.Broker.Election = config.ElectionConfig{Enabled: true, LeaseFile: lease, LeaseTimeout: 300 * time.Millisecond, ID: "hub-b"}
+
+	// Another hub holds the lease, so this one stands by.
+	held := hostbroker.LeaseRecord{Holder: "hub-a", Renewed: time.Now(), Expires: time.Now().Add(time.Hour)}
+	data, _ := json.Marshal(held)
+	if err := os.WriteFile(lease, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer svc.Stop()
+
+	healthURL := fmt.Sprintf("http://%s:%d/v1/health", cfg.Broker.Host, cfg.Broker.Port)
+	time.Sleep(300 * time.Millisecond)
+	if resp, err := http.Get(healthURL); err == nil {
+		resp.Body.Close()
+		t.Fatal("standby is serving the Broker")
+	}
+	if st, _ := svc.GetStatus()["election"].(*hostbroker.LeaseStatus); st == nil || st.Role != hostbroker.RoleStandby || st.Leader != "hub-a" {
+		t.Fatalf("election status = %+v, want standby behind hub-a", st)
+	}
+
+	// The leader goes away and releases its lease.
+	if err := os.Remove(lease); err != nil {
+		t.Fatal(err)
+	}
+	var health struct {
+		Election *hostbroker.LeaseStatus `json:"election"`
+	}
+	waitForCondition(t, func() bool {
+		resp, err := http.Get(healthURL)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(&health) == nil
+	})
+	if health.Election == nil || health.Election.Role != hostbroker.RoleLeader || health.Election.ID != "hub-b" {
+		t.Fatalf("health election = %+v, want hub-b leading", health.Election)
+	}
+}
+
+func TestResolveBrokerEndpointPrecedence(t *testing.T) {
+	cfg := &config.Config{Broker: config.BrokerConfig{Host: "0.0.0.0", Port: 5866}}
+	if got, err := internal.ResolveBrokerEndpoint(cfg, ""); err != nil || got.Addr != "127.0.0.1:5866" || got.Socket != "" {
+		t.Fatalf("tcp endpoint = %+v, %v", got, err)
+	}
+
+	cfg.Broker.Socket = "/from/config.sock"
+	t.Setenv(internal.SocketEnv, "/from/env.sock")
+	if got, _ := internal.ResolveBrokerEndpoint(cfg, ""); got.Socket != "/from/env.sock" {
+		t.Fatalf("env endpoint = %+v", got)
+	}
+	if got, _ := internal.ResolveBrokerEndpoint(cfg, "/from/flag.sock"); got.Socket != "/from/flag.sock" {
+		t.Fatalf("flag endpoint = %+v", got)
+	}
+}
+
+func TestResolveBrokerEndpointHosts(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    string
+		wantErr bool
+	}{
+		{host: "", want: "127.0.0.1:5866"},
+		{host: "::", want: "[::1]:5866"},
+		{host: "[::]", want: "[::1]:5866"},
+		{host: "::1", want: "[::1]:5866"},
+		{host: "Hub.Local", want: "hub.local:5866"},
+		{host: "http://hub.local", wantErr: true},
+		{host: "hub.local:5866", wantErr: true},
+		{host: "hub local", wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{Broker: config.BrokerConfig{Host: tt.host, Port: 5866}}
+		got, err := internal.ResolveBrokerEndpoint(cfg, "")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("host %q: error = %v, wantErr %v", tt.host, err, tt.wantErr)
+			continue
+		}
+		if got.Addr != tt.want {
+			t.Errorf("host %q: addr = %q, want %q", tt.host, got.Addr, tt.want)
+		}
+		if listen, err := internal.BrokerListenAddr(cfg); !tt.wantErr && (err != nil || listen == "") {
+			t.Errorf("host %q: listen addr = %q, %v", tt.host, listen, err)
+		}
+	}
+}
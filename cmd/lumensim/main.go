@@ -0,0 +1,129 @@
+// Command lumensim replays a recorded traffic trace against one or more
+// synthetic clusters through the client's balancer, in virtual time, and
+// reports per-task latency percentiles, node utilization and queued or
+// rejected requests. See pkg/client/sim and examples/sim.
+//
+//	lumensim -trace tuesday.jsonl -target-p95 500ms baseline.yaml plus-cuda.yaml
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client/sim"
+)
+
+type options struct {
+	trace     string
+	clusters  []string
+	since     time.Time
+	until     time.Time
+	seed      uint64
+	targetP95 time.Duration
+	json      bool
+}
+
+func main() {
+	opts, err := parseOptions()
+	if err != nil {
+		fatal(err)
+	}
+	trace, err := sim.ReadTraceFile(opts.trace)
+	if err != nil {
+		fatal(err)
+	}
+	trace = sim.Window(trace, opts.since, opts.until)
+	if len(trace) == 0 {
+		fatal(errors.New("no requests in the trace window"))
+	}
+
+	reports := make(map[string]*sim.Report, len(opts.clusters))
+	missed := false
+	for _, path := range opts.clusters {
+		cluster, err := sim.LoadCluster(path)
+		if err != nil {
+			fatal(fmt.Errorf("%s: %w", path, err))
+		}
+		report, err := sim.Run(trace, cluster, sim.Options{Seed: opts.seed})
+		if err != nil {
+			fatal(fmt.Errorf("%s: %w", path, err))
+		}
+		reports[path] = report
+		if opts.targetP95 > 0 && !report.MeetsP95(opts.targetP95) {
+			missed = true
+		}
+		if opts.json {
+			continue
+		}
+		fmt.Printf("== %s\n", path)
+		if err := report.WriteText(os.Stdout, opts.targetP95); err != nil {
+			fatal(err)
+		}
+		if opts.targetP95 > 0 {
+			verdict := "meets"
+			if !report.MeetsP95(opts.targetP95) {
+				verdict = "misses"
+			}
+			fmt.Printf("\n%s the p95 target of %s\n", verdict, opts.targetP95)
+		}
+		fmt.Println()
+	}
+	if opts.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			fatal(err)
+		}
+	}
+	if missed {
+		os.Exit(2)
+	}
+}
+
+func parseOptions() (options, error) {
+	var since, until string
+	opts := options{}
+	flag.StringVar(&opts.trace, "trace", "", "JSON Lines trace of requests: ts, task, payload_bytes, latency_ms")
+	flag.StringVar(&since, "since", "", "replay only requests at or after this RFC 3339 time")
+	flag.StringVar(&until, "until", "", "replay only requests before this RFC 3339 time")
+	flag.Uint64Var(&opts.seed, "seed", 0, "seed for service time draws; 0 uses each cluster's seed")
+	flag.DurationVar(&opts.targetP95, "target-p95", 0, "flag tasks whose p95 exceeds this and exit 2 if any cluster misses it")
+	flag.BoolVar(&opts.json, "json", false, "print the reports as JSON, keyed by cluster file; durations in nanoseconds")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: lumensim -trace trace.jsonl [flags] cluster.yaml...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if opts.trace == "" {
+		return opts, errors.New("-trace is required")
+	}
+	opts.clusters = flag.Args()
+	if len(opts.clusters) == 0 {
+		return opts, errors.New("at least one cluster file is required")
+	}
+	var err error
+	if opts.since, err = parseTime(since); err != nil {
+		return opts, fmt.Errorf("-since: %w", err)
+	}
+	if opts.until, err = parseTime(until); err != nil {
+		return opts, fmt.Errorf("-until: %w", err)
+	}
+	return opts, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "lumensim:", err)
+	os.Exit(1)
+}
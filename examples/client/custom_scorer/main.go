@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"go.uber.org/zap"
+)
+
+// electricityPrice is the price per kWh of each zone a node may advertise
+// under the capability Extra "zone".
+var electricityPrice = map[string]float64{
+	"hydro-north": 0.08,
+	"grid-east":   0.21,
+	"grid-west":   0.27,
+}
+
+// vramScorer prefers nodes whose free VRAM (capability Extra
+// "free_vram_mb") fits the request's estimated need, then the cheapest
+// electricity zone. Nodes that report too little VRAM are excluded.
+type vramScorer struct {
+	// bytesPerMB estimates the VRAM a request needs from its payload size.
+	bytesPerMB int
+}
+
+func (s vramScorer) Score(_ context.Context, node *discovery.NodeInfo, _ string, hints client.SelectionHints) float64 {
+	needMB := hints.PayloadBytes / s.bytesPerMB
+	freeMB, zone := -1, ""
+	for _, c := range node.Capabilities {
+		if v, err := strconv.Atoi(c.GetExtra()["free_vram_mb"]); err == nil {
+			freeMB = v
+		}
+		if z := c.GetExtra()["zone"]; z != "" {
+			zone = z
+		}
+	}
+	if freeMB >= 0 && freeMB < needMB {
+		return -1 // higher is better; negative excludes the node
+	}
+
+	score := 1.0
+	if freeMB >= needMB {
+		score += 1 // known to fit beats unknown
+	}
+	if price, ok := electricityPrice[zone]; ok {
+		score += 1 - price // cheaper zones rank higher among equals
+	}
+	return score
+}
+
+// Usage: go run main.go
+func main() {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	cfg := config.DefaultConfig()
+	cfg.Pool.Strategy = config.StrategyCustom
+	cfg.Pool.ScoreWeights = map[string]float64{
+		config.ScoreCustom: 1,
+		config.ScoreLoad:   0.5, // break near-ties toward idle nodes
+	}
+
+	lumen, err := client.NewLumenClient(cfg, logger)
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+	defer lumen.Close()
+	lumen.RegisterScorer(vramScorer{bytesPerMB: 4096})
+
+	ctx := context.Background()
+	if err := lumen.Start(ctx); err != nil {
+		log.Fatalf("Failed to start client: %v", err)
+	}
+
+	exp, err := lumen.ExplainSelection(ctx, types.TaskSemanticTextEmbed)
+	if err != nil {
+		log.Fatalf("Explain failed: %v", err)
+	}
+	fmt.Printf("Next %s request goes to %q (strategy %s)\n", exp.Task, exp.Pick, exp.Strategy)
+	for _, c := range exp.Candidates {
+		fmt.Printf("  %-24s eligible=%-5v %s\n", c.NodeID, c.Eligible, c.Reason)
+	}
+}
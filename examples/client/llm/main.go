@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/lumen"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+)
+
+// Usage: PROMPT="Write a haiku about autumn" go run main.go
+func main() {
+	prompt := os.Getenv("PROMPT")
+	if prompt == "" {
+		fmt.Println("Usage: PROMPT=\"Write a haiku about autumn\" go run main.go")
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	c, err := lumen.Connect(ctx)
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer c.Close()
+
+	gen, err := c.GenerateText(ctx, prompt,
+		types.WithMaxTokens(256),
+		types.WithTemperature(0.7),
+		types.WithDoSample(true),
+		types.WithSeed(42),
+	)
+	if err != nil {
+		log.Fatalf("Generate failed: %v", err)
+	}
+
+	fmt.Printf("Prompt: %s\n", prompt)
+	fmt.Printf("Model:  %s\n", gen.ModelID)
+	fmt.Printf("Tokens: %d in, %d out (%s)\n", gen.InputTokens, gen.GeneratedTokens, gen.FinishReason)
+	fmt.Printf("\n%s\n", gen.Text)
+}
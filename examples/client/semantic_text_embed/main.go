@@ -7,10 +7,7 @@ import (
 	"os"
 	"strings"
 
-	"github.com/edwinzhancn/lumen-sdk/pkg/client"
-	"github.com/edwinzhancn/lumen-sdk/pkg/config"
-	"github.com/edwinzhancn/lumen-sdk/pkg/types"
-	"go.uber.org/zap"
+	"github.com/edwinzhancn/lumen-sdk/pkg/lumen"
 )
 
 // Usage: EMBED_TEXT="hello world" go run main.go
@@ -21,33 +18,16 @@ func main() {
 		os.Exit(1)
 	}
 
-	logger, _ := zap.NewProduction()
-	defer logger.Sync()
-
-	lumen, err := client.NewLumenClient(config.DefaultConfig(), logger)
-	if err != nil {
-		log.Fatalf("Failed to create client: %v", err)
-	}
-	defer lumen.Close()
-
 	ctx := context.Background()
-	if err := lumen.Start(ctx); err != nil {
-		log.Fatalf("Failed to start client: %v", err)
-	}
-
-	req := types.NewInferRequest(types.TaskSemanticTextEmbed).
-		WithCorrelationID("example_text_embed").
-		ForSemanticTextEmbed(text).
-		Build()
-
-	resp, err := lumen.Infer(ctx, req)
+	c, err := lumen.Connect(ctx)
 	if err != nil {
-		log.Fatalf("Infer failed: %v", err)
+		log.Fatalf("Failed to connect: %v", err)
 	}
+	defer c.Close()
 
-	embedding, err := types.ParseInferResponse(resp).AsEmbeddingResponse()
+	embedding, err := c.Embed(ctx, text)
 	if err != nil {
-		log.Fatalf("Parse failed: %v\nRaw: %s", err, resp.Result)
+		log.Fatalf("Embed failed: %v", err)
 	}
 
 	fmt.Printf("Text:       %s\n", text)
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/vectorstore"
+	"github.com/edwinzhancn/lumen-sdk/pkg/vectorstore/memstore"
+	"github.com/edwinzhancn/lumen-sdk/pkg/vectorstore/reststore"
+	"go.uber.org/zap"
+)
+
+// Usage: PHOTO_DIR=photos go run main.go
+//
+// Indexes the faces of every photo in PHOTO_DIR, then lists the faces most
+// like the first one found. Faces are kept in memory unless QDRANT_URL (and
+// QDRANT_COLLECTION, default "faces", and QDRANT_API_KEY) name a Qdrant
+// collection created with the embeddings' size and the cosine distance.
+func main() {
+	dir := os.Getenv("PHOTO_DIR")
+	if dir == "" {
+		fmt.Println("Usage: PHOTO_DIR=photos go run main.go")
+		os.Exit(1)
+	}
+
+	var items []vectorstore.Item
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jpg", ".jpeg", ".png", ".webp":
+		default:
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		faceReq, err := types.NewFaceRecognitionRequest(data)
+		if err != nil {
+			log.Printf("Skipping %s: %v", path, err)
+			return nil
+		}
+		items = append(items, vectorstore.Item{
+			ID: path,
+			Request: types.NewInferRequest(types.TaskFaceRecognition).
+				WithCorrelationID(path).
+				ForFaceRecognitionRaw(faceReq.Payload, faceReq.PayloadMime).
+				Build(),
+			Meta: map[string]string{"path": path},
+		})
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	if len(items) == 0 {
+		log.Fatalf("No photos in %s", dir)
+	}
+
+	var store vectorstore.VectorStore = memstore.New(0)
+	if url := os.Getenv("QDRANT_URL"); url != "" {
+		collection := os.Getenv("QDRANT_COLLECTION")
+		if collection == "" {
+			collection = "faces"
+		}
+		store, err = reststore.New(reststore.Qdrant(url, collection, os.Getenv("QDRANT_API_KEY")))
+		if err != nil {
+			log.Fatalf("Invalid Qdrant config: %v", err)
+		}
+	}
+
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	lumen, err := client.NewLumenClient(config.DefaultConfig(), logger)
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+	defer lumen.Close()
+
+	ctx := context.Background()
+	if err := lumen.Start(ctx); err != nil {
+		log.Fatalf("Failed to start client: %v", err)
+	}
+
+	res, err := vectorstore.EmbedAndStore(ctx, lumen, items, store, vectorstore.PipelineOptions{
+		BatchSize: 16,
+		Batch:     client.BatchOptions{Concurrency: 4},
+		OnProgress: func(p vectorstore.PipelineProgress) {
+			fmt.Printf("  %d/%d photos, %d faces stored, %d failed\n", p.Done, p.Total, p.Stored, p.Failed)
+		},
+	})
+	if err != nil {
+		log.Fatalf("Indexing stopped: %v", err)
+	}
+	for _, f := range res.Failed {
+		fmt.Printf("  failed: %v\n", f)
+	}
+	fmt.Printf("Indexed %d faces from %d photos\n", res.Stored, len(items))
+	if res.Stored == 0 {
+		return
+	}
+
+	// Query with the first face stored. The pipeline wrote normalized
+	// vectors, so this is the face itself ranked first.
+	var probe []float32
+	var probeID string
+	for _, item := range items {
+		resp, err := lumen.Infer(ctx, item.Request)
+		if err != nil {
+			continue
+		}
+		recs, err := vectorstore.ExtractVectors(resp)
+		if err != nil || len(recs) == 0 {
+			continue
+		}
+		probe, probeID = vectorstore.Normalize(recs[0].Vector), item.ID+"/"+recs[0].ID
+		break
+	}
+	if probe == nil {
+		return
+	}
+
+	matches, err := store.Query(ctx, probe, 5)
+	if err != nil {
+		log.Fatalf("Query failed: %v", err)
+	}
+	fmt.Printf("Faces most like %s:\n", probeID)
+	for i, m := range matches {
+		fmt.Printf("  %d. %s score=%.3f confidence=%s\n", i+1, m.ID, m.Score, m.Meta["confidence"])
+	}
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+)
+
+// addressRecordingClientConn records UpdateAddresses calls and ignores
+// picker updates.
+type addressRecordingClientConn struct {
+	balancer.ClientConn
+	updated []string
+}
+
+func (c *addressRecordingClientConn) UpdateAddresses(_ balancer.SubConn, addrs []resolver.Address) {
+	for _, a := range addrs {
+		c.updated = append(c.updated, a.Addr)
+	}
+}
+
+func (c *addressRecordingClientConn) UpdateState(balancer.State) {}
+
+func TestAddressChangeKeepsNodeState(t *testing.T) {
+	identity := discovery.NewNodeIdentity("lab", "node-uuid-1")
+	key := identity.Key()
+	attr := nodeAttr{Identity: identity, Tasks: []string{"embed"}}
+
+	changes := make(chan discovery.NodeAddressChanged, 1)
+	reg := &nodeRegistry{
+		nodes:           make(map[string]*registeredNode),
+		latency:         newLatencySet(0),
+		onAddressChange: func(c discovery.NodeAddressChanged) { changes <- c },
+	}
+	cc := &addressRecordingClientConn{}
+	lb := &lumenBalancer{
+		cc:       cc,
+		registry: reg,
+		subConns: map[string]*subConnState{
+			key: {
+				addr:         setNodeAttr(resolver.Address{Addr: "192.168.1.20:5866"}, attr),
+				identity:     identity,
+				state:        connectivity.Ready,
+				tasks:        []string{"embed"},
+				hardFailures: 2,
+			},
+		},
+	}
+	reg.latency.observe(key, 20*time.Millisecond)
+
+	moved := setNodeAttr(resolver.Address{Addr: "192.168.1.77:5866"}, attr)
+	if err := lb.UpdateClientConnState(balancer.ClientConnState{
+		ResolverState: resolver.State{Addresses: []resolver.Address{moved}},
+	}); err != nil {
+		t.Fatalf("UpdateClientConnState: %v", err)
+	}
+
+	select {
+	case c := <-changes:
+		if c.NodeID != key || c.OldAddress != "192.168.1.20:5866" || c.NewAddress != "192.168.1.77:5866" || c.At.IsZero() {
+			t.Fatalf("change = %+v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no address change published")
+	}
+
+	if len(lb.subConns) != 1 {
+		t.Fatalf("subConns = %d, want the node migrated in place", len(lb.subConns))
+	}
+	scs := lb.subConns[key]
+	if scs.addr.Addr != "192.168.1.77:5866" || scs.hardFailures != 2 {
+		t.Fatalf("node state = addr %s hardFailures %d, want new address and kept failures", scs.addr.Addr, scs.hardFailures)
+	}
+	if len(cc.updated) != 1 || cc.updated[0] != "192.168.1.77:5866" {
+		t.Fatalf("UpdateAddresses calls = %v", cc.updated)
+	}
+	if stats, ok := reg.latency.snapshot()[key]; !ok || stats.Count != 1 {
+		t.Fatalf("latency after move = %+v (found %v), want history kept under %s", stats, ok, key)
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+const (
+	// DefaultBatchConcurrency is how many items of a batch InferBatch runs
+	// at once when BatchOptions.Concurrency is unset.
+	DefaultBatchConcurrency = 8
+	// DefaultMaxBatchSize is the most items InferBatch accepts when
+	// BatchOptions.MaxSize is unset.
+	DefaultMaxBatchSize = 256
+)
+
+// ErrBatchFailed is returned by InferBatch when every item failed.
+var ErrBatchFailed = fmt.Errorf("every batch item failed")
+
+// BatchOptions tune one InferBatch call; the zero value uses the defaults.
+type BatchOptions struct {
+	// Concurrency is how many items are in flight at once.
+	Concurrency int
+	// MaxSize is the most items a batch may have; larger batches are
+	// rejected whole.
+	MaxSize int
+}
+
+// BatchItem is the outcome of one request of a batch.
+type BatchItem struct {
+	// Response is the item's result; nil when Err is set.
+	Response *pb.InferResponse
+	Err      error
+	// Node is the node that served the item, as in InferResult.
+	Node string
+	// Timings is where the item's time went.
+	Timings PhaseTimings
+}
+
+// OK reports whether the item succeeded.
+func (i BatchItem) OK() bool { return i.Err == nil }
+
+// InferBatch runs reqs as independent Infer calls, at most
+// opts.Concurrency at a time, and returns their outcomes in the order of
+// reqs. An item failing does not fail the others; InferBatch returns the
+// items with ErrBatchFailed only when none succeeded. A batch larger than
+// opts.MaxSize, or containing a nil request, is rejected with an INVALID
+// error before any item is sent. Canceling ctx fails the items not yet
+// finished.
+func (c *LumenClient) InferBatch(ctx context.Context, reqs []*pb.InferRequest, opts BatchOptions) ([]BatchItem, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultBatchConcurrency
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxBatchSize
+	}
+	if len(reqs) > opts.MaxSize {
+		return nil, utils.InvalidError(fmt.Sprintf("batch has %d items, more than the maximum of %d", len(reqs), opts.MaxSize))
+	}
+	for i, req := range reqs {
+		if req == nil {
+			return nil, utils.InvalidError(fmt.Sprintf("batch item %d is nil", i))
+		}
+	}
+
+	items := make([]BatchItem, len(reqs))
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			items[i].Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, req *pb.InferRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			result, err := c.InferDetailed(ctx, req)
+			items[i] = BatchItem{Response: result.Response, Err: err, Node: result.Node, Timings: result.Timings}
+		}(i, req)
+	}
+	wg.Wait()
+
+	for _, item := range items {
+		if item.OK() {
+			return items, nil
+		}
+	}
+	if len(items) == 0 {
+		return items, nil
+	}
+	return items, ErrBatchFailed
+}
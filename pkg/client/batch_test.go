@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pickyEchoServer echoes payloads and rejects those containing "fail".
+type pickyEchoServer struct {
+	testInferenceServer
+}
+
+func (s *pickyEchoServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(req.Payload), "fail") {
+		return status.Error(codes.InvalidArgument, "rejected payload")
+	}
+	return stream.Send(&pb.InferResponse{CorrelationId: req.CorrelationId, IsFinal: true, Result: req.Payload})
+}
+
+func textRequests(texts ...string) []*pb.InferRequest {
+	reqs := make([]*pb.InferRequest, len(texts))
+	for i, text := range texts {
+		reqs[i] = types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed(text).Build()
+	}
+	return reqs
+}
+
+func TestInferBatchKeepsOrderAcrossFailures(t *testing.T) {
+	c := startClientFor(t, &pickyEchoServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+	texts := []string{"one", "fail two", "three", "four", "fail five", "six"}
+
+	items, err := c.InferBatch(context.Background(), textRequests(texts...), BatchOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("InferBatch: %v", err)
+	}
+	if len(items) != len(texts) {
+		t.Fatalf("got %d items, want %d", len(items), len(texts))
+	}
+	for i, item := range items {
+		wantOK := !strings.HasPrefix(texts[i], "fail")
+		if item.OK() != wantOK {
+			t.Fatalf("item %d (%q) ok = %v, err = %v", i, texts[i], item.OK(), item.Err)
+		}
+		if item.Node != "local-node-1" {
+			t.Errorf("item %d served by %q, want local-node-1", i, item.Node)
+		}
+		if item.Timings.Total <= 0 {
+			t.Errorf("item %d has no timings", i)
+		}
+		if wantOK && string(item.Response.Result) != texts[i] {
+			t.Errorf("item %d result = %q, want %q", i, item.Response.Result, texts[i])
+		}
+	}
+}
+
+func TestInferBatchFailsWhenEveryItemFails(t *testing.T) {
+	c := startClientFor(t, &pickyEchoServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+
+	items, err := c.InferBatch(context.Background(), textRequests("fail a", "fail b"), BatchOptions{})
+	if !errors.Is(err, ErrBatchFailed) {
+		t.Fatalf("InferBatch error = %v, want ErrBatchFailed", err)
+	}
+	if len(items) != 2 || items[0].OK() || items[1].OK() {
+		t.Fatalf("items = %+v, want two failures", items)
+	}
+}
+
+func TestInferBatchRejectsOversizedBatch(t *testing.T) {
+	c := startClientFor(t, &pickyEchoServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+
+	items, err := c.InferBatch(context.Background(), textRequests("a", "b", "c"), BatchOptions{MaxSize: 2})
+	if !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+		t.Fatalf("InferBatch error = %v, want INVALID", err)
+	}
+	if items != nil {
+		t.Fatalf("items = %+v, want none for a rejected batch", items)
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"fmt"
+	"maps"
+	"runtime/debug"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Callback kinds, the keys of PoolStats.CallbackPanics.
+const (
+	CallbackNodesChanged     = "nodes_changed"
+	CallbackCapabilityChange = "capability_change"
+	CallbackAddressChange    = "address_change"
+	CallbackStatusChange     = "status_change"
+	CallbackSelection        = "selection"
+	CallbackNodeDrained      = "node_drained"
+	CallbackNodeEjection     = "node_ejection"
+	CallbackCatalogFreshness = "catalog_freshness"
+	CallbackProgress         = "progress"
+)
+
+// callbackGuard runs user callbacks so a panicking one costs only its own
+// invocation: the panic is logged with its stack and counted per kind, and
+// the pool carries on. A callback registered for pool events that panics
+// limit times is unregistered; zero limit never unregisters. A nil guard
+// still recovers, without logging or counting.
+type callbackGuard struct {
+	logger *zap.Logger
+	limit  int
+
+	mu           sync.Mutex
+	panics       map[string]int64
+	unsubscribed map[string]int64
+}
+
+func newCallbackGuard(logger *zap.Logger, limit int) *callbackGuard {
+	return &callbackGuard{logger: logger, limit: limit}
+}
+
+// run calls fn, a callback of kind, and reports whether it panicked.
+func (g *callbackGuard) run(kind string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			g.recordPanic(kind, r, debug.Stack())
+		}
+	}()
+	fn()
+	return false
+}
+
+func (g *callbackGuard) recordPanic(kind string, r any, stack []byte) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	if g.panics == nil {
+		g.panics = make(map[string]int64)
+	}
+	g.panics[kind]++
+	g.mu.Unlock()
+	g.logger.Error("callback panicked; invocation dropped",
+		zap.String("callback", kind),
+		zap.String("panic", fmt.Sprint(r)),
+		zap.ByteString("stack", stack),
+	)
+}
+
+// exhausted reports whether a callback of kind that has panicked panics
+// times is to be unregistered, and when it is, logs and counts that.
+func (g *callbackGuard) exhausted(kind string, panics int) bool {
+	if g == nil || g.limit <= 0 || panics < g.limit {
+		return false
+	}
+	g.mu.Lock()
+	if g.unsubscribed == nil {
+		g.unsubscribed = make(map[string]int64)
+	}
+	g.unsubscribed[kind]++
+	g.mu.Unlock()
+	g.logger.Warn("callback unregistered after repeated panics",
+		zap.String("callback", kind),
+		zap.Int("panics", panics),
+	)
+	return true
+}
+
+// counts returns the panics and unregistrations per kind, nil before any.
+func (g *callbackGuard) counts() (panics, unsubscribed map[string]int64) {
+	if g == nil {
+		return nil, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.panics) > 0 {
+		panics = maps.Clone(g.panics)
+	}
+	if len(g.unsubscribed) > 0 {
+		unsubscribed = maps.Clone(g.unsubscribed)
+	}
+	return panics, unsubscribed
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestPanickingCallbacksDoNotStopRequests checks a selection watcher and a
+// progress handler that panic on every call cost only their own calls:
+// each request still succeeds, and every panic is counted.
+func TestPanickingCallbacksDoNotStopRequests(t *testing.T) {
+	c := startClientFor(t, &progressServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+	core, logs := observer.New(zapcore.ErrorLevel)
+	c.pool.callbacks.logger = zap.New(core)
+
+	var selections atomic.Int32
+	c.WatchSelections(func(discovery.SelectionDecision) {
+		selections.Add(1)
+		panic("selection watcher bug")
+	})
+	ctx := WithProgressHandler(context.Background(), func(float64, map[string]string) {
+		panic("progress handler bug")
+	})
+
+	for i := range 3 {
+		req := embedRequest()
+		resp, err := c.Infer(ctx, req)
+		if err != nil {
+			t.Fatalf("request %d after panics: %v", i, err)
+		}
+		if string(resp.Result) != string(req.Payload) {
+			t.Fatalf("request %d result = %q, want %q", i, resp.Result, req.Payload)
+		}
+	}
+
+	got := c.pool.Stats().CallbackPanics
+	if got[CallbackSelection] != int64(selections.Load()) || got[CallbackSelection] < 3 || got[CallbackProgress] != 6 {
+		t.Fatalf("callback panics = %v after %d selections, want every one and 2 progress reports per request", got, selections.Load())
+	}
+	entries := logs.FilterField(zap.String("callback", CallbackProgress)).All()
+	if len(entries) != 6 {
+		t.Fatalf("logged %d progress panics, want 6", len(entries))
+	}
+	if stack, _ := entries[0].ContextMap()["stack"].(string); !strings.Contains(stack, "runtime/debug.Stack") {
+		t.Fatalf("stack field does not hold a stack trace: %q", stack)
+	}
+}
+
+// TestCallbackUnregisteredAfterPanicLimit checks a callback that keeps
+// panicking is unregistered once it reaches pool.callback_panic_limit, while
+// well-behaved ones keep being called.
+func TestCallbackUnregisteredAfterPanicLimit(t *testing.T) {
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{NotifyWindow: -1, CallbackPanicLimit: 2})
+	pool.registry = &nodeRegistry{nodes: make(map[string]*registeredNode)}
+	t.Cleanup(func() { _ = pool.Close() })
+
+	var panicking, healthy atomic.Int32
+	pool.OnSelection(func(discovery.SelectionDecision) {
+		panicking.Add(1)
+		panic("bug")
+	})
+	pool.OnSelection(func(discovery.SelectionDecision) { healthy.Add(1) })
+	for range 4 {
+		pool.notifySelectionWatchers(discovery.SelectionDecision{})
+	}
+	if panicking.Load() != 2 || healthy.Load() != 4 {
+		t.Fatalf("panicking watcher called %d times, healthy %d; want 2 and 4", panicking.Load(), healthy.Load())
+	}
+
+	var nodeCalls atomic.Int32
+	pool.OnNodesChanged(func([]*discovery.NodeInfo) {
+		nodeCalls.Add(1)
+		panic("bug")
+	})
+	for i := range 2 {
+		pool.notifyWatchers()
+		waitUntil(t, func() bool { return nodeCalls.Load() == int32(i+1) })
+	}
+	waitUntil(t, func() bool {
+		pool.mu.RLock()
+		defer pool.mu.RUnlock()
+		return len(pool.watchers) == 0
+	})
+	pool.notifyWatchers()
+
+	stats := pool.Stats()
+	if stats.CallbacksUnregistered[CallbackSelection] != 1 || stats.CallbacksUnregistered[CallbackNodesChanged] != 1 {
+		t.Fatalf("unregistered = %v, want one selection and one node watcher", stats.CallbacksUnregistered)
+	}
+	if stats.CallbackPanics[CallbackSelection] != 2 || stats.CallbackPanics[CallbackNodesChanged] != 2 {
+		t.Fatalf("panics = %v, want 2 of each", stats.CallbackPanics)
+	}
+	if n := nodeCalls.Load(); n != 2 {
+		t.Fatalf("unregistered node watcher called %d times, want 2", n)
+	}
+}
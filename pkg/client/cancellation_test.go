@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// hangingInferServer accepts an Infer stream and never answers, reporting
+// when the first request arrives and when the server-side stream context is
+// cancelled.
+type hangingInferServer struct {
+	testInferenceServer
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func (s *hangingInferServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	close(s.started)
+	<-stream.Context().Done()
+	close(s.cancelled)
+	return stream.Context().Err()
+}
+
+func startHangingClient(t *testing.T) (*LumenClient, *hangingInferServer) {
+	t.Helper()
+	srv := &hangingInferServer{
+		testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}},
+		started:             make(chan struct{}),
+		cancelled:           make(chan struct{}),
+	}
+	return startClientFor(t, srv), srv
+}
+
+// startClientFor serves srv on a loopback port and returns a LumenClient
+// whose pool has a single Ready node pointing at it.
+func startClientFor(t testing.TB, srv pb.InferenceServer) *LumenClient {
+	t.Helper()
+	addr := startInferenceServer(t, srv)
+	host, port, _ := splitEndpoint(addr)
+
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{ConnectTimeout: 2 * time.Second})
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{{
+		Type: discovery.NodeDiscovered,
+		Resolved: discovery.ResolvedNode{
+			Identity:  discovery.NewNodeIdentity("local", "node-1"),
+			Addresses: []string{host},
+			Port:      port,
+			Txt:       map[string]string{"tasks": types.TaskSemanticTextEmbed},
+		},
+	}}}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+	waitUntil(t, func() bool { return pool.Stats().HealthyConnections > 0 })
+
+	return &LumenClient{pool: pool, config: config.DefaultConfig(), logger: zap.NewNop()}
+}
+
+func waitStarted(t *testing.T, srv *hangingInferServer) {
+	t.Helper()
+	select {
+	case <-srv.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server never received the request")
+	}
+}
+
+func TestInferCancellationReachesServerStream(t *testing.T) {
+	c, srv := startHangingClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+		_, err := c.Infer(ctx, req)
+		errCh <- err
+	}()
+
+	waitStarted(t, srv)
+	cancel()
+
+	select {
+	case <-srv.cancelled:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("server stream context not cancelled after client cancellation")
+	}
+	if err := <-errCh; err == nil {
+		t.Fatal("Infer returned nil error after cancellation")
+	}
+}
+
+func TestInferStreamCancellationReachesServerStream(t *testing.T) {
+	c, srv := startHangingClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+	ch, err := c.InferStream(ctx, req)
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+
+	waitStarted(t, srv)
+	cancel()
+
+	select {
+	case <-srv.cancelled:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("server stream context not cancelled after client cancellation")
+	}
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("unexpected response after cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("response channel not closed after cancellation")
+	}
+}
+
+// earlyFinalServer answers the first request with a final frame and returns
+// without reading the rest of the stream, like a node that rejects a request
+// before the client has finished uploading it.
+type earlyFinalServer struct {
+	testInferenceServer
+}
+
+func (s *earlyFinalServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	return stream.Send(&pb.InferResponse{CorrelationId: req.CorrelationId, IsFinal: true, Result: []byte("ok")})
+}
+
+func TestInferDoesNotLeakStreams(t *testing.T) {
+	c := startClientFor(t, &earlyFinalServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+	c.config.Chunk = config.ChunkConfig{EnableAuto: true, Threshold: 64, MaxChunkBytes: 16}
+
+	baseline := runtime.NumGoroutine()
+	const calls = 100
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			text := "hi"
+			if i%2 == 0 {
+				// Large enough to be chunked; the server answers after the
+				// first chunk, before the sender is done.
+				text = string(make([]byte, 4096))
+			}
+			req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed(text).Build()
+			if _, err := c.Infer(context.Background(), req); err != nil {
+				t.Errorf("Infer: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Every RPC must complete so the balancer sees its outcome.
+	waitUntil(t, func() bool {
+		var count uint64
+		for _, stats := range c.pool.NodeLatency() {
+			count += stats.Count
+		}
+		return count == calls
+	})
+	waitUntil(t, func() bool { return runtime.NumGoroutine() <= baseline+5 })
+}
+
+// collectingServer reads an upload to its end and answers it, counting the
+// streams it has open.
+type collectingServer struct {
+	testInferenceServer
+	open atomic.Int64
+}
+
+func (s *collectingServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	s.open.Add(1)
+	defer s.open.Add(-1)
+	var correlationID string
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.Send(&pb.InferResponse{CorrelationId: correlationID, IsFinal: true, Result: []byte("ok")})
+		}
+		if err != nil {
+			return err
+		}
+		correlationID = req.CorrelationId
+	}
+}
+
+// sendFaultClient opens streams whose Send of chunk failSeq fails with err.
+type sendFaultClient struct {
+	pb.InferenceClient
+	failSeq func() uint64
+	err     error
+}
+
+func (c *sendFaultClient) Infer(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse], error) {
+	stream, err := c.InferenceClient.Infer(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &sendFaultStream{BidiStreamingClient: stream, failSeq: c.failSeq(), err: c.err}, nil
+}
+
+type sendFaultStream struct {
+	grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse]
+	failSeq uint64
+	err     error
+}
+
+func (s *sendFaultStream) Send(req *pb.InferRequest) error {
+	if req.Seq == s.failSeq {
+		return s.err
+	}
+	return s.BidiStreamingClient.Send(req)
+}
+
+// TestInferSendFailuresDoNotLeak fails the upload of chunked requests at a
+// random chunk, many times over and concurrently, and checks every Infer
+// returns the failure while no stream or goroutine outlives it.
+func TestInferSendFailuresDoNotLeak(t *testing.T) {
+	srv := &collectingServer{testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}}
+	c := startClientFor(t, srv)
+	c.config.Chunk = config.ChunkConfig{EnableAuto: true, Threshold: 64, MaxChunkBytes: 16}
+	const chunks = 32
+
+	seed := time.Now().UnixNano()
+	t.Logf("seed %d", seed)
+	var rngMu sync.Mutex
+	rng := rand.New(rand.NewSource(seed))
+	injected := errors.New("injected send failure")
+	c.pool.cli = &sendFaultClient{
+		InferenceClient: c.pool.cli,
+		failSeq: func() uint64 {
+			rngMu.Lock()
+			defer rngMu.Unlock()
+			return uint64(rng.Intn(chunks))
+		},
+		err: injected,
+	}
+
+	baseline := runtime.NumGoroutine()
+	const workers, iterations = 8, 25
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed(string(make([]byte, chunks*16))).Build()
+				if _, err := c.Infer(context.Background(), req); !errors.Is(err, injected) {
+					t.Errorf("Infer error = %v, want the injected send failure", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	waitUntil(t, func() bool { return srv.open.Load() == 0 && c.pool.Stats().OpenStreams == 0 })
+	waitUntil(t, func() bool { return runtime.NumGoroutine() <= baseline+5 })
+}
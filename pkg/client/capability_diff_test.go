@@ -0,0 +1,276 @@
+package client
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// swappableCapabilityServer advertises whatever capability was set last.
+type swappableCapabilityServer struct {
+	pb.UnimplementedInferenceServer
+	mu  sync.Mutex
+	cap *pb.Capability
+}
+
+func (s *swappableCapabilityServer) set(cap *pb.Capability) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cap = cap
+}
+
+func (s *swappableCapabilityServer) StreamCapabilities(_ *emptypb.Empty, stream grpc.ServerStreamingServer[pb.Capability]) error {
+	s.mu.Lock()
+	cap := s.cap
+	s.mu.Unlock()
+	return stream.Send(cap)
+}
+
+// nopClientConn accepts picker updates and ignores them.
+type nopClientConn struct{ balancer.ClientConn }
+
+func (nopClientConn) UpdateState(balancer.State) {}
+
+func TestCapabilityRefetchPublishesDiff(t *testing.T) {
+	srv := &swappableCapabilityServer{}
+	srv.set(&pb.Capability{
+		ServiceName:    "vision",
+		ModelIds:       []string{"clip-b32", "scrfd"},
+		Runtime:        "onnxrt-cpu",
+		MaxConcurrency: 2,
+		Tasks:          []*pb.IOTask{{Name: "embed"}, {Name: "face"}},
+	})
+	addr := startInferenceServer(t, srv)
+
+	diffs := make(chan discovery.CapabilityDiff, 1)
+	reg := &nodeRegistry{
+		nodes:              make(map[string]*registeredNode),
+		onCapabilityChange: func(d discovery.CapabilityDiff) { diffs <- d },
+	}
+	lb := &lumenBalancer{
+		cc:       nopClientConn{},
+		subConns: map[string]*subConnState{"local-node-1": {}},
+		registry: reg,
+		options:  balancerOptions{capFetchTimeout: 2 * time.Second},
+	}
+
+	if !lb.fetchCapabilitiesForNode("local-node-1", addr) {
+		t.Fatal("first fetch failed")
+	}
+	select {
+	case d := <-diffs:
+		t.Fatalf("first fetch published diff %+v, want none", d)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	srv.set(&pb.Capability{
+		ServiceName:    "vision",
+		ModelIds:       []string{"clip-b32", "ppocr"},
+		Runtime:        "onnxrt-cuda",
+		MaxConcurrency: 8,
+		Tasks:          []*pb.IOTask{{Name: "embed"}, {Name: "ocr"}},
+	})
+	if !lb.fetchCapabilitiesForNode("local-node-1", addr) {
+		t.Fatal("second fetch failed")
+	}
+
+	var diff discovery.CapabilityDiff
+	select {
+	case diff = <-diffs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no capability diff published")
+	}
+	want := discovery.CapabilityDiff{
+		NodeID:             "local-node-1",
+		At:                 diff.At,
+		TasksAdded:         []string{"ocr"},
+		TasksRemoved:       []string{"face"},
+		ModelsAdded:        []string{"ppocr"},
+		ModelsRemoved:      []string{"scrfd"},
+		RuntimeChanges:     []discovery.RuntimeChange{{Service: "vision", Old: "onnxrt-cpu", New: "onnxrt-cuda"}},
+		ConcurrencyChanges: []discovery.ConcurrencyChange{{Service: "vision", Old: 2, New: 8}},
+	}
+	if diff.At.IsZero() || !reflect.DeepEqual(diff, want) {
+		t.Fatalf("diff = %+v\nwant %+v", diff, want)
+	}
+
+	infos := reg.nodeInfos()
+	if len(infos) != 1 || infos[0].LastCapabilityDiff == nil {
+		t.Fatalf("node infos = %+v, want the last diff attached", infos)
+	}
+	if !infos[0].LastCapabilityChange.Equal(diff.At) {
+		t.Fatalf("last_capability_change = %v, want %v", infos[0].LastCapabilityChange, diff.At)
+	}
+	if got := discovery.CloneNode(infos[0]).LastCapabilityDiff; !reflect.DeepEqual(got, infos[0].LastCapabilityDiff) {
+		t.Fatalf("cloned diff = %+v", got)
+	}
+
+	// An identical re-fetch is not a change.
+	if !lb.fetchCapabilitiesForNode("local-node-1", addr) {
+		t.Fatal("third fetch failed")
+	}
+	select {
+	case d := <-diffs:
+		t.Fatalf("unchanged fetch published diff %+v", d)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestCapabilityRefetchRefreshesTaskSet checks the task lookup the picker
+// filters on follows a capability change in both directions, and that a
+// discovery hint the fetched capabilities contradict stops routing.
+func TestCapabilityRefetchRefreshesTaskSet(t *testing.T) {
+	srv := &swappableCapabilityServer{}
+	srv.set(&pb.Capability{ServiceName: "vision", Tasks: []*pb.IOTask{{Name: "embed"}, {Name: "face"}}})
+	addr := startInferenceServer(t, srv)
+
+	reg := &nodeRegistry{nodes: make(map[string]*registeredNode)}
+	scs := &subConnState{identity: discovery.NewNodeIdentity("local", "node-1"), state: connectivity.Ready, hintTasks: []string{"hinted"}}
+	lb := &lumenBalancer{
+		cc:       nopClientConn{},
+		subConns: map[string]*subConnState{"local-node-1": scs},
+		registry: reg,
+		options:  balancerOptions{capFetchTimeout: 2 * time.Second},
+	}
+	routable := func(task string) bool {
+		candidates, _ := reg.picker.Load().candidates(task, time.Now(), nil)
+		return len(candidates) == 1 && reg.nodes["local-node-1"].taskSet.has(task)
+	}
+
+	if !lb.fetchCapabilitiesForNode("local-node-1", addr) {
+		t.Fatal("first fetch failed")
+	}
+	if !routable("face") || routable("hinted") || routable("ocr") {
+		t.Fatalf("after first fetch tasks = %v", scs.tasks)
+	}
+
+	srv.set(&pb.Capability{ServiceName: "vision", Tasks: []*pb.IOTask{{Name: "embed"}, {Name: "ocr"}}})
+	if !lb.fetchCapabilitiesForNode("local-node-1", addr) {
+		t.Fatal("second fetch failed")
+	}
+	if !routable("ocr") || routable("face") || routable("hinted") {
+		t.Fatalf("after capability change tasks = %v", scs.tasks)
+	}
+}
+
+// multiCapabilityServer streams the capabilities set last, one per service,
+// then fails the stream with err when it is set.
+type multiCapabilityServer struct {
+	pb.UnimplementedInferenceServer
+	mu   sync.Mutex
+	caps []*pb.Capability
+	err  error
+}
+
+func (s *multiCapabilityServer) set(err error, caps ...*pb.Capability) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.caps, s.err = caps, err
+}
+
+func (s *multiCapabilityServer) StreamCapabilities(_ *emptypb.Empty, stream grpc.ServerStreamingServer[pb.Capability]) error {
+	s.mu.Lock()
+	caps, err := s.caps, s.err
+	s.mu.Unlock()
+	for _, cap := range caps {
+		if err := stream.Send(cap); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// TestPartialCapabilityFetchKeepsStaleServices fetches from a node serving
+// three services while one of them fails in different ways.
+func TestPartialCapabilityFetchKeepsStaleServices(t *testing.T) {
+	service := func(name, model string, tasks ...string) *pb.Capability {
+		cap := &pb.Capability{ServiceName: name, ModelIds: []string{model}, Runtime: "onnxrt-cpu"}
+		for _, task := range tasks {
+			cap.Tasks = append(cap.Tasks, &pb.IOTask{Name: task})
+		}
+		return cap
+	}
+	failing := func(name string) *pb.Capability {
+		return &pb.Capability{ServiceName: name, Extra: map[string]string{discovery.CapabilityErrorExtraKey: "backend timed out"}}
+	}
+	srv := &multiCapabilityServer{}
+	addr := startInferenceServer(t, srv)
+	reg := &nodeRegistry{nodes: make(map[string]*registeredNode), recent: newRecentErrors()}
+	scs := &subConnState{identity: discovery.NewNodeIdentity("local", "node-1"), state: connectivity.Ready}
+	lb := &lumenBalancer{
+		cc:       nopClientConn{},
+		subConns: map[string]*subConnState{"local-node-1": scs},
+		registry: reg,
+		options:  balancerOptions{capFetchTimeout: 2 * time.Second},
+	}
+	check := func(step string, wantOK bool, wantServices, wantTasks, wantStale []string) {
+		t.Helper()
+		if ok := lb.fetchCapabilitiesForNode("local-node-1", addr); ok != wantOK {
+			t.Fatalf("%s: fetch = %v, want %v", step, ok, wantOK)
+		}
+		var services []string
+		for _, cap := range scs.capabilities {
+			services = append(services, cap.GetServiceName())
+		}
+		if !reflect.DeepEqual(services, wantServices) || !reflect.DeepEqual(scs.tasks, wantTasks) || !reflect.DeepEqual(scs.staleServices, wantStale) {
+			t.Fatalf("%s: services %v tasks %v stale %v, want %v %v %v", step, services, scs.tasks, scs.staleServices, wantServices, wantTasks, wantStale)
+		}
+	}
+
+	srv.set(nil, service("vision", "clip", "embed"), service("ocr", "ppocr", "ocr"), service("llm", "qwen", "chat"))
+	check("complete", true, []string{"vision", "ocr", "llm"}, []string{"embed", "ocr", "chat"}, nil)
+
+	// ocr reports failing and llm is no longer served: ocr keeps its old
+	// capability, llm is gone.
+	srv.set(nil, service("vision", "clip", "embed", "face"), failing("ocr"))
+	check("service failed", true, []string{"vision", "ocr"}, []string{"embed", "face", "ocr"}, []string{"ocr"})
+	if infos := reg.nodeInfos(); len(infos) != 1 || !reflect.DeepEqual(infos[0].StaleServices, []string{"ocr"}) {
+		t.Fatalf("node infos = %+v, want ocr stale", infos)
+	}
+
+	// The stream breaks off after ocr: vision is kept, ocr refreshed.
+	srv.set(status.Error(codes.Unavailable, "backend restarting"), service("ocr", "ppocr-v4", "ocr"))
+	check("stream broke", true, []string{"ocr", "vision"}, []string{"ocr", "embed", "face"}, []string{"vision"})
+
+	// Nothing usable: the fetch fails and the capabilities stand.
+	srv.set(nil, failing("vision"), failing("ocr"))
+	check("all failed", false, []string{"ocr", "vision"}, []string{"ocr", "embed", "face"}, []string{"vision"})
+
+	var ops []string
+	for _, e := range reg.recent.get("local-node-1", recentErrorsLen) {
+		ops = append(ops, e.Op+" "+e.Code)
+	}
+	want := []string{"capability SERVICE_UNAVAILABLE", "capability UNAVAILABLE", "capability SERVICE_UNAVAILABLE", "capability SERVICE_UNAVAILABLE"}
+	if !reflect.DeepEqual(ops, want) {
+		t.Fatalf("recent errors = %v, want %v", ops, want)
+	}
+
+	srv.set(nil, service("vision", "clip", "embed"), service("ocr", "ppocr", "ocr"))
+	check("recovered", true, []string{"vision", "ocr"}, []string{"embed", "ocr"}, nil)
+}
+
+func TestBuildModelInfosListsSharedModelsOnce(t *testing.T) {
+	models := buildModelInfos([]*pb.Capability{
+		{ServiceName: "embed", ModelIds: []string{"clip"}, Runtime: "onnxrt-cpu"},
+		{ServiceName: "classify", ModelIds: []string{"clip", "bioclip"}, Runtime: "onnxrt-cpu"},
+		{ServiceName: "embed-gpu", ModelIds: []string{"clip"}, Runtime: "onnxrt-cuda"},
+	})
+	var got []string
+	for _, m := range models {
+		got = append(got, m.ID+"/"+m.Runtime)
+	}
+	if want := []string{"clip/onnxrt-cpu", "bioclip/onnxrt-cpu", "clip/onnxrt-cuda"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("models = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+// Catalog freshness is checked every quarter of PoolOptions.MaxCatalogAge,
+// within these bounds.
+const (
+	minCatalogCheckInterval = time.Second
+	maxCatalogCheckInterval = 30 * time.Second
+)
+
+// newCatalogTracker tracks catalog freshness under o. Static nodes are
+// vouched for only by passing health checks, so they never go stale when
+// health checks are off.
+func newCatalogTracker(o PoolOptions, clock func() time.Time) *discovery.CatalogTracker {
+	var exempt []string
+	if o.HealthCheckInterval <= 0 {
+		exempt = append(exempt, discovery.SourceStatic)
+	}
+	return discovery.NewCatalogTracker(o.MaxCatalogAge, clock, exempt...)
+}
+
+// monitorCatalog checks catalog freshness until stop is closed, so a
+// catalog going stale is logged and reported to OnCatalogFreshness
+// callbacks even while no request asks.
+func (p *Pool) monitorCatalog(stop <-chan struct{}) {
+	interval := min(max(p.options.MaxCatalogAge/4, minCatalogCheckInterval), maxCatalogCheckInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.checkCatalog()
+		}
+	}
+}
+
+// checkCatalog returns the catalog's freshness. When it has turned stale,
+// or fresh again, since the last check it logs the change and notifies
+// OnCatalogFreshness callbacks.
+func (p *Pool) checkCatalog() discovery.CatalogFreshness {
+	f, changed := p.catalog.Check()
+	if !changed {
+		return f
+	}
+	if f.Stale {
+		p.logger.Warn("discovery catalog is stale",
+			zap.Duration("age", f.Age),
+			zap.Duration("max_age", f.MaxAge),
+			zap.String("oldest", f.Oldest),
+		)
+	} else {
+		p.logger.Info("discovery catalog is fresh again", zap.Duration("age", f.Age))
+	}
+	for _, w := range p.catalogWatch.snapshot() {
+		go w.deliver(p.callbacks, CallbackCatalogFreshness, f)
+	}
+	return f
+}
+
+// OnCatalogFreshness registers a callback invoked when the catalog turns
+// stale and when it turns fresh again. The returned func unregisters it.
+func (p *Pool) OnCatalogFreshness(cb func(discovery.CatalogFreshness)) (unsubscribe func()) {
+	return p.catalogWatch.add(cb)
+}
+
+// staleCatalogError returns a SERVICE_UNAVAILABLE error while the catalog
+// is stale and PoolOptions.FailOnStaleCatalog is set, and nil otherwise.
+func (p *Pool) staleCatalogError(task string) error {
+	if !p.options.FailOnStaleCatalog {
+		return nil
+	}
+	f := p.checkCatalog()
+	if !f.Stale {
+		return nil
+	}
+	return utils.ServiceUnavailableError("stale catalog", map[string]string{
+		"task":    task,
+		"age":     f.Age.String(),
+		"max_age": f.MaxAge.String(),
+		"oldest":  f.Oldest,
+	})
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"go.uber.org/zap"
+)
+
+// catalogClock is a settable clock for the pool's catalog tracker.
+type catalogClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *catalogClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *catalogClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// chanNodeResolver emits whatever is sent on events until ctx is done.
+type chanNodeResolver struct {
+	events chan discovery.NodeEvent
+}
+
+func (r *chanNodeResolver) Watch(context.Context) (<-chan discovery.NodeEvent, error) {
+	return r.events, nil
+}
+
+// startFreshnessClient connects a client to one pushed node, with catalog
+// freshness measured on clock.
+func startFreshnessClient(t *testing.T, opts PoolOptions, clock *catalogClock) (*LumenClient, *chanNodeResolver, discovery.NodeIdentity) {
+	t.Helper()
+	addr := startInferenceServer(t, &pickyEchoServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+	host, port, _ := splitEndpoint(addr)
+
+	opts.ConnectTimeout = 2 * time.Second
+	pool := NewPoolWithOptions(zap.NewNop(), opts)
+	pool.catalog = newCatalogTracker(pool.options, clock.Now)
+	id := discovery.NewNodeIdentity("local", "node-1")
+	res := &chanNodeResolver{events: make(chan discovery.NodeEvent, 8)}
+	res.events <- discovery.NodeEvent{
+		Type:   discovery.NodeDiscovered,
+		Source: discovery.SourcePush,
+		Resolved: discovery.ResolvedNode{
+			Identity:  id,
+			Addresses: []string{host},
+			Port:      port,
+			Txt:       map[string]string{"tasks": types.TaskSemanticTextEmbed},
+		},
+	}
+	if err := pool.Connect(res); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+	waitUntil(t, func() bool { return pool.Stats().HealthyConnections > 0 })
+
+	return &LumenClient{pool: pool, config: config.DefaultConfig(), logger: zap.NewNop()}, res, id
+}
+
+func TestStaleCatalogEscalates(t *testing.T) {
+	clock := &catalogClock{now: time.Unix(1_700_000_000, 0)}
+	c, res, id := startFreshnessClient(t, PoolOptions{MaxCatalogAge: time.Minute, FailOnStaleCatalog: true}, clock)
+	changes := make(chan discovery.CatalogFreshness, 4)
+	c.WatchCatalogFreshness(func(f discovery.CatalogFreshness) { changes <- f })
+	req := func() *pb.InferRequest {
+		return types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+	}
+
+	if _, err := c.Infer(context.Background(), req()); err != nil {
+		t.Fatalf("Infer on a fresh catalog: %v", err)
+	}
+
+	// Past MaxCatalogAge: status and stats report the catalog stale.
+	clock.Advance(time.Minute + time.Second)
+	st := c.DiscoveryStatus()
+	if st.Catalog == nil || !st.Catalog.Stale || st.Catalog.Oldest != id.Key() {
+		t.Fatalf("DiscoveryStatus().Catalog = %+v, want stale on %s", st.Catalog, id.Key())
+	}
+	if st.Degraded {
+		t.Fatal("a stale catalog marked the discovery backends degraded")
+	}
+	if stats := c.DiscoveryStats(); !stats.Catalog.Stale {
+		t.Fatalf("DiscoveryStats().Catalog = %+v, want stale", stats.Catalog)
+	}
+
+	// Requests fail fast, as transport failures, and watchers hear of the
+	// change.
+	_, err := c.Infer(context.Background(), req())
+	if !utils.HasErrorCode(err, utils.ErrCodeConnectionFailed) || !utils.HasErrorCode(errors.Unwrap(err), utils.ErrCodeServiceUnavailable) {
+		t.Fatalf("Infer on a stale catalog = %v, want CONNECTION_FAILED wrapping SERVICE_UNAVAILABLE", err)
+	}
+	if f := waitCatalogChange(t, changes); !f.Stale || f.Since.IsZero() {
+		t.Fatalf("change = %+v, want stale with Since set", f)
+	}
+
+	// A heartbeat makes the node current again.
+	res.events <- discovery.NodeEvent{Type: discovery.NodeRefreshed, Source: discovery.SourcePush, Identity: id}
+	waitUntil(t, func() bool { return !c.DiscoveryStatus().Catalog.Stale })
+	if _, err := c.Infer(context.Background(), req()); err != nil {
+		t.Fatalf("Infer after the refresh: %v", err)
+	}
+	if f := waitCatalogChange(t, changes); f.Stale {
+		t.Fatalf("change = %+v, want fresh again", f)
+	}
+}
+
+func TestStaleCatalogOnlyWarnsByDefault(t *testing.T) {
+	clock := &catalogClock{now: time.Unix(1_700_000_000, 0)}
+	c, _, _ := startFreshnessClient(t, PoolOptions{MaxCatalogAge: time.Minute}, clock)
+
+	clock.Advance(2 * time.Minute)
+	if f := c.pool.checkCatalog(); !f.Stale {
+		t.Fatalf("checkCatalog = %+v, want stale", f)
+	}
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+	if _, err := c.Infer(context.Background(), req); err != nil {
+		t.Fatalf("Infer on a stale catalog without fail_on_stale_catalog: %v", err)
+	}
+}
+
+func waitCatalogChange(t *testing.T, changes <-chan discovery.CatalogFreshness) discovery.CatalogFreshness {
+	t.Helper()
+	select {
+	case f := <-changes:
+		return f
+	case <-time.After(2 * time.Second):
+		t.Fatal("no catalog freshness change delivered")
+		return discovery.CatalogFreshness{}
+	}
+}
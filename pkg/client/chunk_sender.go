@@ -0,0 +1,295 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ResumeFromMetaKey is the request Meta key of the chunks re-sent on a new
+// stream after the upload's stream broke, holding the Seq of the first one.
+// Only nodes advertising discovery.FeatureResume get such a stream: they
+// join it to the upload with the same CorrelationId, ignoring chunks they
+// already have.
+const ResumeFromMetaKey = "lumen.resume_from"
+
+// chunkRetryBackoff is the wait before the first retry of a chunk; it
+// doubles with every further one.
+const chunkRetryBackoff = 20 * time.Millisecond
+
+type inferStream = grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse]
+
+// chunkSenderOptions shapes what a chunkSender uploads.
+type chunkSenderOptions struct {
+	// lo and hi bound the chunks sent, chunks[lo:hi]; hi 0 means every
+	// chunk. Chunks keep the Seq and Offset of their place in the whole
+	// payload.
+	lo, hi int
+	// meta is attached to every chunk; nil means the request's Meta.
+	meta map[string]string
+	// abort is called when the upload fails on the client's side, so the
+	// receiver sees the failure on its next Recv rather than waiting for
+	// the node. It is not called when the node ended the stream (io.EOF)
+	// or the upload was stopped.
+	abort func()
+	// retries bounds the retries of one chunk whose Send failed with a
+	// transient error, and the streams reopened for the upload; 0 fails
+	// at the first error.
+	retries int
+	// reopen opens a new stream to the same node when the current one
+	// broke; the sender re-sends from the failed chunk on it. nil fails the
+	// upload instead.
+	reopen func() (inferStream, error)
+	// counters counts retries and reopened streams; nil counts nothing.
+	counters *sendRetryCounters
+}
+
+// senderOptions are the options of c's chunk uploads: chunk.send_retries
+// and c's counters, with abort.
+func (c *LumenClient) senderOptions(abort func()) chunkSenderOptions {
+	opts := chunkSenderOptions{abort: abort, counters: &c.sendRetries}
+	if c.config != nil {
+		opts.retries = c.config.Chunk.SendRetries
+	}
+	return opts
+}
+
+// sendRetryCounters counts how chunk uploads recovered from failed Sends.
+type sendRetryCounters struct {
+	retries     atomic.Int64
+	recreations atomic.Int64
+}
+
+func (c *sendRetryCounters) retried() {
+	if c != nil {
+		c.retries.Add(1)
+	}
+}
+
+func (c *sendRetryCounters) recreated() {
+	if c != nil {
+		c.recreations.Add(1)
+	}
+}
+
+// chunkSender uploads a chunked payload on one stream from its own
+// goroutine, while the caller receives. It owns CloseSend, which it calls
+// once the last chunk is sent or the upload fails. Infer, InferStream and
+// every part of a parallel upload send through it.
+type chunkSender struct {
+	mu      sync.Mutex
+	stream  inferStream
+	swapped chan struct{} // closed and replaced when stream is
+
+	req    *pb.InferRequest
+	chunks [][]byte
+	opts   chunkSenderOptions
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+func newChunkSender(stream inferStream, req *pb.InferRequest, chunks [][]byte, opts chunkSenderOptions) *chunkSender {
+	if opts.hi == 0 {
+		opts.hi = len(chunks)
+	}
+	if opts.meta == nil {
+		opts.meta = req.Meta
+	}
+	return &chunkSender{
+		stream:  stream,
+		swapped: make(chan struct{}),
+		req:     req,
+		chunks:  chunks,
+		opts:    opts,
+		cancel:  func() {},
+		done:    make(chan struct{}),
+	}
+}
+
+// Start begins the upload. It stops at the first Send error it cannot
+// retry, or before the next chunk once ctx ends or Stop is called.
+func (s *chunkSender) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(s.done)
+		s.err = s.send(ctx)
+		_ = s.Stream().CloseSend()
+		if s.err != nil && s.err != io.EOF && ctx.Err() == nil && s.opts.abort != nil {
+			s.opts.abort()
+		}
+	}()
+}
+
+// Stop ends the upload before its next chunk, e.g. once the node has sent
+// its final frame. It does not wait; use Done.
+func (s *chunkSender) Stop() {
+	s.cancel()
+}
+
+// Done is closed once the sender has finished and called CloseSend.
+func (s *chunkSender) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the upload stopped early: a Send error, io.EOF when the
+// node ended the stream, or the context error. It is nil while the upload
+// runs and after every chunk was sent.
+func (s *chunkSender) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Stream returns the stream the upload is on: the one the sender was
+// created with, or the last one reopened after it broke.
+func (s *chunkSender) Stream() inferStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stream
+}
+
+// Reopened waits until the upload moves off stream, whose Recv failed,
+// and returns the stream it moved to, or nil once the sender finished
+// without moving. A receiver keeps receiving on the stream returned. It
+// returns nil at once when the sender cannot reopen streams.
+func (s *chunkSender) Reopened(stream inferStream) inferStream {
+	if s.opts.reopen == nil {
+		return nil
+	}
+	for {
+		s.mu.Lock()
+		current, swapped := s.stream, s.swapped
+		s.mu.Unlock()
+		if current != stream {
+			return current
+		}
+		select {
+		case <-swapped:
+		case <-s.done:
+			if current := s.Stream(); current != stream {
+				return current
+			}
+			return nil
+		}
+	}
+}
+
+// send uploads chunks[lo:hi], numbering them by their place in the whole
+// payload and attaching meta to each. A chunk whose Send fails with a
+// transient error is retried on the same stream, and one whose stream
+// broke is re-sent on a reopened stream, each up to opts.retries times
+// with a doubling backoff. It stops at any other Send error or once ctx
+// is cancelled.
+func (s *chunkSender) send(ctx context.Context) error {
+	var offset uint64
+	for _, chunk := range s.chunks[:s.opts.lo] {
+		offset += uint64(len(chunk))
+	}
+	total := uint64(len(s.chunks))
+	meta := s.opts.meta
+	reopened := 0
+	for i := s.opts.lo; i < s.opts.hi; i++ {
+		chunk := s.chunks[i]
+		for attempt := 0; ; attempt++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			sendReq := &pb.InferRequest{
+				CorrelationId: s.req.CorrelationId,
+				Task:          s.req.Task,
+				Payload:       chunk,
+				PayloadMime:   s.req.PayloadMime,
+				Seq:           uint64(i),
+				Total:         total,
+				Offset:        offset,
+				Meta:          meta,
+			}
+			err := s.Stream().Send(sendReq)
+			if err == nil {
+				break
+			}
+			if attempt >= s.opts.retries {
+				return err
+			}
+			switch {
+			case retryableSendError(err):
+				s.opts.counters.retried()
+			case s.opts.reopen != nil && reopened < s.opts.retries && brokenStreamError(err):
+				stream, reopenErr := s.opts.reopen()
+				if reopenErr != nil {
+					return err
+				}
+				reopened++
+				s.opts.counters.recreated()
+				meta = maps.Clone(s.opts.meta)
+				if meta == nil {
+					meta = make(map[string]string, 1)
+				}
+				meta[ResumeFromMetaKey] = strconv.Itoa(i)
+				s.swap(stream)
+			default:
+				return err
+			}
+			if err := sleepCtx(ctx, chunkRetryBackoff<<attempt); err != nil {
+				return err
+			}
+		}
+		offset += uint64(len(chunk))
+	}
+	return nil
+}
+
+// swap moves the upload to stream, waking receivers waiting in Reopened.
+func (s *chunkSender) swap(stream inferStream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stream = stream
+	close(s.swapped)
+	s.swapped = make(chan struct{})
+}
+
+// retryableSendError reports whether a failed Send may succeed on the same
+// stream if tried again: the local network stack ran short of buffers or
+// asked to try again.
+func retryableSendError(err error) bool {
+	if errors.Is(err, syscall.ENOBUFS) || errors.Is(err, syscall.EAGAIN) {
+		return true
+	}
+	var temp interface{ Temporary() bool }
+	return errors.As(err, &temp) && temp.Temporary()
+}
+
+// brokenStreamError reports whether a failed Send means the stream, not
+// the request, is gone, so the upload may go on over a new one. io.EOF is
+// not: the node ended the stream and reports why on Recv.
+func brokenStreamError(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+// sleepCtx waits for d or until ctx ends, returning ctx's error then.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,285 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// chunkRecordingStream records what a chunkSender sends. failAt makes the
+// Send of that Seq fail with sendErr; faults makes the Sends of a Seq fail
+// with its errors in turn, one per Send, before one goes through; block
+// holds every Send until closed.
+type chunkRecordingStream struct {
+	fakeInferStream
+	failAt uint64
+	block  chan struct{}
+
+	mu     sync.Mutex
+	faults map[uint64][]error
+	sent   []*pb.InferRequest
+	closes int
+}
+
+func (s *chunkRecordingStream) Send(req *pb.InferRequest) error {
+	if s.block != nil {
+		<-s.block
+	}
+	if s.sendErr != nil && req.Seq == s.failAt {
+		return s.sendErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errs := s.faults[req.Seq]; len(errs) > 0 {
+		s.faults[req.Seq] = errs[1:]
+		return errs[0]
+	}
+	s.sent = append(s.sent, req)
+	return nil
+}
+
+func (s *chunkRecordingStream) seqs() []uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seqs := make([]uint64, len(s.sent))
+	for i, req := range s.sent {
+		seqs[i] = req.Seq
+	}
+	return seqs
+}
+
+func (s *chunkRecordingStream) CloseSend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closes++
+	return nil
+}
+
+func waitSender(t *testing.T, s *chunkSender) {
+	t.Helper()
+	select {
+	case <-s.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("chunk sender did not finish")
+	}
+}
+
+func TestChunkSenderSendsRange(t *testing.T) {
+	stream := &chunkRecordingStream{}
+	req := &pb.InferRequest{CorrelationId: "c1", Task: "t", PayloadMime: "text/plain", Meta: map[string]string{"k": "v"}}
+	chunks := [][]byte{[]byte("ab"), []byte("cde"), []byte("f"), []byte("gh")}
+	partMeta := map[string]string{"part": "2"}
+
+	s := newChunkSender(stream, req, chunks, chunkSenderOptions{lo: 1, hi: 3, meta: partMeta})
+	s.Start(context.Background())
+	waitSender(t, s)
+
+	if err := s.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	if stream.closes != 1 {
+		t.Fatalf("CloseSend called %d times, want 1", stream.closes)
+	}
+	if len(stream.sent) != 2 {
+		t.Fatalf("sent %d chunks, want 2", len(stream.sent))
+	}
+	for i, want := range []struct{ seq, offset uint64 }{{1, 2}, {2, 5}} {
+		got := stream.sent[i]
+		if got.Seq != want.seq || got.Offset != want.offset || got.Total != 4 {
+			t.Errorf("chunk %d: seq %d offset %d total %d, want seq %d offset %d total 4", i, got.Seq, got.Offset, got.Total, want.seq, want.offset)
+		}
+		if got.Meta["part"] != "2" || got.CorrelationId != "c1" || got.PayloadMime != "text/plain" {
+			t.Errorf("chunk %d: %+v does not carry the request fields and part meta", i, got)
+		}
+	}
+}
+
+func TestChunkSenderDefaultsToWholePayload(t *testing.T) {
+	stream := &chunkRecordingStream{}
+	req := &pb.InferRequest{CorrelationId: "c1", Meta: map[string]string{"k": "v"}}
+	s := newChunkSender(stream, req, [][]byte{[]byte("a"), []byte("b")}, chunkSenderOptions{})
+	s.Start(context.Background())
+	waitSender(t, s)
+
+	if len(stream.sent) != 2 || stream.sent[1].Meta["k"] != "v" {
+		t.Fatalf("sent %+v, want both chunks with the request meta", stream.sent)
+	}
+}
+
+func TestChunkSenderAbortsOnClientFailure(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		err       error
+		wantAbort bool
+	}{
+		{"send error", errors.New("boom"), true},
+		{"node ended stream", io.EOF, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stream := &chunkRecordingStream{failAt: 1}
+			stream.sendErr = tc.err
+			aborted := false
+			s := newChunkSender(stream, &pb.InferRequest{}, [][]byte{{1}, {2}, {3}}, chunkSenderOptions{
+				abort: func() { aborted = true },
+			})
+			s.Start(context.Background())
+			waitSender(t, s)
+
+			if !errors.Is(s.Err(), tc.err) {
+				t.Fatalf("Err() = %v, want %v", s.Err(), tc.err)
+			}
+			if aborted != tc.wantAbort {
+				t.Fatalf("aborted = %v, want %v", aborted, tc.wantAbort)
+			}
+			if len(stream.sent) != 1 || stream.closes != 1 {
+				t.Fatalf("sent %d chunks and closed %d times, want 1 and 1", len(stream.sent), stream.closes)
+			}
+		})
+	}
+}
+
+func TestChunkSenderStop(t *testing.T) {
+	stream := &chunkRecordingStream{block: make(chan struct{})}
+	aborted := false
+	s := newChunkSender(stream, &pb.InferRequest{}, [][]byte{{1}, {2}, {3}}, chunkSenderOptions{
+		abort: func() { aborted = true },
+	})
+	s.Start(context.Background())
+	if err := s.Err(); err != nil {
+		t.Fatalf("Err() while running = %v, want nil", err)
+	}
+	s.Stop()
+	close(stream.block)
+	waitSender(t, s)
+
+	if !errors.Is(s.Err(), context.Canceled) {
+		t.Fatalf("Err() = %v, want context.Canceled", s.Err())
+	}
+	if len(stream.sent) > 1 {
+		t.Fatalf("sent %d chunks after Stop, want at most the one in flight", len(stream.sent))
+	}
+	if aborted {
+		t.Fatal("Stop aborted the stream")
+	}
+	if stream.closes != 1 {
+		t.Fatalf("CloseSend called %d times, want 1", stream.closes)
+	}
+}
+
+func TestChunkSenderRetriesTransientSendErrors(t *testing.T) {
+	boom := errors.New("boom")
+	for _, tc := range []struct {
+		name      string
+		faults    []error
+		retries   int
+		wantErr   error
+		wantSeqs  []uint64
+		wantRetry int64
+	}{
+		{"recovers", []error{syscall.ENOBUFS, syscall.EAGAIN}, 3, nil, []uint64{0, 1, 2}, 2},
+		{"out of retries", []error{syscall.ENOBUFS, syscall.ENOBUFS, syscall.ENOBUFS}, 2, syscall.ENOBUFS, []uint64{0}, 2},
+		{"not transient", []error{boom}, 3, boom, []uint64{0}, 0},
+		{"retries off", []error{syscall.ENOBUFS}, 0, syscall.ENOBUFS, []uint64{0}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stream := &chunkRecordingStream{faults: map[uint64][]error{1: tc.faults}}
+			counters := &sendRetryCounters{}
+			aborted := false
+			s := newChunkSender(stream, &pb.InferRequest{}, [][]byte{{1}, {2}, {3}}, chunkSenderOptions{
+				abort:    func() { aborted = true },
+				retries:  tc.retries,
+				counters: counters,
+			})
+			s.Start(context.Background())
+			waitSender(t, s)
+
+			if !errors.Is(s.Err(), tc.wantErr) {
+				t.Fatalf("Err() = %v, want %v", s.Err(), tc.wantErr)
+			}
+			if aborted != (tc.wantErr != nil) {
+				t.Fatalf("aborted = %v with error %v", aborted, s.Err())
+			}
+			if got := stream.seqs(); !slices.Equal(got, tc.wantSeqs) {
+				t.Fatalf("sent seqs %v, want %v", got, tc.wantSeqs)
+			}
+			if n := counters.retries.Load(); n != tc.wantRetry {
+				t.Fatalf("counted %d retries, want %d", n, tc.wantRetry)
+			}
+		})
+	}
+}
+
+// TestChunkSenderReopensBrokenStream checks an upload whose stream breaks
+// goes on over a reopened stream from the failed chunk, marking the chunks
+// re-sent there, and that a receiver waiting on the broken stream is handed
+// the new one.
+func TestChunkSenderReopensBrokenStream(t *testing.T) {
+	broken := &chunkRecordingStream{faults: map[uint64][]error{2: {status.Error(codes.Unavailable, "connection reset")}}}
+	next := &chunkRecordingStream{}
+	counters := &sendRetryCounters{}
+	reopens := 0
+	req := &pb.InferRequest{CorrelationId: "c1", Meta: map[string]string{"k": "v"}}
+	s := newChunkSender(broken, req, [][]byte{[]byte("ab"), []byte("c"), []byte("de"), []byte("f")}, chunkSenderOptions{
+		retries:  2,
+		counters: counters,
+		reopen: func() (inferStream, error) {
+			reopens++
+			return next, nil
+		},
+	})
+	s.Start(context.Background())
+	if got := s.Reopened(broken); got != next {
+		t.Fatalf("Reopened(broken) = %v, want the new stream", got)
+	}
+	waitSender(t, s)
+
+	if err := s.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	if reopens != 1 || counters.recreations.Load() != 1 || counters.retries.Load() != 0 {
+		t.Fatalf("reopened %d times, counted %d recreations and %d retries; want 1, 1 and 0",
+			reopens, counters.recreations.Load(), counters.retries.Load())
+	}
+	if got := broken.seqs(); !slices.Equal(got, []uint64{0, 1}) {
+		t.Fatalf("broken stream got seqs %v, want [0 1]", got)
+	}
+	if got := next.seqs(); !slices.Equal(got, []uint64{2, 3}) {
+		t.Fatalf("new stream got seqs %v, want [2 3]", got)
+	}
+	first := next.sent[0]
+	if first.Meta[ResumeFromMetaKey] != "2" || first.Meta["k"] != "v" || first.Offset != 3 || first.CorrelationId != "c1" {
+		t.Fatalf("resumed chunk = %+v, want offset 3 with the request meta and resume_from 2", first)
+	}
+	if req.Meta[ResumeFromMetaKey] != "" {
+		t.Fatal("resume meta leaked into the request")
+	}
+	if s.Stream() != next || next.closes != 1 || broken.closes != 0 {
+		t.Fatalf("CloseSend: broken %d, new %d; want it on the new stream only", broken.closes, next.closes)
+	}
+	if got := s.Reopened(next); got != nil {
+		t.Fatalf("Reopened(new) after the upload = %v, want nil", got)
+	}
+}
+
+func TestChunkSenderWithoutReopenFailsOnBrokenStream(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection reset")
+	stream := &chunkRecordingStream{faults: map[uint64][]error{1: {unavailable}}}
+	s := newChunkSender(stream, &pb.InferRequest{}, [][]byte{{1}, {2}}, chunkSenderOptions{retries: 3})
+	s.Start(context.Background())
+	if got := s.Reopened(stream); got != nil {
+		t.Fatalf("Reopened = %v, want nil without reopen", got)
+	}
+	waitSender(t, s)
+	if !errors.Is(s.Err(), unavailable) {
+		t.Fatalf("Err() = %v, want %v", s.Err(), unavailable)
+	}
+}
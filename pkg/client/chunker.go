@@ -1,11 +1,94 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
 )
 
+// Response Meta keys set by Infer to the number of request chunks sent and
+// the size of each (the payload size when it was not chunked).
+const (
+	ChunksMetaKey     = "lumen.chunks"
+	ChunkBytesMetaKey = "lumen.chunk_bytes"
+)
+
+// ChunkInfo records how Infer split a request payload. Nodes that mishandle
+// chunked input (e.g. ignore Offset) can be told apart by Chunks > 1.
+type ChunkInfo struct {
+	PayloadBytes int `json:"payload_bytes"`
+	// Chunks is the number of messages sent; 1 when not chunked.
+	Chunks int `json:"chunks"`
+	// ChunkBytes is the most a chunk holds: the size of every chunk but
+	// the last when split by bytes, while text profiles may cut chunks
+	// short. The payload size when not chunked.
+	ChunkBytes int `json:"chunk_bytes"`
+	// Threshold is the payload size above which auto-chunking applies; 0
+	// when auto-chunking is disabled.
+	Threshold int `json:"threshold"`
+	// Source is ChunkSourceNode when ChunkBytes is the size the node
+	// advertised it prefers, and empty for chunk.max_chunk_bytes.
+	Source string `json:"source,omitempty"`
+}
+
+// Chunked reports whether the payload was sent in more than one message.
+func (i ChunkInfo) Chunked() bool {
+	return i.Chunks > 1
+}
+
+// PlanChunks returns how ChunkPayload would split a payload of payloadLen
+// bytes under cfg, without touching the payload. A payload split by a text
+// profile (see ChunkPayloadFor) may take a few more chunks than planned.
+func PlanChunks(payloadLen int, cfg config.ChunkConfig) (ChunkInfo, error) {
+	info := ChunkInfo{PayloadBytes: payloadLen, Chunks: 1, ChunkBytes: payloadLen}
+	if !cfg.EnableAuto {
+		return info, nil
+	}
+	if cfg.MaxChunkBytes <= 0 {
+		return ChunkInfo{}, errors.New("invalid MaxChunkBytes")
+	}
+	info.Threshold = cfg.Threshold
+	if payloadLen <= cfg.Threshold || payloadLen <= cfg.MaxChunkBytes {
+		return info, nil
+	}
+	info.Chunks = (payloadLen + cfg.MaxChunkBytes - 1) / cfg.MaxChunkBytes
+	info.ChunkBytes = cfg.MaxChunkBytes
+	return info, nil
+}
+
+// setMeta records info on a response under ChunksMetaKey and
+// ChunkBytesMetaKey, and ChunkBytesSourceMetaKey when set.
+func (i ChunkInfo) setMeta(resp *pb.InferResponse) {
+	if resp.Meta == nil {
+		resp.Meta = make(map[string]string, 2)
+	}
+	resp.Meta[ChunksMetaKey] = strconv.Itoa(i.Chunks)
+	resp.Meta[ChunkBytesMetaKey] = strconv.Itoa(i.ChunkBytes)
+	if i.Source != "" {
+		resp.Meta[ChunkBytesSourceMetaKey] = i.Source
+	}
+}
+
+type chunkInfoKey struct{}
+
+// withChunkInfoSlot asks dispatchInfer to report its chunking decision into
+// slot; InferDetailed uses it to see through the middleware chain.
+func withChunkInfoSlot(ctx context.Context, slot *ChunkInfo) context.Context {
+	return context.WithValue(ctx, chunkInfoKey{}, slot)
+}
+
+func chunkInfoSlot(ctx context.Context) *ChunkInfo {
+	slot, _ := ctx.Value(chunkInfoKey{}).(*ChunkInfo)
+	return slot
+}
+
 // ChunkPayload splits a large payload into smaller chunks based on configuration.
 //
 // This function enables efficient transmission of large data (images, videos, documents)
@@ -50,6 +133,16 @@ import (
 //	fmt.Printf("Split into %d chunks\n", len(chunks))
 //	// Output: Split into 20 chunks
 func ChunkPayload(payload []byte, cfg config.ChunkConfig) ([][]byte, error) {
+	return ChunkPayloadFor(payload, "", cfg)
+}
+
+// ChunkPayloadFor is ChunkPayload for a payload of the given MIME type,
+// split as the longest matching prefix in cfg.Profiles says: text on
+// character or whitespace boundaries, JSON by bytes with a check that the
+// chunks reassemble to the payload, anything else by bytes. A cut never
+// leaves a chunk larger than MaxChunkBytes; a character that cannot fit is
+// split by bytes. No chunk is empty unless the payload is.
+func ChunkPayloadFor(payload []byte, mime string, cfg config.ChunkConfig) ([][]byte, error) {
 	if !cfg.EnableAuto {
 		return [][]byte{payload}, nil
 	}
@@ -59,14 +152,82 @@ func ChunkPayload(payload []byte, cfg config.ChunkConfig) ([][]byte, error) {
 	if len(payload) <= cfg.Threshold {
 		return [][]byte{payload}, nil
 	}
+
+	mode := chunkSplitMode(cfg.Profiles, mime)
+	var cut cutFunc = cutBytes
+	switch mode {
+	case config.ChunkSplitRunes:
+		cut = cutRunes
+	case config.ChunkSplitWhitespace:
+		cut = cutWhitespace
+	}
 	var chunks [][]byte
-	for off := 0; off < len(payload); off += cfg.MaxChunkBytes {
-		end := off + cfg.MaxChunkBytes
-		if end > len(payload) {
-			end = len(payload)
+	for off := 0; off < len(payload); {
+		end := min(off+cfg.MaxChunkBytes, len(payload))
+		if end < len(payload) {
+			end = cut(payload, off, end)
 		}
 		// 注意：为了减少内存复制，你可以使用 payload[off:end] 的切片（要注意生命周期）
 		chunks = append(chunks, payload[off:end])
+		off = end
+	}
+	if mode == config.ChunkSplitJSON && !reassemblesTo(chunks, payload) {
+		return nil, fmt.Errorf("chunked %s payload does not reassemble to the original", mime)
 	}
 	return chunks, nil
 }
+
+// chunkSplitMode returns the split mode of the longest prefix of mime in
+// profiles, ignoring case, or ChunkSplitBytes.
+func chunkSplitMode(profiles map[string]string, mime string) string {
+	mime = strings.ToLower(strings.TrimSpace(mime))
+	mode, longest := config.ChunkSplitBytes, -1
+	for prefix, m := range profiles {
+		if len(prefix) > longest && strings.HasPrefix(mime, strings.ToLower(prefix)) {
+			mode, longest = m, len(prefix)
+		}
+	}
+	return mode
+}
+
+// A cut func returns where the chunk starting at off ends, given the
+// furthest it may end; the result is in (off, end].
+type cutFunc func(payload []byte, off, end int) int
+
+func cutBytes(_ []byte, _, end int) int { return end }
+
+// cutRunes backs end up to the start of the character it falls in.
+func cutRunes(payload []byte, off, end int) int {
+	for i := end; i > off && end-i < utf8.UTFMax; i-- {
+		if utf8.RuneStart(payload[i]) {
+			return i
+		}
+	}
+	return end
+}
+
+// cutWhitespace ends the chunk after the last newline in its final quarter,
+// or else the last space or tab there, or else on a character boundary.
+func cutWhitespace(payload []byte, off, end int) int {
+	lo := max(off, end-max((end-off)/4, 1))
+	window := payload[lo:end]
+	if i := bytes.LastIndexByte(window, '\n'); i >= 0 {
+		return lo + i + 1
+	}
+	if i := bytes.LastIndexAny(window, " \t\r"); i >= 0 {
+		return lo + i + 1
+	}
+	return cutRunes(payload, off, end)
+}
+
+// reassemblesTo reports whether chunks concatenate to payload.
+func reassemblesTo(chunks [][]byte, payload []byte) bool {
+	off := 0
+	for _, chunk := range chunks {
+		if off+len(chunk) > len(payload) || !bytes.Equal(chunk, payload[off:off+len(chunk)]) {
+			return false
+		}
+		off += len(chunk)
+	}
+	return off == len(payload)
+}
@@ -12,10 +12,14 @@ import (
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/config"
 	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/tasks"
 	sdktypes "github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"github.com/edwinzhancn/lumen-sdk/pkg/version"
 	pb "github.com/edwinzhancn/lumen-sdk/proto"
 
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -61,14 +65,52 @@ func shouldAffectNodeHealth(ctx context.Context, err error) bool {
 
 // ClientMetrics is a lightweight metrics snapshot for monitoring.
 type ClientMetrics struct {
-	TotalNodes      int       `json:"total_nodes"`
-	ActiveNodes     int       `json:"active_nodes"`
-	TotalRequests   int64     `json:"total_requests"`
-	SuccessRequests int64     `json:"success_requests"`
-	FailedRequests  int64     `json:"failed_requests"`
-	AverageLatency  int64     `json:"average_latency_ns"`
-	ErrorRate       float64   `json:"error_rate"`
-	LastUpdated     time.Time `json:"last_updated"`
+	TotalNodes      int   `json:"total_nodes"`
+	ActiveNodes     int   `json:"active_nodes"`
+	TotalRequests   int64 `json:"total_requests"`
+	SuccessRequests int64 `json:"success_requests"`
+	// FailedRequests and ErrorRate leave out requests the caller
+	// cancelled, which CancelledRequests counts instead. TotalRequests
+	// includes requests still in flight, so the three outcomes add up to
+	// at most TotalRequests and ErrorRate is within [0, 1].
+	FailedRequests    int64 `json:"failed_requests"`
+	CancelledRequests int64 `json:"cancelled_requests"`
+	// AverageLatency is the mean latency of successful requests.
+	AverageLatency int64     `json:"average_latency_ns"`
+	ErrorRate      float64   `json:"error_rate"`
+	LastUpdated    time.Time `json:"last_updated"`
+
+	// Failures breaks failed and cancelled requests down by FailureClass;
+	// TaskFailures and NodeFailures break it down per task and per node
+	// picked. A request that never reached a node has no node entry.
+	Failures     FailureCounts            `json:"failures"`
+	TaskFailures map[string]FailureCounts `json:"task_failures,omitempty"`
+	NodeFailures map[string]FailureCounts `json:"node_failures,omitempty"`
+
+	// Latency summarises successful request latencies; TaskLatency and
+	// NodeLatency break it down per task and per serving node. The window
+	// is controlled by Metrics.LatencyWindow.
+	Latency     LatencyStats            `json:"latency"`
+	TaskLatency map[string]LatencyStats `json:"task_latency,omitempty"`
+	NodeLatency map[string]LatencyStats `json:"node_latency,omitempty"`
+
+	// Usage is each tenant's usage since start; see WithTenant and Usage
+	// for windowed figures.
+	Usage map[string]TenantUsage `json:"usage,omitempty"`
+
+	// LocalFallbacks counts requests handed to a local handler because no
+	// node could serve them; they are also counted in TotalRequests.
+	LocalFallbacks int64 `json:"local_fallbacks"`
+
+	// ChunkRetries counts the chunk Sends retried after a transient error,
+	// and StreamRecreations the upload streams reopened after one broke;
+	// see chunk.send_retries.
+	ChunkRetries      int64 `json:"chunk_retries"`
+	StreamRecreations int64 `json:"stream_recreations"`
+
+	// Queue describes the durable queue, without its dead letters; nil
+	// when the queue is disabled.
+	Queue *discovery.QueueStatus `json:"queue,omitempty"`
 }
 
 // LumenClient provides inference access to ML nodes.
@@ -79,22 +121,88 @@ type ClientMetrics struct {
 type LumenClient struct {
 	pool     *Pool
 	resolver discovery.NodeResolver
-	config   *config.Config
-	logger   *zap.Logger
+	// push is the backend of self-registered nodes; nil unless
+	// discovery.push is enabled.
+	push *discovery.PushResolver
+	// nodeFilter and pushFilter hold discovery.allow_nodes/deny_nodes and
+	// discovery.push.allow_nodes; SetNodeFilters replaces their patterns.
+	nodeFilter *discovery.NodeFilter
+	pushFilter *discovery.NodeFilter
+	config     *config.Config
+	logger     *zap.Logger
+
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	startup *StartupReport
+
+	mwMu     sync.RWMutex
+	inferMW  []InferMiddleware
+	streamMW []StreamMiddleware
+
+	// counters feed GetMetrics, and taskFailures breaks their failures
+	// down per task.
+	counters     requestCounters
+	taskFailures *failureSet
+
+	latency     *latencyTracker
+	taskLatency *latencySet
+	usage       *usageTracker
+	// taskState collects per-task state of tasks nobody uses any more.
+	taskState *TaskStateRegistry
+
+	localMu        sync.RWMutex
+	localHandlers  map[string]InferFunc
+	localFallbacks atomic.Int64
+	// sendRetries counts the chunk Sends retried and the upload streams
+	// reopened.
+	sendRetries sendRetryCounters
+
+	// jobs remembers the node running each job; see jobTracker.
+	jobsOnce sync.Once
+	jobs     *jobTracker
+
+	// queue holds requests added with EnqueueInfer; nil unless queue.dir
+	// is set. queueDone is closed when its dispatcher, started by Start,
+	// has stopped.
+	queue     *durableQueue
+	queueDone chan struct{}
+	stopQueue context.CancelFunc
+
+	// requests counts Infer calls in flight so Close can drain them.
+	requests  requestGate
+	closeOnce sync.Once
+	closeErr  error
+}
 
-	cancel context.CancelFunc
-	mu     sync.Mutex
+// Client is the public surface of LumenClient. Application code that depends
+// on Client rather than *LumenClient can substitute a test double such as
+// clientmock.Client.
+type Client interface {
+	Start(ctx context.Context) error
+	Close() error
 
-	totalReqs      atomic.Int64
-	successReqs    atomic.Int64
-	failedReqs     atomic.Int64
-	totalLatencyNs atomic.Int64
+	Infer(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error)
+	InferStream(ctx context.Context, req *pb.InferRequest) (<-chan *pb.InferResponse, error)
+	Use(mw ...InferMiddleware)
+	UseStream(mw ...StreamMiddleware)
+
+	GetConfig() *config.Config
+	GetNodes() []*discovery.NodeInfo
+	FindTaskContract(taskName string) (sdktypes.TaskContract, string, bool)
+	GetMetrics() *ClientMetrics
+	PoolStats() PoolStats
+	DiscoveryStats() DiscoveryStats
+	SystemStats() SystemStats
+	WatchNodes(cb func([]*discovery.NodeInfo)) (unsubscribe func())
 }
 
+var _ Client = (*LumenClient)(nil)
+
 // NewLumenClient creates a new LumenClient.
 //
 // Discovery backends are additive: every configured backend (mDNS when
-// MDNSEnabled, Broker push when BrokerURL is set, StaticNodes when non-empty) runs concurrently and their node events are
+// MDNSEnabled, Broker push when BrokerURL is set, StaticNodes when non-empty,
+// self-registered nodes when Push is enabled) runs concurrently and their node events are
 // merged. A node reachable through more than one backend appears once per
 // backend identity; the pool tolerates the redundant connection.
 func NewLumenClient(cfg *config.Config, logger *zap.Logger) (*LumenClient, error) {
@@ -106,15 +214,52 @@ func NewLumenClient(cfg *config.Config, logger *zap.Logger) (*LumenClient, error
 	}
 
 	pool := NewPoolWithOptions(logger, PoolOptions{
-		ConnectTimeout:        cfg.Discovery.ConnectTimeout,
-		RediscoveryBackoffMin: cfg.Discovery.RediscoveryBackoffMin,
-		RediscoveryBackoffMax: cfg.Discovery.RediscoveryBackoffMax,
+		ConnectTimeout:               cfg.Discovery.ConnectTimeout,
+		RediscoveryBackoffMin:        cfg.Discovery.RediscoveryBackoffMin,
+		RediscoveryBackoffMax:        cfg.Discovery.RediscoveryBackoffMax,
+		CapabilityFetchTimeout:       cfg.Discovery.ProbeTimeout,
+		MaxConcurrentProbes:          cfg.Discovery.MaxConcurrentProbes,
+		RequireConfirmedCapabilities: !cfg.Discovery.ProvisionalTraffic,
+		LatencyWindow:                cfg.Metrics.LatencyWindow,
+		MaxConnections:               cfg.Pool.MaxConnections,
+		MaxIdleTime:                  cfg.Pool.MaxIdleTime,
+		MaxLifetime:                  cfg.Pool.MaxLifetime,
+		HealthCheckInterval:          healthCheckInterval(cfg.Pool),
+		StreamWarnThreshold:          cfg.Pool.StreamWarnThreshold,
+		StreamMaxAge:                 cfg.Pool.StreamMaxAge,
+		Strategy:                     cfg.Pool.Strategy,
+		ScoreWeights:                 cfg.Pool.ScoreWeights,
+		RandomTieBreak:               cfg.Pool.RandomTieBreak,
+		KeepAlive:                    keepAliveInterval(cfg.Pool),
+		KeepAliveTimeout:             cfg.Pool.KeepAliveTimeout,
+		MaxMessageSize:               cfg.Pool.MaxMessageSize,
+		Compression:                  cfg.Pool.Compression,
+		NotifyWindow:                 notifyWindow(cfg.Discovery),
+		TLS:                          cfg.Pool.TLS,
+		PerNode:                      cfg.Pool.PerNode,
+		Outlier:                      cfg.Pool.Outlier,
+		Hysteresis:                   cfg.Pool.Hysteresis,
+		MaxCatalogAge:                cfg.Discovery.MaxCatalogAge,
+		FailOnStaleCatalog:           cfg.Discovery.FailOnStaleCatalog,
+		CallbackPanicLimit:           cfg.Pool.CallbackPanicLimit,
 	})
 
+	nodeFilter, err := discovery.NewNodeFilter(cfg.Discovery.AllowNodes, cfg.Discovery.DenyNodes)
+	if err != nil {
+		return nil, fmt.Errorf("discovery node filter: %w", err)
+	}
+	pushFilter, err := discovery.NewNodeFilter(cfg.Discovery.Push.AllowNodes, nil)
+	if err != nil {
+		return nil, fmt.Errorf("discovery push filter: %w", err)
+	}
+
 	var resolvers []discovery.NodeResolver
+	var push *discovery.PushResolver
 	if cfg.Discovery.Enabled {
 		if cfg.Discovery.MDNSEnabled {
-			resolvers = append(resolvers, discovery.NewMDNSResolver(&cfg.Discovery, logger))
+			mdns := discovery.NewMDNSResolver(&cfg.Discovery, logger)
+			mdns.SetFilter(nodeFilter)
+			resolvers = append(resolvers, mdns)
 		}
 		if brokerURL := cfg.Discovery.EffectiveBrokerURL(); brokerURL != "" {
 			resolvers = append(resolvers, discovery.NewBrokerResolverWithDeployment(brokerURL, cfg.Discovery.DeploymentID, logger))
@@ -122,33 +267,134 @@ func NewLumenClient(cfg *config.Config, logger *zap.Logger) (*LumenClient, error
 		if len(cfg.Discovery.StaticNodes) > 0 {
 			resolvers = append(resolvers, discovery.NewStaticResolver(cfg.Discovery.StaticNodes, cfg.Discovery.DeploymentID, logger))
 		}
+		if cfg.Discovery.Push.Enabled {
+			push = discovery.NewPushResolver(cfg.Discovery.DeploymentID, cfg.Discovery.Push.HeartbeatTimeout, logger)
+			push.SetFilter(pushFilter)
+			resolvers = append(resolvers, push)
+		}
 	}
 	if len(resolvers) == 0 {
-		return nil, fmt.Errorf("no discovery backend configured: enable mDNS, set broker_url, or list static_nodes")
+		return nil, fmt.Errorf("no discovery backend configured: enable mDNS, set broker_url, list static_nodes or enable push")
 	}
-	resolver := discovery.NewCompositeResolver(resolvers...)
+	resolver := discovery.NewCompositeResolverWithLogger(logger, resolvers...)
 
-	return &LumenClient{
-		pool:     pool,
-		resolver: resolver,
-		config:   cfg,
-		logger:   logger,
-	}, nil
+	c := &LumenClient{
+		pool:         pool,
+		resolver:     resolver,
+		push:         push,
+		nodeFilter:   nodeFilter,
+		pushFilter:   pushFilter,
+		config:       cfg,
+		logger:       logger,
+		latency:      newLatencyTracker(cfg.Metrics.LatencyWindow),
+		taskLatency:  newLatencySet(cfg.Metrics.LatencyWindow),
+		taskFailures: &failureSet{},
+		usage:        newUsageTracker(cfg.Usage, logger),
+		taskState:    NewTaskStateRegistry(cfg.TaskState.Retention, pool.servedTasks, logger),
+	}
+	c.taskState.Register("task_latency", c.taskLatency.keys, c.taskLatency.forget)
+	c.taskState.Register("task_failures", c.taskFailures.keys, c.taskFailures.forget)
+	c.taskState.Register("selection", pool.selectionTasks, pool.forgetTask)
+
+	if cfg.Queue.Dir != "" {
+		cipher, err := utils.LoadPayloadCipher(cfg.PayloadProtection)
+		if err != nil {
+			return nil, fmt.Errorf("payload protection: %w", err)
+		}
+		if c.queue, err = openQueue(cfg.Queue, cipher, logger); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// TaskState returns the registry that collects per-task state of tasks no
+// node serves and nobody has used for task_state.retention. Applications
+// keeping their own per-task state can Register it to share the lifecycle.
+func (c *LumenClient) TaskState() *TaskStateRegistry {
+	return c.taskState
+}
+
+func healthCheckInterval(cfg config.PoolConfig) time.Duration {
+	if !cfg.HealthCheck {
+		return 0
+	}
+	return cfg.HealthInterval
+}
+
+// notifyWindow maps the config's "0 delivers every change" onto
+// PoolOptions, where zero selects the default.
+func notifyWindow(cfg config.DiscoveryConfig) time.Duration {
+	if cfg.NotifyWindow <= 0 {
+		return -1
+	}
+	return cfg.NotifyWindow
+}
+
+// keepAliveInterval maps the config's "0 disables" onto PoolOptions, where
+// zero selects the default.
+func keepAliveInterval(cfg config.PoolConfig) time.Duration {
+	if cfg.KeepAlive <= 0 {
+		return -1
+	}
+	return cfg.KeepAlive
 }
 
 // Start begins node discovery and connection management.
 // It blocks until at least one node has reported its capabilities,
 // or until ctx is cancelled / the connect timeout elapses.
+//
+// A discovery backend that fails to start, such as mDNS on a host without
+// a multicast route, is logged and retried in the background while the
+// other backends run; DiscoveryStatus reports discovery as degraded until
+// it starts. Start fails only when no backend starts, e.g. when mDNS is
+// the only one configured, with the backend's error (ErrCodeDiscoveryFailed
+// for mDNS). That failure is not fatal when local handlers can serve
+// requests without a node (fallback.enabled with RegisterLocalHandler
+// called before Start); the client then starts degraded.
+//
+// Start logs how each subsystem came up; GetStartupReport returns it.
 func (c *LumenClient) Start(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	_, c.cancel = context.WithCancel(ctx)
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c.cancel = cancel
+	if interval := c.config.TaskState.GCInterval; interval > 0 && c.taskState != nil {
+		go c.taskState.run(runCtx, interval)
+	}
+	if c.queue != nil {
+		c.pool.OnNodesChanged(func([]*discovery.NodeInfo) { c.queue.notify() })
+		queueCtx, stopQueue := context.WithCancel(runCtx)
+		c.stopQueue = stopQueue
+		c.queueDone = make(chan struct{})
+		go func() {
+			defer close(c.queueDone)
+			c.queue.run(queueCtx, c.Infer, c.canServe)
+		}()
+	}
 
+	report := newStartupReport()
+	err := c.start(ctx, report)
+	c.startup = report.finish(err)
+	switch c.startup.Status {
+	case discovery.StartupFailed:
+		c.logger.Error("lumen client failed to start", zap.Object("startup", c.startup), zap.Error(err))
+	case discovery.StartupDegraded:
+		c.logger.Warn("lumen client started degraded", zap.Object("startup", c.startup))
+	default:
+		c.logger.Info("lumen client started", zap.Object("startup", c.startup))
+	}
+	return err
+}
+
+func (c *LumenClient) start(ctx context.Context, report startupReport) error {
 	ready := make(chan struct{}, 1)
 	c.pool.OnNodesChanged(func(nodes []*discovery.NodeInfo) {
 		for _, n := range nodes {
-			if n.IsActive() && len(n.Tasks) > 0 {
+			// A provisional node counts: its hinted tasks are enough to
+			// call the client started, whether or not it takes traffic yet.
+			if (n.IsActive() || n.IsProvisional()) && len(n.Tasks) > 0 {
 				select {
 				case ready <- struct{}{}:
 				default:
@@ -159,8 +405,10 @@ func (c *LumenClient) Start(ctx context.Context) error {
 	})
 
 	if err := c.pool.Connect(c.resolver); err != nil {
+		report.set(ComponentPool, discovery.StartupFailed, err)
 		return fmt.Errorf("pool connect: %w", err)
 	}
+	report.set(ComponentPool, discovery.StartupOK, nil)
 
 	timeout := c.config.Discovery.ConnectTimeout
 	if timeout <= 0 {
@@ -168,13 +416,27 @@ func (c *LumenClient) Start(ctx context.Context) error {
 	}
 	select {
 	case <-ready:
+		report.set(ComponentNodes, discovery.StartupOK, nil)
+	case err := <-c.pool.discoveryFailed():
+		report.set(ComponentDiscovery, discovery.StartupFailed, err)
+		if c.hasLocalHandlers() {
+			report.set(ComponentNodes, discovery.StartupDegraded, errors.New("serving local handlers only"))
+			return nil
+		}
+		return err
 	case <-ctx.Done():
+		report.set(ComponentNodes, discovery.StartupFailed, ctx.Err())
 		return ctx.Err()
 	case <-time.After(timeout):
-		c.logger.Warn("timed out waiting for node capabilities, continuing anyway")
+		report.set(ComponentNodes, discovery.StartupDegraded,
+			fmt.Errorf("no node reported capabilities within %s", timeout))
 	}
 
-	c.logger.Info("lumen client started")
+	if status := c.pool.DiscoveryStatus(); status.Degraded {
+		report.set(ComponentDiscovery, discovery.StartupDegraded, discoveryStatusError(status))
+	} else {
+		report.set(ComponentDiscovery, discovery.StartupOK, nil)
+	}
 	return nil
 }
 
@@ -191,75 +453,214 @@ func (c *LumenClient) Infer(ctx context.Context, req *pb.InferRequest) (*pb.Infe
 		return nil, err
 	}
 
+	if !c.requests.enter() {
+		return nil, ErrClientClosed
+	}
+	defer c.requests.leave()
+
 	c.resolveService(req)
+	tagClientVersion(req)
+	tagProjection(ctx, req)
 
+	return c.inferChain()(c.withRequestLogger(ctx, req), req)
+}
+
+// InferResult is the response of InferDetailed together with how the
+// request was sent.
+type InferResult struct {
+	Response *pb.InferResponse
+	// Chunking is how the request payload was split; zero if the request
+	// failed before reaching the node (e.g. rejected by a middleware).
+	Chunking ChunkInfo
+	// Timings is where the time of the call went.
+	Timings PhaseTimings
+	// Node is the node the request was routed to; empty when it failed
+	// before a node was picked or a local handler served it.
+	Node string
+	// Timeout is the timeout derived from the node's advertised p50 for
+	// the task, given to a call without a deadline of its own; zero when
+	// none was derived. See config.CostHintsConfig.
+	Timeout time.Duration
+}
+
+// InferDetailed is Infer that also reports how the request payload was
+// chunked and how long each phase of the request took. The chunking is
+// recorded on the response Meta under ChunksMetaKey and ChunkBytesMetaKey
+// too. A failed call still returns the result, without a Response, and a
+// call whose deadline expires fails with a *DeadlineError naming the phase.
+func (c *LumenClient) InferDetailed(ctx context.Context, req *pb.InferRequest) (*InferResult, error) {
+	result := &InferResult{}
+	timer := &phaseTimer{}
 	start := time.Now()
-	c.totalReqs.Add(1)
+	resp, err := c.Infer(withPhaseTimer(withChunkInfoSlot(ctx, &result.Chunking), timer), req)
+	now := time.Now()
+	var phase string
+	result.Timings, phase = timer.timings(now)
+	result.Timings.Total = now.Sub(start)
+	result.Node = timer.pickedNode()
+	result.Timeout = timer.derivedTimeout()
+	if err != nil {
+		if deadlineExpired(ctx, err) {
+			derr := &DeadlineError{Phase: phase, Timings: result.Timings, Err: err}
+			if deadline, ok := ctx.Deadline(); ok {
+				derr.Limit = deadline.Sub(start)
+			} else {
+				derr.Limit = result.Timeout
+			}
+			if derr.Phase == "" {
+				// Expired before dispatch, e.g. in a middleware.
+				derr.Phase = PhaseSelection
+			}
+			err = derr
+		}
+		return result, err
+	}
+	result.Response = resp
+	return result, nil
+}
+
+// PreviewChunking returns how many messages Infer would send for a payload
+// of payloadLen bytes and the size of each (the payload size when it is not
+// chunked). It returns 0, 0 if the chunk configuration is invalid, in which
+// case Infer fails too.
+func (c *LumenClient) PreviewChunking(payloadLen int) (chunks int, chunkBytes int) {
+	info, err := PlanChunks(payloadLen, c.config.Chunk)
+	if err != nil {
+		return 0, 0
+	}
+	return info.Chunks, info.ChunkBytes
+}
 
-	chunks, err := ChunkPayload(req.Payload, c.config.Chunk)
+// dispatchRemote sends req to a node: it chunks the payload and runs either
+// the single-message or the multi-chunk stream exchange.
+func (c *LumenClient) dispatchRemote(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+	info, err := PlanChunks(len(req.Payload), c.config.Chunk)
 	if err != nil {
-		c.failedReqs.Add(1)
 		return nil, fmt.Errorf("chunk payload: %w", err)
 	}
+	var chunks [][]byte
+	if info.Chunked() {
+		chunks, err = ChunkPayloadFor(req.Payload, req.PayloadMime, c.config.Chunk)
+		if err != nil {
+			return nil, fmt.Errorf("chunk payload: %w", err)
+		}
+		// Text profiles may need more chunks than planned.
+		info.Chunks = len(chunks)
+	}
+	if slot := chunkInfoSlot(ctx); slot != nil {
+		*slot = info
+	}
+	utils.LoggerFrom(ctx).Debug("payload chunking",
+		zap.Int("payload_bytes", info.PayloadBytes),
+		zap.Int("chunks", info.Chunks),
+		zap.Int("chunk_bytes", info.ChunkBytes),
+		zap.Int("threshold", info.Threshold),
+	)
 
 	cli := c.pool.Client()
 	if cli == nil {
-		c.failedReqs.Add(1)
 		return nil, ErrNoAvailableNode
 	}
+	if err := c.pool.staleCatalogError(req.Task); err != nil {
+		return nil, err
+	}
+	if err := checkStrategy(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.checkMinHealthy(ctx, req.Task); err != nil {
+		return nil, err
+	}
 
-	ctx = WithTask(ctx, req.Task)
+	ctx = withSelectionHints(WithTask(ctx, req.Task), req)
+	timer := phaseTimerFrom(ctx)
+	timer.markStart()
+	defer timer.markDone()
 
-	if len(chunks) == 1 {
+	if !info.Chunked() {
 		resp, err := c.inferSingle(ctx, cli, req)
 		if err != nil {
-			c.failedReqs.Add(1)
 			return nil, err
 		}
-		c.successReqs.Add(1)
-		c.totalLatencyNs.Add(time.Since(start).Nanoseconds())
+		info.setMeta(resp)
 		return resp, nil
 	}
 
-	stream, err := cli.Infer(ctx)
+	finalResp, err := c.inferChunked(ctx, cli, req, chunks, &info, true)
+	if err != nil {
+		return nil, err
+	}
+	info.setMeta(finalResp)
+	return finalResp, nil
+}
+
+// inferChunked uploads chunks on one stream and assembles the responses.
+// With parallel set, a payload the node can take over several streams is
+// handed to inferParallel instead, falling back to one stream once if that
+// fails.
+func (c *LumenClient) inferChunked(ctx context.Context, cli pb.InferenceClient, req *pb.InferRequest, chunks [][]byte, info *ChunkInfo, parallel bool) (finalResp *pb.InferResponse, err error) {
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+
+	var node pickedNode
+	stream, err := cli.Infer(withPickedNode(streamCtx, &node))
 	if err != nil {
-		c.failedReqs.Add(1)
 		return nil, fmt.Errorf("infer stream: %w", err)
 	}
+	phaseTimerFrom(ctx).markOpened()
+	tagResolvedTask(req, node.resolvedTask())
+	ctx = utils.WithRequestLogger(ctx, nil, zap.String("node_id", node.get()))
 
-	sendCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	converted, err := c.matchNodeMIME(ctx, req, node.get())
+	if err != nil {
+		return nil, err
+	}
+	if converted {
+		if chunks, err = c.rechunkConverted(ctx, req, info); err != nil {
+			return nil, err
+		}
+	}
+	costs := c.nodeCosts(node.get(), req.Task)
+	chunks = c.rechunkForNode(ctx, req, chunks, info, costs)
+	timeout := c.startDerivedTimeout(ctx, cancelStream, costs)
+	defer func() {
+		timeout.stop()
+		if err = timeout.err(err); err == nil {
+			timeout.setMeta(finalResp)
+			setMIMEConvertedMeta(req, finalResp)
+		}
+	}()
 
-	sendErrCh := make(chan error, 1)
-	go func() {
-		defer func() { _ = stream.CloseSend() }()
-		var offset uint64
-		total := uint64(len(chunks))
-		for i, chunk := range chunks {
-			select {
-			case <-sendCtx.Done():
-				sendErrCh <- sendCtx.Err()
-				return
-			default:
+	if parallel {
+		if parts := c.parallelParts(node.get(), req, len(chunks)); parts > 1 {
+			resp, err := inferParallel(streamCtx, cancelStream, cli, stream, node.get(), req, chunks, parts, c.senderOptions(nil), c.pool.callbacks)
+			if err == nil || ctx.Err() != nil || timeout.expired() {
+				return resp, err
 			}
-			sendReq := &pb.InferRequest{
-				CorrelationId: req.CorrelationId,
-				Task:          req.Task,
-				Payload:       chunk,
-				PayloadMime:   req.PayloadMime,
-				Seq:           uint64(i),
-				Total:         total,
-				Offset:        offset,
-				Meta:          req.Meta,
-			}
-			if err := stream.Send(sendReq); err != nil {
-				sendErrCh <- err
-				cancel()
-				return
-			}
-			offset += uint64(len(chunk))
+			utils.LoggerFrom(ctx).Warn("parallel upload failed; retrying on one stream",
+				zap.Int("streams", parts),
+				zap.Error(err),
+			)
+			cancelStream()
+			return c.inferChunked(ctx, cli, req, chunks, info, false)
+		}
+	}
+
+	// Every receiver exit cancels the stream context, which unblocks a
+	// pending Send, and then waits for the sender so no goroutine outlives
+	// the call. A node that resumes uploads gets the rest of the payload
+	// on a new stream when this one breaks; the response then comes on
+	// that stream.
+	opts := c.senderOptions(cancelStream)
+	if key := node.get(); c.pool.nodeSupportsFeature(key, discovery.FeatureResume) {
+		opts.reopen = func() (inferStream, error) {
+			return cli.Infer(withPinnedNode(streamCtx, key))
 		}
-		sendErrCh <- nil
+	}
+	sender := newChunkSender(stream, req, chunks, opts)
+	sender.Start(streamCtx)
+	defer func() {
+		cancelStream()
+		<-sender.Done()
 	}()
 
 	var responses []*pb.InferResponse
@@ -269,38 +670,72 @@ func (c *LumenClient) Infer(ctx context.Context, req *pb.InferRequest) (*pb.Infe
 			if err == io.EOF && len(responses) > 0 {
 				break
 			}
-			c.failedReqs.Add(1)
-			select {
-			case se := <-sendErrCh:
-				if se != nil {
-					return nil, fmt.Errorf("send failed: %w", se)
-				}
-			default:
+			if next := sender.Reopened(stream); next != nil {
+				stream, responses = next, nil
+				continue
+			}
+			if sendErr := sender.Err(); sendErr != nil {
+				return nil, fmt.Errorf("send failed: %w", sendErr)
 			}
 			return nil, fmt.Errorf("recv: %w", err)
 		}
+		if !resp.IsFinal && reportProgress(ctx, c.pool.callbacks, resp) {
+			continue
+		}
 		responses = append(responses, resp)
 		if resp.IsFinal {
+			// The node is done with the request; stop uploading.
+			sender.Stop()
+			drainStream(stream, cancelStream)
 			break
 		}
 	}
 
-	finalResp, err := sdktypes.AssembleInferResponses(responses)
+	finalResp, err = sdktypes.AssembleInferResponses(responses)
 	if err != nil {
-		c.failedReqs.Add(1)
 		return nil, fmt.Errorf("assemble response: %w", err)
 	}
-
-	c.successReqs.Add(1)
-	c.totalLatencyNs.Add(time.Since(start).Nanoseconds())
 	return finalResp, nil
 }
 
-func (c *LumenClient) inferSingle(ctx context.Context, cli pb.InferenceClient, req *pb.InferRequest) (*pb.InferResponse, error) {
-	stream, err := cli.Infer(ctx)
+// streamDrainGrace bounds how long a node may keep a stream open after
+// sending its final frame.
+const streamDrainGrace = 2 * time.Second
+
+// drainStream reads the trailing status after a final frame. gRPC only
+// completes an RPC (releasing the stream and running the balancer's Done
+// callback) once Recv returns an error or the context is cancelled; nodes
+// close the stream right after the final frame, and one that does not is cut
+// off after streamDrainGrace.
+func drainStream(stream grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse], cancel context.CancelFunc) {
+	timer := time.AfterFunc(streamDrainGrace, cancel)
+	defer timer.Stop()
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *LumenClient) inferSingle(ctx context.Context, cli pb.InferenceClient, req *pb.InferRequest) (_ *pb.InferResponse, err error) {
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+
+	var node pickedNode
+	stream, err := cli.Infer(withPickedNode(streamCtx, &node))
 	if err != nil {
 		return nil, fmt.Errorf("infer stream: %w", err)
 	}
+	phaseTimerFrom(ctx).markOpened()
+	tagResolvedTask(req, node.resolvedTask())
+	if _, err := c.matchNodeMIME(ctx, req, node.get()); err != nil {
+		return nil, err
+	}
+	timeout := c.startDerivedTimeout(ctx, cancelStream, c.nodeCosts(node.get(), req.Task))
+	defer func() {
+		timeout.stop()
+		err = timeout.err(err)
+	}()
 
 	if err := stream.Send(req); err != nil {
 		return nil, fmt.Errorf("send: %w", err)
@@ -318,16 +753,28 @@ func (c *LumenClient) inferSingle(ctx context.Context, cli pb.InferenceClient, r
 		if err != nil {
 			return nil, fmt.Errorf("recv: %w", err)
 		}
+		if !resp.IsFinal && reportProgress(ctx, c.pool.callbacks, resp) {
+			continue
+		}
 		responses = append(responses, resp)
 		if resp.IsFinal {
+			drainStream(stream, cancelStream)
 			break
 		}
 	}
 
-	return sdktypes.AssembleInferResponses(responses)
+	resp, err := sdktypes.AssembleInferResponses(responses)
+	if err != nil {
+		return nil, err
+	}
+	timeout.setMeta(resp)
+	setMIMEConvertedMeta(req, resp)
+	return resp, nil
 }
 
-// InferStream performs a streaming inference request.
+// InferStream performs a streaming inference request. The returned channel
+// holds 100 frames and blocks the stream when full; use WithStreamOptions
+// on ctx to change the capacity or the overflow policy.
 func (c *LumenClient) InferStream(ctx context.Context, req *pb.InferRequest) (<-chan *pb.InferResponse, error) {
 	if req == nil {
 		return nil, fmt.Errorf("request cannot be nil")
@@ -335,45 +782,129 @@ func (c *LumenClient) InferStream(ctx context.Context, req *pb.InferRequest) (<-
 	if err := sdktypes.ValidateTaskRequest(req); err != nil {
 		return nil, err
 	}
+	if !c.requests.enter() {
+		return nil, ErrClientClosed
+	}
+	defer c.requests.leave()
+
+	tagClientVersion(req)
+	tagProjection(ctx, req)
+	c.taskState.Touch(req.Task)
+
+	return c.streamChain()(c.withRequestLogger(ctx, req), req)
+}
+
+// dispatchStream is the innermost StreamFunc. Payloads above the chunk
+// threshold are uploaded by a sender goroutine while frames are forwarded as
+// they arrive. The two sides are coupled: a final frame stops the sender
+// before its next chunk, and a failed Send ends the stream with a
+// synthesized error frame instead of leaving the caller waiting on Recv.
+func (c *LumenClient) dispatchStream(ctx context.Context, req *pb.InferRequest) (<-chan *pb.InferResponse, error) {
+	chunks, err := ChunkPayloadFor(req.Payload, req.PayloadMime, c.config.Chunk)
+	if err != nil {
+		return nil, fmt.Errorf("chunk payload: %w", err)
+	}
 
 	cli := c.pool.Client()
 	if cli == nil {
 		return nil, ErrNoAvailableNode
 	}
+	if err := c.pool.staleCatalogError(req.Task); err != nil {
+		return nil, err
+	}
+	if err := checkStrategy(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.checkMinHealthy(ctx, req.Task); err != nil {
+		return nil, err
+	}
 
-	ctx = WithTask(ctx, req.Task)
+	ctx = withSelectionHints(WithTask(ctx, req.Task), req)
 
-	stream, err := cli.Infer(ctx)
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	var node pickedNode
+	stream, err := cli.Infer(withPickedNode(streamCtx, &node))
 	if err != nil {
+		cancelStream()
 		return nil, fmt.Errorf("infer stream: %w", err)
 	}
-
-	if err := stream.Send(req); err != nil {
-		return nil, fmt.Errorf("send: %w", err)
+	tagResolvedTask(req, node.resolvedTask())
+	converted, err := c.matchNodeMIME(ctx, req, node.get())
+	if err == nil && converted {
+		chunks, err = ChunkPayloadFor(req.Payload, req.PayloadMime, c.config.Chunk)
 	}
-	if err := stream.CloseSend(); err != nil {
-		return nil, fmt.Errorf("close send: %w", err)
+	if err != nil {
+		cancelStream()
+		return nil, err
+	}
+
+	var sender *chunkSender
+	if len(chunks) == 1 {
+		if err := stream.Send(req); err != nil {
+			cancelStream()
+			return nil, fmt.Errorf("send: %w", err)
+		}
+		if err := stream.CloseSend(); err != nil {
+			cancelStream()
+			return nil, fmt.Errorf("close send: %w", err)
+		}
+	} else {
+		sender = newChunkSender(stream, req, chunks, c.senderOptions(cancelStream))
+		sender.Start(streamCtx)
 	}
 
-	respChan := make(chan *pb.InferResponse, 100)
+	out := newStreamOutput(streamOptionsFromContext(ctx))
+	out.adoptObservers(ctx)
 	go func() {
-		defer close(respChan)
+		defer close(out.ch)
+		defer out.finish()
+		defer func() {
+			cancelStream()
+			if sender != nil {
+				sender.Stop()
+				<-sender.Done()
+			}
+		}()
 		for {
 			resp, err := stream.Recv()
-			if err == io.EOF {
+			if err != nil {
+				if sender == nil {
+					return
+				}
+				<-sender.Done()
+				if sendErr := sender.Err(); sendErr != nil && sendErr != io.EOF && ctx.Err() == nil {
+					out.deliver(ctx, req, sendFailedResponse(req, sendErr))
+				}
 				return
 			}
-			if err != nil {
+			if resp.IsFinal && sender != nil {
+				// The node is done with the request; stop uploading.
+				sender.Stop()
+			}
+			if !out.deliver(ctx, req, resp) {
 				return
 			}
-			respChan <- resp
 			if resp.IsFinal {
+				drainStream(stream, cancelStream)
 				return
 			}
 		}
 	}()
 
-	return respChan, nil
+	return out.ch, nil
+}
+
+// sendFailedResponse is the final frame InferStream delivers when uploading
+// a chunked payload fails before the node produced a final response.
+func sendFailedResponse(req *pb.InferRequest, err error) *pb.InferResponse {
+	return &pb.InferResponse{
+		CorrelationId: req.CorrelationId,
+		IsFinal:       true,
+		Error: &pb.Error{
+			Code:    pb.ErrorCode_ERROR_CODE_UNAVAILABLE,
+			Message: fmt.Sprintf("send failed: %v", err),
+		},
+	}
 }
 
 // GetConfig returns a thread-safe copy of the current configuration.
@@ -386,7 +917,11 @@ func (c *LumenClient) GetConfig() *config.Config {
 	return &copyCfg
 }
 
-// GetNodes returns summary descriptors for all pool connections.
+// GetNodes returns summary descriptors for all pool connections, sorted by
+// ID. Each is a snapshot taken under the registry lock, capabilities and
+// tasks included: it stays consistent while nodes change and may be kept,
+// serialized or modified by the caller. discovery.NodeQuery filters and
+// pages the list.
 func (c *LumenClient) GetNodes() []*discovery.NodeInfo {
 	return c.pool.NodeInfos()
 }
@@ -405,7 +940,7 @@ func (c *LumenClient) FindTaskContract(taskName string) (sdktypes.TaskContract,
 				continue
 			}
 			for _, task := range capability.GetTasks() {
-				if task.GetName() == taskName {
+				if tasks.Match(taskName, task.GetName()) {
 					return sdktypes.NewTaskContract(task), capability.GetServiceName(), true
 				}
 			}
@@ -417,28 +952,38 @@ func (c *LumenClient) FindTaskContract(taskName string) (sdktypes.TaskContract,
 // GetMetrics returns real metrics from the current process since start.
 func (c *LumenClient) GetMetrics() *ClientMetrics {
 	s := c.pool.Stats()
-	total := c.totalReqs.Load()
-	success := c.successReqs.Load()
-	failed := c.failedReqs.Load()
-	latencyNs := c.totalLatencyNs.Load()
-
-	var avgLatency int64
-	var errorRate float64
-	if total > 0 {
-		avgLatency = latencyNs / total
-		errorRate = float64(failed) / float64(total)
+	req := c.counters.snapshot()
+	m := &ClientMetrics{
+		TotalNodes:        s.TotalConnections,
+		ActiveNodes:       s.HealthyConnections,
+		TotalRequests:     req.Total,
+		SuccessRequests:   req.Success,
+		FailedRequests:    req.Failures.Failed(),
+		CancelledRequests: req.Failures.Cancelled,
+		AverageLatency:    req.AverageLatency(),
+		ErrorRate:         req.ErrorRate(),
+		LastUpdated:       time.Now(),
+		Failures:          req.Failures,
+		TaskFailures:      c.taskFailures.snapshot(),
+		NodeFailures:      c.pool.NodeFailures(),
+		TaskLatency:       c.taskLatency.snapshot(),
+		NodeLatency:       c.pool.NodeLatency(),
+		LocalFallbacks:    c.localFallbacks.Load(),
+		ChunkRetries:      c.sendRetries.retries.Load(),
+		StreamRecreations: c.sendRetries.recreations.Load(),
 	}
-
-	return &ClientMetrics{
-		TotalNodes:      s.TotalConnections,
-		ActiveNodes:     s.HealthyConnections,
-		TotalRequests:   total,
-		SuccessRequests: success,
-		FailedRequests:  failed,
-		AverageLatency:  avgLatency,
-		ErrorRate:       errorRate,
-		LastUpdated:     time.Now(),
+	if c.latency != nil {
+		m.Latency = c.latency.snapshot()
+	}
+	if c.usage != nil {
+		m.Usage = c.usage.totals()
 	}
+	if c.queue != nil {
+		q := c.queue.status(m.LastUpdated)
+		q.DeadLetters = nil
+		m.Queue = &q
+	}
+	return m
 }
 
 // PoolStats returns current pool statistics.
@@ -446,9 +991,191 @@ func (c *LumenClient) PoolStats() PoolStats {
 	return c.pool.Stats()
 }
 
+// DiscoveryStats returns counters for the discovery events the client has
+// consumed across all backends.
+func (c *LumenClient) DiscoveryStats() DiscoveryStats {
+	return c.pool.DiscoveryStats()
+}
+
+// DiscoveryStatus reports whether discovery is degraded: some configured
+// backends, typically mDNS on a host without a multicast route, failed to
+// start and are being retried while the others run. Catalog reports how
+// recently discovery vouched for the known nodes, and whether that is
+// longer ago than discovery.max_catalog_age.
+func (c *LumenClient) DiscoveryStatus() discovery.DiscoveryStatus {
+	return c.pool.DiscoveryStatus()
+}
+
+// WatchCatalogFreshness registers a callback that fires when the node
+// catalog turns stale, some node having gone unconfirmed by discovery for
+// longer than discovery.max_catalog_age, and again when it turns fresh.
+func (c *LumenClient) WatchCatalogFreshness(cb func(discovery.CatalogFreshness)) (unsubscribe func()) {
+	return c.pool.OnCatalogFreshness(cb)
+}
+
+// PushRegistry returns the registry nodes push themselves into, or nil when
+// discovery.push is disabled. A Host Broker serves it at /v1/push.
+func (c *LumenClient) PushRegistry() *discovery.PushResolver {
+	return c.push
+}
+
+// SetNodeFilters applies the allow/deny patterns of cfg to a running client,
+// e.g. after a configuration reload. Discovered nodes the new patterns reject
+// are removed with their connections, and nodes they newly admit are picked
+// up by the next mDNS query. Static nodes are never filtered. If any pattern
+// is malformed, no filter changes.
+func (c *LumenClient) SetNodeFilters(cfg config.DiscoveryConfig) error {
+	for _, patterns := range [][]string{cfg.AllowNodes, cfg.DenyNodes, cfg.Push.AllowNodes} {
+		for _, p := range patterns {
+			if err := discovery.ValidateNodePattern(p); err != nil {
+				return utils.InvalidError(err.Error())
+			}
+		}
+	}
+	if err := c.nodeFilter.Update(cfg.AllowNodes, cfg.DenyNodes); err != nil {
+		return utils.InvalidError(err.Error())
+	}
+	if err := c.pushFilter.Update(cfg.Push.AllowNodes, nil); err != nil {
+		return utils.InvalidError(err.Error())
+	}
+	return nil
+}
+
+// SystemStats returns client metrics, pool and discovery statistics and the
+// node count per status in one snapshot.
+func (c *LumenClient) SystemStats() SystemStats {
+	return SystemStats{
+		Metrics:   c.GetMetrics(),
+		Pool:      c.pool.Stats(),
+		Discovery: c.pool.DiscoveryStats(),
+		Nodes:     countNodesByStatus(c.pool.NodeInfos()),
+	}
+}
+
 // WatchNodes registers a callback that fires whenever the node list changes.
-func (c *LumenClient) WatchNodes(cb func([]*discovery.NodeInfo)) {
-	c.pool.OnNodesChanged(cb)
+// Bursts of changes within discovery.notify_window are coalesced into one
+// call with the latest list; see Pool.OnNodesChanged.
+//
+// Every Watch method returns a func that unregisters the callback, so code
+// that re-subscribes, e.g. on reconnect, does not pile up callbacks. It may
+// be called from the callback itself; once it returns no new call starts.
+// Close unregisters every callback.
+func (c *LumenClient) WatchNodes(cb func([]*discovery.NodeInfo)) (unsubscribe func()) {
+	return c.pool.OnNodesChanged(cb)
+}
+
+// CapabilityFilter restricts GetClusterCapabilities by runtime, precision or
+// model ID.
+type CapabilityFilter = discovery.CapabilityFilter
+
+// GetClusterCapabilities merges the capabilities of all active nodes by task:
+// the serving nodes and services, their runtimes, precisions and model IDs,
+// and the summed max concurrency. Only services passing filter count, so
+// CapabilityFilter{Runtime: "cuda"} answers "which tasks can run on GPU".
+func (c *LumenClient) GetClusterCapabilities(filter CapabilityFilter) discovery.ClusterCapabilities {
+	return discovery.AggregateCapabilities(c.pool.NodeInfos(), filter)
+}
+
+// WatchCapabilityChanges registers a callback that fires whenever a node's
+// re-fetched capabilities differ from the previous fetch (tasks or models
+// added or removed, runtime or max concurrency changed). The latest diff is
+// also reported on the node's NodeInfo.
+func (c *LumenClient) WatchCapabilityChanges(cb func(discovery.CapabilityDiff)) (unsubscribe func()) {
+	return c.pool.OnCapabilityChange(cb)
+}
+
+// WatchAddressChanges registers a callback that fires whenever a node keeps
+// its ID but moves to a new address. Nodes that advertise a "node_id" TXT
+// record keep their ID across DHCP lease changes, so their connection,
+// cooldown and latency history carry over.
+func (c *LumenClient) WatchAddressChanges(cb func(discovery.NodeAddressChanged)) (unsubscribe func()) {
+	return c.pool.OnAddressChange(cb)
+}
+
+// WatchStatusChanges registers a callback that fires whenever a node's
+// status changes, with the old and new status, the reason and when it
+// happened, as NodeInfo.StatusHistory records them.
+func (c *LumenClient) WatchStatusChanges(cb func(discovery.NodeStatusChanged)) (unsubscribe func()) {
+	return c.pool.OnStatusChange(cb)
+}
+
+// DrainNode takes a node out of selection before it is stopped: new
+// requests go to other nodes while those already routed to it, and their
+// open streams, finish. GetNodes reports the node as "draining" with its
+// in-flight count, and WatchDrains fires once that count reaches zero. A
+// request only a drained node could serve fails as if no node supported its
+// task, so a registered local handler takes it. The node stays drained until
+// UndrainNode, even across a restart under the same ID.
+func (c *LumenClient) DrainNode(nodeID string) error {
+	return c.pool.DrainNode(nodeID, 0)
+}
+
+// DrainNodeFor drains a node like DrainNode and undrains it after timeout,
+// so a node whose upgrade is abandoned does not stay out of rotation.
+func (c *LumenClient) DrainNodeFor(nodeID string, timeout time.Duration) error {
+	return c.pool.DrainNode(nodeID, timeout)
+}
+
+// UndrainNode returns a drained node to selection. Undraining a node that
+// is not drained does nothing.
+func (c *LumenClient) UndrainNode(nodeID string) error {
+	return c.pool.UndrainNode(nodeID)
+}
+
+// WatchDrains registers a callback that fires when a drained node has no
+// request left in flight and can be stopped without failing any.
+func (c *LumenClient) WatchDrains(cb func(discovery.NodeDrained)) (unsubscribe func()) {
+	return c.pool.OnNodeDrained(cb)
+}
+
+// WatchEjections registers a callback that fires when outlier detection
+// ejects a node whose error rate or latency stands out from the rest, and
+// again when the node's re-admission starts. GetNodes reports an ejected
+// node as "ejected", with the rates that got it ejected.
+func (c *LumenClient) WatchEjections(cb func(discovery.NodeEjection)) (unsubscribe func()) {
+	return c.pool.OnNodeEjection(cb)
+}
+
+// WatchSelections registers a callback that fires with every routing
+// decision: the task, the node picked, the strategy and how many nodes were
+// eligible. It runs synchronously on the request path and must not block;
+// it is meant for tests and diagnostics that need the exact sequence of
+// picks. ExplainSelection reports the latest decision per task.
+func (c *LumenClient) WatchSelections(cb func(discovery.SelectionDecision)) (unsubscribe func()) {
+	return c.pool.OnSelection(cb)
+}
+
+// RegisterScorer sets the NodeScorer that ranks nodes under the custom
+// selection strategy (pool.strategy: custom), replacing any earlier one; nil
+// removes it. Its score is weighted with the built-in ones by
+// pool.score_weights, and a negative score excludes a node. Under any other
+// strategy the scorer is used only by requests choosing custom with
+// WithStrategy.
+func (c *LumenClient) RegisterScorer(scorer NodeScorer) {
+	if scorer != nil && c.config.Pool.Strategy != config.StrategyCustom {
+		c.logger.Warn("node scorer registered but pool.strategy is not custom; only requests choosing custom with WithStrategy will use it",
+			zap.String("strategy", c.config.Pool.Strategy))
+	}
+	c.pool.SetScorer(scorer)
+}
+
+// ExplainSelection runs the node filters and selection strategy for task
+// without dispatching a request. The result lists every known node with the
+// reason it was passed over (not Ready, unsupported task, cooling down,
+// draining, excluded by the scorer) and the node the next request for task would go to.
+// A strategy set on ctx with WithStrategy is explained instead of pool.strategy.
+func (c *LumenClient) ExplainSelection(ctx context.Context, task string) (*discovery.SelectionExplanation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.pool.explainStrategy(strings.TrimSpace(task), StrategyFromContext(ctx))
+}
+
+// RecentNodeErrors returns the latest failures involving the node with
+// nodeID, oldest first; see Pool.RecentErrors. Requests that failed carry
+// their correlation ID.
+func (c *LumenClient) RecentNodeErrors(nodeID string) []discovery.RecentError {
+	return c.pool.RecentErrors(nodeID)
 }
 
 // resolveService auto-fills req.Meta["service"] from node capabilities
@@ -457,24 +1184,74 @@ func (c *LumenClient) resolveService(req *pb.InferRequest) {
 	if sdktypes.ServiceFromMeta(req.Meta) != "" {
 		return
 	}
-	for _, node := range c.pool.NodeInfos() {
-		if services := node.MatchingServices(req.Task); len(services) == 1 {
-			if req.Meta == nil {
-				req.Meta = make(map[string]string)
-			}
-			req.Meta[sdktypes.MetaService] = services[0]
-			return
+	if service := c.pool.uniqueService(req.Task); service != "" {
+		if req.Meta == nil {
+			req.Meta = make(map[string]string)
 		}
+		req.Meta[sdktypes.MetaService] = service
 	}
 }
 
-// Close stops discovery and closes all connections.
-func (c *LumenClient) Close() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// withRequestLogger returns ctx carrying the logger for req's log lines:
+// the request logger ctx already carries, e.g. one seeded with an HTTP
+// request ID, or the client's, with the correlation ID and task added. The
+// node is added once one is picked. See utils.WithRequestLogger.
+func (c *LumenClient) withRequestLogger(ctx context.Context, req *pb.InferRequest) context.Context {
+	base := c.logger
+	if utils.HasRequestLogger(ctx) {
+		base = nil
+	}
+	return utils.WithRequestLogger(ctx, base,
+		zap.String("correlation_id", req.GetCorrelationId()),
+		zap.String("task", req.GetTask()),
+	)
+}
+
+// ClientVersionMetaKey is the request Meta key carrying the SDK version
+// that sent the request, so nodes can log it when triaging compatibility
+// issues. Infer and InferStream set it unless the caller already has.
+const ClientVersionMetaKey = "lumen.client_version"
+
+var clientVersion = version.Get().Version
+
+func tagClientVersion(req *pb.InferRequest) {
+	if _, ok := req.Meta[ClientVersionMetaKey]; ok {
+		return
+	}
+	if req.Meta == nil {
+		req.Meta = make(map[string]string)
+	}
+	req.Meta[ClientVersionMetaKey] = clientVersion
+}
+
+// ResolvedTaskMetaKey is the request Meta key naming the task the chosen
+// node advertises for the request when it differs from req.Task, e.g.
+// "clip_text_embed@v2" for a request for "clip_text_embed" or "ocr.detect"
+// matched by "ocr.*". See package tasks for the matching rules.
+const ResolvedTaskMetaKey = "lumen.resolved_task"
 
-	if c.cancel != nil {
-		c.cancel()
+// tagResolvedTask records resolved on req once its node is picked. A retry
+// on another node replaces or clears what an earlier attempt recorded.
+func tagResolvedTask(req *pb.InferRequest, resolved string) {
+	if resolved == "" || resolved == req.Task {
+		delete(req.Meta, ResolvedTaskMetaKey)
+		return
+	}
+	if req.Meta == nil {
+		req.Meta = make(map[string]string)
 	}
-	return c.pool.Close()
+	req.Meta[ResolvedTaskMetaKey] = resolved
+}
+
+// Close stops discovery and closes all connections. New Infer and
+// InferStream calls fail with ErrClientClosed from the moment it starts;
+// calls already in flight get up to 10s to finish first, and streams still
+// open when the pool closes end with an error frame or a closed channel.
+// The requests the durable queue is sending are stopped first and sent again
+// after a restart. Close may be called more than once and from several
+// goroutines; every call waits for the first to finish and returns its
+// result.
+func (c *LumenClient) Close() error {
+	c.closeOnce.Do(func() { c.closeErr = c.shutdown() })
+	return c.closeErr
 }
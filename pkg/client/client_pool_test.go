@@ -3,16 +3,24 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
 	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
 	pb "github.com/edwinzhancn/lumen-sdk/proto"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/metadata"
@@ -129,6 +137,42 @@ func TestFilterByTaskExpiredCooldownProbes(t *testing.T) {
 	}
 }
 
+// BenchmarkPickerPick picks among 50 Ready nodes advertising 20 tasks each,
+// ten of which serve the requested task. task_set is the precomputed lookup
+// the balancer maintains; task_scan is the per-Pick walk of each node's task
+// list it replaces.
+func BenchmarkPickerPick(b *testing.B) {
+	for _, precomputed := range []bool{true, false} {
+		name := "task_scan"
+		if precomputed {
+			name = "task_set"
+		}
+		b.Run(name, func(b *testing.B) {
+			picker := &lumenPicker{}
+			for n := 0; n < 50; n++ {
+				scs := &subConnState{identity: discovery.NewNodeIdentity("local", fmt.Sprintf("node-%d", n)), state: connectivity.Ready}
+				for t := 0; t < 20; t++ {
+					scs.hintTasks = append(scs.hintTasks, fmt.Sprintf("task-%d-%d", n%5, t))
+				}
+				if precomputed {
+					scs.refreshTasksLocked()
+				} else {
+					scs.tasks = scs.hintTasks
+				}
+				picker.ready = append(picker.ready, scs)
+			}
+			info := balancer.PickInfo{Ctx: WithTask(context.Background(), "task-4-19")}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := picker.Pick(info); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 func TestAnySupportsTask(t *testing.T) {
 	nodes := []*subConnState{
 		{tasks: []string{"ocr", "embed"}},
@@ -195,6 +239,82 @@ func TestStartCooldownCapsAtMax(t *testing.T) {
 	}
 }
 
+func TestProbeRetryDelaySchedule(t *testing.T) {
+	want := []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, // before quarantine
+		30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute,
+		10 * time.Minute, 10 * time.Minute, // capped
+	}
+	for i, w := range want {
+		if got := probeRetryDelay(i + 1); got != w {
+			t.Errorf("probeRetryDelay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestProbeFailuresQuarantineAndRecover(t *testing.T) {
+	lb := &lumenBalancer{}
+	scs := &subConnState{}
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 1; i < quarantineThreshold; i++ {
+		delay, entered := lb.recordProbeFailureLocked(scs, clock)
+		if entered || !scs.nextProbe.IsZero() {
+			t.Fatalf("failure %d: entered=%v nextProbe=%v, want no quarantine yet", i, entered, scs.nextProbe)
+		}
+		clock = clock.Add(delay)
+	}
+
+	delay, entered := lb.recordProbeFailureLocked(scs, clock)
+	if !entered || delay != quarantineBackoffMin {
+		t.Fatalf("threshold failure: entered=%v delay=%v, want true %v", entered, delay, quarantineBackoffMin)
+	}
+	if want := clock.Add(quarantineBackoffMin); !scs.nextProbe.Equal(want) {
+		t.Fatalf("nextProbe = %v, want %v", scs.nextProbe, want)
+	}
+
+	clock = clock.Add(delay)
+	delay, entered = lb.recordProbeFailureLocked(scs, clock)
+	if entered || delay != 2*quarantineBackoffMin {
+		t.Fatalf("second quarantined failure: entered=%v delay=%v, want false %v", entered, delay, 2*quarantineBackoffMin)
+	}
+	if want := clock.Add(delay); !scs.nextProbe.Equal(want) {
+		t.Fatalf("nextProbe = %v, want %v", scs.nextProbe, want)
+	}
+
+	if !lb.clearProbeFailuresLocked(scs) {
+		t.Fatal("clearProbeFailuresLocked should report the node was quarantined")
+	}
+	if scs.probeFailures != 0 || !scs.nextProbe.IsZero() {
+		t.Fatalf("after clear: failures=%d nextProbe=%v, want reset", scs.probeFailures, scs.nextProbe)
+	}
+	if lb.clearProbeFailuresLocked(scs) {
+		t.Fatal("clearing a healthy node should not report a quarantine")
+	}
+}
+
+func TestNodeRegistryReportsQuarantinedNodes(t *testing.T) {
+	next := time.Date(2026, 1, 1, 0, 5, 0, 0, time.UTC)
+	reg := &nodeRegistry{
+		nodes: map[string]*registeredNode{
+			"node-1": {identity: discovery.NewNodeIdentity("local", "node-1"), state: connectivity.Ready, probeFailures: quarantineThreshold, nextProbe: next},
+			"node-2": {identity: discovery.NewNodeIdentity("local", "node-2"), state: connectivity.Ready, probeFailures: 1},
+		},
+	}
+	if got := reg.quarantined(); got != 1 {
+		t.Fatalf("quarantined = %d, want 1", got)
+	}
+	for _, info := range reg.nodeInfos() {
+		quarantined := info.Status == discovery.NodeStatusQuarantined
+		if quarantined != (info.ID == "local-node-1") {
+			t.Errorf("node %s status = %s", info.ID, info.Status)
+		}
+		if quarantined && !info.NextProbe.Equal(next) {
+			t.Errorf("node %s NextProbe = %v, want %v", info.ID, info.NextProbe, next)
+		}
+	}
+}
+
 // --- Task context tests ---
 
 func TestTaskContext(t *testing.T) {
@@ -257,6 +377,99 @@ func TestNodeRegistryEmptyStats(t *testing.T) {
 	}
 }
 
+func TestNodeRegistryProbeFailures(t *testing.T) {
+	reg := &nodeRegistry{
+		nodes: map[string]*registeredNode{
+			"node-1": {state: connectivity.Ready, probeFailures: 3},
+			"node-2": {state: connectivity.Ready},
+		},
+	}
+	failures := reg.probeFailures()
+	if len(failures) != 1 || failures["node-1"] != 3 {
+		t.Fatalf("probeFailures = %v, want map[node-1:3]", failures)
+	}
+}
+
+func TestPoolOptionsNormalizedProbeDefaults(t *testing.T) {
+	opts := PoolOptions{}.normalized()
+	if opts.CapabilityFetchTimeout != defaultCapFetchTimeout {
+		t.Fatalf("CapabilityFetchTimeout = %v, want %v", opts.CapabilityFetchTimeout, defaultCapFetchTimeout)
+	}
+	if opts.MaxConcurrentProbes != 4 {
+		t.Fatalf("MaxConcurrentProbes = %d, want 4", opts.MaxConcurrentProbes)
+	}
+}
+
+func TestPoolOptionsKeepalive(t *testing.T) {
+	params, ok := PoolOptions{}.normalized().keepaliveParams()
+	if !ok || params.Time != defaultKeepAlive || params.Timeout != defaultKeepAliveTimeout || !params.PermitWithoutStream {
+		t.Fatalf("default keepalive = %+v (enabled %v), want %v/%v pinging without streams", params, ok, defaultKeepAlive, defaultKeepAliveTimeout)
+	}
+
+	params, ok = PoolOptions{KeepAlive: time.Minute, KeepAliveTimeout: 5 * time.Second}.normalized().keepaliveParams()
+	if !ok || params.Time != time.Minute || params.Timeout != 5*time.Second {
+		t.Fatalf("keepalive = %+v, want 1m/5s", params)
+	}
+
+	if _, ok := (PoolOptions{KeepAlive: -1}).normalized().keepaliveParams(); ok {
+		t.Fatal("negative KeepAlive should disable pings")
+	}
+	if got := keepAliveInterval(config.PoolConfig{}); got >= 0 {
+		t.Fatalf("keepAliveInterval(0) = %v, want negative (disabled)", got)
+	}
+}
+
+func TestNodeRegistryStateTransitions(t *testing.T) {
+	reg := &nodeRegistry{nodes: make(map[string]*registeredNode)}
+	reg.recordTransition(connectivity.Ready, connectivity.Idle)
+	reg.recordTransition(connectivity.Idle, connectivity.Connecting)
+	reg.recordTransition(connectivity.Ready, connectivity.Idle)
+
+	got := reg.stateTransitions()
+	if got["READY->IDLE"] != 2 || got["IDLE->CONNECTING"] != 1 || len(got) != 2 {
+		t.Fatalf("transitions = %v", got)
+	}
+	got["READY->IDLE"] = 99
+	if reg.stateTransitions()["READY->IDLE"] != 2 {
+		t.Fatal("stateTransitions should return a copy")
+	}
+}
+
+func TestProbeCapabilitiesBoundedByProbeSlots(t *testing.T) {
+	reg := &nodeRegistry{nodes: make(map[string]*registeredNode)}
+	lb := &lumenBalancer{
+		subConns: make(map[string]*subConnState),
+		registry: reg,
+		options:  balancerOptions{capFetchTimeout: 200 * time.Millisecond},
+		probeSem: make(chan struct{}, 1),
+	}
+
+	// Occupy the only slot; the probe must wait instead of dialing.
+	lb.probeSem <- struct{}{}
+	done := make(chan bool, 1)
+	go func() {
+		done <- lb.probeCapabilities("node-1", "127.0.0.1:1")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if got := reg.inFlightProbes.Load(); got != 0 {
+		t.Fatalf("in-flight probes while slot taken = %d, want 0", got)
+	}
+
+	<-lb.probeSem
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("probe against closed port should fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("probe did not run after slot was released")
+	}
+	if got := reg.inFlightProbes.Load(); got != 0 {
+		t.Fatalf("in-flight probes after completion = %d, want 0", got)
+	}
+}
+
 // --- availabilityFromRegistered tests ---
 
 func TestAvailabilityFromRegistered(t *testing.T) {
@@ -439,6 +652,420 @@ func TestPoolMultipleNodes(t *testing.T) {
 	})
 }
 
+func discoveredNode(id, addr string, tasks ...string) discovery.NodeEvent {
+	host, port, _ := splitEndpoint(addr)
+	return discovery.NodeEvent{
+		Type: discovery.NodeDiscovered,
+		Resolved: discovery.ResolvedNode{
+			Identity:  discovery.NewNodeIdentity("local", id),
+			Addresses: []string{host},
+			Port:      port,
+			Txt:       map[string]string{"tasks": strings.Join(tasks, ",")},
+		},
+	}
+}
+
+func TestPoolMaxConnectionsCapsNodes(t *testing.T) {
+	resolver := &fakeNodeResolver{}
+	for i := 1; i <= 3; i++ {
+		addr := startCapabilityServer(t, "ocr")
+		resolver.events = append(resolver.events, discoveredNode(fmt.Sprintf("node-%d", i), addr, "ocr"))
+	}
+
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{ConnectTimeout: 2 * time.Second, MaxConnections: 2})
+	if err := pool.Connect(resolver); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer pool.Close()
+
+	waitUntil(t, func() bool { return pool.Stats().HealthyConnections == 2 })
+	time.Sleep(100 * time.Millisecond)
+	if total := pool.Stats().TotalConnections; total != 2 {
+		t.Fatalf("TotalConnections = %d, want 2", total)
+	}
+}
+
+// countingListener counts accepted connections.
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int64
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+func TestPoolRecyclesConnectionsAfterMaxLifetime(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	lis := &countingListener{Listener: inner}
+	addr := serveInference(t, lis, &testInferenceServer{tasks: []string{"ocr"}})
+
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{
+		ConnectTimeout: 2 * time.Second,
+		MaxLifetime:    100 * time.Millisecond,
+	})
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{discoveredNode("node-1", addr, "ocr")}}); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer pool.Close()
+
+	// One pool connection plus one capability probe, then one more per
+	// recycle.
+	waitUntil(t, func() bool { return lis.accepted.Load() >= 5 })
+	if s := pool.Stats(); s.TotalConnections != 1 || s.HealthyConnections != 1 {
+		t.Fatalf("stats after recycling = %+v, want one healthy node", s)
+	}
+}
+
+// flakyHealthServer fails Health while failing is set. Once failLimit
+// failures have been served it answers Unimplemented, which health checks
+// ignore, so the failure count settles.
+type flakyHealthServer struct {
+	testInferenceServer
+	failing   atomic.Bool
+	failed    atomic.Int32
+	failLimit int32
+}
+
+func (s *flakyHealthServer) Health(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	if !s.failing.Load() {
+		return &emptypb.Empty{}, nil
+	}
+	if s.failLimit > 0 && s.failed.Load() >= s.failLimit {
+		return nil, status.Error(codes.Unimplemented, "health disabled")
+	}
+	s.failed.Add(1)
+	return nil, status.Error(codes.Unavailable, "model unloaded")
+}
+
+func registeredNodeFor(pool *Pool, key string) registeredNode {
+	pool.registry.mu.RLock()
+	defer pool.registry.mu.RUnlock()
+	if rn := pool.registry.nodes[key]; rn != nil {
+		return *rn
+	}
+	return registeredNode{}
+}
+
+// nodeSubConn returns the SubConn currently serving key, or nil.
+func nodeSubConn(pool *Pool, key string) balancer.SubConn {
+	picker := pool.registry.picker.Load()
+	if picker == nil || picker.balancer == nil {
+		return nil
+	}
+	lb := picker.balancer
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if scs, ok := lb.subConns[key]; ok && scs.state == connectivity.Ready {
+		return scs.sc
+	}
+	return nil
+}
+
+// probeHealthNow runs one health check of key, as the health-check loop
+// would.
+func probeHealthNow(pool *Pool, key string) {
+	lb := pool.registry.picker.Load().balancer
+	lb.mu.Lock()
+	scs := lb.subConns[key]
+	scs.healthChecking = true
+	addr := scs.addr.Addr
+	lb.mu.Unlock()
+	lb.probeHealth(key, addr)
+}
+
+func connectHealthTestPool(t *testing.T, addr string, interval time.Duration) *Pool {
+	t.Helper()
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{
+		ConnectTimeout:        2 * time.Second,
+		RediscoveryBackoffMin: time.Minute,
+		RediscoveryBackoffMax: time.Minute,
+		HealthCheckInterval:   interval,
+	})
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{discoveredNode("node-1", addr, "ocr")}}); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+	waitUntil(t, func() bool { return nodeSubConn(pool, "local-node-1") != nil })
+	return pool
+}
+
+func TestPoolHealthCheckCoolsDownFailingNode(t *testing.T) {
+	srv := &flakyHealthServer{testInferenceServer: testInferenceServer{tasks: []string{"ocr"}}, failLimit: hardFailureThreshold}
+	srv.failing.Store(true)
+	addr := startInferenceServer(t, srv)
+	pool := connectHealthTestPool(t, addr, 20*time.Millisecond)
+
+	waitUntil(t, func() bool {
+		rn := registeredNodeFor(pool, "local-node-1")
+		return rn.healthFailures >= hardFailureThreshold && !rn.cooldownUntil.IsZero()
+	})
+	// Health-check failures are counted apart from request failures.
+	info := nodeInfoByID(pool.NodeInfos(), "local-node-1")
+	if info.HealthCheckFailures != hardFailureThreshold || info.RequestFailures != 0 {
+		t.Fatalf("failures = %d health, %d request; want %d health, 0 request",
+			info.HealthCheckFailures, info.RequestFailures, hardFailureThreshold)
+	}
+	if n := len(info.StatusHistory); info.Status != discovery.NodeStatusError || info.StatusReason != discovery.StatusReasonHealthCheckFailed ||
+		n == 0 || info.StatusHistory[n-1].To != discovery.NodeStatusError || !info.StatusSince.Equal(info.StatusHistory[n-1].At) {
+		t.Fatalf("status %s (%s since %v) with history %+v, want error after failed health checks",
+			info.Status, info.StatusReason, info.StatusSince, info.StatusHistory)
+	}
+}
+
+// TestPoolHealthCheckRecoversSameConnection takes a node through failing
+// health checks and a restart on the same address, and checks it returns to
+// selection on the connection it had throughout.
+func TestPoolHealthCheckRecoversSameConnection(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := lis.Addr().String()
+	srv := &flakyHealthServer{testInferenceServer: testInferenceServer{tasks: []string{"ocr"}}}
+	server := grpc.NewServer()
+	pb.RegisterInferenceServer(server, srv)
+	go func() { _ = server.Serve(lis) }()
+
+	pool := connectHealthTestPool(t, addr, 0)
+	sc := nodeSubConn(pool, "local-node-1")
+
+	srv.failing.Store(true)
+	for i := 0; i < hardFailureThreshold; i++ {
+		probeHealthNow(pool, "local-node-1")
+	}
+	rn := registeredNodeFor(pool, "local-node-1")
+	if rn.healthFailures != hardFailureThreshold || rn.cooldownUntil.IsZero() {
+		t.Fatalf("after %d failed checks: healthFailures %d, cooldown %v; want the node cooled down",
+			hardFailureThreshold, rn.healthFailures, rn.cooldownUntil)
+	}
+
+	// The node goes away and comes back healthy on the same address.
+	server.Stop()
+	waitUntil(t, func() bool { return nodeSubConn(pool, "local-node-1") == nil })
+	lis, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("relisten on %s: %v", addr, err)
+	}
+	srv.failing.Store(false)
+	server = grpc.NewServer()
+	pb.RegisterInferenceServer(server, srv)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	// Becoming Ready again triggers a health check without waiting for a
+	// retry, which clears the failures.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rn = registeredNodeFor(pool, "local-node-1")
+		if rn.state == connectivity.Ready && rn.healthFailures == 0 && rn.cooldownUntil.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("node did not recover: %+v", rn)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := nodeSubConn(pool, "local-node-1"); got != sc {
+		t.Fatal("node recovered on a new connection, want the original one")
+	}
+}
+
+// TestPoolHealthCheckOnFakeClock drives health checks, cooldowns and the
+// damper's suspect time from a FakeClock: the node is only judged again
+// once the clock is moved, however long the test takes.
+func TestPoolHealthCheckOnFakeClock(t *testing.T) {
+	srv := &flakyHealthServer{testInferenceServer: testInferenceServer{tasks: []string{"ocr"}}}
+	addr := startInferenceServer(t, srv)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewFakeClock(start)
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{
+		ConnectTimeout:        2 * time.Second,
+		RediscoveryBackoffMin: time.Minute,
+		RediscoveryBackoffMax: time.Minute,
+		HealthCheckInterval:   time.Hour,
+		Hysteresis: config.HysteresisConfig{
+			FailThreshold:    1,
+			RecoverThreshold: 1,
+			FlapLimit:        1,
+			FlapWindow:       10 * time.Minute,
+			SuspectTime:      30 * time.Second,
+		},
+	})
+	pool.SetClock(clock)
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{discoveredNode("node-1", addr, "ocr")}}); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+	waitUntil(t, func() bool { return nodeSubConn(pool, "local-node-1") != nil })
+
+	current := func() *discovery.NodeInfo {
+		t.Helper()
+		return nodeInfoByID(pool.NodeInfos(), "local-node-1")
+	}
+
+	// The health ticker fires on the fake clock's hour, not the wall's.
+	srv.failing.Store(true)
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	waitUntil(t, func() bool { return registeredNodeFor(pool, "local-node-1").healthFailures == 1 })
+	failedAt := start.Add(time.Hour)
+	if rn := registeredNodeFor(pool, "local-node-1"); !rn.cooldownUntil.Equal(failedAt.Add(time.Minute)) {
+		t.Fatalf("cooldown until %v, want %v: a minute after the failed check", rn.cooldownUntil, failedAt.Add(time.Minute))
+	}
+	if info := current(); info.Status != discovery.NodeStatusError || !info.StatusSince.Equal(failedAt) {
+		t.Fatalf("status %s since %v, want error since %v", info.Status, info.StatusSince, failedAt)
+	}
+
+	srv.failing.Store(false)
+	probeHealthNow(pool, "local-node-1")
+	if info := current(); info.Status != discovery.NodeStatusActive {
+		t.Fatalf("status after a passed check = %s, want active", info.Status)
+	}
+
+	// A second error within the flap window holds the node suspect, and no
+	// passed check lets it out until the suspect time is over.
+	srv.failing.Store(true)
+	probeHealthNow(pool, "local-node-1")
+	srv.failing.Store(false)
+	probeHealthNow(pool, "local-node-1")
+	if info := current(); info.Status != discovery.NodeStatusSuspect || !info.SuspectUntil.Equal(failedAt.Add(30*time.Second)) {
+		t.Fatalf("status %s until %v, want suspect until %v", info.Status, info.SuspectUntil, failedAt.Add(30*time.Second))
+	}
+	clock.Advance(30 * time.Second)
+	probeHealthNow(pool, "local-node-1")
+	if info := current(); info.Status != discovery.NodeStatusActive || info.StatusReason != discovery.StatusReasonHealthCheckPassed {
+		t.Fatalf("status after the suspect time = %s (%s), want active", info.Status, info.StatusReason)
+	}
+}
+
+// countingInferServer answers every Infer request with an empty final
+// response and counts them.
+type countingInferServer struct {
+	testInferenceServer
+	calls atomic.Int32
+}
+
+func (s *countingInferServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	s.calls.Add(1)
+	return stream.Send(&pb.InferResponse{CorrelationId: req.CorrelationId, IsFinal: true})
+}
+
+// TestPoolDropsCrashedNodeAtOnce stops one of two nodes and checks requests
+// stop going to it within a second, without a health-check tick, and that
+// it is reported in error until it is back on the same address.
+func TestPoolDropsCrashedNodeAtOnce(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addrA := lis.Addr().String()
+	srvA := &countingInferServer{testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}}
+	serverA := grpc.NewServer()
+	pb.RegisterInferenceServer(serverA, srvA)
+	go func() { _ = serverA.Serve(lis) }()
+	srvB := &countingInferServer{testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}}
+	addrB := startInferenceServer(t, srvB)
+
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{ConnectTimeout: 2 * time.Second})
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{
+		discoveredNode("a", addrA, types.TaskSemanticTextEmbed),
+		discoveredNode("b", addrB, types.TaskSemanticTextEmbed),
+	}}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+	// Wait for both nodes' capabilities, so node a crashes active rather
+	// than provisional.
+	waitUntil(t, func() bool {
+		infos := pool.NodeInfos()
+		a, b := nodeInfoByID(infos, "local-a"), nodeInfoByID(infos, "local-b")
+		return a != nil && b != nil && a.Status == discovery.NodeStatusActive && b.Status == discovery.NodeStatusActive
+	})
+	c := &LumenClient{pool: pool, config: config.DefaultConfig(), logger: zap.NewNop()}
+
+	serverA.Stop()
+	crashed := time.Now()
+	waitUntil(t, func() bool { return nodeSubConn(pool, "local-a") == nil })
+	if elapsed := time.Since(crashed); elapsed >= time.Second {
+		t.Fatalf("node left selection %s after the crash, want under 1s", elapsed)
+	}
+	before := srvA.calls.Load()
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err := c.Infer(ctx, embedRequest())
+		cancel()
+		if err != nil {
+			t.Fatalf("request %d after the crash: %v", i, err)
+		}
+	}
+	if srvA.calls.Load() != before || srvB.calls.Load() < 10 {
+		t.Fatalf("calls after the crash: a %d, b %d; want all on b", srvA.calls.Load()-before, srvB.calls.Load())
+	}
+
+	info := nodeInfoByID(pool.NodeInfos(), "local-a")
+	if info.Status != discovery.NodeStatusError || info.LastError == nil || len(info.StatusHistory) == 0 ||
+		info.StatusHistory[len(info.StatusHistory)-1].Reason != discovery.StatusReasonConnectionLost {
+		t.Fatalf("crashed node: status %s, last error %+v, history %+v; want error after a lost connection",
+			info.Status, info.LastError, info.StatusHistory)
+	}
+
+	lis, err = net.Listen("tcp", addrA)
+	if err != nil {
+		t.Fatalf("relisten on %s: %v", addrA, err)
+	}
+	serverA = grpc.NewServer()
+	pb.RegisterInferenceServer(serverA, srvA)
+	go func() { _ = serverA.Serve(lis) }()
+	t.Cleanup(serverA.Stop)
+
+	waitUntil(t, func() bool {
+		info := nodeInfoByID(pool.NodeInfos(), "local-a")
+		return nodeSubConn(pool, "local-a") != nil && info != nil && info.Status == discovery.NodeStatusActive
+	})
+	info = nodeInfoByID(pool.NodeInfos(), "local-a")
+	if info.Status != discovery.NodeStatusActive || info.LastError != nil ||
+		info.StatusHistory[len(info.StatusHistory)-1].Reason != discovery.StatusReasonConnectionRestored {
+		t.Fatalf("restarted node: status %s, last error %+v, history %+v; want active and cleared",
+			info.Status, info.LastError, info.StatusHistory)
+	}
+}
+
+func TestPoolHealthCheckReconnectsAfterSustainedFailure(t *testing.T) {
+	srv := &flakyHealthServer{testInferenceServer: testInferenceServer{tasks: []string{"ocr"}}}
+	addr := startInferenceServer(t, srv)
+	pool := connectHealthTestPool(t, addr, 0)
+	sc := nodeSubConn(pool, "local-node-1")
+
+	srv.failing.Store(true)
+	for i := 0; i < healthFailureLimit-1; i++ {
+		probeHealthNow(pool, "local-node-1")
+	}
+	if nodeSubConn(pool, "local-node-1") != sc {
+		t.Fatalf("connection replaced before %d failed checks", healthFailureLimit)
+	}
+	probeHealthNow(pool, "local-node-1")
+
+	waitUntil(t, func() bool {
+		got := nodeSubConn(pool, "local-node-1")
+		return got != nil && got != sc
+	})
+	if rn := registeredNodeFor(pool, "local-node-1"); rn.healthFailures != 0 {
+		t.Fatalf("healthFailures = %d on the new connection, want 0", rn.healthFailures)
+	}
+}
+
 func TestPoolWatcherNotification(t *testing.T) {
 	addr := startCapabilityServer(t, "ocr")
 	host, port, _ := splitEndpoint(addr)
@@ -483,6 +1110,109 @@ func TestPoolWatcherNotification(t *testing.T) {
 	}
 }
 
+// nodeCounter is an OnNodesChanged callback recording how many nodes each
+// call saw.
+type nodeCounter struct {
+	mu     sync.Mutex
+	counts []int
+}
+
+func (c *nodeCounter) watch(nodes []*discovery.NodeInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = append(c.counts, len(nodes))
+}
+
+func (c *nodeCounter) calls() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]int(nil), c.counts...)
+}
+
+func (c *nodeCounter) last() int {
+	calls := c.calls()
+	if len(calls) == 0 {
+		return -1
+	}
+	return calls[len(calls)-1]
+}
+
+func TestPoolCoalescesNodeNotifications(t *testing.T) {
+	const nodes = 20
+	resolver := &fakeNodeResolver{}
+	for i := 1; i <= nodes; i++ {
+		// Closed ports: every node also fails to connect, adding more
+		// changes to the burst.
+		resolver.events = append(resolver.events, discoveredNode(fmt.Sprintf("node-%d", i), fmt.Sprintf("127.0.0.1:%d", i), "ocr"))
+	}
+
+	const window = 100 * time.Millisecond
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{NotifyWindow: window})
+	counter := &nodeCounter{}
+	pool.OnNodesChanged(counter.watch)
+
+	start := time.Now()
+	if err := pool.Connect(resolver); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer pool.Close()
+	waitUntil(t, func() bool { return counter.last() == nodes })
+	elapsed := time.Since(start)
+
+	// One call at once, then at most one per window.
+	calls := counter.calls()
+	if limit := int(elapsed/window) + 2; len(calls) > limit {
+		t.Fatalf("%d callbacks in %s, want at most %d: %v", len(calls), elapsed, limit, calls)
+	}
+	for i := 1; i < len(calls); i++ {
+		if calls[i] < calls[i-1] {
+			t.Fatalf("callback %d saw %d nodes after %d: an older list was delivered", i, calls[i], calls[i-1])
+		}
+	}
+}
+
+func TestPoolNodeWatcherDoesNotOverlap(t *testing.T) {
+	// Coalescing is off, so only the slow callback itself folds changes.
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{NotifyWindow: -1})
+	reg := &nodeRegistry{nodes: make(map[string]*registeredNode)}
+	pool.registry = reg
+	defer pool.Close()
+
+	counter := &nodeCounter{}
+	var running, overlaps atomic.Int32
+	pool.OnNodesChanged(func(nodes []*discovery.NodeInfo) {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(20 * time.Millisecond)
+		counter.watch(nodes)
+		running.Add(-1)
+	})
+
+	const changes = 50
+	for i := 1; i <= changes; i++ {
+		id := discovery.NewNodeIdentity("local", fmt.Sprintf("node-%d", i))
+		reg.mu.Lock()
+		reg.nodes[id.Key()] = &registeredNode{identity: id, addr: fmt.Sprintf("127.0.0.1:%d", i)}
+		reg.mu.Unlock()
+		pool.notifyWatchers()
+	}
+	waitUntil(t, func() bool { return counter.last() == changes })
+
+	if n := overlaps.Load(); n != 0 {
+		t.Fatalf("callback overlapped itself %d times", n)
+	}
+	calls := counter.calls()
+	if len(calls) >= changes {
+		t.Fatalf("%d callbacks for %d changes, want them coalesced", len(calls), changes)
+	}
+	for i := 1; i < len(calls); i++ {
+		if calls[i] < calls[i-1] {
+			t.Fatalf("callback %d saw %d nodes after %d: an older list was delivered", i, calls[i], calls[i-1])
+		}
+	}
+}
+
 func TestPoolCloseIdempotent(t *testing.T) {
 	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{})
 	resolver := &fakeNodeResolver{}
@@ -512,7 +1242,7 @@ func TestPoolStatsBeforeConnect(t *testing.T) {
 
 // --- Helpers ---
 
-func waitUntil(t *testing.T, condition func() bool) {
+func waitUntil(t testing.TB, condition func() bool) {
 	t.Helper()
 	deadline := time.Now().Add(5 * time.Second)
 	for time.Now().Before(deadline) {
@@ -525,13 +1255,23 @@ func waitUntil(t *testing.T, condition func() bool) {
 }
 
 func startCapabilityServer(t *testing.T, tasks ...string) string {
+	t.Helper()
+	return startInferenceServer(t, &testInferenceServer{tasks: tasks})
+}
+
+func startInferenceServer(t testing.TB, srv pb.InferenceServer) string {
 	t.Helper()
 	lis, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatalf("listen: %v", err)
 	}
+	return serveInference(t, lis, srv)
+}
+
+func serveInference(t testing.TB, lis net.Listener, srv pb.InferenceServer) string {
+	t.Helper()
 	server := grpc.NewServer()
-	pb.RegisterInferenceServer(server, &testInferenceServer{tasks: tasks})
+	pb.RegisterInferenceServer(server, srv)
 	go func() {
 		_ = server.Serve(lis)
 	}()
@@ -634,10 +1374,10 @@ func (f *fakeCapabilityStream) Recv() (*pb.Capability, error) {
 
 func (f *fakeCapabilityStream) Header() (metadata.MD, error) { return nil, nil }
 func (f *fakeCapabilityStream) Trailer() metadata.MD         { return nil }
-func (f *fakeCapabilityStream) CloseSend() error              { return nil }
-func (f *fakeCapabilityStream) Context() context.Context      { return context.Background() }
-func (f *fakeCapabilityStream) SendMsg(any) error             { return nil }
-func (f *fakeCapabilityStream) RecvMsg(any) error             { return nil }
+func (f *fakeCapabilityStream) CloseSend() error             { return nil }
+func (f *fakeCapabilityStream) Context() context.Context     { return context.Background() }
+func (f *fakeCapabilityStream) SendMsg(any) error            { return nil }
+func (f *fakeCapabilityStream) RecvMsg(any) error            { return nil }
 
 type fakeInferStream struct {
 	sendErr      error
@@ -664,7 +1404,7 @@ func (f *fakeInferStream) Recv() (*pb.InferResponse, error) {
 
 func (f *fakeInferStream) Header() (metadata.MD, error) { return nil, nil }
 func (f *fakeInferStream) Trailer() metadata.MD         { return nil }
-func (f *fakeInferStream) CloseSend() error              { return f.closeSendErr }
-func (f *fakeInferStream) Context() context.Context      { return context.Background() }
-func (f *fakeInferStream) SendMsg(any) error             { return nil }
-func (f *fakeInferStream) RecvMsg(any) error             { return nil }
+func (f *fakeInferStream) CloseSend() error             { return f.closeSendErr }
+func (f *fakeInferStream) Context() context.Context     { return context.Background() }
+func (f *fakeInferStream) SendMsg(any) error            { return nil }
+func (f *fakeInferStream) RecvMsg(any) error            { return nil }
@@ -0,0 +1,42 @@
+package clientmock
+
+// TestingT is the subset of *testing.T used by the assertion helpers, so the
+// package does not import "testing" into production builds.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertInferCalledWith fails t unless some recorded call for task has a
+// payload satisfying match. A nil match accepts any payload.
+func (m *Client) AssertInferCalledWith(t TestingT, task string, match func(payload []byte) bool) bool {
+	t.Helper()
+	calls := m.CallsFor(task)
+	for _, c := range calls {
+		if match == nil || match(c.Request.GetPayload()) {
+			return true
+		}
+	}
+	if len(calls) == 0 {
+		t.Errorf("clientmock: expected a call for task %q, got none (recorded %d calls)", task, len(m.Calls()))
+	} else {
+		t.Errorf("clientmock: %d calls for task %q, none with a matching payload", len(calls), task)
+	}
+	return false
+}
+
+// AssertCallCount fails t unless task was called exactly n times.
+func (m *Client) AssertCallCount(t TestingT, task string, n int) bool {
+	t.Helper()
+	if got := len(m.CallsFor(task)); got != n {
+		t.Errorf("clientmock: task %q called %d times, want %d", task, got, n)
+		return false
+	}
+	return true
+}
+
+// AssertNotCalled fails t if task was called at all.
+func (m *Client) AssertNotCalled(t TestingT, task string) bool {
+	t.Helper()
+	return m.AssertCallCount(t, task, 0)
+}
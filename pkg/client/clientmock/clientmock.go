@@ -0,0 +1,483 @@
+// Package clientmock provides a scriptable in-memory implementation of
+// client.Client for testing code that embeds the SDK without a cluster.
+//
+//	mock := clientmock.New().
+//	    On(types.TaskSemanticTextEmbed, clientmock.Reply(
+//	        clientmock.JSONResult("embedding_v1", types.EmbeddingV1{Vector: []float32{1, 0}, Dim: 2}),
+//	    ))
+//
+//	app := NewApp(mock) // app depends on client.Client
+//	app.Index(ctx, "hello")
+//
+//	mock.AssertInferCalledWith(t, types.TaskSemanticTextEmbed, func(payload []byte) bool {
+//	    return string(payload) == "hello"
+//	})
+//
+// The package only depends on the SDK's own packages and the standard library.
+package clientmock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// Response is one scripted outcome for a task.
+type Response struct {
+	// Frames are the responses delivered in order. Infer returns the last
+	// frame, passing the progress of earlier ones to the caller's
+	// ProgressHandler; InferStream delivers them all.
+	Frames []*pb.InferResponse
+	// Err, when set, is returned instead of any frame.
+	Err error
+	// Latency delays the outcome. The delay is cut short, with the
+	// context's error, if the caller's context ends first.
+	Latency time.Duration
+}
+
+// Reply scripts a successful outcome delivering frames.
+func Reply(frames ...*pb.InferResponse) Response {
+	return Response{Frames: frames}
+}
+
+// Fail scripts an outcome returning err.
+func Fail(err error) Response {
+	return Response{Err: err}
+}
+
+// After returns a copy of r delayed by d.
+func (r Response) After(d time.Duration) Response {
+	r.Latency = d
+	return r
+}
+
+// JSONResult builds a final frame carrying v encoded as
+// "application/json;schema=<schema>", the format the typed parsers in
+// pkg/types expect. It panics if v cannot be marshalled.
+func JSONResult(schema string, v any) *pb.InferResponse {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("clientmock: marshal %s result: %v", schema, err))
+	}
+	return &pb.InferResponse{
+		IsFinal:    true,
+		Result:     data,
+		ResultMime: "application/json;schema=" + schema,
+	}
+}
+
+// Progress builds an intermediate frame reporting pct percent done under
+// client.ProgressMetaKey. Script it ahead of the final frame:
+//
+//	clientmock.Reply(clientmock.Progress(50), clientmock.JSONResult(...))
+func Progress(pct float64) *pb.InferResponse {
+	return &pb.InferResponse{
+		Meta: map[string]string{client.ProgressMetaKey: strconv.FormatFloat(pct, 'f', -1, 64)},
+	}
+}
+
+// Call records one Infer or InferStream invocation.
+type Call struct {
+	Method  string // "Infer" or "InferStream"
+	Task    string
+	Request *pb.InferRequest
+	At      time.Time
+}
+
+type contractEntry struct {
+	contract types.TaskContract
+	service  string
+}
+
+// Client is a configurable fake implementing client.Client. The zero value
+// is not usable; create one with New. All methods are safe for concurrent
+// use.
+type Client struct {
+	mu        sync.Mutex
+	scripts   map[string][]Response
+	fallback  *Response
+	calls     []Call
+	nodes     []*discovery.NodeInfo
+	contracts map[string]contractEntry
+	watchers  []*nodeWatch
+	cfg       *config.Config
+	started   bool
+	closed    bool
+
+	inferMW  []client.InferMiddleware
+	streamMW []client.StreamMiddleware
+
+	total, failed int64
+	latencyNs     int64
+}
+
+var _ client.Client = (*Client)(nil)
+
+// New returns an empty mock. Unscripted tasks fail with ErrNotScripted.
+func New() *Client {
+	return &Client{
+		scripts:   make(map[string][]Response),
+		contracts: make(map[string]contractEntry),
+		cfg:       config.DefaultConfig(),
+	}
+}
+
+// ErrNotScripted is returned for a task with no scripted response.
+var ErrNotScripted = fmt.Errorf("clientmock: no response scripted")
+
+// On queues responses for task. Calls consume them in order and the last
+// one repeats once the queue is exhausted.
+func (m *Client) On(task string, responses ...Response) *Client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scripts[task] = append(m.scripts[task], responses...)
+	return m
+}
+
+// OnAny sets the response used for tasks without their own script.
+func (m *Client) OnAny(r Response) *Client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fallback = &r
+	return m
+}
+
+// SetNodes replaces the node list returned by GetNodes and notifies
+// watchers.
+func (m *Client) SetNodes(nodes ...*discovery.NodeInfo) *Client {
+	m.mu.Lock()
+	m.nodes = nodes
+	watchers := m.watchers
+	m.mu.Unlock()
+	for _, w := range watchers {
+		if !w.removed.Load() {
+			w.cb(nodes)
+		}
+	}
+	return m
+}
+
+// SetTaskContract makes FindTaskContract report contract for task.
+func (m *Client) SetTaskContract(task string, contract types.TaskContract, service string) *Client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.contracts[task] = contractEntry{contract: contract, service: service}
+	return m
+}
+
+// SetConfig sets the configuration returned by GetConfig.
+func (m *Client) SetConfig(cfg *config.Config) *Client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+	return m
+}
+
+// Calls returns every recorded Infer and InferStream call in order.
+func (m *Client) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallsFor returns the recorded calls for task.
+func (m *Client) CallsFor(task string) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Call
+	for _, c := range m.calls {
+		if c.Task == task {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Reset forgets recorded calls and metrics but keeps scripts.
+func (m *Client) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+	m.total, m.failed, m.latencyNs = 0, 0, 0
+}
+
+// Started reports whether Start has been called.
+func (m *Client) Started() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.started
+}
+
+// Closed reports whether Close has been called.
+func (m *Client) Closed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+// --- client.Client ---
+
+func (m *Client) Start(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = true
+	return nil
+}
+
+func (m *Client) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for _, w := range m.watchers {
+		w.removed.Store(true)
+	}
+	m.watchers = nil
+	return nil
+}
+
+// Infer records the call and returns the next scripted outcome for the
+// request's task, after running registered middlewares.
+func (m *Client) Infer(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	m.mu.Lock()
+	next := client.InferFunc(m.dispatchInfer)
+	for i := len(m.inferMW) - 1; i >= 0; i-- {
+		next = m.inferMW[i](next)
+	}
+	m.mu.Unlock()
+	return next(ctx, req)
+}
+
+// InferStream records the call and delivers the next scripted outcome's
+// frames on the returned channel, after running registered middlewares.
+func (m *Client) InferStream(ctx context.Context, req *pb.InferRequest) (<-chan *pb.InferResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
+	}
+	m.mu.Lock()
+	next := client.StreamFunc(m.dispatchStream)
+	for i := len(m.streamMW) - 1; i >= 0; i-- {
+		next = m.streamMW[i](next)
+	}
+	m.mu.Unlock()
+	return next(ctx, req)
+}
+
+func (m *Client) Use(mw ...client.InferMiddleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inferMW = append(m.inferMW, mw...)
+}
+
+func (m *Client) UseStream(mw ...client.StreamMiddleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.streamMW = append(m.streamMW, mw...)
+}
+
+func (m *Client) GetConfig() *config.Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cfg := *m.cfg
+	return &cfg
+}
+
+func (m *Client) GetNodes() []*discovery.NodeInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*discovery.NodeInfo(nil), m.nodes...)
+}
+
+func (m *Client) FindTaskContract(taskName string) (types.TaskContract, string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.contracts[taskName]
+	return e.contract, e.service, ok
+}
+
+func (m *Client) GetMetrics() *client.ClientMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metrics := &client.ClientMetrics{
+		TotalNodes:      len(m.nodes),
+		ActiveNodes:     m.activeNodesLocked(),
+		TotalRequests:   m.total,
+		SuccessRequests: m.total - m.failed,
+		FailedRequests:  m.failed,
+		LastUpdated:     time.Now(),
+	}
+	if m.total > 0 {
+		metrics.AverageLatency = m.latencyNs / m.total
+		metrics.ErrorRate = float64(m.failed) / float64(m.total)
+	}
+	return metrics
+}
+
+func (m *Client) PoolStats() client.PoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return client.PoolStats{TotalConnections: len(m.nodes), HealthyConnections: m.activeNodesLocked()}
+}
+
+func (m *Client) DiscoveryStats() client.DiscoveryStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return client.DiscoveryStats{ResolvedNodes: len(m.nodes)}
+}
+
+func (m *Client) SystemStats() client.SystemStats {
+	stats := client.SystemStats{
+		Metrics:   m.GetMetrics(),
+		Pool:      m.PoolStats(),
+		Discovery: m.DiscoveryStats(),
+		Nodes:     make(map[discovery.NodeStatus]int),
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, node := range m.nodes {
+		if node != nil {
+			stats.Nodes[node.Status]++
+		}
+	}
+	return stats
+}
+
+// WatchNodes registers cb to be called by SetNodes until the returned func
+// is called or the mock is closed.
+func (m *Client) WatchNodes(cb func([]*discovery.NodeInfo)) (unsubscribe func()) {
+	w := &nodeWatch{cb: cb}
+	m.mu.Lock()
+	m.watchers = append(m.watchers, w)
+	m.mu.Unlock()
+	return func() {
+		w.removed.Store(true)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		// SetNodes may be iterating the old slice.
+		m.watchers = slices.DeleteFunc(slices.Clone(m.watchers), func(x *nodeWatch) bool { return x == w })
+	}
+}
+
+// --- internals ---
+
+type nodeWatch struct {
+	cb      func([]*discovery.NodeInfo)
+	removed atomic.Bool
+}
+
+func (m *Client) activeNodesLocked() int {
+	n := 0
+	for _, node := range m.nodes {
+		if node != nil && node.IsActive() {
+			n++
+		}
+	}
+	return n
+}
+
+// next records a call and pops the response scripted for its task.
+func (m *Client) next(method string, req *pb.InferRequest) (Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Task: req.Task, Request: req, At: time.Now()})
+
+	queue := m.scripts[req.Task]
+	switch {
+	case len(queue) > 1:
+		m.scripts[req.Task] = queue[1:]
+		return queue[0], nil
+	case len(queue) == 1:
+		return queue[0], nil
+	case m.fallback != nil:
+		return *m.fallback, nil
+	default:
+		return Response{}, fmt.Errorf("%w for task %q", ErrNotScripted, req.Task)
+	}
+}
+
+func (m *Client) record(start time.Time, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.total++
+	m.latencyNs += int64(time.Since(start))
+	if err != nil {
+		m.failed++
+	}
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Client) dispatchInfer(ctx context.Context, req *pb.InferRequest) (resp *pb.InferResponse, err error) {
+	start := time.Now()
+	defer func() { m.record(start, err) }()
+
+	r, err := m.next("Infer", req)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx, r.Latency); err != nil {
+		return nil, err
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if len(r.Frames) == 0 {
+		return &pb.InferResponse{CorrelationId: req.CorrelationId, IsFinal: true}, nil
+	}
+	if h := client.ProgressHandlerFromContext(ctx); h != nil {
+		for _, frame := range r.Frames[:len(r.Frames)-1] {
+			if pct, ok := client.Progress(frame); ok && !frame.IsFinal {
+				h(pct, frame.Meta)
+			}
+		}
+	}
+	return r.Frames[len(r.Frames)-1], nil
+}
+
+func (m *Client) dispatchStream(ctx context.Context, req *pb.InferRequest) (<-chan *pb.InferResponse, error) {
+	start := time.Now()
+	r, err := m.next("InferStream", req)
+	if err == nil {
+		err = wait(ctx, r.Latency)
+	}
+	if err == nil {
+		err = r.Err
+	}
+	if err != nil {
+		m.record(start, err)
+		return nil, err
+	}
+
+	out := make(chan *pb.InferResponse, len(r.Frames))
+	for _, frame := range r.Frames {
+		out <- frame
+	}
+	close(out)
+	m.record(start, nil)
+	return out, nil
+}
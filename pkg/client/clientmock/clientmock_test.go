@@ -0,0 +1,148 @@
+package clientmock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// recordingT captures assertion failures instead of failing the test.
+type recordingT struct{ errors []string }
+
+func (r *recordingT) Helper() {}
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func embedRequest(text string) *pb.InferRequest {
+	return types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed(text).Build()
+}
+
+func TestScriptedResponsesAreConsumedInOrder(t *testing.T) {
+	boom := errors.New("node exploded")
+	m := New().On(types.TaskSemanticTextEmbed,
+		Reply(JSONResult("embedding_v1", types.EmbeddingV1{Vector: []float32{1, 0}, Dim: 2})),
+		Fail(boom),
+	)
+
+	resp, err := m.Infer(context.Background(), embedRequest("a"))
+	if err != nil {
+		t.Fatalf("first Infer: %v", err)
+	}
+	emb, err := types.ParseInferResponse(resp).AsEmbeddingResponse()
+	if err != nil || emb.Dim != 2 {
+		t.Fatalf("embedding = %+v, %v", emb, err)
+	}
+	// The last scripted response repeats.
+	for i := 0; i < 2; i++ {
+		if _, err := m.Infer(context.Background(), embedRequest("b")); !errors.Is(err, boom) {
+			t.Fatalf("call %d error = %v, want %v", i+2, err, boom)
+		}
+	}
+
+	metrics := m.GetMetrics()
+	if metrics.TotalRequests != 3 || metrics.FailedRequests != 2 {
+		t.Fatalf("metrics = %+v, want 3 total / 2 failed", metrics)
+	}
+}
+
+func TestUnscriptedTaskFails(t *testing.T) {
+	_, err := New().Infer(context.Background(), embedRequest("a"))
+	if !errors.Is(err, ErrNotScripted) {
+		t.Fatalf("error = %v, want ErrNotScripted", err)
+	}
+}
+
+func TestLatencyHonoursContext(t *testing.T) {
+	m := New().On(types.TaskSemanticTextEmbed, Reply().After(time.Minute))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := m.Infer(ctx, embedRequest("a")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestInferStreamDeliversFrames(t *testing.T) {
+	m := New().On("vlm", Reply(
+		&pb.InferResponse{Result: []byte("partial")},
+		&pb.InferResponse{Result: []byte("done"), IsFinal: true},
+	))
+	ch, err := m.InferStream(context.Background(), types.NewInferRequest("vlm").Build())
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	var got []string
+	for frame := range ch {
+		got = append(got, string(frame.Result))
+	}
+	if len(got) != 2 || got[1] != "done" {
+		t.Fatalf("frames = %v", got)
+	}
+	if calls := m.Calls(); len(calls) != 1 || calls[0].Method != "InferStream" {
+		t.Fatalf("calls = %+v", calls)
+	}
+}
+
+func TestMiddlewaresWrapScriptedCalls(t *testing.T) {
+	m := New().OnAny(Reply())
+	var order []string
+	tag := func(name string) client.InferMiddleware {
+		return func(next client.InferFunc) client.InferFunc {
+			return func(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+				order = append(order, name)
+				return next(ctx, req)
+			}
+		}
+	}
+	m.Use(tag("outer"), tag("inner"))
+
+	if _, err := m.Infer(context.Background(), embedRequest("a")); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Fatalf("middleware order = %v", order)
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	m := New().OnAny(Reply())
+	_, _ = m.Infer(context.Background(), embedRequest("hello"))
+
+	m.AssertInferCalledWith(t, types.TaskSemanticTextEmbed, func(p []byte) bool { return string(p) == "hello" })
+	m.AssertCallCount(t, types.TaskSemanticTextEmbed, 1)
+	m.AssertNotCalled(t, types.TaskOCR)
+
+	rt := &recordingT{}
+	m.AssertInferCalledWith(rt, types.TaskSemanticTextEmbed, func(p []byte) bool { return string(p) == "bye" })
+	m.AssertInferCalledWith(rt, types.TaskOCR, nil)
+	m.AssertCallCount(rt, types.TaskSemanticTextEmbed, 2)
+	if len(rt.errors) != 3 {
+		t.Fatalf("recorded failures = %q, want 3", rt.errors)
+	}
+}
+
+func TestInferPassesScriptedProgress(t *testing.T) {
+	m := New().On("ocr", Reply(
+		Progress(30),
+		Progress(80),
+		&pb.InferResponse{Result: []byte("text"), IsFinal: true},
+	))
+	var got []float64
+	ctx := client.WithProgressHandler(context.Background(), func(pct float64, _ map[string]string) {
+		got = append(got, pct)
+	})
+	resp, err := m.Infer(ctx, types.NewInferRequest("ocr").Build())
+	if err != nil || string(resp.Result) != "text" {
+		t.Fatalf("Infer = %v, %v", resp, err)
+	}
+	if len(got) != 2 || got[0] != 30 || got[1] != 80 {
+		t.Fatalf("progress = %v, want [30 80]", got)
+	}
+}
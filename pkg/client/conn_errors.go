@@ -0,0 +1,57 @@
+package client
+
+import (
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+// classifyConnError maps a failed connection attempt to addr onto a
+// LumenError code, so callers can tell a node that is briefly unreachable
+// (CONNECTION_FAILED, retryable) from one that will never connect as
+// configured: a rejected TLS handshake (UNAUTHORIZED) or an address that
+// cannot be dialled (INVALID). utils.IsRetryable reports the difference.
+func classifyConnError(addr string, err error) *utils.LumenError {
+	details := map[string]interface{}{"address": addr}
+	msg := err.Error()
+	lower := strings.ToLower(msg)
+
+	var addrErr *net.AddrError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostname), errors.As(err, &invalidCert),
+		strings.Contains(lower, "authentication handshake failed"),
+		strings.Contains(lower, "x509:"),
+		strings.Contains(lower, "tls:"):
+		details["retryable"] = false
+		return utils.Wrap(err, utils.ErrCodeUnauthorized, "TLS handshake rejected by "+addr, details)
+	case errors.As(err, &addrErr),
+		strings.Contains(lower, "missing port in address"),
+		strings.Contains(lower, "too many colons in address"),
+		strings.Contains(lower, "invalid port"),
+		strings.Contains(lower, "unknown port"):
+		details["retryable"] = false
+		return utils.Wrap(err, utils.ErrCodeInvalid, "malformed node address "+addr, details)
+	default:
+		details["retryable"] = true
+		return utils.Wrap(err, utils.ErrCodeConnectionFailed, "cannot connect to "+addr, details)
+	}
+}
+
+// nodeError converts a classified connection error for NodeInfo and
+// PoolStats.
+func nodeError(lumErr *utils.LumenError, at time.Time) *discovery.NodeError {
+	return &discovery.NodeError{
+		Code:      string(lumErr.Code),
+		Message:   lumErr.Error(),
+		Retryable: utils.IsRetryable(lumErr),
+		At:        at,
+	}
+}
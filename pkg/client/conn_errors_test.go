@@ -0,0 +1,78 @@
+package client
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"go.uber.org/zap"
+)
+
+func TestClassifyConnError(t *testing.T) {
+	_, missingPort := net.Dial("tcp", "10.0.0.1")
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	_, refused := net.Dial("tcp", closedAddr)
+
+	tests := []struct {
+		name      string
+		err       error
+		code      utils.ErrorCode
+		retryable bool
+	}{
+		{"refused", fmt.Errorf("connection error: desc = %q: %w", "transport: Error while dialing", refused), utils.ErrCodeConnectionFailed, true},
+		{"missing port", fmt.Errorf("transport: Error while dialing: %w", missingPort), utils.ErrCodeInvalid, false},
+		{"unknown authority", fmt.Errorf("transport: authentication handshake failed: %w", x509.UnknownAuthorityError{}), utils.ErrCodeUnauthorized, false},
+		{"plaintext server", fmt.Errorf("transport: authentication handshake failed: tls: first record does not look like a TLS handshake"), utils.ErrCodeUnauthorized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lumErr := classifyConnError("10.0.0.1:50051", tt.err)
+			if lumErr.Code != tt.code || utils.IsRetryable(lumErr) != tt.retryable {
+				t.Fatalf("code = %s retryable = %v, want %s %v (err: %v)", lumErr.Code, utils.IsRetryable(lumErr), tt.code, tt.retryable, tt.err)
+			}
+			if lumErr.Unwrap() != tt.err {
+				t.Fatal("classified error does not wrap the dial error")
+			}
+			if ne := nodeError(lumErr, time.Now()); ne.Code != string(tt.code) || ne.Retryable != tt.retryable {
+				t.Fatalf("node error = %+v", ne)
+			}
+		})
+	}
+}
+
+func TestPoolRecordsLastConnectionError(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := closed.Addr().String()
+	closed.Close()
+
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{ConnectTimeout: time.Second})
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{discoveredNode("node-1", addr, "ocr")}}); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer pool.Close()
+
+	waitUntil(t, func() bool { return len(pool.Stats().LastErrors) == 1 })
+	lastErr := pool.Stats().LastErrors["local-node-1"]
+	if lastErr.Code != string(utils.ErrCodeConnectionFailed) || !lastErr.Retryable || lastErr.At.IsZero() {
+		t.Fatalf("last error = %+v, want a retryable CONNECTION_FAILED", lastErr)
+	}
+	infos := pool.NodeInfos()
+	if len(infos) != 1 || infos[0].LastError == nil || infos[0].LastError.Code != lastErr.Code {
+		t.Fatalf("node infos = %+v, want the last error attached", infos)
+	}
+	if cloned := discovery.CloneNode(infos[0]); cloned.LastError == nil || cloned.LastError == infos[0].LastError {
+		t.Fatal("CloneNode did not deep-copy LastError")
+	}
+}
@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/tasks"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Cost hints a node may advertise. P50MsLimitKey and
+// PreferredChunkBytesKey are IOTask limits for one task;
+// PreferredChunkBytesKey and TaskP50ExtraKey are also read from the
+// capability Extra, where a task's own limits win. See
+// config.CostHintsConfig for how Infer uses them.
+const (
+	// P50MsLimitKey is the node's median time in milliseconds to serve
+	// the task, e.g. "850" or "12.5".
+	P50MsLimitKey = "p50_ms"
+	// PreferredChunkBytesKey is the chunk size in bytes the node wants a
+	// chunked payload split into.
+	PreferredChunkBytesKey = "preferred_chunk_bytes"
+)
+
+// TaskP50ExtraKey is the capability Extra key under which a node
+// advertises its p50 in milliseconds for task, e.g.
+// "task.vlm_generate.p50_ms".
+func TaskP50ExtraKey(task string) string {
+	return "task." + task + "." + P50MsLimitKey
+}
+
+// Response Meta keys recording a timeout Infer derived from cost hints,
+// and the chunk size a node asked for.
+const (
+	// TimeoutMetaKey is the derived timeout in milliseconds.
+	TimeoutMetaKey = "lumen.timeout_ms"
+	// TimeoutSourceMetaKey says where the timeout came from:
+	// TimeoutSourceNodeP50.
+	TimeoutSourceMetaKey = "lumen.timeout_source"
+	// NodeP50MetaKey is the p50 in milliseconds the timeout was derived
+	// from.
+	NodeP50MetaKey = "lumen.node_p50_ms"
+	// ChunkBytesSourceMetaKey is ChunkSourceNode when ChunkBytesMetaKey is
+	// the node's preferred chunk size rather than chunk.max_chunk_bytes.
+	ChunkBytesSourceMetaKey = "lumen.chunk_bytes_source"
+)
+
+// Values of TimeoutSourceMetaKey and ChunkBytesSourceMetaKey.
+const (
+	TimeoutSourceNodeP50 = "node_p50"
+	ChunkSourceNode      = "node"
+)
+
+// taskCosts are the cost hints a node advertises for one task; zero
+// fields were not advertised.
+type taskCosts struct {
+	P50        time.Duration
+	ChunkBytes int
+}
+
+// costsForTask reads the cost hints for task from a node's capabilities.
+// A task's IOTask limits win over the capability Extra. When several
+// capabilities serve the task, the slowest p50 and the smallest chunk size
+// are kept.
+func costsForTask(caps []*pb.Capability, task string) taskCosts {
+	var c taskCosts
+	keep := func(p50 time.Duration, chunk int) {
+		if p50 > c.P50 {
+			c.P50 = p50
+		}
+		if chunk > 0 && (c.ChunkBytes == 0 || chunk < c.ChunkBytes) {
+			c.ChunkBytes = chunk
+		}
+	}
+	for _, cap := range caps {
+		extra := cap.GetExtra()
+		for _, t := range cap.GetTasks() {
+			if !tasks.Match(task, t.GetName()) {
+				continue
+			}
+			p50 := parseMillis(t.GetLimits()[P50MsLimitKey])
+			if p50 == 0 {
+				p50 = parseMillis(extra[TaskP50ExtraKey(t.GetName())])
+			}
+			chunk := parseBytes(t.GetLimits()[PreferredChunkBytesKey])
+			if chunk == 0 {
+				chunk = parseBytes(extra[PreferredChunkBytesKey])
+			}
+			keep(p50, chunk)
+		}
+	}
+	return c
+}
+
+// parseMillis parses a positive number of milliseconds, or returns 0.
+func parseMillis(s string) time.Duration {
+	ms, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || ms <= 0 || ms > float64(24*time.Hour/time.Millisecond) {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// parseBytes parses a positive byte count, or returns 0.
+func parseBytes(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n
+}
+
+// deriveTimeout is p50 times cfg.TimeoutMultiplier, within
+// [cfg.MinTimeout, cfg.MaxTimeout]; 0 when p50 is.
+func deriveTimeout(p50 time.Duration, cfg config.CostHintsConfig) time.Duration {
+	if p50 <= 0 {
+		return 0
+	}
+	mult, lo, hi := cfg.TimeoutMultiplier, cfg.MinTimeout, cfg.MaxTimeout
+	if mult <= 0 {
+		mult = 10
+	}
+	if lo <= 0 {
+		lo = time.Second
+	}
+	if hi <= 0 {
+		hi = 10 * time.Minute
+	}
+	d := time.Duration(float64(p50) * mult)
+	return min(max(d, lo), hi)
+}
+
+// deriveChunkBytes is a node's preferred chunk size within
+// [cfg.MinChunkBytes, cfg.MaxChunkBytes]; 0 when it prefers none.
+func deriveChunkBytes(preferred int, cfg config.CostHintsConfig) int {
+	if preferred <= 0 {
+		return 0
+	}
+	lo, hi := cfg.MinChunkBytes, cfg.MaxChunkBytes
+	if lo <= 0 {
+		lo = 16 << 10
+	}
+	if hi <= 0 || hi > config.MaxMessageBytes {
+		hi = config.MaxMessageBytes
+	}
+	return min(max(preferred, lo), hi)
+}
+
+// nodeCosts returns the cost hints of the node with key for task, or none
+// when cost hints are disabled.
+func (c *LumenClient) nodeCosts(key, task string) taskCosts {
+	if !c.config.CostHints.Enabled || key == "" {
+		return taskCosts{}
+	}
+	return costsForTask(c.pool.nodeCapabilities(key), task)
+}
+
+// rechunkForNode splits the payload again at the chunk size the picked
+// node prefers, recording the new split in info and the chunk info slot. It
+// returns chunks unchanged when the node prefers no size or its size is
+// the one already used.
+func (c *LumenClient) rechunkForNode(ctx context.Context, req *pb.InferRequest, chunks [][]byte, info *ChunkInfo, costs taskCosts) [][]byte {
+	size := deriveChunkBytes(costs.ChunkBytes, c.config.CostHints)
+	if size == 0 || size == info.ChunkBytes {
+		return chunks
+	}
+	cfg := c.config.Chunk
+	cfg.MaxChunkBytes = size
+	rechunked, err := ChunkPayloadFor(req.Payload, req.PayloadMime, cfg)
+	if err != nil {
+		utils.LoggerFrom(ctx).Debug("keeping the configured chunk size",
+			zap.Int("node_chunk_bytes", size),
+			zap.Error(err),
+		)
+		return chunks
+	}
+	info.Chunks = len(rechunked)
+	info.ChunkBytes = min(size, info.PayloadBytes)
+	info.Source = ChunkSourceNode
+	if slot := chunkInfoSlot(ctx); slot != nil {
+		*slot = *info
+	}
+	return rechunked
+}
+
+// derivedTimeout is a timeout Infer set from the picked node's p50 for a
+// call that had no deadline of its own.
+type derivedTimeout struct {
+	limit time.Duration
+	p50   time.Duration
+	timer *time.Timer
+	fired atomic.Bool
+}
+
+// startDerivedTimeout cancels the call through cancel once the timeout
+// derived from costs expires, counted from when the node was picked. It
+// returns nil, and sets nothing, when ctx has a deadline or the node
+// advertised no p50.
+func (c *LumenClient) startDerivedTimeout(ctx context.Context, cancel context.CancelFunc, costs taskCosts) *derivedTimeout {
+	if _, ok := ctx.Deadline(); ok {
+		return nil
+	}
+	limit := deriveTimeout(costs.P50, c.config.CostHints)
+	if limit == 0 {
+		return nil
+	}
+	d := &derivedTimeout{limit: limit, p50: costs.P50}
+	d.timer = time.AfterFunc(limit, func() {
+		d.fired.Store(true)
+		cancel()
+	})
+	phaseTimerFrom(ctx).setTimeout(limit)
+	return d
+}
+
+// stop ends the timeout once the call is over. It is a no-op on nil.
+func (d *derivedTimeout) stop() {
+	if d != nil {
+		d.timer.Stop()
+	}
+}
+
+// expired reports whether the timeout cut the call short.
+func (d *derivedTimeout) expired() bool {
+	return d != nil && d.fired.Load()
+}
+
+// err returns err as a DeadlineExceeded status naming the derived
+// timeout when that timeout cut the call short, and err otherwise.
+func (d *derivedTimeout) err(err error) error {
+	if err == nil || !d.expired() {
+		return err
+	}
+	return status.Errorf(codes.DeadlineExceeded,
+		"timeout of %s derived from the node's p50 of %s expired: %v", d.limit, d.p50, err)
+}
+
+// setMeta records the timeout on a response. It is a no-op on nil.
+func (d *derivedTimeout) setMeta(resp *pb.InferResponse) {
+	if d == nil || resp == nil {
+		return
+	}
+	if resp.Meta == nil {
+		resp.Meta = make(map[string]string, 3)
+	}
+	resp.Meta[TimeoutMetaKey] = strconv.FormatInt(d.limit.Milliseconds(), 10)
+	resp.Meta[TimeoutSourceMetaKey] = TimeoutSourceNodeP50
+	resp.Meta[NodeP50MetaKey] = strconv.FormatFloat(float64(d.p50)/float64(time.Millisecond), 'f', -1, 64)
+}
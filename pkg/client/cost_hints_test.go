@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestCostsForTask(t *testing.T) {
+	caps := []*pb.Capability{
+		{
+			Extra: map[string]string{
+				TaskP50ExtraKey("vlm_generate"): "1200",
+				TaskP50ExtraKey("ocr"):          "40.5",
+				PreferredChunkBytesKey:          "65536",
+			},
+			Tasks: []*pb.IOTask{
+				{Name: "vlm_generate"},
+				{Name: "ocr", Limits: map[string]string{P50MsLimitKey: "30", PreferredChunkBytesKey: "8192"}},
+				{Name: "embed"},
+			},
+		},
+		{
+			Extra: map[string]string{TaskP50ExtraKey("embed"): "fast"},
+			Tasks: []*pb.IOTask{{Name: "embed", Limits: map[string]string{P50MsLimitKey: "-3"}}},
+		},
+	}
+	tests := []struct {
+		task string
+		want taskCosts
+	}{
+		{task: "vlm_generate", want: taskCosts{P50: 1200 * time.Millisecond, ChunkBytes: 65536}},
+		// The task's own limits win over the capability Extra.
+		{task: "ocr", want: taskCosts{P50: 30 * time.Millisecond, ChunkBytes: 8192}},
+		// Malformed hints are ignored; the first capability's chunk size
+		// still applies.
+		{task: "embed", want: taskCosts{ChunkBytes: 65536}},
+		{task: "asr", want: taskCosts{}},
+	}
+	for _, tt := range tests {
+		if got := costsForTask(caps, tt.task); got != tt.want {
+			t.Errorf("costsForTask(%s) = %+v, want %+v", tt.task, got, tt.want)
+		}
+	}
+
+	two := []*pb.Capability{
+		{Tasks: []*pb.IOTask{{Name: "embed", Limits: map[string]string{P50MsLimitKey: "100", PreferredChunkBytesKey: "4096"}}}},
+		{Tasks: []*pb.IOTask{{Name: "embed", Limits: map[string]string{P50MsLimitKey: "250", PreferredChunkBytesKey: "65536"}}}},
+	}
+	if got := costsForTask(two, "embed"); got != (taskCosts{P50: 250 * time.Millisecond, ChunkBytes: 4096}) {
+		t.Errorf("two capabilities = %+v, want the slowest p50 and smallest chunk", got)
+	}
+}
+
+func TestDeriveTimeoutAndChunkBytes(t *testing.T) {
+	cfg := config.CostHintsConfig{TimeoutMultiplier: 4, MinTimeout: time.Second, MaxTimeout: time.Minute, MinChunkBytes: 1024, MaxChunkBytes: 1 << 20}
+	timeouts := []struct {
+		p50, want time.Duration
+	}{
+		{0, 0},
+		{100 * time.Millisecond, time.Second}, // raised to MinTimeout
+		{750 * time.Millisecond, 3 * time.Second}, // 4x
+		{time.Minute, time.Minute},                // capped at MaxTimeout
+	}
+	for _, tt := range timeouts {
+		if got := deriveTimeout(tt.p50, cfg); got != tt.want {
+			t.Errorf("deriveTimeout(%s) = %s, want %s", tt.p50, got, tt.want)
+		}
+	}
+	if got := deriveTimeout(2*time.Second, config.CostHintsConfig{}); got != 20*time.Second {
+		t.Errorf("deriveTimeout with zero config = %s, want the 10x default", got)
+	}
+
+	chunks := []struct{ preferred, want int }{
+		{0, 0},
+		{100, 1024},
+		{64 << 10, 64 << 10},
+		{8 << 20, 1 << 20},
+	}
+	for _, tt := range chunks {
+		if got := deriveChunkBytes(tt.preferred, cfg); got != tt.want {
+			t.Errorf("deriveChunkBytes(%d) = %d, want %d", tt.preferred, got, tt.want)
+		}
+	}
+	if got := deriveChunkBytes(16<<20, config.CostHintsConfig{}); got != config.MaxMessageBytes {
+		t.Errorf("deriveChunkBytes with zero config = %d, want MaxMessageBytes", got)
+	}
+}
+
+// costHintServer advertises limits for its task, records the size of each
+// request message and answers once the stream is closed, or never if hang
+// is set.
+type costHintServer struct {
+	testInferenceServer
+	limits map[string]string
+	hang   bool
+
+	mu    sync.Mutex
+	sizes []int
+}
+
+func (s *costHintServer) hintedCapability() *pb.Capability {
+	cap := s.capability()
+	for _, task := range cap.Tasks {
+		task.Limits = s.limits
+	}
+	return cap
+}
+
+func (s *costHintServer) GetCapabilities(context.Context, *emptypb.Empty) (*pb.Capability, error) {
+	return s.hintedCapability(), nil
+}
+
+func (s *costHintServer) StreamCapabilities(_ *emptypb.Empty, stream grpc.ServerStreamingServer[pb.Capability]) error {
+	return stream.Send(s.hintedCapability())
+}
+
+func (s *costHintServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	var first *pb.InferRequest
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first == nil {
+			first = req
+		}
+		s.mu.Lock()
+		s.sizes = append(s.sizes, len(req.Payload))
+		s.mu.Unlock()
+	}
+	if s.hang {
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}
+	return stream.Send(&pb.InferResponse{CorrelationId: first.CorrelationId, IsFinal: true})
+}
+
+func (s *costHintServer) messageSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.sizes...)
+}
+
+func startCostHintClient(t *testing.T, srv *costHintServer) *LumenClient {
+	t.Helper()
+	c := startClientFor(t, srv)
+	waitUntil(t, func() bool { return len(c.pool.nodeCapabilities("local-node-1")) > 0 })
+	return c
+}
+
+func TestInferDerivesTimeoutFromNodeP50(t *testing.T) {
+	srv := &costHintServer{
+		testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}},
+		limits:              map[string]string{P50MsLimitKey: "20"},
+		hang:                true,
+	}
+	c := startCostHintClient(t, srv)
+	c.config.CostHints = config.CostHintsConfig{Enabled: true, TimeoutMultiplier: 5, MinTimeout: time.Millisecond, MaxTimeout: time.Minute}
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+
+	start := time.Now()
+	result, err := c.InferDetailed(context.Background(), req)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("call took %s, want the 100ms derived timeout", elapsed)
+	}
+	var derr *DeadlineError
+	if !errors.As(err, &derr) || status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("err = %v, want a DeadlineError with DeadlineExceeded", err)
+	}
+	if derr.Limit != 100*time.Millisecond || result.Timeout != 100*time.Millisecond {
+		t.Fatalf("limit = %s, result timeout = %s, want 100ms (20ms p50 x5)", derr.Limit, result.Timeout)
+	}
+	if !strings.Contains(err.Error(), "derived from the node's p50 of 20ms") {
+		t.Fatalf("err = %v, want it to say where the timeout came from", err)
+	}
+	if got := c.GetMetrics().Failures.Timeout; got != 1 {
+		t.Fatalf("timeout failures = %d, want 1", got)
+	}
+
+	// A caller's own deadline wins over the hint.
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	result, _ = c.InferDetailed(ctx, req)
+	if result.Timeout != 0 || time.Since(start) < 300*time.Millisecond {
+		t.Fatalf("derived timeout %s applied to a call with a deadline", result.Timeout)
+	}
+}
+
+func TestInferChunksAtNodePreferredSize(t *testing.T) {
+	srv := &costHintServer{
+		testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}},
+		limits:              map[string]string{P50MsLimitKey: "50", PreferredChunkBytesKey: "400"},
+	}
+	c := startCostHintClient(t, srv)
+	c.config.Chunk = config.ChunkConfig{EnableAuto: true, Threshold: 1000, MaxChunkBytes: 1000}
+	c.config.CostHints = config.CostHintsConfig{Enabled: true, MinChunkBytes: 100}
+
+	req := &pb.InferRequest{Task: types.TaskSemanticTextEmbed, Payload: []byte(strings.Repeat("a", 2000)), PayloadMime: "text/plain"}
+	result, err := c.InferDetailed(context.Background(), req)
+	if err != nil {
+		t.Fatalf("InferDetailed: %v", err)
+	}
+	if got := srv.messageSizes(); len(got) != 5 || got[0] != 400 {
+		t.Fatalf("node received %v, want five 400-byte chunks", got)
+	}
+	if result.Chunking.Chunks != 5 || result.Chunking.ChunkBytes != 400 || result.Chunking.Source != ChunkSourceNode {
+		t.Fatalf("chunking = %+v, want 5 x 400 bytes from the node", result.Chunking)
+	}
+	meta := result.Response.Meta
+	if meta[ChunkBytesSourceMetaKey] != ChunkSourceNode || meta[ChunkBytesMetaKey] != "400" {
+		t.Fatalf("meta = %v, want the node's chunk size recorded", meta)
+	}
+	if meta[TimeoutMetaKey] != "1000" || meta[TimeoutSourceMetaKey] != TimeoutSourceNodeP50 || meta[NodeP50MetaKey] != "50" {
+		t.Fatalf("meta = %v, want the 1s floor on 50ms x10 recorded", meta)
+	}
+
+	// Without hints, or with them disabled, the configured size applies.
+	c.config.CostHints.Enabled = false
+	result, err = c.InferDetailed(context.Background(), req)
+	if err != nil {
+		t.Fatalf("InferDetailed: %v", err)
+	}
+	if result.Chunking.ChunkBytes != 1000 || result.Chunking.Source != "" || result.Timeout != 0 {
+		t.Fatalf("chunking = %+v, timeout = %s with cost hints disabled", result.Chunking, result.Timeout)
+	}
+	if _, ok := result.Response.Meta[TimeoutMetaKey]; ok {
+		t.Fatal("timeout recorded with cost hints disabled")
+	}
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+// nodeDrain is one drained node. idle is set once its NodeDrained event has
+// fired, so the event fires once per drain.
+type nodeDrain struct {
+	since time.Time
+	timer utils.Timer
+	idle  bool
+}
+
+// drain takes the node with key out of selection. RPCs already picked for it
+// run to completion, and so do pinned RPCs joining them. A positive timeout
+// undrains the node once it elapses. Draining an already drained node keeps
+// its start time and replaces the timeout. The drain outlives the node
+// leaving discovery, so a node restarted under the same ID stays drained.
+func (r *nodeRegistry) drain(key string, timeout time.Duration) error {
+	r.mu.RLock()
+	rn := r.nodes[key]
+	r.mu.RUnlock()
+	if rn == nil {
+		return utils.NodeNotFoundError(key)
+	}
+
+	r.drainMu.Lock()
+	if r.drains == nil {
+		r.drains = make(map[string]*nodeDrain)
+	}
+	d := r.drains[key]
+	if d == nil {
+		d = &nodeDrain{since: r.now()}
+		r.drains[key] = d
+	} else if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if timeout > 0 {
+		d.timer = utils.ClockOrReal(r.clock).AfterFunc(timeout, func() { r.expireDrain(key, d) })
+	}
+	r.publishDrainsLocked()
+	r.drainMu.Unlock()
+
+	r.refreshPicker()
+	if rn.streams.inFlight() == 0 {
+		r.nodeIdle(key)
+	}
+	return nil
+}
+
+// undrain returns the node with key to selection. It is a no-op for a node
+// that is not drained.
+func (r *nodeRegistry) undrain(key string) {
+	r.drainMu.Lock()
+	d, ok := r.drains[key]
+	if ok {
+		if d.timer != nil {
+			d.timer.Stop()
+		}
+		delete(r.drains, key)
+		r.publishDrainsLocked()
+	}
+	r.drainMu.Unlock()
+	if ok {
+		r.refreshPicker()
+	}
+}
+
+// expireDrain undrains key when its drain timeout fires, unless the node
+// was undrained, or drained again, in the meantime.
+func (r *nodeRegistry) expireDrain(key string, d *nodeDrain) {
+	r.drainMu.Lock()
+	current := r.drains[key] == d
+	if current {
+		delete(r.drains, key)
+		r.publishDrainsLocked()
+	}
+	r.drainMu.Unlock()
+	if current {
+		r.refreshPicker()
+	}
+}
+
+func (r *nodeRegistry) publishDrainsLocked() {
+	draining := make(map[string]time.Time, len(r.drains))
+	for key, d := range r.drains {
+		draining[key] = d.since
+	}
+	r.draining.Store(&draining)
+}
+
+// drainingNodes returns when each drained node was drained, keyed by node.
+// The map is shared and must not be modified.
+func (r *nodeRegistry) drainingNodes() map[string]time.Time {
+	if m := r.draining.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+// nodeIdle is called when the node with key has no RPC in flight. If the
+// node is drained it fires onDrained, once per drain.
+func (r *nodeRegistry) nodeIdle(key string) {
+	if _, ok := r.drainingNodes()[key]; !ok {
+		return
+	}
+	r.drainMu.Lock()
+	d := r.drains[key]
+	fire := d != nil && !d.idle
+	if fire {
+		d.idle = true
+	}
+	r.drainMu.Unlock()
+	if fire && r.onDrained != nil {
+		r.onDrained(discovery.NodeDrained{NodeID: key, Since: d.since, At: r.now()})
+	}
+}
+
+// refreshPicker rebuilds the balancer's picker after a drain change, so RPCs
+// waiting for a node pick again and node watchers see the new status.
+func (r *nodeRegistry) refreshPicker() {
+	picker := r.picker.Load()
+	if picker == nil || picker.balancer == nil {
+		return
+	}
+	lb := picker.balancer
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.closed {
+		return
+	}
+	lb.syncRegistryLocked()
+	lb.rebuildPickerLocked()
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+)
+
+func nodeInfoByID(infos []*discovery.NodeInfo, id string) *discovery.NodeInfo {
+	for _, info := range infos {
+		if info.ID == id {
+			return info
+		}
+	}
+	return nil
+}
+
+func TestPickSkipsDrainingNodes(t *testing.T) {
+	var nodes []*subConnState
+	for _, name := range []string{"a", "b"} {
+		nodes = append(nodes, &subConnState{
+			sc:       &namedSubConn{name: "local-" + name},
+			identity: discovery.NewNodeIdentity("local", name),
+			state:    connectivity.Ready,
+			tasks:    []string{"ocr"},
+		})
+	}
+	reg := explainFixture(nodes...)
+	picker := reg.picker.Load()
+	picker.balancer = &lumenBalancer{registry: reg}
+	reg.drains = map[string]*nodeDrain{"local-a": {since: time.Now()}}
+	reg.publishDrainsLocked()
+
+	info := balancer.PickInfo{Ctx: WithTask(context.Background(), "ocr")}
+	for i := 0; i < 4; i++ {
+		res, err := picker.Pick(info)
+		if err != nil {
+			t.Fatalf("Pick: %v", err)
+		}
+		if name := res.SubConn.(*namedSubConn).name; name != "local-b" {
+			t.Fatalf("picked %s, want the node that is not draining", name)
+		}
+	}
+	if c := candidateByID(reg.explainSelection("ocr", time.Now()), "local-a"); c.Eligible || c.Reason != discovery.SelectionDraining {
+		t.Fatalf("explained drained node = %+v, want reason draining", c)
+	}
+
+	// A pinned RPC joins streams already open to the drained node.
+	if res, err := picker.Pick(balancer.PickInfo{Ctx: withPinnedNode(info.Ctx, "local-a")}); err != nil || res.SubConn.(*namedSubConn).name != "local-a" {
+		t.Fatalf("pinned Pick = %v, %v; want local-a", res.SubConn, err)
+	}
+
+	reg.drains["local-b"] = &nodeDrain{since: time.Now()}
+	reg.publishDrainsLocked()
+	var noNode atomic.Bool
+	_, err := picker.Pick(balancer.PickInfo{Ctx: withNoNodeFlag(info.Ctx, &noNode)})
+	if err == nil || !strings.Contains(err.Error(), "draining") {
+		t.Fatalf("Pick with every node drained = %v, want a draining error", err)
+	}
+	if !noNode.Load() {
+		t.Fatal("Pick with every node drained did not allow local fallback")
+	}
+}
+
+func TestDrainNodeWaitsForInFlightRequests(t *testing.T) {
+	c, srv := startHangingClient(t)
+	drained := make(chan discovery.NodeDrained, 1)
+	c.WatchDrains(func(ev discovery.NodeDrained) { drained <- ev })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := c.Infer(ctx, embedRequest())
+		errCh <- err
+	}()
+	waitStarted(t, srv)
+
+	if err := c.DrainNode("local-node-1"); err != nil {
+		t.Fatalf("DrainNode: %v", err)
+	}
+	info := nodeInfoByID(c.pool.NodeInfos(), "local-node-1")
+	if info == nil || info.Status != discovery.NodeStatusDraining || info.InFlight != 1 || info.DrainingSince.IsZero() {
+		t.Fatalf("node info = %+v, want draining with 1 request in flight", info)
+	}
+	select {
+	case ev := <-drained:
+		t.Fatalf("drained event %+v fired with a request in flight", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// New requests are not sent to the drained node.
+	if _, err := c.Infer(context.Background(), embedRequest()); err == nil || !strings.Contains(err.Error(), "draining") {
+		t.Fatalf("Infer while draining = %v, want a draining error", err)
+	}
+
+	cancel()
+	<-errCh
+	select {
+	case ev := <-drained:
+		if ev.NodeID != "local-node-1" || ev.Since != info.DrainingSince {
+			t.Fatalf("drained event = %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no drained event after the last request finished")
+	}
+	if info := nodeInfoByID(c.pool.NodeInfos(), "local-node-1"); info.InFlight != 0 {
+		t.Fatalf("in flight = %d after the request finished", info.InFlight)
+	}
+}
+
+func TestUndrainNodeRestoresSelection(t *testing.T) {
+	c := startClientFor(t, newParallelUploadServer("", ""))
+	c.config.Fallback.Enabled = true
+	c.RegisterLocalHandler(types.TaskSemanticTextEmbed, echoHandler)
+
+	drained := make(chan discovery.NodeDrained, 1)
+	c.WatchDrains(func(ev discovery.NodeDrained) { drained <- ev })
+	if err := c.DrainNode("local-node-1"); err != nil {
+		t.Fatalf("DrainNode: %v", err)
+	}
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle node drained without an event")
+	}
+
+	resp, err := c.Infer(context.Background(), embedRequest())
+	if err != nil || resp.Meta[ServedLocallyMetaKey] != "true" {
+		t.Fatalf("Infer while draining = %v, %v; want it served locally", resp, err)
+	}
+
+	if err := c.UndrainNode("local-node-1"); err != nil {
+		t.Fatalf("UndrainNode: %v", err)
+	}
+	if info := nodeInfoByID(c.pool.NodeInfos(), "local-node-1"); info.Status != discovery.NodeStatusActive {
+		t.Fatalf("status after undrain = %s, want active", info.Status)
+	}
+	resp, err = c.Infer(context.Background(), embedRequest())
+	if err != nil || resp.Meta[ServedLocallyMetaKey] != "" {
+		t.Fatalf("Infer after undrain = %v, %v; want a node response", resp, err)
+	}
+}
+
+func TestDrainNodeForUndrainsAfterTimeout(t *testing.T) {
+	c := startClientFor(t, newParallelUploadServer("", ""))
+	if err := c.DrainNodeFor("local-node-1", 50*time.Millisecond); err != nil {
+		t.Fatalf("DrainNodeFor: %v", err)
+	}
+	if info := nodeInfoByID(c.pool.NodeInfos(), "local-node-1"); info.Status != discovery.NodeStatusDraining {
+		t.Fatalf("status = %s, want draining", info.Status)
+	}
+	waitUntil(t, func() bool {
+		return nodeInfoByID(c.pool.NodeInfos(), "local-node-1").Status == discovery.NodeStatusActive
+	})
+	if _, err := c.Infer(context.Background(), embedRequest()); err != nil {
+		t.Fatalf("Infer after the drain expired: %v", err)
+	}
+}
+
+func TestDrainUnknownNode(t *testing.T) {
+	c := startClientFor(t, newParallelUploadServer("", ""))
+	if err := c.DrainNode("local-missing"); !utils.HasErrorCode(err, utils.ErrCodeNodeNotFound) {
+		t.Fatalf("DrainNode for an unknown node = %v, want NODE_NOT_FOUND", err)
+	}
+	if err := (&LumenClient{pool: NewPool(nil)}).DrainNode("local-node-1"); err == nil {
+		t.Fatal("DrainNode before the pool connects succeeded")
+	}
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FailureClass is why an Infer call failed.
+type FailureClass string
+
+const (
+	// FailureCancelled: the caller cancelled the context. It is not counted
+	// as a failure in ClientMetrics.FailedRequests or ErrorRate.
+	FailureCancelled FailureClass = "cancelled"
+	// FailureTimeout: the context's deadline passed, or a node reported
+	// DEADLINE_EXCEEDED.
+	FailureTimeout FailureClass = "timeout"
+	// FailureTransport: no node could be reached, or the connection to it
+	// failed.
+	FailureTransport FailureClass = "transport"
+	// FailureNode: the node answered with an error.
+	FailureNode FailureClass = "node"
+)
+
+// FailureCounts counts failed Infer calls by FailureClass.
+type FailureCounts struct {
+	Cancelled int64 `json:"cancelled"`
+	Timeout   int64 `json:"timeout"`
+	Transport int64 `json:"transport"`
+	Node      int64 `json:"node"`
+}
+
+// Failed returns the failures other than cancellations.
+func (f FailureCounts) Failed() int64 {
+	return f.Timeout + f.Transport + f.Node
+}
+
+// ClassifyFailure reports why a call made with ctx failed with err. A
+// cancelled or expired ctx wins over whatever err says, so a request the
+// caller abandoned is never blamed on the node that was serving it.
+func ClassifyFailure(ctx context.Context, err error) FailureClass {
+	if ctx != nil {
+		switch ctx.Err() {
+		case context.Canceled:
+			return FailureCancelled
+		case context.DeadlineExceeded:
+			return FailureTimeout
+		}
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return FailureCancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		return FailureTimeout
+	}
+	return classifyStatus(err)
+}
+
+// classifyStatus classifies err by its Lumen or gRPC code alone, for
+// callers without the request's context such as the balancer.
+func classifyStatus(err error) FailureClass {
+	var lumenErr *utils.LumenError
+	if errors.As(err, &lumenErr) {
+		switch lumenErr.Code {
+		case utils.ErrCodeCancelled:
+			return FailureCancelled
+		case utils.ErrCodeTimeout:
+			return FailureTimeout
+		case utils.ErrCodeConnectionFailed, utils.ErrCodeUnavailable, utils.ErrCodeServiceUnavailable:
+			return FailureTransport
+		}
+	}
+	if errors.Is(err, ErrNoAvailableNode) {
+		return FailureTransport
+	}
+	switch status.Code(err) {
+	case codes.Canceled:
+		return FailureCancelled
+	case codes.DeadlineExceeded:
+		return FailureTimeout
+	case codes.Unavailable:
+		return FailureTransport
+	}
+	return FailureNode
+}
+
+// classifiedError wraps err in a LumenError whose code matches class:
+// CANCELLED, TIMEOUT or CONNECTION_FAILED. Node errors are returned as the
+// node sent them. The gRPC status stays reachable through errors.As.
+func classifiedError(class FailureClass, err error) error {
+	var code utils.ErrorCode
+	var message string
+	switch class {
+	case FailureCancelled:
+		code, message = utils.ErrCodeCancelled, "request cancelled"
+	case FailureTimeout:
+		code, message = utils.ErrCodeTimeout, "request deadline exceeded"
+	case FailureTransport:
+		code, message = utils.ErrCodeConnectionFailed, "no node reachable"
+	default:
+		return err
+	}
+	if utils.HasErrorCode(err, code) {
+		return err
+	}
+	return utils.Wrap(err, code, message)
+}
+
+// failureCounters is a FailureCounts updated with atomics.
+type failureCounters struct {
+	cancelled, timeout, transport, node atomic.Int64
+}
+
+func (f *failureCounters) add(class FailureClass) {
+	switch class {
+	case FailureCancelled:
+		f.cancelled.Add(1)
+	case FailureTimeout:
+		f.timeout.Add(1)
+	case FailureTransport:
+		f.transport.Add(1)
+	default:
+		f.node.Add(1)
+	}
+}
+
+func (f *failureCounters) snapshot() FailureCounts {
+	return FailureCounts{
+		Cancelled: f.cancelled.Load(),
+		Timeout:   f.timeout.Load(),
+		Transport: f.transport.Load(),
+		Node:      f.node.Load(),
+	}
+}
+
+// failureSet keeps failure counters per key (task name or node ID), like
+// latencySet does for latencies.
+type failureSet struct {
+	counters sync.Map // string -> *failureCounters
+}
+
+func (s *failureSet) add(key string, class FailureClass) {
+	if s == nil || key == "" {
+		return
+	}
+	v, ok := s.counters.Load(key)
+	if !ok {
+		v, _ = s.counters.LoadOrStore(key, &failureCounters{})
+	}
+	v.(*failureCounters).add(class)
+}
+
+func (s *failureSet) snapshot() map[string]FailureCounts {
+	if s == nil {
+		return nil
+	}
+	out := make(map[string]FailureCounts)
+	s.counters.Range(func(k, v any) bool {
+		out[k.(string)] = v.(*failureCounters).snapshot()
+		return true
+	})
+	return out
+}
+
+// keys returns the keys holding counters.
+func (s *failureSet) keys() []string {
+	if s == nil {
+		return nil
+	}
+	var out []string
+	s.counters.Range(func(k, _ any) bool {
+		out = append(out, k.(string))
+		return true
+	})
+	return out
+}
+
+// forget drops key's counters.
+func (s *failureSet) forget(key string) {
+	if s == nil {
+		return
+	}
+	s.counters.Delete(key)
+}
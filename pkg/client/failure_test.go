@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failingInferServer fails every Infer call with err, or holds it until the
+// client goes away when err is nil.
+type failingInferServer struct {
+	testInferenceServer
+	err error
+}
+
+func (s *failingInferServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	if s.err != nil {
+		return s.err
+	}
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+// TestInferClassifiesFailures drives each failure mode through a node and
+// checks the error code Infer returns and the bucket GetMetrics counts it
+// in, overall, for the task and for the node.
+func TestInferClassifiesFailures(t *testing.T) {
+	cases := []struct {
+		name      string
+		serverErr error
+		timeout   time.Duration
+		cancel    time.Duration
+		class     FailureClass
+		code      utils.ErrorCode
+		grpcCode  codes.Code
+	}{
+		{name: "cancelled", cancel: 50 * time.Millisecond, class: FailureCancelled, code: utils.ErrCodeCancelled, grpcCode: codes.Canceled},
+		{name: "deadline", timeout: 50 * time.Millisecond, class: FailureTimeout, code: utils.ErrCodeTimeout, grpcCode: codes.DeadlineExceeded},
+		{name: "transport", serverErr: status.Error(codes.Unavailable, "connection reset"), class: FailureTransport, code: utils.ErrCodeConnectionFailed, grpcCode: codes.Unavailable},
+		{name: "node", serverErr: status.Error(codes.InvalidArgument, "bad payload"), class: FailureNode, grpcCode: codes.InvalidArgument},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := startClientFor(t, &failingInferServer{
+				testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}},
+				err:                 tc.serverErr,
+			})
+			c.taskFailures = &failureSet{}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if tc.timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+			if tc.cancel > 0 {
+				time.AfterFunc(tc.cancel, cancel)
+			}
+			_, err := c.Infer(ctx, embedRequest())
+			if err == nil {
+				t.Fatal("Infer succeeded")
+			}
+			if tc.code != "" && !utils.HasErrorCode(err, tc.code) {
+				t.Errorf("err = %v, want code %s", err, tc.code)
+			}
+			if tc.code == "" && utils.IsLumenError(err) {
+				t.Errorf("err = %v, want the node's error unwrapped", err)
+			}
+			if got := status.Code(err); got != tc.grpcCode {
+				t.Errorf("status.Code = %s, want %s", got, tc.grpcCode)
+			}
+
+			want := FailureCounts{}
+			switch tc.class {
+			case FailureCancelled:
+				want.Cancelled = 1
+			case FailureTimeout:
+				want.Timeout = 1
+			case FailureTransport:
+				want.Transport = 1
+			case FailureNode:
+				want.Node = 1
+			}
+			m := c.GetMetrics()
+			if m.Failures != want || m.TaskFailures[types.TaskSemanticTextEmbed] != want {
+				t.Errorf("failures = %+v, task failures = %+v, want %+v", m.Failures, m.TaskFailures, want)
+			}
+			if m.FailedRequests != want.Failed() || m.CancelledRequests != want.Cancelled {
+				t.Errorf("failed = %d, cancelled = %d, want %d and %d", m.FailedRequests, m.CancelledRequests, want.Failed(), want.Cancelled)
+			}
+			// The balancer records the node's share when the stream ends.
+			waitUntil(t, func() bool {
+				for _, counts := range c.GetMetrics().NodeFailures {
+					if counts == want {
+						return true
+					}
+				}
+				return false
+			})
+		})
+	}
+}
+
+func TestClassifyFailurePrefersTheContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The node's error raced the cancellation; the caller still gave up.
+	if got := ClassifyFailure(ctx, status.Error(codes.Internal, "boom")); got != FailureCancelled {
+		t.Fatalf("ClassifyFailure = %s, want cancelled", got)
+	}
+	if got := ClassifyFailure(context.Background(), utils.TimeoutError("slow")); got != FailureTimeout {
+		t.Fatalf("ClassifyFailure of a TIMEOUT error = %s, want timeout", got)
+	}
+	err := classifiedError(ClassifyFailure(context.Background(), ErrNoAvailableNode), ErrNoAvailableNode)
+	if !utils.HasErrorCode(err, utils.ErrCodeConnectionFailed) || !errors.Is(err, ErrNoAvailableNode) {
+		t.Fatalf("err = %v, want CONNECTION_FAILED wrapping ErrNoAvailableNode", err)
+	}
+}
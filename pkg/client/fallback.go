@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+)
+
+// ServedLocallyMetaKey is set to "true" on responses produced by a local
+// handler instead of a node.
+const ServedLocallyMetaKey = "lumen.served_locally"
+
+// RegisterLocalHandler registers fn to serve task in-process when
+// fallback.enabled is set and no node can: the pool is not connected or has
+// discovered no node, or no known node supports task. Other failures, such
+// as a node erroring or every supporting node still connecting, are
+// returned as usual. fn gets the caller's context, and Infer gives up on it
+// when that context ends, as it would on a node. InferStream never falls
+// back. A nil fn removes the handler for task.
+func (c *LumenClient) RegisterLocalHandler(task string, fn InferFunc) {
+	c.localMu.Lock()
+	defer c.localMu.Unlock()
+	if fn == nil {
+		delete(c.localHandlers, task)
+		return
+	}
+	if c.localHandlers == nil {
+		c.localHandlers = make(map[string]InferFunc)
+	}
+	c.localHandlers[task] = fn
+}
+
+// localHandler returns the handler for task, or nil when there is none or
+// fallback is disabled.
+func (c *LumenClient) localHandler(task string) InferFunc {
+	if !c.config.Fallback.Enabled {
+		return nil
+	}
+	c.localMu.RLock()
+	defer c.localMu.RUnlock()
+	return c.localHandlers[task]
+}
+
+type noNodeKey struct{}
+
+// withNoNodeFlag asks lumenPicker to set flag when it fails an RPC because
+// no node supports its task.
+func withNoNodeFlag(ctx context.Context, flag *atomic.Bool) context.Context {
+	return context.WithValue(ctx, noNodeKey{}, flag)
+}
+
+func noNodeFlag(ctx context.Context) *atomic.Bool {
+	flag, _ := ctx.Value(noNodeKey{}).(*atomic.Bool)
+	return flag
+}
+
+// dispatchInfer is the innermost InferFunc: it sends req to a node, or to
+// the task's local handler when no node can serve it.
+func (c *LumenClient) dispatchInfer(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+	fn := c.localHandler(req.Task)
+	if fn == nil {
+		return c.dispatchRemote(ctx, req)
+	}
+	if c.pool.empty() {
+		return c.serveLocally(ctx, fn, req, ErrNoAvailableNode)
+	}
+	var noNode atomic.Bool
+	resp, err := c.dispatchRemote(withNoNodeFlag(ctx, &noNode), req)
+	if err != nil && (errors.Is(err, ErrNoAvailableNode) || noNode.Load()) {
+		return c.serveLocally(ctx, fn, req, err)
+	}
+	return resp, err
+}
+
+// serveLocally runs fn in place of a node. If ctx ends first the call fails
+// with the status a node call would, without waiting for fn.
+func (c *LumenClient) serveLocally(ctx context.Context, fn InferFunc, req *pb.InferRequest, cause error) (*pb.InferResponse, error) {
+	c.localFallbacks.Add(1)
+	utils.LoggerFrom(ctx).Debug("no node available; serving locally",
+		zap.NamedError("cause", cause),
+	)
+	// Nothing was chunked or sent.
+	if slot := chunkInfoSlot(ctx); slot != nil {
+		*slot = ChunkInfo{}
+	}
+
+	type result struct {
+		resp *pb.InferResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := fn(ctx, req)
+		done <- result{resp, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, fmt.Errorf("local handler: %w", r.err)
+		}
+		if r.resp == nil {
+			return nil, fmt.Errorf("local handler for %s returned no response", req.Task)
+		}
+		if r.resp.Meta == nil {
+			r.resp.Meta = make(map[string]string, 1)
+		}
+		r.resp.Meta[ServedLocallyMetaKey] = "true"
+		return r.resp, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
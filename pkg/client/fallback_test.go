@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func echoHandler(_ context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+	return &pb.InferResponse{IsFinal: true, Result: req.Payload}, nil
+}
+
+// startEmptyClusterClient returns a client whose pool is connected but has
+// discovered no node.
+func startEmptyClusterClient(t *testing.T) *LumenClient {
+	t.Helper()
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{ConnectTimeout: time.Second})
+	if err := pool.Connect(&fakeNodeResolver{}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+	cfg := config.DefaultConfig()
+	cfg.Fallback.Enabled = true
+	return &LumenClient{pool: pool, config: cfg, logger: zap.NewNop()}
+}
+
+func embedRequest() *pb.InferRequest {
+	return types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hello").Build()
+}
+
+func TestLocalFallbackServesEmptyCluster(t *testing.T) {
+	c := startEmptyClusterClient(t)
+	c.RegisterLocalHandler(types.TaskSemanticTextEmbed, echoHandler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := c.Infer(ctx, embedRequest())
+	if err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	if string(resp.Result) != "hello" || resp.Meta[ServedLocallyMetaKey] != "true" {
+		t.Fatalf("response = %q meta %v, want the echo marked as served locally", resp.Result, resp.Meta)
+	}
+	if m := c.GetMetrics(); m.LocalFallbacks != 1 || m.SuccessRequests != 1 {
+		t.Fatalf("metrics: %d local fallbacks, %d successes; want 1 and 1", m.LocalFallbacks, m.SuccessRequests)
+	}
+
+	// A task without a handler still waits for discovery.
+	short, cancelShort := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelShort()
+	req := types.NewInferRequest(types.TaskOCR).ForOCRRaw([]byte("img"), "image/png").Build()
+	if _, err := c.Infer(short, req); err == nil {
+		t.Fatal("Infer for a task without a local handler succeeded")
+	}
+}
+
+func TestLocalFallbackWithoutPool(t *testing.T) {
+	c := newFakeLumenClient(nil, config.ChunkConfig{})
+	c.config.Fallback.Enabled = true
+	c.RegisterLocalHandler(types.TaskSemanticTextEmbed, echoHandler)
+
+	resp, err := c.Infer(context.Background(), embedRequest())
+	if err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	if resp.Meta[ServedLocallyMetaKey] != "true" {
+		t.Fatalf("meta = %v, want served locally", resp.Meta)
+	}
+
+	c.RegisterLocalHandler(types.TaskSemanticTextEmbed, nil)
+	if _, err := c.Infer(context.Background(), embedRequest()); !errors.Is(err, ErrNoAvailableNode) {
+		t.Fatalf("Infer after removing the handler = %v, want ErrNoAvailableNode", err)
+	}
+}
+
+func TestLocalFallbackNeedsEnabledFlag(t *testing.T) {
+	c := startEmptyClusterClient(t)
+	c.config.Fallback.Enabled = false
+	c.RegisterLocalHandler(types.TaskSemanticTextEmbed, echoHandler)
+
+	// Without fallback the request waits for discovery.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := c.Infer(ctx, embedRequest()); err == nil {
+		t.Fatal("Infer fell back with fallback.enabled unset")
+	}
+	if n := c.GetMetrics().LocalFallbacks; n != 0 {
+		t.Fatalf("local fallbacks = %d, want 0", n)
+	}
+}
+
+func TestLocalFallbackForUnsupportedTask(t *testing.T) {
+	c := startClientFor(t, newParallelUploadServer("", ""))
+	c.config.Fallback.Enabled = true
+	c.RegisterLocalHandler(types.TaskOCR, echoHandler)
+
+	req := types.NewInferRequest(types.TaskOCR).ForOCRRaw([]byte("img"), "image/png").Build()
+	resp, err := c.Infer(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	if resp.Meta[ServedLocallyMetaKey] != "true" {
+		t.Fatalf("meta = %v, want served locally: the only node lacks ocr", resp.Meta)
+	}
+}
+
+func TestLocalFallbackSkippedWhenNodesServe(t *testing.T) {
+	t.Run("node answers", func(t *testing.T) {
+		c := startClientFor(t, newParallelUploadServer("", ""))
+		c.config.Fallback.Enabled = true
+		c.RegisterLocalHandler(types.TaskSemanticTextEmbed, func(context.Context, *pb.InferRequest) (*pb.InferResponse, error) {
+			t.Error("local handler ran while a node was available")
+			return nil, errors.New("unexpected")
+		})
+
+		resp, err := c.Infer(context.Background(), embedRequest())
+		if err != nil {
+			t.Fatalf("Infer: %v", err)
+		}
+		if _, ok := resp.Meta[ServedLocallyMetaKey]; ok {
+			t.Fatalf("meta = %v, want a node response", resp.Meta)
+		}
+	})
+	t.Run("node fails", func(t *testing.T) {
+		// testInferenceServer does not implement Infer.
+		c := startClientFor(t, &testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}})
+		c.config.Fallback.Enabled = true
+		c.RegisterLocalHandler(types.TaskSemanticTextEmbed, echoHandler)
+
+		_, err := c.Infer(context.Background(), embedRequest())
+		if status.Code(errors.Unwrap(err)) != codes.Unimplemented {
+			t.Fatalf("Infer error = %v, want the node's Unimplemented", err)
+		}
+		if n := c.GetMetrics().LocalFallbacks; n != 0 {
+			t.Fatalf("local fallbacks = %d, want 0", n)
+		}
+	})
+}
+
+func TestLocalFallbackHonoursDeadline(t *testing.T) {
+	c := startEmptyClusterClient(t)
+	release := make(chan struct{})
+	defer close(release)
+	c.RegisterLocalHandler(types.TaskSemanticTextEmbed, func(context.Context, *pb.InferRequest) (*pb.InferResponse, error) {
+		<-release // ignores its context
+		return &pb.InferResponse{IsFinal: true}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := c.Infer(ctx, embedRequest())
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("Infer error = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestLocalFallbackSkipsStreams(t *testing.T) {
+	c := startEmptyClusterClient(t)
+	c.RegisterLocalHandler(types.TaskSemanticTextEmbed, echoHandler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	ch, err := c.InferStream(ctx, embedRequest())
+	if err == nil {
+		for resp := range ch {
+			if resp.Meta[ServedLocallyMetaKey] == "true" {
+				t.Fatal("InferStream was served locally")
+			}
+		}
+	}
+	if n := c.GetMetrics().LocalFallbacks; n != 0 {
+		t.Fatalf("local fallbacks = %d, want 0", n)
+	}
+}
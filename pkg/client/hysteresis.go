@@ -0,0 +1,228 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+)
+
+// statusDamper turns each node's health-check results into a damped status:
+// active, error or suspect. It lives in the nodeRegistry, so a node's
+// streaks, flaps and history outlive reconnects and balancer rebuilds.
+type statusDamper struct {
+	cfg config.HysteresisConfig
+
+	mu    sync.Mutex
+	nodes map[string]*nodeHealth
+}
+
+// nodeHealth is one node's damped status and the checks that led to it.
+type nodeHealth struct {
+	// state is active, error or suspect, and its history the node's
+	// health-driven transitions.
+	state discovery.NodeState
+	// failures and successes are the current streaks of failed and passed
+	// checks; one of them is always zero.
+	failures, successes int
+	score               float64
+	// flaps are when the node went to error, within the flap window.
+	flaps        []time.Time
+	suspectUntil time.Time
+	// connLost is set while the node is in error because its connection
+	// dropped rather than because checks failed.
+	connLost bool
+}
+
+func newStatusDamper(cfg config.HysteresisConfig) *statusDamper {
+	def := config.DefaultConfig().Pool.Hysteresis
+	if cfg.FailThreshold <= 0 {
+		cfg.FailThreshold = def.FailThreshold
+	}
+	if cfg.RecoverThreshold <= 0 {
+		cfg.RecoverThreshold = def.RecoverThreshold
+	}
+	if cfg.Smoothing <= 0 || cfg.Smoothing > 1 {
+		cfg.Smoothing = def.Smoothing
+	}
+	if cfg.FlapLimit < 0 || cfg.FlapWindow <= 0 || cfg.SuspectTime <= 0 {
+		cfg.FlapLimit = 0
+	}
+	return &statusDamper{cfg: cfg, nodes: make(map[string]*nodeHealth)}
+}
+
+// observe records one health check of the node with key and returns its
+// damped status afterwards, and the transition the check caused, if any.
+//
+// An active node goes to error after FailThreshold failed checks in a row,
+// or to suspect when that makes more than FlapLimit errors within
+// FlapWindow. An error node returns to active after RecoverThreshold passed
+// checks in a row. A suspect node stays suspect until SuspectTime has
+// passed, then is judged as an error node on its current streak.
+func (d *statusDamper) observe(key string, passed bool, now time.Time) (discovery.NodeStatus, *discovery.StatusTransition) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h := d.nodes[key]
+	if h == nil {
+		h = newNodeHealth()
+		d.nodes[key] = h
+	}
+
+	sample := 0.0
+	if passed {
+		sample = 1
+		h.successes++
+		h.failures = 0
+	} else {
+		h.failures++
+		h.successes = 0
+	}
+	h.score = d.cfg.Smoothing*sample + (1-d.cfg.Smoothing)*h.score
+	h.pruneFlaps(now, d.cfg.FlapWindow)
+
+	var to discovery.NodeStatus
+	var reason discovery.StatusReason
+	var detail string
+	switch h.state.Status {
+	case discovery.NodeStatusActive:
+		if h.failures < d.cfg.FailThreshold {
+			return h.state.Status, nil
+		}
+		h.flaps = append(h.flaps, now)
+		to, reason = discovery.NodeStatusError, discovery.StatusReasonHealthCheckFailed
+		detail = fmt.Sprintf("%d failed %s", h.failures, checkNoun(h.failures))
+		if d.cfg.FlapLimit > 0 && len(h.flaps) > d.cfg.FlapLimit {
+			to, reason = discovery.NodeStatusSuspect, discovery.StatusReasonFlapping
+			h.suspectUntil = now.Add(d.cfg.SuspectTime)
+			detail = fmt.Sprintf("%d errors within %s", len(h.flaps), d.cfg.FlapWindow)
+		}
+	case discovery.NodeStatusSuspect:
+		if now.Before(h.suspectUntil) {
+			return h.state.Status, nil
+		}
+		h.suspectUntil = time.Time{}
+		to, reason, detail = discovery.NodeStatusError, discovery.StatusReasonSuspectElapsed, "suspect time elapsed"
+		if h.successes >= d.cfg.RecoverThreshold {
+			to, reason = discovery.NodeStatusActive, discovery.StatusReasonHealthCheckPassed
+			detail = fmt.Sprintf("suspect time elapsed after %d passed %s", h.successes, checkNoun(h.successes))
+		}
+	default:
+		if h.successes < d.cfg.RecoverThreshold {
+			return h.state.Status, nil
+		}
+		to, reason = discovery.NodeStatusActive, discovery.StatusReasonHealthCheckPassed
+		detail = fmt.Sprintf("%d passed %s", h.successes, checkNoun(h.successes))
+	}
+
+	h.connLost = false
+	return to, h.transition(to, reason, detail, now)
+}
+
+// connectionChanged records the node with key losing (up false) or
+// regaining its connection, and returns the transition it caused, if any.
+// Losing it moves an active node to error at once, without waiting for
+// FailThreshold failed checks; regaining it returns a node that went to
+// error that way to active. A node in error for failed checks stays there
+// until enough checks pass.
+func (d *statusDamper) connectionChanged(key string, up bool, now time.Time) *discovery.StatusTransition {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h := d.nodes[key]
+	if h == nil {
+		if up {
+			return nil
+		}
+		h = newNodeHealth()
+		d.nodes[key] = h
+	}
+
+	switch {
+	case !up && h.state.Status == discovery.NodeStatusActive:
+		h.connLost = true
+		return h.transition(discovery.NodeStatusError, discovery.StatusReasonConnectionLost, "connection lost", now)
+	case up && h.connLost:
+		h.connLost = false
+		return h.transition(discovery.NodeStatusActive, discovery.StatusReasonConnectionRestored, "connection restored", now)
+	}
+	return nil
+}
+
+func newNodeHealth() *nodeHealth {
+	h := &nodeHealth{score: 1}
+	h.state.Status = discovery.NodeStatusActive
+	return h
+}
+
+// transition moves the node's damped status to to. The damper only moves
+// between active, error and suspect, which CanTransition always allows.
+func (h *nodeHealth) transition(to discovery.NodeStatus, reason discovery.StatusReason, detail string, now time.Time) *discovery.StatusTransition {
+	t, _ := h.state.TransitionTo(to, reason, detail, now)
+	return t
+}
+
+// checkNoun is "check" or "checks" to follow n.
+func checkNoun(n int) string {
+	if n == 1 {
+		return "check"
+	}
+	return "checks"
+}
+
+// pruneFlaps drops flaps older than window.
+func (h *nodeHealth) pruneFlaps(now time.Time, window time.Duration) {
+	i := 0
+	for i < len(h.flaps) && now.Sub(h.flaps[i]) >= window {
+		i++
+	}
+	h.flaps = h.flaps[i:]
+}
+
+// nodeHealthView is what NodeInfo reports of a node's damped status.
+type nodeHealthView struct {
+	status       discovery.NodeStatus
+	reason       discovery.StatusReason
+	detail       string
+	score        float64
+	flaps        int
+	suspectUntil time.Time
+	history      []discovery.StatusTransition
+}
+
+// view returns the damped status of the node with key; ok is false for a
+// node never checked.
+func (d *statusDamper) view(key string, now time.Time) (v nodeHealthView, ok bool) {
+	if d == nil {
+		return v, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h := d.nodes[key]
+	if h == nil {
+		return v, false
+	}
+	h.pruneFlaps(now, d.cfg.FlapWindow)
+	v = nodeHealthView{
+		status:       h.state.Status,
+		reason:       h.state.Reason,
+		score:        h.score,
+		flaps:        len(h.flaps),
+		suspectUntil: h.suspectUntil,
+		history:      append([]discovery.StatusTransition(nil), h.state.History...),
+	}
+	if n := len(h.state.History); n > 0 {
+		v.detail = h.state.History[n-1].Detail
+	}
+	return v, true
+}
+
+// forget drops the state of a node that is gone.
+func (d *statusDamper) forget(key string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	delete(d.nodes, key)
+	d.mu.Unlock()
+}
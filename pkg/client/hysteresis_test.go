@@ -0,0 +1,135 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+)
+
+// runChecks feeds script into d, one check per second from start: '+' is a
+// passed check and '-' a failed one. It returns the status after each.
+func runChecks(d *statusDamper, start time.Time, script string) []discovery.NodeStatus {
+	var out []discovery.NodeStatus
+	for i, c := range script {
+		status, _ := d.observe("node-1", c == '+', start.Add(time.Duration(i)*time.Second))
+		out = append(out, status)
+	}
+	return out
+}
+
+func statusString(statuses []discovery.NodeStatus) string {
+	var b strings.Builder
+	for _, s := range statuses {
+		switch s {
+		case discovery.NodeStatusActive:
+			b.WriteByte('A')
+		case discovery.NodeStatusError:
+			b.WriteByte('E')
+		case discovery.NodeStatusSuspect:
+			b.WriteByte('S')
+		}
+	}
+	return b.String()
+}
+
+func TestStatusDamperHysteresis(t *testing.T) {
+	cfg := config.HysteresisConfig{FailThreshold: 3, RecoverThreshold: 2}
+	for _, tc := range []struct {
+		name, script, want string
+	}{
+		{"isolated failures never leave active", "+-+--+--+", "AAAAAAAAA"},
+		{"three failures in a row", "+---", "AAAE"},
+		{"recovery needs two passes in a row", "----+-++", "AAEEEEEA"},
+		{"edge of range", "-+-+---+-++", "AAAAAAEEEEA"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := statusString(runChecks(newStatusDamper(cfg), time.Now(), tc.script))
+			if got != tc.want {
+				t.Fatalf("statuses %s for checks %s, want %s", got, tc.script, tc.want)
+			}
+		})
+	}
+}
+
+func TestStatusDamperHoldsFlappingNodeSuspect(t *testing.T) {
+	d := newStatusDamper(config.HysteresisConfig{
+		FailThreshold:    1,
+		RecoverThreshold: 1,
+		FlapLimit:        2,
+		FlapWindow:       time.Minute,
+		SuspectTime:      30 * time.Second,
+	})
+	start := time.Now()
+
+	// The third error within the minute makes the node suspect; passing
+	// checks do not bring it back until the suspect time is over.
+	got := statusString(runChecks(d, start, "-+-+-++++"))
+	if got != "EAEASSSSS" {
+		t.Fatalf("statuses = %s, want EAEASSSSS", got)
+	}
+	v, _ := d.view("node-1", start.Add(8*time.Second))
+	if v.flaps != 3 || !v.suspectUntil.Equal(start.Add(34*time.Second)) {
+		t.Fatalf("flaps %d, suspect until %v; want 3 and 34s after start", v.flaps, v.suspectUntil.Sub(start))
+	}
+
+	status, tr := d.observe("node-1", true, start.Add(40*time.Second))
+	if status != discovery.NodeStatusActive || tr == nil || tr.From != discovery.NodeStatusSuspect {
+		t.Fatalf("after suspect time: status %s, transition %+v; want active from suspect", status, tr)
+	}
+
+	// Flaps age out of the window: one more error a minute later is not
+	// enough to make the node suspect again.
+	if status, _ := d.observe("node-1", false, start.Add(2*time.Minute)); status != discovery.NodeStatusError {
+		t.Fatalf("status = %s, want error", status)
+	}
+}
+
+func TestStatusDamperKeepsRecentHistory(t *testing.T) {
+	d := newStatusDamper(config.HysteresisConfig{FailThreshold: 1, RecoverThreshold: 1})
+	start := time.Now()
+	runChecks(d, start, strings.Repeat("-+", discovery.StatusHistoryLen))
+
+	v, ok := d.view("node-1", start.Add(time.Hour))
+	if !ok || len(v.history) != discovery.StatusHistoryLen {
+		t.Fatalf("history = %+v, want the last %d transitions", v.history, discovery.StatusHistoryLen)
+	}
+	first, last := v.history[0], v.history[len(v.history)-1]
+	if !first.At.Equal(start.Add(time.Duration(discovery.StatusHistoryLen)*time.Second)) || last.To != discovery.NodeStatusActive || last.Reason != discovery.StatusReasonHealthCheckPassed || last.Detail != "1 passed check" {
+		t.Fatalf("history runs from %+v to %+v", first, last)
+	}
+	if v.score <= 0 || v.score >= 1 {
+		t.Fatalf("smoothed score = %v, want between 0 and 1", v.score)
+	}
+
+	d.forget("node-1")
+	if _, ok := d.view("node-1", start); ok {
+		t.Fatal("forgotten node still has a status")
+	}
+}
+
+// TestStatusDamperConnectionLoss checks a lost connection puts an active
+// node in error at once and its return clears that, but not an error that
+// failed checks caused.
+func TestStatusDamperConnectionLoss(t *testing.T) {
+	d := newStatusDamper(config.HysteresisConfig{FailThreshold: 3, RecoverThreshold: 2})
+	now := time.Now()
+
+	if tr := d.connectionChanged("node-1", false, now); tr == nil || tr.To != discovery.NodeStatusError || tr.Reason != discovery.StatusReasonConnectionLost {
+		t.Fatalf("losing the connection: %+v, want a transition to error", tr)
+	}
+	if tr := d.connectionChanged("node-1", true, now); tr == nil || tr.To != discovery.NodeStatusActive {
+		t.Fatalf("regaining the connection: %+v, want a transition to active", tr)
+	}
+
+	runChecks(d, now, "---")
+	d.connectionChanged("node-1", false, now)
+	if tr := d.connectionChanged("node-1", true, now); tr != nil {
+		t.Fatalf("regaining the connection of a node failing checks: %+v, want it left in error", tr)
+	}
+	if got := statusString(runChecks(d, now, "++")); got != "EA" {
+		t.Fatalf("statuses %s after reconnecting, want EA: checks decide", got)
+	}
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// InferOnNode is Infer sending req to the node with nodeID instead of the
+// one selection would pick, e.g. to check every node serving a task in
+// turn. The request runs through the middlewares, chunking and metrics
+// like any other. It reaches the node while it drains or is ejected, but
+// never another node or a local handler, and pool.min_healthy_nodes does
+// not apply. It fails with NODE_NOT_FOUND for a node the pool does not
+// know, and like a request no node can serve while the node is not
+// connected, cooling down or not serving req's task.
+func (c *LumenClient) InferOnNode(ctx context.Context, nodeID string, req *pb.InferRequest) (*pb.InferResponse, error) {
+	if !c.pool.knowsNode(nodeID) {
+		return nil, utils.NodeNotFoundError(nodeID)
+	}
+	return c.Infer(withPinnedNode(ctx, nodeID), req)
+}
+
+// knowsNode reports whether the node with key has been discovered and not
+// removed since.
+func (p *Pool) knowsNode(key string) bool {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return false
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.nodes[key] != nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// namedServer answers every request with its own name.
+type namedServer struct {
+	testInferenceServer
+	name string
+}
+
+func (s *namedServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if req.Total <= 1 || req.Seq == req.Total-1 {
+			break
+		}
+	}
+	return stream.Send(&pb.InferResponse{Result: []byte(s.name), IsFinal: true})
+}
+
+// namedNodesClient returns a client whose pool is connected to a node
+// serving tasks per name, each answering with its name.
+func namedNodesClient(t *testing.T, nodes map[string][]string) *LumenClient {
+	t.Helper()
+	var events []discovery.NodeEvent
+	for name, tasks := range nodes {
+		addr := startInferenceServer(t, &namedServer{testInferenceServer{tasks: tasks}, name})
+		events = append(events, discoveredNode(name, addr, tasks...))
+	}
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{ConnectTimeout: 2 * time.Second})
+	if err := pool.Connect(&fakeNodeResolver{events: events}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+	waitUntil(t, func() bool { return pool.Stats().HealthyConnections == len(nodes) })
+	return &LumenClient{pool: pool, config: config.DefaultConfig(), logger: zap.NewNop()}
+}
+
+func TestInferOnNodeReachesThatNode(t *testing.T) {
+	embed := types.TaskSemanticTextEmbed
+	c := namedNodesClient(t, map[string][]string{"a": {embed}, "b": {embed}, "ocr": {types.TaskOCR}})
+	key := func(name string) string { return discovery.NewNodeIdentity("local", name).Key() }
+	ctx := context.Background()
+
+	for _, name := range []string{"a", "b", "a", "b", "b"} {
+		resp, err := c.InferOnNode(ctx, key(name), embedRequest())
+		if err != nil {
+			t.Fatalf("InferOnNode(%s): %v", name, err)
+		}
+		if got := string(resp.Result); got != name {
+			t.Fatalf("InferOnNode(%s) served by %s", name, got)
+		}
+	}
+
+	if _, err := c.InferOnNode(ctx, key("gone"), embedRequest()); !utils.HasErrorCode(err, utils.ErrCodeNodeNotFound) {
+		t.Fatalf("unknown node = %v, want NODE_NOT_FOUND", err)
+	}
+	if resp, err := c.InferOnNode(ctx, key("ocr"), embedRequest()); err == nil {
+		t.Fatalf("node not serving the task answered %q", resp.Result)
+	}
+}
+
+// TestInferOnNodeIgnoresSelectionRules checks a pinned request reaches a
+// draining node and is not held back by pool.min_healthy_nodes, both of
+// which stop balanced requests.
+func TestInferOnNodeIgnoresSelectionRules(t *testing.T) {
+	embed := types.TaskSemanticTextEmbed
+	c := namedNodesClient(t, map[string][]string{"a": {embed}, "b": {embed}})
+	ctx := context.Background()
+	a := discovery.NewNodeIdentity("local", "a").Key()
+
+	if err := c.DrainNode(a); err != nil {
+		t.Fatalf("DrainNode: %v", err)
+	}
+	for range 3 {
+		resp, err := c.Infer(ctx, embedRequest())
+		if err != nil || string(resp.Result) != "b" {
+			t.Fatalf("balanced request with a draining = %v, %v; want b", resp, err)
+		}
+	}
+	if resp, err := c.InferOnNode(ctx, a, embedRequest()); err != nil || string(resp.Result) != "a" {
+		t.Fatalf("InferOnNode(draining a) = %v, %v", resp, err)
+	}
+
+	c.config.Pool.MinHealthyNodes = map[string]int{embed: 5}
+	if resp, err := c.Infer(ctx, embedRequest()); err == nil {
+		t.Fatalf("balanced request below the minimum served by %s", resp.Result)
+	}
+	if resp, err := c.InferOnNode(ctx, a, embedRequest()); err != nil || string(resp.Result) != "a" {
+		t.Fatalf("InferOnNode below the minimum = %v, %v", resp, err)
+	}
+}
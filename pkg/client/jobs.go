@@ -0,0 +1,281 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// JobMetaKey is the request Meta key asking a node advertising
+// discovery.FeatureJobs to treat the request as a job operation, one of the
+// JobOp values.
+const JobMetaKey = "lumen.job"
+
+// Job operations sent under JobMetaKey.
+const (
+	// JobOpSubmit starts the request as a job and answers at once with the
+	// job's ID under JobIDMetaKey.
+	JobOpSubmit = "submit"
+	// JobOpStatus asks for the state of a job.
+	JobOpStatus = "status"
+	// JobOpResult asks for the response of a finished job.
+	JobOpResult = "result"
+	// JobOpCancel stops a job.
+	JobOpCancel = "cancel"
+)
+
+// JobIDMetaKey carries the node's ID of a job: on the response to a submit
+// and on the requests of the other job operations.
+const JobIDMetaKey = "lumen.job_id"
+
+// JobStateMetaKey is the response Meta key under which a node reports the
+// discovery.JobState of a job. Progress is reported under ProgressMetaKey.
+const JobStateMetaKey = "lumen.job_state"
+
+// jobControlMime is the payload MIME type of status, result and cancel
+// requests, which carry no payload.
+const jobControlMime = "application/json"
+
+type requiredFeatureKey struct{}
+
+// withRequiredFeature makes lumenPicker route the RPC only to nodes
+// advertising the wire feature.
+func withRequiredFeature(ctx context.Context, feature string) context.Context {
+	return context.WithValue(ctx, requiredFeatureKey{}, feature)
+}
+
+func requiredFeature(ctx context.Context) string {
+	feature, _ := ctx.Value(requiredFeatureKey{}).(string)
+	return feature
+}
+
+// SubmitJob starts req as an asynchronous job on a node advertising
+// discovery.FeatureJobs and returns the job's ID, for JobStatus, JobResult
+// and CancelJob. The client remembers which node runs the job for jobs.ttl
+// after the last query about it. req is validated and sent as by Infer, with
+// JobMetaKey set on its Meta.
+func (c *LumenClient) SubmitJob(ctx context.Context, req *pb.InferRequest) (string, error) {
+	if req == nil {
+		return "", fmt.Errorf("request cannot be nil")
+	}
+	if req.Meta == nil {
+		req.Meta = make(map[string]string)
+	}
+	req.Meta[JobMetaKey] = JobOpSubmit
+
+	result, err := c.InferDetailed(withRequiredFeature(ctx, discovery.FeatureJobs), req)
+	if err != nil {
+		return "", err
+	}
+	if err := responseError(result.Response); err != nil {
+		return "", err
+	}
+	nodeJobID := result.Response.GetMeta()[JobIDMetaKey]
+	if nodeJobID == "" || result.Node == "" {
+		return "", utils.ResponseFailedError(fmt.Sprintf("node %q accepted job for task %q without a job ID", result.Node, req.Task))
+	}
+
+	now := time.Now()
+	job := discovery.JobStatus{
+		ID:          newJobID(),
+		Task:        req.Task,
+		NodeID:      result.Node,
+		State:       discovery.JobQueued,
+		SubmittedAt: now,
+		UpdatedAt:   now,
+	}
+	c.jobTracker().add(job, nodeJobID, now)
+	return job.ID, nil
+}
+
+// JobStatus asks the node running the job with id for its state and
+// progress. It fails with a NOT_FOUND error for a job the client does not
+// know or has forgotten.
+func (c *LumenClient) JobStatus(ctx context.Context, id string) (*discovery.JobStatus, error) {
+	job, resp, err := c.jobOp(ctx, id, JobOpStatus)
+	if err != nil {
+		return nil, err
+	}
+	return &job, responseError(resp)
+}
+
+// JobResult returns the response of the finished job with id. It fails
+// with an UNAVAILABLE error while the job is still running, and with a
+// REQUEST_FAILED error when the job failed or was cancelled.
+func (c *LumenClient) JobResult(ctx context.Context, id string) (*pb.InferResponse, error) {
+	job, resp, err := c.jobOp(ctx, id, JobOpResult)
+	if err != nil {
+		return nil, err
+	}
+	switch job.State {
+	case discovery.JobSucceeded:
+		return resp, nil
+	case discovery.JobFailed, discovery.JobCancelled:
+		return nil, utils.RequestFailedError(fmt.Sprintf("job %s %s", id, job.State), job.Error)
+	default:
+		return nil, utils.UnavailableError(fmt.Sprintf("job %s is %s", id, job.State))
+	}
+}
+
+// CancelJob asks the node running the job with id to stop it. Cancelling a
+// finished job is not an error; the job keeps its final state.
+func (c *LumenClient) CancelJob(ctx context.Context, id string) error {
+	_, resp, err := c.jobOp(ctx, id, JobOpCancel)
+	if err != nil {
+		return err
+	}
+	return responseError(resp)
+}
+
+// Jobs returns the jobs the client remembers, oldest first, as last
+// reported by their nodes.
+func (c *LumenClient) Jobs() []discovery.JobStatus {
+	return c.jobTracker().list(time.Now())
+}
+
+// jobOp sends the job operation op for the job with id to the node running
+// it and records the state the node reports.
+func (c *LumenClient) jobOp(ctx context.Context, id, op string) (discovery.JobStatus, *pb.InferResponse, error) {
+	jobs := c.jobTracker()
+	job, nodeJobID, ok := jobs.get(id, time.Now())
+	if !ok {
+		return discovery.JobStatus{}, nil, utils.NotFoundError(fmt.Sprintf("job %q not found", id))
+	}
+
+	req := &pb.InferRequest{
+		CorrelationId: id,
+		Task:          job.Task,
+		PayloadMime:   jobControlMime,
+		Meta:          map[string]string{JobMetaKey: op, JobIDMetaKey: nodeJobID},
+	}
+	tagClientVersion(req)
+	resp, err := c.inferChain()(c.withRequestLogger(withPinnedNode(ctx, job.NodeID), req), req)
+	if err != nil {
+		return job, nil, err
+	}
+
+	if state := discovery.JobState(resp.GetMeta()[JobStateMetaKey]); state != "" {
+		job.State = state
+	}
+	if pct, ok := Progress(resp); ok {
+		job.Progress = pct
+	}
+	if job.State == discovery.JobFailed && resp.GetError() != nil {
+		job.Error = resp.GetError().GetMessage()
+	}
+	job.UpdatedAt = time.Now()
+	return jobs.update(job), resp, nil
+}
+
+// responseError returns the error a node reported in resp, if any.
+func responseError(resp *pb.InferResponse) error {
+	if e := resp.GetError(); e != nil {
+		return utils.RequestFailedError(e.GetMessage(), e.GetDetail())
+	}
+	return nil
+}
+
+func newJobID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return "job-" + hex.EncodeToString(b[:])
+}
+
+// jobTracker remembers which node runs each submitted job. A job is
+// forgotten ttl after it was submitted or last queried.
+type jobTracker struct {
+	ttl time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*trackedJob
+}
+
+type trackedJob struct {
+	status    discovery.JobStatus
+	nodeJobID string
+}
+
+func newJobTracker(ttl time.Duration) *jobTracker {
+	if ttl <= 0 {
+		ttl = config.DefaultConfig().Jobs.TTL
+	}
+	return &jobTracker{ttl: ttl, jobs: make(map[string]*trackedJob)}
+}
+
+// jobTracker returns the client's job tracker, creating it on first use.
+func (c *LumenClient) jobTracker() *jobTracker {
+	c.jobsOnce.Do(func() {
+		var ttl time.Duration
+		if c.config != nil {
+			ttl = c.config.Jobs.TTL
+		}
+		c.jobs = newJobTracker(ttl)
+	})
+	return c.jobs
+}
+
+func (t *jobTracker) add(status discovery.JobStatus, nodeJobID string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expireLocked(now)
+	status.ExpiresAt = now.Add(t.ttl)
+	t.jobs[status.ID] = &trackedJob{status: status, nodeJobID: nodeJobID}
+}
+
+// get returns the job with id and extends its expiry.
+func (t *jobTracker) get(id string, now time.Time) (discovery.JobStatus, string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expireLocked(now)
+	job, ok := t.jobs[id]
+	if !ok {
+		return discovery.JobStatus{}, "", false
+	}
+	job.status.ExpiresAt = now.Add(t.ttl)
+	return job.status, job.nodeJobID, true
+}
+
+// update records status for a job still tracked and returns it as stored.
+func (t *jobTracker) update(status discovery.JobStatus) discovery.JobStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if job, ok := t.jobs[status.ID]; ok {
+		status.ExpiresAt = job.status.ExpiresAt
+		job.status = status
+	}
+	return status
+}
+
+func (t *jobTracker) list(now time.Time) []discovery.JobStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expireLocked(now)
+	out := make([]discovery.JobStatus, 0, len(t.jobs))
+	for _, job := range t.jobs {
+		out = append(out, job.status)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].SubmittedAt.Equal(out[j].SubmittedAt) {
+			return out[i].SubmittedAt.Before(out[j].SubmittedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func (t *jobTracker) expireLocked(now time.Time) {
+	for id, job := range t.jobs {
+		if !now.Before(job.status.ExpiresAt) {
+			delete(t.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// jobServer runs submitted requests as jobs that stay running until
+// finish is called, echoing the payload as the result.
+type jobServer struct {
+	testInferenceServer
+	features string
+
+	mu       sync.Mutex
+	payloads map[string][]byte
+	states   map[string]discovery.JobState
+}
+
+func newJobServer(features string) *jobServer {
+	return &jobServer{
+		testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}},
+		features:            features,
+		payloads:            make(map[string][]byte),
+		states:              make(map[string]discovery.JobState),
+	}
+}
+
+func (s *jobServer) GetCapabilities(context.Context, *emptypb.Empty) (*pb.Capability, error) {
+	return s.capability(), nil
+}
+
+func (s *jobServer) StreamCapabilities(_ *emptypb.Empty, stream grpc.ServerStreamingServer[pb.Capability]) error {
+	return stream.Send(s.capability())
+}
+
+func (s *jobServer) capability() *pb.Capability {
+	cap := s.testInferenceServer.capability()
+	cap.Extra = map[string]string{discovery.FeaturesExtraKey: s.features}
+	return cap
+}
+
+func (s *jobServer) finish(nodeJobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[nodeJobID] = discovery.JobSucceeded
+}
+
+func (s *jobServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	var req *pb.InferRequest
+	var payload []byte
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		req = r
+		payload = append(payload, r.Payload...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := req.Meta[JobIDMetaKey]
+	resp := &pb.InferResponse{IsFinal: true, Meta: map[string]string{}}
+	switch req.Meta[JobMetaKey] {
+	case JobOpSubmit:
+		id = "node-job-1"
+		s.payloads[id] = payload
+		s.states[id] = discovery.JobRunning
+		resp.Meta[JobIDMetaKey] = id
+	case JobOpStatus:
+		if s.states[id] == discovery.JobRunning {
+			resp.Meta[ProgressMetaKey] = "50"
+		}
+	case JobOpResult:
+		if s.states[id] == discovery.JobSucceeded {
+			resp.Result = s.payloads[id]
+		}
+	case JobOpCancel:
+		if !s.states[id].Done() {
+			s.states[id] = discovery.JobCancelled
+		}
+	default:
+		resp.Result = payload
+		return stream.Send(resp)
+	}
+	resp.Meta[JobStateMetaKey] = string(s.states[id])
+	return stream.Send(resp)
+}
+
+func startJobClient(t *testing.T, srv *jobServer) *LumenClient {
+	t.Helper()
+	c := startClientFor(t, srv)
+	waitUntil(t, func() bool { return len(c.pool.nodeCapabilities("local-node-1")) > 0 })
+	return c
+}
+
+func TestJobLifecycle(t *testing.T) {
+	srv := newJobServer("progress,jobs")
+	c := startJobClient(t, srv)
+	ctx := context.Background()
+
+	id, err := c.SubmitJob(ctx, embedRequest())
+	if err != nil {
+		t.Fatalf("SubmitJob: %v", err)
+	}
+
+	job, err := c.JobStatus(ctx, id)
+	if err != nil {
+		t.Fatalf("JobStatus: %v", err)
+	}
+	if job.State != discovery.JobRunning || job.Progress != 50 || job.NodeID != "local-node-1" {
+		t.Fatalf("job = %+v, want running at 50%% on local-node-1", job)
+	}
+	if _, err := c.JobResult(ctx, id); !utils.HasErrorCode(err, utils.ErrCodeUnavailable) {
+		t.Fatalf("JobResult while running = %v, want UNAVAILABLE", err)
+	}
+
+	srv.finish("node-job-1")
+	resp, err := c.JobResult(ctx, id)
+	if err != nil {
+		t.Fatalf("JobResult: %v", err)
+	}
+	if string(resp.Result) != "hello" {
+		t.Fatalf("result = %q, want the echoed payload", resp.Result)
+	}
+	// Cancelling a finished job leaves it finished.
+	if err := c.CancelJob(ctx, id); err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+	if jobs := c.Jobs(); len(jobs) != 1 || jobs[0].State != discovery.JobSucceeded {
+		t.Fatalf("jobs = %+v, want one succeeded job", jobs)
+	}
+
+	if _, err := c.JobStatus(ctx, "job-unknown"); !utils.HasErrorCode(err, utils.ErrCodeNotFound) {
+		t.Fatalf("JobStatus of an unknown job = %v, want NOT_FOUND", err)
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	c := startJobClient(t, newJobServer("jobs"))
+	ctx := context.Background()
+
+	id, err := c.SubmitJob(ctx, embedRequest())
+	if err != nil {
+		t.Fatalf("SubmitJob: %v", err)
+	}
+	if err := c.CancelJob(ctx, id); err != nil {
+		t.Fatalf("CancelJob: %v", err)
+	}
+	if _, err := c.JobResult(ctx, id); !utils.HasErrorCode(err, utils.ErrCodeRequestFailed) {
+		t.Fatalf("JobResult of a cancelled job = %v, want REQUEST_FAILED", err)
+	}
+}
+
+func TestSubmitJobNeedsNodeSupport(t *testing.T) {
+	c := startJobClient(t, newJobServer("progress"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := c.SubmitJob(ctx, embedRequest()); err == nil {
+		t.Fatal("SubmitJob to a node without jobs succeeded")
+	}
+	if jobs := c.Jobs(); len(jobs) != 0 {
+		t.Fatalf("jobs = %+v, want none", jobs)
+	}
+}
+
+func TestJobTrackerExpiresIdleJobs(t *testing.T) {
+	jobs := newJobTracker(time.Minute)
+	start := time.Now()
+	jobs.add(discovery.JobStatus{ID: "job-1", SubmittedAt: start}, "n1", start)
+	jobs.add(discovery.JobStatus{ID: "job-2", SubmittedAt: start}, "n2", start)
+
+	// A query extends the job's expiry.
+	if _, _, ok := jobs.get("job-1", start.Add(50*time.Second)); !ok {
+		t.Fatal("job-1 expired early")
+	}
+	got := jobs.list(start.Add(90 * time.Second))
+	if len(got) != 1 || got[0].ID != "job-1" {
+		t.Fatalf("jobs = %+v, want only job-1", got)
+	}
+	if _, _, ok := jobs.get("job-1", start.Add(2*time.Minute)); ok {
+		t.Fatal("job-1 outlived its ttl")
+	}
+}
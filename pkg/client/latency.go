@@ -0,0 +1,234 @@
+package client
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the fixed histogram buckets. The
+// final implicit bucket catches everything above the last bound.
+var latencyBuckets = [...]time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// LatencyStats is a percentile summary of observed request latencies.
+// Percentiles are bucket upper bounds, so they over-estimate by at most one
+// bucket width and never exceed Max.
+type LatencyStats struct {
+	Count uint64        `json:"count"`
+	P50   time.Duration `json:"p50_ns"`
+	P95   time.Duration `json:"p95_ns"`
+	P99   time.Duration `json:"p99_ns"`
+	Max   time.Duration `json:"max_ns"`
+}
+
+// latencyHistogram is a fixed-size histogram updated with atomics only.
+type latencyHistogram struct {
+	counts [len(latencyBuckets) + 1]atomic.Uint64
+	max    atomic.Int64
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	for {
+		cur := h.max.Load()
+		if int64(d) <= cur || h.max.CompareAndSwap(cur, int64(d)) {
+			return
+		}
+	}
+}
+
+// addTo accumulates the histogram into counts and returns its max.
+func (h *latencyHistogram) addTo(counts []uint64) time.Duration {
+	for i := range h.counts {
+		counts[i] += h.counts[i].Load()
+	}
+	return time.Duration(h.max.Load())
+}
+
+func summarizeLatency(counts []uint64, maxLatency time.Duration) LatencyStats {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	stats := LatencyStats{Count: total, Max: maxLatency}
+	if total == 0 {
+		return stats
+	}
+	quantile := func(q float64) time.Duration {
+		rank := uint64(q*float64(total) + 0.5)
+		if rank == 0 {
+			rank = 1
+		}
+		var seen uint64
+		for i, c := range counts {
+			seen += c
+			if seen >= rank {
+				if i < len(latencyBuckets) && latencyBuckets[i] < maxLatency {
+					return latencyBuckets[i]
+				}
+				return maxLatency
+			}
+		}
+		return maxLatency
+	}
+	stats.P50 = quantile(0.50)
+	stats.P95 = quantile(0.95)
+	stats.P99 = quantile(0.99)
+	return stats
+}
+
+// latencyTracker is a histogram with an optional sliding window. With a zero
+// window it is cumulative. Otherwise observations land in the current
+// histogram, which is rotated into "previous" once per window; snapshots merge
+// both, so they always cover between one and two windows of traffic.
+type latencyTracker struct {
+	window    time.Duration
+	clock     func() time.Time // nil means time.Now
+	cur       atomic.Pointer[latencyHistogram]
+	prev      atomic.Pointer[latencyHistogram]
+	rotatedAt atomic.Int64
+}
+
+func newLatencyTracker(window time.Duration) *latencyTracker {
+	return newLatencyTrackerWithClock(window, nil)
+}
+
+// newLatencyTrackerWithClock returns a tracker whose window turns on clock
+// rather than the wall clock.
+func newLatencyTrackerWithClock(window time.Duration, clock func() time.Time) *latencyTracker {
+	t := &latencyTracker{window: window, clock: clock}
+	t.cur.Store(&latencyHistogram{})
+	t.rotatedAt.Store(t.now().UnixNano())
+	return t
+}
+
+func (t *latencyTracker) now() time.Time {
+	if t.clock != nil {
+		return t.clock()
+	}
+	return time.Now()
+}
+
+func (t *latencyTracker) observe(d time.Duration) {
+	t.maybeRotate(t.now())
+	t.cur.Load().observe(d)
+}
+
+func (t *latencyTracker) maybeRotate(now time.Time) {
+	if t.window <= 0 {
+		return
+	}
+	last := t.rotatedAt.Load()
+	if now.UnixNano()-last < int64(t.window) {
+		return
+	}
+	if !t.rotatedAt.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	old := t.cur.Swap(&latencyHistogram{})
+	if now.UnixNano()-last >= 2*int64(t.window) {
+		// Idle for more than a full window: the old data is out of range.
+		old = nil
+	}
+	t.prev.Store(old)
+}
+
+func (t *latencyTracker) snapshot() LatencyStats {
+	t.maybeRotate(t.now())
+	counts := make([]uint64, len(latencyBuckets)+1)
+	maxLatency := t.cur.Load().addTo(counts)
+	if prev := t.prev.Load(); prev != nil {
+		if m := prev.addTo(counts); m > maxLatency {
+			maxLatency = m
+		}
+	}
+	return summarizeLatency(counts, maxLatency)
+}
+
+// latencySet keeps one tracker per key (task name or node ID). Per-task
+// trackers of tasks nobody uses any more are dropped by the client's
+// TaskStateRegistry through forget.
+type latencySet struct {
+	window   time.Duration
+	clock    func() time.Time // nil means time.Now
+	trackers sync.Map         // string -> *latencyTracker
+}
+
+func newLatencySet(window time.Duration) *latencySet {
+	return &latencySet{window: window}
+}
+
+func (s *latencySet) observe(key string, d time.Duration) {
+	if s == nil || key == "" {
+		return
+	}
+	v, ok := s.trackers.Load(key)
+	if !ok {
+		v, _ = s.trackers.LoadOrStore(key, newLatencyTrackerWithClock(s.window, s.clock))
+	}
+	v.(*latencyTracker).observe(d)
+}
+
+// get returns the latency percentiles recorded for key.
+func (s *latencySet) get(key string) LatencyStats {
+	if s == nil {
+		return LatencyStats{}
+	}
+	if v, ok := s.trackers.Load(key); ok {
+		return v.(*latencyTracker).snapshot()
+	}
+	return LatencyStats{}
+}
+
+func (s *latencySet) snapshot() map[string]LatencyStats {
+	if s == nil {
+		return nil
+	}
+	out := make(map[string]LatencyStats)
+	s.trackers.Range(func(k, v any) bool {
+		out[k.(string)] = v.(*latencyTracker).snapshot()
+		return true
+	})
+	return out
+}
+
+// keys returns the keys holding a tracker.
+func (s *latencySet) keys() []string {
+	if s == nil {
+		return nil
+	}
+	var out []string
+	s.trackers.Range(func(k, _ any) bool {
+		out = append(out, k.(string))
+		return true
+	})
+	return out
+}
+
+// forget drops key's tracker.
+func (s *latencySet) forget(key string) {
+	if s == nil {
+		return
+	}
+	s.trackers.Delete(key)
+}
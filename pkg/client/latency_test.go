@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+func TestLatencyTrackerPercentiles(t *testing.T) {
+	tr := newLatencyTracker(0)
+	for i := 0; i < 98; i++ {
+		tr.observe(3 * time.Millisecond)
+	}
+	tr.observe(400 * time.Millisecond)
+	tr.observe(3 * time.Second)
+
+	s := tr.snapshot()
+	if s.Count != 100 {
+		t.Fatalf("count = %d, want 100", s.Count)
+	}
+	if s.P50 != 5*time.Millisecond {
+		t.Fatalf("p50 = %v, want 5ms bucket", s.P50)
+	}
+	if s.P95 != 5*time.Millisecond {
+		t.Fatalf("p95 = %v, want 5ms bucket", s.P95)
+	}
+	if s.P99 != 500*time.Millisecond {
+		t.Fatalf("p99 = %v, want 500ms bucket", s.P99)
+	}
+	if s.Max != 3*time.Second {
+		t.Fatalf("max = %v, want 3s", s.Max)
+	}
+}
+
+func TestLatencyTrackerPercentileNeverExceedsMax(t *testing.T) {
+	tr := newLatencyTracker(0)
+	tr.observe(1500 * time.Microsecond)
+	if s := tr.snapshot(); s.P99 != 1500*time.Microsecond {
+		t.Fatalf("p99 = %v, want capped at max 1.5ms", s.P99)
+	}
+}
+
+func TestLatencyTrackerSlidingWindowDropsOldData(t *testing.T) {
+	tr := newLatencyTracker(time.Minute)
+	tr.observe(time.Second)
+
+	// Simulate an idle period longer than two windows.
+	tr.rotatedAt.Store(time.Now().Add(-3 * time.Minute).UnixNano())
+	if s := tr.snapshot(); s.Count != 0 {
+		t.Fatalf("count after idle = %d, want 0", s.Count)
+	}
+
+	tr.observe(10 * time.Millisecond)
+	tr.rotatedAt.Store(time.Now().Add(-61 * time.Second).UnixNano())
+	if s := tr.snapshot(); s.Count != 1 {
+		t.Fatalf("count after one rotation = %d, want previous window kept", s.Count)
+	}
+}
+
+func TestGetMetricsReportsTaskLatency(t *testing.T) {
+	c := newFakeLumenClient(&streamInferenceClient{stream: &fakeInferStream{
+		responses: []*pb.InferResponse{{IsFinal: true}},
+	}}, config.ChunkConfig{})
+	c.latency = newLatencyTracker(0)
+	c.taskLatency = newLatencySet(0)
+
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+	if _, err := c.Infer(context.Background(), req); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+
+	m := c.GetMetrics()
+	if m.Latency.Count != 1 {
+		t.Fatalf("latency count = %d, want 1", m.Latency.Count)
+	}
+	if m.TaskLatency[types.TaskSemanticTextEmbed].Count != 1 {
+		t.Fatalf("task latency = %+v", m.TaskLatency)
+	}
+}
+
+func BenchmarkLatencyTrackerObserve(b *testing.B) {
+	tr := newLatencyTracker(time.Minute)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tr.observe(7 * time.Millisecond)
+		}
+	})
+}
+
+// BenchmarkInferMetrics compares the Infer hot path with and without
+// latency percentile tracking against an in-memory fake node.
+func BenchmarkInferMetrics(b *testing.B) {
+	run := func(b *testing.B, track bool) {
+		c := newFakeLumenClient(nil, config.ChunkConfig{})
+		if track {
+			c.latency = newLatencyTracker(0)
+			c.taskLatency = newLatencySet(0)
+		}
+		req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.pool.cli = &streamInferenceClient{stream: &fakeInferStream{
+				responses: []*pb.InferResponse{{IsFinal: true}},
+			}}
+			if _, err := c.Infer(context.Background(), req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.Run("counters-only", func(b *testing.B) { run(b, false) })
+	b.Run("with-percentiles", func(b *testing.B) { run(b, true) })
+}
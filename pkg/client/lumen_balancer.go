@@ -4,20 +4,25 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"slices"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/tasks"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
 	pb "github.com/edwinzhancn/lumen-sdk/proto"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
-	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -26,6 +31,23 @@ type balancerOptions struct {
 	connectTimeout        time.Duration
 	rediscoveryBackoffMin time.Duration
 	rediscoveryBackoffMax time.Duration
+	capFetchTimeout       time.Duration
+	maxConcurrentProbes   int
+	maxConnections        int
+	maxLifetime           time.Duration
+	healthInterval        time.Duration
+	streamWarnThreshold   int
+	streamMaxAge          time.Duration
+	// strategies are the selection strategies requests are routed
+	// through; nil means round-robin alone.
+	strategies *strategies
+	// requireConfirmed keeps a node out of selection until its capabilities
+	// are fetched, rather than routing on its TXT task hints.
+	requireConfirmed bool
+	// dialOptions configure the side connections used for probes; their
+	// credentials come from transport, resolved per node like the SubConns'.
+	dialOptions []grpc.DialOption
+	transport   *nodeCredentials
 }
 
 var balancerSeq int64
@@ -50,44 +72,211 @@ type nodeRegistry struct {
 	mu        sync.RWMutex
 	nodes     map[string]*registeredNode
 	onChanged func()
+	// onCapabilityChange is called with every non-empty capability diff
+	// found when a node's capabilities are re-fetched.
+	onCapabilityChange func(discovery.CapabilityDiff)
+	// onAddressChange is called when a known node moves to a new address.
+	onAddressChange func(discovery.NodeAddressChanged)
+	// onSelection is called synchronously with every routing decision.
+	onSelection func(discovery.SelectionDecision)
+
+	// lastPicks is the latest routing decision per task, cursors each
+	// task's round-robin position and selections the picks made per
+	// strategy; all are guarded by pickMu so Pick never waits on mu.
+	pickMu     sync.Mutex
+	lastPicks  map[string]discovery.SelectionDecision
+	cursors    map[string]*taskCursor
+	selections map[string]int64
+
+	// transitions counts connection state changes keyed "FROM->TO";
+	// guarded by mu.
+	transitions map[string]int64
+
+	// inFlightProbes counts capability fetches currently holding a probe slot.
+	inFlightProbes atomic.Int64
+	// latency records per-node RPC latency, measured from pick to done.
+	latency *latencySet
+	// failures counts failed RPCs per node by FailureClass.
+	failures *failureSet
+	// picker is the balancer's current picker, kept so ExplainSelection can
+	// replay its filtering without dispatching a request.
+	picker atomic.Pointer[lumenPicker]
+
+	// drains are the drained nodes by key, guarded by drainMu. draining
+	// republishes when each was drained on every change so Pick can read
+	// them without locking.
+	drainMu  sync.Mutex
+	drains   map[string]*nodeDrain
+	draining atomic.Pointer[map[string]time.Time]
+	// onDrained is called when a drained node's last in-flight request
+	// finishes.
+	onDrained func(discovery.NodeDrained)
+
+	// outliers ejects nodes failing or slowing down far more than the rest;
+	// nil when outlier detection is off. onEjection is called when a node
+	// is ejected and again when its re-admission starts.
+	outliers   *outlierDetector
+	onEjection func(discovery.NodeEjection)
+
+	// health damps each node's health-check driven status.
+	health *statusDamper
+	// onHealthy is called with the key of every node that passes a health
+	// check.
+	onHealthy func(key string)
+	// recent keeps each node's latest failures for node detail.
+	recent *recentErrors
+
+	// statuses is each node's status as NodeInfo reports it, moved with
+	// NodeState.TransitionTo; guarded by statusMu, which is taken before
+	// mu. onStatusChange is called with every transition.
+	statusMu       sync.Mutex
+	statuses       map[string]*discovery.NodeState
+	onStatusChange func(discovery.NodeStatusChanged)
+
+	// logger logs what the registry itself decides; nil logs nothing.
+	logger *zap.Logger
+	// clock runs the balancer's health checks, retries, cooldowns and
+	// timers and times the statuses they lead to; nil is the wall clock. A
+	// VirtualPool runs the balancer on its own.
+	clock utils.Clock
+}
+
+// now is the time on the registry's clock.
+func (r *nodeRegistry) now() time.Time {
+	return utils.ClockOrReal(r.clock).Now()
 }
 
 type registeredNode struct {
-	identity      discovery.NodeIdentity
-	addr          string
-	state         connectivity.State
-	capabilities  []*pb.Capability
-	tasks         []string
-	hardFailures  int
-	cooldownUntil time.Time
-	cooldown      time.Duration
-	txt           map[string]string
+	identity       discovery.NodeIdentity
+	addr           string
+	state          connectivity.State
+	capabilities   []*pb.Capability
+	features       []string
+	tasks          []string
+	taskSet        *taskSet
+	hardFailures   int
+	healthFailures int
+	held           bool
+	cooldownUntil  time.Time
+	cooldown       time.Duration
+	txt            map[string]string
+	probeFailures  int
+	nextProbe      time.Time
+	lastCapDiff    *discovery.CapabilityDiff
+	staleServices  []string
+	lastErr        *discovery.NodeError
+	connLost       bool
+	streams        *nodeStreams
 }
 
+// nodeInfos returns every node as NodeInfo reports it. It only reads:
+// statuses are recorded by syncStatuses as the layers behind them change.
 func (r *nodeRegistry) nodeInfos() []*discovery.NodeInfo {
+	r.statusMu.Lock()
+	now := r.now()
+	draining := r.drainingNodes()
 	r.mu.RLock()
-	defer r.mu.RUnlock()
 
 	out := make([]*discovery.NodeInfo, 0, len(r.nodes))
 	for _, rn := range r.nodes {
 		availability := availabilityFromRegistered(rn)
-		out = append(out, &discovery.NodeInfo{
+		// Tasks point into the cloned capabilities, so nothing the caller
+		// changes reaches the registry.
+		caps := discovery.CloneCapabilities(rn.capabilities)
+		info := &discovery.NodeInfo{
 			ID:           rn.identity.Key(),
 			Address:      rn.addr,
-			Status:       availability.NodeStatus(),
 			Availability: availability,
 			Metadata:     buildCapabilityMetadata(rn.capabilities),
 			Models:       buildModelInfos(rn.capabilities),
-			Tasks:        tasksToIOTasksFromCapabilities(rn.capabilities, rn.tasks),
-			Capabilities: discovery.CloneCapabilities(rn.capabilities),
+			Tasks:        tasksToIOTasksFromCapabilities(caps, rn.tasks),
+			Capabilities: caps,
+			Features:     rn.features,
 			Version:      rn.txt["v"],
 			Runtime:      rn.txt["runtime"],
-			LastSeen:     time.Now(),
-		})
+			Source:       rn.txt[discovery.SourceTxtKey],
+			LastSeen:     now,
+		}
+		if load, err := strconv.ParseFloat(rn.txt[discovery.LoadTxtKey], 64); err == nil {
+			info.Load = load
+		}
+		if rn.probeFailures >= quarantineThreshold {
+			info.NextProbe = rn.nextProbe
+		}
+		info.InFlight = int(rn.streams.inFlight())
+		info.RequestFailures = rn.hardFailures
+		info.HealthCheckFailures = rn.healthFailures
+		info.HealthScore = 1
+		health, checked := r.health.view(info.ID, now)
+		if checked {
+			info.HealthScore = health.score
+			info.Flaps = health.flaps
+			info.SuspectUntil = health.suspectUntil
+		}
+		ejection := r.outliers.ejection(info.ID, now)
+		info.Ejection = ejection
+		drainingSince, drained := draining[info.ID]
+		if drained {
+			info.DrainingSince = drainingSince
+		}
+
+		if state := r.statuses[info.ID]; state != nil {
+			info.Status = state.Status
+			info.StatusReason = state.Reason
+			info.StatusSince = state.Since
+			info.StatusHistory = append([]discovery.StatusTransition(nil), state.History...)
+		} else {
+			// Not recorded yet: the node arrived after the last sync.
+			status := deriveNodeStatus(rn, availability, health, checked, ejection, drained)
+			info.Status = status.status
+			info.StatusReason = status.reason
+		}
+		// A node known only by its TXT hints reports the concurrency it
+		// hinted until its capabilities say.
+		if len(rn.capabilities) == 0 && len(rn.tasks) > 0 {
+			if hints := discovery.ParseCapabilityHints(rn.txt); hints.MaxConcurrency > 0 {
+				info.Metadata = map[string]interface{}{discovery.MaxConcurrencyTxtKey: hints.MaxConcurrency}
+			}
+		}
+		if rn.lastCapDiff != nil {
+			diff := *rn.lastCapDiff
+			info.LastCapabilityChange = diff.At
+			info.LastCapabilityDiff = &diff
+		}
+		if rn.lastErr != nil {
+			lastErr := *rn.lastErr
+			info.LastError = &lastErr
+		}
+		info.StaleServices = rn.staleServices
+		out = append(out, info)
 	}
+	r.mu.RUnlock()
+	r.statusMu.Unlock()
+
+	discovery.SortNodes(out)
 	return out
 }
 
+// uniqueService returns the service of the first node, by ID, that serves
+// task under exactly one service, or "" when none does. Unlike nodeInfos it
+// copies nothing, so it suits every request.
+func (r *nodeRegistry) uniqueService(task string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]string, 0, len(r.nodes))
+	for key := range r.nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		node := discovery.NodeInfo{Capabilities: r.nodes[key].capabilities}
+		if services := node.MatchingServices(task); len(services) == 1 {
+			return services[0]
+		}
+	}
+	return ""
+}
+
 func (r *nodeRegistry) stats() (total, healthy int) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -100,6 +289,96 @@ func (r *nodeRegistry) stats() (total, healthy int) {
 	return
 }
 
+// recordTransition counts a node connection moving from one state to another.
+func (r *nodeRegistry) recordTransition(from, to connectivity.State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.transitions == nil {
+		r.transitions = make(map[string]int64)
+	}
+	r.transitions[from.String()+"->"+to.String()]++
+}
+
+func (r *nodeRegistry) stateTransitions() map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]int64, len(r.transitions))
+	for k, v := range r.transitions {
+		out[k] = v
+	}
+	return out
+}
+
+// probeFailures returns the consecutive capability-fetch failure count of
+// every node that has failed at least once since its last successful fetch.
+func (r *nodeRegistry) probeFailures() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]int)
+	for key, rn := range r.nodes {
+		if rn.probeFailures > 0 {
+			out[key] = rn.probeFailures
+		}
+	}
+	return out
+}
+
+// lastErrors returns the last connection error of every node that has
+// failed to connect at least once.
+func (r *nodeRegistry) lastErrors() map[string]discovery.NodeError {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]discovery.NodeError)
+	for key, rn := range r.nodes {
+		if rn.lastErr != nil {
+			out[key] = *rn.lastErr
+		}
+	}
+	return out
+}
+
+// streamStats returns the stream counts of every node that has opened a
+// stream.
+func (r *nodeRegistry) streamStats() map[string]StreamStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]StreamStats)
+	for key, rn := range r.nodes {
+		if rn.streams != nil && rn.streams.total.Load() > 0 {
+			out[key] = rn.streams.snapshot()
+		}
+	}
+	return out
+}
+
+// quarantined returns how many nodes are quarantined for failing their
+// capability fetches.
+func (r *nodeRegistry) quarantined() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := 0
+	for _, rn := range r.nodes {
+		if rn.probeFailures >= quarantineThreshold {
+			n++
+		}
+	}
+	return n
+}
+
+// provisional counts the Ready nodes routed on their TXT task hints alone,
+// whose capabilities have not been fetched.
+func (r *nodeRegistry) provisional() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := 0
+	for _, rn := range r.nodes {
+		if rn.state == connectivity.Ready && len(rn.capabilities) == 0 && len(rn.tasks) > 0 && rn.probeFailures < quarantineThreshold {
+			n++
+		}
+	}
+	return n
+}
+
 // --- Balancer Builder ---
 
 type lumenBalancerBuilder struct {
@@ -112,29 +391,105 @@ type lumenBalancerBuilder struct {
 func (b *lumenBalancerBuilder) Name() string { return b.name }
 
 func (b *lumenBalancerBuilder) Build(cc balancer.ClientConn, _ balancer.BuildOptions) balancer.Balancer {
-	return &lumenBalancer{
+	lb := &lumenBalancer{
 		cc:       cc,
 		subConns: make(map[string]*subConnState),
 		registry: b.registry,
 		options:  b.opts,
 		logger:   b.logger,
+		probeSem: make(chan struct{}, b.opts.maxConcurrentProbes),
+		done:     make(chan struct{}),
+	}
+	if b.opts.healthInterval > 0 {
+		go lb.healthCheckLoop(b.opts.healthInterval)
 	}
+	if lb.registry != nil && lb.registry.outliers != nil {
+		go lb.outlierLoop(lb.registry.outliers.cfg.Interval)
+	}
+	return lb
 }
 
 // --- Balancer ---
 
 type subConnState struct {
-	sc            balancer.SubConn
-	addr          resolver.Address
-	identity      discovery.NodeIdentity
-	state         connectivity.State
-	capabilities  []*pb.Capability
-	tasks         []string
-	hardFailures  int
+	sc           balancer.SubConn
+	addr         resolver.Address
+	identity     discovery.NodeIdentity
+	state        connectivity.State
+	capabilities []*pb.Capability
+	// features are the wire features the capabilities advertise, derived
+	// when they are fetched.
+	features []string
+	// hintTasks are the tasks advertised in discovery (TXT records). tasks
+	// is their union with the fetched capabilities' tasks and changes only
+	// through refreshTasksLocked, which keeps taskSet, the lookup the picker
+	// filters on, in step with it.
+	hintTasks []string
+	tasks     []string
+	taskSet   *taskSet
+	// hardFailures counts consecutive failed requests and connection
+	// attempts, healthFailures consecutive failed Health RPCs; either
+	// reaching hardFailureThreshold cools the node down.
+	hardFailures   int
+	healthFailures int
+	// held is set while the node's damped status is error or suspect: it
+	// then takes only probe traffic, whatever its cooldown.
+	held          bool
 	cooldownUntil time.Time
 	cooldown      time.Duration
 	txt           map[string]string
 	capFetching   bool
+	probeFailures int
+	lastCapDiff   *discovery.CapabilityDiff
+	// staleServices are the services whose previous capabilities the last
+	// partial capability fetch kept; see mergeCapabilities.
+	staleServices []string
+	// lastErr is the node's most recent connection failure, classified;
+	// it is cleared once the node is Ready again.
+	lastErr *discovery.NodeError
+	// connLost is set from the moment a Ready connection drops until it is
+	// Ready again; the node is reported in error meanwhile.
+	connLost bool
+
+	// nextProbe is when a quarantined node's capabilities are fetched
+	// again; probeTimer fires then.
+	nextProbe  time.Time
+	probeTimer utils.Timer
+
+	// replacement is the SubConn dialled to take over once this one has
+	// outlived maxLifetime; it is promoted when it becomes Ready.
+	replacement    balancer.SubConn
+	recycleTimer   utils.Timer
+	healthChecking bool
+	// healthRetry re-runs a failed Health RPC with backoff, ahead of the
+	// next health-check tick.
+	healthRetry utils.Timer
+
+	// streams counts RPCs picked for this node, open and in total.
+	streams *nodeStreams
+
+	// live is the node a picker's copy was taken from; nil on the node
+	// itself. See snapshotLocked.
+	live *subConnState
+}
+
+// snapshotLocked returns a copy of the node for a picker to route on. Pick
+// runs concurrently with the balancer, which goes on rewriting the node's
+// tasks, capabilities and cooldown under lb.mu; the copy keeps what the
+// picker was built with. Callers hold lb.mu.
+func (scs *subConnState) snapshotLocked() *subConnState {
+	cp := *scs
+	cp.live = scs
+	return &cp
+}
+
+// node returns the node a picker's copy was taken from, which outcomes are
+// recorded on; scs itself when it is not a copy.
+func (scs *subConnState) node() *subConnState {
+	if scs.live != nil {
+		return scs.live
+	}
+	return scs
 }
 
 type lumenBalancer struct {
@@ -144,6 +499,15 @@ type lumenBalancer struct {
 	registry *nodeRegistry
 	options  balancerOptions
 	logger   *zap.Logger
+
+	// probeSem bounds the number of concurrent capability fetches so a burst
+	// of Ready transitions (e.g. after a network flap) cannot dial every node
+	// at once.
+	probeSem chan struct{}
+
+	// done is closed by Close to stop background health checks.
+	done   chan struct{}
+	closed bool
 }
 
 func (lb *lumenBalancer) UpdateClientConnState(state balancer.ClientConnState) error {
@@ -151,6 +515,25 @@ func (lb *lumenBalancer) UpdateClientConnState(state balancer.ClientConnState) e
 	defer lb.mu.Unlock()
 
 	activeKeys := make(map[string]bool, len(state.ResolverState.Addresses))
+	for _, addr := range state.ResolverState.Addresses {
+		if attr, ok := getNodeAttr(addr); ok {
+			activeKeys[attr.Identity.Key()] = true
+		}
+	}
+
+	// Remove vanished nodes first so their slots count towards
+	// maxConnections for newly discovered ones.
+	for key, scs := range lb.subConns {
+		if activeKeys[key] {
+			continue
+		}
+		lb.removeSubConnLocked(scs)
+		delete(lb.subConns, key)
+		if lb.registry != nil {
+			lb.registry.health.forget(key)
+			lb.registry.recent.forget(key)
+		}
+	}
 
 	for _, addr := range state.ResolverState.Addresses {
 		attr, ok := getNodeAttr(addr)
@@ -158,70 +541,126 @@ func (lb *lumenBalancer) UpdateClientConnState(state balancer.ClientConnState) e
 			continue
 		}
 		key := attr.Identity.Key()
-		activeKeys[key] = true
 
 		existing, exists := lb.subConns[key]
 		if exists {
 			if existing.addr.Addr != addr.Addr {
+				lb.publishAddressChange(discovery.NodeAddressChanged{
+					NodeID:     key,
+					OldAddress: existing.addr.Addr,
+					NewAddress: addr.Addr,
+					At:         lb.now(),
+				})
 				existing.addr = addr
 				lb.cc.UpdateAddresses(existing.sc, []resolver.Address{addr})
+				lb.dropReplacementLocked(existing)
+				// A node re-advertised on a new address gets a fresh
+				// start rather than waiting out its old quarantine.
+				if lb.clearProbeFailuresLocked(existing) {
+					lb.log().Info("node left quarantine: address changed",
+						zap.String("id", key),
+						zap.String("address", addr.Addr),
+					)
+				}
 			}
-			existing.tasks = mergeTasks(existing.tasks, attr.Tasks)
+			existing.hintTasks = attr.Tasks
+			existing.refreshTasksLocked()
 			existing.txt = attr.Txt
 			continue
 		}
 
-		sc, err := lb.cc.NewSubConn([]resolver.Address{addr}, balancer.NewSubConnOptions{
-			StateListener: lb.makeStateListener(key),
-		})
+		if limit := lb.options.maxConnections; limit > 0 && len(lb.subConns) >= limit {
+			lb.log().Debug("connection limit reached; ignoring node",
+				zap.String("id", key),
+				zap.Int("max_connections", limit),
+			)
+			continue
+		}
+
+		sc, err := lb.newSubConnLocked(key, addr)
 		if err != nil {
 			lb.log().Warn("failed to create SubConn", zap.String("id", key), zap.Error(err))
 			continue
 		}
-		lb.subConns[key] = &subConnState{
-			sc:       sc,
-			addr:     addr,
-			identity: attr.Identity,
-			state:    connectivity.Idle,
-			tasks:    attr.Tasks,
-			txt:      attr.Txt,
+		scs := &subConnState{
+			sc:        sc,
+			addr:      addr,
+			identity:  attr.Identity,
+			state:     connectivity.Idle,
+			hintTasks: attr.Tasks,
+			txt:       attr.Txt,
+			streams:   new(nodeStreams),
 		}
+		scs.refreshTasksLocked()
+		lb.subConns[key] = scs
 		sc.Connect()
 	}
 
-	for key, scs := range lb.subConns {
-		if activeKeys[key] {
-			continue
-		}
-		lb.cc.RemoveSubConn(scs.sc)
-		delete(lb.subConns, key)
-	}
-
 	lb.syncRegistryLocked()
 	lb.rebuildPickerLocked()
 	return nil
 }
 
-func (lb *lumenBalancer) makeStateListener(key string) func(balancer.SubConnState) {
-	return func(state balancer.SubConnState) {
-		lb.handleSubConnStateChange(key, state)
+// newSubConnLocked creates a SubConn whose state updates are attributed to
+// key. The listener also carries the SubConn itself, so updates from a
+// connection that has since been replaced are ignored.
+func (lb *lumenBalancer) newSubConnLocked(key string, addr resolver.Address) (balancer.SubConn, error) {
+	var sc balancer.SubConn
+	sc, err := lb.cc.NewSubConn([]resolver.Address{addr}, balancer.NewSubConnOptions{
+		StateListener: func(state balancer.SubConnState) {
+			lb.handleSubConnStateChange(key, sc, state)
+		},
+	})
+	return sc, err
+}
+
+func (lb *lumenBalancer) removeSubConnLocked(scs *subConnState) {
+	if scs.recycleTimer != nil {
+		scs.recycleTimer.Stop()
+	}
+	if scs.probeTimer != nil {
+		scs.probeTimer.Stop()
+	}
+	if scs.healthRetry != nil {
+		scs.healthRetry.Stop()
+	}
+	lb.dropReplacementLocked(scs)
+	lb.cc.RemoveSubConn(scs.sc)
+}
+
+func (lb *lumenBalancer) dropReplacementLocked(scs *subConnState) {
+	if scs.replacement != nil {
+		lb.cc.RemoveSubConn(scs.replacement)
+		scs.replacement = nil
 	}
 }
 
-func (lb *lumenBalancer) handleSubConnStateChange(key string, state balancer.SubConnState) {
+func (lb *lumenBalancer) handleSubConnStateChange(key string, sc balancer.SubConn, state balancer.SubConnState) {
 	lb.mu.Lock()
 	scs, ok := lb.subConns[key]
 	if !ok {
 		lb.mu.Unlock()
 		return
 	}
+	if scs.sc != sc {
+		if scs.replacement == sc {
+			lb.handleReplacementStateLocked(key, scs, state)
+		}
+		lb.mu.Unlock()
+		return
+	}
 	prevState := scs.state
 	scs.state = state.ConnectivityState
+	if lb.registry != nil && prevState != state.ConnectivityState {
+		lb.registry.recordTransition(prevState, state.ConnectivityState)
+	}
 
 	if state.ConnectivityState == connectivity.Ready && prevState != connectivity.Ready {
 		scs.hardFailures = 0
 		scs.cooldownUntil = time.Time{}
 		scs.cooldown = 0
+		scs.lastErr = nil
+		lb.scheduleRecycleLocked(key, scs)
 		// Publish the Ready node immediately (TXT task hints may already allow
 		// routing); the capability fetch refines the task set asynchronously
 		// and retries with backoff instead of giving up on one failure.
@@ -229,16 +668,60 @@ func (lb *lumenBalancer) handleSubConnStateChange(key string, state balancer.Sub
 			scs.capFetching = true
 			go lb.fetchCapabilitiesWithRetry(key, scs.addr.Addr)
 		}
+		// A node that was failing health checks, or whose connection
+		// dropped, is probed as soon as its transport is back, rather than
+		// at the next tick or retry.
+		if scs.healthFailures > 0 || scs.connLost {
+			lb.retryHealthLocked(key, scs, 0)
+		}
+		if scs.connLost {
+			scs.connLost = false
+			lb.connectionChangedLocked(key, true)
+		}
 		lb.syncRegistryLocked()
 		lb.rebuildPickerLocked()
 		lb.mu.Unlock()
 		return
 	}
 
+	// A Ready connection dropping (the node crashed, restarted or became
+	// unreachable) takes the node out of the picker below at once, and
+	// marks it in error until it is Ready again, instead of leaving it to
+	// the next health check or a failed request.
+	if prevState == connectivity.Ready && !scs.connLost {
+		scs.connLost = true
+		if state.ConnectionError == nil {
+			lost := utils.ConnectionFailedError(scs.addr.Addr + " (connection lost)")
+			scs.lastErr = nodeError(lost, lb.now())
+			lb.recordError(key, discovery.NodeOpDial, lost, "")
+		}
+		lb.log().Debug("node connection lost",
+			zap.String("id", key),
+			zap.String("state", state.ConnectivityState.String()),
+		)
+		lb.connectionChangedLocked(key, false)
+	}
+
+	if state.ConnectivityState != connectivity.Ready && scs.recycleTimer != nil {
+		scs.recycleTimer.Stop()
+		scs.recycleTimer = nil
+		lb.dropReplacementLocked(scs)
+	}
+
 	if state.ConnectivityState == connectivity.TransientFailure {
+		if state.ConnectionError != nil {
+			lumErr := classifyConnError(scs.addr.Addr, state.ConnectionError)
+			scs.lastErr = nodeError(lumErr, lb.now())
+			lb.recordError(key, discovery.NodeOpDial, lumErr, "")
+			lb.log().Debug("node connection failed",
+				zap.String("id", key),
+				zap.String("code", string(lumErr.Code)),
+				zap.Error(state.ConnectionError),
+			)
+		}
 		scs.hardFailures++
 		if scs.hardFailures >= hardFailureThreshold {
-			lb.startCooldownLocked(scs, time.Now())
+			lb.startCooldownLocked(scs, lb.now())
 		}
 	}
 
@@ -251,6 +734,73 @@ func (lb *lumenBalancer) handleSubConnStateChange(key string, state balancer.Sub
 	lb.mu.Unlock()
 }
 
+// recordError keeps err among the node's recent errors.
+func (lb *lumenBalancer) recordError(key, op string, err error, correlationID string) {
+	if lb.registry == nil {
+		return
+	}
+	lb.registry.recent.record(key, op, err, correlationID, lb.now())
+}
+
+// scheduleRecycleLocked arms the maxLifetime timer for the node's current
+// SubConn.
+func (lb *lumenBalancer) scheduleRecycleLocked(key string, scs *subConnState) {
+	if lb.options.maxLifetime <= 0 {
+		return
+	}
+	if scs.recycleTimer != nil {
+		scs.recycleTimer.Stop()
+	}
+	sc := scs.sc
+	scs.recycleTimer = lb.clock().AfterFunc(lb.options.maxLifetime, func() {
+		lb.recycle(key, sc)
+	})
+}
+
+// recycle dials a replacement for a SubConn that has outlived maxLifetime.
+// The old SubConn keeps serving until the replacement is Ready.
+func (lb *lumenBalancer) recycle(key string, sc balancer.SubConn) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	scs, ok := lb.subConns[key]
+	if lb.closed || !ok || scs.sc != sc || scs.state != connectivity.Ready || scs.replacement != nil {
+		return
+	}
+	replacement, err := lb.newSubConnLocked(key, scs.addr)
+	if err != nil {
+		lb.log().Warn("failed to create replacement SubConn", zap.String("id", key), zap.Error(err))
+		lb.scheduleRecycleLocked(key, scs)
+		return
+	}
+	scs.replacement = replacement
+	replacement.Connect()
+}
+
+// handleReplacementStateLocked promotes a Ready replacement or abandons one
+// that failed to connect, retrying after another maxLifetime.
+func (lb *lumenBalancer) handleReplacementStateLocked(key string, scs *subConnState, state balancer.SubConnState) {
+	switch state.ConnectivityState {
+	case connectivity.Ready:
+		// Pickers built earlier still reference scs, so the promoted
+		// connection gets a fresh subConnState rather than a mutated one.
+		fresh := *scs
+		fresh.sc = scs.replacement
+		fresh.replacement = nil
+		fresh.recycleTimer = nil
+		lb.subConns[key] = &fresh
+		lb.cc.RemoveSubConn(scs.sc)
+		lb.scheduleRecycleLocked(key, &fresh)
+		lb.log().Debug("recycled node connection", zap.String("id", key))
+		lb.syncRegistryLocked()
+		lb.rebuildPickerLocked()
+	case connectivity.TransientFailure:
+		lb.dropReplacementLocked(scs)
+		lb.scheduleRecycleLocked(key, scs)
+	case connectivity.Idle:
+		scs.replacement.Connect()
+	}
+}
+
 func (lb *lumenBalancer) startCooldownLocked(scs *subConnState, now time.Time) {
 	next := lb.options.rediscoveryBackoffMin
 	if scs.cooldown > 0 {
@@ -269,7 +819,23 @@ func (lb *lumenBalancer) ResolverError(err error) {
 
 func (lb *lumenBalancer) UpdateSubConnState(_ balancer.SubConn, _ balancer.SubConnState) {}
 
-func (lb *lumenBalancer) Close() {}
+func (lb *lumenBalancer) Close() {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.closed {
+		return
+	}
+	lb.closed = true
+	close(lb.done)
+	for _, scs := range lb.subConns {
+		if scs.recycleTimer != nil {
+			scs.recycleTimer.Stop()
+		}
+		if scs.healthRetry != nil {
+			scs.healthRetry.Stop()
+		}
+	}
+}
 
 func (lb *lumenBalancer) ExitIdle() {
 	lb.mu.Lock()
@@ -282,25 +848,47 @@ func (lb *lumenBalancer) ExitIdle() {
 }
 
 func (lb *lumenBalancer) rebuildPickerLocked() {
-	now := time.Now()
+	now := lb.now()
 	var ready []*subConnState
 	var probes []*subConnState
 
 	for _, scs := range lb.subConns {
+		if lb.options.requireConfirmed && len(scs.capabilities) == 0 {
+			continue
+		}
 		switch {
+		case scs.state == connectivity.Ready && scs.held:
+			if scs.cooldownUntil.IsZero() || now.After(scs.cooldownUntil) {
+				probes = append(probes, scs.snapshotLocked())
+			}
 		case scs.state == connectivity.Ready:
 			if scs.cooldownUntil.IsZero() || now.After(scs.cooldownUntil) {
-				ready = append(ready, scs)
+				ready = append(ready, scs.snapshotLocked())
 			}
 		case scs.state != connectivity.Shutdown && !scs.cooldownUntil.IsZero() && now.After(scs.cooldownUntil):
-			probes = append(probes, scs)
+			probes = append(probes, scs.snapshotLocked())
 		}
 	}
 
+	// A stable order keeps each task's rotation position meaningful
+	// across rebuilds.
+	sortByKey(ready)
+	sortByKey(probes)
 	picker := &lumenPicker{
-		ready:    ready,
-		probes:   probes,
-		balancer: lb,
+		ready:      ready,
+		probes:     probes,
+		balancer:   lb,
+		strategies: lb.options.strategies,
+	}
+	if picker.strategies != nil && lb.registry != nil {
+		infos := lb.registry.nodeInfos()
+		picker.infos = make(map[string]*discovery.NodeInfo, len(infos))
+		for _, info := range infos {
+			picker.infos[info.ID] = info
+		}
+	}
+	if lb.registry != nil {
+		lb.registry.picker.Store(picker)
 	}
 
 	var aggState connectivity.State
@@ -330,37 +918,101 @@ func (lb *lumenBalancer) syncRegistryLocked() {
 	lb.registry.nodes = make(map[string]*registeredNode, len(lb.subConns))
 	for key, scs := range lb.subConns {
 		lb.registry.nodes[key] = &registeredNode{
-			identity:      scs.identity,
-			addr:          scs.addr.Addr,
-			state:         scs.state,
-			capabilities:  scs.capabilities,
-			tasks:         scs.tasks,
-			hardFailures:  scs.hardFailures,
-			cooldownUntil: scs.cooldownUntil,
-			cooldown:      scs.cooldown,
-			txt:           scs.txt,
+			identity:       scs.identity,
+			addr:           scs.addr.Addr,
+			state:          scs.state,
+			capabilities:   scs.capabilities,
+			features:       scs.features,
+			tasks:          scs.tasks,
+			taskSet:        scs.taskSet,
+			hardFailures:   scs.hardFailures,
+			healthFailures: scs.healthFailures,
+			held:           scs.held,
+			cooldownUntil:  scs.cooldownUntil,
+			cooldown:       scs.cooldown,
+			txt:            scs.txt,
+			probeFailures:  scs.probeFailures,
+			nextProbe:      scs.nextProbe,
+			lastCapDiff:    scs.lastCapDiff,
+			staleServices:  scs.staleServices,
+			lastErr:        scs.lastErr,
+			connLost:       scs.connLost,
+			streams:        scs.streams,
 		}
 	}
 	lb.registry.mu.Unlock()
 
+	lb.registry.syncStatuses()
 	if lb.registry.onChanged != nil {
 		go lb.registry.onChanged()
 	}
 }
 
 const (
-	capFetchBackoffMin = 1 * time.Second
-	capFetchBackoffMax = 8 * time.Second
+	capFetchBackoffMin     = 1 * time.Second
+	capFetchBackoffMax     = 8 * time.Second
+	defaultCapFetchTimeout = 10 * time.Second
+
+	// quarantineThreshold is the number of consecutive failed capability
+	// fetches after which a node is quarantined: it is probed on a slower,
+	// growing schedule and logged only when it enters or leaves quarantine.
+	quarantineThreshold  = 5
+	quarantineBackoffMin = 30 * time.Second
+	quarantineBackoffMax = 10 * time.Minute
 )
 
+// probeRetryDelay returns how long to wait before the next capability fetch
+// after the given number of consecutive failures: 1s doubling up to 8s, then
+// once quarantined 30s doubling up to 10m.
+func probeRetryDelay(failures int) time.Duration {
+	base, limit, doublings := capFetchBackoffMin, capFetchBackoffMax, failures-1
+	if failures >= quarantineThreshold {
+		base, limit, doublings = quarantineBackoffMin, quarantineBackoffMax, failures-quarantineThreshold
+	}
+	delay := base
+	for i := 0; i < doublings && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// recordProbeFailureLocked counts a failed capability fetch and returns the
+// delay before the next one. entered is true when this failure put the node
+// into quarantine.
+func (lb *lumenBalancer) recordProbeFailureLocked(scs *subConnState, now time.Time) (delay time.Duration, entered bool) {
+	scs.probeFailures++
+	delay = probeRetryDelay(scs.probeFailures)
+	if scs.probeFailures >= quarantineThreshold {
+		scs.nextProbe = now.Add(delay)
+	}
+	return delay, scs.probeFailures == quarantineThreshold
+}
+
+// clearProbeFailuresLocked resets the node's failure count and any pending
+// quarantine probe, reporting whether the node was quarantined.
+func (lb *lumenBalancer) clearProbeFailuresLocked(scs *subConnState) bool {
+	wasQuarantined := scs.probeFailures >= quarantineThreshold
+	scs.probeFailures = 0
+	scs.nextProbe = time.Time{}
+	if scs.probeTimer != nil {
+		scs.probeTimer.Stop()
+		scs.probeTimer = nil
+	}
+	return wasQuarantined
+}
+
 // fetchCapabilitiesWithRetry keeps trying to fetch node capabilities for as
 // long as the SubConn stays Ready on the same address. Unbounded on purpose: a
 // hub that binds its port before models are downloaded (control-plane-first
 // startup) answers UNAVAILABLE for many minutes while the connection stays
 // Ready, so giving up after a fixed attempt count would leave the node
-// capability-less until an unrelated reconnect. It clears the per-node
-// capFetching guard on exit so a later Ready transition can start a fresh
-// fetch.
+// capability-less until an unrelated reconnect. Once the node is quarantined
+// the loop exits and a timer starts the next attempt, so the wait can be
+// cancelled by an address change. It clears the per-node capFetching guard on
+// exit so a later Ready transition can start a fresh fetch.
 func (lb *lumenBalancer) fetchCapabilitiesWithRetry(key, addr string) {
 	defer func() {
 		lb.mu.Lock()
@@ -370,50 +1022,370 @@ func (lb *lumenBalancer) fetchCapabilitiesWithRetry(key, addr string) {
 		lb.mu.Unlock()
 	}()
 
-	backoff := capFetchBackoffMin
-	for attempt := 1; ; attempt++ {
-		if lb.fetchCapabilitiesForNode(key, addr) {
+	for {
+		if lb.probeCapabilities(key, addr) {
 			return
 		}
 
 		lb.mu.Lock()
 		scs, ok := lb.subConns[key]
-		stale := !ok || scs.state != connectivity.Ready || scs.addr.Addr != addr
-		lb.mu.Unlock()
+		stale := lb.closed || !ok || scs.state != connectivity.Ready || scs.addr.Addr != addr
 		if stale {
+			lb.mu.Unlock()
+			return
+		}
+		delay, entered := lb.recordProbeFailureLocked(scs, lb.now())
+		if entered {
+			lb.log().Warn("node quarantined: capability fetch keeps failing",
+				zap.String("id", key),
+				zap.Int("failures", scs.probeFailures),
+				zap.Time("next_probe", scs.nextProbe),
+			)
+		}
+		if scs.probeFailures >= quarantineThreshold {
+			if scs.probeTimer != nil {
+				scs.probeTimer.Stop()
+			}
+			scs.probeTimer = lb.clock().AfterFunc(delay, func() {
+				lb.resumeProbe(key, addr)
+			})
+			lb.syncRegistryLocked()
+			lb.mu.Unlock()
+			return
+		}
+		lb.syncRegistryLocked()
+		lb.mu.Unlock()
+
+		lb.clock().Sleep(delay)
+	}
+}
+
+// resumeProbe restarts capability fetching for a quarantined node whose
+// next probe is due, unless it has since moved, dropped or been removed.
+func (lb *lumenBalancer) resumeProbe(key, addr string) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	scs, ok := lb.subConns[key]
+	if lb.closed || !ok || scs.state != connectivity.Ready || scs.addr.Addr != addr || scs.capFetching {
+		return
+	}
+	scs.probeTimer = nil
+	scs.capFetching = true
+	go lb.fetchCapabilitiesWithRetry(key, addr)
+}
+
+// probeCapabilities runs one capability fetch while holding a probe slot.
+// Slots are taken per attempt, never across the retry backoff, so a node that
+// keeps failing does not starve the others.
+func (lb *lumenBalancer) probeCapabilities(key, addr string) bool {
+	if lb.probeSem != nil {
+		lb.probeSem <- struct{}{}
+		defer func() { <-lb.probeSem }()
+	}
+	if lb.registry != nil {
+		lb.registry.inFlightProbes.Add(1)
+		defer lb.registry.inFlightProbes.Add(-1)
+	}
+	return lb.fetchCapabilitiesForNode(key, addr)
+}
+
+// healthCheckLoop sends a Health RPC to every Ready node once per interval
+// until the balancer is closed.
+func (lb *lumenBalancer) healthCheckLoop(interval time.Duration) {
+	ticker := lb.clock().NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lb.done:
+			return
+		case <-ticker.C():
+			lb.checkHealth()
+		}
+	}
+}
+
+func (lb *lumenBalancer) checkHealth() {
+	now := lb.now()
+	lb.mu.Lock()
+	targets := make(map[string]string)
+	for key, scs := range lb.subConns {
+		if scs.state != connectivity.Ready || scs.healthChecking || scs.healthRetry != nil {
+			continue
+		}
+		if !scs.cooldownUntil.IsZero() && now.Before(scs.cooldownUntil) {
+			continue
+		}
+		scs.healthChecking = true
+		targets[key] = scs.addr.Addr
+	}
+	lb.mu.Unlock()
+
+	for key, addr := range targets {
+		go lb.probeHealth(key, addr)
+	}
+}
+
+// probeHealth runs one Health RPC while holding a probe slot and feeds the
+// result into the node's damped status (see statusDamper). A failure cools
+// the node down while it is in error or suspect and schedules a retry with
+// backoff; healthFailureLimit failures in a row discard the connection for
+// a fresh one. Once enough checks pass the node returns to selection on the
+// same connection; until then it takes only probe traffic.
+func (lb *lumenBalancer) probeHealth(key, addr string) {
+	if lb.probeSem != nil {
+		lb.probeSem <- struct{}{}
+		defer func() { <-lb.probeSem }()
+	}
+	err := lb.healthCheckNode(key, addr)
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	scs, ok := lb.subConns[key]
+	if !ok {
+		return
+	}
+	scs.healthChecking = false
+	if lb.closed || scs.addr.Addr != addr || scs.state != connectivity.Ready {
+		// A node that is not Ready is probed again when it becomes Ready.
+		return
+	}
+	if err != nil && status.Code(err) == codes.Unimplemented {
+		return
+	}
+	wasHeld := scs.held
+	damped := lb.observeHealthLocked(key, scs, err == nil)
+	if err == nil {
+		if lb.registry != nil && lb.registry.onHealthy != nil {
+			lb.registry.onHealthy(key)
+		}
+		if damped == discovery.NodeStatusError {
+			// Recovering: check again soon rather than at the next tick.
+			lb.retryHealthLocked(key, scs, healthRetryBackoffMin)
+		}
+		if scs.hardFailures == 0 && scs.healthFailures == 0 && scs.held == wasHeld {
 			return
 		}
-		if attempt%10 == 0 {
-			lb.log().Warn("cap fetch: node still not reporting capabilities; retrying",
+		if scs.healthFailures > 0 {
+			lb.log().Info("node passed health check",
 				zap.String("id", key),
-				zap.Int("attempts", attempt),
+				zap.Int("failed_checks", scs.healthFailures),
+				zap.String("status", string(damped)),
 			)
 		}
+		scs.hardFailures = 0
+		scs.healthFailures = 0
+		scs.cooldownUntil = time.Time{}
+		scs.cooldown = 0
+	} else {
+		scs.healthFailures++
+		lb.recordError(key, discovery.NodeOpHealth, err, "")
+		lb.log().Warn("health check failed",
+			zap.String("id", key),
+			zap.Int("consecutive", scs.healthFailures),
+			zap.String("status", string(damped)),
+			zap.Error(err),
+		)
+		if scs.healthFailures >= healthFailureLimit {
+			lb.reconnectLocked(key, scs)
+			return
+		}
+		if scs.held {
+			lb.startCooldownLocked(scs, lb.now())
+		}
+		delay := healthRetryDelay(scs.healthFailures, lb.options.healthInterval)
+		if damped == discovery.NodeStatusSuspect {
+			// A suspect node is judged again when its suspect time is over.
+			now := lb.now()
+			v, _ := lb.registry.health.view(key, now)
+			delay = max(delay, v.suspectUntil.Sub(now))
+		}
+		lb.retryHealthLocked(key, scs, delay)
+	}
+	lb.syncRegistryLocked()
+	lb.rebuildPickerLocked()
+}
 
-		time.Sleep(backoff)
-		backoff *= 2
-		if backoff > capFetchBackoffMax {
-			backoff = capFetchBackoffMax
+// observeHealthLocked feeds one health check of the node into its damped
+// status, logs the transition it causes and returns the status. A balancer
+// without a registry goes to error after hardFailureThreshold failed checks
+// and back after one passed check.
+func (lb *lumenBalancer) observeHealthLocked(key string, scs *subConnState, passed bool) discovery.NodeStatus {
+	if lb.registry == nil || lb.registry.health == nil {
+		scs.held = !passed && scs.healthFailures+1 >= hardFailureThreshold
+		if scs.held {
+			return discovery.NodeStatusError
 		}
+		return discovery.NodeStatusActive
+	}
+	damped, t := lb.registry.health.observe(key, passed, lb.now())
+	scs.held = damped != discovery.NodeStatusActive
+	if t != nil {
+		lb.logStatusTransition(key, t)
+		lb.registry.syncStatuses()
+	}
+	return damped
+}
+
+// connectionChangedLocked feeds a node losing or regaining its connection
+// into its damped status and logs the transition it causes.
+func (lb *lumenBalancer) connectionChangedLocked(key string, up bool) {
+	if lb.registry == nil || lb.registry.health == nil {
+		return
+	}
+	if t := lb.registry.health.connectionChanged(key, up, lb.now()); t != nil {
+		lb.logStatusTransition(key, t)
 	}
 }
 
+// logStatusTransition logs a node's damped status change: at warn level
+// away from active, at info level back to it.
+func (lb *lumenBalancer) logStatusTransition(key string, t *discovery.StatusTransition) {
+	log := lb.log().Warn
+	if t.To == discovery.NodeStatusActive {
+		log = lb.log().Info
+	}
+	log("node status changed",
+		zap.String("id", key),
+		zap.String("from", string(t.From)),
+		zap.String("to", string(t.To)),
+		zap.String("reason", string(t.Reason)),
+		zap.String("detail", t.Detail),
+	)
+}
+
+const (
+	healthRetryBackoffMin = 1 * time.Second
+
+	// healthFailureLimit is the number of consecutive failed Health RPCs
+	// after which the node's connection is discarded and redialled.
+	healthFailureLimit = 5
+)
+
+// healthRetryDelay returns how long to wait before re-running a failed
+// Health RPC: 1s doubling per consecutive failure, capped at the
+// health-check interval.
+func healthRetryDelay(failures int, interval time.Duration) time.Duration {
+	delay := healthRetryBackoffMin
+	for i := 1; i < failures && delay < interval; i++ {
+		delay *= 2
+	}
+	if interval > 0 && delay > interval {
+		delay = interval
+	}
+	return delay
+}
+
+// retryHealthLocked runs the node's Health RPC again after delay, replacing
+// any retry already scheduled.
+func (lb *lumenBalancer) retryHealthLocked(key string, scs *subConnState, delay time.Duration) {
+	if scs.healthRetry != nil {
+		scs.healthRetry.Stop()
+	}
+	addr := scs.addr.Addr
+	var timer utils.Timer
+	timer = lb.clock().AfterFunc(delay, func() {
+		lb.mu.Lock()
+		scs, ok := lb.subConns[key]
+		if !ok || scs.healthRetry != timer {
+			lb.mu.Unlock()
+			return
+		}
+		scs.healthRetry = nil
+		run := !lb.closed && !scs.healthChecking && scs.state == connectivity.Ready && scs.addr.Addr == addr
+		if run {
+			scs.healthChecking = true
+		}
+		lb.mu.Unlock()
+		if run {
+			lb.probeHealth(key, addr)
+		}
+	})
+	scs.healthRetry = timer
+}
+
+// reconnectLocked discards a node's connection after sustained health-check
+// failure and dials a fresh one. The node keeps its registry entry,
+// capabilities and in-flight count; the fresh connection starts with a
+// clean failure count once it is Ready.
+func (lb *lumenBalancer) reconnectLocked(key string, scs *subConnState) {
+	sc, err := lb.newSubConnLocked(key, scs.addr)
+	if err != nil {
+		lb.log().Warn("failed to create SubConn", zap.String("id", key), zap.Error(err))
+		return
+	}
+	lb.log().Warn("node failed sustained health checks, reconnecting",
+		zap.String("id", key),
+		zap.Int("failed_checks", scs.healthFailures),
+	)
+	lb.removeSubConnLocked(scs)
+	if lb.registry != nil {
+		lb.registry.recordTransition(scs.state, connectivity.Idle)
+	}
+	// Pickers built earlier still reference scs, so the new connection
+	// gets a fresh subConnState rather than a mutated one.
+	fresh := *scs
+	fresh.sc = sc
+	fresh.state = connectivity.Idle
+	fresh.healthFailures = 0
+	fresh.replacement = nil
+	fresh.recycleTimer = nil
+	fresh.probeTimer = nil
+	fresh.healthRetry = nil
+	lb.subConns[key] = &fresh
+	sc.Connect()
+	lb.syncRegistryLocked()
+	lb.rebuildPickerLocked()
+}
+
+func (lb *lumenBalancer) healthCheckNode(key, addr string) error {
+	timeout := lb.options.capFetchTimeout
+	if timeout <= 0 {
+		timeout = defaultCapFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := lb.dialNode(key, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = pb.NewInferenceClient(conn).Health(ctx, &emptypb.Empty{})
+	return err
+}
+
+// dialNode opens a side connection to a node for probes that must reach that
+// node specifically rather than whichever one the picker selects. It uses the
+// same per-node transport as the node's SubConn, so capability fetches and
+// health checks reach TLS-only nodes too.
+func (lb *lumenBalancer) dialNode(key, addr string) (*grpc.ClientConn, error) {
+	lb.mu.Lock()
+	var nt nodeTransport
+	if scs, ok := lb.subConns[key]; ok {
+		nt, _ = getNodeTransport(scs.addr.Attributes)
+	}
+	lb.mu.Unlock()
+
+	opts := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(lb.options.transport.forNode(nt)),
+	}, lb.options.dialOptions...)
+	return grpc.NewClient(addr, opts...)
+}
+
 // fetchCapabilitiesForNode performs one capability fetch. It reports success
 // only when at least one capability was received; the caller owns retries.
 func (lb *lumenBalancer) fetchCapabilitiesForNode(key, addr string) bool {
-	timeout := 10 * time.Second
+	timeout := lb.options.capFetchTimeout
+	if timeout <= 0 {
+		timeout = defaultCapFetchTimeout
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	conn, err := grpc.NewClient(addr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithKeepaliveParams(keepalive.ClientParameters{
-			Time:    10 * time.Second,
-			Timeout: 3 * time.Second,
-		}),
-	)
+	conn, err := lb.dialNode(key, addr)
 	if err != nil {
-		lb.log().Warn("cap fetch: dial failed", zap.String("id", key), zap.Error(err))
+		lb.recordError(key, discovery.NodeOpCapability, err, "")
+		lb.log().Debug("cap fetch: dial failed", zap.String("id", key), zap.Error(err))
 		return false
 	}
 	defer conn.Close()
@@ -421,35 +1393,70 @@ func (lb *lumenBalancer) fetchCapabilitiesForNode(key, addr string) bool {
 	cli := pb.NewInferenceClient(conn)
 	stream, err := cli.StreamCapabilities(ctx, &emptypb.Empty{})
 	if err != nil {
-		lb.log().Warn("cap fetch: stream failed", zap.String("id", key), zap.Error(err))
+		lb.recordError(key, discovery.NodeOpCapability, err, "")
+		lb.log().Debug("cap fetch: stream failed", zap.String("id", key), zap.Error(err))
 		return false
 	}
 
-	var caps []*pb.Capability
+	// A stream breaking off, or a capability reporting its service failing,
+	// makes the fetch partial: the services it could not refresh keep
+	// their previous capabilities.
+	var fetched []*pb.Capability
+	var failed []string
+	broken := false
 	for {
 		cap, err := stream.Recv()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
+			lb.recordError(key, discovery.NodeOpCapability, err, "")
 			lb.log().Debug("cap fetch: recv failed", zap.String("id", key), zap.Error(err))
+			broken = true
 			break
 		}
-		if cap != nil {
-			caps = append(caps, cap)
+		if cap == nil {
+			continue
+		}
+		if reason, ok := cap.GetExtra()[discovery.CapabilityErrorExtraKey]; ok {
+			lb.recordError(key, discovery.NodeOpCapability, utils.ServiceUnavailableError(cap.GetServiceName(), reason), "")
+			lb.log().Debug("cap fetch: service failed",
+				zap.String("id", key),
+				zap.String("service", cap.GetServiceName()),
+				zap.String("reason", reason),
+			)
+			failed = append(failed, cap.GetServiceName())
+			continue
 		}
+		fetched = append(fetched, cap)
 	}
-	if len(caps) == 0 {
+	if len(fetched) == 0 {
 		return false
 	}
 
-	tasks := tasksFromCapabilities(caps)
-
+	var tasks, stale []string
+	var diff *discovery.CapabilityDiff
+	var recovered bool
+	var mismatch []zap.Field
 	lb.mu.Lock()
 	scs, ok := lb.subConns[key]
 	if ok {
+		var caps []*pb.Capability
+		caps, stale = mergeCapabilities(scs.capabilities, fetched, failed, broken)
+		tasks = tasksFromCapabilities(caps)
+		if len(scs.capabilities) == 0 {
+			mismatch = hintMismatch(scs.hintTasks, discovery.ParseCapabilityHints(scs.txt), tasks, caps)
+		} else if d := discovery.DiffCapabilities(scs.capabilities, caps); !d.Empty() {
+			d.NodeID = key
+			d.At = lb.now()
+			diff = &d
+			scs.lastCapDiff = diff
+		}
 		scs.capabilities = caps
-		scs.tasks = mergeTasks(scs.tasks, tasks)
+		scs.staleServices = stale
+		scs.features = discovery.FeaturesFromCapabilities(caps)
+		scs.refreshTasksLocked()
+		recovered = lb.clearProbeFailuresLocked(scs)
 	}
 	lb.syncRegistryLocked()
 	lb.rebuildPickerLocked()
@@ -459,9 +1466,101 @@ func (lb *lumenBalancer) fetchCapabilitiesForNode(key, addr string) bool {
 		zap.String("id", key),
 		zap.Strings("tasks", tasks),
 	)
+	if len(stale) > 0 {
+		lb.log().Warn("partial capability fetch; keeping the previous capabilities of services it did not refresh",
+			zap.String("id", key),
+			zap.Strings("stale_services", stale),
+		)
+	}
+	if len(mismatch) > 0 {
+		lb.log().Warn("node's TXT capability hints were wrong; using its fetched capabilities",
+			append([]zap.Field{zap.String("id", key)}, mismatch...)...)
+	}
+	if recovered {
+		lb.log().Info("node left quarantine: capabilities fetched", zap.String("id", key))
+	}
+	if diff != nil {
+		lb.publishCapabilityDiff(*diff)
+	}
 	return true
 }
 
+// mergeCapabilities returns a node's capabilities after a fetch that
+// returned fetched, reported the services in failed failing and, if
+// broken, broke off before the end; stale lists the services whose
+// capabilities in prev were kept. A fetch that did neither replaces prev.
+// A broken one keeps every service it did not refresh, and one that only
+// reported failures keeps just those services: the node listed all it
+// serves, so any other service missing from fetched is gone. Stale
+// capabilities follow the fetched ones.
+func mergeCapabilities(prev, fetched []*pb.Capability, failed []string, broken bool) (caps []*pb.Capability, stale []string) {
+	if !broken && len(failed) == 0 {
+		return fetched, nil
+	}
+	refreshed := make(map[string]bool, len(fetched))
+	for _, cap := range fetched {
+		refreshed[cap.GetServiceName()] = true
+	}
+	caps = fetched
+	for _, cap := range prev {
+		service := cap.GetServiceName()
+		if refreshed[service] || (!broken && !slices.Contains(failed, service)) {
+			continue
+		}
+		caps = append(caps, cap)
+		if !slices.Contains(stale, service) {
+			stale = append(stale, service)
+		}
+	}
+	return caps, stale
+}
+
+// publishCapabilityDiff logs a capability change and hands it to the
+// registry's listener.
+func (lb *lumenBalancer) publishCapabilityDiff(diff discovery.CapabilityDiff) {
+	lb.log().Info("node capabilities changed",
+		zap.String("id", diff.NodeID),
+		zap.Strings("tasks_added", diff.TasksAdded),
+		zap.Strings("tasks_removed", diff.TasksRemoved),
+		zap.Strings("models_added", diff.ModelsAdded),
+		zap.Strings("models_removed", diff.ModelsRemoved),
+		zap.Strings("services_added", diff.ServicesAdded),
+		zap.Strings("services_removed", diff.ServicesRemoved),
+		zap.Any("runtime_changes", diff.RuntimeChanges),
+		zap.Any("concurrency_changes", diff.ConcurrencyChanges),
+	)
+	if lb.registry != nil && lb.registry.onCapabilityChange != nil {
+		go lb.registry.onCapabilityChange(diff)
+	}
+}
+
+// publishAddressChange logs a node's move to a new address and hands it to
+// the registry's listener.
+func (lb *lumenBalancer) publishAddressChange(change discovery.NodeAddressChanged) {
+	lb.log().Info("node address changed",
+		zap.String("id", change.NodeID),
+		zap.String("old_address", change.OldAddress),
+		zap.String("new_address", change.NewAddress),
+	)
+	if lb.registry != nil && lb.registry.onAddressChange != nil {
+		go lb.registry.onAddressChange(change)
+	}
+}
+
+// clock is the registry's clock, or the wall clock for a balancer without
+// a registry.
+func (lb *lumenBalancer) clock() utils.Clock {
+	if lb.registry == nil {
+		return utils.RealClock
+	}
+	return utils.ClockOrReal(lb.registry.clock)
+}
+
+// now is the time on the balancer's clock.
+func (lb *lumenBalancer) now() time.Time {
+	return lb.clock().Now()
+}
+
 func (lb *lumenBalancer) log() *zap.Logger {
 	if lb.logger != nil {
 		return lb.logger
@@ -476,8 +1575,14 @@ func availabilityFromRegistered(rn *registeredNode) discovery.NodeAvailability {
 	case connectivity.Ready:
 		return discovery.NodeAvailabilityReady
 	case connectivity.Connecting:
+		if rn.connLost {
+			return discovery.NodeAvailabilityRediscovering
+		}
 		return discovery.NodeAvailabilityConnecting
 	case connectivity.Idle:
+		if rn.connLost {
+			return discovery.NodeAvailabilityRediscovering
+		}
 		return discovery.NodeAvailabilityResolving
 	case connectivity.TransientFailure:
 		if rn.hardFailures >= hardFailureThreshold {
@@ -514,13 +1619,20 @@ func buildCapabilityMetadata(caps []*pb.Capability) map[string]interface{} {
 	return metadata
 }
 
+// buildModelInfos lists the models of caps, each model and runtime pair
+// once however many services serve it.
 func buildModelInfos(caps []*pb.Capability) []*discovery.ModelInfo {
 	var models []*discovery.ModelInfo
+	seen := make(map[[2]string]bool)
 	for _, cap := range caps {
 		if cap == nil {
 			continue
 		}
 		for _, modelID := range cap.ModelIds {
+			if seen[[2]string{modelID, cap.Runtime}] {
+				continue
+			}
+			seen[[2]string{modelID, cap.Runtime}] = true
 			models = append(models, &discovery.ModelInfo{
 				ID:      modelID,
 				Runtime: cap.Runtime,
@@ -535,53 +1647,212 @@ func buildModelInfos(caps []*pb.Capability) []*discovery.ModelInfo {
 type lumenPicker struct {
 	ready    []*subConnState
 	probes   []*subConnState
-	rrIdx    int64
 	balancer *lumenBalancer
+	// strategies choose among eligible nodes, with infos the node
+	// snapshots scorers see, keyed by node.
+	strategies *strategies
+	infos      map[string]*discovery.NodeInfo
 }
 
 func (p *lumenPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
 	task := TaskFromContext(info.Ctx)
-	now := time.Now()
+	now := p.now()
+	strategy, scoring, ok := p.strategies.resolve(info.Ctx)
+	if !ok {
+		return balancer.PickResult{}, status.Errorf(codes.InvalidArgument, "unknown selection strategy %q", strategy)
+	}
 
-	candidates := filterByTask(p.ready, task, false, now)
-	if len(candidates) == 0 {
-		candidates = filterByTask(p.probes, task, true, now)
+	pinned := pinnedNode(info.Ctx)
+	var draining map[string]time.Time
+	if pinned == "" {
+		// Pinned RPCs join streams already open to their node, so they
+		// still reach it while it drains.
+		draining = p.drainingNodes()
 	}
-	if len(candidates) == 0 {
-		if task != "" && !anySupportsTask(p.ready, task) && !anySupportsTask(p.probes, task) {
-			return balancer.PickResult{}, fmt.Errorf("no node supports task %q", task)
+	candidates, probe := p.candidates(task, now, draining)
+	if pinned == "" {
+		candidates = p.withoutEjected(candidates, now)
+	}
+	if feature := requiredFeature(info.Ctx); feature != "" {
+		candidates = withFeature(candidates, feature)
+		if len(candidates) == 0 && pinned == "" {
+			return balancer.PickResult{}, status.Errorf(codes.Unavailable, "no node serving task %q supports %s", task, feature)
+		}
+	}
+	if len(candidates) == 0 && pinned == "" {
+		err := p.noCandidateErr(task, now, draining)
+		if flag := noNodeFlag(info.Ctx); flag != nil && err != balancer.ErrNoSubConnAvailable {
+			flag.Store(true)
 		}
-		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+		return balancer.PickResult{}, err
 	}
 
-	idx := atomic.AddInt64(&p.rrIdx, 1)
-	picked := candidates[idx%int64(len(candidates))]
+	var picked *subConnState
+	if pinned != "" {
+		// A pinned RPC belongs with streams already open to that node, so
+		// fail it rather than wait or route it elsewhere.
+		for _, scs := range candidates {
+			if scs.identity.Key() == pinned {
+				picked = scs
+				break
+			}
+		}
+		if picked == nil {
+			return balancer.PickResult{}, status.Errorf(codes.Unavailable, "node %s is not available for task %q", pinned, task)
+		}
+	} else if scoring != nil {
+		picked, _ = scoring.best(info.Ctx, task, candidates, p.nodeInfo, p.nodeLatency, scoring.randomTies)
+		if picked == nil {
+			if flag := noNodeFlag(info.Ctx); flag != nil {
+				flag.Store(true)
+			}
+			return balancer.PickResult{}, status.Errorf(codes.Unavailable, scorerExcludedAll, task)
+		}
+	} else {
+		picked = candidates[p.nextIndex(task, len(candidates), now)]
+	}
+	if slot := pickedNodeSlot(info.Ctx); slot != nil {
+		slot.set(picked.identity.Key(), picked.resolveTask(task))
+	}
+	phaseTimerFrom(info.Ctx).markPicked(picked.identity.Key())
+	if p.balancer != nil && p.balancer.registry != nil {
+		p.balancer.registry.recordSelection(discovery.SelectionDecision{
+			Task:       task,
+			At:         now,
+			Strategy:   strategy,
+			NodeID:     picked.identity.Key(),
+			Candidates: len(candidates),
+			Probe:      probe,
+			Pinned:     pinned != "",
+		})
+	}
 
+	closeStream := func() {}
+	if picked.streams != nil && p.balancer != nil {
+		closeStream = p.balancer.trackStream(info.Ctx, picked.identity.Key(), picked.streams, task)
+	}
 	return balancer.PickResult{
 		SubConn: picked.sc,
-		Done:    p.makeDone(picked),
+		Done:    p.makeDone(picked.node(), now, selectionHintsFromContext(info.Ctx).CorrelationID, closeStream),
 	}, nil
 }
 
-func (p *lumenPicker) makeDone(scs *subConnState) func(balancer.DoneInfo) {
+func sortByKey(nodes []*subConnState) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].identity.Key() < nodes[j].identity.Key()
+	})
+}
+
+// now is the time on the balancer's clock.
+func (p *lumenPicker) now() time.Time {
+	if p.balancer == nil {
+		return time.Now()
+	}
+	return p.balancer.now()
+}
+
+// nodeInfo is the snapshot of scs a NodeScorer sees, with InFlight current.
+func (p *lumenPicker) nodeInfo(scs *subConnState) *discovery.NodeInfo {
+	info := &discovery.NodeInfo{ID: scs.identity.Key()}
+	if snap := p.infos[info.ID]; snap != nil {
+		info = snap.Clone()
+	}
+	info.InFlight = int(scs.streams.inFlight())
+	return info
+}
+
+func (p *lumenPicker) nodeLatency(key string) LatencyStats {
+	if p.balancer == nil || p.balancer.registry == nil {
+		return LatencyStats{}
+	}
+	return p.balancer.registry.latency.get(key)
+}
+
+// nextIndex advances task's rotation over n candidates. A picker without a
+// registry always takes the first.
+func (p *lumenPicker) nextIndex(task string, n int, now time.Time) int {
+	if p.balancer == nil || p.balancer.registry == nil {
+		return 0
+	}
+	return p.balancer.registry.advanceCursor(task, n, now)
+}
+
+// drainingNodes returns the nodes drained when Pick runs, keyed by node.
+func (p *lumenPicker) drainingNodes() map[string]time.Time {
+	if p.balancer == nil || p.balancer.registry == nil {
+		return nil
+	}
+	return p.balancer.registry.drainingNodes()
+}
+
+// candidates returns the nodes eligible for task, leaving out those in
+// draining: the Ready ones, or when none qualifies, the nodes whose cooldown
+// has expired (probe is then true).
+func (p *lumenPicker) candidates(task string, now time.Time, draining map[string]time.Time) (candidates []*subConnState, probe bool) {
+	candidates = withoutDraining(filterByTask(p.ready, task, false, now), draining)
+	if len(candidates) == 0 {
+		candidates = withoutDraining(filterByTask(p.probes, task, true, now), draining)
+		probe = len(candidates) > 0
+	}
+	return candidates, probe
+}
+
+// noCandidateErr is the error Pick returns when no node qualifies for task.
+// Both a task no node supports and one only draining nodes could serve fail
+// the RPC rather than wait for a node to appear.
+func (p *lumenPicker) noCandidateErr(task string, now time.Time, draining map[string]time.Time) error {
+	if task != "" && !anySupportsTask(p.ready, task) && !anySupportsTask(p.probes, task) {
+		return fmt.Errorf("no node supports task %q", task)
+	}
+	if len(draining) > 0 {
+		if candidates, _ := p.candidates(task, now, nil); len(candidates) > 0 {
+			return fmt.Errorf("every node that can serve task %q is draining", task)
+		}
+	}
+	return balancer.ErrNoSubConnAvailable
+}
+
+// makeDone returns the Done callback of a request picked at picked whose
+// correlation ID is correlationID.
+func (p *lumenPicker) makeDone(scs *subConnState, picked time.Time, correlationID string, closeStream func()) func(balancer.DoneInfo) {
 	return func(info balancer.DoneInfo) {
 		lb := p.balancer
+		closeStream()
 		if info.Err == nil {
+			if lb.registry != nil {
+				latency := lb.now().Sub(picked)
+				lb.registry.latency.observe(scs.identity.Key(), latency)
+				lb.registry.outliers.record(scs.identity.Key(), false, latency)
+			}
+			// Most successes follow successes; write the node, which Pick
+			// reads, only when there is a failure streak to reset.
 			lb.mu.Lock()
-			scs.hardFailures = 0
-			scs.cooldownUntil = time.Time{}
-			scs.cooldown = 0
-			lb.syncRegistryLocked()
+			if scs.hardFailures != 0 || !scs.cooldownUntil.IsZero() || scs.cooldown != 0 {
+				scs.hardFailures = 0
+				scs.cooldownUntil = time.Time{}
+				scs.cooldown = 0
+				lb.syncRegistryLocked()
+			}
 			lb.mu.Unlock()
 			return
 		}
+		class := classifyStatus(info.Err)
+		if lb.registry != nil {
+			lb.registry.failures.add(scs.identity.Key(), class)
+		}
+		if class != FailureCancelled {
+			lb.recordError(scs.identity.Key(), discovery.NodeOpInfer, info.Err, correlationID)
+		}
 		if !shouldAffectNodeHealth(nil, info.Err) {
 			return
 		}
+		if lb.registry != nil {
+			lb.registry.outliers.record(scs.identity.Key(), true, 0)
+		}
 		lb.mu.Lock()
 		scs.hardFailures++
 		if scs.hardFailures >= hardFailureThreshold {
-			lb.startCooldownLocked(scs, time.Now())
+			lb.startCooldownLocked(scs, lb.now())
 		}
 		lb.syncRegistryLocked()
 		lb.rebuildPickerLocked()
@@ -592,14 +1863,9 @@ func (p *lumenPicker) makeDone(scs *subConnState) func(balancer.DoneInfo) {
 func filterByTask(candidates []*subConnState, task string, requireExpiredCooldown bool, now time.Time) []*subConnState {
 	var out []*subConnState
 	for _, scs := range candidates {
-		if task != "" && !nodeSupportsTaskSlice(scs.tasks, task) {
-			continue
-		}
-		if requireExpiredCooldown {
-			if scs.cooldownUntil.IsZero() || now.Before(scs.cooldownUntil) {
-				continue
-			}
-		} else if !scs.cooldownUntil.IsZero() && now.Before(scs.cooldownUntil) {
+		// A held node is probed once any cooldown is over, even one that
+		// never started.
+		if rejectReason(scs.supportsTask(task), scs.cooldownUntil, requireExpiredCooldown && !scs.held, now) != "" {
 			continue
 		}
 		out = append(out, scs)
@@ -607,20 +1873,178 @@ func filterByTask(candidates []*subConnState, task string, requireExpiredCooldow
 	return out
 }
 
+// rejectReason returns why a node with the given task support and cooldown
+// cannot serve a request, or "" when it can. Probe passes
+// (requireExpiredCooldown) only accept nodes whose cooldown has expired.
+func rejectReason(supportsTask bool, cooldownUntil time.Time, requireExpiredCooldown bool, now time.Time) discovery.SelectionReason {
+	if !supportsTask {
+		return discovery.SelectionUnsupportedTask
+	}
+	if requireExpiredCooldown {
+		if cooldownUntil.IsZero() {
+			return discovery.SelectionNotReady
+		}
+		if now.Before(cooldownUntil) {
+			return discovery.SelectionCoolingDown
+		}
+	} else if !cooldownUntil.IsZero() && now.Before(cooldownUntil) {
+		return discovery.SelectionCoolingDown
+	}
+	return ""
+}
+
+// withFeature returns the candidates advertising the wire feature.
+func withFeature(candidates []*subConnState, feature string) []*subConnState {
+	var out []*subConnState
+	for _, scs := range candidates {
+		if slices.Contains(scs.features, feature) {
+			out = append(out, scs)
+		}
+	}
+	return out
+}
+
+func withoutDraining(candidates []*subConnState, draining map[string]time.Time) []*subConnState {
+	if len(draining) == 0 {
+		return candidates
+	}
+	out := candidates[:0:0]
+	for _, scs := range candidates {
+		if _, ok := draining[scs.identity.Key()]; !ok {
+			out = append(out, scs)
+		}
+	}
+	return out
+}
+
 func anySupportsTask(candidates []*subConnState, task string) bool {
 	for _, scs := range candidates {
-		if nodeSupportsTaskSlice(scs.tasks, task) {
+		if scs.supportsTask(task) {
+			return true
+		}
+	}
+	return false
+}
+
+// taskSet is a node's task list indexed for the picker, which checks task
+// support for every candidate on every Pick. Versioned and wildcard tasks
+// are kept apart too, so a plain name is looked up before they are scanned.
+type taskSet struct {
+	names    map[string]struct{}
+	patterns []string
+}
+
+func newTaskSet(names []string) *taskSet {
+	set := &taskSet{names: make(map[string]struct{}, len(names))}
+	for _, t := range names {
+		set.names[t] = struct{}{}
+		if tasks.IsPattern(t) {
+			set.patterns = append(set.patterns, t)
+		}
+	}
+	return set
+}
+
+// has reports whether the set serves task, as tasks.Match defines it; the
+// empty task matches any node.
+func (s *taskSet) has(task string) bool {
+	if task == "" {
+		return true
+	}
+	if _, ok := s.names[task]; ok {
+		return true
+	}
+	for _, p := range s.patterns {
+		if tasks.Match(task, p) {
 			return true
 		}
 	}
 	return false
 }
 
-func nodeSupportsTaskSlice(tasks []string, task string) bool {
-	for _, t := range tasks {
-		if t == task {
+// refreshTasksLocked recomputes the node's tasks and rebuilds the set the
+// picker filters on. Until capabilities are fetched the node is routed on its
+// discovery hints; once they are, they alone count, so a task the node stops
+// serving, or one its hints named wrongly, is dropped. It runs when either
+// input changes rather than on every Pick. Callers hold lb.mu.
+func (scs *subConnState) refreshTasksLocked() {
+	if len(scs.capabilities) > 0 {
+		scs.tasks = mergeTasks(nil, tasksFromCapabilities(scs.capabilities))
+	} else {
+		scs.tasks = mergeTasks(scs.hintTasks, nil)
+	}
+	scs.taskSet = newTaskSet(scs.tasks)
+}
+
+// supportsTask reports whether the node serves task. SubConn states built
+// without refreshTasksLocked (tests) fall back to scanning the task list.
+func (scs *subConnState) supportsTask(task string) bool {
+	if scs.taskSet == nil {
+		return task == "" || nodeSupportsTaskSlice(scs.tasks, task)
+	}
+	return scs.taskSet.has(task)
+}
+
+// resolveTask returns the task the node advertises for a request for task,
+// such as a version of it; task itself when it has none or task is empty.
+func (scs *subConnState) resolveTask(task string) string {
+	if task == "" {
+		return ""
+	}
+	if resolved, ok := tasks.Resolve(task, scs.tasks); ok {
+		return resolved
+	}
+	return task
+}
+
+func nodeSupportsTaskSlice(names []string, task string) bool {
+	for _, t := range names {
+		if tasks.Match(task, t) {
 			return true
 		}
 	}
 	return false
 }
+
+// hintMismatch compares what a node advertised in discovery with the
+// capabilities it reported, returning log fields for each disagreement: hinted
+// tasks it does not serve, served tasks it did not hint, and a wrong runtime
+// or concurrency. A node that hinted nothing disagrees with nothing.
+func hintMismatch(hintTasks []string, hints discovery.CapabilityHints, served []string, caps []*pb.Capability) []zap.Field {
+	if len(hintTasks) == 0 {
+		return nil
+	}
+	var fields []zap.Field
+	var wrong, unhinted []string
+	for _, task := range hintTasks {
+		if !nodeSupportsTaskSlice(served, task) {
+			wrong = append(wrong, task)
+		}
+	}
+	for _, task := range served {
+		if !nodeSupportsTaskSlice(hintTasks, task) {
+			unhinted = append(unhinted, task)
+		}
+	}
+	if len(wrong) > 0 {
+		fields = append(fields, zap.Strings("hinted_not_served", wrong))
+	}
+	if len(unhinted) > 0 {
+		fields = append(fields, zap.Strings("served_not_hinted", unhinted))
+	}
+	var runtimes []string
+	var maxConcurrency uint32
+	for _, c := range caps {
+		if c.Runtime != "" && !slices.Contains(runtimes, c.Runtime) {
+			runtimes = append(runtimes, c.Runtime)
+		}
+		maxConcurrency = max(maxConcurrency, c.MaxConcurrency)
+	}
+	if hints.Runtime != "" && len(runtimes) > 0 && !slices.Contains(runtimes, hints.Runtime) {
+		fields = append(fields, zap.String("hinted_runtime", hints.Runtime), zap.Strings("runtimes", runtimes))
+	}
+	if hints.MaxConcurrency > 0 && maxConcurrency > 0 && uint32(hints.MaxConcurrency) != maxConcurrency {
+		fields = append(fields, zap.Int("hinted_max_concurrency", hints.MaxConcurrency), zap.Uint32("max_concurrency", maxConcurrency))
+	}
+	return fields
+}
@@ -44,16 +44,23 @@ func getNodeAttr(addr resolver.Address) (nodeAttr, bool) {
 // events into gRPC's address resolution framework.
 type lumenResolverBuilder struct {
 	nodeResolver discovery.NodeResolver
+	stats        *discoveryCounters
+	catalog      *discovery.CatalogTracker
+	// onWatchError is called if nodeResolver fails to start.
+	onWatchError func(error)
 	logger       *zap.Logger
 }
 
 func (b *lumenResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	r := &lumenResolver{
-		cc:     cc,
-		cancel: cancel,
-		nodes:  make(map[string]resolvedEntry),
-		logger: b.logger,
+		cc:      cc,
+		cancel:  cancel,
+		nodes:   make(map[string]resolvedEntry),
+		stats:   b.stats,
+		catalog: b.catalog,
+		onErr:   b.onWatchError,
+		logger:  b.logger,
 	}
 	go r.watch(ctx, b.nodeResolver)
 	return r, nil
@@ -72,13 +79,20 @@ type lumenResolver struct {
 	cancel context.CancelFunc
 	mu     sync.Mutex
 	nodes  map[string]resolvedEntry
-	logger *zap.Logger
+	stats  *discoveryCounters
+	// catalog is told about every event, for catalog freshness.
+	catalog *discovery.CatalogTracker
+	onErr   func(error)
+	logger  *zap.Logger
 }
 
 func (r *lumenResolver) watch(ctx context.Context, nr discovery.NodeResolver) {
 	ch, err := nr.Watch(ctx)
 	if err != nil {
 		r.logger.Error("resolver watch failed", zap.Error(err))
+		if r.onErr != nil {
+			r.onErr(err)
+		}
 		return
 	}
 
@@ -100,6 +114,7 @@ func (r *lumenResolver) handleEvent(ev discovery.NodeEvent) {
 		key := resolved.Key()
 		endpoints := resolved.CandidateEndpoints()
 		r.nodes[key] = resolvedEntry{node: resolved, endpoints: endpoints}
+		r.observeCatalog(ev, key)
 
 	case discovery.NodeExpired:
 		resolved := resolvedFromEvent(ev)
@@ -107,17 +122,33 @@ func (r *lumenResolver) handleEvent(ev discovery.NodeEvent) {
 		if key == "" {
 			return
 		}
+		r.observeCatalog(ev, key)
 		if ev.ExplicitRemove {
 			delete(r.nodes, key)
 		}
 
 	case discovery.NodeResolveFailed:
 		// Don't remove — the balancer handles degraded state.
+
+	case discovery.NodeRefreshed, discovery.ScanCompleted:
+		// Only the catalog's freshness changes, not its nodes.
+		r.observeCatalog(ev, eventKey(ev, discovery.ResolvedNode{}))
+		return
 	}
 
+	if r.stats != nil {
+		r.stats.record(ev)
+		r.stats.resolved.Store(int64(len(r.nodes)))
+	}
 	r.pushStateLocked()
 }
 
+func (r *lumenResolver) observeCatalog(ev discovery.NodeEvent, key string) {
+	if r.catalog != nil {
+		r.catalog.Observe(ev, key)
+	}
+}
+
 func (r *lumenResolver) pushStateLocked() {
 	var addrs []resolver.Address
 	for _, entry := range r.nodes {
@@ -132,6 +163,7 @@ func (r *lumenResolver) pushStateLocked() {
 		// Use first endpoint as primary address; the balancer creates one
 		// SubConn per unique address.
 		addr := setNodeAttr(resolver.Address{Addr: entry.endpoints[0]}, attr)
+		addr = setNodeTransport(addr, newNodeTransport(attr, entry.endpoints[0]))
 		addrs = append(addrs, addr)
 	}
 
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"go.uber.org/zap"
+)
+
+// InferFunc performs one logical inference request.
+type InferFunc func(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error)
+
+// InferMiddleware wraps an InferFunc with additional behaviour such as auth
+// metadata, logging or custom metrics.
+//
+// Middlewares see the logical request after validation and service
+// resolution, before payload chunking: a chunked upload passes through the
+// chain once, not once per chunk.
+type InferMiddleware func(next InferFunc) InferFunc
+
+// StreamFunc starts one streaming inference request.
+type StreamFunc func(ctx context.Context, req *pb.InferRequest) (<-chan *pb.InferResponse, error)
+
+// StreamMiddleware is the InferStream counterpart of InferMiddleware.
+type StreamMiddleware func(next StreamFunc) StreamFunc
+
+// Use appends middlewares to the Infer chain. Middlewares run in
+// registration order: the first one registered is the outermost wrapper.
+// Register them before Start; requests already in flight keep the chain
+// they started with.
+func (c *LumenClient) Use(mw ...InferMiddleware) {
+	c.mwMu.Lock()
+	defer c.mwMu.Unlock()
+	c.inferMW = append(c.inferMW, mw...)
+}
+
+// UseStream appends middlewares to the InferStream chain, with the same
+// ordering rules as Use.
+func (c *LumenClient) UseStream(mw ...StreamMiddleware) {
+	c.mwMu.Lock()
+	defer c.mwMu.Unlock()
+	c.streamMW = append(c.streamMW, mw...)
+}
+
+// inferChain composes the built-in metrics and usage middlewares, the
+// registered middlewares and the core dispatch into a single InferFunc.
+func (c *LumenClient) inferChain() InferFunc {
+	c.mwMu.RLock()
+	mws := make([]InferMiddleware, 0, len(c.inferMW)+2)
+	mws = append(mws, c.metricsMiddleware())
+	if c.usage != nil {
+		mws = append(mws, c.usageMiddleware())
+	}
+	mws = append(mws, c.inferMW...)
+	c.mwMu.RUnlock()
+
+	next := InferFunc(c.dispatchInfer)
+	for i := len(mws) - 1; i >= 0; i-- {
+		next = mws[i](next)
+	}
+	return next
+}
+
+func (c *LumenClient) streamChain() StreamFunc {
+	c.mwMu.RLock()
+	var mws []StreamMiddleware
+	if c.usage != nil {
+		mws = append(mws, c.usageStreamMiddleware())
+	}
+	mws = append(mws, c.streamMW...)
+	c.mwMu.RUnlock()
+
+	next := StreamFunc(c.dispatchStream)
+	for i := len(mws) - 1; i >= 0; i-- {
+		next = mws[i](next)
+	}
+	return next
+}
+
+// metricsMiddleware feeds the counters reported by GetMetrics. It is always
+// installed as the outermost Infer middleware, so it classifies every
+// failure once, counts it in its FailureClass and returns it as a
+// LumenError with the matching code.
+func (c *LumenClient) metricsMiddleware() InferMiddleware {
+	return func(next InferFunc) InferFunc {
+		return func(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+			start := time.Now()
+			c.counters.start()
+			c.taskState.Touch(req.GetTask())
+			resp, err := next(ctx, req)
+			if err != nil {
+				class := ClassifyFailure(ctx, err)
+				c.counters.fail(class)
+				c.taskFailures.add(req.GetTask(), class)
+				return nil, classifiedError(class, err)
+			}
+			elapsed := time.Since(start)
+			c.counters.succeed(elapsed)
+			if c.latency != nil {
+				c.latency.observe(elapsed)
+			}
+			c.taskLatency.observe(req.GetTask(), elapsed)
+			return resp, nil
+		}
+	}
+}
+
+// LoggingMiddleware logs every Infer call at debug level, and failures at
+// warn level, with task, correlation ID and latency.
+func LoggingMiddleware(logger *zap.Logger) InferMiddleware {
+	logger = ensureLogger(logger)
+	return func(next InferFunc) InferFunc {
+		return func(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+			start := time.Now()
+			resp, err := next(ctx, req)
+			fields := []zap.Field{
+				zap.String("task", req.GetTask()),
+				zap.String("correlation_id", req.GetCorrelationId()),
+				zap.Int("payload_bytes", len(req.GetPayload())),
+				zap.Duration("latency", time.Since(start)),
+			}
+			if err != nil {
+				logger.Warn("infer failed", append(fields, zap.Error(err))...)
+				return nil, err
+			}
+			logger.Debug("infer completed", fields...)
+			return resp, nil
+		}
+	}
+}
+
+// StreamLoggingMiddleware logs InferStream calls that fail to start.
+func StreamLoggingMiddleware(logger *zap.Logger) StreamMiddleware {
+	logger = ensureLogger(logger)
+	return func(next StreamFunc) StreamFunc {
+		return func(ctx context.Context, req *pb.InferRequest) (<-chan *pb.InferResponse, error) {
+			ch, err := next(ctx, req)
+			if err != nil {
+				logger.Warn("infer stream failed",
+					zap.String("task", req.GetTask()),
+					zap.String("correlation_id", req.GetCorrelationId()),
+					zap.Error(err),
+				)
+				return nil, err
+			}
+			logger.Debug("infer stream started",
+				zap.String("task", req.GetTask()),
+				zap.String("correlation_id", req.GetCorrelationId()),
+			)
+			return ch, nil
+		}
+	}
+}
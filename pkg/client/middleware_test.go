@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/version"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+func newFakeLumenClient(cli pb.InferenceClient, chunk config.ChunkConfig) *LumenClient {
+	return &LumenClient{
+		pool:   &Pool{cli: cli, logger: zap.NewNop()},
+		config: &config.Config{Chunk: chunk},
+		logger: zap.NewNop(),
+	}
+}
+
+type streamInferenceClient struct {
+	fakeInferenceClient
+	stream grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse]
+}
+
+func (c *streamInferenceClient) Infer(context.Context, ...grpc.CallOption) (grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse], error) {
+	return c.stream, nil
+}
+
+func TestMiddlewareRunsInRegistrationOrder(t *testing.T) {
+	stream := &fakeInferStream{
+		responses: []*pb.InferResponse{{IsFinal: true, Result: []byte("ok")}},
+	}
+	c := newFakeLumenClient(&streamInferenceClient{stream: stream}, config.ChunkConfig{})
+
+	var order []string
+	record := func(name string) InferMiddleware {
+		return func(next InferFunc) InferFunc {
+			return func(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+				order = append(order, name+">")
+				resp, err := next(ctx, req)
+				order = append(order, "<"+name)
+				return resp, err
+			}
+		}
+	}
+	c.Use(record("a"), record("b"))
+
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+	if _, err := c.Infer(context.Background(), req); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	if got := strings.Join(order, " "); got != "a> b> <b <a" {
+		t.Fatalf("order = %q, want %q", got, "a> b> <b <a")
+	}
+}
+
+func TestMiddlewareSeesLogicalRequestOnceWhenChunked(t *testing.T) {
+	stream := &fakeInferStream{
+		responses: []*pb.InferResponse{{IsFinal: true, Result: []byte("ok")}},
+	}
+	c := newFakeLumenClient(&streamInferenceClient{stream: stream}, config.ChunkConfig{
+		EnableAuto:    true,
+		Threshold:     4,
+		MaxChunkBytes: 4,
+	})
+
+	var calls int
+	var seenPayload int
+	c.Use(func(next InferFunc) InferFunc {
+		return func(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+			calls++
+			seenPayload = len(req.Payload)
+			return next(ctx, req)
+		}
+	})
+
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("0123456789").Build()
+	if _, err := c.Infer(context.Background(), req); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	if calls != 1 || seenPayload != 10 {
+		t.Fatalf("middleware calls = %d payload = %d, want 1 and 10", calls, seenPayload)
+	}
+}
+
+func TestMetricsMiddlewareCountsShortCircuitedRequests(t *testing.T) {
+	c := newFakeLumenClient(&fakeInferenceClient{}, config.ChunkConfig{})
+	denied := errors.New("denied")
+	c.Use(func(InferFunc) InferFunc {
+		return func(context.Context, *pb.InferRequest) (*pb.InferResponse, error) {
+			return nil, denied
+		}
+	})
+
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+	if _, err := c.Infer(context.Background(), req); !errors.Is(err, denied) {
+		t.Fatalf("Infer error = %v, want denied", err)
+	}
+	m := c.GetMetrics()
+	if m.TotalRequests != 1 || m.FailedRequests != 1 || m.SuccessRequests != 0 {
+		t.Fatalf("metrics = %+v, want 1 total / 1 failed", m)
+	}
+}
+
+func TestStreamMiddlewareWrapsInferStream(t *testing.T) {
+	stream := &fakeInferStream{
+		responses: []*pb.InferResponse{{IsFinal: true}},
+	}
+	c := newFakeLumenClient(&streamInferenceClient{stream: stream}, config.ChunkConfig{})
+
+	var called bool
+	c.UseStream(StreamLoggingMiddleware(nil), func(next StreamFunc) StreamFunc {
+		return func(ctx context.Context, req *pb.InferRequest) (<-chan *pb.InferResponse, error) {
+			called = true
+			return next(ctx, req)
+		}
+	})
+
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+	ch, err := c.InferStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	for range ch {
+	}
+	if !called {
+		t.Fatal("stream middleware was not invoked")
+	}
+}
+
+func TestRequestsCarryClientVersion(t *testing.T) {
+	stream := &fakeInferStream{
+		responses: []*pb.InferResponse{{IsFinal: true, Result: []byte("ok")}},
+	}
+	c := newFakeLumenClient(&streamInferenceClient{stream: stream}, config.ChunkConfig{})
+
+	var seen []string
+	c.Use(func(next InferFunc) InferFunc {
+		return func(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+			seen = append(seen, req.Meta[ClientVersionMetaKey])
+			return next(ctx, req)
+		}
+	})
+
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+	if _, err := c.Infer(context.Background(), req); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	// A version the caller set is kept.
+	req = types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+	req.Meta = map[string]string{ClientVersionMetaKey: "v0.0.1-app"}
+	stream.responses = []*pb.InferResponse{{IsFinal: true, Result: []byte("ok")}}
+	if _, err := c.Infer(context.Background(), req); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+
+	if len(seen) != 2 || seen[0] != version.Get().Version || seen[1] != "v0.0.1-app" {
+		t.Fatalf("client versions = %q, want [%q v0.0.1-app]", seen, version.Get().Version)
+	}
+}
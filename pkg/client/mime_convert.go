@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/tasks"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"go.uber.org/zap"
+)
+
+// MIMEConvertedFromMetaKey is set on a request, and on its response, when
+// Infer transcoded the payload into a type the picked node accepts. Its
+// value is the original payload MIME type; PayloadMime holds the new one.
+const MIMEConvertedFromMetaKey = "lumen.mime_converted_from"
+
+// Transcoding may grow a payload to maxConversionGrowth times its size, or
+// by conversionSlackBytes, whichever allows more. Past that the request
+// fails rather than shipping, say, a flat 2 KiB PNG as a 300 KiB JPEG.
+const (
+	maxConversionGrowth  = 2
+	conversionSlackBytes = 64 << 10
+)
+
+type mimeConversionKey struct{}
+
+// WithoutMIMEConversion has Infer calls made with ctx send their payload
+// as it is, even to a node that does not advertise its MIME type for the
+// task.
+func WithoutMIMEConversion(ctx context.Context) context.Context {
+	return context.WithValue(ctx, mimeConversionKey{}, false)
+}
+
+func mimeConversionEnabled(ctx context.Context) bool {
+	enabled, ok := ctx.Value(mimeConversionKey{}).(bool)
+	return !ok || enabled
+}
+
+// acceptedMIMEs returns the input MIME types the node with key advertises
+// for task, or nil when it advertises none and so accepts anything.
+func (c *LumenClient) acceptedMIMEs(key, task string) []string {
+	var accepted []string
+	for _, cap := range c.pool.nodeCapabilities(key) {
+		for _, t := range cap.GetTasks() {
+			if tasks.Match(task, t.GetName()) {
+				accepted = append(accepted, t.GetInputMimes()...)
+			}
+		}
+	}
+	return accepted
+}
+
+// matchNodeMIME makes req's payload one the node with key accepts for the
+// task. A payload it already accepts, or a request sent with
+// WithoutMIMEConversion, is left alone. An image the node does not accept
+// is transcoded to the first accepted type ConvertImage can produce,
+// updating PayloadMime and recording MIMEConvertedFromMetaKey, and
+// converted is true. Anything else fails with a CODEC_MISMATCH error
+// listing the accepted types, before the payload is sent.
+func (c *LumenClient) matchNodeMIME(ctx context.Context, req *pb.InferRequest, key string) (converted bool, err error) {
+	if key == "" || !mimeConversionEnabled(ctx) {
+		return false, nil
+	}
+	accepted := c.acceptedMIMEs(key, req.Task)
+	if types.MIMEAccepted(req.PayloadMime, accepted) {
+		return false, nil
+	}
+	mismatch := func(reason string) error {
+		return utils.CodecMismatchError(strings.Join(accepted, ", "), req.PayloadMime, map[string]string{
+			"task":   req.Task,
+			"node":   key,
+			"reason": reason,
+		})
+	}
+
+	target := ""
+	for _, a := range accepted {
+		if types.CanConvertImage(req.PayloadMime, a) {
+			target = types.BaseMIME(a)
+			break
+		}
+	}
+	if target == "" {
+		return false, mismatch("no conversion to an accepted type")
+	}
+	payload, err := types.ConvertImage(req.Payload, target)
+	if err != nil {
+		return false, utils.Wrap(err, utils.ErrCodecMismatch, fmt.Sprintf("convert %s to %s", req.PayloadMime, target))
+	}
+	if limit := max(maxConversionGrowth*len(req.Payload), len(req.Payload)+conversionSlackBytes); len(payload) > limit {
+		return false, mismatch(fmt.Sprintf("converting to %s grows the payload from %d to %d bytes", target, len(req.Payload), len(payload)))
+	}
+
+	utils.LoggerFrom(ctx).Debug("converted payload for node",
+		zap.String("from", req.PayloadMime),
+		zap.String("to", target),
+		zap.Int("from_bytes", len(req.Payload)),
+		zap.Int("to_bytes", len(payload)),
+	)
+	if req.Meta == nil {
+		req.Meta = make(map[string]string, 1)
+	}
+	req.Meta[MIMEConvertedFromMetaKey] = req.PayloadMime
+	req.Payload, req.PayloadMime = payload, target
+	return true, nil
+}
+
+// setMIMEConvertedMeta copies the conversion note from req to resp.
+func setMIMEConvertedMeta(req *pb.InferRequest, resp *pb.InferResponse) {
+	from, ok := req.GetMeta()[MIMEConvertedFromMetaKey]
+	if !ok || resp == nil {
+		return
+	}
+	if resp.Meta == nil {
+		resp.Meta = make(map[string]string, 1)
+	}
+	resp.Meta[MIMEConvertedFromMetaKey] = from
+}
+
+// rechunkConverted splits a payload matchNodeMIME converted at the chunk
+// size already in use, recording the new split in info and the chunk info
+// slot.
+func (c *LumenClient) rechunkConverted(ctx context.Context, req *pb.InferRequest, info *ChunkInfo) ([][]byte, error) {
+	cfg := c.config.Chunk
+	cfg.MaxChunkBytes = info.ChunkBytes
+	chunks, err := ChunkPayloadFor(req.Payload, req.PayloadMime, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("chunk converted payload: %w", err)
+	}
+	info.PayloadBytes = len(req.Payload)
+	info.Chunks = len(chunks)
+	info.ChunkBytes = min(info.ChunkBytes, info.PayloadBytes)
+	if slot := chunkInfoSlot(ctx); slot != nil {
+		*slot = *info
+	}
+	return chunks, nil
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"github.com/edwinzhancn/lumen-sdk/test/fixtures"
+
+	"github.com/gabriel-vasile/mimetype"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// mimeServer serves captionTask, accepting the input MIME types in
+// accepts, and records the payload and MIME type it received.
+type mimeServer struct {
+	testInferenceServer
+	accepts []string
+
+	mu       sync.Mutex
+	received []*pb.InferRequest
+}
+
+const captionTask = "caption"
+
+func (s *mimeServer) mimeCapability() *pb.Capability {
+	return &pb.Capability{ServiceName: "test", Tasks: []*pb.IOTask{{Name: captionTask, InputMimes: s.accepts}}}
+}
+
+func (s *mimeServer) GetCapabilities(context.Context, *emptypb.Empty) (*pb.Capability, error) {
+	return s.mimeCapability(), nil
+}
+
+func (s *mimeServer) StreamCapabilities(_ *emptypb.Empty, stream grpc.ServerStreamingServer[pb.Capability]) error {
+	return stream.Send(s.mimeCapability())
+}
+
+func (s *mimeServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	var got *pb.InferRequest
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if got == nil {
+			got = req
+		} else {
+			got.Payload = append(got.Payload, req.Payload...)
+		}
+	}
+	s.mu.Lock()
+	s.received = append(s.received, got)
+	s.mu.Unlock()
+	return stream.Send(&pb.InferResponse{CorrelationId: got.CorrelationId, IsFinal: true})
+}
+
+func (s *mimeServer) last() *pb.InferRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.received) == 0 {
+		return nil
+	}
+	return s.received[len(s.received)-1]
+}
+
+func startMIMEClient(t *testing.T, accepts ...string) (*LumenClient, *mimeServer) {
+	t.Helper()
+	srv := &mimeServer{accepts: accepts}
+	c := startClientFor(t, srv)
+	waitUntil(t, func() bool { return len(c.pool.nodeCapabilities("local-node-1")) > 0 })
+	return c, srv
+}
+
+func imageRequest(t *testing.T, name string) *pb.InferRequest {
+	t.Helper()
+	data, err := fixtures.Image(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pb.InferRequest{Task: captionTask, Payload: data, PayloadMime: mimetype.Detect(data).String()}
+}
+
+func TestInferConvertsPayloadForNode(t *testing.T) {
+	tests := []struct {
+		fixture string
+		accepts []string
+		want    string
+	}{
+		{fixture: "photo.png", accepts: []string{"image/jpeg"}, want: "image/jpeg"},
+		{fixture: "photo.jpg", accepts: []string{"image/png"}, want: "image/png"},
+		{fixture: "frames.gif", accepts: []string{"image/webp", "image/jpeg"}, want: "image/jpeg"},
+		{fixture: "pixel.webp", accepts: []string{"image/png"}, want: "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			c, srv := startMIMEClient(t, tt.accepts...)
+			req := imageRequest(t, tt.fixture)
+			from := req.PayloadMime
+
+			resp, err := c.Infer(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Infer: %v", err)
+			}
+			got := srv.last()
+			if got.PayloadMime != tt.want || mimetype.Detect(got.Payload).String() != tt.want {
+				t.Fatalf("node received %s (%s), want %s", got.PayloadMime, mimetype.Detect(got.Payload), tt.want)
+			}
+			if got.Meta[MIMEConvertedFromMetaKey] != from || resp.Meta[MIMEConvertedFromMetaKey] != from {
+				t.Fatalf("request meta %v, response meta %v; want the conversion from %s noted", got.Meta, resp.Meta, from)
+			}
+		})
+	}
+}
+
+func TestInferConvertsChunkedPayload(t *testing.T) {
+	c, srv := startMIMEClient(t, "image/jpeg")
+	c.config.Chunk = config.ChunkConfig{EnableAuto: true, Threshold: 64, MaxChunkBytes: 64}
+	req := imageRequest(t, "photo.png")
+
+	result, err := c.InferDetailed(context.Background(), req)
+	if err != nil {
+		t.Fatalf("InferDetailed: %v", err)
+	}
+	got := srv.last()
+	if mimetype.Detect(got.Payload).String() != "image/jpeg" {
+		t.Fatalf("node reassembled %s, want a JPEG", mimetype.Detect(got.Payload))
+	}
+	if result.Chunking.PayloadBytes != len(got.Payload) || result.Chunking.Chunks != (len(got.Payload)+63)/64 {
+		t.Fatalf("chunking = %+v for a %d-byte JPEG", result.Chunking, len(got.Payload))
+	}
+}
+
+func TestInferLeavesAcceptedPayloadAlone(t *testing.T) {
+	for _, accepts := range [][]string{nil, {"image/png"}, {"image/*"}} {
+		c, srv := startMIMEClient(t, accepts...)
+		req := imageRequest(t, "photo.png")
+		payload := req.Payload
+		if _, err := c.Infer(context.Background(), req); err != nil {
+			t.Fatalf("accepts %v: Infer: %v", accepts, err)
+		}
+		if got := srv.last(); got.PayloadMime != "image/png" || len(got.Payload) != len(payload) {
+			t.Fatalf("accepts %v: node received %s, want the PNG unchanged", accepts, got.PayloadMime)
+		}
+		if _, ok := srv.last().Meta[MIMEConvertedFromMetaKey]; ok {
+			t.Fatalf("accepts %v: conversion noted on an accepted payload", accepts)
+		}
+	}
+
+	// Disabled per request, an unaccepted payload goes out as it is.
+	c, srv := startMIMEClient(t, "image/jpeg")
+	if _, err := c.Infer(WithoutMIMEConversion(context.Background()), imageRequest(t, "photo.png")); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	if got := srv.last(); got.PayloadMime != "image/png" {
+		t.Fatalf("node received %s with conversion disabled", got.PayloadMime)
+	}
+}
+
+func TestInferFailsFastOnUnconvertiblePayload(t *testing.T) {
+	tests := []struct {
+		name, fixture string
+		accepts       []string
+		reason        string
+	}{
+		// AVIF cannot be decoded, so it converts to nothing.
+		{name: "incompatible format", fixture: "header.avif", accepts: []string{"image/jpeg", "image/png"}, reason: "no conversion"},
+		// A one-pixel checkerboard is tiny as PNG and huge as JPEG.
+		{name: "size guard", fixture: "checkerboard.png", accepts: []string{"image/jpeg"}, reason: "grows the payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := startMIMEClient(t, tt.accepts...)
+			_, err := c.Infer(context.Background(), imageRequest(t, tt.fixture))
+			var lerr *utils.LumenError
+			if !errors.As(err, &lerr) || lerr.Code != utils.ErrCodecMismatch {
+				t.Fatalf("err = %v, want a CODEC_MISMATCH error", err)
+			}
+			if details := fmt.Sprint(lerr.Details); !strings.Contains(details, tt.reason) {
+				t.Fatalf("details = %s, want %q", details, tt.reason)
+			}
+			if srv.last() != nil {
+				t.Fatal("payload sent to a node that cannot take it")
+			}
+		})
+	}
+
+	c, _ := startMIMEClient(t, "image/jpeg", "image/png")
+	_, err := c.Infer(context.Background(), imageRequest(t, "header.avif"))
+	if want := "expected=image/jpeg, image/png, actual=image/avif"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("err = %v, want it to list the accepted types (%s)", err, want)
+	}
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+// waitForTaskPoll is how often WaitForTask recounts the healthy nodes
+// between node-list changes, which do not cover cooldowns running out.
+const waitForTaskPoll = 250 * time.Millisecond
+
+type minHealthyKey struct{}
+
+// WithMinHealthyNodes requires n healthy nodes for the task of each request
+// made with ctx, instead of its pool.min_healthy_nodes entry; 0 requires
+// none. A request for a task with fewer fails with SERVICE_UNAVAILABLE
+// before it is dispatched, or is served by the task's local handler when
+// one is registered.
+func WithMinHealthyNodes(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, minHealthyKey{}, n)
+}
+
+// MinHealthyNodesFromContext returns the minimum set with
+// WithMinHealthyNodes, and whether one was set.
+func MinHealthyNodesFromContext(ctx context.Context) (int, bool) {
+	n, ok := ctx.Value(minHealthyKey{}).(int)
+	return n, ok
+}
+
+// healthyNodes counts the nodes a request for task could be routed to at
+// now: the Ready ones serving it that are not cooling down, held, draining
+// or ejected. Probes do not count.
+func (p *lumenPicker) healthyNodes(task string, now time.Time) int {
+	nodes := withoutDraining(filterByTask(p.ready, task, false, now), p.drainingNodes())
+	if p.balancer == nil || p.balancer.registry == nil {
+		return len(nodes)
+	}
+	outliers := p.balancer.registry.outliers
+	n := 0
+	for _, scs := range nodes {
+		if !outliers.ejected(scs.identity.Key(), now) {
+			n++
+		}
+	}
+	return n
+}
+
+// HealthyNodes counts the nodes a request for task could be routed to now;
+// see discovery.TaskReadiness. It is 0 before the pool connects.
+func (p *Pool) HealthyNodes(task string) int {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return 0
+	}
+	picker := reg.picker.Load()
+	if picker == nil {
+		return 0
+	}
+	return picker.healthyNodes(task, picker.now())
+}
+
+// HealthyNodes counts the nodes a request for task could be routed to now:
+// Ready, serving the task, and not cooling down, held, draining or ejected.
+func (c *LumenClient) HealthyNodes(task string) int {
+	return c.pool.HealthyNodes(task)
+}
+
+// minHealthyNodes is the number of healthy nodes a request for task made
+// with ctx requires.
+func (c *LumenClient) minHealthyNodes(ctx context.Context, task string) int {
+	if n, ok := MinHealthyNodesFromContext(ctx); ok {
+		return n
+	}
+	return c.minHealthyConfig()[task]
+}
+
+// minHealthyConfig is pool.min_healthy_nodes.
+func (c *LumenClient) minHealthyConfig() map[string]int {
+	if c.config == nil {
+		return nil
+	}
+	return c.config.Pool.MinHealthyNodes
+}
+
+// checkMinHealthy fails a request for task whose minimum of healthy nodes
+// is not met, flagging it for the local handler like a request no node can
+// serve. Requests pinned to a node are not checked.
+func (c *LumenClient) checkMinHealthy(ctx context.Context, task string) error {
+	required := c.minHealthyNodes(ctx, task)
+	if required <= 0 || pinnedNode(ctx) != "" {
+		return nil
+	}
+	healthy := c.pool.HealthyNodes(task)
+	if healthy >= required {
+		return nil
+	}
+	if flag := noNodeFlag(ctx); flag != nil {
+		flag.Store(true)
+	}
+	return utils.ServiceUnavailableError(task, map[string]int{"healthy": healthy, "required": required})
+}
+
+// TaskReadiness reports whether each of tasks has its minimum of healthy
+// nodes: its pool.min_healthy_nodes entry, and at least 1. Without tasks it
+// reports the tasks listed in pool.min_healthy_nodes, sorted.
+func (c *LumenClient) TaskReadiness(tasks ...string) []discovery.TaskReadiness {
+	minimums := c.minHealthyConfig()
+	if len(tasks) == 0 {
+		for task := range minimums {
+			tasks = append(tasks, task)
+		}
+		slices.Sort(tasks)
+	}
+	out := make([]discovery.TaskReadiness, 0, len(tasks))
+	for _, task := range tasks {
+		r := discovery.TaskReadiness{
+			Task:     task,
+			Healthy:  c.pool.HealthyNodes(task),
+			Required: max(minimums[task], 1),
+		}
+		r.Ready = r.Healthy >= r.Required
+		out = append(out, r)
+	}
+	return out
+}
+
+// WaitForTask blocks until task has the healthy nodes a request made with
+// ctx requires, at least 1, or ctx ends. A task with pool.min_healthy_nodes
+// 3 is waited on until three nodes can serve it, not just the first.
+func (c *LumenClient) WaitForTask(ctx context.Context, task string) error {
+	required := max(c.minHealthyNodes(ctx, task), 1)
+	changed := make(chan struct{}, 1)
+	unsubscribe := c.pool.OnNodesChanged(func([]*discovery.NodeInfo) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	defer unsubscribe()
+	ticker := time.NewTicker(waitForTaskPoll)
+	defer ticker.Stop()
+	for {
+		if c.pool.HealthyNodes(task) >= required {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"google.golang.org/grpc/connectivity"
+)
+
+// minHealthyClient returns a client whose pool routes through reg, with
+// pool.min_healthy_nodes set to minimums.
+func minHealthyClient(reg *nodeRegistry, minimums map[string]int) *LumenClient {
+	cfg := config.DefaultConfig()
+	cfg.Pool.MinHealthyNodes = minimums
+	pool := NewPool(nil)
+	pool.registry = reg
+	return &LumenClient{pool: pool, config: cfg}
+}
+
+func readyNode(name string, tasks ...string) *subConnState {
+	return &subConnState{identity: discovery.NewNodeIdentity("local", name), state: connectivity.Ready, tasks: tasks}
+}
+
+// TestHealthyNodesCountsOnlyRoutableNodes checks the count leaves out every
+// node selection would: cooling down, draining, ejected, probes and nodes
+// not serving the task.
+func TestHealthyNodesCountsOnlyRoutableNodes(t *testing.T) {
+	now := time.Now()
+	cooling := readyNode("cooling", "face")
+	cooling.cooldownUntil = now.Add(time.Hour)
+	probe := readyNode("probe", "face")
+	probe.state = connectivity.TransientFailure
+	probe.cooldownUntil = now.Add(-time.Second)
+	reg := explainFixture(
+		readyNode("a", "face"), readyNode("b", "face"), readyNode("drained", "face"), readyNode("ejected", "face"),
+		readyNode("ocr", "ocr"), cooling, probe,
+	)
+	reg.draining.Store(&map[string]time.Time{"local-drained": now})
+	reg.outliers = &outlierDetector{}
+	reg.outliers.views.Store(&map[string]ejectionView{"local-ejected": {until: now.Add(time.Hour)}})
+
+	c := minHealthyClient(reg, map[string]int{"face": 2})
+	if n := c.HealthyNodes("face"); n != 2 {
+		t.Fatalf("healthy face nodes = %d, want a and b", n)
+	}
+	if err := c.checkMinHealthy(context.Background(), "face"); err != nil {
+		t.Fatalf("2 of 2 required: %v", err)
+	}
+
+	err := c.checkMinHealthy(WithMinHealthyNodes(context.Background(), 3), "face")
+	if !utils.HasErrorCode(err, utils.ErrCodeServiceUnavailable) {
+		t.Fatalf("2 of 3 required = %v, want SERVICE_UNAVAILABLE", err)
+	}
+	var lumErr *utils.LumenError
+	if !errors.As(err, &lumErr) {
+		t.Fatalf("error %T is not a LumenError", err)
+	}
+	if d, _ := lumErr.Details.(map[string]int); d["healthy"] != 2 || d["required"] != 3 {
+		t.Fatalf("details = %v, want healthy 2 of required 3", lumErr.Details)
+	}
+
+	if err := c.checkMinHealthy(WithMinHealthyNodes(context.Background(), 0), "face"); err != nil {
+		t.Fatalf("no minimum: %v", err)
+	}
+	if err := c.checkMinHealthy(context.Background(), "ocr"); err != nil {
+		t.Fatalf("task without a minimum: %v", err)
+	}
+	if err := c.checkMinHealthy(WithMinHealthyNodes(context.Background(), 1), "embed"); !utils.HasErrorCode(err, utils.ErrCodeServiceUnavailable) {
+		t.Fatalf("task no node serves = %v, want SERVICE_UNAVAILABLE", err)
+	}
+
+	got := c.TaskReadiness()
+	if len(got) != 1 || got[0] != (discovery.TaskReadiness{Task: "face", Healthy: 2, Required: 2, Ready: true}) {
+		t.Fatalf("readiness = %+v", got)
+	}
+	got = c.TaskReadiness("ocr", "embed")
+	if len(got) != 2 || !got[0].Ready || got[0].Required != 1 || got[1].Ready {
+		t.Fatalf("readiness of ocr and embed = %+v", got)
+	}
+}
+
+// TestWaitForTaskWaitsForTheMinimum checks WaitForTask keeps waiting after
+// the first node appears until the task's minimum is met.
+func TestWaitForTaskWaitsForTheMinimum(t *testing.T) {
+	reg := explainFixture(readyNode("a", "face"))
+	c := minHealthyClient(reg, map[string]int{"face": 2})
+
+	short, cancel := context.WithTimeout(context.Background(), 2*waitForTaskPoll)
+	defer cancel()
+	if err := c.WaitForTask(short, "face"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitForTask with 1 of 2 nodes = %v, want the deadline", err)
+	}
+	if err := c.WaitForTask(WithMinHealthyNodes(context.Background(), 1), "face"); err != nil {
+		t.Fatalf("WaitForTask with 1 of 1 nodes = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.WaitForTask(context.Background(), "face") }()
+	select {
+	case err := <-done:
+		t.Fatalf("WaitForTask returned %v before the second node", err)
+	case <-time.After(waitForTaskPoll / 2):
+	}
+	reg.picker.Store(explainFixture(readyNode("a", "face"), readyNode("b", "face")).picker.Load())
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("WaitForTask = %v", err)
+		}
+	case <-time.After(4 * waitForTaskPoll):
+		t.Fatal("WaitForTask did not return once two nodes serve the task")
+	}
+}
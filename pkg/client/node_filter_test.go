@@ -0,0 +1,53 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+// TestSetNodeFiltersDropsNowRejectedNodes registers a node by push, then
+// narrows the push allowlist as a configuration reload would and expects
+// the node and its connection to leave the pool.
+func TestSetNodeFiltersDropsNowRejectedNodes(t *testing.T) {
+	addr := startInferenceServer(t, &testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}})
+
+	nodeFilter, _ := discovery.NewNodeFilter(nil, nil)
+	pushFilter, _ := discovery.NewNodeFilter(nil, nil)
+	push := discovery.NewPushResolver("local", time.Minute, zap.NewNop())
+	push.SetFilter(pushFilter)
+
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{ConnectTimeout: 2 * time.Second})
+	if err := pool.Connect(push); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+	c := &LumenClient{pool: pool, push: push, nodeFilter: nodeFilter, pushFilter: pushFilter, config: config.DefaultConfig(), logger: zap.NewNop()}
+
+	if _, err := push.Register(discovery.PushRegistration{NodeID: "cpu-1", Address: addr, Tasks: []string{types.TaskSemanticTextEmbed}}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	waitUntil(t, func() bool { return len(c.GetNodes()) == 1 })
+
+	bad := config.DiscoveryConfig{DenyNodes: []string{"cpu-["}, Push: config.PushConfig{AllowNodes: []string{"gpu-*"}}}
+	if err := c.SetNodeFilters(bad); !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+		t.Fatalf("SetNodeFilters with a malformed pattern = %v, want INVALID", err)
+	}
+	if ok, _ := pushFilter.Allows(discovery.ResolvedNode{InstanceName: "cpu-1"}); !ok {
+		t.Fatal("a rejected reload still changed the push allowlist")
+	}
+
+	if err := c.SetNodeFilters(config.DiscoveryConfig{Push: config.PushConfig{AllowNodes: []string{"gpu-*"}}}); err != nil {
+		t.Fatalf("SetNodeFilters: %v", err)
+	}
+	waitUntil(t, func() bool { return len(c.GetNodes()) == 0 })
+	if got := push.Nodes(); len(got) != 0 {
+		t.Fatalf("pushed nodes after the reload = %+v", got)
+	}
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+)
+
+// snapshotBalancer returns a balancer with n Ready nodes, each serving the
+// tasks of caps, published to its registry.
+func snapshotBalancer(n int, caps []*pb.Capability) *lumenBalancer {
+	lb := &lumenBalancer{
+		registry: &nodeRegistry{nodes: make(map[string]*registeredNode)},
+		subConns: make(map[string]*subConnState, n),
+	}
+	for i := 0; i < n; i++ {
+		scs := &subConnState{
+			identity:     discovery.NewNodeIdentity("local", fmt.Sprintf("node-%d", i)),
+			addr:         resolver.Address{Addr: fmt.Sprintf("10.0.0.%d:50051", i)},
+			state:        connectivity.Ready,
+			capabilities: caps,
+		}
+		scs.refreshTasksLocked()
+		lb.subConns[scs.identity.Key()] = scs
+	}
+	lb.syncRegistryLocked()
+	return lb
+}
+
+func capabilityServing(tasks ...string) []*pb.Capability {
+	cap := &pb.Capability{ServiceName: "svc"}
+	for _, task := range tasks {
+		cap.Tasks = append(cap.Tasks, &pb.IOTask{Name: task})
+	}
+	return []*pb.Capability{cap}
+}
+
+// TestNodeInfosAreConsistentSnapshots replaces every node's capabilities
+// while other goroutines read and serialize the node list, as the host
+// broker does. Each NodeInfo must show one capability set throughout, and
+// changing it must not reach the registry. Run with -race.
+func TestNodeInfosAreConsistentSnapshots(t *testing.T) {
+	sets := [][]*pb.Capability{
+		capabilityServing("ocr", "face_detect"),
+		capabilityServing("clip_text_embed", "clip_image_embed", "tts"),
+	}
+	lb := snapshotBalancer(5, sets[0])
+
+	stop := make(chan struct{})
+	var writer sync.WaitGroup
+	writer.Add(1)
+	go func() {
+		defer writer.Done()
+		for i := 1; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			lb.mu.Lock()
+			for _, scs := range lb.subConns {
+				scs.capabilities = sets[i%2]
+				scs.refreshTasksLocked()
+			}
+			lb.syncRegistryLocked()
+			lb.mu.Unlock()
+		}
+	}()
+
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := 0; i < 200; i++ {
+				for _, info := range lb.registry.nodeInfos() {
+					if _, err := json.Marshal(info); err != nil {
+						t.Errorf("marshal %s: %v", info.ID, err)
+						return
+					}
+					want := len(info.Capabilities[0].GetTasks())
+					if len(info.Tasks) != want || info.Status != discovery.NodeStatusActive {
+						t.Errorf("%s: %s with %d tasks for a capability of %d", info.ID, info.Status, len(info.Tasks), want)
+						return
+					}
+					// Callers own what they get.
+					info.Tasks[0].Name = "changed"
+					info.Capabilities[0].Tasks = nil
+				}
+			}
+		}()
+	}
+	readers.Wait()
+	close(stop)
+	writer.Wait()
+
+	for _, info := range lb.registry.nodeInfos() {
+		if len(info.Capabilities[0].GetTasks()) == 0 || info.Tasks[0].Name == "changed" {
+			t.Fatalf("%s: a caller's changes reached the registry: %+v", info.ID, info)
+		}
+	}
+}
+
+// BenchmarkNodeInfos snapshots 50 nodes of 20 tasks each, the cost of one
+// GetNodes call.
+func BenchmarkNodeInfos(b *testing.B) {
+	tasks := make([]string, 20)
+	for i := range tasks {
+		tasks[i] = fmt.Sprintf("task-%d", i)
+	}
+	lb := snapshotBalancer(50, capabilityServing(tasks...))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(lb.registry.nodeInfos()) != 50 {
+			b.Fatal("missing nodes")
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"sort"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/connectivity"
+)
+
+// derivedStatus is a node's status as its layers make it, and why.
+type derivedStatus struct {
+	status discovery.NodeStatus
+	reason discovery.StatusReason
+	detail string
+}
+
+// deriveNodeStatus derives rn's status from its layers, each later one
+// overriding the earlier:
+//   - the connection: active when ready, starting while connecting, error
+//     when it failed or dropped, unknown once the pool shut it down, which
+//     overrides every other layer;
+//   - capability fetches: quarantined while they keep failing;
+//   - damped health: error or suspect for an active node, and the reason
+//     for a status it agrees with;
+//   - capabilities: provisional while only TXT hints are known, degraded
+//     while some services are stale;
+//   - outlier detection: ejected;
+//   - drains: draining.
+func deriveNodeStatus(rn *registeredNode, availability discovery.NodeAvailability, health nodeHealthView, checked bool, ejection *discovery.NodeEjection, drained bool) derivedStatus {
+	d := derivedStatus{status: availability.NodeStatus()}
+	switch availability {
+	case discovery.NodeAvailabilityReady:
+		d.reason = discovery.StatusReasonConnected
+	case discovery.NodeAvailabilityConnecting, discovery.NodeAvailabilityResolving:
+		d.reason = discovery.StatusReasonConnecting
+	case discovery.NodeAvailabilityRediscovering:
+		d.reason = discovery.StatusReasonConnectionLost
+	case discovery.NodeAvailabilityUnavailable, discovery.NodeAvailabilityDegraded:
+		d.reason = discovery.StatusReasonConnectionFailed
+	}
+	if rn.state == connectivity.Shutdown {
+		return derivedStatus{status: discovery.NodeStatusUnknown, reason: discovery.StatusReasonConnectionClosed}
+	}
+
+	if rn.probeFailures >= quarantineThreshold {
+		d = derivedStatus{status: discovery.NodeStatusQuarantined, reason: discovery.StatusReasonCapabilityFetchFailed}
+	}
+	// The damper knows why, when it agrees or holds an active node back.
+	if checked && health.reason != "" && (d.status == discovery.NodeStatusActive || d.status == health.status) {
+		d = derivedStatus{status: health.status, reason: health.reason, detail: health.detail}
+	}
+	if d.status == discovery.NodeStatusActive {
+		switch {
+		case len(rn.capabilities) == 0 && len(rn.tasks) > 0:
+			d = derivedStatus{status: discovery.NodeStatusProvisional, reason: discovery.StatusReasonCapabilitiesUnconfirmed}
+		case len(rn.staleServices) > 0:
+			d = derivedStatus{status: discovery.NodeStatusDegraded, reason: discovery.StatusReasonStaleServices}
+		}
+	}
+	if ejection != nil && ejection.Ejected {
+		d = derivedStatus{status: discovery.NodeStatusEjected, reason: discovery.StatusReasonOutlierEjected, detail: ejection.Reason}
+	}
+	if drained {
+		d = derivedStatus{status: discovery.NodeStatusDraining, reason: discovery.StatusReasonOperatorDrain}
+	}
+	return d
+}
+
+// recordStatusLocked moves the state of the node with key to status and
+// returns the change when there is one. A move
+// discovery.CanTransition rejects leaves the node its last status and is
+// logged at debug. The caller holds statusMu.
+func (r *nodeRegistry) recordStatusLocked(key string, status derivedStatus, now time.Time) *discovery.NodeStatusChanged {
+	if r.statuses == nil {
+		r.statuses = make(map[string]*discovery.NodeState)
+	}
+	state := r.statuses[key]
+	if state == nil {
+		state = &discovery.NodeState{}
+		r.statuses[key] = state
+	}
+	t, err := state.TransitionTo(status.status, status.reason, status.detail, now)
+	if err != nil {
+		ensureLogger(r.logger).Debug("node status transition rejected",
+			zap.String("id", key),
+			zap.String("from", string(state.Status)),
+			zap.String("to", string(status.status)),
+			zap.String("reason", string(status.reason)),
+		)
+	}
+	if t == nil {
+		return nil
+	}
+	return &discovery.NodeStatusChanged{NodeID: key, StatusTransition: *t}
+}
+
+// pruneStatusesLocked drops the states of nodes not in known. The caller
+// holds statusMu.
+func (r *nodeRegistry) pruneStatusesLocked(known map[string]bool) {
+	for key := range r.statuses {
+		if !known[key] {
+			delete(r.statuses, key)
+		}
+	}
+}
+
+// closeNodes records every node's connection as shut down, as closing the
+// pool does, and reports the status changes that causes.
+func (r *nodeRegistry) closeNodes() {
+	r.mu.Lock()
+	for _, rn := range r.nodes {
+		rn.state = connectivity.Shutdown
+	}
+	r.mu.Unlock()
+	r.syncStatuses()
+}
+
+// syncStatuses records every node's current status and reports each change
+// through onStatusChange, in the order it was recorded. It is called
+// wherever a layer deriveNodeStatus reads changes, so that nodeInfos only
+// reads.
+func (r *nodeRegistry) syncStatuses() {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	now := r.now()
+	draining := r.drainingNodes()
+	r.mu.RLock()
+	keys := make([]string, 0, len(r.nodes))
+	for key := range r.nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	known := make(map[string]bool, len(keys))
+	var changes []discovery.NodeStatusChanged
+	for _, key := range keys {
+		rn := r.nodes[key]
+		known[key] = true
+		health, checked := r.health.view(key, now)
+		_, drained := draining[key]
+		status := deriveNodeStatus(rn, availabilityFromRegistered(rn), health, checked, r.outliers.ejection(key, now), drained)
+		if change := r.recordStatusLocked(key, status, now); change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	r.mu.RUnlock()
+	r.pruneStatusesLocked(known)
+
+	// Reported under statusMu, so concurrent syncs report in the order
+	// they recorded; onStatusChange only queues the change.
+	if r.onStatusChange != nil {
+		for _, change := range changes {
+			r.onStatusChange(change)
+		}
+	}
+}
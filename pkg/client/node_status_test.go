@@ -0,0 +1,122 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/connectivity"
+)
+
+// TestNodeStatusFollowsLayers takes a node through the layers its status is
+// derived from and checks each change is recorded with its reason and
+// reported once, by syncStatuses; reading NodeInfos records nothing.
+func TestNodeStatusFollowsLayers(t *testing.T) {
+	reg := explainFixture(&subConnState{
+		sc:       &namedSubConn{name: "local-a"},
+		identity: discovery.NewNodeIdentity("local", "a"),
+		state:    connectivity.Ready,
+		tasks:    []string{"ocr"},
+	})
+	var changes []discovery.NodeStatusChanged
+	reg.onStatusChange = func(c discovery.NodeStatusChanged) { changes = append(changes, c) }
+	rn := reg.nodes["local-a"]
+
+	steps := []struct {
+		name   string
+		change func()
+		status discovery.NodeStatus
+		reason discovery.StatusReason
+	}{
+		{"hinted", func() {}, discovery.NodeStatusProvisional, discovery.StatusReasonCapabilitiesUnconfirmed},
+		{"fetched", func() { rn.capabilities = []*pb.Capability{{ServiceName: "ocr"}} }, discovery.NodeStatusActive, discovery.StatusReasonConnected},
+		{"stale", func() { rn.staleServices = []string{"ocr"} }, discovery.NodeStatusDegraded, discovery.StatusReasonStaleServices},
+		{"drained", func() {
+			reg.drains = map[string]*nodeDrain{"local-a": {since: time.Now()}}
+			reg.publishDrainsLocked()
+		}, discovery.NodeStatusDraining, discovery.StatusReasonOperatorDrain},
+		{"undrained", func() {
+			reg.drains = nil
+			reg.publishDrainsLocked()
+		}, discovery.NodeStatusDegraded, discovery.StatusReasonStaleServices},
+		{"refreshed", func() { rn.staleServices = nil }, discovery.NodeStatusActive, discovery.StatusReasonConnected},
+		{"unchanged", func() {}, discovery.NodeStatusActive, discovery.StatusReasonConnected},
+		// A shut-down connection overrides the other layers.
+		{"shut down", func() { rn.state = connectivity.Shutdown; rn.staleServices = []string{"ocr"} }, discovery.NodeStatusUnknown, discovery.StatusReasonConnectionClosed},
+		{"quarantined", func() { rn.state = connectivity.Ready; rn.staleServices = nil; rn.probeFailures = quarantineThreshold }, discovery.NodeStatusQuarantined, discovery.StatusReasonCapabilityFetchFailed},
+	}
+	wantChanges := 0
+	last := discovery.NodeStatusUnknown
+	for _, step := range steps {
+		step.change()
+		before := len(changes)
+		reg.nodeInfos()
+		if len(changes) != before {
+			t.Fatalf("%s: reading NodeInfos reported %d changes", step.name, len(changes)-before)
+		}
+		reg.syncStatuses()
+		info := nodeInfoByID(reg.nodeInfos(), "local-a")
+		if info.Status != step.status || info.StatusReason != step.reason {
+			t.Fatalf("%s: status %s (%s), want %s (%s)", step.name, info.Status, info.StatusReason, step.status, step.reason)
+		}
+		if step.status != last {
+			wantChanges++
+			got := changes[len(changes)-1]
+			if got.NodeID != "local-a" || got.From != last || got.To != step.status || got.Reason != step.reason || !got.At.Equal(info.StatusSince) {
+				t.Fatalf("%s: change %+v, want local-a from %s to %s", step.name, got, last, step.status)
+			}
+		}
+		if len(changes) != wantChanges {
+			t.Fatalf("%s: %d changes reported, want %d", step.name, len(changes), wantChanges)
+		}
+		last = step.status
+	}
+	if info := nodeInfoByID(reg.nodeInfos(), "local-a"); len(info.StatusHistory) != wantChanges {
+		t.Fatalf("history holds %d transitions, want %d", len(info.StatusHistory), wantChanges)
+	}
+
+	delete(reg.nodes, "local-a")
+	reg.syncStatuses()
+	if len(reg.statuses) != 0 {
+		t.Fatalf("a dropped node keeps its status: %v", reg.statuses)
+	}
+}
+
+// TestPoolCloseReportsNodesClosed checks closing the pool moves each known
+// node to unknown for connection_closed, and tells status watchers.
+func TestPoolCloseReportsNodesClosed(t *testing.T) {
+	addr := startInferenceServer(t, &testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}})
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{ConnectTimeout: 2 * time.Second})
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{
+		discoveredNode("a", addr, types.TaskSemanticTextEmbed),
+	}}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	waitUntil(t, func() bool {
+		info := nodeInfoByID(pool.NodeInfos(), "local-a")
+		return info != nil && info.Status == discovery.NodeStatusActive
+	})
+	changes := make(chan discovery.NodeStatusChanged, 4)
+	pool.OnStatusChange(func(c discovery.NodeStatusChanged) { changes <- c })
+	reg := pool.registry
+
+	if err := pool.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	select {
+	case c := <-changes:
+		if c.NodeID != "local-a" || c.From != discovery.NodeStatusActive || c.To != discovery.NodeStatusUnknown || c.Reason != discovery.StatusReasonConnectionClosed {
+			t.Fatalf("change on Close = %+v, want local-a from active to unknown for connection_closed", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no status change on Close")
+	}
+	reg.statusMu.Lock()
+	defer reg.statusMu.Unlock()
+	if st := reg.statuses["local-a"]; st == nil || st.Status != discovery.NodeStatusUnknown || st.Reason != discovery.StatusReasonConnectionClosed {
+		t.Fatalf("recorded status = %+v, want unknown for connection_closed", st)
+	}
+}
@@ -0,0 +1,517 @@
+package client
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// outlierMinErrorRateGap keeps small absolute differences from ejecting
+	// a node: its error rate must also be this far above the median, so 0.4%
+	// against a median of 0.1% ejects nothing.
+	outlierMinErrorRateGap = 0.05
+	// outlierMaxMultiplier caps how many times EjectionTime one ejection
+	// lasts.
+	outlierMaxMultiplier = 8
+)
+
+// outlierDetector tracks each node's request outcomes over a sliding window
+// and ejects nodes far worse than the cluster median. It lives in the
+// nodeRegistry, so ejections outlive balancer rebuilds.
+type outlierDetector struct {
+	cfg config.OutlierConfig
+	// clock times outcomes and re-admissions; nil is the wall clock.
+	clock utils.Clock
+
+	mu        sync.Mutex
+	windows   map[string]*outcomeWindow
+	ejections map[string]*ejection
+	// views republishes each ejection's timing on every change so Pick can
+	// read them without locking.
+	views atomic.Pointer[map[string]ejectionView]
+
+	// onReadmit is called, outside mu, when an ejection ends and the node's
+	// re-admission starts.
+	onReadmit func(discovery.NodeEjection)
+}
+
+// ejection is one node's ejection record. It is kept after re-admission so
+// a node ejected again soon after is ejected for longer; Count decays by one
+// for every evaluation the node passes.
+type ejection struct {
+	state discovery.NodeEjection
+	timer utils.Timer
+}
+
+// ejectionView is what Pick needs of an ejection: the node takes no requests
+// until until, then a share growing from the probe fraction to all of them
+// at rampUntil.
+type ejectionView struct {
+	until, rampUntil time.Time
+}
+
+func newOutlierDetector(cfg config.OutlierConfig) *outlierDetector {
+	def := config.DefaultConfig().Pool.Outlier
+	if cfg.Interval <= 0 {
+		cfg.Interval = def.Interval
+	}
+	if cfg.Window < cfg.Interval {
+		cfg.Window = max(def.Window, cfg.Interval)
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = def.MinRequests
+	}
+	if cfg.ErrorRateFactor <= 1 {
+		cfg.ErrorRateFactor = def.ErrorRateFactor
+	}
+	if cfg.EjectionTime <= 0 {
+		cfg.EjectionTime = def.EjectionTime
+	}
+	if cfg.MaxEjectionPercent <= 0 || cfg.MaxEjectionPercent > 100 {
+		cfg.MaxEjectionPercent = def.MaxEjectionPercent
+	}
+	if cfg.ProbeFraction <= 0 || cfg.ProbeFraction > 1 {
+		cfg.ProbeFraction = def.ProbeFraction
+	}
+	return &outlierDetector{
+		cfg:       cfg,
+		windows:   make(map[string]*outcomeWindow),
+		ejections: make(map[string]*ejection),
+	}
+}
+
+// record counts one finished request to the node with key: a failure, or a
+// success taking latency.
+func (d *outlierDetector) record(key string, failed bool, latency time.Duration) {
+	if d == nil {
+		return
+	}
+	now := utils.ClockOrReal(d.clock).Now()
+	d.mu.Lock()
+	w := d.windows[key]
+	if w == nil {
+		w = newOutcomeWindow(now)
+		d.windows[key] = w
+	}
+	w.record(now, d.cfg.Window, failed, latency)
+	d.mu.Unlock()
+}
+
+// nodeOutcomes is one node's outcomes over the detection window.
+type nodeOutcomes struct {
+	key       string
+	requests  int
+	errorRate float64
+	latency   time.Duration // median of successful requests; 0 if none
+}
+
+// evaluate compares the nodes with keys, the ones currently known, and
+// ejects the outliers, never leaving more than MaxEjectionPercent of them
+// ejected. It returns the new ejections.
+func (d *outlierDetector) evaluate(now time.Time, keys []string) []discovery.NodeEjection {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key] = true
+	}
+	for key := range d.windows {
+		if !known[key] {
+			delete(d.windows, key)
+		}
+	}
+
+	ejected := 0
+	var judged []nodeOutcomes
+	for _, key := range keys {
+		if e := d.ejections[key]; e != nil && now.Before(e.state.Until) {
+			ejected++
+			continue
+		}
+		w := d.windows[key]
+		if w == nil {
+			continue
+		}
+		if o := w.outcomes(now, d.cfg.Window); o.requests >= d.cfg.MinRequests {
+			o.key = key
+			judged = append(judged, o)
+		}
+	}
+	if len(judged) < 2 {
+		return nil
+	}
+
+	medianErr := lowerMedian(judged, func(o nodeOutcomes) (float64, bool) { return o.errorRate, true })
+	medianLatency := time.Duration(lowerMedian(judged, func(o nodeOutcomes) (float64, bool) {
+		return float64(o.latency), o.latency > 0
+	}))
+
+	var outliers []outlier
+	for _, o := range judged {
+		switch {
+		case o.errorRate > d.cfg.ErrorRateFactor*medianErr && o.errorRate-medianErr >= outlierMinErrorRateGap:
+			outliers = append(outliers, outlier{o, discovery.EjectedForErrorRate, outlierErrorRate, o.errorRate})
+		case d.cfg.LatencyFactor > 0 && medianLatency > 0 && float64(o.latency) > d.cfg.LatencyFactor*float64(medianLatency):
+			outliers = append(outliers, outlier{o, discovery.EjectedForLatency, outlierLatency, float64(o.latency) / float64(medianLatency)})
+		default:
+			d.decayLocked(o.key, now)
+		}
+	}
+	sortOutliers(outliers)
+
+	limit := len(keys) * d.cfg.MaxEjectionPercent / 100
+	var events []discovery.NodeEjection
+	for _, o := range outliers {
+		if ejected >= limit {
+			break
+		}
+		ejected++
+		events = append(events, d.ejectLocked(o.key, o.reason, o.nodeOutcomes, medianErr, medianLatency, now))
+	}
+	if len(events) > 0 {
+		d.publishLocked()
+	}
+	return events
+}
+
+// outlierKind orders ejection candidates when the cap binds: error-rate
+// outliers go before latency outliers.
+type outlierKind int
+
+const (
+	outlierErrorRate outlierKind = iota
+	outlierLatency
+)
+
+// outlier is a node found an outlier in one evaluation. severity compares
+// outliers of the same kind: the error rate, or the latency over the
+// median.
+type outlier struct {
+	nodeOutcomes
+	reason   string
+	kind     outlierKind
+	severity float64
+}
+
+// sortOutliers orders outliers by kind, then the worst first.
+func sortOutliers(outliers []outlier) {
+	slices.SortStableFunc(outliers, func(a, b outlier) int {
+		return cmp.Or(cmp.Compare(a.kind, b.kind), cmp.Compare(b.severity, a.severity))
+	})
+}
+
+func (d *outlierDetector) ejectLocked(key, reason string, o nodeOutcomes, medianErr float64, medianLatency time.Duration, now time.Time) discovery.NodeEjection {
+	e := d.ejections[key]
+	if e == nil {
+		e = &ejection{}
+		d.ejections[key] = e
+	} else if e.timer != nil {
+		e.timer.Stop()
+	}
+	count := min(e.state.Count+1, outlierMaxMultiplier)
+	until := now.Add(time.Duration(count) * d.cfg.EjectionTime)
+	e.state = discovery.NodeEjection{
+		NodeID:          key,
+		Ejected:         true,
+		Reason:          reason,
+		ErrorRate:       o.errorRate,
+		MedianErrorRate: medianErr,
+		Latency:         o.latency,
+		MedianLatency:   medianLatency,
+		Count:           count,
+		Since:           now,
+		Until:           until,
+		RampUntil:       until.Add(d.cfg.EjectionTime),
+		At:              now,
+	}
+	e.timer = utils.ClockOrReal(d.clock).AfterFunc(until.Sub(now), func() { d.readmit(key, e) })
+	return e.state
+}
+
+// readmit starts re-admitting the node with key when its ejection e ends,
+// judging it afresh from then on.
+func (d *outlierDetector) readmit(key string, e *ejection) {
+	d.mu.Lock()
+	if d.ejections[key] != e || !e.state.Ejected {
+		d.mu.Unlock()
+		return
+	}
+	e.state.Ejected = false
+	e.state.At = utils.ClockOrReal(d.clock).Now()
+	e.timer = nil
+	delete(d.windows, key)
+	d.publishLocked()
+	state := e.state
+	d.mu.Unlock()
+	if d.onReadmit != nil {
+		d.onReadmit(state)
+	}
+}
+
+// decayLocked credits a node that passed an evaluation after re-admission:
+// its next ejection is shorter, and once back to none its record is dropped.
+func (d *outlierDetector) decayLocked(key string, now time.Time) {
+	e := d.ejections[key]
+	if e == nil || now.Before(e.state.RampUntil) {
+		return
+	}
+	e.state.Count--
+	if e.state.Count <= 0 {
+		delete(d.ejections, key)
+		d.publishLocked()
+	}
+}
+
+func (d *outlierDetector) publishLocked() {
+	views := make(map[string]ejectionView, len(d.ejections))
+	for key, e := range d.ejections {
+		views[key] = ejectionView{until: e.state.Until, rampUntil: e.state.RampUntil}
+	}
+	d.views.Store(&views)
+}
+
+// view returns the ejection of the node with key as Pick sees it.
+func (d *outlierDetector) view(key string) (ejectionView, bool) {
+	if d == nil {
+		return ejectionView{}, false
+	}
+	m := d.views.Load()
+	if m == nil {
+		return ejectionView{}, false
+	}
+	v, ok := (*m)[key]
+	return v, ok
+}
+
+// ejected reports whether the node with key is out of selection at now.
+func (d *outlierDetector) ejected(key string, now time.Time) bool {
+	v, ok := d.view(key)
+	return ok && now.Before(v.until)
+}
+
+// admits decides whether a node being re-admitted takes one request at now:
+// with a probability growing linearly from ProbeFraction at the end of its
+// ejection to 1 at the end of the ramp.
+func (d *outlierDetector) admits(key string, now time.Time) bool {
+	v, ok := d.view(key)
+	if !ok || !now.Before(v.rampUntil) {
+		return true
+	}
+	if now.Before(v.until) {
+		return false
+	}
+	share := d.cfg.ProbeFraction + (1-d.cfg.ProbeFraction)*float64(now.Sub(v.until))/float64(v.rampUntil.Sub(v.until))
+	return rand.Float64() < share
+}
+
+// ejection returns the node's ejection while it is ejected or being
+// re-admitted, or nil.
+func (d *outlierDetector) ejection(key string, now time.Time) *discovery.NodeEjection {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e := d.ejections[key]
+	if e == nil || !now.Before(e.state.RampUntil) {
+		return nil
+	}
+	state := e.state
+	return &state
+}
+
+// ejectedCount is the number of nodes out of selection at now.
+func (d *outlierDetector) ejectedCount(now time.Time) int {
+	if d == nil {
+		return 0
+	}
+	m := d.views.Load()
+	if m == nil {
+		return 0
+	}
+	n := 0
+	for _, v := range *m {
+		if now.Before(v.until) {
+			n++
+		}
+	}
+	return n
+}
+
+// stop cancels pending re-admissions.
+func (d *outlierDetector) stop() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.ejections {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+	}
+}
+
+// lowerMedian returns the lower median of the values value reports for
+// nodes, skipping those it reports no value for; 0 when there are none.
+func lowerMedian(nodes []nodeOutcomes, value func(nodeOutcomes) (float64, bool)) float64 {
+	vals := make([]float64, 0, len(nodes))
+	for _, o := range nodes {
+		if v, ok := value(o); ok {
+			vals = append(vals, v)
+		}
+	}
+	if len(vals) == 0 {
+		return 0
+	}
+	sort.Float64s(vals)
+	return vals[(len(vals)-1)/2]
+}
+
+// outcomeWindow counts one node's requests in the current and previous
+// window, rotated like latencyTracker, so it always covers between one and
+// two windows of traffic.
+type outcomeWindow struct {
+	rotatedAt time.Time
+	cur, prev *outcomeBucket
+}
+
+type outcomeBucket struct {
+	requests, failures int
+	latency            latencyHistogram
+}
+
+func newOutcomeWindow(now time.Time) *outcomeWindow {
+	return &outcomeWindow{rotatedAt: now, cur: &outcomeBucket{}}
+}
+
+func (w *outcomeWindow) rotate(now time.Time, window time.Duration) {
+	elapsed := now.Sub(w.rotatedAt)
+	if elapsed < window {
+		return
+	}
+	w.prev = w.cur
+	if elapsed >= 2*window {
+		w.prev = nil
+	}
+	w.cur = &outcomeBucket{}
+	w.rotatedAt = now
+}
+
+func (w *outcomeWindow) record(now time.Time, window time.Duration, failed bool, latency time.Duration) {
+	w.rotate(now, window)
+	w.cur.requests++
+	if failed {
+		w.cur.failures++
+		return
+	}
+	w.cur.latency.observe(latency)
+}
+
+func (w *outcomeWindow) outcomes(now time.Time, window time.Duration) nodeOutcomes {
+	w.rotate(now, window)
+	counts := make([]uint64, len(latencyBuckets)+1)
+	var o nodeOutcomes
+	var failures int
+	var maxLatency time.Duration
+	for _, b := range []*outcomeBucket{w.cur, w.prev} {
+		if b == nil {
+			continue
+		}
+		o.requests += b.requests
+		failures += b.failures
+		maxLatency = max(maxLatency, b.latency.addTo(counts))
+	}
+	if o.requests > 0 {
+		o.errorRate = float64(failures) / float64(o.requests)
+	}
+	o.latency = summarizeLatency(counts, maxLatency).P50
+	return o
+}
+
+// filter leaves out of candidates the nodes ejected at now and, when thin is
+// set, each node being re-admitted unless it admits this request. Ejection
+// never takes a task's last nodes: when it would leave none, candidates are
+// returned unchanged.
+func (d *outlierDetector) filter(candidates []*subConnState, now time.Time, thin bool) []*subConnState {
+	if d == nil || d.views.Load() == nil {
+		return candidates
+	}
+	out := candidates[:0:0]
+	for _, scs := range candidates {
+		key := scs.identity.Key()
+		if d.ejected(key, now) || (thin && !d.admits(key, now)) {
+			continue
+		}
+		out = append(out, scs)
+	}
+	if len(out) == 0 {
+		return candidates
+	}
+	return out
+}
+
+// withoutEjected filters candidates for one pick.
+func (p *lumenPicker) withoutEjected(candidates []*subConnState, now time.Time) []*subConnState {
+	if p.balancer == nil || p.balancer.registry == nil {
+		return candidates
+	}
+	return p.balancer.registry.outliers.filter(candidates, now, true)
+}
+
+// outlierLoop evaluates the nodes for ejection once per interval until the
+// balancer is closed.
+func (lb *lumenBalancer) outlierLoop(interval time.Duration) {
+	ticker := lb.clock().NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lb.done:
+			return
+		case now := <-ticker.C():
+			lb.evaluateOutliers(now)
+		}
+	}
+}
+
+func (lb *lumenBalancer) evaluateOutliers(now time.Time) {
+	lb.mu.Lock()
+	keys := make([]string, 0, len(lb.subConns))
+	for key := range lb.subConns {
+		keys = append(keys, key)
+	}
+	lb.mu.Unlock()
+
+	reg := lb.registry
+	events := reg.outliers.evaluate(now, keys)
+	for _, ev := range events {
+		lb.logger.Warn("node ejected",
+			zap.String("node", ev.NodeID),
+			zap.String("reason", ev.Reason),
+			zap.Float64("error_rate", ev.ErrorRate),
+			zap.Float64("median_error_rate", ev.MedianErrorRate),
+			zap.Duration("latency", ev.Latency),
+			zap.Duration("median_latency", ev.MedianLatency),
+			zap.Time("until", ev.Until))
+		if reg.onEjection != nil {
+			reg.onEjection(ev)
+		}
+	}
+	if len(events) > 0 {
+		reg.syncStatuses()
+		if reg.onChanged != nil {
+			reg.onChanged()
+		}
+	}
+}
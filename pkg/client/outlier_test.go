@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+)
+
+func testOutlierConfig() config.OutlierConfig {
+	cfg := config.DefaultConfig().Pool.Outlier
+	cfg.Enabled = true
+	cfg.LatencyFactor = 3
+	return cfg
+}
+
+// recordOutcomes records requests to key, failures of them failed, each
+// success taking latency.
+func recordOutcomes(d *outlierDetector, key string, requests, failures int, latency time.Duration) {
+	for i := 0; i < requests; i++ {
+		d.record(key, i < failures, latency)
+	}
+}
+
+func TestOutlierEjectsErrorRateOutliers(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures map[string]int // out of 100 requests each
+		want     []string
+	}{
+		{
+			name:     "one failing node",
+			failures: map[string]int{"a": 0, "b": 1, "c": 0, "d": 40},
+			want:     []string{"d"},
+		},
+		{
+			name:     "small absolute gap",
+			failures: map[string]int{"a": 0, "b": 1, "c": 1, "d": 4},
+		},
+		{
+			// 34% of four nodes allows one ejection: the worse node.
+			name:     "capped at max ejection percent",
+			failures: map[string]int{"a": 0, "b": 0, "c": 30, "d": 60},
+			want:     []string{"d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newOutlierDetector(testOutlierConfig())
+			defer d.stop()
+			keys := []string{"a", "b", "c", "d"}
+			for _, key := range keys {
+				recordOutcomes(d, key, 100, tt.failures[key], time.Millisecond)
+			}
+			events := d.evaluate(time.Now(), keys)
+			var got []string
+			for _, ev := range events {
+				if !ev.Ejected || ev.Reason != discovery.EjectedForErrorRate || ev.Count != 1 {
+					t.Errorf("ejection = %+v, want a first error-rate ejection", ev)
+				}
+				got = append(got, ev.NodeID)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Fatalf("ejected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutlierEjectsSlowNode(t *testing.T) {
+	d := newOutlierDetector(testOutlierConfig())
+	defer d.stop()
+	keys := []string{"a", "b", "c"}
+	recordOutcomes(d, "a", 50, 0, 2*time.Millisecond)
+	recordOutcomes(d, "b", 50, 0, 2*time.Millisecond)
+	recordOutcomes(d, "c", 50, 0, 500*time.Millisecond)
+
+	events := d.evaluate(time.Now(), keys)
+	if len(events) != 1 || events[0].NodeID != "c" || events[0].Reason != discovery.EjectedForLatency {
+		t.Fatalf("ejections = %+v, want c for latency", events)
+	}
+	if events[0].Latency <= events[0].MedianLatency {
+		t.Fatalf("ejected latency %v not above median %v", events[0].Latency, events[0].MedianLatency)
+	}
+}
+
+// TestSortOutliers checks error-rate outliers go before latency outliers
+// however slow those are, and the worst of each kind first.
+func TestSortOutliers(t *testing.T) {
+	outliers := []outlier{
+		{nodeOutcomes: nodeOutcomes{key: "slow"}, kind: outlierLatency, severity: 5},
+		{nodeOutcomes: nodeOutcomes{key: "failing"}, kind: outlierErrorRate, severity: 0.1},
+		{nodeOutcomes: nodeOutcomes{key: "stalled"}, kind: outlierLatency, severity: 2000},
+		{nodeOutcomes: nodeOutcomes{key: "broken"}, kind: outlierErrorRate, severity: 0.9},
+	}
+	sortOutliers(outliers)
+	var got []string
+	for _, o := range outliers {
+		got = append(got, o.key)
+	}
+	if want := []string{"broken", "failing", "stalled", "slow"}; !slices.Equal(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+}
+
+func TestOutlierSkipsNodesWithFewRequests(t *testing.T) {
+	d := newOutlierDetector(testOutlierConfig())
+	defer d.stop()
+	recordOutcomes(d, "a", 100, 0, time.Millisecond)
+	recordOutcomes(d, "b", 100, 0, time.Millisecond)
+	recordOutcomes(d, "c", 10, 10, time.Millisecond) // below min_requests
+
+	if events := d.evaluate(time.Now(), []string{"a", "b", "c"}); len(events) != 0 {
+		t.Fatalf("ejections = %+v, want none for a node below min_requests", events)
+	}
+}
+
+// TestOutlierReadmitsAndBacksOff runs the detector on a FakeClock: the
+// node is re-admitted as the clock passes the end of its ejection, and
+// ejected for twice as long when it fails again soon after.
+func TestOutlierReadmitsAndBacksOff(t *testing.T) {
+	cfg := testOutlierConfig()
+	cfg.EjectionTime = 30 * time.Second
+	d := newOutlierDetector(cfg)
+	clock := utils.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	d.clock = clock
+	defer d.stop()
+	readmitted := make(chan discovery.NodeEjection, 2)
+	d.onReadmit = func(ev discovery.NodeEjection) { readmitted <- ev }
+	keys := []string{"a", "b", "c"}
+
+	eject := func() discovery.NodeEjection {
+		t.Helper()
+		recordOutcomes(d, "a", 50, 0, time.Millisecond)
+		recordOutcomes(d, "b", 50, 0, time.Millisecond)
+		recordOutcomes(d, "c", 50, 50, 0)
+		events := d.evaluate(clock.Now(), keys)
+		if len(events) != 1 || events[0].NodeID != "c" {
+			t.Fatalf("ejections = %+v, want c", events)
+		}
+		return events[0]
+	}
+
+	first := eject()
+	if !d.ejected("c", clock.Now()) || d.admits("c", clock.Now()) {
+		t.Fatal("ejected node is still selectable")
+	}
+	clock.Advance(cfg.EjectionTime - time.Second)
+	select {
+	case ev := <-readmitted:
+		t.Fatalf("re-admitted before the ejection ended: %+v", ev)
+	default:
+	}
+	clock.Advance(time.Second)
+	select {
+	case ev := <-readmitted:
+		if ev.Ejected || ev.NodeID != "c" || !ev.At.Equal(first.Until) {
+			t.Fatalf("re-admission = %+v, want c at %v", ev, first.Until)
+		}
+	default:
+		t.Fatal("ejected node not re-admitted once the clock passed its ejection")
+	}
+	now := clock.Now()
+	if d.ejected("c", now) {
+		t.Fatal("node still ejected after its ejection time")
+	}
+	if info := d.ejection("c", now); info == nil || info.Ejected {
+		t.Fatalf("ramping ejection = %+v, want one not ejected", info)
+	}
+
+	// Failing again soon after is punished for twice as long.
+	second := eject()
+	if second.Count != 2 || second.Until.Sub(second.Since) != 2*first.Until.Sub(first.Since) {
+		t.Fatalf("second ejection = %+v, want count 2 lasting twice as long", second)
+	}
+}
+
+func TestPickSkipsEjectedNodes(t *testing.T) {
+	var nodes []*subConnState
+	for _, name := range []string{"a", "b"} {
+		nodes = append(nodes, &subConnState{
+			sc:       &namedSubConn{name: "local-" + name},
+			identity: discovery.NewNodeIdentity("local", name),
+			state:    connectivity.Ready,
+			tasks:    []string{"ocr"},
+		})
+	}
+	reg := explainFixture(nodes...)
+	reg.outliers = newOutlierDetector(testOutlierConfig())
+	defer reg.outliers.stop()
+	picker := reg.picker.Load()
+	picker.balancer = &lumenBalancer{registry: reg}
+
+	reg.outliers.mu.Lock()
+	reg.outliers.ejectLocked("local-a", discovery.EjectedForErrorRate, nodeOutcomes{errorRate: 0.5}, 0, 0, time.Now())
+	reg.outliers.publishLocked()
+	reg.outliers.mu.Unlock()
+
+	info := balancer.PickInfo{Ctx: WithTask(context.Background(), "ocr")}
+	for i := 0; i < 4; i++ {
+		res, err := picker.Pick(info)
+		if err != nil {
+			t.Fatalf("Pick: %v", err)
+		}
+		if name := res.SubConn.(*namedSubConn).name; name != "local-b" {
+			t.Fatalf("picked %s, want the node that is not ejected", name)
+		}
+	}
+	if c := candidateByID(reg.explainSelection("ocr", time.Now()), "local-a"); c.Eligible || c.Reason != discovery.SelectionEjected {
+		t.Fatalf("explained ejected node = %+v, want reason ejected", c)
+	}
+	if info := nodeInfoByID(reg.nodeInfos(), "local-a"); info.Status != discovery.NodeStatusEjected || info.Ejection == nil {
+		t.Fatalf("ejected node info = %+v", info)
+	}
+
+	// Ejection never takes the last node for a task.
+	reg.drains = map[string]*nodeDrain{"local-b": {since: time.Now()}}
+	reg.publishDrainsLocked()
+	if res, err := picker.Pick(info); err != nil || res.SubConn.(*namedSubConn).name != "local-a" {
+		t.Fatalf("Pick with only the ejected node = %v, %v; want local-a", res.SubConn, err)
+	}
+}
+
+func TestEjectionWatchersAndStats(t *testing.T) {
+	pool := newWatchedPool(t)
+	reg := pool.registry
+	reg.outliers = newOutlierDetector(testOutlierConfig())
+	reg.onEjection = pool.notifyEjectionWatchers
+	defer reg.outliers.stop()
+	events := make(chan discovery.NodeEjection, 1)
+	pool.OnNodeEjection(func(ev discovery.NodeEjection) { events <- ev })
+
+	lb := &lumenBalancer{registry: reg, logger: zap.NewNop(), subConns: make(map[string]*subConnState)}
+	for _, key := range []string{"a", "b", "c"} {
+		lb.subConns[key] = &subConnState{}
+		failures := 0
+		if key == "b" {
+			failures = 20
+		}
+		recordOutcomes(reg.outliers, key, 40, failures, time.Millisecond)
+	}
+	lb.evaluateOutliers(time.Now())
+
+	select {
+	case ev := <-events:
+		if ev.NodeID != "b" || !ev.Ejected {
+			t.Fatalf("ejection event = %+v, want b ejected", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ejection watcher never fired")
+	}
+	if n := pool.Stats().Ejected; n != 1 {
+		t.Fatalf("Stats().Ejected = %d, want 1", n)
+	}
+}
@@ -0,0 +1,227 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/tasks"
+	sdktypes "github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+)
+
+// ParallelUploadExtraKey is the capability Extra key a node sets to accept
+// one chunked payload over several concurrent Infer streams: "true", or the
+// most streams it takes per request. A node listing
+// discovery.FeatureParallelUpload under discovery.FeaturesExtraKey instead
+// takes up to config.MaxParallelStreams.
+const ParallelUploadExtraKey = discovery.FeatureParallelUpload
+
+// UploadPartMetaKey is the request Meta key naming the stream ("x/y",
+// 1-based) a chunk of a parallel upload travels on. The node reassembles the
+// payload by CorrelationId, Seq and Offset and answers on part 1 only; the
+// other streams end with just a status.
+const UploadPartMetaKey = "lumen.upload_part"
+
+type pinnedNodeKey struct{}
+
+// withPinnedNode makes lumenPicker route the RPC to the node with key, and
+// fail it when that node is not eligible, instead of balancing it.
+func withPinnedNode(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, pinnedNodeKey{}, key)
+}
+
+func pinnedNode(ctx context.Context) string {
+	key, _ := ctx.Value(pinnedNodeKey{}).(string)
+	return key
+}
+
+// pickedNode receives the key of the node lumenPicker routes an RPC to,
+// and the task that node advertises for the request (see tasks.Resolve).
+type pickedNode struct {
+	mu   sync.Mutex
+	key  string
+	task string
+}
+
+func (n *pickedNode) set(key, task string) {
+	n.mu.Lock()
+	n.key, n.task = key, task
+	n.mu.Unlock()
+}
+
+func (n *pickedNode) get() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.key
+}
+
+func (n *pickedNode) resolvedTask() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.task
+}
+
+type pickedNodeKey struct{}
+
+func withPickedNode(ctx context.Context, slot *pickedNode) context.Context {
+	return context.WithValue(ctx, pickedNodeKey{}, slot)
+}
+
+func pickedNodeSlot(ctx context.Context) *pickedNode {
+	slot, _ := ctx.Value(pickedNodeKey{}).(*pickedNode)
+	return slot
+}
+
+// parallelParts returns how many streams to upload req's chunks over to the
+// node with key: 1 unless parallel upload is configured, the payload is
+// above chunk.parallel_threshold and the node supports
+// discovery.FeatureParallelUpload on a service serving req's task. A
+// ParallelUploadExtraKey count caps the streams.
+func (c *LumenClient) parallelParts(key string, req *pb.InferRequest, chunks int) int {
+	cfg := c.config.Chunk
+	parts := min(cfg.ParallelStreams, config.MaxParallelStreams, chunks)
+	if parts < 2 || key == "" || len(req.Payload) <= cfg.ParallelThreshold {
+		return 1
+	}
+	if !c.pool.nodeSupportsFeature(key, discovery.FeatureParallelUpload) {
+		return 1
+	}
+	limit := 0
+	for _, cap := range c.pool.nodeCapabilities(key) {
+		if !capabilityHasTask(cap, req.Task) {
+			continue
+		}
+		if n, err := strconv.Atoi(cap.GetExtra()[ParallelUploadExtraKey]); err == nil {
+			limit = max(limit, n)
+		} else if slices.Contains(discovery.FeaturesFromCapabilities([]*pb.Capability{cap}), discovery.FeatureParallelUpload) {
+			limit = config.MaxParallelStreams
+		}
+	}
+	return max(min(parts, limit), 1)
+}
+
+func capabilityHasTask(cap *pb.Capability, task string) bool {
+	for _, t := range cap.GetTasks() {
+		if tasks.Match(task, t.GetName()) {
+			return true
+		}
+	}
+	return false
+}
+
+// inferParallel uploads chunks over parts streams to the node with key.
+// first is already open to that node; it carries the first range and
+// receives the response. The others are opened pinned to the same node and
+// each carries the next contiguous range. The first failure on any stream
+// cancels them all through cancel, which must cancel ctx. Each part's
+// sender retries failed Sends as send says; progress frames go to ctx's
+// handler through callbacks.
+func inferParallel(ctx context.Context, cancel context.CancelFunc, cli pb.InferenceClient, first grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse], key string, req *pb.InferRequest, chunks [][]byte, parts int, send chunkSenderOptions, callbacks *callbackGuard) (*pb.InferResponse, error) {
+	streams := []grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse]{first}
+	for i := 1; i < parts; i++ {
+		stream, err := cli.Infer(withPinnedNode(ctx, key))
+		if err != nil {
+			return nil, fmt.Errorf("open upload part %d/%d: %w", i+1, parts, err)
+		}
+		streams = append(streams, stream)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		failErr  error
+	)
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failErr = err
+			cancel()
+		})
+	}
+	for i, stream := range streams {
+		lo, hi := i*len(chunks)/parts, (i+1)*len(chunks)/parts
+		meta := make(map[string]string, len(req.Meta)+1)
+		for k, v := range req.Meta {
+			meta[k] = v
+		}
+		meta[UploadPartMetaKey] = fmt.Sprintf("%d/%d", i+1, parts)
+
+		opts := send
+		opts.lo, opts.hi, opts.meta = lo, hi, meta
+		sender := newChunkSender(stream, req, chunks, opts)
+		sender.Start(ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-sender.Done()
+			sendErr := sender.Err()
+			if i == 0 {
+				// The receive loop below reports first's status.
+				if sendErr != nil {
+					fail(fmt.Errorf("upload part 1/%d: send: %w", parts, sendErr))
+				}
+				return
+			}
+			err := awaitUploadStatus(stream)
+			if err == nil && sendErr != nil {
+				err = fmt.Errorf("send: %w", sendErr)
+			}
+			if err != nil {
+				fail(fmt.Errorf("upload part %d/%d: %w", i+1, parts, err))
+			}
+		}()
+	}
+
+	var responses []*pb.InferResponse
+	for {
+		resp, err := first.Recv()
+		if err != nil {
+			if err == io.EOF && len(responses) > 0 {
+				break
+			}
+			fail(fmt.Errorf("recv: %w", err))
+			wg.Wait()
+			return nil, failErr
+		}
+		if !resp.IsFinal && reportProgress(ctx, callbacks, resp) {
+			continue
+		}
+		responses = append(responses, resp)
+		if resp.IsFinal {
+			drainStream(first, cancel)
+			break
+		}
+	}
+
+	// The node answered, so it has every part; give the other streams the
+	// usual grace to report their status before cutting them off.
+	timer := time.AfterFunc(streamDrainGrace, cancel)
+	wg.Wait()
+	timer.Stop()
+
+	finalResp, err := sdktypes.AssembleInferResponses(responses)
+	if err != nil {
+		return nil, fmt.Errorf("assemble response: %w", err)
+	}
+	return finalResp, nil
+}
+
+// awaitUploadStatus reads a secondary upload stream until the node closes
+// it, returning nil for a clean close.
+func awaitUploadStatus(stream grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse]) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
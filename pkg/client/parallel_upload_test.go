@@ -0,0 +1,313 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// uploadStream is what parallelUploadServer saw on one Infer stream.
+type uploadStream struct {
+	part         string
+	correlations map[string]bool
+	seqs         []uint64
+	offsets      []uint64
+}
+
+// parallelUploadServer reassembles parallel uploads by Seq and echoes the
+// payload on part 1 once every chunk has arrived. Streams without a part
+// are answered on their own, like a node without parallel upload.
+type parallelUploadServer struct {
+	testInferenceServer
+	extra    string // ParallelUploadExtraKey advertised; empty for none
+	features string // discovery.FeaturesExtraKey advertised; empty for none
+	failPart string // part that fails after its first chunk
+
+	mu       sync.Mutex
+	chunks   map[uint64][]byte
+	complete chan struct{}
+	streams  []uploadStream
+	aborted  int
+}
+
+func newParallelUploadServer(extra, failPart string) *parallelUploadServer {
+	return &parallelUploadServer{
+		testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}},
+		extra:               extra,
+		failPart:            failPart,
+		chunks:              make(map[uint64][]byte),
+		complete:            make(chan struct{}),
+	}
+}
+
+func (s *parallelUploadServer) GetCapabilities(context.Context, *emptypb.Empty) (*pb.Capability, error) {
+	return s.capability(), nil
+}
+
+func (s *parallelUploadServer) StreamCapabilities(_ *emptypb.Empty, stream grpc.ServerStreamingServer[pb.Capability]) error {
+	return stream.Send(s.capability())
+}
+
+func (s *parallelUploadServer) capability() *pb.Capability {
+	cap := s.testInferenceServer.capability()
+	cap.Extra = map[string]string{}
+	if s.extra != "" {
+		cap.Extra[ParallelUploadExtraKey] = s.extra
+	}
+	if s.features != "" {
+		cap.Extra[discovery.FeaturesExtraKey] = s.features
+	}
+	return cap
+}
+
+func (s *parallelUploadServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	rec := uploadStream{correlations: map[string]bool{}}
+	defer func() {
+		s.mu.Lock()
+		s.streams = append(s.streams, rec)
+		s.mu.Unlock()
+	}()
+
+	var own []byte
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		rec.part = req.Meta[UploadPartMetaKey]
+		rec.correlations[req.CorrelationId] = true
+		rec.seqs = append(rec.seqs, req.Seq)
+		rec.offsets = append(rec.offsets, req.Offset)
+		if rec.part == "" {
+			own = append(own, req.Payload...)
+			continue
+		}
+		if rec.part == s.failPart {
+			return status.Error(codes.Internal, "part lost")
+		}
+		s.mu.Lock()
+		s.chunks[req.Seq] = req.Payload
+		if uint64(len(s.chunks)) == req.Total {
+			close(s.complete)
+		}
+		s.mu.Unlock()
+	}
+
+	if rec.part == "" {
+		return stream.Send(&pb.InferResponse{IsFinal: true, Result: own})
+	}
+	if !strings.HasPrefix(rec.part, "1/") {
+		return nil
+	}
+	select {
+	case <-s.complete:
+	case <-stream.Context().Done():
+		s.mu.Lock()
+		s.aborted++
+		s.mu.Unlock()
+		return stream.Context().Err()
+	}
+	s.mu.Lock()
+	seqs := make([]uint64, 0, len(s.chunks))
+	for seq := range s.chunks {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	var payload []byte
+	for _, seq := range seqs {
+		payload = append(payload, s.chunks[seq]...)
+	}
+	s.mu.Unlock()
+	return stream.Send(&pb.InferResponse{IsFinal: true, Result: payload})
+}
+
+func (s *parallelUploadServer) recorded() []uploadStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := append([]uploadStream(nil), s.streams...)
+	sort.Slice(out, func(i, j int) bool { return out[i].part < out[j].part })
+	return out
+}
+
+// startParallelUploadClient returns a client splitting payloads into 4-byte
+// chunks over up to 3 streams, connected to srv once its capabilities are
+// known.
+func startParallelUploadClient(t *testing.T, srv *parallelUploadServer) *LumenClient {
+	t.Helper()
+	c := startClientFor(t, srv)
+	c.config.Chunk = config.ChunkConfig{EnableAuto: true, MaxChunkBytes: 4, ParallelStreams: 3}
+	waitUntil(t, func() bool { return len(c.pool.nodeCapabilities("local-node-1")) > 0 })
+	return c
+}
+
+func uploadRequest(payload []byte) *pb.InferRequest {
+	return &pb.InferRequest{
+		CorrelationId: "upload-1",
+		Task:          types.TaskSemanticTextEmbed,
+		Payload:       payload,
+		PayloadMime:   "text/plain",
+	}
+}
+
+func TestParallelUploadSplitsContiguousRanges(t *testing.T) {
+	srv := newParallelUploadServer("true", "")
+	c := startParallelUploadClient(t, srv)
+	payload := []byte("0123456789abcdefghijklmnopqrstuvwxyzABCD") // 10 chunks
+
+	resp, err := c.Infer(context.Background(), uploadRequest(payload))
+	if err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	if !bytes.Equal(resp.Result, payload) {
+		t.Fatalf("result = %q, want the payload reassembled", resp.Result)
+	}
+
+	waitUntil(t, func() bool { return len(srv.recorded()) == 3 })
+	wantSeqs := [][]uint64{{0, 1, 2}, {3, 4, 5}, {6, 7, 8, 9}}
+	for i, rec := range srv.recorded() {
+		if want := fmt.Sprintf("%d/3", i+1); rec.part != want {
+			t.Fatalf("stream %d part = %q, want %q", i, rec.part, want)
+		}
+		if len(rec.correlations) != 1 || !rec.correlations["upload-1"] {
+			t.Fatalf("part %s correlation IDs = %v, want upload-1", rec.part, rec.correlations)
+		}
+		if fmt.Sprint(rec.seqs) != fmt.Sprint(wantSeqs[i]) {
+			t.Fatalf("part %s seqs = %v, want %v", rec.part, rec.seqs, wantSeqs[i])
+		}
+		for j, seq := range rec.seqs {
+			if rec.offsets[j] != seq*4 {
+				t.Fatalf("part %s seq %d offset = %d, want %d", rec.part, seq, rec.offsets[j], seq*4)
+			}
+		}
+	}
+}
+
+func TestParallelUploadFailureFallsBackToOneStream(t *testing.T) {
+	srv := newParallelUploadServer("true", "2/3")
+	c := startParallelUploadClient(t, srv)
+	payload := []byte("0123456789abcdefghijklmnopqrstuvwxyzABCD")
+
+	resp, err := c.Infer(context.Background(), uploadRequest(payload))
+	if err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	if !bytes.Equal(resp.Result, payload) {
+		t.Fatalf("result = %q, want the payload from the single-stream retry", resp.Result)
+	}
+
+	// Part 1 waits for the payload that part 2 never completes; the failure
+	// must cancel it.
+	waitUntil(t, func() bool {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return srv.aborted == 1
+	})
+	waitUntil(t, func() bool { return len(srv.recorded()) == 4 })
+	single := srv.recorded()[0]
+	if single.part != "" || len(single.seqs) != 10 {
+		t.Fatalf("retry stream = %+v, want all 10 chunks without a part", single)
+	}
+}
+
+func TestParallelUploadNeedsNodeSupport(t *testing.T) {
+	for name, extra := range map[string]string{"not advertised": "", "disabled": "false", "one stream": "1"} {
+		t.Run(name, func(t *testing.T) {
+			srv := newParallelUploadServer(extra, "")
+			c := startParallelUploadClient(t, srv)
+			payload := []byte("0123456789abcdef")
+
+			if _, err := c.Infer(context.Background(), uploadRequest(payload)); err != nil {
+				t.Fatalf("Infer: %v", err)
+			}
+			waitUntil(t, func() bool { return len(srv.recorded()) == 1 })
+			if rec := srv.recorded()[0]; rec.part != "" || len(rec.seqs) != 4 {
+				t.Fatalf("stream = %+v, want one stream carrying all 4 chunks", rec)
+			}
+		})
+	}
+}
+
+func TestParallelUploadHonoursThreshold(t *testing.T) {
+	srv := newParallelUploadServer("2", "")
+	c := startParallelUploadClient(t, srv)
+	c.config.Chunk.ParallelThreshold = 64
+
+	if _, err := c.Infer(context.Background(), uploadRequest([]byte("0123456789abcdef"))); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	waitUntil(t, func() bool { return len(srv.recorded()) == 1 })
+
+	c.config.Chunk.ParallelThreshold = 0
+	if _, err := c.Infer(context.Background(), uploadRequest([]byte("0123456789abcdef"))); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	// The node caps the upload at 2 streams though 3 are configured.
+	waitUntil(t, func() bool { return len(srv.recorded()) == 3 })
+	if parts := srv.recorded(); parts[1].part != "1/2" || parts[2].part != "2/2" {
+		t.Fatalf("parts = %q, %q; want 1/2 and 2/2", parts[1].part, parts[2].part)
+	}
+}
+
+// TestClientEmitsOnlyAdvertisedFeatures runs the same upload against nodes
+// advertising different feature sets: the upload_part Meta of a parallel
+// upload goes only to nodes listing parallel_upload.
+func TestClientEmitsOnlyAdvertisedFeatures(t *testing.T) {
+	cases := []struct {
+		name, extra, features string
+		parallel              bool
+	}{
+		{name: "nothing advertised"},
+		{name: "other features", features: "progress,zstd"},
+		{name: "features list", features: "checksum, PARALLEL_UPLOAD", parallel: true},
+		{name: "legacy extra key", extra: "true", parallel: true},
+		{name: "legacy key disabled", extra: "false", features: "progress"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newParallelUploadServer(tc.extra, "")
+			srv.features = tc.features
+			c := startParallelUploadClient(t, srv)
+
+			nodes := c.GetNodes()
+			if len(nodes) != 1 || nodes[0].SupportsFeature(discovery.FeatureParallelUpload) != tc.parallel {
+				t.Fatalf("node features = %v, want parallel_upload supported: %v", nodes[0].Features, tc.parallel)
+			}
+
+			payload := []byte("0123456789abcdefghijklmnopqrstuvwxyzABCD")
+			resp, err := c.Infer(context.Background(), uploadRequest(payload))
+			if err != nil {
+				t.Fatalf("Infer: %v", err)
+			}
+			if !bytes.Equal(resp.Result, payload) {
+				t.Fatalf("result = %q, want the payload", resp.Result)
+			}
+			want := 1
+			if tc.parallel {
+				want = 3
+			}
+			waitUntil(t, func() bool { return len(srv.recorded()) == want })
+			for _, rec := range srv.recorded() {
+				if (rec.part != "") != tc.parallel {
+					t.Fatalf("stream part = %q with parallel upload advertised: %v", rec.part, tc.parallel)
+				}
+			}
+		})
+	}
+}
@@ -3,17 +3,20 @@ package client
 import (
 	"fmt"
 	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
 	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
 	pb "github.com/edwinzhancn/lumen-sdk/proto"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/encoding/gzip" // registers the gzip compressor for PoolOptions.Compression
 	"google.golang.org/grpc/keepalive"
 )
 
@@ -27,8 +30,87 @@ type PoolOptions struct {
 	ConnectTimeout        time.Duration
 	RediscoveryBackoffMin time.Duration
 	RediscoveryBackoffMax time.Duration
+	// CapabilityFetchTimeout bounds a single capability fetch against a node.
+	CapabilityFetchTimeout time.Duration
+	// MaxConcurrentProbes caps how many capability fetches run at once.
+	MaxConcurrentProbes int
+	// RequireConfirmedCapabilities keeps a node out of selection until its
+	// capabilities are fetched; by default it is routed on its TXT task
+	// hints meanwhile, reported as provisional.
+	RequireConfirmedCapabilities bool
+	// LatencyWindow is the sliding window for per-node latency percentiles;
+	// zero keeps them cumulative.
+	LatencyWindow time.Duration
+	// MaxConnections caps how many nodes get a connection; zero means no
+	// limit.
+	MaxConnections int
+	// MaxIdleTime lets the ClientConn go idle, closing every node
+	// connection, after this long without RPCs. Zero disables idleness.
+	MaxIdleTime time.Duration
+	// MaxLifetime recycles a node connection once it has been Ready this
+	// long. Zero disables recycling.
+	MaxLifetime time.Duration
+	// HealthCheckInterval is how often every Ready node is sent a Health
+	// RPC. Zero disables health checks.
+	HealthCheckInterval time.Duration
+	// StreamWarnThreshold logs a warning when a node's open streams exceed
+	// it. Zero disables the warning.
+	StreamWarnThreshold int
+	// StreamMaxAge logs a warning for every stream open longer than this,
+	// a likely leak. Zero disables the warning.
+	StreamMaxAge time.Duration
+	// KeepAlive is how often an idle node connection is pinged so NAT
+	// gateways and firewalls keep it open; pings are sent even with no RPC
+	// in flight. Zero means 5m, negative disables pings.
+	KeepAlive time.Duration
+	// KeepAliveTimeout is how long to wait for a ping ack before the
+	// connection is closed. Zero means 20s.
+	KeepAliveTimeout time.Duration
+	// MaxMessageSize is the largest message accepted from a node, on the
+	// pool connection and on the side connections that fetch capabilities
+	// and probe health. Zero keeps gRPC's 4 MiB default.
+	MaxMessageSize int
+	// Compression names the compressor for messages sent to nodes, as in
+	// config.PoolConfig; empty or config.CompressionNone sends them
+	// uncompressed.
+	Compression string
+	// NotifyWindow is how long OnNodesChanged callbacks coalesce node-list
+	// changes after a delivery. Zero means 200ms, negative delivers every
+	// change.
+	NotifyWindow time.Duration
+	// Strategy, ScoreWeights and RandomTieBreak choose among eligible nodes
+	// as in config.PoolConfig; an empty Strategy means round-robin.
+	Strategy       string
+	ScoreWeights   map[string]float64
+	RandomTieBreak bool
+	// TLS and PerNode secure node connections as in config.PoolConfig; the
+	// zero values dial every node in plaintext, except those advertising
+	// tls=required.
+	TLS     config.TransportConfig
+	PerNode map[string]config.TransportConfig
+	// Outlier ejects nodes whose error rate or latency stands out from the
+	// rest, as in config.PoolConfig; off unless Outlier.Enabled is set.
+	Outlier config.OutlierConfig
+	// Hysteresis damps health-check status changes as in config.PoolConfig;
+	// zero fields select the defaults.
+	Hysteresis config.HysteresisConfig
+	// MaxCatalogAge and FailOnStaleCatalog bound how long discovered nodes
+	// may go unconfirmed, as in config.DiscoveryConfig; zero MaxCatalogAge
+	// never marks the catalog stale.
+	MaxCatalogAge      time.Duration
+	FailOnStaleCatalog bool
+	// CallbackPanicLimit unregisters a callback registered for pool events
+	// once it has panicked this many times. Zero never unregisters one; a
+	// panic then drops only the call that panicked.
+	CallbackPanicLimit int
 }
 
+const (
+	defaultKeepAlive        = 5 * time.Minute
+	defaultKeepAliveTimeout = 20 * time.Second
+	defaultNotifyWindow     = 200 * time.Millisecond
+)
+
 func (o PoolOptions) normalized() PoolOptions {
 	if o.ConnectTimeout <= 0 {
 		o.ConnectTimeout = 10 * time.Second
@@ -39,21 +121,92 @@ func (o PoolOptions) normalized() PoolOptions {
 	if o.RediscoveryBackoffMax < o.RediscoveryBackoffMin {
 		o.RediscoveryBackoffMax = 2 * time.Minute
 	}
+	if o.CapabilityFetchTimeout <= 0 {
+		o.CapabilityFetchTimeout = defaultCapFetchTimeout
+	}
+	if o.MaxConcurrentProbes <= 0 {
+		o.MaxConcurrentProbes = 4
+	}
+	if o.KeepAlive == 0 {
+		o.KeepAlive = defaultKeepAlive
+	}
+	if o.KeepAliveTimeout <= 0 {
+		o.KeepAliveTimeout = defaultKeepAliveTimeout
+	}
+	if o.NotifyWindow == 0 {
+		o.NotifyWindow = defaultNotifyWindow
+	}
 	return o
 }
 
+// keepaliveParams translates KeepAlive into gRPC client parameters. gRPC
+// raises intervals below 10s to 10s. ok is false when pings are disabled.
+func (o PoolOptions) keepaliveParams() (params keepalive.ClientParameters, ok bool) {
+	if o.KeepAlive <= 0 {
+		return keepalive.ClientParameters{}, false
+	}
+	return keepalive.ClientParameters{
+		Time:                o.KeepAlive,
+		Timeout:             o.KeepAliveTimeout,
+		PermitWithoutStream: true,
+	}, true
+}
+
+// dialOptions are the options shared by the pool connection and the
+// balancer's per-node probe connections, so a capability fetch is held to
+// the same keepalive, message size and compression as inference; callers
+// add the credentials.
+func (o PoolOptions) dialOptions() []grpc.DialOption {
+	var opts []grpc.DialOption
+	if params, ok := o.keepaliveParams(); ok {
+		opts = append(opts, grpc.WithKeepaliveParams(params))
+	}
+	var callOpts []grpc.CallOption
+	if o.MaxMessageSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(o.MaxMessageSize))
+	}
+	if o.Compression != "" && o.Compression != config.CompressionNone {
+		callOpts = append(callOpts, grpc.UseCompressor(o.Compression))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	return opts
+}
+
 // Pool manages a single gRPC ClientConn with a custom resolver and task-aware
 // balancer. Discovery events are fed through the resolver; the balancer creates
 // one SubConn per node and routes RPCs based on the task set in the context.
 type Pool struct {
-	mu       sync.RWMutex
-	conn     *grpc.ClientConn
-	cli      pb.InferenceClient
-	registry *nodeRegistry
-	watchers []func([]*discovery.NodeInfo)
+	mu          sync.RWMutex
+	conn        *grpc.ClientConn
+	cli         pb.InferenceClient
+	registry    *nodeRegistry
+	watchers    []*nodeWatcher
+	watchStop   chan struct{} // closed by Close to stop the watchers
+	capWatch    watchList[discovery.CapabilityDiff]
+	addrWatch   watchList[discovery.NodeAddressChanged]
+	statusWatch watchList[discovery.NodeStatusChanged]
+	selWatch    watchList[discovery.SelectionDecision]
+	drainWatch  watchList[discovery.NodeDrained]
+	ejectWatch  watchList[discovery.NodeEjection]
+
+	resolver       discovery.NodeResolver
+	discoveryErr   chan error // receives the error if resolver fails to start
+	discoveryStats discoveryCounters
+	// catalog follows how recently discovery vouched for each node.
+	catalog      *discovery.CatalogTracker
+	catalogWatch watchList[discovery.CatalogFreshness]
 
 	logger  *zap.Logger
 	options PoolOptions
+	// callbacks recovers and counts panics in user callbacks.
+	callbacks *callbackGuard
+	// scoring holds the scorer registered with SetScorer; the balancer
+	// uses it only under the custom strategy.
+	scoring *scoring
+	// clock is the clock set with SetClock; nil is the wall clock.
+	clock utils.Clock
 }
 
 // NewPool creates an empty connection pool.
@@ -67,18 +220,63 @@ func NewPoolWithOptions(logger *zap.Logger, options PoolOptions) *Pool {
 		logger = zap.NewNop()
 	}
 	return &Pool{
-		logger:  logger,
-		options: options.normalized(),
+		logger:    logger,
+		options:   options.normalized(),
+		callbacks: newCallbackGuard(logger, options.CallbackPanicLimit),
+		scoring:   newScoring(options.ScoreWeights, options.RandomTieBreak),
+		catalog:   newCatalogTracker(options, nil),
 	}
 }
 
+// SetScorer registers scorer for the custom selection strategy, replacing
+// any earlier one; nil removes it. It takes effect on the next pick.
+func (p *Pool) SetScorer(scorer NodeScorer) {
+	p.scoring.setScorer(scorer)
+}
+
+// SetClock sets the clock the pool's timing runs on; nil is the wall
+// clock. That covers health checks and their retries, capability-probe
+// backoff, cooldowns, connection recycling, drains, ejections, node
+// statuses and the OnNodesChanged window. Call it before Connect.
+func (p *Pool) SetClock(clock utils.Clock) {
+	p.clock = clock
+}
+
 // Connect creates the gRPC ClientConn using the given resolver backend.
 func (p *Pool) Connect(resolver discovery.NodeResolver) error {
+	creds, err := newNodeCredentials(config.PoolConfig{TLS: p.options.TLS, PerNode: p.options.PerNode})
+	if err != nil {
+		return utils.Wrap(err, utils.ErrCodeInvalid, "load node transport credentials")
+	}
+
 	registry := &nodeRegistry{
-		nodes: make(map[string]*registeredNode),
-		onChanged: func() {
+		nodes:              make(map[string]*registeredNode),
+		onStatusChange:     p.notifyStatusWatchers,
+		logger:             p.logger,
+		onCapabilityChange: p.notifyCapabilityWatchers,
+		onAddressChange:    p.notifyAddressWatchers,
+		onSelection:        p.notifySelectionWatchers,
+		onDrained:          p.notifyDrainWatchers,
+		latency:            newLatencySet(p.options.LatencyWindow),
+		failures:           &failureSet{},
+		onEjection:         p.notifyEjectionWatchers,
+		health:             newStatusDamper(p.options.Hysteresis),
+		onHealthy:          p.catalog.Vouch,
+		recent:             newRecentErrors(),
+		clock:              p.clock,
+	}
+	if p.clock != nil {
+		registry.latency.clock = p.clock.Now
+	}
+	registry.onChanged = p.notifyWatchers
+	if p.options.Outlier.Enabled {
+		registry.outliers = newOutlierDetector(p.options.Outlier)
+		registry.outliers.clock = p.clock
+		registry.outliers.onReadmit = func(ev discovery.NodeEjection) {
+			p.notifyEjectionWatchers(ev)
+			registry.syncStatuses()
 			p.notifyWatchers()
-		},
+		}
 	}
 
 	opts := p.options
@@ -86,33 +284,53 @@ func (p *Pool) Connect(resolver discovery.NodeResolver) error {
 		connectTimeout:        opts.ConnectTimeout,
 		rediscoveryBackoffMin: opts.RediscoveryBackoffMin,
 		rediscoveryBackoffMax: opts.RediscoveryBackoffMax,
+		capFetchTimeout:       opts.CapabilityFetchTimeout,
+		maxConcurrentProbes:   opts.MaxConcurrentProbes,
+		requireConfirmed:      opts.RequireConfirmedCapabilities,
+		maxConnections:        opts.MaxConnections,
+		maxLifetime:           opts.MaxLifetime,
+		healthInterval:        opts.HealthCheckInterval,
+		streamWarnThreshold:   opts.StreamWarnThreshold,
+		streamMaxAge:          opts.StreamMaxAge,
+		strategies:            newStrategies(opts.Strategy, p.scoring),
+		dialOptions:           opts.dialOptions(),
+		transport:             creds,
 	}, p.logger)
 
+	discoveryErr := make(chan error, 1)
 	rb := &lumenResolverBuilder{
 		nodeResolver: resolver,
+		stats:        &p.discoveryStats,
+		catalog:      p.catalog,
+		onWatchError: func(err error) { discoveryErr <- err },
 		logger:       p.logger,
 	}
 
 	svcCfg := fmt.Sprintf(`{"loadBalancingConfig": [{"%s": {}}]}`, balancerName)
 
-	conn, err := grpc.NewClient(
-		lumenScheme+":///cluster",
+	dialOpts := append(opts.dialOptions(),
+		grpc.WithTransportCredentials(creds),
 		grpc.WithResolvers(rb),
 		grpc.WithDefaultServiceConfig(svcCfg),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithKeepaliveParams(keepalive.ClientParameters{
-			Time:    10 * time.Second,
-			Timeout: 3 * time.Second,
-		}),
+		grpc.WithIdleTimeout(opts.MaxIdleTime),
 	)
+	conn, err := grpc.NewClient(lumenScheme+":///cluster", dialOpts...)
 	if err != nil {
-		return fmt.Errorf("create gRPC client: %w", err)
+		return utils.Wrap(err, utils.ErrCodeInvalid, "create gRPC client")
 	}
 
 	p.mu.Lock()
 	p.conn = conn
 	p.cli = pb.NewInferenceClient(conn)
 	p.registry = registry
+	p.resolver = resolver
+	p.discoveryErr = discoveryErr
+	if p.options.MaxCatalogAge > 0 {
+		if p.watchStop == nil {
+			p.watchStop = make(chan struct{})
+		}
+		go p.monitorCatalog(p.watchStop)
+	}
 	p.mu.Unlock()
 
 	// grpc.NewClient is lazy — force eager resolver/balancer startup so
@@ -133,6 +351,41 @@ func (p *Pool) Client() pb.InferenceClient {
 type PoolStats struct {
 	TotalConnections   int `json:"total_connections"`
 	HealthyConnections int `json:"healthy_connections"`
+	// InFlightProbes is the number of capability fetches currently running.
+	InFlightProbes int `json:"in_flight_probes"`
+	// StateTransitions counts node connection state changes, keyed
+	// "FROM->TO" (e.g. "READY->IDLE"), since the pool connected.
+	StateTransitions map[string]int64 `json:"state_transitions,omitempty"`
+	// ProbeFailures maps node IDs to their consecutive capability-fetch
+	// failures. Nodes whose last fetch succeeded are omitted.
+	ProbeFailures map[string]int `json:"probe_failures,omitempty"`
+	// Quarantined is the number of nodes whose capability fetch failed
+	// often enough that they are only probed on a slow backoff.
+	Quarantined int `json:"quarantined"`
+	// Provisional is the number of connected nodes known only by their TXT
+	// capability hints, whose capabilities have not been fetched yet.
+	Provisional int `json:"provisional"`
+	// LastErrors maps node IDs to their most recent connection failure.
+	// Nodes that never failed to connect are omitted.
+	LastErrors map[string]discovery.NodeError `json:"last_errors,omitempty"`
+	// OpenStreams is the number of RPC streams open across all nodes, and
+	// Streams the open, peak and total counts of each node that has had
+	// one.
+	OpenStreams int64                  `json:"open_streams"`
+	Streams     map[string]StreamStats `json:"streams,omitempty"`
+	// Ejected is the number of nodes outlier detection has taken out of
+	// selection.
+	Ejected int `json:"ejected"`
+	// Selections counts the requests routed under each selection strategy,
+	// keyed like pool.strategy, since the pool connected; requests choosing
+	// a strategy with WithStrategy count under theirs.
+	Selections map[string]int64 `json:"selections,omitempty"`
+	// CallbackPanics counts the user callback calls that panicked, and
+	// CallbacksUnregistered the callbacks unregistered for panicking too
+	// often, keyed by kind (CallbackSelection etc.), since the pool was
+	// created.
+	CallbackPanics        map[string]int64 `json:"callback_panics,omitempty"`
+	CallbacksUnregistered map[string]int64 `json:"callbacks_unregistered,omitempty"`
 }
 
 // Stats returns current pool statistics.
@@ -140,17 +393,93 @@ func (p *Pool) Stats() PoolStats {
 	p.mu.RLock()
 	reg := p.registry
 	p.mu.RUnlock()
+	panics, unregistered := p.callbacks.counts()
 	if reg == nil {
-		return PoolStats{}
+		return PoolStats{CallbackPanics: panics, CallbacksUnregistered: unregistered}
 	}
 	total, healthy := reg.stats()
-	return PoolStats{
-		TotalConnections:   total,
-		HealthyConnections: healthy,
+	stats := PoolStats{
+		TotalConnections:      total,
+		HealthyConnections:    healthy,
+		InFlightProbes:        int(reg.inFlightProbes.Load()),
+		StateTransitions:      reg.stateTransitions(),
+		ProbeFailures:         reg.probeFailures(),
+		Quarantined:           reg.quarantined(),
+		Provisional:           reg.provisional(),
+		LastErrors:            reg.lastErrors(),
+		Streams:               reg.streamStats(),
+		Ejected:               reg.outliers.ejectedCount(reg.now()),
+		Selections:            reg.selectionCounts(),
+		CallbackPanics:        panics,
+		CallbacksUnregistered: unregistered,
 	}
+	for _, s := range stats.Streams {
+		stats.OpenStreams += s.Open
+	}
+	return stats
 }
 
-// NodeInfos returns snapshot descriptors for all connections.
+// DiscoveryStats returns counters for the discovery events consumed so far.
+func (p *Pool) DiscoveryStats() DiscoveryStats {
+	s := p.discoveryStats.snapshot()
+	st := p.DiscoveryStatus()
+	s.Degraded = st.Degraded
+	s.FailedBackends = st.Failed
+	s.Catalog = *st.Catalog
+	return s
+}
+
+// DiscoveryStatus reports the discovery backends that failed to start and
+// are being retried while the others run, and the catalog's freshness. It
+// is never degraded for a single backend, which fails Connect's resolver
+// outright instead.
+func (p *Pool) DiscoveryStatus() discovery.DiscoveryStatus {
+	p.mu.RLock()
+	r := p.resolver
+	p.mu.RUnlock()
+	var st discovery.DiscoveryStatus
+	if sr, ok := r.(discovery.StatusReporter); ok {
+		st = sr.Status()
+	}
+	catalog := p.catalog.Freshness()
+	st.Catalog = &catalog
+	return st
+}
+
+// discoveryFailed receives the error if the resolver passed to Connect
+// failed to start. It is nil before Connect.
+func (p *Pool) discoveryFailed() <-chan error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.discoveryErr
+}
+
+// NodeLatency returns per-node latency percentiles of successful RPCs,
+// keyed by node ID.
+func (p *Pool) NodeLatency() map[string]LatencyStats {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return nil
+	}
+	return reg.latency.snapshot()
+}
+
+// NodeFailures returns per-node counts of failed RPCs by FailureClass,
+// keyed by node ID.
+func (p *Pool) NodeFailures() map[string]FailureCounts {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return nil
+	}
+	return reg.failures.snapshot()
+}
+
+// NodeInfos returns snapshot descriptors for all connections; see
+// LumenClient.GetNodes.
 func (p *Pool) NodeInfos() []*discovery.NodeInfo {
 	p.mu.RLock()
 	reg := p.registry
@@ -161,42 +490,398 @@ func (p *Pool) NodeInfos() []*discovery.NodeInfo {
 	return reg.nodeInfos()
 }
 
+// uniqueService returns the service a node serves task under when it has
+// exactly one for it, or "" when no node does or the pool is not connected.
+func (p *Pool) uniqueService(task string) string {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return ""
+	}
+	return reg.uniqueService(task)
+}
+
+// servesTask reports whether an active node, or a provisional one allowed
+// traffic, supports task.
+func (p *Pool) servesTask(task string) bool {
+	provisional := !p.options.RequireConfirmedCapabilities
+	for _, node := range p.NodeInfos() {
+		if (node.IsActive() || provisional && node.IsProvisional()) && node.SupportsTask(task) {
+			return true
+		}
+	}
+	return false
+}
+
+// servedTasks returns the tasks some known node advertises.
+func (p *Pool) servedTasks() map[string]bool {
+	served := make(map[string]bool)
+	for _, node := range p.NodeInfos() {
+		for _, task := range node.Tasks {
+			served[task.GetName()] = true
+		}
+		for _, capability := range node.Capabilities {
+			for _, task := range capability.GetTasks() {
+				served[task.GetName()] = true
+			}
+		}
+	}
+	return served
+}
+
+// selectionTasks returns the tasks holding routing state: a round-robin
+// cursor or a last routing decision.
+func (p *Pool) selectionTasks() []string {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return nil
+	}
+	return reg.selectionTasks()
+}
+
+// forgetTask drops task's routing state.
+func (p *Pool) forgetTask(task string) {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg != nil {
+		reg.forgetTask(task)
+	}
+}
+
+// empty reports whether the pool is connected but knows of no node. RPCs
+// then wait for discovery instead of failing.
+func (p *Pool) empty() bool {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return false
+	}
+	total, _ := reg.stats()
+	return total == 0
+}
+
+// nodeCapabilities returns the capabilities last fetched from the node with
+// key, or nil when it is unknown.
+func (p *Pool) nodeCapabilities(key string) []*pb.Capability {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return nil
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	if rn := reg.nodes[key]; rn != nil {
+		return rn.capabilities
+	}
+	return nil
+}
+
+// nodeSupportsFeature reports whether the node with key advertised the wire
+// feature name; false when it is unknown or its capabilities have not been
+// fetched.
+func (p *Pool) nodeSupportsFeature(key, name string) bool {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return false
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	rn := reg.nodes[key]
+	return rn != nil && slices.Contains(rn.features, name)
+}
+
+// ExplainSelection reports how the next request for task would be routed
+// without dispatching one. It returns an error when the pool has not
+// connected yet.
+func (p *Pool) ExplainSelection(task string) (*discovery.SelectionExplanation, error) {
+	return p.explainStrategy(task, "")
+}
+
+// explainStrategy is ExplainSelection for a request routed through
+// strategy; "" is the configured one.
+func (p *Pool) explainStrategy(task, strategy string) (*discovery.SelectionExplanation, error) {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return nil, fmt.Errorf("pool is not connected")
+	}
+	return reg.explainStrategy(task, strategy, reg.now()), nil
+}
+
+// RecentErrors returns up to the last 50 failures of the node with nodeID,
+// oldest first: lost or failed connections, failed health checks and
+// capability fetches, and failed requests. It returns nil for a node
+// without any, or before the pool connects.
+func (p *Pool) RecentErrors(nodeID string) []discovery.RecentError {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg == nil {
+		return nil
+	}
+	return reg.recent.get(nodeID, recentErrorsLen)
+}
+
+// nodeWatcher delivers node-list changes to one OnNodesChanged callback
+// from its own goroutine, so the callback never runs concurrently with
+// itself.
+type nodeWatcher struct {
+	cb func([]*discovery.NodeInfo)
+	// panics counts the calls that panicked; only the watcher's goroutine
+	// touches it.
+	panics int
+	// pending holds a token while a change awaits delivery; further
+	// changes before the delivery fold into it.
+	pending chan struct{}
+	// done is closed when the callback is unregistered.
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
 // OnNodesChanged registers a callback invoked whenever the node list changes.
-func (p *Pool) OnNodesChanged(cb func([]*discovery.NodeInfo)) {
+// The first change after a quiet period is delivered at once; later changes
+// within PoolOptions.NotifyWindow are coalesced into one call with the list
+// as it stands when the call is made, so a callback never sees an older list
+// after a newer one. Calls to one callback never overlap, and a slow
+// callback delays only itself.
+//
+// The returned func unregisters the callback; it may be called from the
+// callback itself. A call already under way finishes, but none starts after
+// it returns. Close unregisters every callback.
+func (p *Pool) OnNodesChanged(cb func([]*discovery.NodeInfo)) (unsubscribe func()) {
+	w := &nodeWatcher{cb: cb, pending: make(chan struct{}, 1), done: make(chan struct{})}
+	p.mu.Lock()
+	if p.watchStop == nil {
+		p.watchStop = make(chan struct{})
+	}
+	stop := p.watchStop
+	p.watchers = append(p.watchers, w)
+	p.mu.Unlock()
+	go p.runNodeWatcher(w, stop)
+	return func() { p.removeNodeWatcher(w) }
+}
+
+func (p *Pool) removeNodeWatcher(w *nodeWatcher) {
+	w.doneOnce.Do(func() { close(w.done) })
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.watchers = append(p.watchers, cb)
+	p.watchers = slices.DeleteFunc(p.watchers, func(x *nodeWatcher) bool { return x == w })
 }
 
 func (p *Pool) notifyWatchers() {
 	p.mu.RLock()
-	if len(p.watchers) == 0 {
+	defer p.mu.RUnlock()
+	for _, w := range p.watchers {
+		select {
+		case w.pending <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *Pool) runNodeWatcher(w *nodeWatcher, stop <-chan struct{}) {
+	window := p.options.NotifyWindow
+	for {
+		select {
+		case <-w.pending:
+		case <-stop:
+			return
+		case <-w.done:
+			return
+		}
+		// Snapshot at delivery time rather than when the change was
+		// signalled: the list is the newest one, and each delivery is at
+		// least as new as the one before.
+		p.mu.RLock()
+		reg := p.registry
 		p.mu.RUnlock()
-		return
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		if reg != nil && p.callbacks.run(CallbackNodesChanged, func() { w.cb(reg.nodeInfos()) }) {
+			w.panics++
+			if p.callbacks.exhausted(CallbackNodesChanged, w.panics) {
+				p.removeNodeWatcher(w)
+				return
+			}
+		}
+		if window <= 0 {
+			continue
+		}
+		timer := utils.ClockOrReal(p.clock).NewTimer(window)
+		select {
+		case <-timer.C():
+		case <-stop:
+			timer.Stop()
+			return
+		case <-w.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// OnCapabilityChange registers a callback invoked with the diff whenever a
+// re-fetch finds that a node's capabilities changed. The returned func
+// unregisters it, like OnNodesChanged's.
+func (p *Pool) OnCapabilityChange(cb func(discovery.CapabilityDiff)) (unsubscribe func()) {
+	return p.capWatch.add(cb)
+}
+
+func (p *Pool) notifyCapabilityWatchers(diff discovery.CapabilityDiff) {
+	for _, w := range p.capWatch.snapshot() {
+		go w.deliver(p.callbacks, CallbackCapabilityChange, diff)
 	}
+}
+
+// OnAddressChange registers a callback invoked whenever a known node moves
+// to a new address. The returned func unregisters it.
+func (p *Pool) OnAddressChange(cb func(discovery.NodeAddressChanged)) (unsubscribe func()) {
+	return p.addrWatch.add(cb)
+}
+
+func (p *Pool) notifyAddressWatchers(change discovery.NodeAddressChanged) {
+	for _, w := range p.addrWatch.snapshot() {
+		go w.deliver(p.callbacks, CallbackAddressChange, change)
+	}
+}
+
+// OnStatusChange registers a callback invoked whenever a node's status
+// changes, with the reason for the change. Each callback gets the changes
+// in the order they were recorded, one call at a time. The returned func
+// unregisters it.
+func (p *Pool) OnStatusChange(cb func(discovery.NodeStatusChanged)) (unsubscribe func()) {
+	return p.statusWatch.add(cb)
+}
+
+func (p *Pool) notifyStatusWatchers(change discovery.NodeStatusChanged) {
+	for _, w := range p.statusWatch.snapshot() {
+		w.enqueue(p.callbacks, CallbackStatusChange, change)
+	}
+}
+
+// OnSelection registers a callback invoked with every routing decision.
+// Callbacks run synchronously on the RPC path, in order, so they see
+// decisions in the order they were made; they must not block. The returned
+// func unregisters it.
+func (p *Pool) OnSelection(cb func(discovery.SelectionDecision)) (unsubscribe func()) {
+	return p.selWatch.add(cb)
+}
+
+func (p *Pool) notifySelectionWatchers(d discovery.SelectionDecision) {
+	for _, w := range p.selWatch.snapshot() {
+		w.deliver(p.callbacks, CallbackSelection, d)
+	}
+}
+
+// DrainNode stops routing new RPCs to the node with nodeID while those in
+// flight finish; a positive timeout undrains it once it elapses. It returns
+// a NODE_NOT_FOUND error for a node the pool does not know.
+func (p *Pool) DrainNode(nodeID string, timeout time.Duration) error {
+	p.mu.RLock()
 	reg := p.registry
-	watchers := make([]func([]*discovery.NodeInfo), len(p.watchers))
-	copy(watchers, p.watchers)
 	p.mu.RUnlock()
+	if reg == nil {
+		return fmt.Errorf("pool is not connected")
+	}
+	if err := reg.drain(nodeID, timeout); err != nil {
+		return err
+	}
+	reg.syncStatuses()
+	return nil
+}
 
+// UndrainNode returns a drained node to selection.
+func (p *Pool) UndrainNode(nodeID string) error {
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
 	if reg == nil {
-		return
+		return fmt.Errorf("pool is not connected")
 	}
-	nodes := reg.nodeInfos()
-	for _, w := range watchers {
-		go w(nodes)
+	reg.undrain(nodeID)
+	reg.syncStatuses()
+	return nil
+}
+
+// OnNodeDrained registers a callback invoked when a drained node's last
+// in-flight RPC finishes, or at once when it had none. The returned func
+// unregisters it.
+func (p *Pool) OnNodeDrained(cb func(discovery.NodeDrained)) (unsubscribe func()) {
+	return p.drainWatch.add(cb)
+}
+
+func (p *Pool) notifyDrainWatchers(ev discovery.NodeDrained) {
+	for _, w := range p.drainWatch.snapshot() {
+		go w.deliver(p.callbacks, CallbackNodeDrained, ev)
 	}
 }
 
-// Close closes the gRPC connection and clears the pool.
+// OnNodeEjection registers a callback invoked when outlier detection ejects
+// a node (Ejected true) and when the node's re-admission starts (Ejected
+// false). The returned func unregisters it.
+func (p *Pool) OnNodeEjection(cb func(discovery.NodeEjection)) (unsubscribe func()) {
+	return p.ejectWatch.add(cb)
+}
+
+func (p *Pool) notifyEjectionWatchers(ev discovery.NodeEjection) {
+	for _, w := range p.ejectWatch.snapshot() {
+		go w.deliver(p.callbacks, CallbackNodeEjection, ev)
+	}
+}
+
+// Close closes the gRPC connection and clears the pool, unregistering every
+// watcher. Status watchers are told each node goes to unknown for
+// connection_closed; then the watchers stop, then the connection, whose close stops
+// discovery before the balancer and its subconnections. Closing a closed
+// pool is safe. Callbacks registered after Close, e.g. before
+// connecting again, work as usual.
 func (p *Pool) Close() error {
+	// Status watchers are told every node goes before they are
+	// unregistered; clearing them below lets queued changes through.
+	p.mu.RLock()
+	reg := p.registry
+	p.mu.RUnlock()
+	if reg != nil {
+		reg.closeNodes()
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.watchStop != nil {
+		close(p.watchStop)
+		p.watchStop = nil
+	}
+	for _, w := range p.watchers {
+		w.doneOnce.Do(func() { close(w.done) })
+	}
+	p.watchers = nil
+	p.capWatch.clear()
+	p.addrWatch.clear()
+	p.statusWatch.clear()
+	p.selWatch.clear()
+	p.drainWatch.clear()
+	p.ejectWatch.clear()
+	p.catalogWatch.clear()
+	p.logger.Debug("pool watchers stopped")
+
 	if p.conn != nil {
 		if err := p.conn.Close(); err != nil {
 			p.logger.Error("failed to close connection", zap.Error(err))
 		}
+		p.registry.outliers.stop()
 		p.conn = nil
 		p.cli = nil
 		p.registry = nil
@@ -270,14 +955,6 @@ func tasksFromCapabilities(caps []*pb.Capability) []string {
 	return mergeTasks(nil, tasks)
 }
 
-func tasksToIOTasks(names []string) []*pb.IOTask {
-	out := make([]*pb.IOTask, 0, len(names))
-	for _, n := range names {
-		out = append(out, &pb.IOTask{Name: n})
-	}
-	return out
-}
-
 func tasksToIOTasksFromCapabilities(caps []*pb.Capability, fallbackNames []string) []*pb.IOTask {
 	seen := make(map[string]struct{})
 	var out []*pb.IOTask
@@ -293,11 +970,11 @@ func tasksToIOTasksFromCapabilities(caps []*pb.Capability, fallbackNames []strin
 			out = append(out, task)
 		}
 	}
-	for _, task := range tasksToIOTasks(fallbackNames) {
-		if _, ok := seen[task.GetName()]; ok {
+	for _, name := range fallbackNames {
+		if _, ok := seen[name]; ok {
 			continue
 		}
-		out = append(out, task)
+		out = append(out, &pb.IOTask{Name: name})
 	}
 	return out
 }
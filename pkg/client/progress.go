@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"math"
+	"strconv"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// ProgressMetaKey is the response Meta key nodes report progress under on
+// intermediate frames: the percentage of the work done, from 0 to 100
+// (e.g. "42" or "42.5").
+const ProgressMetaKey = "lumen.progress"
+
+// ProgressHandler receives the progress of a request as a percentage from 0
+// to 100, with the Meta of the frame reporting it. meta is shared with the
+// frame and must not be modified.
+type ProgressHandler func(pct float64, meta map[string]string)
+
+type progressKey struct{}
+
+// WithProgressHandler has Infer calls made with ctx pass each progress
+// frame the node sends before its final one to h. h runs on the goroutine
+// receiving the response, so it should return quickly; a panic in h drops
+// that one report and is counted in PoolStats.CallbackPanics. InferStream
+// callers see progress frames on the channel instead; read them with
+// Progress.
+func WithProgressHandler(ctx context.Context, h ProgressHandler) context.Context {
+	return context.WithValue(ctx, progressKey{}, h)
+}
+
+// ProgressHandlerFromContext returns the handler set with
+// WithProgressHandler, or nil.
+func ProgressHandlerFromContext(ctx context.Context) ProgressHandler {
+	h, _ := ctx.Value(progressKey{}).(ProgressHandler)
+	return h
+}
+
+// Progress returns the progress resp reports under ProgressMetaKey, clamped
+// to 0–100, and whether it reports a valid one.
+func Progress(resp *pb.InferResponse) (float64, bool) {
+	v, ok := resp.GetMeta()[ProgressMetaKey]
+	if !ok {
+		return 0, false
+	}
+	pct, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(pct) {
+		return 0, false
+	}
+	return math.Min(math.Max(pct, 0), 100), true
+}
+
+// reportProgress passes the progress of an intermediate frame to ctx's
+// handler, through g so a panicking handler loses only this report. It
+// reports whether the frame carries nothing but progress, in which case it
+// is left out of response assembly.
+func reportProgress(ctx context.Context, g *callbackGuard, resp *pb.InferResponse) (progressOnly bool) {
+	pct, ok := Progress(resp)
+	if !ok {
+		return false
+	}
+	if h := ProgressHandlerFromContext(ctx); h != nil {
+		g.run(CallbackProgress, func() { h(pct, resp.Meta) })
+	}
+	return len(resp.Result) == 0 && resp.Total <= 1 && resp.Error == nil
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+)
+
+// progressServer reports progress while answering: 25%, the first half of
+// the echoed payload as response chunk 0 of 2, 75%, then the second half as
+// the final chunk.
+type progressServer struct {
+	testInferenceServer
+}
+
+func (s *progressServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	var payload []byte
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		payload = append(payload, req.Payload...)
+	}
+	half := len(payload) / 2
+	for _, resp := range []*pb.InferResponse{
+		{Meta: map[string]string{ProgressMetaKey: "25"}},
+		{Result: payload[:half], Seq: 0, Total: 2},
+		{Meta: map[string]string{ProgressMetaKey: "75", "stage": "decode"}},
+		{Result: payload[half:], Seq: 1, Total: 2, Offset: uint64(half), IsFinal: true},
+	} {
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestInferReportsProgress(t *testing.T) {
+	for _, chunked := range []bool{false, true} {
+		name := "single"
+		if chunked {
+			name = "chunked"
+		}
+		t.Run(name, func(t *testing.T) {
+			c := startClientFor(t, &progressServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+			if chunked {
+				c.config.Chunk = config.ChunkConfig{EnableAuto: true, MaxChunkBytes: 4}
+			}
+
+			var got []float64
+			var stage string
+			ctx := WithProgressHandler(context.Background(), func(pct float64, meta map[string]string) {
+				got = append(got, pct)
+				if meta["stage"] != "" {
+					stage = meta["stage"]
+				}
+			})
+			req := embedRequest()
+			resp, err := c.Infer(ctx, req)
+			if err != nil {
+				t.Fatalf("Infer: %v", err)
+			}
+			if string(resp.Result) != string(req.Payload) {
+				t.Fatalf("result = %q, want the echoed payload %q", resp.Result, req.Payload)
+			}
+			if len(got) != 2 || got[0] != 25 || got[1] != 75 || stage != "decode" {
+				t.Fatalf("progress = %v (stage %q), want [25 75] with the frame's meta", got, stage)
+			}
+		})
+	}
+}
+
+func TestProgress(t *testing.T) {
+	for v, want := range map[string]float64{"42": 42, "42.5": 42.5, "-3": 0, "250": 100} {
+		if pct, ok := Progress(&pb.InferResponse{Meta: map[string]string{ProgressMetaKey: v}}); !ok || pct != want {
+			t.Errorf("Progress(%q) = %v, %v; want %v", v, pct, ok, want)
+		}
+	}
+	for _, v := range []string{"", "half", "NaN"} {
+		if _, ok := Progress(&pb.InferResponse{Meta: map[string]string{ProgressMetaKey: v}}); ok {
+			t.Errorf("Progress(%q) reported a value", v)
+		}
+	}
+	if _, ok := Progress(&pb.InferResponse{}); ok {
+		t.Error("Progress of a frame without the key reported a value")
+	}
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// ProjectionMetaKey is the request Meta key listing, comma-separated, the
+// result fields set with WithResultProjection. Nodes that understand it
+// return only those fields; others ignore it and return the full result.
+const ProjectionMetaKey = "lumen.projection"
+
+type projectionKey struct{}
+
+// WithResultProjection asks nodes to return only the named top-level result
+// fields for requests made with ctx, e.g. "vector" for an embedding. It is a
+// hint: parse results so that a full result works too, as
+// types.InferResponseParser.AsEmbeddingVector does. Without fields, ctx is
+// returned unchanged.
+func WithResultProjection(ctx context.Context, fields ...string) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, projectionKey{}, strings.Join(fields, ","))
+}
+
+// tagProjection records the projection set on ctx in the request's Meta
+// unless the caller already has.
+func tagProjection(ctx context.Context, req *pb.InferRequest) {
+	fields, _ := ctx.Value(projectionKey{}).(string)
+	if fields == "" {
+		return
+	}
+	if _, ok := req.Meta[ProjectionMetaKey]; ok {
+		return
+	}
+	if req.Meta == nil {
+		req.Meta = make(map[string]string)
+	}
+	req.Meta[ProjectionMetaKey] = fields
+}
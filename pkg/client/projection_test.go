@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+)
+
+// embedServer answers every request with a 512-dimension embedding. When
+// honorProjection is set it returns only the fields named under
+// ProjectionMetaKey, as a cooperating node would.
+type embedServer struct {
+	testInferenceServer
+	honorProjection bool
+	full, projected []byte
+}
+
+func newEmbedServer(honorProjection bool) *embedServer {
+	vec := make([]float32, 512)
+	for i := range vec {
+		vec[i] = rand.Float32()*2 - 1
+	}
+	full, _ := json.Marshal(types.EmbeddingV1{Vector: vec, Dim: len(vec), ModelID: "clip-vit-b-32"})
+	projected, _ := json.Marshal(map[string]any{"vector": vec})
+	return &embedServer{
+		testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}},
+		honorProjection:     honorProjection,
+		full:                full,
+		projected:           projected,
+	}
+}
+
+func (s *embedServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	var first *pb.InferRequest
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first == nil {
+			first = req
+		}
+	}
+	result := s.full
+	if s.honorProjection && first.GetMeta()[ProjectionMetaKey] == "vector" {
+		result = s.projected
+	}
+	return stream.Send(&pb.InferResponse{
+		CorrelationId: first.GetCorrelationId(),
+		IsFinal:       true,
+		ResultMime:    "application/json;schema=embedding_v1",
+		Result:        result,
+		Meta:          map[string]string{"model": "clip-vit-b-32"},
+	})
+}
+
+func TestResultProjectionWithAnyNode(t *testing.T) {
+	for _, honor := range []bool{true, false} {
+		srv := newEmbedServer(honor)
+		c := startClientFor(t, srv)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		req := embedRequest()
+		resp, err := c.Infer(WithResultProjection(ctx, "vector"), req)
+		if err != nil {
+			t.Fatalf("Infer: %v", err)
+		}
+		if got := req.Meta[ProjectionMetaKey]; got != "vector" {
+			t.Fatalf("request Meta %s = %q, want vector", ProjectionMetaKey, got)
+		}
+		if projected := len(resp.GetResult()) == len(srv.projected); projected != honor {
+			t.Fatalf("node honoring the projection: %v, got a projected result: %v", honor, projected)
+		}
+		vec, err := types.ParseInferResponse(resp).AsEmbeddingVector()
+		if err != nil {
+			t.Fatalf("AsEmbeddingVector: %v", err)
+		}
+		if len(vec) != 512 {
+			t.Fatalf("got %d dimensions, want 512", len(vec))
+		}
+	}
+}
+
+func TestWithResultProjectionKeepsCallerMeta(t *testing.T) {
+	req := embedRequest()
+	tagProjection(context.Background(), req)
+	if got, ok := req.Meta[ProjectionMetaKey]; ok {
+		t.Fatalf("%s = %q without a projection", ProjectionMetaKey, got)
+	}
+	req.Meta = map[string]string{ProjectionMetaKey: "vector,dim"}
+	tagProjection(WithResultProjection(context.Background(), "vector"), req)
+	if got := req.Meta[ProjectionMetaKey]; got != "vector,dim" {
+		t.Fatalf("%s = %q, want the caller's vector,dim", ProjectionMetaKey, got)
+	}
+}
+
+// BenchmarkEmbedLoop runs the embed loop of an indexing pipeline against a
+// local node: "full" parses the whole result with AsEmbeddingResponse,
+// "projected" asks for the vector only and decodes it into a reused buffer.
+// Run with -benchtime=10000x -benchmem to compare allocations.
+func BenchmarkEmbedLoop(b *testing.B) {
+	run := func(b *testing.B, projected bool) {
+		c := startClientFor(b, newEmbedServer(true))
+		ctx := context.Background()
+		if projected {
+			ctx = WithResultProjection(ctx, "vector")
+		}
+		var buf []float32
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			resp, err := c.Infer(ctx, embedRequest())
+			if err != nil {
+				b.Fatal(err)
+			}
+			parser := types.ParseInferResponse(resp)
+			if projected {
+				buf, err = parser.AsEmbeddingVectorInto(buf)
+			} else {
+				var emb *types.EmbeddingV1
+				emb, err = parser.AsEmbeddingResponse()
+				if err == nil {
+					buf = emb.Vector
+				}
+			}
+			if err != nil || len(buf) != 512 {
+				b.Fatalf("parse: %v, %d dimensions", err, len(buf))
+			}
+		}
+	}
+	b.Run("full", func(b *testing.B) { run(b, false) })
+	b.Run("projected", func(b *testing.B) { run(b, true) })
+}
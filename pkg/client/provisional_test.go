@@ -0,0 +1,130 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// gatedCapabilityServer answers capability fetches only once release is
+// closed, so a node stays provisional until then.
+type gatedCapabilityServer struct {
+	testInferenceServer
+	release chan struct{}
+}
+
+func (s *gatedCapabilityServer) StreamCapabilities(e *emptypb.Empty, stream grpc.ServerStreamingServer[pb.Capability]) error {
+	select {
+	case <-s.release:
+	case <-stream.Context().Done():
+		return stream.Context().Err()
+	}
+	return s.testInferenceServer.StreamCapabilities(e, stream)
+}
+
+func connectHintedNode(t *testing.T, opts PoolOptions, logger *zap.Logger, srv pb.InferenceServer, txt map[string]string) *Pool {
+	t.Helper()
+	host, port, _ := splitEndpoint(startInferenceServer(t, srv))
+	opts.ConnectTimeout = 2 * time.Second
+	opts.CapabilityFetchTimeout = 5 * time.Second
+	pool := NewPoolWithOptions(logger, opts)
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{{
+		Type: discovery.NodeDiscovered,
+		Resolved: discovery.ResolvedNode{
+			Identity:  discovery.NewNodeIdentity("local", "node-1"),
+			Addresses: []string{host},
+			Port:      port,
+			Txt:       txt,
+		},
+	}}}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+	return pool
+}
+
+func onlyNode(t *testing.T, pool *Pool) *discovery.NodeInfo {
+	t.Helper()
+	nodes := pool.NodeInfos()
+	if len(nodes) != 1 {
+		t.Fatalf("nodes = %d, want 1", len(nodes))
+	}
+	return nodes[0]
+}
+
+func TestProvisionalNodeRoutesOnHintsUntilConfirmed(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	srv := &gatedCapabilityServer{testInferenceServer: testInferenceServer{tasks: []string{"embed", "ocr"}}, release: make(chan struct{})}
+	pool := connectHintedNode(t, PoolOptions{}, zap.New(core), srv, map[string]string{
+		"tasks":           "embed,face_detect",
+		"runtime":         "cuda",
+		"max_concurrency": "8",
+	})
+
+	waitUntil(t, func() bool { return pool.Stats().Provisional == 1 })
+	node := onlyNode(t, pool)
+	if !node.IsProvisional() || node.Metadata["max_concurrency"] != 8 {
+		t.Fatalf("node = %s %v, want provisional with max_concurrency 8", node.Status, node.Metadata)
+	}
+	if exp := pool.registry.explainSelection("face_detect", time.Now()); exp.Pick != "local-node-1" {
+		t.Fatalf("face_detect pick before confirmation = %q (%s), want the hinted node", exp.Pick, exp.Error)
+	}
+	if !pool.servesTask("embed") {
+		t.Fatal("a provisional node allowed traffic does not serve its hinted task")
+	}
+
+	close(srv.release)
+	waitUntil(t, func() bool { return onlyNode(t, pool).IsActive() })
+	if pool.Stats().Provisional != 0 {
+		t.Fatal("confirmed node still counted as provisional")
+	}
+	if exp := pool.registry.explainSelection("face_detect", time.Now()); exp.Pick != "" {
+		t.Fatalf("face_detect still routed to %s after capabilities contradicted the hint", exp.Pick)
+	}
+	if exp := pool.registry.explainSelection("ocr", time.Now()); exp.Pick != "local-node-1" {
+		t.Fatalf("ocr pick after confirmation = %q, want the node", exp.Pick)
+	}
+
+	warned := logs.FilterMessageSnippet("TXT capability hints were wrong").All()
+	if len(warned) != 1 {
+		t.Fatalf("mismatch warnings = %d, want 1", len(warned))
+	}
+	fields := warned[0].ContextMap()
+	if got := fields["hinted_not_served"]; len(got.([]interface{})) != 1 || got.([]interface{})[0] != "face_detect" {
+		t.Fatalf("hinted_not_served = %v, want [face_detect]", got)
+	}
+	if got := fields["served_not_hinted"]; len(got.([]interface{})) != 1 || got.([]interface{})[0] != "ocr" {
+		t.Fatalf("served_not_hinted = %v, want [ocr]", got)
+	}
+}
+
+func TestProvisionalNodeWithoutTrafficOnlyCountsTowardReadiness(t *testing.T) {
+	srv := &gatedCapabilityServer{testInferenceServer: testInferenceServer{tasks: []string{"embed"}}, release: make(chan struct{})}
+	pool := connectHintedNode(t, PoolOptions{RequireConfirmedCapabilities: true}, zap.NewNop(), srv, map[string]string{"tasks": "embed"})
+
+	waitUntil(t, func() bool { return pool.Stats().Provisional == 1 })
+	if node := onlyNode(t, pool); !node.IsProvisional() || len(node.Tasks) != 1 {
+		t.Fatalf("node = %s with %d tasks, want provisional with its hinted task", node.Status, len(node.Tasks))
+	}
+	exp := pool.registry.explainSelection("embed", time.Now())
+	if exp.Pick != "" || len(exp.Candidates) != 1 || exp.Candidates[0].Reason != discovery.SelectionProvisional {
+		t.Fatalf("explanation = %+v, want the node passed over as provisional", exp)
+	}
+	if pool.servesTask("embed") {
+		t.Fatal("a provisional node barred from traffic counted as serving")
+	}
+
+	close(srv.release)
+	waitUntil(t, func() bool { return pool.registry.explainSelection("embed", time.Now()).Pick == "local-node-1" })
+	if !onlyNode(t, pool).IsActive() {
+		t.Fatal("node not active once its capabilities arrived")
+	}
+}
@@ -0,0 +1,740 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	sdktypes "github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// QueueResult is what a handler registered with HandleQueueResults receives
+// for a request added with EnqueueInfer.
+type QueueResult struct {
+	// ID is the queue's ID of the request, as returned by EnqueueInfer.
+	ID            string
+	CorrelationID string
+	Task          string
+	Topic         string
+	Response      *pb.InferResponse
+	// Attempts counts the attempts that failed before the one answered.
+	Attempts int
+}
+
+// QueueHandler consumes the results of one topic. Returning an error keeps
+// the result queued, and it is offered again later.
+type QueueHandler func(QueueResult) error
+
+// queuePollInterval bounds how long the dispatcher sleeps without being
+// woken, so results wait at most this long for a handler that failed.
+const queuePollInterval = 30 * time.Second
+
+// EnqueueInfer adds req to the durable queue in queue.dir and returns its
+// queue ID. A background dispatcher sends it, queue.concurrency at a time,
+// whenever a node serving its task is up, retrying failures with backoff and
+// moving it to the dead letters after queue.max_attempts; RetryDeadLetters
+// queues dead letters again. The result goes to the handler registered for
+// topic with HandleQueueResults, or else to queue.results_dir.
+//
+// The queue survives restarts. A request is only sent again when the client
+// stopped before its node answered; a result that was received is kept until
+// it is delivered, so a crash at most delivers it twice. req is validated as
+// by Infer and gets its queue ID as CorrelationId when it has none.
+// EnqueueInfer fails with an UNAVAILABLE error when the queue is disabled or
+// queue.max_bytes is reached.
+func (c *LumenClient) EnqueueInfer(req *pb.InferRequest, topic string) (string, error) {
+	if req == nil {
+		return "", fmt.Errorf("request cannot be nil")
+	}
+	if c.queue == nil {
+		return "", utils.UnavailableError("durable queue is disabled; set queue.dir")
+	}
+	if err := sdktypes.ValidateTaskRequest(req); err != nil {
+		return "", err
+	}
+	return c.queue.enqueue(req, topic, c.queue.clock.Now())
+}
+
+// HandleQueueResults registers fn for the results of requests queued with
+// topic, including results that were waiting for a handler. A nil fn removes
+// the handler for topic.
+func (c *LumenClient) HandleQueueResults(topic string, fn QueueHandler) {
+	if c.queue == nil {
+		return
+	}
+	c.queue.handle(topic, fn)
+}
+
+// QueueStatus describes the durable queue; Enabled is false when queue.dir
+// is not set.
+func (c *LumenClient) QueueStatus() discovery.QueueStatus {
+	if c.queue == nil {
+		return discovery.QueueStatus{}
+	}
+	return c.queue.status(c.queue.clock.Now())
+}
+
+// RetryDeadLetters queues every dead letter again with a fresh attempt
+// count and returns how many there were.
+func (c *LumenClient) RetryDeadLetters() (int, error) {
+	if c.queue == nil {
+		return 0, utils.UnavailableError("durable queue is disabled; set queue.dir")
+	}
+	return c.queue.retryDead()
+}
+
+// canServe reports whether a request for task can be sent now: a node
+// serving it is up, or a local handler would take it.
+func (c *LumenClient) canServe(task string) bool {
+	return c.localHandler(task) != nil || c.pool.servesTask(task)
+}
+
+// durableQueue keeps queued requests on disk, one set of files per request
+// named by its ID:
+//
+//	<id>.req   the request, sealed when payload protection is enabled
+//	<id>.resp  the response, once a node answered, sealed likewise
+//	<id>.json  its discovery.QueueItem, written last
+//
+// Every file is written to a temporary name and renamed, and the item is
+// the commit point: a request exists once its item does, and its state
+// moves to completed only after the response is on disk. Files without an
+// item are left over from a crash and removed when the queue is opened.
+type durableQueue struct {
+	dir            string
+	resultsDir     string
+	maxBytes       int64
+	concurrency    int
+	maxAttempts    int
+	backoff        time.Duration
+	maxBackoff     time.Duration
+	attemptTimeout time.Duration
+	cipher         *utils.PayloadCipher
+	logger         *zap.Logger
+	// clock times attempts, backoffs and the dispatcher's waits.
+	clock utils.Clock
+
+	// wake is signalled when there may be work: a request was queued, nodes
+	// changed or a handler was registered.
+	wake chan struct{}
+
+	mu       sync.Mutex
+	items    map[string]*discovery.QueueItem
+	busy     map[string]bool // being sent or delivered
+	bytes    int64
+	handlers map[string]QueueHandler
+	rate     rateWindow
+
+	dispatched   atomic.Int64
+	completed    atomic.Int64
+	failed       atomic.Int64
+	deadLettered atomic.Int64
+}
+
+// openQueue opens the queue in cfg.Dir, creating it if needed, and loads
+// the requests left there.
+func openQueue(cfg config.QueueConfig, cipher *utils.PayloadCipher, logger *zap.Logger) (*durableQueue, error) {
+	defaults := config.DefaultConfig().Queue
+	q := &durableQueue{
+		dir:            cfg.Dir,
+		resultsDir:     cfg.ResultsDir,
+		maxBytes:       positiveOr(cfg.MaxBytes, defaults.MaxBytes),
+		concurrency:    positiveOr(cfg.Concurrency, defaults.Concurrency),
+		maxAttempts:    positiveOr(cfg.MaxAttempts, defaults.MaxAttempts),
+		backoff:        positiveOr(cfg.RetryBackoff, defaults.RetryBackoff),
+		maxBackoff:     positiveOr(cfg.MaxRetryBackoff, defaults.MaxRetryBackoff),
+		attemptTimeout: positiveOr(cfg.AttemptTimeout, defaults.AttemptTimeout),
+		cipher:         cipher,
+		logger:         logger,
+		clock:          utils.RealClock,
+		wake:           make(chan struct{}, 1),
+		items:          make(map[string]*discovery.QueueItem),
+		busy:           make(map[string]bool),
+		handlers:       make(map[string]QueueHandler),
+	}
+	if err := os.MkdirAll(q.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("queue dir: %w", err)
+	}
+	if q.resultsDir != "" {
+		if err := os.MkdirAll(q.resultsDir, 0o700); err != nil {
+			return nil, fmt.Errorf("queue results dir: %w", err)
+		}
+	}
+	if err := q.load(); err != nil {
+		return nil, fmt.Errorf("load queue: %w", err)
+	}
+	return q, nil
+}
+
+func positiveOr[T int | int64 | time.Duration](v, def T) T {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// load reads the items in the queue directory and removes files no item
+// owns.
+func (q *durableQueue) load() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(q.dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		var item discovery.QueueItem
+		if err := json.Unmarshal(data, &item); err != nil || item.ID != id {
+			q.logger.Warn("dropping unreadable queue item", zap.String("item", e.Name()), zap.Error(err))
+			q.removeFiles(id)
+			continue
+		}
+		if _, err := os.Stat(q.path(id, ".req")); err != nil {
+			q.logger.Warn("dropping queue item without its request", zap.String("id", id), zap.Error(err))
+			q.removeFiles(id)
+			continue
+		}
+		q.items[id] = &item
+		q.bytes += item.Bytes
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			continue
+		}
+		if _, ok := q.items[strings.TrimSuffix(name, filepath.Ext(name))]; !ok || strings.HasSuffix(name, ".tmp") {
+			_ = os.Remove(filepath.Join(q.dir, name))
+		}
+	}
+	if len(q.items) > 0 {
+		q.logger.Info("durable queue loaded", zap.String("dir", q.dir), zap.Int("items", len(q.items)))
+	}
+	return nil
+}
+
+func (q *durableQueue) path(id, ext string) string {
+	return filepath.Join(q.dir, id+ext)
+}
+
+// removeFiles removes the files of id, the item first.
+func (q *durableQueue) removeFiles(id string) {
+	for _, ext := range []string{".json", ".req", ".resp"} {
+		if err := os.Remove(q.path(id, ext)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			q.logger.Warn("failed to remove queue file", zap.String("id", id), zap.String("file", ext), zap.Error(err))
+		}
+	}
+}
+
+func (q *durableQueue) enqueue(req *pb.InferRequest, topic string, now time.Time) (string, error) {
+	id := newQueueID(now)
+	if req.CorrelationId == "" {
+		req.CorrelationId = id
+	}
+	data, err := q.seal(req)
+	if err != nil {
+		return "", err
+	}
+	item := &discovery.QueueItem{
+		ID:            id,
+		CorrelationID: req.CorrelationId,
+		Task:          req.Task,
+		Topic:         topic,
+		State:         discovery.QueuePending,
+		Bytes:         int64(len(data)),
+		EnqueuedAt:    now,
+	}
+
+	// Reserve the space before writing so concurrent calls cannot overrun
+	// the quota together.
+	q.mu.Lock()
+	if q.bytes+item.Bytes > q.maxBytes {
+		q.mu.Unlock()
+		return "", utils.UnavailableError(fmt.Sprintf("durable queue is full: %d of %d bytes used", q.bytes, q.maxBytes))
+	}
+	q.bytes += item.Bytes
+	q.mu.Unlock()
+
+	err = writeFileAtomic(q.path(id, ".req"), data)
+	if err == nil {
+		err = q.writeItem(item)
+	}
+	q.mu.Lock()
+	if err != nil {
+		q.bytes -= item.Bytes
+	} else {
+		q.items[id] = item
+	}
+	q.mu.Unlock()
+	if err != nil {
+		q.removeFiles(id)
+		return "", utils.Wrap(err, utils.ErrCodeInternal, "queue request")
+	}
+	q.notify()
+	return id, nil
+}
+
+func newQueueID(now time.Time) string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	// Time first, so IDs sort in queue order.
+	return fmt.Sprintf("q-%016x-%s", now.UnixNano(), hex.EncodeToString(b[:]))
+}
+
+func (q *durableQueue) seal(m proto.Message) ([]byte, error) {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return nil, utils.Wrap(err, utils.ErrCodeInvalid, "encode queued message")
+	}
+	if q.cipher == nil {
+		return data, nil
+	}
+	sealed, err := q.cipher.Seal(data)
+	utils.ZeroBytes(data)
+	return sealed, err
+}
+
+func (q *durableQueue) open(path string, m proto.Message) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if q.cipher != nil {
+		if data, err = q.cipher.Open(data); err != nil {
+			return err
+		}
+		defer utils.ZeroBytes(data)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (q *durableQueue) writeItem(item *discovery.QueueItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(q.path(item.ID, ".json"), data)
+}
+
+// writeFileAtomic replaces path with data so that a crash leaves either
+// the old or the new content.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	// Make the rename durable; not every platform can sync a directory.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+	return nil
+}
+
+func (q *durableQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *durableQueue) handle(topic string, fn QueueHandler) {
+	q.mu.Lock()
+	if fn == nil {
+		delete(q.handlers, topic)
+	} else {
+		q.handlers[topic] = fn
+	}
+	q.mu.Unlock()
+	q.notify()
+}
+
+// run dispatches queued requests with infer while ctx lasts, sending a
+// request only when servable reports a way to serve its task. It returns
+// once the attempts it started have ended.
+func (q *durableQueue) run(ctx context.Context, infer InferFunc, servable func(task string) bool) {
+	var attempts sync.WaitGroup
+	defer attempts.Wait()
+	for {
+		q.deliverCompleted()
+		wait := queuePollInterval
+		if next := q.dispatchReady(ctx, &attempts, infer, servable); !next.IsZero() {
+			wait = min(wait, max(next.Sub(q.clock.Now()), 0))
+		}
+		timer := q.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-q.wake:
+		case <-timer.C():
+		}
+		timer.Stop()
+	}
+}
+
+// dispatchReady starts attempts for the pending requests that are due, in
+// queue order, up to the concurrency limit. It returns when the next
+// request waiting out its backoff is due, or zero when none is.
+func (q *durableQueue) dispatchReady(ctx context.Context, attempts *sync.WaitGroup, infer InferFunc, servable func(string) bool) time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.clock.Now()
+	var ready []*discovery.QueueItem
+	var next time.Time
+	inFlight := 0
+	for _, item := range q.items {
+		switch {
+		case item.State != discovery.QueuePending:
+		case q.busy[item.ID]:
+			inFlight++
+		case item.NextAttemptAt.After(now):
+			if next.IsZero() || item.NextAttemptAt.Before(next) {
+				next = item.NextAttemptAt
+			}
+		default:
+			ready = append(ready, item)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool { return ready[i].ID < ready[j].ID })
+
+	canServe := make(map[string]bool)
+	for _, item := range ready {
+		if inFlight >= q.concurrency {
+			break
+		}
+		ok, seen := canServe[item.Task]
+		if !seen {
+			ok = servable(item.Task)
+			canServe[item.Task] = ok
+		}
+		if !ok {
+			continue
+		}
+		inFlight++
+		q.busy[item.ID] = true
+		q.dispatched.Add(1)
+		attempts.Add(1)
+		go func(item discovery.QueueItem) {
+			defer attempts.Done()
+			q.attempt(ctx, item, infer)
+		}(*item)
+	}
+	return next
+}
+
+// attempt sends the request of item once and records the outcome.
+func (q *durableQueue) attempt(ctx context.Context, item discovery.QueueItem, infer InferFunc) {
+	req := &pb.InferRequest{}
+	if err := q.open(q.path(item.ID, ".req"), req); err != nil {
+		q.finishAttempt(item.ID, nil, fmt.Errorf("read queued request: %w", err))
+		return
+	}
+	actx, cancel := context.WithTimeout(ctx, q.attemptTimeout)
+	resp, err := infer(actx, req)
+	cancel()
+	if err == nil {
+		err = responseError(resp)
+	}
+	if err != nil && ctx.Err() != nil {
+		// Stopped, not failed: the request is sent again after a restart.
+		q.mu.Lock()
+		delete(q.busy, item.ID)
+		q.mu.Unlock()
+		return
+	}
+	q.finishAttempt(item.ID, resp, err)
+	q.notify()
+}
+
+// finishAttempt records a failed attempt, or stores the response and
+// delivers it.
+func (q *durableQueue) finishAttempt(id string, resp *pb.InferResponse, err error) {
+	var respBytes int64
+	if err == nil {
+		var data []byte
+		if data, err = q.seal(resp); err == nil {
+			err = writeFileAtomic(q.path(id, ".resp"), data)
+			respBytes = int64(len(data))
+		}
+		if err != nil {
+			err = fmt.Errorf("store response: %w", err)
+		}
+	}
+
+	q.mu.Lock()
+	item, ok := q.items[id]
+	if !ok {
+		delete(q.busy, id)
+		q.mu.Unlock()
+		return
+	}
+	now := q.clock.Now()
+	if err == nil {
+		item.State = discovery.QueueCompleted
+		item.NextAttemptAt = time.Time{}
+		item.Bytes += respBytes
+		q.bytes += respBytes
+		q.completed.Add(1)
+		q.rate.add(now)
+	} else {
+		item.Attempts++
+		item.LastError = err.Error()
+		q.failed.Add(1)
+		if item.Attempts >= q.maxAttempts {
+			item.State = discovery.QueueDead
+			q.deadLettered.Add(1)
+			q.logger.Warn("queued request moved to dead letters",
+				zap.String("id", id), zap.String("task", item.Task), zap.Int("attempts", item.Attempts), zap.Error(err))
+		} else {
+			item.NextAttemptAt = now.Add(q.backoffAfter(item.Attempts))
+			q.logger.Debug("queued request failed; will retry",
+				zap.String("id", id), zap.String("task", item.Task), zap.Int("attempts", item.Attempts),
+				zap.Time("next_attempt_at", item.NextAttemptAt), zap.Error(err))
+		}
+	}
+	snapshot := *item
+	werr := q.writeItem(&snapshot)
+	q.mu.Unlock()
+	if werr != nil {
+		// The item on disk still says pending, so the request is sent
+		// again after a restart.
+		q.logger.Error("failed to record queued request state", zap.String("id", id), zap.Error(werr))
+	}
+
+	if snapshot.State == discovery.QueueCompleted {
+		q.deliver(snapshot)
+	}
+	q.mu.Lock()
+	delete(q.busy, id)
+	q.mu.Unlock()
+}
+
+// backoffAfter returns the wait after the given number of failed attempts.
+func (q *durableQueue) backoffAfter(attempts int) time.Duration {
+	d := q.backoff
+	for i := 1; i < attempts && d < q.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, q.maxBackoff)
+}
+
+// deliverCompleted offers every answered request nobody is handling to its
+// topic's handler or the results directory.
+func (q *durableQueue) deliverCompleted() {
+	q.mu.Lock()
+	var due []discovery.QueueItem
+	for _, item := range q.items {
+		if item.State == discovery.QueueCompleted && !q.busy[item.ID] {
+			q.busy[item.ID] = true
+			due = append(due, *item)
+		}
+	}
+	q.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	for _, item := range due {
+		q.deliver(item)
+		q.mu.Lock()
+		delete(q.busy, item.ID)
+		q.mu.Unlock()
+	}
+}
+
+// deliver hands the response of item to its topic's handler, or writes it
+// to the results directory, and then forgets the request. Without either
+// the response stays queued.
+func (q *durableQueue) deliver(item discovery.QueueItem) {
+	q.mu.Lock()
+	handler := q.handlers[item.Topic]
+	q.mu.Unlock()
+	if handler == nil && q.resultsDir == "" {
+		return
+	}
+
+	resp := &pb.InferResponse{}
+	err := q.open(q.path(item.ID, ".resp"), resp)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("read stored response: %w", err)
+	case handler != nil:
+		err = utils.SafeExecute(func() error {
+			return handler(QueueResult{
+				ID:            item.ID,
+				CorrelationID: item.CorrelationID,
+				Task:          item.Task,
+				Topic:         item.Topic,
+				Response:      resp,
+				Attempts:      item.Attempts,
+			})
+		})
+	default:
+		err = q.writeResultFile(item, resp)
+	}
+	if err != nil {
+		q.logger.Warn("failed to deliver queued result; will retry",
+			zap.String("id", item.ID), zap.String("topic", item.Topic), zap.Error(err))
+		return
+	}
+
+	q.mu.Lock()
+	delete(q.items, item.ID)
+	q.bytes -= item.Bytes
+	q.mu.Unlock()
+	q.removeFiles(item.ID)
+}
+
+// queueResultFile is the content of <id>.json in the results directory.
+// It is named by the queue ID because correlation IDs need not be unique.
+type queueResultFile struct {
+	ID            string            `json:"id"`
+	CorrelationID string            `json:"correlation_id"`
+	Task          string            `json:"task"`
+	Topic         string            `json:"topic"`
+	ResultMime    string            `json:"result_mime"`
+	Result        []byte            `json:"result"`
+	Meta          map[string]string `json:"meta,omitempty"`
+}
+
+func (q *durableQueue) writeResultFile(item discovery.QueueItem, resp *pb.InferResponse) error {
+	data, err := json.Marshal(queueResultFile{
+		ID:            item.ID,
+		CorrelationID: item.CorrelationID,
+		Task:          item.Task,
+		Topic:         item.Topic,
+		ResultMime:    resp.GetResultMime(),
+		Result:        resp.GetResult(),
+		Meta:          resp.GetMeta(),
+	})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(q.resultsDir, item.ID+".json"), data)
+}
+
+func (q *durableQueue) retryDead() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	retried := 0
+	var errs []error
+	for _, item := range q.items {
+		if item.State != discovery.QueueDead {
+			continue
+		}
+		updated := *item
+		updated.State = discovery.QueuePending
+		updated.Attempts = 0
+		updated.NextAttemptAt = time.Time{}
+		if err := q.writeItem(&updated); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", item.ID, err))
+			continue
+		}
+		*item = updated
+		retried++
+	}
+	if retried > 0 {
+		q.notify()
+	}
+	if len(errs) > 0 {
+		return retried, utils.Wrap(errors.Join(errs...), utils.ErrCodeInternal, "retry dead letters")
+	}
+	return retried, nil
+}
+
+func (q *durableQueue) status(now time.Time) discovery.QueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := discovery.QueueStatus{
+		Enabled:      true,
+		Bytes:        q.bytes,
+		MaxBytes:     q.maxBytes,
+		Dispatched:   q.dispatched.Load(),
+		Completed:    q.completed.Load(),
+		Failed:       q.failed.Load(),
+		DeadLettered: q.deadLettered.Load(),
+		DispatchRate: q.rate.perSecond(now),
+	}
+	for _, item := range q.items {
+		switch item.State {
+		case discovery.QueuePending:
+			s.Depth++
+			if q.busy[item.ID] {
+				s.InFlight++
+			}
+		case discovery.QueueCompleted:
+			s.Undelivered++
+		case discovery.QueueDead:
+			s.Dead++
+			s.DeadLetters = append(s.DeadLetters, *item)
+		}
+	}
+	sort.Slice(s.DeadLetters, func(i, j int) bool { return s.DeadLetters[i].ID < s.DeadLetters[j].ID })
+	return s
+}
+
+// rateWindow counts events per second over the last minute.
+type rateWindow struct {
+	buckets [60]struct {
+		sec int64
+		n   int64
+	}
+}
+
+func (w *rateWindow) add(now time.Time) {
+	sec := now.Unix()
+	b := &w.buckets[sec%int64(len(w.buckets))]
+	if b.sec != sec {
+		b.sec, b.n = sec, 0
+	}
+	b.n++
+}
+
+func (w *rateWindow) perSecond(now time.Time) float64 {
+	since := now.Unix() - int64(len(w.buckets))
+	var n int64
+	for _, b := range w.buckets {
+		if b.sec > since {
+			n += b.n
+		}
+	}
+	return float64(n) / float64(len(w.buckets))
+}
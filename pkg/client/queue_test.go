@@ -0,0 +1,439 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"go.uber.org/zap"
+)
+
+func openTestQueue(t *testing.T, cfg config.QueueConfig) *durableQueue {
+	t.Helper()
+	q, err := openQueue(cfg, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("openQueue: %v", err)
+	}
+	return q
+}
+
+// runQueue runs q's dispatcher until the test ends or the returned func is
+// called, which waits for it to stop.
+func runQueue(t *testing.T, q *durableQueue, infer InferFunc, servable func(string) bool) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.run(ctx, infer, servable)
+	}()
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// countingInfer answers every request with its payload as the result and
+// counts the calls.
+type countingInfer struct {
+	calls atomic.Int64
+	fail  atomic.Bool
+}
+
+func (f *countingInfer) infer(_ context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+	f.calls.Add(1)
+	if f.fail.Load() {
+		return nil, utils.UnavailableError("node is down")
+	}
+	return &pb.InferResponse{CorrelationId: req.CorrelationId, IsFinal: true, ResultMime: "text/plain", Result: req.Payload}, nil
+}
+
+func queuedRequest(payload string) *pb.InferRequest {
+	return &pb.InferRequest{Task: types.TaskSemanticTextEmbed, Payload: []byte(payload), PayloadMime: "text/plain"}
+}
+
+// resultSink collects delivered results.
+type resultSink struct {
+	mu      sync.Mutex
+	results []QueueResult
+}
+
+func (s *resultSink) handle(r QueueResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, r)
+	return nil
+}
+
+func (s *resultSink) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.results)
+}
+
+func queueFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestQueueDispatchesOnceANodeServesTheTask(t *testing.T) {
+	q := openTestQueue(t, config.QueueConfig{Dir: t.TempDir(), Concurrency: 2})
+	var up atomic.Bool
+	node := &countingInfer{}
+	sink := &resultSink{}
+	q.handle("photos", sink.handle)
+	runQueue(t, q, node.infer, func(task string) bool { return up.Load() && task == types.TaskSemanticTextEmbed })
+
+	for _, p := range []string{"a", "b", "c"} {
+		if _, err := q.enqueue(queuedRequest(p), "photos", time.Now()); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := node.calls.Load(); n != 0 {
+		t.Fatalf("sent %d requests with no node up", n)
+	}
+	if s := q.status(time.Now()); s.Depth != 3 || s.InFlight != 0 {
+		t.Fatalf("status = %+v, want 3 pending", s)
+	}
+
+	up.Store(true)
+	q.notify()
+	waitUntil(t, func() bool { return sink.len() == 3 })
+
+	s := q.status(time.Now())
+	if s.Depth != 0 || s.Undelivered != 0 || s.Bytes != 0 || s.Dispatched != 3 || s.Completed != 3 || s.DispatchRate <= 0 {
+		t.Fatalf("status = %+v, want an empty queue after 3 completions", s)
+	}
+	for _, r := range sink.results {
+		if r.Topic != "photos" || r.CorrelationID != r.ID || r.Response.GetCorrelationId() != r.ID {
+			t.Fatalf("result %+v does not carry its queue ID as correlation ID", r)
+		}
+	}
+	if files := queueFiles(t, q.dir); len(files) != 0 {
+		t.Fatalf("queue dir holds %v after delivery", files)
+	}
+}
+
+func TestQueueDeadLettersAndRetries(t *testing.T) {
+	q := openTestQueue(t, config.QueueConfig{Dir: t.TempDir(), MaxAttempts: 3, RetryBackoff: time.Millisecond, MaxRetryBackoff: 5 * time.Millisecond})
+	node := &countingInfer{}
+	node.fail.Store(true)
+	sink := &resultSink{}
+	q.handle("photos", sink.handle)
+	runQueue(t, q, node.infer, func(string) bool { return true })
+
+	id, err := q.enqueue(queuedRequest("a"), "photos", time.Now())
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitUntil(t, func() bool { return q.status(time.Now()).Dead == 1 })
+
+	s := q.status(time.Now())
+	if s.Depth != 0 || s.Failed != 3 || s.DeadLettered != 1 || node.calls.Load() != 3 {
+		t.Fatalf("status = %+v after %d calls, want one dead letter after 3 failures", s, node.calls.Load())
+	}
+	dead := s.DeadLetters[0]
+	if dead.ID != id || dead.Attempts != 3 || dead.LastError == "" || dead.State != discovery.QueueDead {
+		t.Fatalf("dead letter = %+v", dead)
+	}
+
+	node.fail.Store(false)
+	if n, err := q.retryDead(); err != nil || n != 1 {
+		t.Fatalf("retryDead = %d, %v; want 1", n, err)
+	}
+	waitUntil(t, func() bool { return sink.len() == 1 })
+	if got := sink.results[0]; got.ID != id || got.Attempts != 0 {
+		t.Fatalf("result = %+v, want %s with no failed attempts since the retry", got, id)
+	}
+}
+
+func TestQueueBackoffDoublesUpToTheCap(t *testing.T) {
+	q := &durableQueue{backoff: time.Second, maxBackoff: 5 * time.Second}
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 40: 5 * time.Second} {
+		if got := q.backoffAfter(attempts); got != want {
+			t.Errorf("backoffAfter(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+// TestQueueResumesAfterRestart stops the dispatcher with one request
+// answered but undelivered and one not yet sent, as a crash would, and
+// reopens the queue: the answered one is delivered without being sent
+// again, and the other is sent once.
+func TestQueueResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestQueue(t, config.QueueConfig{Dir: dir})
+	node := &countingInfer{}
+	var up atomic.Bool
+	up.Store(true)
+	stop := runQueue(t, q, node.infer, func(string) bool { return up.Load() })
+
+	answered := queuedRequest("answered")
+	if _, err := q.enqueue(answered, "photos", time.Now()); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitUntil(t, func() bool { return q.status(time.Now()).Undelivered == 1 })
+	up.Store(false)
+	if _, err := q.enqueue(queuedRequest("pending"), "photos", time.Now()); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	stop()
+
+	// Leftovers of writes a crash interrupted.
+	for _, name := range []string{"q-0000000000000001-00000000.req", "q-0000000000000001-00000000.json.tmp"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("partial"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q = openTestQueue(t, config.QueueConfig{Dir: dir})
+	if s := q.status(time.Now()); s.Depth != 1 || s.Undelivered != 1 {
+		t.Fatalf("reopened status = %+v, want 1 pending and 1 undelivered", s)
+	}
+	if files := queueFiles(t, dir); len(files) != 5 {
+		t.Fatalf("queue dir holds %v, want the files of two requests", files)
+	}
+
+	sink := &resultSink{}
+	q.handle("photos", sink.handle)
+	runQueue(t, q, node.infer, func(string) bool { return true })
+	waitUntil(t, func() bool { return sink.len() == 2 })
+	if n := node.calls.Load(); n != 2 {
+		t.Fatalf("node saw %d requests, want 2: the answered one must not be sent again", n)
+	}
+}
+
+func TestQueueWritesResultsWithoutAHandler(t *testing.T) {
+	results := t.TempDir()
+	q := openTestQueue(t, config.QueueConfig{Dir: t.TempDir(), ResultsDir: results})
+	node := &countingInfer{}
+	runQueue(t, q, node.infer, func(string) bool { return true })
+
+	// Two requests share a correlation ID; neither result may replace the
+	// other.
+	var ids []string
+	for _, payload := range []string{"a photo", "its copy"} {
+		req := queuedRequest(payload)
+		req.CorrelationId = "import/IMG_0001"
+		id, err := q.enqueue(req, "photos", time.Now())
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	waitUntil(t, func() bool { return len(queueFiles(t, results)) == 2 })
+
+	for i, payload := range []string{"a photo", "its copy"} {
+		data, err := os.ReadFile(filepath.Join(results, ids[i]+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got queueResultFile
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("result file: %v", err)
+		}
+		if got.ID != ids[i] || got.CorrelationID != "import/IMG_0001" || got.Topic != "photos" || string(got.Result) != payload {
+			t.Fatalf("result file = %+v", got)
+		}
+	}
+	waitUntil(t, func() bool { return q.status(time.Now()).Undelivered == 0 })
+}
+
+func TestQueueRetriesAFailedDelivery(t *testing.T) {
+	q := openTestQueue(t, config.QueueConfig{Dir: t.TempDir()})
+	var failing atomic.Bool
+	failing.Store(true)
+	sink := &resultSink{}
+	q.handle("photos", func(r QueueResult) error {
+		if failing.Load() {
+			return errors.New("index unavailable")
+		}
+		return sink.handle(r)
+	})
+	runQueue(t, q, (&countingInfer{}).infer, func(string) bool { return true })
+
+	if _, err := q.enqueue(queuedRequest("a"), "photos", time.Now()); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitUntil(t, func() bool { return q.status(time.Now()).Undelivered == 1 })
+	failing.Store(false)
+	q.notify()
+	waitUntil(t, func() bool { return sink.len() == 1 })
+}
+
+func TestQueueQuota(t *testing.T) {
+	q := openTestQueue(t, config.QueueConfig{Dir: t.TempDir(), MaxBytes: 128})
+	if _, err := q.enqueue(queuedRequest("small"), "", time.Now()); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	_, err := q.enqueue(queuedRequest(string(make([]byte, 128))), "", time.Now())
+	if !utils.HasErrorCode(err, utils.ErrCodeUnavailable) {
+		t.Fatalf("enqueue over quota = %v, want UNAVAILABLE", err)
+	}
+	if s := q.status(time.Now()); s.Depth != 1 || s.Bytes > 128 {
+		t.Fatalf("status = %+v, want the first request only", s)
+	}
+}
+
+// TestQueueCountsStoredResponses checks that an undelivered response counts
+// against queue.max_bytes, across a restart, until it is delivered.
+func TestQueueCountsStoredResponses(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestQueue(t, config.QueueConfig{Dir: dir})
+	stop := runQueue(t, q, (&countingInfer{}).infer, func(string) bool { return true })
+
+	id, err := q.enqueue(queuedRequest("a photo"), "photos", time.Now())
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitUntil(t, func() bool { return q.status(time.Now()).Undelivered == 1 })
+	stop()
+
+	var want int64
+	for _, ext := range []string{".req", ".resp"} {
+		info, err := os.Stat(q.path(id, ext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += info.Size()
+	}
+	if s := q.status(time.Now()); s.Bytes != want {
+		t.Fatalf("Bytes = %d, want %d for the request and its response", s.Bytes, want)
+	}
+
+	q = openTestQueue(t, config.QueueConfig{Dir: dir})
+	if s := q.status(time.Now()); s.Bytes != want {
+		t.Fatalf("reopened Bytes = %d, want %d", s.Bytes, want)
+	}
+	sink := &resultSink{}
+	q.handle("photos", sink.handle)
+	runQueue(t, q, (&countingInfer{}).infer, func(string) bool { return true })
+	waitUntil(t, func() bool { return sink.len() == 1 })
+	if s := q.status(time.Now()); s.Bytes != 0 {
+		t.Fatalf("Bytes = %d after delivery, want 0", s.Bytes)
+	}
+}
+
+// TestQueueBacksOffOnItsClock fails a request twice on a fake clock: each
+// retry waits out its backoff in virtual time.
+func TestQueueBacksOffOnItsClock(t *testing.T) {
+	clock := utils.NewFakeClock(time.Unix(1_700_000_000, 0))
+	q := openTestQueue(t, config.QueueConfig{Dir: t.TempDir(), MaxAttempts: 5, RetryBackoff: time.Second, MaxRetryBackoff: time.Minute})
+	q.clock = clock
+	node := &countingInfer{}
+	node.fail.Store(true)
+	runQueue(t, q, node.infer, func(string) bool { return true })
+
+	start := clock.Now()
+	id, err := q.enqueue(queuedRequest("a"), "photos", start)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	nextAttemptAt := func() time.Time {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.items[id].NextAttemptAt
+	}
+
+	waitUntil(t, func() bool { return q.status(clock.Now()).Failed == 1 })
+	if got, want := nextAttemptAt(), start.Add(time.Second); !got.Equal(want) {
+		t.Fatalf("NextAttemptAt = %s, want %s", got, want)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := node.calls.Load(); n != 1 {
+		t.Fatalf("sent %d times before the backoff passed, want 1", n)
+	}
+
+	clock.Advance(time.Second)
+	waitUntil(t, func() bool { return q.status(clock.Now()).Failed == 2 })
+	if got, want := nextAttemptAt(), start.Add(3*time.Second); !got.Equal(want) {
+		t.Fatalf("NextAttemptAt = %s, want %s after the second failure", got, want)
+	}
+}
+
+func TestQueueSealsPayloads(t *testing.T) {
+	cipher, err := utils.NewPayloadCipher("k1", map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	q, err := openQueue(config.QueueConfig{Dir: dir}, cipher, zap.NewNop())
+	if err != nil {
+		t.Fatalf("openQueue: %v", err)
+	}
+	id, err := q.enqueue(queuedRequest("secret holiday photo"), "", time.Now())
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	data, err := os.ReadFile(q.path(id, ".req"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret holiday photo")) {
+		t.Fatal("queued request was written in plaintext")
+	}
+
+	sink := &resultSink{}
+	q.handle("", sink.handle)
+	runQueue(t, q, (&countingInfer{}).infer, func(string) bool { return true })
+	waitUntil(t, func() bool { return sink.len() == 1 })
+	if got := string(sink.results[0].Response.GetResult()); got != "secret holiday photo" {
+		t.Fatalf("result = %q", got)
+	}
+}
+
+func TestEnqueueInferThroughClient(t *testing.T) {
+	c := startClientFor(t, newEmbedServer(false))
+	if _, err := c.EnqueueInfer(queuedRequest("a"), "photos"); !utils.HasErrorCode(err, utils.ErrCodeUnavailable) {
+		t.Fatalf("EnqueueInfer without a queue = %v, want UNAVAILABLE", err)
+	}
+	if c.QueueStatus().Enabled || c.GetMetrics().Queue != nil {
+		t.Fatal("queue reported without queue.dir")
+	}
+
+	c.queue = openTestQueue(t, config.QueueConfig{Dir: t.TempDir()})
+	sink := &resultSink{}
+	c.HandleQueueResults("photos", sink.handle)
+	runQueue(t, c.queue, c.Infer, c.canServe)
+
+	if _, err := c.EnqueueInfer(&pb.InferRequest{Task: types.TaskSemanticTextEmbed}, "photos"); err == nil {
+		t.Fatal("EnqueueInfer accepted a request without a payload")
+	}
+	if _, err := c.EnqueueInfer(queuedRequest("a photo of a cat"), "photos"); err != nil {
+		t.Fatalf("EnqueueInfer: %v", err)
+	}
+	waitUntil(t, func() bool { return sink.len() == 1 })
+	vec, err := types.ParseInferResponse(sink.results[0].Response).AsEmbeddingVector()
+	if err != nil || len(vec) != 512 {
+		t.Fatalf("queued embedding: %d dimensions, %v", len(vec), err)
+	}
+	if m := c.GetMetrics().Queue; m == nil || m.Completed != 1 {
+		t.Fatalf("metrics queue = %+v, want one completion", m)
+	}
+}
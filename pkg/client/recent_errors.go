@@ -0,0 +1,148 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// recentErrorsLen is how many errors are kept per node.
+	recentErrorsLen = 50
+	// recentErrorMessageLen caps a kept error message, in bytes.
+	recentErrorMessageLen = 256
+	// explainErrorsLen is how many of a node's errors an explanation lists
+	// for a node passed over for its health.
+	explainErrorsLen = 3
+)
+
+// errorRing holds a node's latest errors, each new one overwriting the
+// oldest once it is full.
+type errorRing struct {
+	buf  [recentErrorsLen]discovery.RecentError
+	next int // index the next error is written to
+	n    int // errors held
+}
+
+func (r *errorRing) add(e discovery.RecentError) {
+	r.buf[r.next] = e
+	r.next = (r.next + 1) % len(r.buf)
+	if r.n < len(r.buf) {
+		r.n++
+	}
+}
+
+// last returns up to n of the latest errors, oldest first.
+func (r *errorRing) last(n int) []discovery.RecentError {
+	n = min(n, r.n)
+	out := make([]discovery.RecentError, n)
+	start := r.next - n + len(r.buf)
+	for i := range out {
+		out[i] = r.buf[(start+i)%len(r.buf)]
+	}
+	return out
+}
+
+// recentErrors keeps an errorRing per node, under a lock of its own so
+// recording from a request's done callback never waits on the balancer.
+// A nil recentErrors records nothing.
+type recentErrors struct {
+	mu    sync.Mutex
+	nodes map[string]*errorRing
+}
+
+func newRecentErrors() *recentErrors {
+	return &recentErrors{nodes: make(map[string]*errorRing)}
+}
+
+// record keeps err as key's latest error from op. correlationID is the
+// failed request's, or "".
+func (s *recentErrors) record(key, op string, err error, correlationID string, at time.Time) {
+	if s == nil || err == nil || key == "" {
+		return
+	}
+	e := discovery.RecentError{
+		At:            at,
+		Op:            op,
+		Code:          string(errorCode(err)),
+		CorrelationID: correlationID,
+		Message:       truncateMessage(err.Error(), recentErrorMessageLen),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.nodes[key]
+	if r == nil {
+		r = &errorRing{}
+		s.nodes[key] = r
+	}
+	r.add(e)
+}
+
+// get returns up to n of key's latest errors, oldest first.
+func (s *recentErrors) get(key string, n int) []discovery.RecentError {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.nodes[key]
+	if r == nil || r.n == 0 {
+		return nil
+	}
+	return r.last(n)
+}
+
+// forget drops key's errors.
+func (s *recentErrors) forget(key string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.nodes, key)
+}
+
+// errorCode is err's Lumen code, or the one matching its gRPC status.
+func errorCode(err error) utils.ErrorCode {
+	var lumErr *utils.LumenError
+	if errors.As(err, &lumErr) {
+		return lumErr.Code
+	}
+	switch status.Code(err) {
+	case codes.Canceled:
+		return utils.ErrCodeCancelled
+	case codes.DeadlineExceeded:
+		return utils.ErrCodeTimeout
+	case codes.Unavailable:
+		return utils.ErrCodeUnavailable
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return utils.ErrCodeInvalid
+	case codes.NotFound:
+		return utils.ErrCodeNotFound
+	case codes.Unauthenticated:
+		return utils.ErrCodeUnauthorized
+	case codes.PermissionDenied:
+		return utils.ErrCodeForbidden
+	case codes.Unimplemented:
+		return utils.ErrCodeTaskUnsupported
+	}
+	return utils.ErrCodeInternal
+}
+
+// truncateMessage cuts msg to at most n bytes without splitting a rune.
+func truncateMessage(msg string, n int) string {
+	if len(msg) <= n {
+		return msg
+	}
+	cut := n - len("...")
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut] + "..."
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecentErrorsKeepTheLatestInOrder(t *testing.T) {
+	s := newRecentErrors()
+	start := time.Now()
+	for i := range recentErrorsLen + 7 {
+		s.record("local-a", discovery.NodeOpInfer, fmt.Errorf("failure %d", i), "", start.Add(time.Duration(i)*time.Second))
+	}
+	got := s.get("local-a", recentErrorsLen)
+	if len(got) != recentErrorsLen {
+		t.Fatalf("kept %d errors, want %d", len(got), recentErrorsLen)
+	}
+	for i, e := range got {
+		if want := fmt.Sprintf("failure %d", i+7); e.Message != want {
+			t.Fatalf("error %d = %q, want %q", i, e.Message, want)
+		}
+	}
+	if last := s.get("local-a", 3); len(last) != 3 || last[2].Message != fmt.Sprintf("failure %d", recentErrorsLen+6) {
+		t.Fatalf("last 3 = %+v", last)
+	}
+
+	s.forget("local-a")
+	if got := s.get("local-a", recentErrorsLen); got != nil {
+		t.Fatalf("forgotten node kept %d errors", len(got))
+	}
+	var none *recentErrors
+	none.record("local-a", discovery.NodeOpDial, fmt.Errorf("refused"), "", start)
+	if none.get("local-a", 1) != nil {
+		t.Fatal("nil set returned errors")
+	}
+}
+
+func TestRecentErrorsConcurrentWritersStayBounded(t *testing.T) {
+	s := newRecentErrors()
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				s.record("local-a", discovery.NodeOpInfer, fmt.Errorf("w%d-%d", w, i), "", time.Now())
+				s.get("local-a", explainErrorsLen)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(s.get("local-a", 1000)); n != recentErrorsLen {
+		t.Fatalf("kept %d errors, want %d", n, recentErrorsLen)
+	}
+}
+
+func TestRecentErrorCodeAndTruncation(t *testing.T) {
+	s := newRecentErrors()
+	long := strings.Repeat("é", recentErrorMessageLen)
+	s.record("local-a", discovery.NodeOpInfer, status.Error(codes.DeadlineExceeded, long), "req-1", time.Now())
+	s.record("local-a", discovery.NodeOpDial, utils.ConnectionFailedError("10.0.0.1:50051"), "", time.Now())
+
+	got := s.get("local-a", 2)
+	if got[0].Code != string(utils.ErrCodeTimeout) || got[0].CorrelationID != "req-1" {
+		t.Fatalf("infer error = %+v", got[0])
+	}
+	if msg := got[0].Message; len(msg) > recentErrorMessageLen || !strings.HasSuffix(msg, "...") || !utf8.ValidString(msg) {
+		t.Fatalf("message of %d bytes not truncated cleanly: %q", len(msg), msg)
+	}
+	if got[1].Code != string(utils.ErrCodeConnectionFailed) || got[1].Op != discovery.NodeOpDial {
+		t.Fatalf("dial error = %+v", got[1])
+	}
+}
+
+// TestFailedRequestRecordsCorrelationID checks a request failing on a node
+// is kept with its correlation ID, and a cancelled one, which round-robin
+// sends to the next node, is not.
+func TestFailedRequestRecordsCorrelationID(t *testing.T) {
+	reg, picker := strategyFixture()
+	reg.recent = newRecentErrors()
+	picker.balancer.registry = reg
+
+	ctx := withSelectionHints(WithTask(context.Background(), "ocr"), &pb.InferRequest{CorrelationId: "req-42"})
+	res, err := picker.Pick(balancer.PickInfo{Ctx: ctx})
+	if err != nil {
+		t.Fatalf("Pick: %v", err)
+	}
+	key := res.SubConn.(*namedSubConn).name
+	res.Done(balancer.DoneInfo{Err: status.Error(codes.Internal, "model crashed")})
+
+	res, err = picker.Pick(balancer.PickInfo{Ctx: ctx})
+	if err != nil {
+		t.Fatalf("Pick: %v", err)
+	}
+	cancelled := res.SubConn.(*namedSubConn).name
+	res.Done(balancer.DoneInfo{Err: status.Error(codes.Canceled, "context canceled")})
+
+	got := reg.recent.get(key, recentErrorsLen)
+	if len(got) != 1 || got[0].Op != discovery.NodeOpInfer || got[0].CorrelationID != "req-42" || got[0].Code != string(utils.ErrCodeInternal) {
+		t.Fatalf("%s recent errors = %+v", key, got)
+	}
+	if got := reg.recent.get(cancelled, recentErrorsLen); got != nil {
+		t.Fatalf("cancelled request recorded: %+v", got)
+	}
+}
+
+func TestExplainListsRecentErrorsOfUnhealthyNodes(t *testing.T) {
+	now := time.Now()
+	id := func(n string) discovery.NodeIdentity { return discovery.NewNodeIdentity("local", n) }
+	reg := explainFixture(
+		&subConnState{identity: id("ready"), state: connectivity.Ready, tasks: []string{"ocr"}},
+		&subConnState{identity: id("cooling"), state: connectivity.Ready, tasks: []string{"ocr"}, cooldownUntil: now.Add(time.Hour)},
+		&subConnState{identity: id("embed"), state: connectivity.Ready, tasks: []string{"embed"}},
+	)
+	reg.recent = newRecentErrors()
+	for i := range 5 {
+		reg.recent.record("local-cooling", discovery.NodeOpInfer, fmt.Errorf("failure %d", i), "", now)
+		reg.recent.record("local-ready", discovery.NodeOpInfer, fmt.Errorf("failure %d", i), "", now)
+		reg.recent.record("local-embed", discovery.NodeOpInfer, fmt.Errorf("failure %d", i), "", now)
+	}
+
+	exp := reg.explainSelection("ocr", now)
+	if c := candidateByID(exp, "local-cooling"); len(c.RecentErrors) != explainErrorsLen || c.RecentErrors[explainErrorsLen-1].Message != "failure 4" {
+		t.Fatalf("cooling node's errors = %+v, want its last %d", c.RecentErrors, explainErrorsLen)
+	}
+	for _, id := range []string{"local-ready", "local-embed"} {
+		if c := candidateByID(exp, id); c.RecentErrors != nil {
+			t.Errorf("%s (reason %q) lists errors: %+v", id, c.Reason, c.RecentErrors)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestInferLogLinesCarryRequestFields runs a parallel upload that fails
+// over to one stream, so the client logs both before and after the node is
+// picked, and checks every line names the request.
+func TestInferLogLinesCarryRequestFields(t *testing.T) {
+	srv := newParallelUploadServer("true", "2/3")
+	c := startParallelUploadClient(t, srv)
+	core, logs := observer.New(zapcore.DebugLevel)
+	c.logger = zap.New(core)
+
+	req := uploadRequest([]byte("0123456789abcdefghijklmnopqrstuvwxyzABCD"))
+	ctx := utils.WithRequestLogger(context.Background(), zap.New(core), zap.String("request_id", "http-1"))
+	if _, err := c.Infer(ctx, req); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+
+	if logs.Len() == 0 {
+		t.Fatal("no log lines")
+	}
+	sawFailover := false
+	for _, entry := range logs.All() {
+		fields := entry.ContextMap()
+		if fields["correlation_id"] != req.CorrelationId || fields["task"] != req.Task || fields["request_id"] != "http-1" {
+			t.Errorf("%q has fields %v, want the request's correlation_id, task and request_id", entry.Message, fields)
+		}
+		if entry.Message == "parallel upload failed; retrying on one stream" {
+			sawFailover = true
+			if fields["node_id"] != "local-node-1" {
+				t.Errorf("%q has node_id %v, want local-node-1", entry.Message, fields["node_id"])
+			}
+		}
+	}
+	if !sawFailover {
+		t.Fatalf("no failover line among %d lines", logs.Len())
+	}
+}
+
+func TestInferSeedsRequestLoggerFromClient(t *testing.T) {
+	srv := newParallelUploadServer("", "")
+	c := startParallelUploadClient(t, srv)
+	core, logs := observer.New(zapcore.DebugLevel)
+	c.logger = zap.New(core)
+
+	req := uploadRequest([]byte("0123456789abcdef"))
+	if _, err := c.Infer(context.Background(), req); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	lines := logs.FilterMessage("payload chunking").All()
+	if len(lines) != 1 {
+		t.Fatalf("got %d payload chunking lines, want 1", len(lines))
+	}
+	if fields := lines[0].ContextMap(); fields["correlation_id"] != req.CorrelationId || fields["task"] != req.Task {
+		t.Fatalf("fields = %v, want the request's correlation_id and task", fields)
+	}
+}
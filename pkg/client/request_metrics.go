@@ -0,0 +1,74 @@
+package client
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// requestCounters are the request totals behind GetMetrics. Each is an
+// atomic counter updated without locks; snapshot reads them in an order
+// that keeps the figures consistent with each other.
+type requestCounters struct {
+	total   atomic.Int64
+	success atomic.Int64
+	// latencyNs sums the latencies of successful requests.
+	latencyNs atomic.Int64
+	// failures counts failed requests by FailureClass, cancellations
+	// included.
+	failures failureCounters
+}
+
+// requestSnapshot is one consistent reading of requestCounters.
+type requestSnapshot struct {
+	Total, Success int64
+	LatencyNs      int64
+	Failures       FailureCounts
+}
+
+// start counts a request when it begins, so Total includes requests still
+// in flight.
+func (r *requestCounters) start() {
+	r.total.Add(1)
+}
+
+func (r *requestCounters) succeed(elapsed time.Duration) {
+	r.success.Add(1)
+	r.latencyNs.Add(elapsed.Nanoseconds())
+}
+
+func (r *requestCounters) fail(class FailureClass) {
+	r.failures.add(class)
+}
+
+// snapshot reads the counters. Every request is counted in total before
+// any outcome, so reading the outcomes first and total last guarantees
+// Success + Failures.Failed() + Failures.Cancelled <= Total however many
+// requests finish during the reads. latencyNs is read before success for
+// the same reason: the sum never covers more requests than the count it
+// is divided by.
+func (r *requestCounters) snapshot() requestSnapshot {
+	var s requestSnapshot
+	s.LatencyNs = r.latencyNs.Load()
+	s.Success = r.success.Load()
+	s.Failures = r.failures.snapshot()
+	s.Total = r.total.Load()
+	return s
+}
+
+// ErrorRate is the share of requests that failed, cancellations aside;
+// requests in flight count as not failed.
+func (s requestSnapshot) ErrorRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Failures.Failed()) / float64(s.Total)
+}
+
+// AverageLatency is the mean latency of successful requests in
+// nanoseconds.
+func (s requestSnapshot) AverageLatency() int64 {
+	if s.Success == 0 {
+		return 0
+	}
+	return s.LatencyNs / s.Success
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+)
+
+func TestGetMetricsConsistentUnderLoad(t *testing.T) {
+	c := startClientFor(t, &pickyEchoServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	const workers, perWorker = 8, 40
+	var callers sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		callers.Add(1)
+		go func() {
+			defer callers.Done()
+			for i := 0; i < perWorker; i++ {
+				ctx, text := context.Background(), "ok"
+				switch i % 3 {
+				case 1:
+					text = "fail"
+				case 2:
+					ctx = cancelled
+				}
+				_, _ = c.Infer(ctx, types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed(text).Build())
+			}
+		}()
+	}
+
+	var done atomic.Bool
+	var polls int
+	go func() {
+		callers.Wait()
+		done.Store(true)
+	}()
+	for !done.Load() || polls == 0 {
+		polls++
+		m := c.GetMetrics()
+		if outcomes := m.SuccessRequests + m.FailedRequests + m.CancelledRequests; outcomes > m.TotalRequests {
+			t.Fatalf("poll %d: %d successes + %d failures + %d cancellations > %d requests",
+				polls, m.SuccessRequests, m.FailedRequests, m.CancelledRequests, m.TotalRequests)
+		}
+		if m.ErrorRate < 0 || m.ErrorRate > 1 {
+			t.Fatalf("poll %d: error rate %v", polls, m.ErrorRate)
+		}
+		if m.FailedRequests != m.Failures.Failed() {
+			t.Fatalf("poll %d: failed %d, but failures %+v", polls, m.FailedRequests, m.Failures)
+		}
+	}
+
+	m := c.GetMetrics()
+	const total = workers * perWorker
+	if m.TotalRequests != total || m.SuccessRequests+m.FailedRequests+m.CancelledRequests != total {
+		t.Fatalf("final metrics = %d total, %d ok, %d failed, %d cancelled; want %d in all",
+			m.TotalRequests, m.SuccessRequests, m.FailedRequests, m.CancelledRequests, total)
+	}
+	if m.CancelledRequests == 0 || m.FailedRequests == 0 || m.SuccessRequests == 0 {
+		t.Fatalf("final metrics = %+v, want every outcome represented", m)
+	}
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// SelectionHints describes the request being routed, for NodeScorers.
+type SelectionHints struct {
+	// PayloadBytes is the size of the request payload, before chunking.
+	PayloadBytes int
+	Tenant       string
+	// Meta is the request Meta; it is shared and must not be modified.
+	Meta map[string]string
+	// CorrelationID is the request's, or "" when it has none.
+	CorrelationID string
+}
+
+// NodeScorer ranks the nodes eligible for a request under the custom
+// selection strategy (pool.strategy: custom). Higher is better; a negative
+// score excludes the node, whatever its weight. The request goes to the
+// node with the highest sum of weighted scores (pool.score_weights).
+//
+// Score runs inside the gRPC picker for every eligible node on every
+// request, so it must be fast and must not call back into the client. node
+// is a snapshot taken when the node last changed, with InFlight current; it
+// must not be modified.
+type NodeScorer interface {
+	Score(ctx context.Context, node *discovery.NodeInfo, task string, hints SelectionHints) float64
+}
+
+// NodeScorerFunc adapts a function to NodeScorer.
+type NodeScorerFunc func(ctx context.Context, node *discovery.NodeInfo, task string, hints SelectionHints) float64
+
+func (f NodeScorerFunc) Score(ctx context.Context, node *discovery.NodeInfo, task string, hints SelectionHints) float64 {
+	return f(ctx, node, task, hints)
+}
+
+// scorerExcludedAll is the error, formatted with the task, of a request for
+// which the scorer excluded every eligible node.
+const scorerExcludedAll = "node scorer excluded every node for task %q"
+
+type selectionHintsKey struct{}
+
+// withSelectionHints attaches hints describing req to ctx for the picker.
+func withSelectionHints(ctx context.Context, req *pb.InferRequest) context.Context {
+	return context.WithValue(ctx, selectionHintsKey{}, SelectionHints{
+		PayloadBytes:  len(req.Payload),
+		Tenant:        TenantFromContext(ctx),
+		Meta:          req.Meta,
+		CorrelationID: req.CorrelationId,
+	})
+}
+
+func selectionHintsFromContext(ctx context.Context) SelectionHints {
+	h, _ := ctx.Value(selectionHintsKey{}).(SelectionHints)
+	return h
+}
+
+// scoring is the custom strategy's policy: the weights of each score, the
+// registered scorer, and how ties are broken.
+type scoring struct {
+	custom, load, latency float64
+	randomTies            bool
+	scorer                atomic.Pointer[NodeScorer]
+}
+
+func newScoring(weights map[string]float64, randomTies bool) *scoring {
+	s := &scoring{randomTies: randomTies}
+	if len(weights) == 0 {
+		s.custom = 1
+	} else {
+		s.custom = weights[config.ScoreCustom]
+		s.load = weights[config.ScoreLoad]
+		s.latency = weights[config.ScoreLatency]
+	}
+	return s
+}
+
+// setScorer registers scorer; nil removes it, leaving the built-in scores.
+func (s *scoring) setScorer(scorer NodeScorer) {
+	if scorer == nil {
+		s.scorer.Store(nil)
+		return
+	}
+	s.scorer.Store(&scorer)
+}
+
+// score returns node's combined score, or false if the scorer excludes it.
+func (s *scoring) score(ctx context.Context, node *discovery.NodeInfo, task string, hints SelectionHints, latency LatencyStats) (float64, bool) {
+	var total float64
+	if p := s.scorer.Load(); p != nil {
+		custom := (*p).Score(ctx, node, task, hints)
+		if custom < 0 || math.IsNaN(custom) {
+			return 0, false
+		}
+		total += s.custom * custom
+	}
+	// A node pushing its own load is scored down by it as well.
+	total += s.load * (1 - node.Load) / float64(1+node.InFlight)
+	if latency.Count > 0 {
+		total += s.latency / (1 + latency.P50.Seconds())
+	} else {
+		total += s.latency
+	}
+	return total, true
+}
+
+// best returns the highest-scoring of candidates, which are sorted by node
+// ID, and the IDs of those the scorer excluded. Ties go to the first unless
+// random tie-breaking is on. picked is nil when every candidate is excluded.
+func (s *scoring) best(ctx context.Context, task string, candidates []*subConnState, info func(*subConnState) *discovery.NodeInfo, latency func(string) LatencyStats, random bool) (picked *subConnState, excluded []string) {
+	hints := selectionHintsFromContext(ctx)
+	top := math.Inf(-1)
+	var ties int
+	for _, scs := range candidates {
+		key := scs.identity.Key()
+		score, ok := s.score(ctx, info(scs), task, hints, latency(key))
+		switch {
+		case !ok:
+			excluded = append(excluded, key)
+		case score > top:
+			top, picked, ties = score, scs, 1
+		case score == top:
+			// Reservoir sampling keeps each tied node equally likely.
+			ties++
+			if random && rand.IntN(ties) == 0 {
+				picked = scs
+			}
+		}
+	}
+	return picked, excluded
+}
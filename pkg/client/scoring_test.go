@@ -0,0 +1,170 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+)
+
+// scoredFixture returns a registry whose picker scores the Ready ocr nodes
+// named by names under s, with each node's snapshot in picker.infos.
+func scoredFixture(s *scoring, names ...string) (*nodeRegistry, *lumenPicker) {
+	var nodes []*subConnState
+	for _, name := range names {
+		nodes = append(nodes, &subConnState{
+			sc:       &namedSubConn{name: "local-" + name},
+			identity: discovery.NewNodeIdentity("local", name),
+			state:    connectivity.Ready,
+			tasks:    []string{"ocr"},
+			streams:  &nodeStreams{},
+		})
+	}
+	reg := explainFixture(nodes...)
+	picker := reg.picker.Load()
+	picker.strategies = newStrategies(config.StrategyCustom, s)
+	picker.infos = make(map[string]*discovery.NodeInfo)
+	for _, scs := range nodes {
+		key := scs.identity.Key()
+		picker.infos[key] = &discovery.NodeInfo{ID: key, Runtime: "rt-" + key}
+	}
+	return reg, picker
+}
+
+// scoreTable scores nodes by ID; unlisted nodes score 0.
+func scoreTable(scores map[string]float64) NodeScorer {
+	return NodeScorerFunc(func(_ context.Context, node *discovery.NodeInfo, _ string, _ SelectionHints) float64 {
+		return scores[node.ID]
+	})
+}
+
+func pickName(t *testing.T, picker *lumenPicker, ctx context.Context) string {
+	t.Helper()
+	res, err := picker.Pick(balancer.PickInfo{Ctx: WithTask(ctx, "ocr")})
+	if err != nil {
+		t.Fatalf("Pick: %v", err)
+	}
+	return res.SubConn.(*namedSubConn).name
+}
+
+func TestScoredPickExcludesNegativeScores(t *testing.T) {
+	s := newScoring(nil, false)
+	reg, picker := scoredFixture(s, "a", "b", "c")
+	var decisions []discovery.SelectionDecision
+	reg.onSelection = func(d discovery.SelectionDecision) { decisions = append(decisions, d) }
+
+	s.setScorer(scoreTable(map[string]float64{"local-a": 5, "local-b": -1, "local-c": 3}))
+	for i := 0; i < 3; i++ {
+		if name := pickName(t, picker, context.Background()); name != "local-a" {
+			t.Fatalf("picked %s, want the highest score", name)
+		}
+	}
+	if d := decisions[0]; d.Strategy != config.StrategyCustom || d.Candidates != 3 {
+		t.Fatalf("decision = %+v, want the custom strategy over 3 candidates", d)
+	}
+
+	// The best node excluded, the next best is picked.
+	s.setScorer(scoreTable(map[string]float64{"local-a": -5, "local-b": -1, "local-c": 3}))
+	if name := pickName(t, picker, context.Background()); name != "local-c" {
+		t.Fatalf("picked %s, want local-c", name)
+	}
+	exp := reg.explainSelection("ocr", time.Now())
+	if exp.Pick != "local-c" || exp.Strategy != config.StrategyCustom {
+		t.Fatalf("explained pick %q strategy %q, want local-c under custom", exp.Pick, exp.Strategy)
+	}
+	for _, id := range []string{"local-a", "local-b"} {
+		if c := candidateByID(exp, id); c.Eligible || c.Reason != discovery.SelectionExcluded {
+			t.Fatalf("explained %s = %+v, want excluded", id, c)
+		}
+	}
+
+	s.setScorer(scoreTable(map[string]float64{"local-a": -1, "local-b": -1, "local-c": -1}))
+	var noNode atomic.Bool
+	_, err := picker.Pick(balancer.PickInfo{Ctx: withNoNodeFlag(WithTask(context.Background(), "ocr"), &noNode)})
+	if err == nil || !strings.Contains(err.Error(), "excluded every node") {
+		t.Fatalf("Pick with every node excluded = %v", err)
+	}
+	if !noNode.Load() {
+		t.Fatal("Pick with every node excluded did not allow local fallback")
+	}
+	if exp := reg.explainSelection("ocr", time.Now()); exp.Pick != "" || !strings.Contains(exp.Error, "excluded every node") {
+		t.Fatalf("explained = pick %q error %q", exp.Pick, exp.Error)
+	}
+}
+
+func TestScoreWeightsCombineBuiltinScores(t *testing.T) {
+	s := newScoring(map[string]float64{config.ScoreCustom: 1, config.ScoreLoad: 10}, false)
+	_, picker := scoredFixture(s, "a", "b")
+	s.setScorer(scoreTable(map[string]float64{"local-a": 2, "local-b": 1}))
+
+	// a: 2 + 10/(1+4) = 4; b: 1 + 10/1 = 11.
+	picker.ready[0].streams.open.Store(4)
+	if name := pickName(t, picker, context.Background()); name != "local-b" {
+		t.Fatalf("picked %s, want the idle node once load outweighs the custom score", name)
+	}
+
+	// Without a load weight the custom score decides.
+	s.load = 0
+	if name := pickName(t, picker, context.Background()); name != "local-a" {
+		t.Fatalf("picked %s, want the higher custom score", name)
+	}
+}
+
+func TestLoadScoreCountsPushedLoad(t *testing.T) {
+	s := newScoring(map[string]float64{config.ScoreLoad: 1}, false)
+	_, picker := scoredFixture(s, "a", "b")
+
+	// a reports itself 90% busy; b reports nothing.
+	picker.infos["local-a"].Load = 0.9
+	if name := pickName(t, picker, context.Background()); name != "local-b" {
+		t.Fatalf("picked %s, want the node not reporting load", name)
+	}
+}
+
+func TestScorerSeesNodeAndHints(t *testing.T) {
+	s := newScoring(nil, false)
+	_, picker := scoredFixture(s, "a")
+	picker.ready[0].streams.open.Store(2)
+
+	var got *discovery.NodeInfo
+	var hints SelectionHints
+	s.setScorer(NodeScorerFunc(func(_ context.Context, node *discovery.NodeInfo, task string, h SelectionHints) float64 {
+		got, hints = node, h
+		return 1
+	}))
+	req := &pb.InferRequest{Task: "ocr", Payload: make([]byte, 300), Meta: map[string]string{"k": "v"}}
+	pickName(t, picker, withSelectionHints(WithTenant(context.Background(), "team-a"), req))
+	if got.ID != "local-a" || got.Runtime != "rt-local-a" || got.InFlight != 2 {
+		t.Fatalf("scorer saw node %+v, want the snapshot with 2 in flight", got)
+	}
+	if hints.PayloadBytes != 300 || hints.Tenant != "team-a" || hints.Meta["k"] != "v" {
+		t.Fatalf("scorer saw hints %+v", hints)
+	}
+}
+
+func TestScoredTieBreak(t *testing.T) {
+	s := newScoring(nil, false)
+	_, picker := scoredFixture(s, "a", "b", "c")
+	s.setScorer(scoreTable(map[string]float64{"local-a": 1, "local-b": 2, "local-c": 2}))
+	for i := 0; i < 20; i++ {
+		if name := pickName(t, picker, context.Background()); name != "local-b" {
+			t.Fatalf("picked %s, want ties broken by node ID", name)
+		}
+	}
+
+	s.randomTies = true
+	seen := map[string]int{}
+	for i := 0; i < 200; i++ {
+		seen[pickName(t, picker, context.Background())]++
+	}
+	if seen["local-a"] != 0 || seen["local-b"] == 0 || seen["local-c"] == 0 {
+		t.Fatalf("random tie-break picks = %v, want both tied nodes and never the lower score", seen)
+	}
+}
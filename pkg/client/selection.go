@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+)
+
+// taskCursor is one task's round-robin position: the number of picks made
+// for it. Each task rotates on its own, so a busy task cannot leave a
+// quieter one that shares its nodes landing on the same node every time.
+type taskCursor struct {
+	picks    uint64
+	lastPick time.Time
+}
+
+// explainSelection replays the current picker's filtering for task and
+// reports every known node with the reason it was passed over, plus the node
+// the next Pick would return. It does not advance the round-robin index.
+// Under the custom strategy it scores the nodes without request hints, and
+// reports the first of tied nodes even when ties are broken at random.
+func (r *nodeRegistry) explainSelection(task string, now time.Time) *discovery.SelectionExplanation {
+	return r.explainStrategy(task, "", now)
+}
+
+// explainStrategy is explainSelection for a request routed through
+// strategy, as set with WithStrategy; "" is the configured one.
+func (r *nodeRegistry) explainStrategy(task, strategy string, now time.Time) *discovery.SelectionExplanation {
+	exp := &discovery.SelectionExplanation{Task: task, At: now, Strategy: config.StrategyRoundRobin}
+
+	eligible := make(map[string]bool)
+	excluded := make(map[string]bool)
+	draining := r.drainingNodes()
+	picker := r.picker.Load()
+	requireConfirmed := picker != nil && picker.balancer != nil && picker.balancer.options.requireConfirmed
+	var scoring *scoring
+	if picker != nil {
+		if strategy == "" {
+			strategy = picker.strategies.name()
+		}
+		exp.Strategy = strategy
+		var ok bool
+		if scoring, ok = picker.strategies.scoring(strategy); !ok {
+			exp.Error = fmt.Sprintf("unknown selection strategy %q", strategy)
+			picker = nil
+		}
+	} else {
+		exp.Error = balancer.ErrNoSubConnAvailable.Error()
+	}
+	if picker != nil {
+		candidates, probe := picker.candidates(task, now, draining)
+		candidates = r.outliers.filter(candidates, now, false)
+		for _, scs := range candidates {
+			eligible[scs.identity.Key()] = true
+		}
+		switch {
+		case len(candidates) > 0 && scoring != nil:
+			picked, out := scoring.best(context.Background(), task, candidates, picker.nodeInfo, picker.nodeLatency, false)
+			for _, key := range out {
+				eligible[key] = false
+				excluded[key] = true
+			}
+			if picked != nil {
+				exp.Pick = picked.identity.Key()
+				exp.Probe = probe
+			} else {
+				exp.Error = fmt.Sprintf(scorerExcludedAll, task)
+			}
+		case len(candidates) > 0:
+			exp.Pick = candidates[r.peekCursor(task, len(candidates))].identity.Key()
+			exp.Probe = probe
+		default:
+			exp.Error = picker.noCandidateErr(task, now, draining).Error()
+		}
+	}
+
+	r.mu.RLock()
+	for key, rn := range r.nodes {
+		c := discovery.SelectionCandidate{
+			NodeID:      key,
+			Address:     rn.addr,
+			State:       rn.state.String(),
+			Eligible:    eligible[key],
+			Quarantined: rn.probeFailures >= quarantineThreshold,
+		}
+		if !rn.cooldownUntil.IsZero() && now.Before(rn.cooldownUntil) {
+			c.CooldownUntil = rn.cooldownUntil
+		}
+		if excluded[key] {
+			c.Reason = discovery.SelectionExcluded
+		} else if !c.Eligible {
+			c.Reason = rejectReason(rn.taskSet.has(task), rn.cooldownUntil, rn.state != connectivity.Ready && !rn.held, now)
+			if rn.held && c.Reason == "" {
+				c.Reason = discovery.SelectionUnhealthy
+			}
+			if _, ok := draining[key]; ok && c.Reason == "" {
+				c.Reason = discovery.SelectionDraining
+			}
+			if r.outliers.ejected(key, now) && c.Reason == "" {
+				c.Reason = discovery.SelectionEjected
+			}
+			if requireConfirmed && len(rn.capabilities) == 0 && c.Reason == "" {
+				c.Reason = discovery.SelectionProvisional
+			}
+			if c.Reason == "" {
+				// Passes the filters but was not offered: either a probe
+				// while Ready nodes qualify, or state changed since the
+				// picker was built.
+				c.Reason = discovery.SelectionNotReady
+				if rn.state != connectivity.Ready && exp.Pick != "" && !exp.Probe {
+					c.Reason = discovery.SelectionProbeSkipped
+				}
+			}
+		}
+		switch c.Reason {
+		case discovery.SelectionNotReady, discovery.SelectionCoolingDown, discovery.SelectionUnhealthy:
+			c.RecentErrors = r.recent.get(key, explainErrorsLen)
+		}
+		exp.Candidates = append(exp.Candidates, c)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(exp.Candidates, func(i, j int) bool {
+		return exp.Candidates[i].NodeID < exp.Candidates[j].NodeID
+	})
+
+	r.pickMu.Lock()
+	if d, ok := r.lastPicks[task]; ok {
+		exp.LastPick = &d
+	}
+	r.pickMu.Unlock()
+	return exp
+}
+
+// recordSelection keeps d as the latest decision for its task, counts it
+// under its strategy and passes it to onSelection.
+func (r *nodeRegistry) recordSelection(d discovery.SelectionDecision) {
+	r.pickMu.Lock()
+	if r.lastPicks == nil {
+		r.lastPicks = make(map[string]discovery.SelectionDecision)
+	}
+	if r.selections == nil {
+		r.selections = make(map[string]int64)
+	}
+	r.lastPicks[d.Task] = d
+	r.selections[d.Strategy]++
+	r.pickMu.Unlock()
+	if r.onSelection != nil {
+		r.onSelection(d)
+	}
+}
+
+// advanceCursor returns the index among n candidates task's next pick takes
+// and moves its rotation on. Cursors of tasks nobody uses any more are
+// dropped by the client's TaskStateRegistry through forgetTask.
+func (r *nodeRegistry) advanceCursor(task string, n int, now time.Time) int {
+	r.pickMu.Lock()
+	defer r.pickMu.Unlock()
+	if r.cursors == nil {
+		r.cursors = make(map[string]*taskCursor)
+	}
+	c := r.cursors[task]
+	if c == nil {
+		c = &taskCursor{}
+		r.cursors[task] = c
+	}
+	c.picks++
+	c.lastPick = now
+	return int(c.picks % uint64(n))
+}
+
+// peekCursor returns the index advanceCursor would return next for task
+// without moving its rotation.
+func (r *nodeRegistry) peekCursor(task string, n int) int {
+	r.pickMu.Lock()
+	defer r.pickMu.Unlock()
+	var picks uint64
+	if c := r.cursors[task]; c != nil {
+		picks = c.picks
+	}
+	return int((picks + 1) % uint64(n))
+}
+
+// selectionTasks returns the tasks holding a round-robin cursor or a last
+// routing decision.
+func (r *nodeRegistry) selectionTasks() []string {
+	r.pickMu.Lock()
+	defer r.pickMu.Unlock()
+	tasks := make([]string, 0, len(r.cursors)+len(r.lastPicks))
+	for task := range r.cursors {
+		tasks = append(tasks, task)
+	}
+	for task := range r.lastPicks {
+		if _, ok := r.cursors[task]; !ok {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// selectionCounts returns the picks made per strategy, nil before any.
+func (r *nodeRegistry) selectionCounts() map[string]int64 {
+	r.pickMu.Lock()
+	defer r.pickMu.Unlock()
+	if len(r.selections) == 0 {
+		return nil
+	}
+	return maps.Clone(r.selections)
+}
+
+// forgetTask drops task's round-robin cursor and last routing decision.
+func (r *nodeRegistry) forgetTask(task string) {
+	r.pickMu.Lock()
+	defer r.pickMu.Unlock()
+	delete(r.cursors, task)
+	delete(r.lastPicks, task)
+}
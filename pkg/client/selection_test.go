@@ -0,0 +1,256 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+)
+
+// explainFixture builds a registry and picker the way rebuildPickerLocked
+// and syncRegistryLocked would for the given SubConn states.
+func explainFixture(nodes ...*subConnState) *nodeRegistry {
+	reg := &nodeRegistry{nodes: make(map[string]*registeredNode)}
+	picker := &lumenPicker{balancer: &lumenBalancer{registry: reg}}
+	now := time.Now()
+	for _, scs := range nodes {
+		scs.taskSet = newTaskSet(scs.tasks)
+		reg.nodes[scs.identity.Key()] = &registeredNode{
+			identity:      scs.identity,
+			state:         scs.state,
+			tasks:         scs.tasks,
+			taskSet:       scs.taskSet,
+			cooldownUntil: scs.cooldownUntil,
+			probeFailures: scs.probeFailures,
+		}
+		switch {
+		case scs.state == connectivity.Ready:
+			if scs.cooldownUntil.IsZero() || now.After(scs.cooldownUntil) {
+				picker.ready = append(picker.ready, scs)
+			}
+		case !scs.cooldownUntil.IsZero() && now.After(scs.cooldownUntil):
+			picker.probes = append(picker.probes, scs)
+		}
+	}
+	reg.picker.Store(picker)
+	return reg
+}
+
+func candidateByID(exp *discovery.SelectionExplanation, id string) discovery.SelectionCandidate {
+	for _, c := range exp.Candidates {
+		if c.NodeID == id {
+			return c
+		}
+	}
+	return discovery.SelectionCandidate{}
+}
+
+func TestExplainSelectionReasons(t *testing.T) {
+	now := time.Now()
+	id := func(n string) discovery.NodeIdentity { return discovery.NewNodeIdentity("local", n) }
+	reg := explainFixture(
+		&subConnState{identity: id("ready-ocr"), state: connectivity.Ready, tasks: []string{"ocr"}},
+		&subConnState{identity: id("ready-embed"), state: connectivity.Ready, tasks: []string{"embed"}},
+		&subConnState{identity: id("cooling"), state: connectivity.Ready, tasks: []string{"ocr"}, cooldownUntil: now.Add(time.Hour)},
+		&subConnState{identity: id("probe"), state: connectivity.TransientFailure, tasks: []string{"ocr"}, cooldownUntil: now.Add(-time.Second)},
+		&subConnState{identity: id("connecting"), state: connectivity.Connecting, tasks: []string{"ocr"}, probeFailures: quarantineThreshold},
+	)
+
+	exp := reg.explainSelection("ocr", now)
+	if exp.Pick != "local-ready-ocr" || exp.Probe || exp.Error != "" || exp.Strategy != config.StrategyRoundRobin {
+		t.Fatalf("pick = %q probe = %v error = %q", exp.Pick, exp.Probe, exp.Error)
+	}
+	if len(exp.Candidates) != 5 || exp.Candidates[0].NodeID != "local-connecting" {
+		t.Fatalf("candidates = %+v, want all 5 sorted by ID", exp.Candidates)
+	}
+	want := map[string]discovery.SelectionReason{
+		"local-ready-ocr":   "",
+		"local-ready-embed": discovery.SelectionUnsupportedTask,
+		"local-cooling":     discovery.SelectionCoolingDown,
+		"local-probe":       discovery.SelectionProbeSkipped,
+		"local-connecting":  discovery.SelectionNotReady,
+	}
+	for id, reason := range want {
+		c := candidateByID(exp, id)
+		if c.Reason != reason || c.Eligible != (reason == "") {
+			t.Errorf("%s: eligible = %v reason = %q, want reason %q", id, c.Eligible, c.Reason, reason)
+		}
+	}
+	if c := candidateByID(exp, "local-cooling"); c.CooldownUntil.IsZero() {
+		t.Error("cooling node has no cooldown_until")
+	}
+	if !candidateByID(exp, "local-connecting").Quarantined {
+		t.Error("quarantined node not reported")
+	}
+}
+
+func TestExplainSelectionFallsBackToProbe(t *testing.T) {
+	now := time.Now()
+	reg := explainFixture(
+		&subConnState{identity: discovery.NewNodeIdentity("local", "a"), state: connectivity.TransientFailure, tasks: []string{"ocr"}, cooldownUntil: now.Add(-time.Second)},
+	)
+	exp := reg.explainSelection("ocr", now)
+	if exp.Pick != "local-a" || !exp.Probe {
+		t.Fatalf("pick = %q probe = %v, want probe of local-a", exp.Pick, exp.Probe)
+	}
+
+	exp = reg.explainSelection("embed", now)
+	if exp.Pick != "" || exp.Error == "" {
+		t.Fatalf("pick = %q error = %q, want no pick and an error", exp.Pick, exp.Error)
+	}
+}
+
+// namedSubConn tells picks apart in tests; its methods are never called.
+type namedSubConn struct {
+	balancer.SubConn
+	name string
+}
+
+// TestExplainSelectionMatchesPick checks the explained pick is the node the
+// next Pick returns, and that explaining does not advance the rotation.
+func TestExplainSelectionMatchesPick(t *testing.T) {
+	var nodes []*subConnState
+	for _, name := range []string{"a", "b", "c"} {
+		nodes = append(nodes, &subConnState{
+			sc:       &namedSubConn{name: "local-" + name},
+			identity: discovery.NewNodeIdentity("local", name),
+			state:    connectivity.Ready,
+			tasks:    []string{"ocr"},
+		})
+	}
+	reg := explainFixture(nodes...)
+	picker := reg.picker.Load()
+	info := balancer.PickInfo{Ctx: WithTask(context.Background(), "ocr")}
+	for i := 0; i < 6; i++ {
+		explained := reg.explainSelection("ocr", time.Now()).Pick
+		if again := reg.explainSelection("ocr", time.Now()).Pick; again != explained {
+			t.Fatalf("explain advanced the rotation: %q then %q", explained, again)
+		}
+		res, err := picker.Pick(info)
+		if err != nil {
+			t.Fatalf("Pick: %v", err)
+		}
+		if picked := res.SubConn.(*namedSubConn).name; picked != explained {
+			t.Fatalf("round %d: explained %q, picked %q", i, explained, picked)
+		}
+	}
+}
+
+func TestPickReportsDecisions(t *testing.T) {
+	var nodes []*subConnState
+	for _, name := range []string{"a", "b", "c"} {
+		nodes = append(nodes, &subConnState{
+			sc:       &namedSubConn{name: "local-" + name},
+			identity: discovery.NewNodeIdentity("local", name),
+			state:    connectivity.Ready,
+			tasks:    []string{"ocr"},
+		})
+	}
+	reg := explainFixture(nodes...)
+	var got []discovery.SelectionDecision
+	reg.onSelection = func(d discovery.SelectionDecision) { got = append(got, d) }
+	picker := reg.picker.Load()
+	picker.balancer = &lumenBalancer{registry: reg}
+
+	info := balancer.PickInfo{Ctx: WithTask(context.Background(), "ocr")}
+	for i := 0; i < 4; i++ {
+		if _, err := picker.Pick(info); err != nil {
+			t.Fatalf("Pick: %v", err)
+		}
+	}
+	pinned := balancer.PickInfo{Ctx: withPinnedNode(info.Ctx, "local-a")}
+	if _, err := picker.Pick(pinned); err != nil {
+		t.Fatalf("pinned Pick: %v", err)
+	}
+
+	want := []string{"local-b", "local-c", "local-a", "local-b", "local-a"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d decisions, want %d", len(got), len(want))
+	}
+	for i, d := range got {
+		if d.NodeID != want[i] || d.Task != "ocr" || d.Strategy != config.StrategyRoundRobin || d.Candidates != 3 || d.Pinned != (i == 4) {
+			t.Errorf("decision %d = %+v, want %s of 3 candidates", i, d, want[i])
+		}
+	}
+	if last := reg.explainSelection("ocr", time.Now()).LastPick; last == nil || *last != got[4] {
+		t.Fatalf("explained last pick = %+v, want %+v", last, got[4])
+	}
+	if last := reg.explainSelection("embed", time.Now()).LastPick; last != nil {
+		t.Fatalf("last pick for an unrouted task = %+v", last)
+	}
+}
+
+func TestPoolExplainSelectionBeforeConnect(t *testing.T) {
+	c := &LumenClient{pool: NewPool(nil)}
+	if _, err := c.ExplainSelection(context.Background(), "ocr"); err == nil {
+		t.Fatal("expected an error before the pool connects")
+	}
+}
+
+// TestRoundRobinIsPerTask interleaves two tasks over three nodes serving
+// both, embed picking twice for each ocr pick. With one shared index every
+// ocr pick would land on the same node; each task must cycle through all
+// three.
+func TestRoundRobinIsPerTask(t *testing.T) {
+	var nodes []*subConnState
+	for _, name := range []string{"a", "b", "c"} {
+		nodes = append(nodes, &subConnState{
+			sc:       &namedSubConn{name: "local-" + name},
+			identity: discovery.NewNodeIdentity("local", name),
+			state:    connectivity.Ready,
+			tasks:    []string{"ocr", "embed"},
+		})
+	}
+	reg := explainFixture(nodes...)
+	picker := reg.picker.Load()
+
+	seen := map[string][]string{}
+	for i := 0; i < 6; i++ {
+		for _, task := range []string{"ocr", "embed", "embed"} {
+			res, err := picker.Pick(balancer.PickInfo{Ctx: WithTask(context.Background(), task)})
+			if err != nil {
+				t.Fatalf("Pick(%s): %v", task, err)
+			}
+			seen[task] = append(seen[task], res.SubConn.(*namedSubConn).name)
+		}
+	}
+	for task, picks := range seen {
+		for i := 0; i < len(picks); i += 3 {
+			round := map[string]bool{picks[i]: true, picks[i+1]: true, picks[i+2]: true}
+			if len(round) != 3 {
+				t.Errorf("%s picks %v: round %d does not visit every node", task, picks, i/3)
+			}
+		}
+	}
+}
+
+func TestForgetTaskDropsRoutingState(t *testing.T) {
+	reg := &nodeRegistry{}
+	now := time.Now()
+	if got := reg.advanceCursor("ocr", 3, now); got != 1 {
+		t.Fatalf("first pick index = %d, want 1", got)
+	}
+	reg.advanceCursor("embed", 3, now)
+	reg.recordSelection(discovery.SelectionDecision{Task: "embed"})
+	if got := reg.peekCursor("ocr", 3); got != 2 {
+		t.Fatalf("peek = %d, want 2", got)
+	}
+	if got := reg.advanceCursor("ocr", 3, now.Add(time.Minute)); got != 2 {
+		t.Fatalf("second pick index = %d, want 2", got)
+	}
+
+	reg.forgetTask("embed")
+	if _, ok := reg.cursors["embed"]; ok {
+		t.Fatal("forgotten task kept its cursor")
+	}
+	if _, ok := reg.lastPicks["embed"]; ok {
+		t.Fatal("forgotten task kept its last routing decision")
+	}
+	if got := reg.selectionTasks(); len(got) != 1 || got[0] != "ocr" {
+		t.Fatalf("selection tasks = %v, want [ocr]", got)
+	}
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrClientClosed is returned by calls made once Close has started.
+var ErrClientClosed = fmt.Errorf("lumen client closed")
+
+// closeDrainTimeout bounds how long Close waits for in-flight requests
+// before closing the pool under them.
+const closeDrainTimeout = 10 * time.Second
+
+// requestGate counts the requests in flight and, once closed, turns new
+// ones away so Close can wait for the rest to finish.
+type requestGate struct {
+	mu     sync.Mutex
+	closed bool
+	active int
+	idle   chan struct{} // closed once the gate is closed and active is 0
+}
+
+// enter admits a request, reporting false once the gate is closed. Every
+// admitted request must call leave.
+func (g *requestGate) enter() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	g.active++
+	return true
+}
+
+func (g *requestGate) leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active--
+	if g.closed && g.active == 0 {
+		close(g.idle)
+	}
+}
+
+// close turns new requests away. The returned channel is closed once the
+// requests already admitted have left.
+func (g *requestGate) close() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.closed {
+		g.closed = true
+		g.idle = make(chan struct{})
+		if g.active == 0 {
+			close(g.idle)
+		}
+	}
+	return g.idle
+}
+
+func (g *requestGate) inFlight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.active
+}
+
+// shutdown stops the client in order: stop accepting requests, drain the
+// ones in flight, close the pool, which stops discovery before the balancer
+// and connections, then stop the background loops. Each step is logged.
+func (c *LumenClient) shutdown() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The queue dispatcher goes first: its attempts would otherwise fail
+	// against the closed gate rather than stay queued for the next start.
+	if c.stopQueue != nil {
+		c.stopQueue()
+		<-c.queueDone
+	}
+	idle := c.requests.close()
+	c.logger.Debug("lumen client closing: no longer accepting requests")
+
+	timer := time.NewTimer(closeDrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		c.logger.Debug("in-flight requests drained")
+	case <-timer.C:
+		c.logger.Warn("in-flight requests still running after the drain timeout; closing the pool under them",
+			zap.Int("in_flight", c.requests.inFlight()),
+			zap.Duration("timeout", closeDrainTimeout))
+	}
+
+	err := c.pool.Close()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.logger.Debug("background loops stopped")
+	c.logger.Info("lumen client closed")
+	return err
+}
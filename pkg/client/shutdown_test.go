@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"google.golang.org/grpc"
+)
+
+// heldInferServer answers each Infer once release is closed, reporting
+// every request it receives on started.
+type heldInferServer struct {
+	testInferenceServer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *heldInferServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	s.started <- struct{}{}
+	select {
+	case <-s.release:
+	case <-stream.Context().Done():
+		return stream.Context().Err()
+	}
+	return stream.Send(&pb.InferResponse{CorrelationId: req.CorrelationId, IsFinal: true, Result: req.Payload})
+}
+
+func TestConcurrentCloseDrainsInFlightRequests(t *testing.T) {
+	const inFlight, closers = 6, 4
+	srv := &heldInferServer{
+		testInferenceServer: testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}},
+		started:             make(chan struct{}, inFlight),
+		release:             make(chan struct{}),
+	}
+	c := startClientFor(t, srv)
+	newReq := func() *pb.InferRequest {
+		return types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hi").Build()
+	}
+
+	inferErrs := make(chan error, inFlight)
+	for i := 0; i < inFlight; i++ {
+		go func() {
+			_, err := c.Infer(context.Background(), newReq())
+			inferErrs <- err
+		}()
+	}
+	for i := 0; i < inFlight; i++ {
+		select {
+		case <-srv.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d requests reached the server", i, inFlight)
+		}
+	}
+
+	var closing sync.WaitGroup
+	closeErrs := make(chan error, closers)
+	for i := 0; i < closers; i++ {
+		closing.Add(1)
+		go func() {
+			defer closing.Done()
+			closeErrs <- c.Close()
+		}()
+	}
+	waitUntil(t, func() bool {
+		c.requests.mu.Lock()
+		defer c.requests.mu.Unlock()
+		return c.requests.closed
+	})
+	if _, err := c.Infer(context.Background(), newReq()); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("Infer while closing = %v, want ErrClientClosed", err)
+	}
+	if _, err := c.InferStream(context.Background(), newReq()); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("InferStream while closing = %v, want ErrClientClosed", err)
+	}
+	select {
+	case err := <-closeErrs:
+		t.Fatalf("Close returned (%v) with requests still in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(srv.release)
+	for i := 0; i < inFlight; i++ {
+		if err := <-inferErrs; err != nil {
+			t.Fatalf("in-flight request cut off by Close: %v", err)
+		}
+	}
+	closing.Wait()
+	for i := 0; i < closers; i++ {
+		if err := <-closeErrs; err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close after Close: %v", err)
+	}
+	if c.pool.Client() != nil {
+		t.Fatal("pool still connected after Close")
+	}
+}
@@ -0,0 +1,213 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Service time distributions.
+const (
+	// DistFixed takes Mean for every request.
+	DistFixed = "fixed"
+	// DistExponential draws from an exponential distribution with Mean.
+	DistExponential = "exponential"
+	// DistLognormal draws from a lognormal distribution with Median and P95.
+	DistLognormal = "lognormal"
+	// DistObserved replays the latency the trace recorded for the request,
+	// times Scale.
+	DistObserved = "observed"
+)
+
+// Cluster describes the nodes a simulation routes traffic to.
+type Cluster struct {
+	// Pool is the pool configuration the balancer selects with: strategy,
+	// score_weights and random_tie_break. Unset fields keep their defaults.
+	Pool config.PoolConfig `yaml:"pool"`
+	// Seed seeds the service time draws, so a run can be repeated.
+	Seed  uint64     `yaml:"seed"`
+	Nodes []NodeSpec `yaml:"nodes"`
+}
+
+// NodeSpec describes a node, or Count identical nodes.
+type NodeSpec struct {
+	// ID names the node. With a Count above one the nodes are named ID-1,
+	// ID-2 and so on.
+	ID    string `yaml:"id"`
+	Count int    `yaml:"count"`
+	// Tasks are the tasks the node serves.
+	Tasks []string `yaml:"tasks"`
+	// Txt are the node's discovery hints, e.g. runtime.
+	Txt map[string]string `yaml:"txt"`
+	// Concurrency is how many requests the node serves at once; requests
+	// beyond it wait in the node's queue. Zero means one.
+	Concurrency int `yaml:"concurrency"`
+	// MaxQueue rejects a request that finds this many already waiting, as a
+	// node answering RESOURCE_EXHAUSTED would. Zero means no limit.
+	MaxQueue int `yaml:"max_queue"`
+	// ServiceTime is how long the node takes to serve a request, and
+	// TaskServiceTime overrides it for a task.
+	ServiceTime     ServiceTime            `yaml:"service_time"`
+	TaskServiceTime map[string]ServiceTime `yaml:"task_service_time"`
+}
+
+// ServiceTime is a distribution of service times.
+type ServiceTime struct {
+	// Distribution is DistFixed, DistExponential, DistLognormal or
+	// DistObserved.
+	Distribution string        `yaml:"distribution"`
+	Mean         time.Duration `yaml:"mean"`
+	Median       time.Duration `yaml:"median"`
+	P95          time.Duration `yaml:"p95"`
+	// Scale multiplies observed latencies, e.g. 0.5 for a node twice as
+	// fast as the one that served the trace. Zero means one.
+	Scale float64 `yaml:"scale"`
+	// PerKB is added for every KiB of payload.
+	PerKB time.Duration `yaml:"per_kb"`
+}
+
+// ParseCluster parses a Cluster from YAML and validates it.
+func ParseCluster(data []byte) (*Cluster, error) {
+	c := &Cluster{Pool: config.DefaultConfig().Pool}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("parse cluster: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadCluster reads the Cluster in the YAML file at path.
+func LoadCluster(path string) (*Cluster, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCluster(data)
+}
+
+// Validate reports the first problem with c.
+func (c *Cluster) Validate() error {
+	switch c.Pool.Strategy {
+	case "", config.StrategyRoundRobin, config.StrategyCustom:
+	default:
+		return fmt.Errorf("pool.strategy %q: must be %s or %s", c.Pool.Strategy, config.StrategyRoundRobin, config.StrategyCustom)
+	}
+	if len(c.Nodes) == 0 {
+		return fmt.Errorf("cluster has no nodes")
+	}
+	for i, n := range c.Nodes {
+		if n.ID == "" {
+			return fmt.Errorf("nodes[%d]: id is required", i)
+		}
+		if len(n.Tasks) == 0 {
+			return fmt.Errorf("node %s: tasks is required", n.ID)
+		}
+		if n.Count < 0 || n.Concurrency < 0 || n.MaxQueue < 0 {
+			return fmt.Errorf("node %s: count, concurrency and max_queue must not be negative", n.ID)
+		}
+		if err := n.ServiceTime.validate(); err != nil {
+			return fmt.Errorf("node %s: service_time: %w", n.ID, err)
+		}
+		for task, st := range n.TaskServiceTime {
+			if err := st.validate(); err != nil {
+				return fmt.Errorf("node %s: task_service_time[%s]: %w", n.ID, task, err)
+			}
+		}
+	}
+	return nil
+}
+
+// config returns the client configuration the cluster's pool runs with.
+func (c *Cluster) config() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Pool = c.Pool
+	if cfg.Pool.Strategy == "" {
+		cfg.Pool.Strategy = config.StrategyRoundRobin
+	}
+	return cfg
+}
+
+// expand returns one simNode per node, Count expanded.
+func (c *Cluster) expand() []*simNode {
+	var nodes []*simNode
+	for _, spec := range c.Nodes {
+		count := max(spec.Count, 1)
+		for i := 1; i <= count; i++ {
+			id := spec.ID
+			if count > 1 {
+				id = fmt.Sprintf("%s-%d", spec.ID, i)
+			}
+			nodes = append(nodes, &simNode{id: id, spec: spec, slots: max(spec.Concurrency, 1)})
+		}
+	}
+	return nodes
+}
+
+func (n *NodeSpec) virtualNode(id string) client.VirtualNode {
+	return client.VirtualNode{ID: id, Tasks: n.Tasks, Txt: n.Txt}
+}
+
+// serviceTime returns the distribution of task's service time on the node.
+func (n *NodeSpec) serviceTime(task string) ServiceTime {
+	if st, ok := n.TaskServiceTime[task]; ok {
+		return st
+	}
+	return n.ServiceTime
+}
+
+func (s ServiceTime) validate() error {
+	switch s.Distribution {
+	case DistFixed, DistExponential:
+		if s.Mean <= 0 {
+			return fmt.Errorf("%s needs a positive mean", s.Distribution)
+		}
+	case DistLognormal:
+		if s.Median <= 0 || s.P95 < s.Median {
+			return fmt.Errorf("lognormal needs a positive median and a p95 no lower than it")
+		}
+	case DistObserved:
+		if s.Scale < 0 {
+			return fmt.Errorf("observed needs a scale that is not negative")
+		}
+	default:
+		return fmt.Errorf("distribution %q: must be %s, %s, %s or %s", s.Distribution, DistFixed, DistExponential, DistLognormal, DistObserved)
+	}
+	if s.PerKB < 0 {
+		return fmt.Errorf("per_kb must not be negative")
+	}
+	return nil
+}
+
+// z95 is the standard normal quantile at 0.95.
+const z95 = 1.6448536269514722
+
+// draw returns a service time for req.
+func (s ServiceTime) draw(rng *rand.Rand, req Request) time.Duration {
+	var d time.Duration
+	switch s.Distribution {
+	case DistFixed:
+		d = s.Mean
+	case DistExponential:
+		d = time.Duration(rng.ExpFloat64() * float64(s.Mean))
+	case DistLognormal:
+		mu := math.Log(float64(s.Median))
+		sigma := (math.Log(float64(s.P95)) - mu) / z95
+		d = time.Duration(math.Exp(mu + sigma*rng.NormFloat64()))
+	case DistObserved:
+		scale := s.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		d = time.Duration(float64(req.Latency()) * scale)
+	}
+	return d + time.Duration(float64(s.PerKB)*float64(req.PayloadBytes)/1024)
+}
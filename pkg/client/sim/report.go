@@ -0,0 +1,196 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is the outcome of a Run.
+type Report struct {
+	// Start and End bound the run: the first request, and the last
+	// completion.
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Requests int       `json:"requests"`
+	// Tasks and Nodes are keyed by task name and node ID.
+	Tasks map[string]*TaskReport `json:"tasks"`
+	Nodes map[string]*NodeReport `json:"nodes"`
+
+	latencies map[string][]time.Duration
+	observed  map[string][]time.Duration
+}
+
+// TaskReport is how a task's requests fared.
+type TaskReport struct {
+	Requests  int `json:"requests"`
+	Completed int `json:"completed"`
+	// Queued counts the requests that waited for a slot.
+	Queued int `json:"queued"`
+	// Rejected counts the requests turned away by a full queue, or that no
+	// node could take (Unroutable), e.g. because none serves the task.
+	Rejected   int `json:"rejected"`
+	Unroutable int `json:"unroutable"`
+	// Latency is the simulated latency of the completed requests, from
+	// arrival to completion; Observed is the latency the trace recorded,
+	// for comparison, when it recorded one.
+	Latency  Percentiles  `json:"latency"`
+	Observed *Percentiles `json:"observed,omitempty"`
+}
+
+// Percentiles summarize latencies.
+type Percentiles struct {
+	P50  time.Duration `json:"p50"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+	Mean time.Duration `json:"mean"`
+}
+
+// NodeReport is how busy a node was.
+type NodeReport struct {
+	// Served counts the requests the node completed, and Rejected those its
+	// full queue turned away.
+	Served   int `json:"served"`
+	Rejected int `json:"rejected"`
+	// Utilization is the share of the node's slot time spent serving, over
+	// the whole run.
+	Utilization float64 `json:"utilization"`
+	// PeakQueue is the most requests that waited for the node at once, and
+	// MeanWait how long a request it served waited for a slot.
+	PeakQueue int           `json:"peak_queue"`
+	MeanWait  time.Duration `json:"mean_wait"`
+}
+
+func newReport(start time.Time) *Report {
+	return &Report{
+		Start:     start,
+		End:       start,
+		Tasks:     make(map[string]*TaskReport),
+		Nodes:     make(map[string]*NodeReport),
+		latencies: make(map[string][]time.Duration),
+		observed:  make(map[string][]time.Duration),
+	}
+}
+
+// task counts req and returns its task's report.
+func (r *Report) task(req Request) *TaskReport {
+	t := r.Tasks[req.Task]
+	if t == nil {
+		t = &TaskReport{}
+		r.Tasks[req.Task] = t
+	}
+	r.Requests++
+	t.Requests++
+	if req.LatencyMS > 0 {
+		r.observed[req.Task] = append(r.observed[req.Task], req.Latency())
+	}
+	return t
+}
+
+func (r *Report) complete(f *inflight, at time.Time) {
+	r.Tasks[f.req.Task].Completed++
+	r.latencies[f.req.Task] = append(r.latencies[f.req.Task], at.Sub(f.req.At))
+	if at.After(r.End) {
+		r.End = at
+	}
+}
+
+func (r *Report) finish(nodes []*simNode) {
+	for name, t := range r.Tasks {
+		t.Latency = percentiles(r.latencies[name])
+		if obs := r.observed[name]; len(obs) > 0 {
+			p := percentiles(obs)
+			t.Observed = &p
+		}
+	}
+	span := r.End.Sub(r.Start)
+	for _, n := range nodes {
+		nr := &NodeReport{Served: n.served, Rejected: n.rejected, PeakQueue: n.peakQueue}
+		if span > 0 {
+			nr.Utilization = float64(n.busy) / (float64(span) * float64(n.slots))
+		}
+		if n.served > 0 {
+			nr.MeanWait = n.waited / time.Duration(n.served)
+		}
+		r.Nodes[n.id] = nr
+	}
+}
+
+// percentiles summarizes d, which it sorts.
+func percentiles(d []time.Duration) Percentiles {
+	if len(d) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+	at := func(q float64) time.Duration {
+		return d[int(math.Ceil(q*float64(len(d))))-1]
+	}
+	return Percentiles{
+		P50:  at(0.50),
+		P95:  at(0.95),
+		P99:  at(0.99),
+		Max:  d[len(d)-1],
+		Mean: sum / time.Duration(len(d)),
+	}
+}
+
+// WriteText writes r as tables. A task whose simulated p95 exceeds a
+// positive targetP95 is flagged.
+func (r *Report) WriteText(w io.Writer, targetP95 time.Duration) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%d requests from %s over %s\n\n", r.Requests, r.Start.Format(time.RFC3339), r.End.Sub(r.Start).Round(time.Millisecond))
+	fmt.Fprintln(tw, "TASK\tREQUESTS\tP50\tP95\tP99\tMAX\tOBSERVED P95\tQUEUED\tREJECTED\t")
+	for _, name := range sortedKeys(r.Tasks) {
+		t := r.Tasks[name]
+		observed := "-"
+		if t.Observed != nil {
+			observed = ms(t.Observed.P95)
+		}
+		flag := ""
+		if targetP95 > 0 && t.Latency.P95 > targetP95 {
+			flag = "over target"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", name, t.Requests,
+			ms(t.Latency.P50), ms(t.Latency.P95), ms(t.Latency.P99), ms(t.Latency.Max),
+			observed, t.Queued, t.Rejected, flag)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "NODE\tSERVED\tUTILIZATION\tPEAK QUEUE\tMEAN WAIT\tREJECTED\t")
+	for _, id := range sortedKeys(r.Nodes) {
+		n := r.Nodes[id]
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%d\t%s\t%d\t\n", id, n.Served, 100*n.Utilization, n.PeakQueue, ms(n.MeanWait), n.Rejected)
+	}
+	return tw.Flush()
+}
+
+// MeetsP95 reports whether every task's simulated p95 is within target and
+// no request was rejected.
+func (r *Report) MeetsP95(target time.Duration) bool {
+	for _, t := range r.Tasks {
+		if t.Rejected > 0 || t.Latency.P95 > target {
+			return false
+		}
+	}
+	return true
+}
+
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
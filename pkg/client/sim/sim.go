@@ -0,0 +1,207 @@
+// Package sim replays recorded traffic against the client's balancer in
+// virtual time, over a cluster that exists only on paper, to answer
+// capacity-planning questions such as "would one more CUDA node have kept
+// p95 under 500ms last Tuesday?".
+//
+// A trace (ReadTrace) lists when each request was made, for which task, with
+// what payload. A Cluster lists the nodes: the tasks each serves, how many
+// requests it serves at once, how long it takes, and how many requests may
+// wait for it. Run routes every request through a client.VirtualPool, which
+// selects with the pool's own strategy, models each node as a queue in front
+// of its concurrency slots, and reports per-task latency percentiles, node
+// utilization, and how many requests queued or were rejected.
+//
+// The model is deliberately simple: the network adds nothing, nodes never
+// fail, and a request routed to a busy node waits for it rather than being
+// routed again.
+package sim
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Options tunes a Run.
+type Options struct {
+	// Seed overrides Cluster.Seed when non-zero.
+	Seed uint64
+	// Scorer is the NodeScorer a custom-strategy pool consults; nodes are
+	// seen under the key "sim-<id>".
+	Scorer client.NodeScorer
+}
+
+// Run replays trace against cluster and reports how it went.
+func Run(trace []Request, cluster *Cluster, opts Options) (*Report, error) {
+	if err := cluster.Validate(); err != nil {
+		return nil, err
+	}
+	if len(trace) == 0 {
+		return nil, fmt.Errorf("trace is empty")
+	}
+	nodes := cluster.expand()
+	byID := make(map[string]*simNode, len(nodes))
+	virtual := make([]client.VirtualNode, 0, len(nodes))
+	for _, n := range nodes {
+		byID[n.id] = n
+		virtual = append(virtual, n.spec.virtualNode(n.id))
+	}
+	start := trace[0].At
+	pool, err := client.NewVirtualPool(cluster.config(), virtual, start)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Scorer != nil {
+		pool.SetScorer(opts.Scorer)
+	}
+	seed := cluster.Seed
+	if opts.Seed != 0 {
+		seed = opts.Seed
+	}
+
+	s := &run{
+		pool:   pool,
+		nodes:  byID,
+		rng:    rand.New(rand.NewPCG(seed, seed)),
+		report: newReport(start),
+	}
+	ctx := context.Background()
+	for _, req := range trace {
+		s.completeUntil(req.At)
+		s.arrive(ctx, req)
+	}
+	s.completeUntil(time.Time{})
+	s.report.finish(nodes)
+	return s.report, nil
+}
+
+// run is the state of one Run.
+type run struct {
+	pool   *client.VirtualPool
+	nodes  map[string]*simNode
+	rng    *rand.Rand
+	events completions
+	seq    int
+	report *Report
+}
+
+// simNode is a node's queue and slots.
+type simNode struct {
+	id    string
+	spec  NodeSpec
+	slots int
+
+	active    int
+	queue     []*inflight
+	busy      time.Duration
+	served    int
+	rejected  int
+	peakQueue int
+	waited    time.Duration
+}
+
+// inflight is a request routed to a node.
+type inflight struct {
+	req  Request
+	node *simNode
+	pick *client.VirtualPick
+	// started is when a slot took the request.
+	started time.Time
+}
+
+// arrive routes req at its time.
+func (s *run) arrive(ctx context.Context, req Request) {
+	s.pool.Advance(req.At)
+	task := s.report.task(req)
+	pick, err := s.pool.Pick(ctx, req.Task, client.SelectionHints{PayloadBytes: req.PayloadBytes})
+	if err != nil {
+		task.Rejected++
+		task.Unroutable++
+		return
+	}
+	n := s.nodes[pick.NodeID]
+	r := &inflight{req: req, node: n, pick: pick}
+	switch {
+	case n.active < n.slots:
+		s.start(r, req.At)
+	case n.spec.MaxQueue > 0 && len(n.queue) >= n.spec.MaxQueue:
+		// The node turns it away at once, as a full node would with
+		// RESOURCE_EXHAUSTED; that does not cool the node down.
+		s.pool.Done(pick, status.Error(codes.ResourceExhausted, "queue full"))
+		task.Rejected++
+		n.rejected++
+	default:
+		n.queue = append(n.queue, r)
+		n.peakQueue = max(n.peakQueue, len(n.queue))
+		task.Queued++
+	}
+}
+
+// start gives r a slot of its node at now.
+func (s *run) start(r *inflight, now time.Time) {
+	n := r.node
+	n.active++
+	r.started = now
+	n.waited += now.Sub(r.req.At)
+	d := n.spec.serviceTime(r.req.Task).draw(s.rng, r.req)
+	n.busy += d
+	s.seq++
+	heap.Push(&s.events, completion{at: now.Add(d), seq: s.seq, r: r})
+}
+
+// completeUntil finishes the requests due by t, or every request when t is
+// zero, handing each freed slot to the next request queued for the node.
+func (s *run) completeUntil(t time.Time) {
+	for s.events.Len() > 0 {
+		next := s.events[0]
+		if !t.IsZero() && next.at.After(t) {
+			return
+		}
+		heap.Pop(&s.events)
+		s.pool.Advance(next.at)
+		r := next.r
+		s.pool.Done(r.pick, nil)
+		s.report.complete(r, next.at)
+		n := r.node
+		n.active--
+		n.served++
+		if len(n.queue) > 0 {
+			queued := n.queue[0]
+			n.queue = n.queue[1:]
+			s.start(queued, next.at)
+		}
+	}
+}
+
+// completion is when a request finishes; seq orders simultaneous ones by
+// start.
+type completion struct {
+	at  time.Time
+	seq int
+	r   *inflight
+}
+
+type completions []completion
+
+func (c completions) Len() int { return len(c) }
+func (c completions) Less(i, j int) bool {
+	if c[i].at.Equal(c[j].at) {
+		return c[i].seq < c[j].seq
+	}
+	return c[i].at.Before(c[j].at)
+}
+func (c completions) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c *completions) Push(x any)   { *c = append(*c, x.(completion)) }
+func (c *completions) Pop() any {
+	old := *c
+	x := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,163 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2026, 10, 13, 14, 0, 0, 0, time.UTC)
+
+func burst(task string, n int, every time.Duration) []Request {
+	trace := make([]Request, n)
+	for i := range trace {
+		trace[i] = Request{At: t0.Add(time.Duration(i) * every), Task: task}
+	}
+	return trace
+}
+
+func fixedNode(id string, count, concurrency, maxQueue int, d time.Duration) NodeSpec {
+	return NodeSpec{
+		ID:          id,
+		Count:       count,
+		Tasks:       []string{"embed"},
+		Concurrency: concurrency,
+		MaxQueue:    maxQueue,
+		ServiceTime: ServiceTime{Distribution: DistFixed, Mean: d},
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	trace, err := ReadTrace(strings.NewReader(`{"ts":"2026-10-13T14:00:01Z","task":"ocr","payload_bytes":2048,"latency_ms":420.5}
+
+{"ts":"2026-10-13T14:00:00Z","task":"embed"}
+`))
+	if err != nil {
+		t.Fatalf("ReadTrace: %v", err)
+	}
+	if len(trace) != 2 || trace[0].Task != "embed" || trace[1].Task != "ocr" {
+		t.Fatalf("trace = %+v, want embed then ocr", trace)
+	}
+	if trace[1].PayloadBytes != 2048 || trace[1].Latency() != 420500*time.Microsecond {
+		t.Fatalf("ocr request = %+v", trace[1])
+	}
+	if _, err := ReadTrace(strings.NewReader(`{"ts":"2026-10-13T14:00:00Z"}`)); err == nil {
+		t.Fatal("ReadTrace accepted a request without a task")
+	}
+	if got := Window(trace, t0.Add(time.Second), time.Time{}); len(got) != 1 || got[0].Task != "ocr" {
+		t.Fatalf("Window = %+v, want only ocr", got)
+	}
+}
+
+func TestParseCluster(t *testing.T) {
+	c, err := ParseCluster([]byte(`
+pool:
+  strategy: custom
+  score_weights: {load: 1}
+nodes:
+  - id: cuda
+    count: 2
+    tasks: [embed]
+    concurrency: 4
+    service_time: {distribution: lognormal, median: 80ms, p95: 200ms, per_kb: 50us}
+    task_service_time:
+      ocr: {distribution: observed, scale: 0.5}
+`))
+	if err != nil {
+		t.Fatalf("ParseCluster: %v", err)
+	}
+	if c.Pool.Strategy != "custom" || c.Nodes[0].ServiceTime.Median != 80*time.Millisecond || c.Nodes[0].ServiceTime.PerKB != 50*time.Microsecond {
+		t.Fatalf("cluster = %+v", c)
+	}
+	nodes := c.expand()
+	if len(nodes) != 2 || nodes[0].id != "cuda-1" || nodes[1].id != "cuda-2" || nodes[0].slots != 4 {
+		t.Fatalf("expanded nodes = %+v", nodes)
+	}
+
+	for _, bad := range []string{
+		`nodes: []`,
+		`nodes: [{id: a, tasks: [embed], service_time: {distribution: gamma}}]`,
+		`nodes: [{id: a, tasks: [embed], service_time: {distribution: lognormal, median: 2s, p95: 1s}}]`,
+		`pool: {strategy: fastest}
+nodes: [{id: a, tasks: [embed], service_time: {distribution: fixed, mean: 1s}}]`,
+	} {
+		if _, err := ParseCluster([]byte(bad)); err == nil {
+			t.Errorf("ParseCluster(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRunQueuesThenRejects(t *testing.T) {
+	cluster := &Cluster{Nodes: []NodeSpec{fixedNode("a", 1, 1, 1, 100*time.Millisecond)}}
+	report, err := Run(burst("embed", 3, 0), cluster, Options{})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	task := report.Tasks["embed"]
+	if task.Completed != 2 || task.Queued != 1 || task.Rejected != 1 || task.Unroutable != 0 {
+		t.Fatalf("task = %+v, want one served at once, one queued, one rejected", task)
+	}
+	if task.Latency.P50 != 100*time.Millisecond || task.Latency.Max != 200*time.Millisecond {
+		t.Fatalf("latency = %+v, want 100ms then 200ms", task.Latency)
+	}
+	node := report.Nodes["a"]
+	if node.Served != 2 || node.Rejected != 1 || node.PeakQueue != 1 || node.Utilization != 1 || node.MeanWait != 50*time.Millisecond {
+		t.Fatalf("node = %+v", node)
+	}
+}
+
+func TestRunAnotherNodeAbsorbsTheBurst(t *testing.T) {
+	trace := burst("embed", 40, 50*time.Millisecond)
+	one, err := Run(trace, &Cluster{Nodes: []NodeSpec{fixedNode("a", 1, 1, 0, 100*time.Millisecond)}}, Options{})
+	if err != nil {
+		t.Fatalf("Run one node: %v", err)
+	}
+	two, err := Run(trace, &Cluster{Nodes: []NodeSpec{fixedNode("a", 2, 1, 0, 100*time.Millisecond)}}, Options{})
+	if err != nil {
+		t.Fatalf("Run two nodes: %v", err)
+	}
+	if one.MeetsP95(500 * time.Millisecond) {
+		t.Fatalf("one node met the target: %+v", one.Tasks["embed"].Latency)
+	}
+	if !two.MeetsP95(100*time.Millisecond) || two.Tasks["embed"].Queued != 0 {
+		t.Fatalf("two nodes = %+v, want every request served in 100ms", two.Tasks["embed"])
+	}
+	if two.Nodes["a-1"].Served != 20 || two.Nodes["a-2"].Served != 20 {
+		t.Fatalf("round robin split = %+v / %+v", two.Nodes["a-1"], two.Nodes["a-2"])
+	}
+}
+
+func TestRunObservedServiceTimesAndUnroutableTasks(t *testing.T) {
+	trace := []Request{
+		{At: t0, Task: "embed", LatencyMS: 80},
+		{At: t0.Add(time.Second), Task: "embed", LatencyMS: 120, PayloadBytes: 2048},
+		{At: t0.Add(2 * time.Second), Task: "ocr"},
+	}
+	cluster := &Cluster{Nodes: []NodeSpec{{
+		ID:          "a",
+		Tasks:       []string{"embed"},
+		ServiceTime: ServiceTime{Distribution: DistObserved, Scale: 0.5, PerKB: 10 * time.Millisecond},
+	}}}
+	report, err := Run(trace, cluster, Options{})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	embed := report.Tasks["embed"]
+	if embed.Latency.P50 != 40*time.Millisecond || embed.Latency.Max != 80*time.Millisecond {
+		t.Fatalf("embed latency = %+v, want 40ms and 60ms+20ms", embed.Latency)
+	}
+	if embed.Observed == nil || embed.Observed.Max != 120*time.Millisecond {
+		t.Fatalf("embed observed = %+v", embed.Observed)
+	}
+	if ocr := report.Tasks["ocr"]; ocr.Rejected != 1 || ocr.Unroutable != 1 {
+		t.Fatalf("ocr = %+v, want it unroutable", ocr)
+	}
+
+	var out strings.Builder
+	if err := report.WriteText(&out, 50*time.Millisecond); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	if !strings.Contains(out.String(), "over target") {
+		t.Fatalf("report does not flag embed over target:\n%s", out.String())
+	}
+}
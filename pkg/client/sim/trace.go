@@ -0,0 +1,83 @@
+package sim
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Request is one request of a traffic trace.
+type Request struct {
+	// At is when the request was made.
+	At   time.Time `json:"ts"`
+	Task string    `json:"task"`
+	// PayloadBytes is the size of the request's payload.
+	PayloadBytes int `json:"payload_bytes,omitempty"`
+	// LatencyMS is the end-to-end latency observed for the request, in
+	// milliseconds; zero when unknown. The "observed" service time
+	// distribution replays it.
+	LatencyMS float64 `json:"latency_ms,omitempty"`
+}
+
+// Latency returns the observed latency.
+func (r Request) Latency() time.Duration {
+	return time.Duration(r.LatencyMS * float64(time.Millisecond))
+}
+
+// ReadTrace reads a trace in JSON Lines, one Request per line, and returns
+// it sorted by time. Blank lines are skipped.
+func ReadTrace(r io.Reader) ([]Request, error) {
+	var trace []Request
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		raw := scanner.Bytes()
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+		var req Request
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, fmt.Errorf("trace line %d: %w", line, err)
+		}
+		if req.At.IsZero() || req.Task == "" {
+			return nil, fmt.Errorf("trace line %d: ts and task are required", line)
+		}
+		trace = append(trace, req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(trace, func(i, j int) bool { return trace[i].At.Before(trace[j].At) })
+	return trace, nil
+}
+
+// ReadTraceFile reads the trace in the file at path; see ReadTrace.
+func ReadTraceFile(path string) ([]Request, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTrace(f)
+}
+
+// Window returns the requests of trace made in [since, until). A zero
+// bound leaves that side open.
+func Window(trace []Request, since, until time.Time) []Request {
+	var out []Request
+	for _, req := range trace {
+		if !since.IsZero() && req.At.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !req.At.Before(until) {
+			continue
+		}
+		out = append(out, req)
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+)
+
+// Components reported in a StartupReport.
+const (
+	// ComponentDiscovery is the configured discovery backends.
+	ComponentDiscovery = "discovery"
+	// ComponentPool is the connection pool and its balancer, including
+	// health checking.
+	ComponentPool = "pool"
+	// ComponentNodes is the wait for a first node to report its
+	// capabilities.
+	ComponentNodes = "nodes"
+)
+
+// StartupReport describes how each subsystem came up in Start.
+type StartupReport = discovery.StartupReport
+
+// startupReport accumulates the StartupReport of one Start call.
+type startupReport struct {
+	report *StartupReport
+}
+
+func newStartupReport() startupReport {
+	return startupReport{report: &StartupReport{
+		StartedAt:  time.Now(),
+		Components: make(map[string]discovery.ComponentStatus),
+	}}
+}
+
+func (s startupReport) set(component, status string, err error) {
+	c := discovery.ComponentStatus{Status: status}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	s.report.Components[component] = c
+}
+
+// finish sets the overall status: failed when err ends Start, otherwise
+// degraded if any component is not ok.
+func (s startupReport) finish(err error) *StartupReport {
+	r := s.report
+	r.Duration = time.Since(r.StartedAt)
+	r.Status = discovery.StartupOK
+	for _, c := range r.Components {
+		if c.Status != discovery.StartupOK {
+			r.Status = discovery.StartupDegraded
+		}
+	}
+	if err != nil {
+		r.Status = discovery.StartupFailed
+	}
+	return r
+}
+
+// discoveryStatusError summarizes the backends a degraded discovery is
+// retrying.
+func discoveryStatusError(status discovery.DiscoveryStatus) error {
+	failed := make([]string, 0, len(status.Failed))
+	for _, f := range status.Failed {
+		failed = append(failed, fmt.Sprintf("%s: %s", f.Backend, f.Error))
+	}
+	return fmt.Errorf("backends down: %s", strings.Join(failed, "; "))
+}
+
+// GetStartupReport returns the report of the last Start call, or nil before
+// Start.
+func (c *LumenClient) GetStartupReport() *StartupReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.startup == nil {
+		return nil
+	}
+	r := *c.startup
+	r.Components = make(map[string]discovery.ComponentStatus, len(c.startup.Components))
+	for k, v := range c.startup.Components {
+		r.Components[k] = v
+	}
+	return &r
+}
+
+// hasLocalHandlers reports whether the client can serve some task without
+// a node.
+func (c *LumenClient) hasLocalHandlers() bool {
+	if !c.config.Fallback.Enabled {
+		return false
+	}
+	c.localMu.RLock()
+	defer c.localMu.RUnlock()
+	return len(c.localHandlers) > 0
+}
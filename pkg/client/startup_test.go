@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"go.uber.org/zap"
+)
+
+// nodeResolverFor discovers one node serving srv.
+func nodeResolverFor(t *testing.T, srv pb.InferenceServer) *fakeNodeResolver {
+	t.Helper()
+	host, port, _ := splitEndpoint(startInferenceServer(t, srv))
+	return &fakeNodeResolver{events: []discovery.NodeEvent{{
+		Type: discovery.NodeDiscovered,
+		Resolved: discovery.ResolvedNode{
+			Identity:  discovery.NewNodeIdentity("local", "node-1"),
+			Addresses: []string{host},
+			Port:      port,
+			Txt:       map[string]string{"tasks": types.TaskSemanticTextEmbed},
+		},
+	}}}
+}
+
+func TestStartupReport(t *testing.T) {
+	ok := discovery.ComponentStatus{Status: discovery.StartupOK}
+	embedServer := &testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}
+
+	cases := []struct {
+		name     string
+		resolver func(t *testing.T) discovery.NodeResolver
+		pool     PoolOptions
+		fallback bool
+		timeout  time.Duration
+		wantErr  bool
+		status   string
+		want     map[string]string // component -> status
+	}{
+		{
+			name:     "all up",
+			resolver: func(t *testing.T) discovery.NodeResolver { return nodeResolverFor(t, embedServer) },
+			status:   discovery.StartupOK,
+			want:     map[string]string{ComponentPool: ok.Status, ComponentNodes: ok.Status, ComponentDiscovery: ok.Status},
+		},
+		{
+			name: "one discovery backend down",
+			resolver: func(t *testing.T) discovery.NodeResolver {
+				return discovery.NewCompositeResolver(nodeResolverFor(t, embedServer), failingNodeResolver{err: errors.New("no multicast route")})
+			},
+			status: discovery.StartupDegraded,
+			want:   map[string]string{ComponentPool: ok.Status, ComponentNodes: ok.Status, ComponentDiscovery: discovery.StartupDegraded},
+		},
+		{
+			name:     "no node answers",
+			resolver: func(*testing.T) discovery.NodeResolver { return &fakeNodeResolver{} },
+			timeout:  100 * time.Millisecond,
+			status:   discovery.StartupDegraded,
+			want:     map[string]string{ComponentPool: ok.Status, ComponentNodes: discovery.StartupDegraded, ComponentDiscovery: ok.Status},
+		},
+		{
+			name: "sole discovery source fails",
+			resolver: func(*testing.T) discovery.NodeResolver {
+				return failingNodeResolver{err: errors.New("mDNS cannot start")}
+			},
+			wantErr: true,
+			status:  discovery.StartupFailed,
+			want:    map[string]string{ComponentPool: ok.Status, ComponentDiscovery: discovery.StartupFailed},
+		},
+		{
+			name: "discovery fails with local handlers",
+			resolver: func(*testing.T) discovery.NodeResolver {
+				return failingNodeResolver{err: errors.New("mDNS cannot start")}
+			},
+			fallback: true,
+			status:   discovery.StartupDegraded,
+			want:     map[string]string{ComponentPool: ok.Status, ComponentNodes: discovery.StartupDegraded, ComponentDiscovery: discovery.StartupFailed},
+		},
+		{
+			name:     "pool credentials unusable",
+			resolver: func(*testing.T) discovery.NodeResolver { return &fakeNodeResolver{} },
+			pool:     PoolOptions{TLS: config.TransportConfig{Mode: config.TransportTLS, CAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+			wantErr:  true,
+			status:   discovery.StartupFailed,
+			want:     map[string]string{ComponentPool: discovery.StartupFailed},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Discovery.ConnectTimeout = 5 * time.Second
+			if tc.timeout > 0 {
+				cfg.Discovery.ConnectTimeout = tc.timeout
+			}
+			cfg.Fallback.Enabled = tc.fallback
+			c := &LumenClient{
+				pool:     NewPoolWithOptions(zap.NewNop(), tc.pool),
+				resolver: tc.resolver(t),
+				config:   cfg,
+				logger:   zap.NewNop(),
+			}
+			t.Cleanup(func() { _ = c.Close() })
+			if c.GetStartupReport() != nil {
+				t.Fatal("startup report before Start")
+			}
+			if tc.fallback {
+				c.RegisterLocalHandler(types.TaskSemanticTextEmbed, func(context.Context, *pb.InferRequest) (*pb.InferResponse, error) {
+					return &pb.InferResponse{IsFinal: true}, nil
+				})
+			}
+
+			err := c.Start(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Start = %v, want error: %v", err, tc.wantErr)
+			}
+			report := c.GetStartupReport()
+			if report == nil || report.Status != tc.status {
+				t.Fatalf("report = %+v, want status %s", report, tc.status)
+			}
+			if len(report.Components) != len(tc.want) {
+				t.Fatalf("components = %v, want %v", report.Components, tc.want)
+			}
+			for name, status := range tc.want {
+				got := report.Components[name]
+				if got.Status != status || (status == discovery.StartupOK) != (got.Error == "") {
+					t.Fatalf("component %s = %+v, want %s with an error unless ok", name, got, status)
+				}
+			}
+		})
+	}
+}
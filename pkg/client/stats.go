@@ -0,0 +1,81 @@
+package client
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+)
+
+// DiscoveryStats is a read-only snapshot of the discovery events the pool's
+// resolver has consumed.
+type DiscoveryStats struct {
+	// ResolvedNodes is the number of nodes currently known to the resolver,
+	// whether or not a connection to them is up.
+	ResolvedNodes   int       `json:"resolved_nodes"`
+	Discovered      int64     `json:"discovered_events"`
+	Expired         int64     `json:"expired_events"`
+	ResolveFailures int64     `json:"resolve_failures"`
+	LastEvent       time.Time `json:"last_event,omitempty"`
+	// Degraded is set while some discovery backends failed to start and
+	// are being retried; FailedBackends lists them.
+	Degraded       bool                       `json:"degraded"`
+	FailedBackends []discovery.BackendFailure `json:"failed_backends,omitempty"`
+	// Catalog is how recently discovery vouched for the known nodes,
+	// including when the last mDNS scan completed.
+	Catalog discovery.CatalogFreshness `json:"catalog"`
+}
+
+// SystemStats bundles client metrics, pool and discovery statistics and a
+// per-status node count into one snapshot, suitable for logging or serving
+// as JSON.
+type SystemStats struct {
+	Metrics   *ClientMetrics               `json:"metrics"`
+	Pool      PoolStats                    `json:"pool"`
+	Discovery DiscoveryStats               `json:"discovery"`
+	Nodes     map[discovery.NodeStatus]int `json:"nodes"`
+}
+
+// discoveryCounters is updated by the resolver as events arrive.
+type discoveryCounters struct {
+	resolved        atomic.Int64
+	discovered      atomic.Int64
+	expired         atomic.Int64
+	resolveFailures atomic.Int64
+	lastEventNs     atomic.Int64
+}
+
+func (d *discoveryCounters) record(ev discovery.NodeEvent) {
+	switch ev.Type {
+	case discovery.NodeDiscovered:
+		d.discovered.Add(1)
+	case discovery.NodeExpired:
+		d.expired.Add(1)
+	case discovery.NodeResolveFailed:
+		d.resolveFailures.Add(1)
+	}
+	d.lastEventNs.Store(time.Now().UnixNano())
+}
+
+func (d *discoveryCounters) snapshot() DiscoveryStats {
+	s := DiscoveryStats{
+		ResolvedNodes:   int(d.resolved.Load()),
+		Discovered:      d.discovered.Load(),
+		Expired:         d.expired.Load(),
+		ResolveFailures: d.resolveFailures.Load(),
+	}
+	if ns := d.lastEventNs.Load(); ns != 0 {
+		s.LastEvent = time.Unix(0, ns)
+	}
+	return s
+}
+
+func countNodesByStatus(nodes []*discovery.NodeInfo) map[discovery.NodeStatus]int {
+	out := make(map[discovery.NodeStatus]int)
+	for _, n := range nodes {
+		if n != nil {
+			out[n.Status]++
+		}
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"go.uber.org/zap"
+)
+
+func TestDiscoveryCountersSnapshot(t *testing.T) {
+	var d discoveryCounters
+	if s := d.snapshot(); !s.LastEvent.IsZero() {
+		t.Fatalf("LastEvent = %v before any event, want zero", s.LastEvent)
+	}
+
+	d.record(discovery.NodeEvent{Type: discovery.NodeDiscovered})
+	d.record(discovery.NodeEvent{Type: discovery.NodeDiscovered})
+	d.record(discovery.NodeEvent{Type: discovery.NodeExpired})
+	d.record(discovery.NodeEvent{Type: discovery.NodeResolveFailed})
+	d.resolved.Store(1)
+
+	s := d.snapshot()
+	if s.Discovered != 2 || s.Expired != 1 || s.ResolveFailures != 1 || s.ResolvedNodes != 1 {
+		t.Fatalf("snapshot = %+v, want 2 discovered, 1 expired, 1 failure, 1 resolved", s)
+	}
+	if s.LastEvent.IsZero() {
+		t.Fatal("LastEvent not set after events")
+	}
+}
+
+func TestCountNodesByStatus(t *testing.T) {
+	got := countNodesByStatus([]*discovery.NodeInfo{
+		{ID: "a", Status: discovery.NodeStatusActive},
+		{ID: "b", Status: discovery.NodeStatusActive},
+		{ID: "c", Status: discovery.NodeStatusError},
+		nil,
+	})
+	if got[discovery.NodeStatusActive] != 2 || got[discovery.NodeStatusError] != 1 || len(got) != 2 {
+		t.Fatalf("countNodesByStatus = %v", got)
+	}
+}
+
+type failingNodeResolver struct{ err error }
+
+func (r failingNodeResolver) Watch(context.Context) (<-chan discovery.NodeEvent, error) {
+	return nil, r.err
+}
+
+func TestStartFailsWhenTheOnlyDiscoveryBackendFails(t *testing.T) {
+	cause := utils.DiscoveryFailedError("mDNS cannot start")
+	cfg := config.DefaultConfig()
+	cfg.Discovery.ConnectTimeout = 10 * time.Second
+	c := &LumenClient{
+		pool:     NewPool(zap.NewNop()),
+		resolver: failingNodeResolver{err: cause},
+		config:   cfg,
+		logger:   zap.NewNop(),
+	}
+	t.Cleanup(func() { _ = c.Close() })
+
+	start := time.Now()
+	err := c.Start(context.Background())
+	if !utils.HasErrorCode(err, utils.ErrCodeDiscoveryFailed) {
+		t.Fatalf("Start = %v, want the backend's DISCOVERY_FAILED error", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("Start waited for the connect timeout instead of failing")
+	}
+}
+
+func TestDiscoveryStatsReportDegradedBackend(t *testing.T) {
+	pool := NewPool(zap.NewNop())
+	resolver := discovery.NewCompositeResolver(
+		&fakeNodeResolver{},
+		failingNodeResolver{err: errors.New("no multicast route")},
+	)
+	if err := pool.Connect(resolver); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+
+	waitUntil(t, func() bool { return pool.DiscoveryStats().Degraded })
+	s := pool.DiscoveryStats()
+	if len(s.FailedBackends) != 1 || s.FailedBackends[0].Error != "no multicast route" {
+		t.Fatalf("DiscoveryStats = %+v, want the failed backend", s)
+	}
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+type strategyKey struct{}
+
+// WithStrategy routes the requests made with ctx through strategy, one of
+// config.StrategyRoundRobin, config.StrategyLeastLatency or
+// config.StrategyCustom, instead of pool.strategy. Every strategy chooses
+// among the same nodes, after the same health, cooldown and filter checks;
+// only the choice among the eligible ones differs. A request naming an
+// unknown strategy fails with INVALID.
+func WithStrategy(ctx context.Context, strategy string) context.Context {
+	return context.WithValue(ctx, strategyKey{}, strategy)
+}
+
+// StrategyFromContext returns the strategy set with WithStrategy, or "".
+func StrategyFromContext(ctx context.Context) string {
+	s, _ := ctx.Value(strategyKey{}).(string)
+	return s
+}
+
+// checkStrategy fails a request whose ctx names an unknown strategy before
+// it reaches the picker.
+func checkStrategy(ctx context.Context) error {
+	if s := StrategyFromContext(ctx); s != "" && !config.ValidStrategy(s) {
+		return utils.InvalidError(fmt.Sprintf("unknown selection strategy %q", s))
+	}
+	return nil
+}
+
+// strategies are the selection strategies a picker routes requests through,
+// all over one node set: round-robin, whose rotation lives in the registry,
+// and a scoring policy for each of the others. configured is pool.strategy.
+type strategies struct {
+	configured   string
+	custom       *scoring
+	leastLatency *scoring
+}
+
+// newStrategies returns the strategies for a pool configured with
+// strategy, whose custom strategy scores by custom.
+func newStrategies(strategy string, custom *scoring) *strategies {
+	if strategy == "" {
+		strategy = config.StrategyRoundRobin
+	}
+	return &strategies{
+		configured:   strategy,
+		custom:       custom,
+		leastLatency: newScoring(map[string]float64{config.ScoreLatency: 1}, false),
+	}
+}
+
+// resolve returns the strategy for the request ctx carries and its scoring
+// policy, nil under round-robin. ok is false for an unknown strategy.
+func (s *strategies) resolve(ctx context.Context) (strategy string, sc *scoring, ok bool) {
+	strategy = StrategyFromContext(ctx)
+	if strategy == "" {
+		strategy = s.name()
+	}
+	sc, ok = s.scoring(strategy)
+	return strategy, sc, ok
+}
+
+// name is the configured strategy; a nil s is round-robin.
+func (s *strategies) name() string {
+	if s == nil {
+		return config.StrategyRoundRobin
+	}
+	return s.configured
+}
+
+// scoring returns strategy's scoring policy, nil for round-robin.
+func (s *strategies) scoring(strategy string) (*scoring, bool) {
+	switch strategy {
+	case config.StrategyRoundRobin:
+		return nil, true
+	case config.StrategyLeastLatency:
+		if s == nil {
+			return nil, false
+		}
+		return s.leastLatency, true
+	case config.StrategyCustom:
+		if s == nil || s.custom == nil {
+			return nil, false
+		}
+		return s.custom, true
+	}
+	return nil, false
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// strategyFixture returns a round-robin registry and picker over the Ready
+// ocr nodes a (80ms), b (5ms) and c (40ms), with their median latencies
+// recorded.
+func strategyFixture() (*nodeRegistry, *lumenPicker) {
+	s := newScoring(nil, false)
+	reg, picker := scoredFixture(s, "a", "b", "c")
+	picker.strategies = newStrategies(config.StrategyRoundRobin, s)
+	reg.latency = newLatencySet(0)
+	for key, d := range map[string]time.Duration{"local-a": 80 * time.Millisecond, "local-b": 5 * time.Millisecond, "local-c": 40 * time.Millisecond} {
+		reg.latency.observe(key, d)
+	}
+	return reg, picker
+}
+
+func TestConcurrentCallersUseTheirOwnStrategy(t *testing.T) {
+	reg, picker := strategyFixture()
+
+	const picks = 300
+	callers := map[string]map[string]int{
+		config.StrategyRoundRobin:   {},
+		config.StrategyLeastLatency: {},
+	}
+	var wg sync.WaitGroup
+	for strategy, counts := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := WithTask(WithStrategy(context.Background(), strategy), "ocr")
+			for range picks {
+				res, err := picker.Pick(balancer.PickInfo{Ctx: ctx})
+				if err != nil {
+					t.Errorf("%s Pick: %v", strategy, err)
+					return
+				}
+				counts[res.SubConn.(*namedSubConn).name]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, key := range []string{"local-a", "local-b", "local-c"} {
+		if n := callers[config.StrategyRoundRobin][key]; n != picks/3 {
+			t.Errorf("round-robin picked %s %d times, want %d", key, n, picks/3)
+		}
+	}
+	if n := callers[config.StrategyLeastLatency]["local-b"]; n != picks {
+		t.Errorf("least-latency picked local-b %d of %d times, want every time: %v", n, picks, callers[config.StrategyLeastLatency])
+	}
+	got := reg.selectionCounts()
+	if got[config.StrategyRoundRobin] != picks || got[config.StrategyLeastLatency] != picks || len(got) != 2 {
+		t.Fatalf("selections = %v, want %d per strategy", got, picks)
+	}
+}
+
+func TestStrategyDefaultsToConfigured(t *testing.T) {
+	reg, picker := strategyFixture()
+	picker.strategies.configured = config.StrategyLeastLatency
+
+	var decided []string
+	reg.onSelection = func(d discovery.SelectionDecision) { decided = append(decided, d.Strategy) }
+	if name := pickName(t, picker, context.Background()); name != "local-b" {
+		t.Fatalf("picked %s, want the configured least-latency choice", name)
+	}
+	if name := pickName(t, picker, WithStrategy(context.Background(), config.StrategyRoundRobin)); name != "local-b" {
+		t.Fatalf("picked %s, want the first node in rotation", name)
+	}
+	if len(decided) != 2 || decided[0] != config.StrategyLeastLatency || decided[1] != config.StrategyRoundRobin {
+		t.Fatalf("decisions made under %v", decided)
+	}
+
+	exp := reg.explainStrategy("ocr", "", time.Now())
+	if exp.Pick != "local-b" || exp.Strategy != config.StrategyLeastLatency {
+		t.Fatalf("explained pick %q strategy %q, want local-b under least_latency", exp.Pick, exp.Strategy)
+	}
+	exp = reg.explainStrategy("ocr", config.StrategyRoundRobin, time.Now())
+	if exp.Pick != "local-c" || exp.Strategy != config.StrategyRoundRobin {
+		t.Fatalf("explained pick %q strategy %q, want the next in rotation under round_robin", exp.Pick, exp.Strategy)
+	}
+}
+
+func TestLeastLatencyTriesUnmeasuredNodesFirst(t *testing.T) {
+	reg, picker := strategyFixture()
+	reg.latency.forget("local-c")
+	ctx := WithStrategy(context.Background(), config.StrategyLeastLatency)
+	if name := pickName(t, picker, ctx); name != "local-c" {
+		t.Fatalf("picked %s, want the node without a latency yet", name)
+	}
+}
+
+func TestUnknownStrategy(t *testing.T) {
+	reg, picker := strategyFixture()
+	ctx := WithTask(WithStrategy(context.Background(), "least_connections"), "ocr")
+	if _, err := picker.Pick(balancer.PickInfo{Ctx: ctx}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Pick = %v, want InvalidArgument", err)
+	}
+	if err := checkStrategy(ctx); !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+		t.Fatalf("checkStrategy = %v, want INVALID", err)
+	}
+	if exp := reg.explainStrategy("ocr", "least_connections", time.Now()); exp.Pick != "" || exp.Error == "" {
+		t.Fatalf("explained pick %q error %q, want no pick", exp.Pick, exp.Error)
+	}
+	if n := len(reg.selectionCounts()); n != 0 {
+		t.Fatalf("%d strategies counted, want none", n)
+	}
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// ErrStreamEnded is returned by CollectStream when the response channel
+// closes without a final frame: the stream's context was cancelled or the
+// consumer timeout expired.
+var ErrStreamEnded = fmt.Errorf("stream ended without a final response")
+
+// CollectStream reads an InferStream channel up to the final frame and
+// returns it, discarding the frames before it. Like Infer, a node error is
+// reported on the returned frame's Error. If ctx ends first it returns
+// ctx.Err(); the stream is then released once its own context ends or, at
+// the latest, after the consumer timeout (see WithConsumerTimeout). Pass the
+// context given to InferStream to release it at once.
+//
+//	frames, err := c.InferStream(ctx, req)
+//	if err != nil {
+//	    return err
+//	}
+//	resp, err := client.CollectStream(ctx, frames)
+func CollectStream(ctx context.Context, ch <-chan *pb.InferResponse) (*pb.InferResponse, error) {
+	return CollectStreamWithProgress(ctx, ch, nil)
+}
+
+// CollectStreamWithProgress is CollectStream passing each frame before the
+// final one to onPartial, which may be nil. Progress reads a frame's
+// progress.
+func CollectStreamWithProgress(ctx context.Context, ch <-chan *pb.InferResponse, onPartial func(*pb.InferResponse)) (*pb.InferResponse, error) {
+	for {
+		select {
+		case resp, ok := <-ch:
+			if !ok {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				return nil, ErrStreamEnded
+			}
+			if resp.IsFinal {
+				return resp, nil
+			}
+			if onPartial != nil {
+				onPartial(resp)
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+)
+
+// endlessProgressServer reports progress until the stream is cancelled and
+// never answers.
+type endlessProgressServer struct {
+	testInferenceServer
+}
+
+func (s *endlessProgressServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	for {
+		if err := stream.Send(&pb.InferResponse{Meta: map[string]string{ProgressMetaKey: "50"}}); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestCollectStreamWithProgress(t *testing.T) {
+	c := startClientFor(t, &progressServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+	req := embedRequest()
+
+	frames, err := c.InferStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	var partials int
+	var progress []float64
+	resp, err := CollectStreamWithProgress(context.Background(), frames, func(resp *pb.InferResponse) {
+		partials++
+		if pct, ok := Progress(resp); ok {
+			progress = append(progress, pct)
+		}
+	})
+	if err != nil {
+		t.Fatalf("CollectStreamWithProgress: %v", err)
+	}
+	if !resp.IsFinal || partials != 3 || len(progress) != 2 || progress[1] != 75 {
+		t.Fatalf("final = %v after %d partials with progress %v, want 3 partials reporting 25 and 75", resp, partials, progress)
+	}
+	waitOpenStreams(t, c, 0)
+
+	ctx := WithStreamOptions(context.Background(), DiscardPartials())
+	if frames, err = c.InferStream(ctx, req); err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	got := collectStream(t, frames)
+	if len(got) != 1 || !got[0].IsFinal {
+		t.Fatalf("got %d frames with DiscardPartials, want only the final one", len(got))
+	}
+}
+
+func TestCollectStreamContextEnds(t *testing.T) {
+	c := startClientFor(t, &endlessProgressServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	frames, err := c.InferStream(ctx, embedRequest())
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := CollectStream(ctx, frames); !errors.Is(err, context.Canceled) {
+		t.Fatalf("CollectStream error = %v, want context.Canceled", err)
+	}
+	waitOpenStreams(t, c, 0)
+}
+
+func TestAbandonedStreamIsReleased(t *testing.T) {
+	c := startClientFor(t, &endlessProgressServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+
+	// The caller reads nothing and never cancels.
+	ctx := WithStreamOptions(context.Background(), WithStreamBuffer(2), WithConsumerTimeout(50*time.Millisecond))
+	frames, err := c.InferStream(ctx, embedRequest())
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	waitOpenStreams(t, c, 1)
+	waitOpenStreams(t, c, 0)
+
+	// A caller coming back finds the buffered partials and a closed channel.
+	if _, err := CollectStream(context.Background(), frames); !errors.Is(err, ErrStreamEnded) {
+		t.Fatalf("CollectStream error = %v, want ErrStreamEnded", err)
+	}
+}
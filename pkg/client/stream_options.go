@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// defaultStreamBuffer is the InferStream channel capacity when none is set.
+const defaultStreamBuffer = 100
+
+// defaultConsumerTimeout is how long InferStream waits on a caller that
+// stopped reading before abandoning the stream, when none is set.
+const defaultConsumerTimeout = time.Minute
+
+// DroppedFramesMetaKey is set on the final InferStream frame to the number of
+// partial frames discarded under OverflowDropOldest.
+const DroppedFramesMetaKey = "lumen.dropped_frames"
+
+// OverflowPolicy decides what InferStream does when the caller falls behind
+// and the response channel is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for the caller to make room. A slow caller then
+	// stalls the gRPC stream and, through flow control, the node.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered partial frame to make
+	// room, so the caller sees the latest partials. The final frame is never
+	// dropped; it reports the discarded count under DroppedFramesMetaKey.
+	OverflowDropOldest
+	// OverflowFail ends the stream with a final UNAVAILABLE error frame
+	// noting consumer backpressure.
+	OverflowFail
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop_oldest"
+	case OverflowFail:
+		return "fail"
+	default:
+		return "unknown"
+	}
+}
+
+// StreamOption customises one InferStream call; attach options to the
+// call's context with WithStreamOptions.
+type StreamOption func(*streamOptions)
+
+type streamOptions struct {
+	buffer          int
+	overflow        OverflowPolicy
+	consumerTimeout time.Duration
+	discardPartials bool
+}
+
+// WithStreamBuffer sets the capacity of the response channel. Values below
+// one are raised to one under the dropping and failing policies, which need
+// a buffer to detect overflow.
+func WithStreamBuffer(n int) StreamOption {
+	return func(o *streamOptions) { o.buffer = n }
+}
+
+// WithOverflowPolicy sets what happens when the response channel is full.
+func WithOverflowPolicy(p OverflowPolicy) StreamOption {
+	return func(o *streamOptions) { o.overflow = p }
+}
+
+// WithConsumerTimeout sets how long InferStream waits to deliver a frame to
+// a caller that stopped reading the channel (one minute by default). When it
+// expires the stream is cancelled, its connection released and the channel
+// closed without a final frame. Zero or less waits as long as the context.
+func WithConsumerTimeout(d time.Duration) StreamOption {
+	return func(o *streamOptions) { o.consumerTimeout = d }
+}
+
+// DiscardPartials has InferStream deliver only the final frame, so a caller
+// that wants just the result buffers nothing while the node works.
+func DiscardPartials() StreamOption {
+	return func(o *streamOptions) { o.discardPartials = true }
+}
+
+type streamOptionsKey struct{}
+
+// WithStreamOptions attaches InferStream options to ctx. Options from an
+// enclosing WithStreamOptions call are kept unless overridden.
+//
+//	ctx = client.WithStreamOptions(ctx,
+//	    client.WithStreamBuffer(8),
+//	    client.WithOverflowPolicy(client.OverflowDropOldest),
+//	)
+//	frames, err := c.InferStream(ctx, req)
+func WithStreamOptions(ctx context.Context, opts ...StreamOption) context.Context {
+	o := streamOptionsFromContext(ctx)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return context.WithValue(ctx, streamOptionsKey{}, o)
+}
+
+func streamOptionsFromContext(ctx context.Context) streamOptions {
+	if o, ok := ctx.Value(streamOptionsKey{}).(streamOptions); ok {
+		return o
+	}
+	return streamOptions{buffer: defaultStreamBuffer, consumerTimeout: defaultConsumerTimeout}
+}
+
+// streamObserver sees every frame of one InferStream call from the
+// goroutine receiving them, then learns the stream ended, however it ended:
+// final frame, error, cancellation or a caller that stopped reading. It lets
+// an internal middleware account for a stream without relaying each frame
+// through a channel of its own.
+type streamObserver struct {
+	frame func(resp *pb.InferResponse)
+	done  func()
+	// adopted is set by the stream that calls frame and done. An observer
+	// left unadopted, because a middleware further in answered with a
+	// channel of its own, has to read that channel instead.
+	adopted atomic.Bool
+}
+
+type streamObserversKey struct{}
+
+// withStreamObserver adds obs to the observers of the stream started with
+// ctx.
+func withStreamObserver(ctx context.Context, obs *streamObserver) context.Context {
+	prev, _ := ctx.Value(streamObserversKey{}).([]*streamObserver)
+	return context.WithValue(ctx, streamObserversKey{}, append(slices.Clip(prev), obs))
+}
+
+// streamOutput delivers frames to the caller's channel under an overflow
+// policy. It is owned by the single receiver goroutine.
+type streamOutput struct {
+	ch              chan *pb.InferResponse
+	policy          OverflowPolicy
+	consumerTimeout time.Duration
+	discardPartials bool
+	dropped         int
+	observers       []*streamObserver
+}
+
+func newStreamOutput(o streamOptions) *streamOutput {
+	buffer := o.buffer
+	if buffer < 0 {
+		buffer = 0
+	}
+	if o.overflow != OverflowBlock && buffer < 1 {
+		buffer = 1
+	}
+	return &streamOutput{
+		ch:              make(chan *pb.InferResponse, buffer),
+		policy:          o.overflow,
+		consumerTimeout: o.consumerTimeout,
+		discardPartials: o.discardPartials,
+	}
+}
+
+// adoptObservers takes over the stream observers attached to ctx that no
+// other stream has: they see each frame passed to deliver, and finish.
+func (s *streamOutput) adoptObservers(ctx context.Context) {
+	observers, _ := ctx.Value(streamObserversKey{}).([]*streamObserver)
+	for _, obs := range observers {
+		if obs.adopted.CompareAndSwap(false, true) {
+			s.observers = append(s.observers, obs)
+		}
+	}
+}
+
+func (s *streamOutput) observe(resp *pb.InferResponse) {
+	for _, obs := range s.observers {
+		obs.frame(resp)
+	}
+}
+
+// finish tells the observers the stream ended. The receiver goroutine calls
+// it on every exit path, before closing the channel.
+func (s *streamOutput) finish() {
+	for _, obs := range s.observers {
+		obs.done()
+	}
+}
+
+// deliver hands resp to the caller. It returns false when the stream must
+// end: the context was cancelled, the caller stopped reading for the
+// consumer timeout, or the caller fell behind under OverflowFail, in which
+// case the backpressure error frame has already been queued. Final frames
+// always block until delivered, cancelled or timed out. Observers see every
+// frame, discarded and dropped ones included.
+func (s *streamOutput) deliver(ctx context.Context, req *pb.InferRequest, resp *pb.InferResponse) bool {
+	s.observe(resp)
+	if resp.IsFinal {
+		return s.send(ctx, s.annotate(resp))
+	}
+	if s.discardPartials {
+		return true
+	}
+	switch s.policy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.ch <- resp:
+				return true
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.dropped++
+			default:
+				// The caller made room in the meantime.
+			}
+		}
+	case OverflowFail:
+		select {
+		case s.ch <- resp:
+			return true
+		default:
+			failed := backpressureResponse(req, cap(s.ch))
+			s.observe(failed)
+			s.send(ctx, failed)
+			return false
+		}
+	default:
+		return s.send(ctx, resp)
+	}
+}
+
+func (s *streamOutput) send(ctx context.Context, resp *pb.InferResponse) bool {
+	// A caller that cancels ctx may stop draining; never block on a full
+	// channel once the request is cancelled. One that abandons the channel
+	// without cancelling is given up on after the consumer timeout.
+	select {
+	case s.ch <- resp:
+		return true
+	default:
+	}
+	var expired <-chan time.Time
+	if s.consumerTimeout > 0 {
+		timer := time.NewTimer(s.consumerTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case s.ch <- resp:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-expired:
+		return false
+	}
+}
+
+// annotate records the dropped-frame count on a final frame without
+// mutating the received message's meta map.
+func (s *streamOutput) annotate(resp *pb.InferResponse) *pb.InferResponse {
+	if s.dropped == 0 {
+		return resp
+	}
+	meta := make(map[string]string, len(resp.Meta)+1)
+	for k, v := range resp.Meta {
+		meta[k] = v
+	}
+	meta[DroppedFramesMetaKey] = strconv.Itoa(s.dropped)
+	resp.Meta = meta
+	return resp
+}
+
+// backpressureResponse is the final frame OverflowFail delivers when the
+// caller falls behind.
+func backpressureResponse(req *pb.InferRequest, buffer int) *pb.InferResponse {
+	return &pb.InferResponse{
+		CorrelationId: req.CorrelationId,
+		IsFinal:       true,
+		Error: &pb.Error{
+			Code:    pb.ErrorCode_ERROR_CODE_UNAVAILABLE,
+			Message: "consumer backpressure: response channel full (capacity " + strconv.Itoa(buffer) + ")",
+		},
+	}
+}
@@ -0,0 +1,300 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"google.golang.org/grpc"
+)
+
+// ctxInferenceClient hands out a scriptedInferStream bound to the RPC
+// context, so Recv unblocks on cancellation like a real gRPC stream.
+type ctxInferenceClient struct {
+	fakeInferenceClient
+	stream *scriptedInferStream
+}
+
+func (c *ctxInferenceClient) Infer(ctx context.Context, _ ...grpc.CallOption) (grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse], error) {
+	c.stream.ctx = ctx
+	return c.stream, nil
+}
+
+// scriptedInferStream answers with final once answerAfter chunks have been
+// sent, and fails Send at failAt when set. Without a final frame, Recv blocks
+// until the stream context ends.
+type scriptedInferStream struct {
+	fakeInferStream
+	ctx         context.Context
+	final       *pb.InferResponse
+	answerAfter int64
+	failAt      int64
+	sends       atomic.Int64
+	ready       chan struct{}
+	once        sync.Once
+	delivered   atomic.Bool
+}
+
+func newScriptedInferStream(final *pb.InferResponse, answerAfter, failAt int64) *scriptedInferStream {
+	return &scriptedInferStream{final: final, answerAfter: answerAfter, failAt: failAt, ready: make(chan struct{})}
+}
+
+func (s *scriptedInferStream) Send(*pb.InferRequest) error {
+	n := s.sends.Add(1)
+	if n >= s.answerAfter {
+		s.once.Do(func() { close(s.ready) })
+	}
+	if s.failAt > 0 && n >= s.failAt {
+		return errors.New("connection reset")
+	}
+	time.Sleep(time.Millisecond)
+	return nil
+}
+
+func (s *scriptedInferStream) Recv() (*pb.InferResponse, error) {
+	<-s.ready
+	if s.final != nil {
+		if s.delivered.CompareAndSwap(false, true) {
+			return s.final, nil
+		}
+		return nil, io.EOF
+	}
+	<-s.ctx.Done()
+	return nil, s.ctx.Err()
+}
+
+func chunkedStreamClient(stream *scriptedInferStream) (*LumenClient, *pb.InferRequest) {
+	c := newFakeLumenClient(&ctxInferenceClient{stream: stream}, config.ChunkConfig{
+		EnableAuto:    true,
+		Threshold:     64,
+		MaxChunkBytes: 16,
+	})
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).
+		ForSemanticTextEmbed(strings.Repeat("x", 200*16)).
+		Build()
+	return c, req
+}
+
+func collectStream(t *testing.T, ch <-chan *pb.InferResponse) []*pb.InferResponse {
+	t.Helper()
+	var frames []*pb.InferResponse
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case resp, ok := <-ch:
+			if !ok {
+				return frames
+			}
+			frames = append(frames, resp)
+		case <-timeout:
+			t.Fatal("response channel not closed")
+		}
+	}
+}
+
+func TestInferStreamEarlyFinalStopsSender(t *testing.T) {
+	stream := newScriptedInferStream(&pb.InferResponse{
+		IsFinal: true,
+		Error:   &pb.Error{Code: pb.ErrorCode_ERROR_CODE_INVALID_ARGUMENT, Message: "payload rejected"},
+	}, 1, 0)
+	c, req := chunkedStreamClient(stream)
+
+	ch, err := c.InferStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	frames := collectStream(t, ch)
+
+	if len(frames) != 1 || frames[0].GetError().GetMessage() != "payload rejected" {
+		t.Fatalf("frames = %v, want the node's final error frame", frames)
+	}
+	// The channel closes only after the sender has exited, so the count is
+	// stable here.
+	if sent := stream.sends.Load(); sent >= 200 {
+		t.Fatalf("sent %d chunks after an early final frame, want the sender stopped", sent)
+	}
+}
+
+func TestInferStreamSendFailureSynthesizesErrorFrame(t *testing.T) {
+	stream := newScriptedInferStream(nil, 1, 3)
+	c, req := chunkedStreamClient(stream)
+
+	ch, err := c.InferStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	frames := collectStream(t, ch)
+
+	if len(frames) != 1 {
+		t.Fatalf("got %d frames, want 1 synthesized error frame", len(frames))
+	}
+	final := frames[0]
+	if !final.IsFinal || final.GetError().GetCode() != pb.ErrorCode_ERROR_CODE_UNAVAILABLE {
+		t.Fatalf("frame = %v, want final UNAVAILABLE error", final)
+	}
+	if !strings.Contains(final.GetError().GetMessage(), "connection reset") {
+		t.Fatalf("error message = %q, want the send error", final.GetError().GetMessage())
+	}
+	if final.CorrelationId != req.CorrelationId {
+		t.Fatalf("correlation id = %q, want %q", final.CorrelationId, req.CorrelationId)
+	}
+}
+
+func TestInferStreamChunksLargePayloads(t *testing.T) {
+	// Answer only after the whole upload, like a well-behaved node.
+	stream := newScriptedInferStream(&pb.InferResponse{IsFinal: true, Result: []byte("ok")}, 200, 0)
+	c, req := chunkedStreamClient(stream)
+
+	ch, err := c.InferStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	frames := collectStream(t, ch)
+
+	if len(frames) != 1 || string(frames[0].Result) != "ok" {
+		t.Fatalf("frames = %v, want the final result", frames)
+	}
+	if sent := stream.sends.Load(); sent != 200 {
+		t.Fatalf("sent %d chunks, want 200", sent)
+	}
+}
+
+func TestInferDetailedReportsChunking(t *testing.T) {
+	stream := newScriptedInferStream(&pb.InferResponse{IsFinal: true, Result: []byte("ok")}, 200, 0)
+	c, req := chunkedStreamClient(stream)
+
+	result, err := c.InferDetailed(context.Background(), req)
+	if err != nil {
+		t.Fatalf("InferDetailed: %v", err)
+	}
+	want := ChunkInfo{PayloadBytes: len(req.Payload), Chunks: 200, ChunkBytes: 16, Threshold: 64}
+	if result.Chunking != want {
+		t.Fatalf("chunking = %+v, want %+v", result.Chunking, want)
+	}
+	if meta := result.Response.Meta; meta[ChunksMetaKey] != "200" || meta[ChunkBytesMetaKey] != "16" {
+		t.Fatalf("response meta = %v, want chunk keys", meta)
+	}
+
+	single := &fakeInferStream{responses: []*pb.InferResponse{{IsFinal: true, Result: []byte("ok")}}}
+	c = newFakeLumenClient(&streamInferenceClient{stream: single}, config.ChunkConfig{EnableAuto: true, Threshold: 64, MaxChunkBytes: 16})
+	req.Payload = []byte("small")
+	result, err = c.InferDetailed(context.Background(), req)
+	if err != nil {
+		t.Fatalf("InferDetailed: %v", err)
+	}
+	if result.Chunking.Chunked() || result.Chunking.ChunkBytes != 5 || result.Response.Meta[ChunksMetaKey] != "1" {
+		t.Fatalf("chunking = %+v meta = %v, want a single unchunked message", result.Chunking, result.Response.Meta)
+	}
+}
+
+// burstInferStream returns partials frames back to back followed by a final
+// frame, then signals finalOut, simulating a node far faster than the caller.
+type burstInferStream struct {
+	fakeInferStream
+	recvs    atomic.Int64
+	finalOut chan struct{}
+}
+
+func newBurstInferStream(partials int) *burstInferStream {
+	s := &burstInferStream{finalOut: make(chan struct{})}
+	for i := 0; i < partials; i++ {
+		s.responses = append(s.responses, &pb.InferResponse{Seq: uint64(i), Result: []byte("partial")})
+	}
+	s.responses = append(s.responses, &pb.InferResponse{Seq: uint64(partials), IsFinal: true, Result: []byte("final")})
+	return s
+}
+
+func (s *burstInferStream) Recv() (*pb.InferResponse, error) {
+	resp, err := s.fakeInferStream.Recv()
+	if err == nil {
+		s.recvs.Add(1)
+		if resp.IsFinal {
+			close(s.finalOut)
+		}
+	}
+	return resp, err
+}
+
+func slowConsumerStream(t *testing.T, partials int, opts ...StreamOption) (*burstInferStream, <-chan *pb.InferResponse) {
+	t.Helper()
+	stream := newBurstInferStream(partials)
+	c := newFakeLumenClient(&streamInferenceClient{stream: stream}, config.ChunkConfig{})
+	ctx := WithStreamOptions(context.Background(), opts...)
+	ch, err := c.InferStream(ctx, types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("x").Build())
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	return stream, ch
+}
+
+func frameSeqs(frames []*pb.InferResponse) []uint64 {
+	seqs := make([]uint64, len(frames))
+	for i, f := range frames {
+		seqs[i] = f.Seq
+	}
+	return seqs
+}
+
+func TestInferStreamOverflowBlockDeliversEverything(t *testing.T) {
+	stream, ch := slowConsumerStream(t, 20, WithStreamBuffer(4))
+
+	// The receiver stalls on the full channel instead of reading ahead.
+	waitUntil(t, func() bool { return len(ch) == 4 })
+	time.Sleep(20 * time.Millisecond)
+	if got := stream.recvs.Load(); got > 5 {
+		t.Fatalf("received %d frames with a stalled caller, want at most 5", got)
+	}
+
+	frames := collectStream(t, ch)
+	if len(frames) != 21 || !frames[20].IsFinal {
+		t.Fatalf("got %d frames, want all 21", len(frames))
+	}
+}
+
+func TestInferStreamOverflowDropOldestKeepsLatest(t *testing.T) {
+	stream, ch := slowConsumerStream(t, 20, WithStreamBuffer(4), WithOverflowPolicy(OverflowDropOldest))
+
+	select {
+	case <-stream.finalOut:
+	case <-time.After(5 * time.Second):
+		t.Fatal("receiver did not keep reading from the node")
+	}
+	frames := collectStream(t, ch)
+
+	if got := frameSeqs(frames); !reflect.DeepEqual(got, []uint64{16, 17, 18, 19, 20}) {
+		t.Fatalf("frame seqs = %v, want the latest partials and the final frame", got)
+	}
+	final := frames[len(frames)-1]
+	if !final.IsFinal || final.Meta[DroppedFramesMetaKey] != "16" {
+		t.Fatalf("final frame meta = %v, want %s=16", final.Meta, DroppedFramesMetaKey)
+	}
+}
+
+func TestInferStreamOverflowFailEndsStream(t *testing.T) {
+	stream, ch := slowConsumerStream(t, 20, WithStreamBuffer(4), WithOverflowPolicy(OverflowFail))
+
+	waitUntil(t, func() bool { return stream.recvs.Load() == 5 })
+	frames := collectStream(t, ch)
+
+	if len(frames) != 5 {
+		t.Fatalf("got %d frames, want 4 partials and a backpressure error", len(frames))
+	}
+	final := frames[4]
+	if !final.IsFinal || final.GetError().GetCode() != pb.ErrorCode_ERROR_CODE_UNAVAILABLE ||
+		!strings.Contains(final.GetError().GetMessage(), "consumer backpressure") {
+		t.Fatalf("final frame = %v, want a consumer backpressure error", final)
+	}
+	if got := stream.recvs.Load(); got != 5 {
+		t.Fatalf("received %d frames, want the stream abandoned after 5", got)
+	}
+}
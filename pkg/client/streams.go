@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// nodeStreams counts the RPC streams picked for one node. A stream opens
+// when the picker hands out the node and closes when gRPC reports the RPC
+// done, on every exit path. The counters are shared with the subConnState a
+// recycled connection is promoted to, so streams opened before the swap
+// are still counted.
+type nodeStreams struct {
+	open  atomic.Int64
+	peak  atomic.Int64
+	total atomic.Int64
+	// overAge counts open streams older than the pool's stream max age.
+	overAge atomic.Int64
+}
+
+// StreamStats counts the RPC streams, unary calls included, opened against
+// one node. Open should return to zero once callers finish their requests;
+// a count that stays up, or OverAge above zero, points at a leaked stream.
+type StreamStats struct {
+	Open  int64 `json:"open"`
+	Peak  int64 `json:"peak"`
+	Total int64 `json:"total"`
+	// OverAge is how many open streams have been open longer than
+	// pool.stream_max_age.
+	OverAge int64 `json:"over_age,omitempty"`
+}
+
+func (s *nodeStreams) opened() int64 {
+	s.total.Add(1)
+	n := s.open.Add(1)
+	for {
+		peak := s.peak.Load()
+		if n <= peak || s.peak.CompareAndSwap(peak, n) {
+			return n
+		}
+	}
+}
+
+func (s *nodeStreams) closed() int64 {
+	return s.open.Add(-1)
+}
+
+func (s *nodeStreams) inFlight() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.open.Load()
+}
+
+func (s *nodeStreams) snapshot() StreamStats {
+	return StreamStats{
+		Open:    s.open.Load(),
+		Peak:    s.peak.Load(),
+		Total:   s.total.Load(),
+		OverAge: s.overAge.Load(),
+	}
+}
+
+// trackStream records a stream opened to node for the RPC of ctx and
+// returns the function that records it closing. It warns when the node's
+// open streams first exceed the balancer's threshold and when the stream
+// outlives the max age, both signs of streams that are never closed. The
+// warnings go to the request logger of ctx when it has one.
+func (lb *lumenBalancer) trackStream(ctx context.Context, node string, streams *nodeStreams, task string) func() {
+	log := lb.log().With(zap.String("task", task))
+	if utils.HasRequestLogger(ctx) {
+		log = utils.LoggerFrom(ctx)
+	}
+	open := streams.opened()
+	if limit := lb.options.streamWarnThreshold; limit > 0 && open == int64(limit)+1 {
+		log.Warn("open streams to node exceed threshold, streams may be leaking",
+			zap.String("id", node),
+			zap.Int64("open", open),
+			zap.Int("threshold", limit),
+		)
+	}
+
+	var timer *time.Timer
+	if maxAge := lb.options.streamMaxAge; maxAge > 0 {
+		timer = time.AfterFunc(maxAge, func() {
+			streams.overAge.Add(1)
+			log.Warn("stream open longer than max age, likely leaked",
+				zap.String("id", node),
+				zap.Duration("max_age", maxAge),
+			)
+		})
+	}
+	var done atomic.Bool
+	return func() {
+		if !done.CompareAndSwap(false, true) {
+			return
+		}
+		if timer != nil && !timer.Stop() {
+			streams.overAge.Add(-1)
+		}
+		if streams.closed() == 0 && lb.registry != nil {
+			lb.registry.nodeIdle(node)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gatedInferServer holds every Infer stream open until release is closed,
+// then answers it, or fails it when fail is set.
+type gatedInferServer struct {
+	testInferenceServer
+	release chan struct{}
+	fail    bool
+}
+
+func (s *gatedInferServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if req.Total <= 1 || req.Seq+1 == req.Total {
+			break
+		}
+	}
+	select {
+	case <-s.release:
+	case <-stream.Context().Done():
+		return stream.Context().Err()
+	}
+	if s.fail {
+		return status.Error(codes.Internal, "boom")
+	}
+	return stream.Send(&pb.InferResponse{IsFinal: true})
+}
+
+func newGatedServer(fail bool) *gatedInferServer {
+	return &gatedInferServer{
+		testInferenceServer: testInferenceServer{tasks: []string{"semantic_text_embed"}},
+		release:             make(chan struct{}),
+		fail:                fail,
+	}
+}
+
+// inferConcurrently starts n Infer calls and returns a function that waits
+// for them and returns their errors.
+func inferConcurrently(ctx context.Context, c *LumenClient, n int) func() []error {
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = c.Infer(ctx, embedRequest())
+		}(i)
+	}
+	return func() []error {
+		wg.Wait()
+		return errs
+	}
+}
+
+func waitOpenStreams(t *testing.T, c *LumenClient, want int64) {
+	t.Helper()
+	waitUntil(t, func() bool { return c.pool.Stats().OpenStreams == want })
+}
+
+func TestStreamCountsReturnToZero(t *testing.T) {
+	const n = 6
+	for _, tc := range []struct {
+		name    string
+		fail    bool
+		cancel  bool
+		wantErr bool
+	}{
+		{name: "completed"},
+		{name: "server error", fail: true, wantErr: true},
+		{name: "cancelled", cancel: true, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newGatedServer(tc.fail)
+			c := startClientFor(t, srv)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			wait := inferConcurrently(ctx, c, n)
+			waitOpenStreams(t, c, n)
+			if info := nodeInfoByID(c.pool.NodeInfos(), "local-node-1"); info.InFlight != n {
+				t.Fatalf("node in flight = %d, want %d", info.InFlight, n)
+			}
+
+			if tc.cancel {
+				cancel()
+			} else {
+				close(srv.release)
+			}
+			for _, err := range wait() {
+				if (err != nil) != tc.wantErr {
+					t.Fatalf("Infer error = %v, want error: %v", err, tc.wantErr)
+				}
+			}
+
+			waitOpenStreams(t, c, 0)
+			s := c.pool.Stats().Streams["local-node-1"]
+			if s.Open != 0 || s.Peak != n || s.Total != n {
+				t.Fatalf("stream stats = %+v, want 0 open, peak and total %d", s, n)
+			}
+		})
+	}
+}
+
+func TestStreamLeakWarnings(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	lb := &lumenBalancer{
+		logger:  zap.New(core),
+		options: balancerOptions{streamWarnThreshold: 2, streamMaxAge: 20 * time.Millisecond},
+	}
+	streams := new(nodeStreams)
+
+	var closers []func()
+	for i := 0; i < 4; i++ {
+		closers = append(closers, lb.trackStream(context.Background(), "local-node-1", streams, "ocr"))
+	}
+	if got := logs.FilterMessageSnippet("exceed threshold").Len(); got != 1 {
+		t.Fatalf("threshold warnings = %d, want 1 on crossing it", got)
+	}
+
+	waitUntil(t, func() bool { return streams.overAge.Load() == 4 })
+	if got := logs.FilterMessageSnippet("longer than max age").Len(); got != 4 {
+		t.Fatalf("max-age warnings = %d, want one per stream", got)
+	}
+
+	for _, closeStream := range closers {
+		closeStream()
+		closeStream() // closing twice must not count twice
+	}
+	if s := streams.snapshot(); s != (StreamStats{Peak: 4, Total: 4}) {
+		t.Fatalf("stats after closing = %+v", s)
+	}
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestPickMatchesVersionedAndWildcardTasks(t *testing.T) {
+	advertised := map[string][]string{
+		"embed": {"clip_text_embed@v1", "clip_text_embed@v2"},
+		"ocr":   {"ocr.*"},
+		"face":  {"face_detect"},
+	}
+	var nodes []*subConnState
+	for _, name := range []string{"embed", "ocr", "face"} {
+		nodes = append(nodes, &subConnState{
+			sc:       &namedSubConn{name: "local-" + name},
+			identity: discovery.NewNodeIdentity("local", name),
+			state:    connectivity.Ready,
+			tasks:    advertised[name],
+		})
+	}
+	picker := explainFixture(nodes...).picker.Load()
+
+	tests := []struct {
+		task     string
+		node     string
+		resolved string
+	}{
+		{"clip_text_embed", "local-embed", "clip_text_embed@v2"},
+		{"clip_text_embed@v1", "local-embed", "clip_text_embed@v1"},
+		{"ocr.detect", "local-ocr", "ocr.detect"},
+		{"ocr.recognize@v2", "local-ocr", "ocr.recognize@v2"},
+		{"face_detect", "local-face", "face_detect"},
+		{"face_detect@v2", "", ""},
+		{"clip_text_embed@v3", "", ""},
+		{"ocr", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.task, func(t *testing.T) {
+			var node pickedNode
+			res, err := picker.Pick(balancer.PickInfo{Ctx: withPickedNode(WithTask(context.Background(), tt.task), &node)})
+			if tt.node == "" {
+				if err == nil || !strings.Contains(err.Error(), "no node supports task") {
+					t.Fatalf("Pick = %v, %v; want no node supports task", res.SubConn, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Pick: %v", err)
+			}
+			if name := res.SubConn.(*namedSubConn).name; name != tt.node {
+				t.Fatalf("picked %s, want %s", name, tt.node)
+			}
+			if got := node.resolvedTask(); got != tt.resolved {
+				t.Fatalf("resolved task = %q, want %q", got, tt.resolved)
+			}
+		})
+	}
+}
+
+func TestNodeInfoSupportsVersionedAndWildcardTasks(t *testing.T) {
+	node := &discovery.NodeInfo{Capabilities: []*pb.Capability{{
+		ServiceName: "vision",
+		Tasks:       []*pb.IOTask{{Name: "clip_image_embed@v2"}, {Name: "ocr.*"}},
+	}}}
+	for task, want := range map[string]bool{
+		"clip_image_embed":    true,
+		"clip_image_embed@v2": true,
+		"clip_image_embed@v1": false,
+		"ocr.detect":          true,
+		"face_detect":         false,
+	} {
+		if got := node.SupportsTask(task); got != want {
+			t.Errorf("SupportsTask(%q) = %v, want %v", task, got, want)
+		}
+		if got := node.SupportsServiceTask("vision", task); got != want {
+			t.Errorf("SupportsServiceTask(vision, %q) = %v, want %v", task, got, want)
+		}
+	}
+	if services := node.MatchingServices("ocr.detect"); len(services) != 1 || services[0] != "vision" {
+		t.Errorf("MatchingServices(ocr.detect) = %v, want [vision]", services)
+	}
+}
+
+// metaRecordingServer answers every request with an empty final frame and
+// records the Meta of the first message of each stream.
+type metaRecordingServer struct {
+	testInferenceServer
+	mu   sync.Mutex
+	meta []map[string]string
+}
+
+func (s *metaRecordingServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	first := true
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first {
+			s.mu.Lock()
+			s.meta = append(s.meta, req.GetMeta())
+			s.mu.Unlock()
+			first = false
+		}
+	}
+	return stream.Send(&pb.InferResponse{IsFinal: true, ResultMime: "application/json", Result: []byte("{}")})
+}
+
+func (s *metaRecordingServer) lastMeta() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.meta) == 0 {
+		return nil
+	}
+	return s.meta[len(s.meta)-1]
+}
+
+func TestInferRecordsResolvedTask(t *testing.T) {
+	srv := &metaRecordingServer{testInferenceServer: testInferenceServer{tasks: []string{"custom_embed@v3"}}}
+	c := startClientFor(t, srv)
+	waitUntil(t, func() bool { return len(c.pool.nodeCapabilities("local-node-1")) > 0 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := &pb.InferRequest{CorrelationId: "r1", Task: "custom_embed", Payload: []byte("hi"), PayloadMime: "text/plain"}
+	if _, err := c.Infer(ctx, req); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+	if got := srv.lastMeta()[ResolvedTaskMetaKey]; got != "custom_embed@v3" {
+		t.Fatalf("node saw %s = %q, want custom_embed@v3", ResolvedTaskMetaKey, got)
+	}
+
+	frames, err := c.InferStream(ctx, &pb.InferRequest{CorrelationId: "r2", Task: "custom_embed@v3", Payload: []byte("hi"), PayloadMime: "text/plain"})
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	for range frames {
+	}
+	if got, ok := srv.lastMeta()[ResolvedTaskMetaKey]; ok {
+		t.Fatalf("exact match recorded %s = %q", ResolvedTaskMetaKey, got)
+	}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxLoggedTasks caps how many collected task names one GC log line lists.
+const maxLoggedTasks = 20
+
+// TaskStateRegistry gives per-task state one lifecycle. It records when
+// each task was last used, and Collect forgets the state of tasks that no
+// node serves and that have not been used for the retention period, in
+// every component registered with it. Components holding per-task state,
+// such as round-robin cursors and per-task latency, register with
+// Register rather than sweeping their own maps.
+type TaskStateRegistry struct {
+	retention time.Duration
+	// served returns the tasks some node currently serves; their state is
+	// never collected.
+	served func() map[string]bool
+	logger *zap.Logger
+
+	mu         sync.Mutex
+	lastSeen   map[string]time.Time
+	components []taskStateComponent
+}
+
+type taskStateComponent struct {
+	name   string
+	tasks  func() []string
+	forget func(task string)
+}
+
+// NewTaskStateRegistry returns a registry collecting the state of tasks
+// unused for retention and absent from served. A nil served treats every
+// task as unserved.
+func NewTaskStateRegistry(retention time.Duration, served func() map[string]bool, logger *zap.Logger) *TaskStateRegistry {
+	return &TaskStateRegistry{
+		retention: retention,
+		served:    served,
+		logger:    ensureLogger(logger),
+		lastSeen:  make(map[string]time.Time),
+	}
+}
+
+// Register adds a component holding per-task state: tasks lists the tasks
+// it holds state for and forget drops one task's state. Both are called
+// without the registry's lock held.
+func (r *TaskStateRegistry) Register(name string, tasks func() []string, forget func(task string)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.components = append(r.components, taskStateComponent{name: name, tasks: tasks, forget: forget})
+}
+
+// Touch records that task was used now.
+func (r *TaskStateRegistry) Touch(task string) {
+	if r == nil || task == "" {
+		return
+	}
+	now := time.Now()
+	r.mu.Lock()
+	r.lastSeen[task] = now
+	r.mu.Unlock()
+}
+
+// Tasks returns the number of tasks any component, or the registry itself,
+// holds state for.
+func (r *TaskStateRegistry) Tasks() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.resident(r.snapshotComponents()))
+}
+
+// Collect forgets the state of every task that no node serves and that was
+// last used at least the retention period before now, and returns those
+// tasks sorted. A task found in a component but never touched counts as
+// used at the first Collect that sees it.
+func (r *TaskStateRegistry) Collect(now time.Time) []string {
+	if r == nil {
+		return nil
+	}
+	components := r.snapshotComponents()
+	resident := r.resident(components)
+	var served map[string]bool
+	if r.served != nil {
+		served = r.served()
+	}
+
+	var collected []string
+	r.mu.Lock()
+	for task := range resident {
+		seen, ok := r.lastSeen[task]
+		switch {
+		case !ok:
+			r.lastSeen[task] = now
+		case served[task] || now.Sub(seen) < r.retention:
+		default:
+			delete(r.lastSeen, task)
+			collected = append(collected, task)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, task := range collected {
+		for _, c := range components {
+			c.forget(task)
+		}
+	}
+	if len(collected) > 0 {
+		sort.Strings(collected)
+		logged := collected
+		if len(logged) > maxLoggedTasks {
+			logged = logged[:maxLoggedTasks]
+		}
+		r.logger.Info("collected per-task state",
+			zap.Int("tasks", len(collected)),
+			zap.Strings("collected", logged),
+			zap.Int("remaining", len(resident)-len(collected)),
+		)
+	}
+	return collected
+}
+
+// run collects every interval until ctx is done.
+func (r *TaskStateRegistry) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.Collect(now)
+		}
+	}
+}
+
+func (r *TaskStateRegistry) snapshotComponents() []taskStateComponent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]taskStateComponent(nil), r.components...)
+}
+
+// resident returns the union of the tasks the components and the registry
+// hold state for.
+func (r *TaskStateRegistry) resident(components []taskStateComponent) map[string]bool {
+	out := make(map[string]bool)
+	for _, c := range components {
+		for _, task := range c.tasks() {
+			out[task] = true
+		}
+	}
+	r.mu.Lock()
+	for task := range r.lastSeen {
+		out[task] = true
+	}
+	r.mu.Unlock()
+	return out
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTaskStateRegistryBoundsChurningTasks(t *testing.T) {
+	const churn = 10000
+	latency := newLatencySet(time.Minute)
+	reg := &nodeRegistry{}
+	state := NewTaskStateRegistry(time.Minute, func() map[string]bool {
+		return map[string]bool{"ocr": true}
+	}, nil)
+	state.Register("task_latency", latency.keys, latency.forget)
+	state.Register("selection", reg.selectionTasks, reg.forgetTask)
+
+	now := time.Now()
+	for i := 0; i < churn; i++ {
+		task := fmt.Sprintf("synthetic_%d", i)
+		state.Touch(task)
+		latency.observe(task, time.Millisecond)
+		reg.advanceCursor(task, 3, now)
+	}
+	for _, task := range []string{"ocr", "embed"} {
+		state.Touch(task)
+		latency.observe(task, time.Millisecond)
+		reg.advanceCursor(task, 3, now)
+	}
+	if got := state.Tasks(); got != churn+2 {
+		t.Fatalf("resident tasks = %d, want %d", got, churn+2)
+	}
+
+	// Nothing is collected before the retention period has passed.
+	if got := state.Collect(time.Now().Add(30 * time.Second)); len(got) != 0 {
+		t.Fatalf("collected %d tasks before retention", len(got))
+	}
+
+	// embed is still in use; ocr is served by a node.
+	later := time.Now().Add(2 * time.Minute)
+	state.Touch("embed")
+	collected := state.Collect(later)
+	if len(collected) != churn+1 {
+		t.Fatalf("collected %d tasks, want %d", len(collected), churn+1)
+	}
+	if got := state.Tasks(); got != 1 {
+		t.Fatalf("resident tasks after GC = %d, want 1", got)
+	}
+	if len(latency.keys()) != 1 || len(reg.selectionTasks()) != 1 {
+		t.Fatalf("components kept %d latency and %d selection tasks, want 1 each",
+			len(latency.keys()), len(reg.selectionTasks()))
+	}
+	if _, ok := reg.cursors["ocr"]; !ok {
+		t.Fatal("served task lost its cursor")
+	}
+}
+
+func TestTaskStateRegistryStampsUntouchedTasks(t *testing.T) {
+	latency := newLatencySet(time.Minute)
+	latency.observe("ocr", time.Millisecond)
+	state := NewTaskStateRegistry(time.Minute, nil, nil)
+	state.Register("task_latency", latency.keys, latency.forget)
+
+	now := time.Now()
+	if got := state.Collect(now); len(got) != 0 {
+		t.Fatalf("collected %v at first sight, want nothing", got)
+	}
+	if got := state.Collect(now.Add(time.Minute)); len(got) != 1 || got[0] != "ocr" {
+		t.Fatalf("collected %v, want [ocr]", got)
+	}
+	if keys := latency.keys(); len(keys) != 0 {
+		t.Fatalf("latency kept %v", keys)
+	}
+}
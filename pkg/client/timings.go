@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Phases of a request to a node, as named in PhaseTimings and
+// DeadlineError.
+const (
+	// PhaseSelection is waiting for the balancer to pick a node, including
+	// waiting for one to connect.
+	PhaseSelection = "selection"
+	// PhaseConnect is opening the RPC stream to the picked node.
+	PhaseConnect = "connect"
+	// PhaseInference is sending the request and waiting for the final
+	// response.
+	PhaseInference = "inference"
+)
+
+// PhaseTimings breaks down where the time of an InferDetailed call went.
+// Phases a request never reached, such as every phase of a request served
+// by a local handler, are zero. Total includes middlewares.
+type PhaseTimings struct {
+	Selection time.Duration
+	Connect   time.Duration
+	Inference time.Duration
+	Total     time.Duration
+}
+
+// MarshalJSON encodes the timings in milliseconds, as selection_ms,
+// connect_ms, inference_ms and total_ms.
+func (t PhaseTimings) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return json.Marshal(struct {
+		Selection float64 `json:"selection_ms"`
+		Connect   float64 `json:"connect_ms"`
+		Inference float64 `json:"inference_ms"`
+		Total     float64 `json:"total_ms"`
+	}{ms(t.Selection), ms(t.Connect), ms(t.Inference), ms(t.Total)})
+}
+
+// DeadlineError is the error InferDetailed returns when the call's
+// deadline expires: the phase it expired in, the limit it was given and the
+// timings up to then. It unwraps to the underlying error.
+type DeadlineError struct {
+	Phase string
+	// Limit is the time the call had from its start until the deadline,
+	// or the timeout derived from the node's cost hints, counted from when
+	// the node was picked.
+	Limit   time.Duration
+	Timings PhaseTimings
+	Err     error
+}
+
+func (e *DeadlineError) Error() string {
+	return fmt.Sprintf("deadline of %s exceeded during %s (selection %s, connect %s, inference %s): %v",
+		e.Limit, e.Phase, e.Timings.Selection, e.Timings.Connect, e.Timings.Inference, e.Err)
+}
+
+func (e *DeadlineError) Unwrap() error { return e.Err }
+
+// phaseTimer records when a request reached each phase. The picker and the
+// stream code mark it from different goroutines, and a parallel upload picks
+// several times; each mark keeps the first time it was set.
+type phaseTimer struct {
+	mu                          sync.Mutex
+	start, picked, opened, done time.Time
+	// node is the node the request was first routed to.
+	node string
+	// timeout is the timeout derived from node's cost hints, if any.
+	timeout time.Duration
+}
+
+// mark sets the time field returns, unless already set. It is a no-op on a
+// nil timer, so callers need not check whether InferDetailed asked for one.
+func (t *phaseTimer) mark(field func(*phaseTimer) *time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if at := field(t); at.IsZero() {
+		*at = time.Now()
+	}
+	t.mu.Unlock()
+}
+
+func (t *phaseTimer) markStart()  { t.mark(func(t *phaseTimer) *time.Time { return &t.start }) }
+func (t *phaseTimer) markOpened() { t.mark(func(t *phaseTimer) *time.Time { return &t.opened }) }
+func (t *phaseTimer) markDone()   { t.mark(func(t *phaseTimer) *time.Time { return &t.done }) }
+
+// markPicked records when the request was first routed, and to which node.
+func (t *phaseTimer) markPicked(node string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.picked.IsZero() {
+		t.picked = time.Now()
+		t.node = node
+	}
+	t.mu.Unlock()
+}
+
+// setTimeout records the timeout derived from the picked node's cost
+// hints. It is a no-op on a nil timer.
+func (t *phaseTimer) setTimeout(d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.timeout = d
+	t.mu.Unlock()
+}
+
+// derivedTimeout returns the timeout set with setTimeout, or 0.
+func (t *phaseTimer) derivedTimeout() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timeout
+}
+
+// pickedNode returns the node the request was first routed to, or "".
+func (t *phaseTimer) pickedNode() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.node
+}
+
+// timings returns the durations of the phases the request completed or is
+// in, measured up to now for the current one, and that phase.
+func (t *phaseTimer) timings(now time.Time) (PhaseTimings, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var pt PhaseTimings
+	if t.start.IsZero() {
+		return pt, ""
+	}
+	end := now
+	if !t.done.IsZero() {
+		end = t.done
+	}
+	switch {
+	case t.picked.IsZero():
+		pt.Selection = end.Sub(t.start)
+		return pt, PhaseSelection
+	case t.opened.IsZero():
+		pt.Selection = t.picked.Sub(t.start)
+		pt.Connect = end.Sub(t.picked)
+		return pt, PhaseConnect
+	default:
+		pt.Selection = t.picked.Sub(t.start)
+		pt.Connect = t.opened.Sub(t.picked)
+		pt.Inference = end.Sub(t.opened)
+		return pt, PhaseInference
+	}
+}
+
+type phaseTimerKey struct{}
+
+// withPhaseTimer asks the dispatch and the picker to mark t; InferDetailed
+// uses it to see through the middleware chain.
+func withPhaseTimer(ctx context.Context, t *phaseTimer) context.Context {
+	return context.WithValue(ctx, phaseTimerKey{}, t)
+}
+
+func phaseTimerFrom(ctx context.Context) *phaseTimer {
+	t, _ := ctx.Value(phaseTimerKey{}).(*phaseTimer)
+	return t
+}
+
+// deadlineExpired reports whether err ended a call on ctx because its
+// deadline passed.
+func deadlineExpired(ctx context.Context, err error) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		status.Code(err) == codes.DeadlineExceeded
+}
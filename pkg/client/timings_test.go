@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+)
+
+// slowEchoServer echoes the request payload after delay.
+type slowEchoServer struct {
+	testInferenceServer
+	delay time.Duration
+}
+
+func (s *slowEchoServer) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	time.Sleep(s.delay)
+	return stream.Send(&pb.InferResponse{CorrelationId: req.CorrelationId, IsFinal: true, Result: req.Payload})
+}
+
+func TestInferDetailedReportsTimings(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	c := startClientFor(t, &slowEchoServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}, delay})
+
+	result, err := c.InferDetailed(context.Background(), embedRequest())
+	if err != nil {
+		t.Fatalf("InferDetailed: %v", err)
+	}
+	tm := result.Timings
+	if tm.Inference < delay {
+		t.Fatalf("inference took %s, want at least the server's %s", tm.Inference, delay)
+	}
+	if tm.Total < tm.Selection+tm.Connect+tm.Inference {
+		t.Fatalf("total %s is less than the sum of the phases %+v", tm.Total, tm)
+	}
+
+	data, err := json.Marshal(tm)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, key := range []string{`"selection_ms"`, `"connect_ms"`, `"inference_ms"`, `"total_ms"`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("timings JSON %s has no %s", data, key)
+		}
+	}
+}
+
+func TestInferDetailedNamesExpiredPhase(t *testing.T) {
+	t.Run("inference", func(t *testing.T) {
+		c, _ := startHangingClient(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		result, err := c.InferDetailed(ctx, embedRequest())
+		var derr *DeadlineError
+		if !errors.As(err, &derr) {
+			t.Fatalf("InferDetailed error = %v, want a DeadlineError", err)
+		}
+		if derr.Phase != PhaseInference {
+			t.Fatalf("phase = %q, want %q", derr.Phase, PhaseInference)
+		}
+		if derr.Limit <= 0 || derr.Limit > 200*time.Millisecond {
+			t.Fatalf("limit = %s, want the 200ms the call was given", derr.Limit)
+		}
+		if result == nil || result.Response != nil || result.Timings.Inference <= 0 {
+			t.Fatalf("result = %+v, want the timings up to the deadline and no response", result)
+		}
+	})
+
+	t.Run("selection", func(t *testing.T) {
+		c := startEmptyClusterClient(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := c.InferDetailed(ctx, embedRequest())
+		var derr *DeadlineError
+		if !errors.As(err, &derr) {
+			t.Fatalf("InferDetailed error = %v, want a DeadlineError", err)
+		}
+		if derr.Phase != PhaseSelection || derr.Timings.Selection <= 0 {
+			t.Fatalf("deadline error = %+v, want it to expire in selection", derr)
+		}
+	})
+}
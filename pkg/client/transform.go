@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// How ResponseTransform reduces embedding dimensions.
+const (
+	// PoolingTruncate keeps the first values (the default).
+	PoolingTruncate = "truncate"
+	// PoolingMean averages contiguous runs of values.
+	PoolingMean = "mean"
+)
+
+// ResponseTransform asks TransformMiddleware to shrink a result before it
+// is returned, for callers such as web clients that do not need it whole.
+// The zero value changes nothing.
+type ResponseTransform struct {
+	// Dimensions reduces embeddings to this many values; 0 keeps them all.
+	Dimensions int
+	// Pooling is how dimensions are reduced: PoolingTruncate or PoolingMean.
+	Pooling string
+	// Precision rounds embedding values to this many decimal places; 0
+	// keeps full precision.
+	Precision int
+	// MinConfidence drops face and OCR detections scored below it.
+	MinConfidence float32
+}
+
+// TransformLimits bound what a ResponseTransform may ask for. Requests past
+// a limit are brought within it; the applied values are reported under
+// types.TransformMetaKey.
+type TransformLimits struct {
+	// MinDimensions is the fewest embedding dimensions a request gets.
+	MinDimensions int
+	// MaxPrecision caps ResponseTransform.Precision; 0 leaves it uncapped.
+	MaxPrecision int
+}
+
+type responseTransformKey struct{}
+
+// WithResponseTransform has Infer calls made with ctx apply t to their
+// result, when the client uses TransformMiddleware.
+func WithResponseTransform(ctx context.Context, t ResponseTransform) context.Context {
+	return context.WithValue(ctx, responseTransformKey{}, t)
+}
+
+// ResponseTransformFromContext returns the transform set with
+// WithResponseTransform and whether one was set.
+func ResponseTransformFromContext(ctx context.Context) (ResponseTransform, bool) {
+	t, ok := ctx.Value(responseTransformKey{}).(ResponseTransform)
+	return t, ok
+}
+
+// TransformMiddleware applies the ResponseTransform set on each request's
+// context to its result: embedding_v1 results are reduced and rounded, and
+// face_v1 and ocr_v1 results filtered by confidence. The transforms are the
+// ones in pkg/types; the result is re-encoded at the current schema version
+// with the applied transforms listed under types.TransformMetaKey. Results
+// of other schemas, or of a schema version newer than the SDK knows, pass
+// through unchanged. An invalid transform fails the request with an INVALID
+// error before it is sent.
+func TransformMiddleware(limits TransformLimits) InferMiddleware {
+	return func(next InferFunc) InferFunc {
+		return func(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+			t, ok := ResponseTransformFromContext(ctx)
+			if !ok || t == (ResponseTransform{}) {
+				return next(ctx, req)
+			}
+			if err := t.validate(); err != nil {
+				return nil, err
+			}
+			t = t.within(limits)
+			resp, err := next(ctx, req)
+			if err != nil || resp.GetError() != nil {
+				return resp, err
+			}
+			if err := t.apply(resp); err != nil {
+				return nil, utils.ResponseFailedError(err.Error())
+			}
+			return resp, nil
+		}
+	}
+}
+
+func (t ResponseTransform) validate() error {
+	switch {
+	case t.Dimensions < 0:
+		return utils.InvalidError(fmt.Sprintf("transform dimensions must be non-negative, got %d", t.Dimensions))
+	case t.Pooling != "" && t.Pooling != PoolingTruncate && t.Pooling != PoolingMean:
+		return utils.InvalidError(fmt.Sprintf("transform pooling must be %s or %s, got %q", PoolingTruncate, PoolingMean, t.Pooling))
+	case t.Precision < 0:
+		return utils.InvalidError(fmt.Sprintf("transform precision must be non-negative, got %d", t.Precision))
+	case math.IsNaN(float64(t.MinConfidence)) || t.MinConfidence < 0 || t.MinConfidence > 1:
+		return utils.InvalidError(fmt.Sprintf("transform min_confidence must be between 0 and 1, got %g", t.MinConfidence))
+	}
+	return nil
+}
+
+func (t ResponseTransform) within(limits TransformLimits) ResponseTransform {
+	if t.Dimensions > 0 && t.Dimensions < limits.MinDimensions {
+		t.Dimensions = limits.MinDimensions
+	}
+	if limits.MaxPrecision > 0 && t.Precision > limits.MaxPrecision {
+		t.Precision = limits.MaxPrecision
+	}
+	return t
+}
+
+// apply rewrites resp's result in place.
+func (t ResponseTransform) apply(resp *pb.InferResponse) error {
+	parser := types.ParseInferResponse(resp)
+	var (
+		schema string
+		name   string
+		out    any
+		err    error
+	)
+	switch resp.ResultMime {
+	case "application/json;schema=embedding_v1":
+		var ts []types.Transform[types.EmbeddingV1]
+		if t.Dimensions > 0 {
+			if t.Pooling == PoolingMean {
+				ts = append(ts, types.MeanPoolDimensions(t.Dimensions))
+			} else {
+				ts = append(ts, types.TruncateDimensions(t.Dimensions))
+			}
+		}
+		if t.Precision > 0 {
+			ts = append(ts, types.RoundVector(t.Precision))
+		}
+		if len(ts) == 0 {
+			return nil
+		}
+		var e *types.EmbeddingV1
+		if e, err = parser.AsEmbeddingResponse(); err == nil {
+			chain := types.Chain(ts...)
+			schema, name, out = "embedding_v1", chain.Name, chain.Apply(*e)
+		}
+	case "application/json;schema=face_v1":
+		if t.MinConfidence == 0 {
+			return nil
+		}
+		var f *types.FaceV1
+		if f, err = parser.AsFaceResponse(); err == nil {
+			tr := types.MinFaceConfidence(t.MinConfidence)
+			schema, name, out = "face_v1", tr.Name, tr.Apply(*f)
+		}
+	case "application/json;schema=ocr_v1":
+		if t.MinConfidence == 0 {
+			return nil
+		}
+		var o *types.OCRV1
+		if o, err = parser.AsOCRResponse(); err == nil {
+			tr := types.MinOCRConfidence(t.MinConfidence)
+			schema, name, out = "ocr_v1", tr.Name, tr.Apply(*o)
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("transform result: %w", err)
+	}
+	if parser.CompatibilityWarning() != nil {
+		// Re-encoding would drop the fields this SDK does not know.
+		return nil
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return fmt.Errorf("transform result: %w", err)
+	}
+	resp.Result = data
+	if resp.Meta == nil {
+		resp.Meta = make(map[string]string, 2)
+	}
+	resp.Meta[types.SchemaVersionMetaKey] = strconv.Itoa(types.CurrentSchemaVersion(schema))
+	resp.Meta[types.TransformMetaKey] = name
+	return nil
+}
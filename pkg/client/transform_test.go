@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+func replyWith(schema string, v any) InferFunc {
+	return func(context.Context, *pb.InferRequest) (*pb.InferResponse, error) {
+		data, _ := json.Marshal(v)
+		return &pb.InferResponse{IsFinal: true, Result: data, ResultMime: "application/json;schema=" + schema}, nil
+	}
+}
+
+func TestTransformMiddlewareShrinksEmbeddings(t *testing.T) {
+	vector := make([]float32, 1024)
+	for i := range vector {
+		vector[i] = 1.0 / float32(i+3)
+	}
+	infer := TransformMiddleware(TransformLimits{MinDimensions: 64, MaxPrecision: 6})(
+		replyWith("embedding_v1", types.EmbeddingV1{Vector: vector, Dim: len(vector), ModelID: "clip"}))
+
+	ctx := WithResponseTransform(context.Background(), ResponseTransform{Dimensions: 256, Precision: 4})
+	resp, err := infer(ctx, embedRequest())
+	if err != nil {
+		t.Fatalf("infer: %v", err)
+	}
+	e, err := types.ParseInferResponse(resp).AsEmbeddingResponse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if e.Dim != 256 || len(e.Vector) != 256 || e.Vector[0] != 0.3333 || e.ModelID != "clip" {
+		t.Fatalf("embedding = dim %d, %d values, first %v", e.Dim, len(e.Vector), e.Vector[0])
+	}
+	if got := resp.Meta[types.TransformMetaKey]; got != "dimensions=256,precision=4" {
+		t.Fatalf("transform meta = %q", got)
+	}
+
+	// Limits bring requests within them.
+	ctx = WithResponseTransform(context.Background(), ResponseTransform{Dimensions: 8, Pooling: PoolingMean, Precision: 10})
+	resp, err = infer(ctx, embedRequest())
+	if err != nil {
+		t.Fatalf("infer: %v", err)
+	}
+	if got := resp.Meta[types.TransformMetaKey]; got != "dimensions=64/mean,precision=6" {
+		t.Fatalf("transform meta = %q, want the limits applied", got)
+	}
+
+	// Without a transform the result is untouched.
+	resp, err = infer(context.Background(), embedRequest())
+	if err != nil {
+		t.Fatalf("infer: %v", err)
+	}
+	if _, ok := resp.Meta[types.TransformMetaKey]; ok {
+		t.Fatal("result marked as transformed without a transform")
+	}
+}
+
+func TestTransformMiddlewareFiltersDetections(t *testing.T) {
+	infer := TransformMiddleware(TransformLimits{})(replyWith("ocr_v1", types.OCRV1{
+		Items: []types.OCRItem{{Text: "faint", Confidence: 0.2}, {Text: "clear", Confidence: 0.95}},
+		Count: 2,
+	}))
+	ctx := WithResponseTransform(context.Background(), ResponseTransform{MinConfidence: 0.5})
+	resp, err := infer(ctx, embedRequest())
+	if err != nil {
+		t.Fatalf("infer: %v", err)
+	}
+	ocr, err := types.ParseInferResponse(resp).AsOCRResponse()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ocr.Count != 1 || ocr.Items[0].Text != "clear" || resp.Meta[types.TransformMetaKey] != "min_confidence=0.5" {
+		t.Fatalf("ocr = %+v, meta %v", ocr, resp.Meta)
+	}
+}
+
+func TestTransformMiddlewareRejectsInvalid(t *testing.T) {
+	called := false
+	infer := TransformMiddleware(TransformLimits{})(func(context.Context, *pb.InferRequest) (*pb.InferResponse, error) {
+		called = true
+		return &pb.InferResponse{}, nil
+	})
+	for _, tr := range []ResponseTransform{
+		{Dimensions: -1},
+		{Dimensions: 8, Pooling: "max"},
+		{Precision: -2},
+		{MinConfidence: 1.5},
+	} {
+		_, err := infer(WithResponseTransform(context.Background(), tr), embedRequest())
+		if !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+			t.Errorf("transform %+v: error = %v, want INVALID", tr, err)
+		}
+	}
+	if called {
+		t.Fatal("an invalid transform still sent the request")
+	}
+}
@@ -0,0 +1,211 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+)
+
+// tlsHintKey is the TXT record a node sets to "required" when it only
+// accepts TLS, so the first dial already uses the right transport.
+const tlsHintKey = "tls"
+
+// transportAttrKey is the attributes key for nodeTransport. Unlike
+// nodeAttr, it is stored in resolver.Address.Attributes, which reach the
+// credentials handshake.
+type transportAttrKey struct{}
+
+// nodeTransport is what transport resolution needs to know about a node.
+// It must stay comparable: gRPC compares address attributes with ==.
+type nodeTransport struct {
+	nodeID      string // advertised ID
+	key         string // deployment-qualified ID
+	host        string // host the node was discovered at
+	tlsRequired bool   // TXT tls=required
+}
+
+func newNodeTransport(attr nodeAttr, endpoint string) nodeTransport {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host = endpoint
+	}
+	return nodeTransport{
+		nodeID:      attr.Identity.NodeID,
+		key:         attr.Identity.Key(),
+		host:        host,
+		tlsRequired: attr.Txt[tlsHintKey] == "required",
+	}
+}
+
+func setNodeTransport(addr resolver.Address, nt nodeTransport) resolver.Address {
+	addr.Attributes = addr.Attributes.WithValue(transportAttrKey{}, nt)
+	return addr
+}
+
+func getNodeTransport(attrs *attributes.Attributes) (nodeTransport, bool) {
+	nt, ok := attrs.Value(transportAttrKey{}).(nodeTransport)
+	return nt, ok
+}
+
+// nodeCredentials picks each node's transport credentials at handshake time
+// from config.PoolConfig's TLS and PerNode settings, so one ClientConn can
+// reach plaintext and mutual-TLS nodes alike.
+type nodeCredentials struct {
+	pool    config.PoolConfig
+	global  credentials.TransportCredentials
+	hinted  credentials.TransportCredentials
+	perNode map[string]credentials.TransportCredentials
+}
+
+// newNodeCredentials loads the certificates named by pool's transport
+// settings.
+func newNodeCredentials(pool config.PoolConfig) (*nodeCredentials, error) {
+	c := &nodeCredentials{pool: pool, perNode: make(map[string]credentials.TransportCredentials, len(pool.PerNode))}
+	var err error
+	if c.global, err = transportCredentials(pool.TLS); err != nil {
+		return nil, fmt.Errorf("pool.tls: %w", err)
+	}
+	hinted := pool.TLS
+	hinted.Mode = config.TransportTLS
+	if c.hinted, err = transportCredentials(hinted); err != nil {
+		return nil, fmt.Errorf("pool.tls: %w", err)
+	}
+	for pattern, t := range pool.PerNode {
+		if c.perNode[pattern], err = transportCredentials(t); err != nil {
+			return nil, fmt.Errorf("pool.per_node[%q]: %w", pattern, err)
+		}
+	}
+	return c, nil
+}
+
+// forNode resolves the credentials for one node: a matching PerNode entry,
+// else TLS when the node advertises tls=required, else the global setting.
+// A nil *nodeCredentials dials in plaintext.
+func (c *nodeCredentials) forNode(nt nodeTransport) credentials.TransportCredentials {
+	if c == nil {
+		return insecure.NewCredentials()
+	}
+	if nt.nodeID == "" {
+		return c.global
+	}
+	if _, pattern, ok := c.pool.NodeTransport(nt.nodeID, nt.key); ok {
+		return c.perNode[pattern]
+	}
+	if nt.tlsRequired && !c.pool.TLS.IsTLS() {
+		return c.hinted
+	}
+	return c.global
+}
+
+func (c *nodeCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	nt, ok := getNodeTransport(credentials.ClientHandshakeInfoFromContext(ctx).Attributes)
+	if !ok {
+		return c.global.ClientHandshake(ctx, authority, rawConn)
+	}
+	// The channel authority is the pool's "cluster" target; verify against
+	// the node's own host instead.
+	if nt.host != "" {
+		authority = nt.host
+	}
+	return c.forNode(nt).ClientHandshake(ctx, authority, rawConn)
+}
+
+func (c *nodeCredentials) ServerHandshake(net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("lumen node credentials are client-only")
+}
+
+func (c *nodeCredentials) Info() credentials.ProtocolInfo {
+	return c.global.Info()
+}
+
+func (c *nodeCredentials) Clone() credentials.TransportCredentials {
+	clone := *c
+	return &clone
+}
+
+// OverrideServerName is deprecated in gRPC and unused; ServerName in the
+// transport config serves the same purpose per node.
+func (c *nodeCredentials) OverrideServerName(string) error {
+	return nil
+}
+
+// transportCredentials builds gRPC credentials for t, reading its
+// certificate files.
+func transportCredentials(t config.TransportConfig) (credentials.TransportCredentials, error) {
+	if !t.IsTLS() {
+		return insecure.NewCredentials(), nil
+	}
+	tc := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca_file: %w", err)
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file %s: no PEM certificates found", t.CAFile)
+		}
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cert_file/key_file: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	switch {
+	case t.SPIFFEID():
+		// crypto/tls only checks DNS and IP SANs; a SPIFFE ID is a URI SAN,
+		// so verify the chain here and match the URI instead.
+		tc.InsecureSkipVerify = true
+		tc.VerifyPeerCertificate = verifySPIFFEID(tc.RootCAs, t.ServerName)
+	case t.ServerName != "":
+		tc.ServerName = t.ServerName
+	}
+	return credentials.NewTLS(tc), nil
+}
+
+// verifySPIFFEID accepts a peer whose leaf certificate chains to roots (the
+// system roots when nil) and carries want as a URI SAN.
+func verifySPIFFEID(roots *x509.CertPool, want string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("node presented no certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("parse node certificate: %w", err)
+			}
+			certs[i] = cert
+		}
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: x509.NewCertPool(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		if _, err := certs[0].Verify(opts); err != nil {
+			return err
+		}
+		for _, uri := range certs[0].URIs {
+			if uri.String() == want {
+				return nil
+			}
+		}
+		return fmt.Errorf("node certificate does not carry SPIFFE ID %s", want)
+	}
+}
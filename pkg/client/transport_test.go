@@ -0,0 +1,324 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const testSPIFFEID = "spiffe://lab.test/lumen-node"
+
+// testPKI is a CA with a node certificate (IP 127.0.0.1, SPIFFE ID
+// testSPIFFEID) and a client certificate, written as PEM files for config.
+type testPKI struct {
+	caFile, certFile, keyFile string
+	pool                      *x509.CertPool
+	node                      tls.Certificate
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "lumen test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage, uris []*url.URL, ips []net.IP) ([]byte, *ecdsa.PrivateKey) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			URIs:         uris,
+			IPAddresses:  ips,
+		}, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("issue certificate: %v", err)
+		}
+		return der, key
+	}
+	writePEM := func(name, typ string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	spiffe, _ := url.Parse(testSPIFFEID)
+	nodeDER, nodeKey := issue(2, x509.ExtKeyUsageServerAuth, []*url.URL{spiffe}, []net.IP{net.IPv4(127, 0, 0, 1)})
+	clientDER, clientKey := issue(3, x509.ExtKeyUsageClientAuth, nil, nil)
+	clientKeyDER, _ := x509.MarshalECPrivateKey(clientKey)
+
+	pki := &testPKI{
+		caFile:   writePEM("ca.pem", "CERTIFICATE", caDER),
+		certFile: writePEM("client.pem", "CERTIFICATE", clientDER),
+		keyFile:  writePEM("client-key.pem", "EC PRIVATE KEY", clientKeyDER),
+		pool:     x509.NewCertPool(),
+		node:     tls.Certificate{Certificate: [][]byte{nodeDER}, PrivateKey: nodeKey},
+	}
+	pki.pool.AddCert(ca)
+	return pki
+}
+
+// transport is a mutual-TLS config for nodes issued by pki.
+func (pki *testPKI) transport(serverName string) config.TransportConfig {
+	return config.TransportConfig{
+		Mode:       config.TransportTLS,
+		CAFile:     pki.caFile,
+		CertFile:   pki.certFile,
+		KeyFile:    pki.keyFile,
+		ServerName: serverName,
+	}
+}
+
+// startMTLSCapabilityServer serves tasks only to clients presenting a
+// certificate issued by pki.
+func startMTLSCapabilityServer(t *testing.T, pki *testPKI, tasks ...string) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{pki.node},
+		ClientCAs:    pki.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	pb.RegisterInferenceServer(server, &testInferenceServer{tasks: tasks})
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func nodeHasTask(pool *Pool, id, task string) bool {
+	for _, info := range pool.NodeInfos() {
+		if info.ID != id {
+			continue
+		}
+		for _, tk := range info.Tasks {
+			if tk.Name == task {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestPoolPerNodeTransport(t *testing.T) {
+	pki := newTestPKI(t)
+	plain := startCapabilityServer(t, "ocr")
+	secured := startMTLSCapabilityServer(t, pki, "semantic")
+
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{
+		ConnectTimeout: 2 * time.Second,
+		PerNode:        map[string]config.TransportConfig{"secure-*": pki.transport(testSPIFFEID)},
+	})
+	// Hints name neither task, so both must come from capability fetches
+	// made over each node's own transport.
+	legacy := discoveredNode("legacy-1", plain)
+	secure := discoveredNode("secure-1", secured)
+	legacy.Resolved.Txt = map[string]string{}
+	secure.Resolved.Txt = map[string]string{}
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{legacy, secure}}); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer pool.Close()
+
+	waitUntil(t, func() bool {
+		return nodeHasTask(pool, "local-legacy-1", "ocr") && nodeHasTask(pool, "local-secure-1", "semantic")
+	})
+	if s := pool.Stats(); s.HealthyConnections != 2 {
+		t.Fatalf("healthy = %d, want both nodes Ready", s.HealthyConnections)
+	}
+}
+
+func TestPoolRejectsWrongSPIFFEID(t *testing.T) {
+	pki := newTestPKI(t)
+	secured := startMTLSCapabilityServer(t, pki, "semantic")
+
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{
+		ConnectTimeout: time.Second,
+		PerNode:        map[string]config.TransportConfig{"local-secure-1": pki.transport("spiffe://lab.test/impostor")},
+	})
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{discoveredNode("secure-1", secured, "semantic")}}); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer pool.Close()
+
+	waitUntil(t, func() bool { return len(pool.Stats().LastErrors) == 1 })
+	if lastErr := pool.Stats().LastErrors["local-secure-1"]; lastErr.Code != string(utils.ErrCodeUnauthorized) {
+		t.Fatalf("last error = %+v, want UNAUTHORIZED", lastErr)
+	}
+	if s := pool.Stats(); s.HealthyConnections != 0 {
+		t.Fatalf("healthy = %d, want the node rejected", s.HealthyConnections)
+	}
+}
+
+// TestPoolTLSRequiredHint checks a node advertising tls=required is dialled
+// with the global TLS settings even though the default mode is insecure.
+func TestPoolTLSRequiredHint(t *testing.T) {
+	pki := newTestPKI(t)
+	secured := startMTLSCapabilityServer(t, pki, "semantic")
+
+	global := pki.transport(testSPIFFEID)
+	global.Mode = config.TransportInsecure
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{ConnectTimeout: 2 * time.Second, TLS: global})
+	ev := discoveredNode("secure-1", secured)
+	ev.Resolved.Txt = map[string]string{"tls": "required"}
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{ev}}); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer pool.Close()
+
+	waitUntil(t, func() bool { return nodeHasTask(pool, "local-secure-1", "semantic") })
+}
+
+// bigCapabilityServer answers capability fetches with a message of about
+// size bytes, above gRPC's default receive limit when size is over 4 MiB.
+type bigCapabilityServer struct {
+	testInferenceServer
+	size int
+}
+
+func (s *bigCapabilityServer) capability() *pb.Capability {
+	cap := s.testInferenceServer.capability()
+	cap.Tasks[0].Limits = map[string]string{"padding": strings.Repeat("x", s.size)}
+	return cap
+}
+
+func (s *bigCapabilityServer) GetCapabilities(context.Context, *emptypb.Empty) (*pb.Capability, error) {
+	return s.capability(), nil
+}
+
+func (s *bigCapabilityServer) StreamCapabilities(_ *emptypb.Empty, stream grpc.ServerStreamingServer[pb.Capability]) error {
+	return stream.Send(s.capability())
+}
+
+// TestPoolCapabilityFetchUsesPoolDialOptions checks capability fetches are
+// dialled with the pool's message size, compression and per-node TLS: a node
+// whose capabilities exceed 4 MiB and a TLS-only node both become active.
+func TestPoolCapabilityFetchUsesPoolDialOptions(t *testing.T) {
+	pki := newTestPKI(t)
+	large := startInferenceServer(t, &bigCapabilityServer{testInferenceServer: testInferenceServer{tasks: []string{"ocr"}}, size: 6 << 20})
+	secured := startMTLSCapabilityServer(t, pki, "semantic")
+
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{
+		ConnectTimeout: 2 * time.Second,
+		MaxMessageSize: 16 << 20,
+		Compression:    config.CompressionGzip,
+		PerNode:        map[string]config.TransportConfig{"secure-*": pki.transport(testSPIFFEID)},
+	})
+	// Hints name neither task, so both must come from capability fetches.
+	big := discoveredNode("big-1", large)
+	secure := discoveredNode("secure-1", secured)
+	big.Resolved.Txt = map[string]string{}
+	secure.Resolved.Txt = map[string]string{}
+	if err := pool.Connect(&fakeNodeResolver{events: []discovery.NodeEvent{big, secure}}); err != nil {
+		t.Fatalf("Connect error: %v", err)
+	}
+	defer pool.Close()
+
+	waitUntil(t, func() bool {
+		return nodeHasTask(pool, "local-big-1", "ocr") && nodeHasTask(pool, "local-secure-1", "semantic") &&
+			nodeInfoByID(pool.NodeInfos(), "local-big-1").Status == discovery.NodeStatusActive &&
+			nodeInfoByID(pool.NodeInfos(), "local-secure-1").Status == discovery.NodeStatusActive
+	})
+}
+
+func TestPoolDialOptionsMessageSizeAndCompression(t *testing.T) {
+	if n := len(PoolOptions{KeepAlive: -1}.normalized().dialOptions()); n != 0 {
+		t.Fatalf("defaults gave %d dial options, want none", n)
+	}
+	if n := len(PoolOptions{KeepAlive: -1, Compression: config.CompressionNone}.normalized().dialOptions()); n != 0 {
+		t.Fatalf("compression none gave %d dial options, want none", n)
+	}
+	opts := PoolOptions{KeepAlive: -1, MaxMessageSize: 16 << 20, Compression: config.CompressionGzip}.normalized().dialOptions()
+	if len(opts) != 1 {
+		t.Fatalf("got %d dial options, want the default call options alone", len(opts))
+	}
+}
+
+func TestNodeCredentialsResolution(t *testing.T) {
+	creds, err := newNodeCredentials(config.PoolConfig{
+		PerNode: map[string]config.TransportConfig{
+			"lab-*":       {Mode: config.TransportTLS},
+			"lab-legacy":  {Mode: config.TransportInsecure},
+			"local-gpu-?": {Mode: config.TransportTLS, ServerName: "gpu.lab.test"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newNodeCredentials: %v", err)
+	}
+	node := func(id string, tlsRequired bool) nodeTransport {
+		return nodeTransport{nodeID: id, key: "local-" + id, tlsRequired: tlsRequired}
+	}
+	tests := []struct {
+		name string
+		nt   nodeTransport
+		want credentials.TransportCredentials
+	}{
+		{"pattern", node("lab-3", false), creds.perNode["lab-*"]},
+		{"exact beats pattern", node("lab-legacy", true), creds.perNode["lab-legacy"]},
+		{"deployment-qualified ID", node("gpu-1", false), creds.perNode["local-gpu-?"]},
+		{"hint", node("edge-1", true), creds.hinted},
+		{"global", node("edge-1", false), creds.global},
+		{"unknown node", nodeTransport{}, creds.global},
+	}
+	for _, tt := range tests {
+		if got := creds.forNode(tt.nt); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got.Info(), tt.want.Info())
+		}
+	}
+	if got := (*nodeCredentials)(nil).forNode(node("lab-3", true)); got.Info().SecurityProtocol != "insecure" {
+		t.Errorf("nil credentials: got %q, want insecure", got.Info().SecurityProtocol)
+	}
+}
+
+func TestPoolConnectRejectsUnreadableCA(t *testing.T) {
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{
+		PerNode: map[string]config.TransportConfig{"gpu-*": {Mode: config.TransportTLS, CAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+	})
+	err := pool.Connect(&fakeNodeResolver{})
+	if lumErr, ok := utils.GetLumenError(err); !ok || lumErr.Code != utils.ErrCodeInvalid {
+		t.Fatalf("Connect error = %v, want INVALID", err)
+	}
+}
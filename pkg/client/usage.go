@@ -0,0 +1,392 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// TenantMetaKey is the request Meta key carrying the tenant set with
+	// WithTenant, so nodes can attribute work too.
+	TenantMetaKey = "lumen.tenant"
+	// GPUTimeMetaKey is the response Meta key nodes report GPU time under,
+	// in milliseconds.
+	GPUTimeMetaKey = "gpu_time_ms"
+	// DefaultTenant is charged for requests without a tenant.
+	DefaultTenant = "default"
+
+	usageBucketSize         = time.Minute
+	defaultUsageRetention   = 24 * time.Hour
+	defaultUsageQuotaWindow = time.Hour
+)
+
+// TenantUsage is what one tenant's requests consumed.
+type TenantUsage = discovery.TenantUsage
+
+// UsageReport is per-tenant usage over a time window.
+type UsageReport = discovery.UsageReport
+
+type tenantKey struct{}
+
+// WithTenant attributes requests made with ctx to tenant: they are counted
+// under it in Usage and GetMetrics, limited by its quota, and carry it in
+// their Meta under TenantMetaKey.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set with WithTenant, or
+// DefaultTenant.
+func TenantFromContext(ctx context.Context) string {
+	if tenant, _ := ctx.Value(tenantKey{}).(string); tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// usageTracker accumulates per-tenant usage in per-minute buckets, kept for
+// the configured retention, plus totals since start. It is in memory only.
+type usageTracker struct {
+	retention   time.Duration
+	quotaWindow time.Duration
+	reject      bool
+	quotas      map[string]config.TenantQuota
+	logger      *zap.Logger
+
+	mu      sync.Mutex
+	tenants map[string]*tenantLedger
+}
+
+type tenantLedger struct {
+	total    TenantUsage
+	buckets  []usageBucket // ascending by start
+	warnedAt time.Time
+}
+
+type usageBucket struct {
+	start time.Time
+	usage TenantUsage
+}
+
+func newUsageTracker(cfg config.UsageConfig, logger *zap.Logger) *usageTracker {
+	t := &usageTracker{
+		retention:   cfg.Retention,
+		quotaWindow: cfg.QuotaWindow,
+		reject:      cfg.QuotaPolicy == config.QuotaPolicyReject,
+		quotas:      cfg.Quotas,
+		logger:      ensureLogger(logger),
+		tenants:     make(map[string]*tenantLedger),
+	}
+	if t.retention <= 0 {
+		t.retention = defaultUsageRetention
+	}
+	if t.quotaWindow <= 0 {
+		t.quotaWindow = defaultUsageQuotaWindow
+	}
+	return t
+}
+
+func (t *usageTracker) ledgerLocked(tenant string) *tenantLedger {
+	l, ok := t.tenants[tenant]
+	if !ok {
+		l = &tenantLedger{}
+		t.tenants[tenant] = l
+	}
+	return l
+}
+
+func (t *usageTracker) record(tenant string, at time.Time, u TenantUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l := t.ledgerLocked(tenant)
+	addUsage(&l.total, u)
+
+	start := at.Truncate(usageBucketSize)
+	if n := len(l.buckets); n > 0 && !l.buckets[n-1].start.Before(start) {
+		// Late records (a long request finishing) land in the newest
+		// bucket rather than reopening an old one.
+		addUsage(&l.buckets[n-1].usage, u)
+	} else {
+		l.buckets = append(l.buckets, usageBucket{start: start, usage: u})
+	}
+	cutoff := at.Add(-t.retention)
+	drop := 0
+	for drop < len(l.buckets) && l.buckets[drop].start.Add(usageBucketSize).Before(cutoff) {
+		drop++
+	}
+	l.buckets = l.buckets[drop:]
+}
+
+// windowLocked sums a tenant's buckets that overlap [since, until).
+func (l *tenantLedger) windowLocked(since, until time.Time) TenantUsage {
+	var u TenantUsage
+	for _, b := range l.buckets {
+		if b.start.Add(usageBucketSize).After(since) && b.start.Before(until) {
+			addUsage(&u, b.usage)
+		}
+	}
+	return u
+}
+
+// report returns per-tenant usage over [since, until), at per-minute
+// resolution. A zero since means the start of retention, a zero until now.
+func (t *usageTracker) report(since, until time.Time) *UsageReport {
+	now := time.Now()
+	if until.IsZero() {
+		until = now
+	}
+	if oldest := now.Add(-t.retention); since.IsZero() || since.Before(oldest) {
+		since = oldest
+	}
+	r := &UsageReport{Since: since, Until: until, Tenants: make(map[string]TenantUsage)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for tenant, l := range t.tenants {
+		if u := l.windowLocked(since, until); u != (TenantUsage{}) {
+			r.Tenants[tenant] = u
+		}
+	}
+	return r
+}
+
+// totals returns every tenant's usage since the client started.
+func (t *usageTracker) totals() map[string]TenantUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.tenants) == 0 {
+		return nil
+	}
+	out := make(map[string]TenantUsage, len(t.tenants))
+	for tenant, l := range t.tenants {
+		out[tenant] = l.total
+	}
+	return out
+}
+
+// admit checks tenant against its quota over the trailing quota window.
+// Over quota, it returns a FORBIDDEN error under the reject policy and logs
+// a warning, at most once per window, under the log policy.
+func (t *usageTracker) admit(tenant string, now time.Time) error {
+	quota, ok := t.quotas[tenant]
+	if !ok {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l := t.ledgerLocked(tenant)
+	used := l.windowLocked(now.Add(-t.quotaWindow), now.Add(usageBucketSize))
+	limit := exceededQuota(used, quota)
+	if limit == "" {
+		return nil
+	}
+	if t.reject {
+		l.total.Rejected++
+		l.buckets = appendRejected(l.buckets, now)
+		return utils.ForbiddenError("tenant "+tenant+" is over its "+limit+" quota", map[string]any{
+			"tenant": tenant,
+			"limit":  limit,
+			"window": t.quotaWindow.String(),
+		})
+	}
+	if l.warnedAt.IsZero() || now.Sub(l.warnedAt) >= t.quotaWindow {
+		l.warnedAt = now
+		t.logger.Warn("tenant over usage quota",
+			zap.String("tenant", tenant),
+			zap.String("limit", limit),
+			zap.Duration("window", t.quotaWindow),
+		)
+	}
+	return nil
+}
+
+func appendRejected(buckets []usageBucket, now time.Time) []usageBucket {
+	start := now.Truncate(usageBucketSize)
+	if n := len(buckets); n > 0 && !buckets[n-1].start.Before(start) {
+		buckets[n-1].usage.Rejected++
+		return buckets
+	}
+	return append(buckets, usageBucket{start: start, usage: TenantUsage{Rejected: 1}})
+}
+
+// exceededQuota names the first limit in q that used has reached, or
+// returns "".
+func exceededQuota(used TenantUsage, q config.TenantQuota) string {
+	switch {
+	case q.Requests > 0 && used.Requests >= q.Requests:
+		return "requests"
+	case q.Bytes > 0 && used.BytesIn+used.BytesOut >= q.Bytes:
+		return "bytes"
+	case q.Tokens > 0 && used.Tokens >= q.Tokens:
+		return "tokens"
+	case q.GPUTime > 0 && used.GPUTimeMs >= float64(q.GPUTime.Milliseconds()):
+		return "gpu_time"
+	}
+	return ""
+}
+
+func addUsage(dst *TenantUsage, u TenantUsage) {
+	dst.Requests += u.Requests
+	dst.Failed += u.Failed
+	dst.Rejected += u.Rejected
+	dst.BytesIn += u.BytesIn
+	dst.BytesOut += u.BytesOut
+	dst.Tokens += u.Tokens
+	dst.GPUTimeMs += u.GPUTimeMs
+	dst.TotalLatencyNs += u.TotalLatencyNs
+}
+
+// responseUsage extracts the tokens and GPU time a node reported in resp:
+// token counts from a text_generation_v1 result or the "tokens" meta, GPU
+// time from GPUTimeMetaKey.
+func responseUsage(resp *pb.InferResponse) (tokens int64, gpuMs float64) {
+	if resp == nil {
+		return 0, 0
+	}
+	// Partial frames rarely carry either key; parsing a missing one would
+	// allocate an error per frame.
+	if s, ok := resp.Meta[GPUTimeMetaKey]; ok {
+		if v, err := strconv.ParseFloat(s, 64); err == nil && v > 0 {
+			gpuMs = v
+		}
+	}
+	if resp.ResultMime == "application/json;schema=text_generation_v1" {
+		var gen struct {
+			GeneratedTokens int64 `json:"generated_tokens"`
+			InputTokens     int64 `json:"input_tokens"`
+		}
+		if json.Unmarshal(resp.Result, &gen) == nil {
+			tokens = gen.GeneratedTokens + gen.InputTokens
+		}
+	}
+	if s, ok := resp.Meta["tokens"]; ok && tokens == 0 {
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil && v > 0 {
+			tokens = v
+		}
+	}
+	return tokens, gpuMs
+}
+
+// tagTenant records tenant in the request's Meta.
+func tagTenant(req *pb.InferRequest, tenant string) {
+	if req.Meta == nil {
+		req.Meta = make(map[string]string)
+	}
+	req.Meta[TenantMetaKey] = tenant
+}
+
+// usageMiddleware attributes each Infer call to its tenant and enforces the
+// tenant's quota. It is installed inside metricsMiddleware, so rejected
+// requests count as failed in GetMetrics.
+func (c *LumenClient) usageMiddleware() InferMiddleware {
+	return func(next InferFunc) InferFunc {
+		return func(ctx context.Context, req *pb.InferRequest) (*pb.InferResponse, error) {
+			tenant := TenantFromContext(ctx)
+			start := time.Now()
+			if err := c.usage.admit(tenant, start); err != nil {
+				return nil, err
+			}
+			tagTenant(req, tenant)
+			u := TenantUsage{Requests: 1, BytesIn: int64(len(req.GetPayload()))}
+			resp, err := next(ctx, req)
+			end := time.Now()
+			u.TotalLatencyNs = end.Sub(start).Nanoseconds()
+			if err != nil {
+				u.Failed = 1
+			} else {
+				u.BytesOut = int64(len(resp.GetResult()))
+				u.Tokens, u.GPUTimeMs = responseUsage(resp)
+			}
+			c.usage.record(tenant, end, u)
+			return resp, err
+		}
+	}
+}
+
+// usageStreamMiddleware is the InferStream counterpart of usageMiddleware.
+// A stream is charged when it ends, with the bytes of every frame and the
+// tokens and GPU time of the last frame reporting them: nodes report totals
+// on the final frame. It observes the frames from the stream's own receiver
+// goroutine rather than relaying them through a second channel, so a stream
+// a caller abandons is still charged once the consumer timeout gives up on
+// it.
+func (c *LumenClient) usageStreamMiddleware() StreamMiddleware {
+	return func(next StreamFunc) StreamFunc {
+		return func(ctx context.Context, req *pb.InferRequest) (<-chan *pb.InferResponse, error) {
+			tenant := TenantFromContext(ctx)
+			start := time.Now()
+			if err := c.usage.admit(tenant, start); err != nil {
+				return nil, err
+			}
+			tagTenant(req, tenant)
+			u := TenantUsage{Requests: 1, BytesIn: int64(len(req.GetPayload()))}
+			obs := &streamObserver{
+				frame: func(resp *pb.InferResponse) {
+					u.BytesOut += int64(len(resp.GetResult()))
+					if tokens, gpuMs := responseUsage(resp); tokens > 0 || gpuMs > 0 {
+						u.Tokens, u.GPUTimeMs = tokens, gpuMs
+					}
+					if resp.GetError() != nil {
+						u.Failed = 1
+					}
+				},
+				done: func() {
+					end := time.Now()
+					u.TotalLatencyNs = end.Sub(start).Nanoseconds()
+					c.usage.record(tenant, end, u)
+				},
+			}
+			ch, err := next(withStreamObserver(ctx, obs), req)
+			if err != nil {
+				if obs.adopted.Load() {
+					// The stream that adopted obs charges itself.
+					return nil, err
+				}
+				u.Failed = 1
+				u.TotalLatencyNs = time.Since(start).Nanoseconds()
+				c.usage.record(tenant, time.Now(), u)
+				return nil, err
+			}
+			if obs.adopted.Load() {
+				return ch, nil
+			}
+
+			// A middleware further in answered with its own channel.
+			out := make(chan *pb.InferResponse)
+			go func() {
+				defer close(out)
+				defer obs.done()
+				for resp := range ch {
+					obs.frame(resp)
+					select {
+					case out <- resp:
+					case <-ctx.Done():
+						for range ch {
+						}
+						return
+					}
+				}
+			}()
+			return out, nil
+		}
+	}
+}
+
+// Usage reports per-tenant usage over [since, until) at per-minute
+// resolution. A zero since means as far back as Usage.Retention keeps, a
+// zero until means now.
+func (c *LumenClient) Usage(since, until time.Time) *UsageReport {
+	if c.usage == nil {
+		return &UsageReport{Since: since, Until: until, Tenants: map[string]TenantUsage{}}
+	}
+	return c.usage.report(since, until)
+}
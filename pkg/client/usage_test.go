@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// replyingInferenceClient answers every Infer stream with responses.
+type replyingInferenceClient struct {
+	fakeInferenceClient
+	responses []*pb.InferResponse
+	seen      []*pb.InferRequest
+}
+
+func (c *replyingInferenceClient) Infer(context.Context, ...grpc.CallOption) (grpc.BidiStreamingClient[pb.InferRequest, pb.InferResponse], error) {
+	return &recordingInferStream{
+		fakeInferStream: fakeInferStream{responses: append([]*pb.InferResponse(nil), c.responses...)},
+		seen:            &c.seen,
+	}, nil
+}
+
+type recordingInferStream struct {
+	fakeInferStream
+	seen *[]*pb.InferRequest
+}
+
+func (s *recordingInferStream) Send(req *pb.InferRequest) error {
+	*s.seen = append(*s.seen, req)
+	return nil
+}
+
+func newUsageClient(usage config.UsageConfig, responses ...*pb.InferResponse) (*LumenClient, *replyingInferenceClient) {
+	cli := &replyingInferenceClient{responses: responses}
+	c := newFakeLumenClient(cli, config.ChunkConfig{})
+	c.usage = newUsageTracker(usage, zap.NewNop())
+	return c, cli
+}
+
+func TestUsageAttributesRequestsToTenants(t *testing.T) {
+	c, cli := newUsageClient(config.UsageConfig{}, &pb.InferResponse{
+		IsFinal:    true,
+		Result:     []byte(`{"text":"hi","generated_tokens":7,"input_tokens":5}`),
+		ResultMime: "application/json;schema=text_generation_v1",
+		Meta:       map[string]string{GPUTimeMetaKey: "12.5"},
+	})
+	req := func() *pb.InferRequest {
+		return types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hello").Build()
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Infer(WithTenant(context.Background(), "team-a"), req()); err != nil {
+			t.Fatalf("Infer: %v", err)
+		}
+	}
+	if _, err := c.Infer(context.Background(), req()); err != nil {
+		t.Fatalf("Infer: %v", err)
+	}
+
+	if got := cli.seen[0].Meta[TenantMetaKey]; got != "team-a" {
+		t.Fatalf("request meta tenant = %q, want team-a", got)
+	}
+	usage := c.GetMetrics().Usage
+	a := usage["team-a"]
+	if a.Requests != 2 || a.Tokens != 24 || a.GPUTimeMs != 25 || a.BytesIn == 0 || a.BytesOut == 0 || a.TotalLatencyNs <= 0 {
+		t.Fatalf("team-a usage = %+v", a)
+	}
+	if usage[DefaultTenant].Requests != 1 {
+		t.Fatalf("default tenant usage = %+v, want 1 request", usage[DefaultTenant])
+	}
+
+	report := c.Usage(time.Now().Add(-time.Minute), time.Time{})
+	if report.Tenants["team-a"] != a {
+		t.Fatalf("windowed usage = %+v, want %+v", report.Tenants["team-a"], a)
+	}
+	if report := c.Usage(time.Time{}, time.Now().Add(-2*time.Minute)); len(report.Tenants) != 0 {
+		t.Fatalf("usage before any request = %+v, want none", report.Tenants)
+	}
+}
+
+func TestUsageQuotaRejectPolicy(t *testing.T) {
+	c, _ := newUsageClient(config.UsageConfig{
+		QuotaPolicy: config.QuotaPolicyReject,
+		Quotas:      map[string]config.TenantQuota{"team-a": {Requests: 2}},
+	}, &pb.InferResponse{IsFinal: true, Result: []byte("ok")})
+	ctx := WithTenant(context.Background(), "team-a")
+	req := func() *pb.InferRequest {
+		return types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hello").Build()
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Infer(ctx, req()); err != nil {
+			t.Fatalf("Infer %d: %v", i, err)
+		}
+	}
+	_, err := c.Infer(ctx, req())
+	if lumErr, ok := utils.GetLumenError(err); !ok || lumErr.Code != utils.ErrCodeForbidden {
+		t.Fatalf("over-quota Infer error = %v, want FORBIDDEN", err)
+	}
+	if _, err := c.InferStream(ctx, req()); err == nil {
+		t.Fatal("over-quota InferStream was not rejected")
+	}
+	if _, err := c.Infer(WithTenant(context.Background(), "team-b"), req()); err != nil {
+		t.Fatalf("tenant without quota rejected: %v", err)
+	}
+
+	a := c.GetMetrics().Usage["team-a"]
+	if a.Requests != 2 || a.Rejected != 2 {
+		t.Fatalf("team-a usage = %+v, want 2 requests and 2 rejected", a)
+	}
+}
+
+func TestUsageQuotaLogPolicyAdmits(t *testing.T) {
+	tracker := newUsageTracker(config.UsageConfig{
+		Quotas: map[string]config.TenantQuota{"team-a": {Tokens: 10}},
+	}, zap.NewNop())
+	now := time.Now()
+	tracker.record("team-a", now, TenantUsage{Requests: 1, Tokens: 50})
+	if err := tracker.admit("team-a", now); err != nil {
+		t.Fatalf("log policy rejected: %v", err)
+	}
+	if l := tracker.tenants["team-a"]; l.warnedAt.IsZero() {
+		t.Fatal("over-quota tenant was not warned about")
+	}
+	// Usage older than the quota window no longer counts.
+	if limit := exceededQuota(tracker.tenants["team-a"].windowLocked(now.Add(time.Hour), now.Add(2*time.Hour)), config.TenantQuota{Tokens: 10}); limit != "" {
+		t.Fatalf("quota exceeded outside the window: %s", limit)
+	}
+}
+
+func TestUsageTrackerRetention(t *testing.T) {
+	tracker := newUsageTracker(config.UsageConfig{Retention: 10 * time.Minute}, zap.NewNop())
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 60; i++ {
+		tracker.record("team-a", start.Add(time.Duration(i)*time.Minute), TenantUsage{Requests: 1})
+	}
+	l := tracker.tenants["team-a"]
+	if len(l.buckets) > 12 {
+		t.Fatalf("kept %d buckets, want about 10 minutes' worth", len(l.buckets))
+	}
+	if l.total.Requests != 60 {
+		t.Fatalf("total requests = %d, want 60 regardless of retention", l.total.Requests)
+	}
+}
+
+func TestUsageCountsStreams(t *testing.T) {
+	c, _ := newUsageClient(config.UsageConfig{},
+		&pb.InferResponse{Result: []byte("par")},
+		&pb.InferResponse{IsFinal: true, Result: []byte("tial"), Meta: map[string]string{"tokens": "9", GPUTimeMetaKey: "3"}},
+	)
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hello").Build()
+	ch, err := c.InferStream(WithTenant(context.Background(), "team-a"), req)
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	for range ch {
+	}
+	a := c.GetMetrics().Usage["team-a"]
+	if a.Requests != 1 || a.BytesOut != 7 || a.Tokens != 9 || a.GPUTimeMs != 3 {
+		t.Fatalf("stream usage = %+v", a)
+	}
+}
+
+// TestUsageChargesAbandonedStream checks usage accounting adds no channel
+// of its own: the caller gets the stream's buffered channel, and a stream
+// the caller abandons is released and still charged.
+func TestUsageChargesAbandonedStream(t *testing.T) {
+	c := startClientFor(t, &endlessProgressServer{testInferenceServer{tasks: []string{types.TaskSemanticTextEmbed}}})
+	c.usage = newUsageTracker(config.UsageConfig{}, zap.NewNop())
+
+	ctx := WithStreamOptions(WithTenant(context.Background(), "team-a"), WithStreamBuffer(2), WithConsumerTimeout(50*time.Millisecond))
+	frames, err := c.InferStream(ctx, embedRequest())
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	if cap(frames) != 2 {
+		t.Fatalf("frames channel capacity = %d, want the stream's own buffer of 2", cap(frames))
+	}
+	waitOpenStreams(t, c, 0)
+	waitUntil(t, func() bool { return c.GetMetrics().Usage["team-a"].Requests == 1 })
+
+	if _, err := CollectStream(context.Background(), frames); !errors.Is(err, ErrStreamEnded) {
+		t.Fatalf("CollectStream error = %v, want ErrStreamEnded", err)
+	}
+}
+
+// TestUsageCountsStreamsFromOtherMiddlewares checks a stream answered by a
+// middleware's own channel, which usage cannot observe in place, is still
+// charged.
+func TestUsageCountsStreamsFromOtherMiddlewares(t *testing.T) {
+	c, _ := newUsageClient(config.UsageConfig{})
+	c.UseStream(func(StreamFunc) StreamFunc {
+		return func(context.Context, *pb.InferRequest) (<-chan *pb.InferResponse, error) {
+			ch := make(chan *pb.InferResponse, 1)
+			ch <- &pb.InferResponse{IsFinal: true, Result: []byte("cached")}
+			close(ch)
+			return ch, nil
+		}
+	})
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hello").Build()
+	ch, err := c.InferStream(WithTenant(context.Background(), "team-a"), req)
+	if err != nil {
+		t.Fatalf("InferStream: %v", err)
+	}
+	for range ch {
+	}
+	if a := c.GetMetrics().Usage["team-a"]; a.Requests != 1 || a.BytesOut != 6 {
+		t.Fatalf("stream usage = %+v", a)
+	}
+}
+
+// BenchmarkInferStreamPartials streams 10k partial frames per request, with
+// and without usage accounting, which observes frames without copying them.
+func BenchmarkInferStreamPartials(b *testing.B) {
+	const partials = 10000
+	responses := make([]*pb.InferResponse, 0, partials+1)
+	for i := range partials {
+		responses = append(responses, &pb.InferResponse{Result: []byte(fmt.Sprint(i))})
+	}
+	responses = append(responses, &pb.InferResponse{IsFinal: true, Result: []byte("done")})
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).ForSemanticTextEmbed("hello").Build()
+
+	for _, usage := range []bool{false, true} {
+		b.Run(fmt.Sprintf("usage=%v", usage), func(b *testing.B) {
+			c, _ := newUsageClient(config.UsageConfig{}, responses...)
+			if !usage {
+				c.usage = nil
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				ch, err := c.InferStream(context.Background(), req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for range ch {
+				}
+			}
+		})
+	}
+}
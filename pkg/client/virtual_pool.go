@@ -0,0 +1,204 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+)
+
+// VirtualDeployment is the deployment ID of a VirtualPool's nodes, so a
+// node with ID "cuda-1" has the pool key "sim-cuda-1".
+const VirtualDeployment = "sim"
+
+// VirtualNode is a node of a VirtualPool: what discovery and the capability
+// probe would have told the pool about it.
+type VirtualNode struct {
+	// ID names the node.
+	ID string
+	// Tasks are the tasks the node serves. Versioned and aliased task names
+	// resolve as they do for a real node.
+	Tasks []string
+	// Txt are the node's discovery hints, e.g. "runtime" or a pushed
+	// "load", which NodeScorers and the load score read.
+	Txt map[string]string
+}
+
+// VirtualPool runs the pool's node selection over nodes that exist only in
+// memory, on a clock the caller moves. It is the balancer a Pool uses, with
+// the same strategy, task matching, scoring, cooldowns and latency windows,
+// minus the connections: Pick routes a request and Done reports how it went,
+// in virtual time. It backs capacity-planning simulations such as
+// pkg/client/sim and sends no RPCs.
+//
+// Health checks, capability probes, outlier detection and draining are not
+// simulated: every node is Ready for the whole run.
+//
+// A VirtualPool is not safe for concurrent use.
+type VirtualPool struct {
+	lb    *lumenBalancer
+	cc    *virtualClientConn
+	clock *utils.FakeClock
+	ids   map[string]string // pool key -> VirtualNode.ID
+}
+
+// NewVirtualPool returns a VirtualPool over nodes, selecting by cfg.Pool's
+// strategy, with its clock at start. A nil cfg uses DefaultConfig.
+func NewVirtualPool(cfg *config.Config, nodes []VirtualNode, start time.Time) (*VirtualPool, error) {
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	v := &VirtualPool{
+		cc:    &virtualClientConn{},
+		clock: utils.NewFakeClock(start),
+		ids:   make(map[string]string, len(nodes)),
+	}
+
+	registry := &nodeRegistry{
+		nodes:    make(map[string]*registeredNode),
+		latency:  &latencySet{window: cfg.Metrics.LatencyWindow, clock: v.clock.Now},
+		failures: &failureSet{},
+		clock:    v.clock,
+	}
+	v.lb = &lumenBalancer{
+		cc:       v.cc,
+		subConns: make(map[string]*subConnState, len(nodes)),
+		registry: registry,
+		options: balancerOptions{
+			rediscoveryBackoffMin: cfg.Discovery.RediscoveryBackoffMin,
+			rediscoveryBackoffMax: cfg.Discovery.RediscoveryBackoffMax,
+			strategies:            newStrategies(cfg.Pool.Strategy, newScoring(cfg.Pool.ScoreWeights, cfg.Pool.RandomTieBreak)),
+		},
+		logger: zap.NewNop(),
+		done:   make(chan struct{}),
+	}
+
+	for _, node := range nodes {
+		identity := discovery.NewNodeIdentity(VirtualDeployment, node.ID)
+		if identity.IsZero() {
+			return nil, fmt.Errorf("virtual node without an ID")
+		}
+		key := identity.Key()
+		if _, dup := v.ids[key]; dup {
+			return nil, fmt.Errorf("virtual node %q listed twice", node.ID)
+		}
+		v.ids[key] = node.ID
+		scs := &subConnState{
+			addr:      resolver.Address{Addr: node.ID},
+			identity:  identity,
+			state:     connectivity.Ready,
+			hintTasks: node.Tasks,
+			txt:       node.Txt,
+			streams:   &nodeStreams{},
+		}
+		scs.refreshTasksLocked()
+		v.lb.subConns[key] = scs
+	}
+	v.lb.mu.Lock()
+	v.lb.syncRegistryLocked()
+	v.lb.rebuildPickerLocked()
+	v.lb.mu.Unlock()
+	return v, nil
+}
+
+// SetScorer registers the NodeScorer the custom strategy consults, as
+// LumenClient.SetNodeScorer does. Scorers see nodes under their pool key,
+// "sim-<ID>".
+func (v *VirtualPool) SetScorer(scorer NodeScorer) {
+	v.lb.options.strategies.custom.setScorer(scorer)
+}
+
+// Now returns the pool's clock.
+func (v *VirtualPool) Now() time.Time {
+	return v.clock.Now()
+}
+
+// Advance moves the pool's clock to t. The clock never goes back. Nodes
+// whose cooldown has ended by t take probe traffic again.
+func (v *VirtualPool) Advance(t time.Time) {
+	d := t.Sub(v.clock.Now())
+	if d <= 0 {
+		return
+	}
+	v.clock.Advance(d)
+	v.lb.mu.Lock()
+	defer v.lb.mu.Unlock()
+	for _, scs := range v.lb.subConns {
+		if !scs.cooldownUntil.IsZero() {
+			v.lb.rebuildPickerLocked()
+			return
+		}
+	}
+}
+
+// VirtualPick is a request a VirtualPool routed.
+type VirtualPick struct {
+	// NodeID is the VirtualNode.ID of the node picked.
+	NodeID string
+	// Task is the task the node serves the request under, e.g. a version
+	// of the task asked for.
+	Task string
+	done func(balancer.DoneInfo)
+}
+
+// Pick routes a request for task made with ctx at the pool's clock, as the
+// pool would route an Infer call; NodeScorers see hints. The node counts the
+// request in flight until Done. It fails when no node can serve task right
+// now, e.g. because every node serving it is cooling down.
+func (v *VirtualPool) Pick(ctx context.Context, task string, hints SelectionHints) (*VirtualPick, error) {
+	picker, _ := v.cc.picker.(*lumenPicker)
+	if picker == nil {
+		return nil, balancer.ErrNoSubConnAvailable
+	}
+	slot := &pickedNode{}
+	ctx = context.WithValue(withPickedNode(WithTask(ctx, task), slot), selectionHintsKey{}, hints)
+	result, err := picker.Pick(balancer.PickInfo{FullMethodName: "/lumen.Inference/Infer", Ctx: ctx})
+	if err != nil {
+		return nil, err
+	}
+	return &VirtualPick{NodeID: v.ids[slot.get()], Task: slot.resolvedTask(), done: result.Done}, nil
+}
+
+// Done reports that p finished at the pool's clock, with err when it
+// failed. Latency is measured from the Pick, and failures count toward the
+// node's cooldown as they would for a real node.
+func (v *VirtualPool) Done(p *VirtualPick, err error) {
+	if p == nil || p.done == nil {
+		return
+	}
+	p.done(balancer.DoneInfo{Err: err})
+	p.done = nil
+}
+
+// InFlight returns the requests picked for the node with id and not done.
+func (v *VirtualPool) InFlight(id string) int {
+	scs := v.lb.subConns[discovery.NewNodeIdentity(VirtualDeployment, id).Key()]
+	if scs == nil {
+		return 0
+	}
+	return int(scs.streams.inFlight())
+}
+
+// virtualClientConn stands in for the gRPC ClientConn of a VirtualPool's
+// balancer, keeping the picker it publishes. The balancer calls nothing
+// else on it, as a VirtualPool never creates SubConns.
+type virtualClientConn struct {
+	balancer.ClientConn
+	mu     sync.Mutex
+	picker balancer.Picker
+}
+
+func (c *virtualClientConn) UpdateState(state balancer.State) {
+	c.mu.Lock()
+	c.picker = state.Picker
+	c.mu.Unlock()
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var virtualStart = time.Date(2026, 10, 13, 14, 0, 0, 0, time.UTC)
+
+func virtualPick(t *testing.T, v *VirtualPool, task string) *VirtualPick {
+	t.Helper()
+	p, err := v.Pick(context.Background(), task, SelectionHints{})
+	if err != nil {
+		t.Fatalf("Pick(%s): %v", task, err)
+	}
+	return p
+}
+
+func TestVirtualPoolRoutesByTaskRoundRobin(t *testing.T) {
+	v, err := NewVirtualPool(nil, []VirtualNode{
+		{ID: "cuda-1", Tasks: []string{"embed"}},
+		{ID: "cuda-2", Tasks: []string{"embed"}},
+		{ID: "cpu-1", Tasks: []string{"ocr"}},
+	}, virtualStart)
+	if err != nil {
+		t.Fatalf("NewVirtualPool: %v", err)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		p := virtualPick(t, v, "embed")
+		counts[p.NodeID]++
+		v.Done(p, nil)
+	}
+	if counts["cuda-1"] != 3 || counts["cuda-2"] != 3 {
+		t.Fatalf("embed picks = %v, want 3 each on cuda-1 and cuda-2", counts)
+	}
+	if p := virtualPick(t, v, "ocr"); p.NodeID != "cpu-1" || p.Task != "ocr" {
+		t.Fatalf("ocr pick = %+v, want cpu-1", p)
+	}
+	if _, err := v.Pick(context.Background(), "face_recognition", SelectionHints{}); err == nil {
+		t.Fatal("Pick of a task no node serves succeeded")
+	}
+	if _, err := NewVirtualPool(nil, []VirtualNode{{ID: "a"}, {ID: "a"}}, virtualStart); err == nil {
+		t.Fatal("NewVirtualPool accepted a node listed twice")
+	}
+}
+
+func TestVirtualPoolLoadScoringSeesInFlight(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Pool.Strategy = config.StrategyCustom
+	cfg.Pool.ScoreWeights = map[string]float64{config.ScoreLoad: 1}
+	v, err := NewVirtualPool(cfg, []VirtualNode{
+		{ID: "a", Tasks: []string{"embed"}},
+		{ID: "b", Tasks: []string{"embed"}},
+	}, virtualStart)
+	if err != nil {
+		t.Fatalf("NewVirtualPool: %v", err)
+	}
+
+	first := virtualPick(t, v, "embed")
+	second := virtualPick(t, v, "embed")
+	if first.NodeID != "a" || second.NodeID != "b" {
+		t.Fatalf("picks = %s, %s, want a then the idle b", first.NodeID, second.NodeID)
+	}
+	third := virtualPick(t, v, "embed")
+	if v.InFlight("a") != 2 || v.InFlight("b") != 1 {
+		t.Fatalf("in flight a=%d b=%d, want 2 and 1", v.InFlight("a"), v.InFlight("b"))
+	}
+	v.Done(first, nil)
+	v.Done(third, nil)
+	if p := virtualPick(t, v, "embed"); p.NodeID != "a" {
+		t.Fatalf("pick after a drained = %s, want a", p.NodeID)
+	}
+}
+
+func TestVirtualPoolCooldownRunsOnVirtualClock(t *testing.T) {
+	cfg := config.DefaultConfig()
+	v, err := NewVirtualPool(cfg, []VirtualNode{
+		{ID: "a", Tasks: []string{"embed"}},
+		{ID: "b", Tasks: []string{"embed"}},
+	}, virtualStart)
+	if err != nil {
+		t.Fatalf("NewVirtualPool: %v", err)
+	}
+
+	unavailable := status.Error(codes.Unavailable, "node down")
+	for failed := 0; failed < hardFailureThreshold; {
+		p := virtualPick(t, v, "embed")
+		if p.NodeID == "a" {
+			v.Done(p, unavailable)
+			failed++
+			continue
+		}
+		v.Done(p, nil)
+	}
+	for i := 0; i < 4; i++ {
+		p := virtualPick(t, v, "embed")
+		if p.NodeID != "b" {
+			t.Fatalf("pick %d went to %s while a cools down", i, p.NodeID)
+		}
+		v.Done(p, nil)
+	}
+
+	v.Advance(v.Now().Add(cfg.Discovery.RediscoveryBackoffMin + time.Millisecond))
+	seen := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		p := virtualPick(t, v, "embed")
+		seen[p.NodeID] = true
+		v.Done(p, nil)
+	}
+	if !seen["a"] {
+		t.Fatal("a never picked after its cooldown ended in virtual time")
+	}
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// watchList holds the callbacks registered for one kind of pool event.
+// Notifiers iterate a snapshot, so callbacks may unsubscribe, themselves or
+// others, while being notified.
+type watchList[T any] struct {
+	mu      sync.Mutex
+	entries []*watchEntry[T]
+}
+
+type watchEntry[T any] struct {
+	cb          func(T)
+	removed     atomic.Bool
+	panics      atomic.Int32
+	unsubscribe func()
+
+	// queue holds the values enqueue has yet to deliver, oldest first;
+	// draining is set while a goroutine delivers them. closing refuses new
+	// values and unregisters the callback once the queue is delivered.
+	queueMu  sync.Mutex
+	queue    []T
+	draining bool
+	closing  bool
+}
+
+// add registers cb and returns the func that unregisters it. Calling that
+// more than once, or after clear, does nothing.
+func (l *watchList[T]) add(cb func(T)) (unsubscribe func()) {
+	e := &watchEntry[T]{cb: cb}
+	l.mu.Lock()
+	l.entries = append(l.entries, e)
+	l.mu.Unlock()
+	e.unsubscribe = func() {
+		if e.removed.Swap(true) {
+			return
+		}
+		l.mu.Lock()
+		// Replace rather than edit the slice: snapshots may still be
+		// iterating it.
+		l.entries = slices.DeleteFunc(slices.Clone(l.entries), func(x *watchEntry[T]) bool { return x == e })
+		l.mu.Unlock()
+	}
+	return e.unsubscribe
+}
+
+func (l *watchList[T]) snapshot() []*watchEntry[T] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.entries
+}
+
+// clear unregisters every callback. Values already queued by enqueue are
+// still delivered first.
+func (l *watchList[T]) clear() {
+	l.mu.Lock()
+	entries := l.entries
+	l.entries = nil
+	l.mu.Unlock()
+	for _, e := range entries {
+		e.close()
+	}
+}
+
+// deliver calls the callback, a callback of kind, unless it has been
+// unregistered, including since the notification that is delivering v
+// began. A panic drops this call only, unless it is one too many for g,
+// which unregisters the callback.
+func (e *watchEntry[T]) deliver(g *callbackGuard, kind string, v T) {
+	if e.removed.Load() {
+		return
+	}
+	if g.run(kind, func() { e.cb(v) }) && g.exhausted(kind, int(e.panics.Add(1))) {
+		e.unsubscribe()
+	}
+}
+
+// enqueue delivers v after every value enqueued before it, from a goroutine
+// of its own, so the callback sees values in order and never runs
+// concurrently with itself, while the notifier does not wait for it.
+func (e *watchEntry[T]) enqueue(g *callbackGuard, kind string, v T) {
+	e.queueMu.Lock()
+	if e.closing || e.removed.Load() {
+		e.queueMu.Unlock()
+		return
+	}
+	e.queue = append(e.queue, v)
+	start := !e.draining
+	e.draining = true
+	e.queueMu.Unlock()
+	if start {
+		go e.drain(g, kind)
+	}
+}
+
+func (e *watchEntry[T]) drain(g *callbackGuard, kind string) {
+	for {
+		e.queueMu.Lock()
+		if len(e.queue) == 0 {
+			e.queue = nil
+			e.draining = false
+			if e.closing {
+				e.removed.Store(true)
+			}
+			e.queueMu.Unlock()
+			return
+		}
+		v := e.queue[0]
+		var zero T
+		e.queue[0] = zero
+		e.queue = e.queue[1:]
+		e.queueMu.Unlock()
+		e.deliver(g, kind, v)
+	}
+}
+
+// close unregisters the callback once the values queued for it are
+// delivered.
+func (e *watchEntry[T]) close() {
+	e.queueMu.Lock()
+	defer e.queueMu.Unlock()
+	e.closing = true
+	if !e.draining {
+		e.removed.Store(true)
+	}
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"go.uber.org/zap"
+)
+
+func newWatchedPool(t *testing.T) *Pool {
+	t.Helper()
+	pool := NewPoolWithOptions(zap.NewNop(), PoolOptions{NotifyWindow: -1})
+	pool.registry = &nodeRegistry{nodes: make(map[string]*registeredNode)}
+	t.Cleanup(func() { _ = pool.Close() })
+	return pool
+}
+
+func TestUnsubscribedNodeWatcherNeverFires(t *testing.T) {
+	pool := newWatchedPool(t)
+	var kept, dropped atomic.Int32
+	pool.OnNodesChanged(func([]*discovery.NodeInfo) { kept.Add(1) })
+	unsubscribe := pool.OnNodesChanged(func([]*discovery.NodeInfo) { dropped.Add(1) })
+
+	pool.notifyWatchers()
+	waitUntil(t, func() bool { return kept.Load() == 1 && dropped.Load() == 1 })
+
+	unsubscribe()
+	unsubscribe() // a second call is a no-op
+	for i := 0; i < 5; i++ {
+		pool.notifyWatchers()
+		waitUntil(t, func() bool { return kept.Load() == int32(i+2) })
+	}
+	if n := dropped.Load(); n != 1 {
+		t.Fatalf("unsubscribed watcher fired %d times, want 1", n)
+	}
+	pool.mu.RLock()
+	watchers := len(pool.watchers)
+	pool.mu.RUnlock()
+	if watchers != 1 {
+		t.Fatalf("pool holds %d node watchers, want 1", watchers)
+	}
+}
+
+func TestUnsubscribeDuringNotification(t *testing.T) {
+	pool := newWatchedPool(t)
+
+	// A node watcher unsubscribing itself from its own callback.
+	var nodeCalls atomic.Int32
+	var unsubscribeNodes func()
+	done := make(chan struct{})
+	unsubscribeNodes = pool.OnNodesChanged(func([]*discovery.NodeInfo) {
+		nodeCalls.Add(1)
+		unsubscribeNodes()
+		close(done)
+	})
+	pool.notifyWatchers()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("node watcher deadlocked unsubscribing itself")
+	}
+
+	// Selection watchers run synchronously: the first unsubscribes both
+	// itself and the next one while the notification is under way.
+	var first, second, third atomic.Int32
+	var unsubscribeFirst, unsubscribeSecond func()
+	unsubscribeFirst = pool.OnSelection(func(discovery.SelectionDecision) {
+		first.Add(1)
+		unsubscribeFirst()
+		unsubscribeSecond()
+	})
+	unsubscribeSecond = pool.OnSelection(func(discovery.SelectionDecision) { second.Add(1) })
+	pool.OnSelection(func(discovery.SelectionDecision) { third.Add(1) })
+
+	finished := make(chan struct{})
+	go func() {
+		pool.notifySelectionWatchers(discovery.SelectionDecision{Task: "ocr"})
+		pool.notifySelectionWatchers(discovery.SelectionDecision{Task: "ocr"})
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("selection watcher deadlocked unsubscribing during notification")
+	}
+	if first.Load() != 1 || second.Load() != 0 || third.Load() != 2 {
+		t.Fatalf("calls = %d, %d, %d; want 1, 0, 2", first.Load(), second.Load(), third.Load())
+	}
+
+	pool.notifyWatchers()
+	time.Sleep(20 * time.Millisecond)
+	if n := nodeCalls.Load(); n != 1 {
+		t.Fatalf("node watcher fired %d times, want 1", n)
+	}
+}
+
+// TestStatusWatcherGetsChangesInOrder checks a status watcher sees the
+// changes one at a time, in the order they were reported, even when its
+// callback is slow.
+func TestStatusWatcherGetsChangesInOrder(t *testing.T) {
+	pool := newWatchedPool(t)
+	const n = 50
+	var running atomic.Int32
+	got := make(chan string, n)
+	pool.OnStatusChange(func(c discovery.NodeStatusChanged) {
+		if running.Add(1) != 1 {
+			t.Error("status callback ran concurrently with itself")
+		}
+		time.Sleep(100 * time.Microsecond)
+		got <- c.Detail
+		running.Add(-1)
+	})
+	for i := 0; i < n; i++ {
+		pool.notifyStatusWatchers(discovery.NodeStatusChanged{
+			NodeID:           "local-a",
+			StatusTransition: discovery.StatusTransition{Detail: strconv.Itoa(i)},
+		})
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case detail := <-got:
+			if detail != strconv.Itoa(i) {
+				t.Fatalf("change %s delivered as number %d", detail, i)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("only %d of %d changes delivered", i, n)
+		}
+	}
+}
+
+func TestCloseUnregistersWatchers(t *testing.T) {
+	pool := newWatchedPool(t)
+	var before atomic.Int32
+	unsubscribe := pool.OnSelection(func(discovery.SelectionDecision) { before.Add(1) })
+	if err := pool.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	pool.notifySelectionWatchers(discovery.SelectionDecision{})
+	unsubscribe() // after Close, a no-op
+
+	// Re-registering after Close works.
+	var after atomic.Int32
+	pool.OnSelection(func(discovery.SelectionDecision) { after.Add(1) })
+	pool.notifySelectionWatchers(discovery.SelectionDecision{})
+	if before.Load() != 0 || after.Load() != 1 {
+		t.Fatalf("calls before/after Close = %d, %d; want 0, 1", before.Load(), after.Load())
+	}
+
+	pool.registry = &nodeRegistry{nodes: make(map[string]*registeredNode)}
+	fired := make(chan struct{}, 1)
+	pool.OnNodesChanged(func([]*discovery.NodeInfo) { fired <- struct{}{} })
+	pool.notifyWatchers()
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("node watcher registered after Close never fired")
+	}
+}
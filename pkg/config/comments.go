@@ -0,0 +1,204 @@
+package config
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fieldComments documents each YAML field in files written by SaveConfig,
+// keyed by the dotted YAML path.
+var fieldComments = map[string]string{
+	"discovery":                         "Service discovery for finding ML nodes",
+	"discovery.enabled":                 "Run node discovery at all",
+	"discovery.service_type":            "mDNS service type nodes advertise",
+	"discovery.domain":                  "mDNS domain",
+	"discovery.deployment_id":           "Only nodes of this deployment are used",
+	"discovery.resolve_timeout":         "Bound on a single mDNS resolve",
+	"discovery.connect_timeout":         "Operational dial timeout",
+	"discovery.rediscovery_backoff_min": "Initial rediscovery cooldown",
+	"discovery.rediscovery_backoff_max": "Maximum rediscovery cooldown",
+	"discovery.scan_interval":           "How often to re-query mDNS for services",
+	"discovery.mdns_enabled":            "Discover nodes on the LAN via mDNS",
+	"discovery.probe_timeout":           "Timeout of one capability probe (GetCapabilities) against a discovered node",
+	"discovery.max_concurrent_probes":   "Capability probes allowed in flight at once",
+	"discovery.provisional_traffic":     "Route to nodes on their TXT task hints before GetCapabilities confirms them",
+	"discovery.notify_window":           "Coalesce node-list callbacks within this window; 0 delivers every change",
+	"discovery.max_catalog_age":         "Report the catalog stale once a node goes unconfirmed this long; 0 never does",
+	"discovery.fail_on_stale_catalog":   "Fail requests with SERVICE_UNAVAILABLE while the catalog is stale",
+	"discovery.broker_url":              "Also consume a Host Broker's /v1/nodes/watch",
+	"discovery.static_nodes":            `Fixed node addresses, host:port or [v6]:port, e.g. ["10.0.0.5:50051", "[fd00::5]:50051"]`,
+	"discovery.allow_nodes":             `Adopt only mDNS nodes matching a pattern: name glob, CIDR or "cluster=<name>"`,
+	"discovery.deny_nodes":              "Never adopt mDNS nodes matching a pattern; wins over allow_nodes",
+	"discovery.push":                    "Let nodes register themselves with the Broker",
+	"discovery.push.enabled":            "Serve the /v1/push node registration routes",
+	"discovery.push.token":              "Shared secret nodes send as a bearer token",
+	"discovery.push.heartbeat_timeout":  "Drop a pushed node that misses heartbeats this long",
+	"discovery.push.allow_nodes":        "Accept only registrations matching a pattern; empty accepts all",
+
+	"broker":               "Host Broker control plane",
+	"broker.enabled":       "Serve the Host Broker API",
+	"broker.host":          "Listen address: a hostname or IP literal, e.g. 0.0.0.0 or ::",
+	"broker.port":          "Listen port; set to 0 when using socket",
+	"broker.socket":        "Listen on this unix socket instead of TCP",
+	"broker.socket_mode":   `Octal permissions applied to the socket, e.g. "0660"`,
+	"broker.docs":          "Serve a Swagger UI for /openapi.json at /docs",
+	"broker.read_timeout":  "Max time to receive a request's headers and body; 0 = no limit",
+	"broker.write_timeout": "Max time to write a response; 0 = no limit",
+	"broker.idle_timeout":  "Keep-alive wait for the next request; 0 = read_timeout",
+
+	"broker.election":               "Active/standby: only the lease holder serves the API",
+	"broker.election.enabled":       "Elect one leader among Brokers sharing lease_file",
+	"broker.election.lease_file":    "Lease path on storage every instance shares",
+	"broker.election.lease_timeout": "A standby takes over after the lease goes unrenewed this long",
+	"broker.election.id":            "Name of this instance in the lease; empty = hostname-pid",
+
+	"logging":        "Logging",
+	"logging.level":  "debug, info, warn, error or fatal",
+	"logging.format": "json or text",
+	"logging.output": "stdout, stderr or a file path",
+
+	"chunk":                    "Automatic payload chunking",
+	"chunk.enable_auto":        "Split large payloads into chunks automatically",
+	"chunk.threshold":          "Payload size in bytes above which chunking starts",
+	"chunk.max_chunk_bytes":    "Size of each chunk in bytes",
+	"chunk.parallel_streams":   "Concurrent streams per large upload to nodes advertising parallel_upload (0 or 1 = one stream, max 4)",
+	"chunk.parallel_threshold": "Payload size in bytes above which parallel upload applies",
+	"chunk.send_retries":       "Retries of a chunk whose Send failed transiently; 0 = none",
+	"chunk.profiles":           "How to split by MIME prefix: bytes, runes, whitespace or json (longest prefix wins; default bytes)",
+
+	"metrics":                "Client-side request metrics",
+	"metrics.latency_window": "Sliding percentile window; 0 = cumulative",
+
+	"pool":                              "Node connections held by the client pool",
+	"pool.max_connections":              "Cap on connected nodes; 0 = no limit",
+	"pool.max_idle_time":                "Release connections after this long without RPCs; 0 = never",
+	"pool.max_lifetime":                 "Recycle connections older than this; 0 = never",
+	"pool.health_check":                 "Periodic Health RPC against Ready nodes",
+	"pool.health_interval":              "Interval between health checks",
+	"pool.stream_warn_threshold":        "Warn when a node has more open streams than this; 0 = off",
+	"pool.stream_max_age":               "Warn about streams open longer than this, likely leaks; 0 = off",
+	"pool.strategy":                     "Node selection: round_robin, least_latency, or custom to pick the highest score",
+	"pool.score_weights":                "custom strategy: weight per score (custom, load, latency); empty = custom only",
+	"pool.random_tie_break":             "custom strategy: break equal top scores at random, not by node ID",
+	"pool.min_healthy_nodes":            "Per task: nodes that must be routable before a request is dispatched",
+	"pool.callback_panic_limit":         "Unregister a Watch callback after this many panics; 0 = never",
+	"pool.keep_alive":                   "Ping idle connections this often so NAT keeps them; 0 = off",
+	"pool.keep_alive_timeout":           "Close a connection whose ping is not acked within this",
+	"pool.max_message_size":             "Largest message accepted from a node, capability responses included",
+	"pool.compression":                  "Compress messages sent to nodes: none or gzip",
+	"pool.tls":                          "Transport security for node connections",
+	"pool.tls.mode":                     "insecure or tls",
+	"pool.tls.ca_file":                  "PEM roots node certificates must chain to; empty = system roots",
+	"pool.tls.cert_file":                "Client certificate for mutual TLS",
+	"pool.tls.key_file":                 "Key for cert_file",
+	"pool.tls.server_name":              "Expected node identity: DNS SAN or spiffe:// ID",
+	"pool.per_node":                     `Transport overrides keyed by node ID or pattern, e.g. "lab-gpu-*"`,
+	"pool.outlier":                      "Eject nodes answering far worse than the cluster median",
+	"pool.outlier.enabled":              "Turn passive outlier detection on",
+	"pool.outlier.interval":             "How often nodes are compared",
+	"pool.outlier.window":               "Sliding window of requests each comparison covers",
+	"pool.outlier.min_requests":         "Requests a node needs in the window to be judged",
+	"pool.outlier.error_rate_factor":    "Eject above this multiple of the median error rate",
+	"pool.outlier.latency_factor":       "Eject above this multiple of the median latency; 0 = off",
+	"pool.outlier.ejection_time":        "First ejection length; doubles per consecutive ejection",
+	"pool.outlier.max_ejection_percent": "Never eject more than this share of the nodes",
+	"pool.outlier.probe_fraction":       "Share of its traffic a re-admitted node starts with",
+	"pool.hysteresis":                   "Damp health-check status changes of flapping nodes",
+	"pool.hysteresis.fail_threshold":    "Failed checks in a row taking a node from active to error",
+	"pool.hysteresis.recover_threshold": "Passed checks in a row bringing it back",
+	"pool.hysteresis.flap_limit":        "Errors within flap_window after which a node is suspect; 0 = off",
+	"pool.hysteresis.flap_window":       "Window flaps are counted over",
+	"pool.hysteresis.suspect_time":      "How long a suspect node takes only probe traffic",
+	"pool.hysteresis.smoothing":         "Weight of the newest check in the smoothed health score",
+
+	"usage":              "Per-tenant usage accounting (client.WithTenant)",
+	"usage.retention":    "How long per-minute usage is kept for windowed queries",
+	"usage.quota_window": "Trailing window quotas are measured over",
+	"usage.quota_policy": "log or reject (FORBIDDEN) when a tenant is over quota",
+	"usage.quotas":       `Soft limits by tenant, e.g. {team-a: {requests: 1000, tokens: 50000, gpu_time: 1h}}`,
+
+	"fallback":         "In-process handling when no node serves a task",
+	"fallback.enabled": "Run handlers registered with RegisterLocalHandler when no node is available",
+
+	"cost_hints":                    "Per-task costs nodes advertise in their capabilities",
+	"cost_hints.enabled":            "Derive timeouts and chunk sizes from the picked node's hints",
+	"cost_hints.timeout_multiplier": "Timeout for a call without a deadline = node's p50 for the task x this",
+	"cost_hints.min_timeout":        "Lower bound on a derived timeout",
+	"cost_hints.max_timeout":        "Upper bound on a derived timeout",
+	"cost_hints.min_chunk_bytes":    "Lower bound on a node's preferred chunk size",
+	"cost_hints.max_chunk_bytes":    "Upper bound on a node's preferred chunk size",
+
+	"payload_protection":               "Encryption of persisted payload-derived data",
+	"payload_protection.enabled":       "Encrypt caches, journals and upload state",
+	"payload_protection.key_file":      `One "id:base64key" line per AES key`,
+	"payload_protection.active_key_id": "Key for new ciphertexts; defaults to the first key",
+
+	"task_state":             "Garbage collection of per-task state for tasks no longer served",
+	"task_state.gc_interval": "How often to collect; 0 keeps per-task state forever",
+	"task_state.retention":   "How long an unserved task keeps its state after its last use",
+	"jobs":                   "Asynchronous jobs submitted with SubmitJob",
+	"jobs.ttl":               "How long a job is remembered after its submission or last query",
+
+	"queue":                   "Durable queue of requests added with EnqueueInfer",
+	"queue.dir":               "Where queued requests and undelivered results are kept; empty disables the queue",
+	"queue.max_bytes":         "Cap on the size of queued requests and undelivered responses on disk",
+	"queue.concurrency":       "Queued requests in flight at once",
+	"queue.max_attempts":      "Failed attempts before a request becomes a dead letter",
+	"queue.retry_backoff":     "Wait after the first failed attempt, doubling with each failure",
+	"queue.max_retry_backoff": "Cap on the wait between attempts",
+	"queue.attempt_timeout":   "Time limit of one attempt",
+	"queue.results_dir":       "Where results without a topic handler are written as <queue id>.json",
+}
+
+// AnnotatedYAML marshals the configuration with each field's description as
+// a head comment. lineComment, when non-nil, is called with every dotted
+// YAML path and may return a trailing comment for that line.
+func (c *Config) AnnotatedYAML(lineComment func(path string) string) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(c); err != nil {
+		return nil, err
+	}
+	annotate(&doc, "", lineComment)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func annotate(node *yaml.Node, prefix string, lineComment func(string) string) {
+	if node.Kind == yaml.DocumentNode {
+		for _, child := range node.Content {
+			annotate(child, prefix, lineComment)
+		}
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		path := key.Value
+		if prefix != "" {
+			path = prefix + "." + key.Value
+		}
+		key.HeadComment = fieldComments[path]
+		if lineComment != nil {
+			if comment := lineComment(path); comment != "" {
+				if value.Kind == yaml.MappingNode {
+					key.LineComment = comment
+				} else {
+					value.LineComment = comment
+				}
+			}
+		}
+		annotate(value, path, lineComment)
+	}
+}
@@ -2,13 +2,17 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"net"
+	"net/url"
 	"os"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils/netaddr"
 )
 
 // Config is the configuration for the Lumen SDK.
@@ -20,12 +24,22 @@ type Config struct {
 	Broker    BrokerConfig    `yaml:"broker" json:"broker"`
 	Logging   LoggingConfig   `yaml:"logging" json:"logging"`
 	Chunk     ChunkConfig     `yaml:"chunk" json:"chunk"`
+	Metrics   MetricsConfig   `yaml:"metrics" json:"metrics"`
+	Pool      PoolConfig      `yaml:"pool" json:"pool"`
+	Usage     UsageConfig     `yaml:"usage" json:"usage"`
+	Fallback  FallbackConfig  `yaml:"fallback" json:"fallback"`
+	CostHints CostHintsConfig `yaml:"cost_hints" json:"cost_hints"`
+
+	PayloadProtection PayloadProtectionConfig `yaml:"payload_protection" json:"payload_protection"`
+	TaskState         TaskStateConfig         `yaml:"task_state" json:"task_state"`
+	Jobs              JobsConfig              `yaml:"jobs" json:"jobs"`
+	Queue             QueueConfig             `yaml:"queue" json:"queue"`
 }
 
 // DiscoveryConfig controls service discovery for finding ML nodes.
 //
-// The three discovery backends (mDNS, Broker push via BrokerURL, StaticNodes)
-// are additive: every configured backend runs and their node events are
+// The discovery backends (mDNS, Broker push via BrokerURL, StaticNodes, and
+// nodes registering themselves via Push) are additive: every configured backend runs and their node events are
 // merged. At least one must be configured when discovery is enabled.
 type DiscoveryConfig struct {
 	Enabled               bool          `yaml:"enabled" json:"enabled"`
@@ -38,13 +52,66 @@ type DiscoveryConfig struct {
 	RediscoveryBackoffMax time.Duration `yaml:"rediscovery_backoff_max" json:"rediscovery_backoff_max"`
 	ScanInterval          time.Duration `yaml:"scan_interval" json:"scan_interval"` // mDNS poll interval: how often to re-query for services.
 	MDNSEnabled           bool          `yaml:"mdns_enabled" json:"mdns_enabled"`
+	// ProbeTimeout bounds a single capability probe (GetCapabilities) against
+	// a discovered node. mDNS queries are bounded by ResolveTimeout.
+	ProbeTimeout time.Duration `yaml:"probe_timeout" json:"probe_timeout"`
+	// MaxConcurrentProbes caps how many nodes are probed for capabilities at
+	// the same time. Further probes wait for a free slot.
+	MaxConcurrentProbes int `yaml:"max_concurrent_probes" json:"max_concurrent_probes"`
+	// ProvisionalTraffic lets a node that advertised its tasks in TXT
+	// records ("tasks", "runtime", "max_concurrency") take requests for them
+	// as soon as it is connected, before GetCapabilities confirms them. The
+	// node is reported "provisional" meanwhile. When false it only counts
+	// toward Start's wait for a first node until its capabilities arrive.
+	ProvisionalTraffic bool `yaml:"provisional_traffic" json:"provisional_traffic"`
+	// NotifyWindow coalesces node-list changes within this window into one
+	// WatchNodes callback carrying the latest list, so a scan that finds
+	// many nodes at once does not fire a callback per node. Zero delivers
+	// every change.
+	NotifyWindow time.Duration `yaml:"notify_window" json:"notify_window"`
+	// MaxCatalogAge is how long a discovered node may go without its
+	// backend vouching for it (an mDNS scan finding it, a push heartbeat, a
+	// Broker pong, a passing health check for a static node) before the
+	// catalog is stale. A stale catalog reports health "degraded" and logs
+	// a warning. Zero never marks it stale.
+	MaxCatalogAge time.Duration `yaml:"max_catalog_age" json:"max_catalog_age"`
+	// FailOnStaleCatalog fails requests with SERVICE_UNAVAILABLE while the
+	// catalog is stale, rather than routing on what may be gone nodes.
+	FailOnStaleCatalog bool `yaml:"fail_on_stale_catalog" json:"fail_on_stale_catalog"`
 	// BrokerURL is the base URL of a Lumen Host Broker exposing the
 	// /v1/nodes/watch push-discovery endpoint.
 	BrokerURL string `yaml:"broker_url" json:"broker_url"`
-	// StaticNodes pins node gRPC endpoints ("host:port") that are always
-	// resolved without any dynamic discovery. Connection health is still
-	// managed by the pool; entries only need to be reachable eventually.
+	// StaticNodes pins node gRPC endpoints ("host:port", or "[v6]:port" for
+	// IPv6) that are always resolved without any dynamic discovery.
+	// Connection health is still managed by the pool; entries only need to
+	// be reachable eventually.
 	StaticNodes []string `yaml:"static_nodes" json:"static_nodes"`
+	// AllowNodes and DenyNodes filter the nodes mDNS discovers before they
+	// are probed or connected. Each entry is a glob over the instance name
+	// or node ID ("lab-*"), a CIDR or IP matched against the node's
+	// addresses ("10.1.0.0/16"), or "cluster=<name>" matched against the
+	// TXT "cluster" label. Deny wins over allow; a non-empty AllowNodes
+	// admits only matching nodes. StaticNodes are never filtered.
+	AllowNodes []string `yaml:"allow_nodes,omitempty" json:"allow_nodes,omitempty"`
+	DenyNodes  []string `yaml:"deny_nodes,omitempty" json:"deny_nodes,omitempty"`
+	// Push accepts nodes that register themselves with the Broker and push
+	// their own updates, for networks where multicast is impossible.
+	Push PushConfig `yaml:"push" json:"push"`
+}
+
+// PushConfig controls node self-registration through the Broker's
+// /v1/push routes. Pushed nodes are not expired by discovery TTLs; they
+// stay until they deregister or miss heartbeats for HeartbeatTimeout.
+type PushConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Token is the shared secret nodes send as "Authorization: Bearer
+	// <token>". It is never serialized to JSON.
+	Token            string        `yaml:"token" json:"-"`
+	HeartbeatTimeout time.Duration `yaml:"heartbeat_timeout" json:"heartbeat_timeout"`
+	// AllowNodes, when set, admits only registrations matching one of its
+	// patterns (same syntax as DiscoveryConfig.AllowNodes). The mDNS
+	// filters do not apply to pushed nodes.
+	AllowNodes []string `yaml:"allow_nodes,omitempty" json:"allow_nodes,omitempty"`
 }
 
 // EffectiveBrokerURL returns the configured Broker push-discovery URL.
@@ -53,10 +120,65 @@ func (c DiscoveryConfig) EffectiveBrokerURL() string {
 }
 
 // BrokerConfig configures the Host Broker control-plane server.
+//
+// The server listens either on Host:Port or, when Socket is set, on a unix
+// domain socket at that path; setting both is rejected, so Port must be 0
+// when Socket is used.
 type BrokerConfig struct {
 	Enabled bool   `yaml:"enabled" json:"enabled"`
 	Host    string `yaml:"host" json:"host"`
 	Port    int    `yaml:"port" json:"port"`
+	// Socket is the unix domain socket path to listen on instead of a TCP
+	// port. A stale socket file left by a previous run is removed at startup.
+	Socket string `yaml:"socket,omitempty" json:"socket,omitempty"`
+	// SocketMode is the octal permission mode applied to Socket, e.g.
+	// "0660". Empty means 0660.
+	SocketMode string `yaml:"socket_mode,omitempty" json:"socket_mode,omitempty"`
+	// Docs serves a Swagger UI for the Broker's /openapi.json at /docs.
+	Docs bool `yaml:"docs,omitempty" json:"docs,omitempty"`
+	// ReadTimeout bounds reading one request, headers and body, so a client
+	// drip-feeding it cannot hold a connection; it gets 408 instead.
+	// WriteTimeout bounds writing one response. IdleTimeout is how long a
+	// keep-alive connection may wait for its next request; zero falls back
+	// to ReadTimeout. WebSocket connections are not subject to any of them
+	// once upgraded. Zero means no limit.
+	ReadTimeout  time.Duration `yaml:"read_timeout" json:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout" json:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" json:"idle_timeout"`
+	// Election runs this Broker as one of an active/standby pair.
+	Election ElectionConfig `yaml:"election" json:"election"`
+}
+
+// ElectionConfig makes Brokers sharing a lease file elect one leader. Only
+// the leader binds the Broker API; a standby keeps its node catalog warm
+// and takes over once the leader's lease has gone unrenewed for
+// LeaseTimeout.
+type ElectionConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// LeaseFile is the lease's path on storage every instance shares.
+	LeaseFile string `yaml:"lease_file" json:"lease_file"`
+	// LeaseTimeout is how long a lease lasts unrenewed. The leader renews
+	// it every third of this; a standby takes over after it expires.
+	LeaseTimeout time.Duration `yaml:"lease_timeout" json:"lease_timeout"`
+	// ID names this instance in the lease; empty means hostname-pid.
+	ID string `yaml:"id,omitempty" json:"id,omitempty"`
+}
+
+// DefaultSocketMode is the permission mode of the Broker socket when
+// SocketMode is empty.
+const DefaultSocketMode os.FileMode = 0o660
+
+// SocketFileMode parses SocketMode, defaulting to DefaultSocketMode.
+func (b BrokerConfig) SocketFileMode() (os.FileMode, error) {
+	raw := strings.TrimSpace(b.SocketMode)
+	if raw == "" {
+		return DefaultSocketMode, nil
+	}
+	mode, err := strconv.ParseUint(strings.TrimPrefix(raw, "0o"), 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid socket mode %q: want octal permissions such as 0660", raw)
+	}
+	return os.FileMode(mode), nil
 }
 
 // LoggingConfig configures logging output.
@@ -71,21 +193,329 @@ type ChunkConfig struct {
 	EnableAuto    bool `yaml:"enable_auto" json:"enable_auto"`
 	Threshold     int  `yaml:"threshold" json:"threshold"`
 	MaxChunkBytes int  `yaml:"max_chunk_bytes" json:"max_chunk_bytes"`
+	// ParallelStreams is how many concurrent streams a chunked payload is
+	// split across when the node advertises parallel_upload. 0 or 1 keeps
+	// one stream; at most MaxParallelStreams.
+	ParallelStreams int `yaml:"parallel_streams" json:"parallel_streams"`
+	// ParallelThreshold is the payload size in bytes above which
+	// ParallelStreams applies; 0 applies it to every chunked payload.
+	ParallelThreshold int `yaml:"parallel_threshold" json:"parallel_threshold"`
+	// Profiles picks how payloads are split by MIME type prefix, e.g.
+	// "text/": runes. The longest matching prefix wins; payloads matching
+	// none are split by bytes.
+	Profiles map[string]string `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+	// SendRetries is how many times a chunk whose Send failed with a
+	// transient error is sent again on the same stream, with a short
+	// doubling backoff, and how many times an upload whose stream broke
+	// goes on over a new one to a node advertising the resume feature,
+	// from the failed chunk. Other errors fail the request at once; 0
+	// never retries.
+	SendRetries int `yaml:"send_retries" json:"send_retries"`
+}
+
+// Ways of splitting a payload, for ChunkConfig.Profiles.
+const (
+	// ChunkSplitBytes cuts every MaxChunkBytes, whatever the content.
+	ChunkSplitBytes = "bytes"
+	// ChunkSplitRunes cuts text on UTF-8 character boundaries.
+	ChunkSplitRunes = "runes"
+	// ChunkSplitWhitespace cuts text after the last newline, or failing
+	// that whitespace, in the last quarter of each chunk, and on a
+	// character boundary if there is none.
+	ChunkSplitWhitespace = "whitespace"
+	// ChunkSplitJSON cuts by bytes and checks the chunks reassemble to the
+	// document.
+	ChunkSplitJSON = "json"
+)
+
+// MaxParallelStreams caps ChunkConfig.ParallelStreams: past a few streams
+// one node's link, not the stream, is the bottleneck.
+const MaxParallelStreams = 4
+
+// MetricsConfig controls client-side request metrics.
+type MetricsConfig struct {
+	// LatencyWindow is the sliding window over which latency percentiles
+	// are reported. Zero keeps cumulative percentiles since start.
+	LatencyWindow time.Duration `yaml:"latency_window" json:"latency_window"`
+}
+
+// TaskStateConfig controls garbage collection of per-task state, such as
+// round-robin cursors and per-task latency, for task names that come and
+// go.
+type TaskStateConfig struct {
+	// GCInterval is how often state is collected. Zero disables collection,
+	// keeping per-task state for the client's lifetime.
+	GCInterval time.Duration `yaml:"gc_interval" json:"gc_interval"`
+	// Retention is how long a task no node serves keeps its state after it
+	// was last used.
+	Retention time.Duration `yaml:"retention" json:"retention"`
+}
+
+// JobsConfig controls asynchronous jobs submitted with SubmitJob.
+type JobsConfig struct {
+	// TTL is how long a job is remembered after it was submitted or last
+	// queried; status, result and cancel fail for a job forgotten. Zero
+	// selects the default.
+	TTL time.Duration `yaml:"ttl" json:"ttl"`
+}
+
+// QueueConfig controls the durable queue of requests added with
+// EnqueueInfer. The queue is enabled when Dir is set.
+type QueueConfig struct {
+	// Dir holds the queued requests, their payloads and finished results
+	// until they are delivered. Empty disables the queue.
+	Dir string `yaml:"dir" json:"dir"`
+	// MaxBytes caps the size of the queued requests and their undelivered
+	// responses on disk; EnqueueInfer fails while it is reached. Zero means 1 GiB.
+	MaxBytes int64 `yaml:"max_bytes" json:"max_bytes"`
+	// Concurrency is how many queued requests are in flight at once. Zero
+	// means 2.
+	Concurrency int `yaml:"concurrency" json:"concurrency"`
+	// MaxAttempts is how many failed attempts move a request to the dead
+	// letters. Zero means 5.
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts"`
+	// RetryBackoff is the wait after a first failed attempt; it doubles with
+	// every further failure up to MaxRetryBackoff. Zero means 10s.
+	RetryBackoff time.Duration `yaml:"retry_backoff" json:"retry_backoff"`
+	// MaxRetryBackoff caps the wait between attempts. Zero means 10m.
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff" json:"max_retry_backoff"`
+	// AttemptTimeout bounds one attempt. Zero means 10m.
+	AttemptTimeout time.Duration `yaml:"attempt_timeout" json:"attempt_timeout"`
+	// ResultsDir, when set, receives the result of every request whose
+	// topic has no handler registered with HandleQueueResults, as
+	// <queue id>.json. Without it such results wait in Dir for a handler.
+	ResultsDir string `yaml:"results_dir" json:"results_dir"`
+}
+
+// FallbackConfig controls serving requests in-process when no node can.
+type FallbackConfig struct {
+	// Enabled lets Infer run a handler registered with
+	// client.RegisterLocalHandler when no node serves the request's task.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+}
+
+// CostHintsConfig controls how Infer uses the per-task costs a node
+// advertises in its capabilities: its p50 latency for a task and the chunk
+// size it prefers. Nodes advertising neither are unaffected.
+type CostHintsConfig struct {
+	// Enabled gives calls without a deadline a timeout derived from the
+	// picked node's p50 for the task, and chunks payloads at the size the
+	// node prefers rather than chunk.max_chunk_bytes.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// TimeoutMultiplier scales the advertised p50 into the timeout. Zero
+	// means 10.
+	TimeoutMultiplier float64 `yaml:"timeout_multiplier" json:"timeout_multiplier"`
+	// MinTimeout and MaxTimeout bound a derived timeout. Zero means 1s and
+	// 10m.
+	MinTimeout time.Duration `yaml:"min_timeout" json:"min_timeout"`
+	MaxTimeout time.Duration `yaml:"max_timeout" json:"max_timeout"`
+	// MinChunkBytes and MaxChunkBytes bound a node's preferred chunk size.
+	// Zero means 16 KiB and MaxMessageBytes.
+	MinChunkBytes int `yaml:"min_chunk_bytes" json:"min_chunk_bytes"`
+	MaxChunkBytes int `yaml:"max_chunk_bytes" json:"max_chunk_bytes"`
+}
+
+// UsageConfig controls per-tenant usage accounting. Requests are attributed
+// to the tenant set with client.WithTenant.
+type UsageConfig struct {
+	// Retention is how long per-minute usage buckets are kept for windowed
+	// queries such as GET /v1/usage?window=1h. Totals since start are kept
+	// regardless. Zero means 24h.
+	Retention time.Duration `yaml:"retention" json:"retention"`
+	// QuotaWindow is the trailing window quotas are measured over; it must
+	// not exceed Retention. Zero means 1h.
+	QuotaWindow time.Duration `yaml:"quota_window" json:"quota_window"`
+	// QuotaPolicy is what happens to a tenant over quota: "log" warns once
+	// per QuotaWindow, "reject" fails its requests with FORBIDDEN. Empty
+	// means log.
+	QuotaPolicy string `yaml:"quota_policy" json:"quota_policy"`
+	// Quotas are soft limits keyed by tenant.
+	Quotas map[string]TenantQuota `yaml:"quotas,omitempty" json:"quotas,omitempty"`
+}
+
+// TenantQuota limits what one tenant may use per UsageConfig.QuotaWindow.
+// Zero fields are unlimited.
+type TenantQuota struct {
+	Requests int64 `yaml:"requests,omitempty" json:"requests,omitempty"`
+	// Bytes counts request and response payload bytes.
+	Bytes int64 `yaml:"bytes,omitempty" json:"bytes,omitempty"`
+	// Tokens and GPUTime count what nodes report; requests to nodes that do
+	// not report them are not limited by these.
+	Tokens  int64         `yaml:"tokens,omitempty" json:"tokens,omitempty"`
+	GPUTime time.Duration `yaml:"gpu_time,omitempty" json:"gpu_time,omitempty"`
+}
+
+// Usage quota policies for UsageConfig.QuotaPolicy.
+const (
+	QuotaPolicyLog    = "log"
+	QuotaPolicyReject = "reject"
+)
+
+// PoolConfig tunes the node connections held by the client pool.
+type PoolConfig struct {
+	// MaxConnections caps how many nodes the pool connects to; nodes
+	// discovered beyond the cap are ignored until a slot frees up. Zero means
+	// no limit.
+	MaxConnections int `yaml:"max_connections" json:"max_connections"`
+	// MaxIdleTime releases every node connection after this long without an
+	// RPC; the next request reconnects. Zero keeps connections open.
+	MaxIdleTime time.Duration `yaml:"max_idle_time" json:"max_idle_time"`
+	// MaxLifetime recycles a node connection once it has been up this long.
+	// The replacement is dialled before the old connection is closed. Zero
+	// keeps connections until they fail.
+	MaxLifetime time.Duration `yaml:"max_lifetime" json:"max_lifetime"`
+	// HealthCheck enables a periodic Health RPC against every Ready node;
+	// failures count towards the node's cooldown like failed inferences.
+	HealthCheck    bool          `yaml:"health_check" json:"health_check"`
+	HealthInterval time.Duration `yaml:"health_interval" json:"health_interval"`
+	// StreamWarnThreshold logs a warning when a node has more RPC streams
+	// open than this, and StreamMaxAge one for every stream open longer
+	// than this; both point at streams that are never closed. Zero
+	// disables either warning.
+	StreamWarnThreshold int           `yaml:"stream_warn_threshold" json:"stream_warn_threshold"`
+	StreamMaxAge        time.Duration `yaml:"stream_max_age" json:"stream_max_age"`
+	// Strategy chooses among the nodes eligible for a request:
+	// StrategyRoundRobin (the default) rotates through them,
+	// StrategyLeastLatency picks the one with the lowest median latency,
+	// trying nodes without one first, and StrategyCustom picks the one with
+	// the highest combined score. A request can choose another strategy
+	// with client.WithStrategy. ScoreWeights weighs
+	// the scores summed per node, keyed ScoreCustom (the NodeScorer
+	// registered on the client), ScoreLoad and ScoreLatency; empty weighs
+	// the custom scorer alone. RandomTieBreak picks at random among equal
+	// top scores instead of the first by node ID.
+	Strategy       string             `yaml:"strategy" json:"strategy"`
+	ScoreWeights   map[string]float64 `yaml:"score_weights,omitempty" json:"score_weights,omitempty"`
+	RandomTieBreak bool               `yaml:"random_tie_break" json:"random_tie_break"`
+	// MinHealthyNodes is, per task name, how many nodes must be able to
+	// take a request for the task before one is dispatched: Ready, not
+	// cooling down, held, draining or ejected. A request for a task with
+	// fewer fails at once with SERVICE_UNAVAILABLE rather than load the
+	// nodes left; client.WithMinHealthyNodes overrides it per request.
+	// Tasks not listed need no minimum.
+	MinHealthyNodes map[string]int `yaml:"min_healthy_nodes,omitempty" json:"min_healthy_nodes,omitempty"`
+	// CallbackPanicLimit unregisters a Watch callback once it has panicked
+	// this many times. A panic in a user callback is always recovered and
+	// drops only that call; zero keeps panicking callbacks registered.
+	CallbackPanicLimit int `yaml:"callback_panic_limit" json:"callback_panic_limit"`
+	// KeepAlive pings every node connection at this interval, even with no
+	// RPC in flight, so NAT gateways and firewalls do not drop idle
+	// connections. gRPC servers reject pings more frequent than every 5
+	// minutes unless configured otherwise. Zero disables pings.
+	KeepAlive time.Duration `yaml:"keep_alive" json:"keep_alive"`
+	// KeepAliveTimeout is how long to wait for a ping ack before the
+	// connection is considered dead.
+	KeepAliveTimeout time.Duration `yaml:"keep_alive_timeout" json:"keep_alive_timeout"`
+	// MaxMessageSize is the largest gRPC message accepted from a node,
+	// capability responses included. Zero keeps gRPC's default of
+	// MaxMessageBytes.
+	MaxMessageSize int `yaml:"max_message_size" json:"max_message_size"`
+	// Compression compresses the messages sent to nodes: CompressionGzip,
+	// or CompressionNone (the default) to send them as they are.
+	Compression string `yaml:"compression" json:"compression"`
+	// TLS secures node connections. PerNode overrides it for nodes whose ID
+	// matches a key, an exact ID or a path.Match pattern such as "lab-gpu-*"
+	// (see NodeTransport). A node advertising the TXT record "tls=required"
+	// that no entry matches is dialled with TLS even when TLS.Mode is
+	// insecure, using the TLS settings here.
+	TLS     TransportConfig            `yaml:"tls" json:"tls"`
+	PerNode map[string]TransportConfig `yaml:"per_node,omitempty" json:"per_node,omitempty"`
+	// Outlier ejects nodes that keep answering far worse than the rest of
+	// the cluster without failing outright.
+	Outlier OutlierConfig `yaml:"outlier" json:"outlier"`
+	// Hysteresis keeps a node at the edge of reachability from flapping
+	// between active and error on every health check.
+	Hysteresis HysteresisConfig `yaml:"hysteresis" json:"hysteresis"`
+}
+
+// HysteresisConfig damps node status changes driven by health checks. A
+// node goes from active to error after FailThreshold failed checks in a
+// row and back after RecoverThreshold passed ones; in between it takes only
+// probe traffic. A node going to error more than FlapLimit times within
+// FlapWindow is held suspect for SuspectTime, taking only probe traffic
+// whatever its checks say. Smoothing is the weight of the newest check in
+// the node's exponentially smoothed health score, reported for diagnosis.
+// Zero thresholds and Smoothing select the defaults.
+type HysteresisConfig struct {
+	FailThreshold    int `yaml:"fail_threshold" json:"fail_threshold"`
+	RecoverThreshold int `yaml:"recover_threshold" json:"recover_threshold"`
+	// FlapLimit of zero never holds a node suspect.
+	FlapLimit   int           `yaml:"flap_limit" json:"flap_limit"`
+	FlapWindow  time.Duration `yaml:"flap_window" json:"flap_window"`
+	SuspectTime time.Duration `yaml:"suspect_time" json:"suspect_time"`
+	Smoothing   float64       `yaml:"smoothing" json:"smoothing"`
+}
+
+// OutlierConfig controls passive outlier detection. Every Interval, nodes
+// with at least MinRequests requests in the last Window are compared: one
+// whose error rate is above ErrorRateFactor times the cluster median, or
+// whose median latency is above LatencyFactor times the cluster median, is
+// ejected from selection for EjectionTime, doubling with each consecutive
+// ejection. Once it elapses the node is re-admitted gradually, starting at
+// ProbeFraction of its share of requests. At most MaxEjectionPercent of the
+// nodes are ejected at once.
+type OutlierConfig struct {
+	Enabled         bool          `yaml:"enabled" json:"enabled"`
+	Interval        time.Duration `yaml:"interval" json:"interval"`
+	Window          time.Duration `yaml:"window" json:"window"`
+	MinRequests     int           `yaml:"min_requests" json:"min_requests"`
+	ErrorRateFactor float64       `yaml:"error_rate_factor" json:"error_rate_factor"`
+	// LatencyFactor of zero ejects on error rate only.
+	LatencyFactor      float64       `yaml:"latency_factor" json:"latency_factor"`
+	EjectionTime       time.Duration `yaml:"ejection_time" json:"ejection_time"`
+	MaxEjectionPercent int           `yaml:"max_ejection_percent" json:"max_ejection_percent"`
+	ProbeFraction      float64       `yaml:"probe_fraction" json:"probe_fraction"`
+}
+
+// Selection strategies for PoolConfig.Strategy.
+const (
+	StrategyRoundRobin   = "round_robin"
+	StrategyLeastLatency = "least_latency"
+	StrategyCustom       = "custom"
+)
+
+// ValidStrategy reports whether s names a selection strategy.
+func ValidStrategy(s string) bool {
+	return s == StrategyRoundRobin || s == StrategyLeastLatency || s == StrategyCustom
+}
+
+// Compression settings for PoolConfig.Compression.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// Scores the custom strategy weighs, the keys of PoolConfig.ScoreWeights.
+// Built-in scores range from 0 to 1: ScoreLoad is 1/(1+requests in flight),
+// times 1 minus the load a pushed node reports, and ScoreLatency
+// 1/(1+median latency in seconds), 1 for an unmeasured node.
+const (
+	ScoreCustom  = "custom"
+	ScoreLoad    = "load"
+	ScoreLatency = "latency"
+)
+
+// PayloadProtectionConfig controls encryption of payload-derived data that
+// leaves process memory (caches, journals, upload state).
+//
+// Keys are never stored in the config itself: they are read from KeyFile or
+// the LUMEN_PAYLOAD_KEYS environment variable (see utils.LoadPayloadCipher).
+type PayloadProtectionConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// KeyFile lists one "id:base64key" AES key per line.
+	KeyFile string `yaml:"key_file" json:"key_file"`
+	// ActiveKeyID selects the key used for new ciphertexts; defaults to the
+	// first key listed. Other keys remain valid for decryption.
+	ActiveKeyID string `yaml:"active_key_id" json:"active_key_id"`
 }
 
 // LoadConfig loads configuration from a YAML file with environment overrides.
-// If configPath is empty, DefaultConfig is used with env overrides.
+// If configPath is empty, DefaultConfig is used with env overrides. Use
+// LocateAndLoad to search the standard locations instead.
 func LoadConfig(configPath string) (*Config, error) {
-	config := DefaultConfig()
-
-	if configPath != "" {
-		data, err := os.ReadFile(configPath)
-		if err != nil {
-			return nil, fmt.Errorf("read config %s: %w", configPath, err)
-		}
-		if err := yaml.Unmarshal(data, config); err != nil {
-			return nil, fmt.Errorf("parse config %s: %w", configPath, err)
-		}
+	config, err := LoadFile(configPath)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := config.LoadFromEnv(); err != nil {
@@ -99,6 +529,25 @@ func LoadConfig(configPath string) (*Config, error) {
 	return config, nil
 }
 
+// LoadFile reads a YAML file over DefaultConfig without applying
+// environment overrides or validating. An empty path returns DefaultConfig.
+// Unknown keys and values of the wrong type are errors: the error is a
+// ValidationErrors listing each one with its line number.
+func LoadFile(configPath string) (*Config, error) {
+	config := DefaultConfig()
+	if configPath == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", configPath, err)
+	}
+	if err := decodeStrict(data, config); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", configPath, err)
+	}
+	return config, nil
+}
+
 // LoadFromEnv applies LUMEN_* environment variable overrides.
 func (c *Config) LoadFromEnv() error {
 	if os.Getenv("LUMEN_DISCOVERY_ENABLED") != "" {
@@ -145,6 +594,48 @@ func (c *Config) LoadFromEnv() error {
 		}
 		c.Discovery.RediscoveryBackoffMax = d
 	}
+	if v := os.Getenv("LUMEN_DISCOVERY_PROBE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_DISCOVERY_PROBE_TIMEOUT: %w", err)
+		}
+		c.Discovery.ProbeTimeout = d
+	}
+	if v := os.Getenv("LUMEN_DISCOVERY_MAX_CONCURRENT_PROBES"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_DISCOVERY_MAX_CONCURRENT_PROBES: %w", err)
+		}
+		c.Discovery.MaxConcurrentProbes = n
+	}
+	if os.Getenv("LUMEN_DISCOVERY_PROVISIONAL_TRAFFIC") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_DISCOVERY_PROVISIONAL_TRAFFIC"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_DISCOVERY_PROVISIONAL_TRAFFIC: %w", err)
+		}
+		c.Discovery.ProvisionalTraffic = v
+	}
+	if v := os.Getenv("LUMEN_DISCOVERY_NOTIFY_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_DISCOVERY_NOTIFY_WINDOW: %w", err)
+		}
+		c.Discovery.NotifyWindow = d
+	}
+	if v := os.Getenv("LUMEN_DISCOVERY_MAX_CATALOG_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_DISCOVERY_MAX_CATALOG_AGE: %w", err)
+		}
+		c.Discovery.MaxCatalogAge = d
+	}
+	if os.Getenv("LUMEN_DISCOVERY_FAIL_ON_STALE_CATALOG") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_DISCOVERY_FAIL_ON_STALE_CATALOG"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_DISCOVERY_FAIL_ON_STALE_CATALOG: %w", err)
+		}
+		c.Discovery.FailOnStaleCatalog = v
+	}
 	if os.Getenv("LUMEN_DISCOVERY_MDNS_ENABLED") != "" {
 		v, err := strconv.ParseBool(os.Getenv("LUMEN_DISCOVERY_MDNS_ENABLED"))
 		if err != nil {
@@ -156,13 +647,33 @@ func (c *Config) LoadFromEnv() error {
 		c.Discovery.BrokerURL = v
 	}
 	if v := os.Getenv("LUMEN_DISCOVERY_STATIC_NODES"); v != "" {
-		var nodes []string
-		for _, part := range strings.Split(v, ",") {
-			if part = strings.TrimSpace(part); part != "" {
-				nodes = append(nodes, part)
-			}
+		c.Discovery.StaticNodes = splitList(v)
+	}
+	if v := os.Getenv("LUMEN_DISCOVERY_ALLOW_NODES"); v != "" {
+		c.Discovery.AllowNodes = splitList(v)
+	}
+	if v := os.Getenv("LUMEN_DISCOVERY_DENY_NODES"); v != "" {
+		c.Discovery.DenyNodes = splitList(v)
+	}
+	if os.Getenv("LUMEN_DISCOVERY_PUSH_ENABLED") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_DISCOVERY_PUSH_ENABLED"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_DISCOVERY_PUSH_ENABLED: %w", err)
+		}
+		c.Discovery.Push.Enabled = v
+	}
+	if v := os.Getenv("LUMEN_DISCOVERY_PUSH_TOKEN"); v != "" {
+		c.Discovery.Push.Token = v
+	}
+	if v := os.Getenv("LUMEN_DISCOVERY_PUSH_HEARTBEAT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_DISCOVERY_PUSH_HEARTBEAT_TIMEOUT: %w", err)
 		}
-		c.Discovery.StaticNodes = nodes
+		c.Discovery.Push.HeartbeatTimeout = d
+	}
+	if v := os.Getenv("LUMEN_DISCOVERY_PUSH_ALLOW_NODES"); v != "" {
+		c.Discovery.Push.AllowNodes = splitList(v)
 	}
 	if v := os.Getenv("LUMEN_BROKER_HOST"); v != "" {
 		c.Broker.Host = v
@@ -174,6 +685,65 @@ func (c *Config) LoadFromEnv() error {
 		}
 		c.Broker.Port = p
 	}
+	if v := os.Getenv("LUMEN_BROKER_SOCKET"); v != "" {
+		c.Broker.Socket = v
+		if os.Getenv("LUMEN_BROKER_PORT") == "" {
+			// Selecting a socket from the environment replaces the file's
+			// TCP listener rather than conflicting with it.
+			c.Broker.Port = 0
+		}
+	}
+	if v := os.Getenv("LUMEN_BROKER_SOCKET_MODE"); v != "" {
+		c.Broker.SocketMode = v
+	}
+	if os.Getenv("LUMEN_BROKER_DOCS") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_BROKER_DOCS"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_BROKER_DOCS: %w", err)
+		}
+		c.Broker.Docs = v
+	}
+	if v := os.Getenv("LUMEN_BROKER_READ_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_BROKER_READ_TIMEOUT: %w", err)
+		}
+		c.Broker.ReadTimeout = d
+	}
+	if v := os.Getenv("LUMEN_BROKER_WRITE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_BROKER_WRITE_TIMEOUT: %w", err)
+		}
+		c.Broker.WriteTimeout = d
+	}
+	if v := os.Getenv("LUMEN_BROKER_IDLE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_BROKER_IDLE_TIMEOUT: %w", err)
+		}
+		c.Broker.IdleTimeout = d
+	}
+	if os.Getenv("LUMEN_BROKER_ELECTION_ENABLED") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_BROKER_ELECTION_ENABLED"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_BROKER_ELECTION_ENABLED: %w", err)
+		}
+		c.Broker.Election.Enabled = v
+	}
+	if v := os.Getenv("LUMEN_BROKER_ELECTION_LEASE_FILE"); v != "" {
+		c.Broker.Election.LeaseFile = v
+	}
+	if v := os.Getenv("LUMEN_BROKER_ELECTION_LEASE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_BROKER_ELECTION_LEASE_TIMEOUT: %w", err)
+		}
+		c.Broker.Election.LeaseTimeout = d
+	}
+	if v := os.Getenv("LUMEN_BROKER_ELECTION_ID"); v != "" {
+		c.Broker.Election.ID = v
+	}
 	if v := os.Getenv("LUMEN_LOG_LEVEL"); v != "" {
 		c.Logging.Level = v
 	}
@@ -183,59 +753,703 @@ func (c *Config) LoadFromEnv() error {
 	if v := os.Getenv("LUMEN_LOG_OUTPUT"); v != "" {
 		c.Logging.Output = v
 	}
+	if os.Getenv("LUMEN_PAYLOAD_PROTECTION_ENABLED") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_PAYLOAD_PROTECTION_ENABLED"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_PAYLOAD_PROTECTION_ENABLED: %w", err)
+		}
+		c.PayloadProtection.Enabled = v
+	}
+	if v := os.Getenv("LUMEN_PAYLOAD_PROTECTION_KEY_FILE"); v != "" {
+		c.PayloadProtection.KeyFile = v
+	}
+	if v := os.Getenv("LUMEN_PAYLOAD_PROTECTION_ACTIVE_KEY_ID"); v != "" {
+		c.PayloadProtection.ActiveKeyID = v
+	}
+	if v := os.Getenv("LUMEN_METRICS_LATENCY_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_METRICS_LATENCY_WINDOW: %w", err)
+		}
+		c.Metrics.LatencyWindow = d
+	}
+	if v := os.Getenv("LUMEN_POOL_MAX_CONNECTIONS"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_MAX_CONNECTIONS: %w", err)
+		}
+		c.Pool.MaxConnections = n
+	}
+	if v := os.Getenv("LUMEN_POOL_MAX_IDLE_TIME"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_MAX_IDLE_TIME: %w", err)
+		}
+		c.Pool.MaxIdleTime = d
+	}
+	if v := os.Getenv("LUMEN_POOL_MAX_LIFETIME"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_MAX_LIFETIME: %w", err)
+		}
+		c.Pool.MaxLifetime = d
+	}
+	if os.Getenv("LUMEN_POOL_HEALTH_CHECK") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_POOL_HEALTH_CHECK"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_HEALTH_CHECK: %w", err)
+		}
+		c.Pool.HealthCheck = v
+	}
+	if v := os.Getenv("LUMEN_POOL_HEALTH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_HEALTH_INTERVAL: %w", err)
+		}
+		c.Pool.HealthInterval = d
+	}
+	if v := os.Getenv("LUMEN_POOL_STREAM_WARN_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_STREAM_WARN_THRESHOLD: %w", err)
+		}
+		c.Pool.StreamWarnThreshold = n
+	}
+	if v := os.Getenv("LUMEN_POOL_STREAM_MAX_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_STREAM_MAX_AGE: %w", err)
+		}
+		c.Pool.StreamMaxAge = d
+	}
+	if v := os.Getenv("LUMEN_POOL_STRATEGY"); v != "" {
+		c.Pool.Strategy = v
+	}
+	if os.Getenv("LUMEN_POOL_RANDOM_TIE_BREAK") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_POOL_RANDOM_TIE_BREAK"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_RANDOM_TIE_BREAK: %w", err)
+		}
+		c.Pool.RandomTieBreak = v
+	}
+	if v := os.Getenv("LUMEN_POOL_CALLBACK_PANIC_LIMIT"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_CALLBACK_PANIC_LIMIT: %w", err)
+		}
+		c.Pool.CallbackPanicLimit = n
+	}
+	if v := os.Getenv("LUMEN_POOL_KEEP_ALIVE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_KEEP_ALIVE: %w", err)
+		}
+		c.Pool.KeepAlive = d
+	}
+	if v := os.Getenv("LUMEN_POOL_KEEP_ALIVE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_KEEP_ALIVE_TIMEOUT: %w", err)
+		}
+		c.Pool.KeepAliveTimeout = d
+	}
+	if v := os.Getenv("LUMEN_POOL_MAX_MESSAGE_SIZE"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_MAX_MESSAGE_SIZE: %w", err)
+		}
+		c.Pool.MaxMessageSize = n
+	}
+	if v := os.Getenv("LUMEN_POOL_COMPRESSION"); v != "" {
+		c.Pool.Compression = v
+	}
+	if os.Getenv("LUMEN_POOL_OUTLIER_ENABLED") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_POOL_OUTLIER_ENABLED"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_OUTLIER_ENABLED: %w", err)
+		}
+		c.Pool.Outlier.Enabled = v
+	}
+	if v := os.Getenv("LUMEN_POOL_OUTLIER_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_OUTLIER_INTERVAL: %w", err)
+		}
+		c.Pool.Outlier.Interval = d
+	}
+	if v := os.Getenv("LUMEN_POOL_OUTLIER_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_OUTLIER_WINDOW: %w", err)
+		}
+		c.Pool.Outlier.Window = d
+	}
+	if v := os.Getenv("LUMEN_POOL_OUTLIER_MIN_REQUESTS"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_OUTLIER_MIN_REQUESTS: %w", err)
+		}
+		c.Pool.Outlier.MinRequests = n
+	}
+	if v := os.Getenv("LUMEN_POOL_OUTLIER_ERROR_RATE_FACTOR"); v != "" {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_OUTLIER_ERROR_RATE_FACTOR: %w", err)
+		}
+		c.Pool.Outlier.ErrorRateFactor = f
+	}
+	if v := os.Getenv("LUMEN_POOL_OUTLIER_LATENCY_FACTOR"); v != "" {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_OUTLIER_LATENCY_FACTOR: %w", err)
+		}
+		c.Pool.Outlier.LatencyFactor = f
+	}
+	if v := os.Getenv("LUMEN_POOL_OUTLIER_EJECTION_TIME"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_OUTLIER_EJECTION_TIME: %w", err)
+		}
+		c.Pool.Outlier.EjectionTime = d
+	}
+	if v := os.Getenv("LUMEN_POOL_OUTLIER_MAX_EJECTION_PERCENT"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_OUTLIER_MAX_EJECTION_PERCENT: %w", err)
+		}
+		c.Pool.Outlier.MaxEjectionPercent = n
+	}
+	if v := os.Getenv("LUMEN_POOL_OUTLIER_PROBE_FRACTION"); v != "" {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_OUTLIER_PROBE_FRACTION: %w", err)
+		}
+		c.Pool.Outlier.ProbeFraction = f
+	}
+	if v := os.Getenv("LUMEN_POOL_HYSTERESIS_FAIL_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_HYSTERESIS_FAIL_THRESHOLD: %w", err)
+		}
+		c.Pool.Hysteresis.FailThreshold = n
+	}
+	if v := os.Getenv("LUMEN_POOL_HYSTERESIS_RECOVER_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_HYSTERESIS_RECOVER_THRESHOLD: %w", err)
+		}
+		c.Pool.Hysteresis.RecoverThreshold = n
+	}
+	if v := os.Getenv("LUMEN_POOL_HYSTERESIS_FLAP_LIMIT"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_HYSTERESIS_FLAP_LIMIT: %w", err)
+		}
+		c.Pool.Hysteresis.FlapLimit = n
+	}
+	if v := os.Getenv("LUMEN_POOL_HYSTERESIS_FLAP_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_HYSTERESIS_FLAP_WINDOW: %w", err)
+		}
+		c.Pool.Hysteresis.FlapWindow = d
+	}
+	if v := os.Getenv("LUMEN_POOL_HYSTERESIS_SUSPECT_TIME"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_HYSTERESIS_SUSPECT_TIME: %w", err)
+		}
+		c.Pool.Hysteresis.SuspectTime = d
+	}
+	if v := os.Getenv("LUMEN_POOL_HYSTERESIS_SMOOTHING"); v != "" {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("LUMEN_POOL_HYSTERESIS_SMOOTHING: %w", err)
+		}
+		c.Pool.Hysteresis.Smoothing = f
+	}
+	if v := os.Getenv("LUMEN_USAGE_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_USAGE_RETENTION: %w", err)
+		}
+		c.Usage.Retention = d
+	}
+	if v := os.Getenv("LUMEN_USAGE_QUOTA_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_USAGE_QUOTA_WINDOW: %w", err)
+		}
+		c.Usage.QuotaWindow = d
+	}
+	if v := os.Getenv("LUMEN_USAGE_QUOTA_POLICY"); v != "" {
+		c.Usage.QuotaPolicy = v
+	}
+	if os.Getenv("LUMEN_FALLBACK_ENABLED") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_FALLBACK_ENABLED"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_FALLBACK_ENABLED: %w", err)
+		}
+		c.Fallback.Enabled = v
+	}
+	if os.Getenv("LUMEN_COST_HINTS_ENABLED") != "" {
+		v, err := strconv.ParseBool(os.Getenv("LUMEN_COST_HINTS_ENABLED"))
+		if err != nil {
+			return fmt.Errorf("LUMEN_COST_HINTS_ENABLED: %w", err)
+		}
+		c.CostHints.Enabled = v
+	}
+	if v := os.Getenv("LUMEN_COST_HINTS_TIMEOUT_MULTIPLIER"); v != "" {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("LUMEN_COST_HINTS_TIMEOUT_MULTIPLIER: %w", err)
+		}
+		c.CostHints.TimeoutMultiplier = f
+	}
+	if v := os.Getenv("LUMEN_COST_HINTS_MIN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_COST_HINTS_MIN_TIMEOUT: %w", err)
+		}
+		c.CostHints.MinTimeout = d
+	}
+	if v := os.Getenv("LUMEN_COST_HINTS_MAX_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_COST_HINTS_MAX_TIMEOUT: %w", err)
+		}
+		c.CostHints.MaxTimeout = d
+	}
+	if v := os.Getenv("LUMEN_COST_HINTS_MIN_CHUNK_BYTES"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_COST_HINTS_MIN_CHUNK_BYTES: %w", err)
+		}
+		c.CostHints.MinChunkBytes = n
+	}
+	if v := os.Getenv("LUMEN_COST_HINTS_MAX_CHUNK_BYTES"); v != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("LUMEN_COST_HINTS_MAX_CHUNK_BYTES: %w", err)
+		}
+		c.CostHints.MaxChunkBytes = n
+	}
+	if v := os.Getenv("LUMEN_TASK_STATE_GC_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_TASK_STATE_GC_INTERVAL: %w", err)
+		}
+		c.TaskState.GCInterval = d
+	}
+	if v := os.Getenv("LUMEN_TASK_STATE_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_TASK_STATE_RETENTION: %w", err)
+		}
+		c.TaskState.Retention = d
+	}
+	if v := os.Getenv("LUMEN_JOBS_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_JOBS_TTL: %w", err)
+		}
+		c.Jobs.TTL = d
+	}
+	if v := os.Getenv("LUMEN_QUEUE_DIR"); v != "" {
+		c.Queue.Dir = v
+	}
+	if v := os.Getenv("LUMEN_QUEUE_MAX_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("LUMEN_QUEUE_MAX_BYTES: %w", err)
+		}
+		c.Queue.MaxBytes = n
+	}
+	if v := os.Getenv("LUMEN_QUEUE_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_QUEUE_CONCURRENCY: %w", err)
+		}
+		c.Queue.Concurrency = n
+	}
+	if v := os.Getenv("LUMEN_QUEUE_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_QUEUE_MAX_ATTEMPTS: %w", err)
+		}
+		c.Queue.MaxAttempts = n
+	}
+	if v := os.Getenv("LUMEN_QUEUE_RETRY_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_QUEUE_RETRY_BACKOFF: %w", err)
+		}
+		c.Queue.RetryBackoff = d
+	}
+	if v := os.Getenv("LUMEN_QUEUE_MAX_RETRY_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_QUEUE_MAX_RETRY_BACKOFF: %w", err)
+		}
+		c.Queue.MaxRetryBackoff = d
+	}
+	if v := os.Getenv("LUMEN_QUEUE_ATTEMPT_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("LUMEN_QUEUE_ATTEMPT_TIMEOUT: %w", err)
+		}
+		c.Queue.AttemptTimeout = d
+	}
+	if v := os.Getenv("LUMEN_QUEUE_RESULTS_DIR"); v != "" {
+		c.Queue.ResultsDir = v
+	}
+	if v := os.Getenv("LUMEN_POOL_TLS_MODE"); v != "" {
+		c.Pool.TLS.Mode = v
+	}
+	if v := os.Getenv("LUMEN_POOL_TLS_CA_FILE"); v != "" {
+		c.Pool.TLS.CAFile = v
+	}
+	if v := os.Getenv("LUMEN_POOL_TLS_CERT_FILE"); v != "" {
+		c.Pool.TLS.CertFile = v
+	}
+	if v := os.Getenv("LUMEN_POOL_TLS_KEY_FILE"); v != "" {
+		c.Pool.TLS.KeyFile = v
+	}
+	if v := os.Getenv("LUMEN_POOL_TLS_SERVER_NAME"); v != "" {
+		c.Pool.TLS.ServerName = v
+	}
 	return nil
 }
 
-// Validate checks for configuration correctness.
+// Validate checks for configuration correctness. Every violation is
+// reported, not just the first: the returned error is a ValidationErrors
+// listing them all.
 func (c *Config) Validate() error {
+	var errs ValidationErrors
 	if c.Discovery.Enabled {
 		if c.Discovery.ServiceType == "" {
-			return fmt.Errorf("discovery.service_type is required when enabled")
+			errs.addf("discovery.service_type is required when enabled")
 		}
 		if c.Discovery.DeploymentID == "" {
-			return fmt.Errorf("discovery.deployment_id is required when enabled")
+			errs.addf("discovery.deployment_id is required when enabled")
 		}
 		if c.Discovery.ResolveTimeout <= 0 {
-			return fmt.Errorf("discovery.resolve_timeout must be positive")
+			errs.addf("discovery.resolve_timeout must be positive")
 		}
 		if c.Discovery.ConnectTimeout <= 0 {
-			return fmt.Errorf("discovery.connect_timeout must be positive")
+			errs.addf("discovery.connect_timeout must be positive")
 		}
 		if c.Discovery.RediscoveryBackoffMin <= 0 {
-			return fmt.Errorf("discovery.rediscovery_backoff_min must be positive")
+			errs.addf("discovery.rediscovery_backoff_min must be positive")
 		}
 		if c.Discovery.RediscoveryBackoffMax < c.Discovery.RediscoveryBackoffMin {
-			return fmt.Errorf("discovery.rediscovery_backoff_max must be >= rediscovery_backoff_min")
+			errs.addf("discovery.rediscovery_backoff_max must be >= discovery.rediscovery_backoff_min")
 		}
 		if c.Discovery.ScanInterval < 0 {
-			return fmt.Errorf("discovery.scan_interval must be non-negative")
+			errs.addf("discovery.scan_interval must be non-negative")
+		}
+		if c.Discovery.MDNSEnabled && c.Discovery.ScanInterval > 0 && c.Discovery.ResolveTimeout > c.Discovery.ScanInterval {
+			errs.addf("discovery.resolve_timeout (%s) must not exceed discovery.scan_interval (%s)",
+				c.Discovery.ResolveTimeout, c.Discovery.ScanInterval)
+		}
+		if c.Discovery.ProbeTimeout < 0 {
+			errs.addf("discovery.probe_timeout must be non-negative")
+		}
+		if c.Discovery.MaxConcurrentProbes < 0 {
+			errs.addf("discovery.max_concurrent_probes must be non-negative")
+		}
+		if c.Discovery.NotifyWindow < 0 {
+			errs.addf("discovery.notify_window must be non-negative")
+		}
+		if c.Discovery.MaxCatalogAge < 0 {
+			errs.addf("discovery.max_catalog_age must be non-negative")
+		}
+		if c.Discovery.FailOnStaleCatalog && c.Discovery.MaxCatalogAge == 0 {
+			errs.addf("discovery.fail_on_stale_catalog requires discovery.max_catalog_age")
 		}
 		for _, node := range c.Discovery.StaticNodes {
-			if _, _, err := net.SplitHostPort(strings.TrimSpace(node)); err != nil {
-				return fmt.Errorf("discovery.static_nodes entry %q must be host:port: %w", node, err)
+			if _, err := netaddr.ParseHostPort(node, 0); err != nil {
+				errs.addf("discovery.static_nodes entry must be host:port or [v6]:port: %w", err)
+			}
+		}
+		if u := c.Discovery.BrokerURL; u != "" {
+			if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				errs.addf("discovery.broker_url %q must be an http:// or https:// URL", u)
 			}
 		}
+		validateNodePatterns(&errs, "discovery.allow_nodes", c.Discovery.AllowNodes)
+		validateNodePatterns(&errs, "discovery.deny_nodes", c.Discovery.DenyNodes)
+		validateNodePatterns(&errs, "discovery.push.allow_nodes", c.Discovery.Push.AllowNodes)
+		if c.Discovery.Push.Enabled {
+			if c.Discovery.Push.Token == "" {
+				errs.addf("discovery.push.token is required when push registration is enabled")
+			}
+			if c.Discovery.Push.HeartbeatTimeout <= 0 {
+				errs.addf("discovery.push.heartbeat_timeout must be positive")
+			}
+		}
+		if !c.Discovery.MDNSEnabled && c.Discovery.BrokerURL == "" && len(c.Discovery.StaticNodes) == 0 && !c.Discovery.Push.Enabled {
+			errs.addf("discovery needs a backend when enabled: set discovery.mdns_enabled, discovery.broker_url, discovery.static_nodes or discovery.push")
+		}
 	}
 	if c.Broker.Enabled {
-		if c.Broker.Port <= 0 || c.Broker.Port > 65535 {
-			return fmt.Errorf("broker.port must be in 1-65535")
+		if c.Broker.Host != "" && c.Broker.Socket == "" {
+			if _, err := netaddr.ParseHost(c.Broker.Host); err != nil {
+				errs.addf("broker.host must be a hostname or IP address: %w", err)
+			}
+		}
+		switch {
+		case c.Broker.Socket != "" && c.Broker.Port != 0:
+			errs.addf("broker.socket and broker.port are mutually exclusive; set broker.port to 0 to listen on the socket")
+		case c.Broker.Socket != "":
+			if _, err := c.Broker.SocketFileMode(); err != nil {
+				errs.addf("broker.socket_mode: %w", err)
+			}
+		case c.Broker.Port <= 0 || c.Broker.Port > 65535:
+			errs.addf("broker.port must be in 1-65535 (or set broker.socket)")
+		}
+		if c.Broker.ReadTimeout < 0 {
+			errs.addf("broker.read_timeout must be non-negative")
+		}
+		if c.Broker.WriteTimeout < 0 {
+			errs.addf("broker.write_timeout must be non-negative")
+		}
+		if c.Broker.IdleTimeout < 0 {
+			errs.addf("broker.idle_timeout must be non-negative")
+		}
+		if e := c.Broker.Election; e.Enabled {
+			if e.LeaseFile == "" {
+				errs.addf("broker.election.lease_file is required when enabled")
+			}
+			if e.LeaseTimeout < time.Second {
+				errs.addf("broker.election.lease_timeout must be at least 1s")
+			}
+		}
+	}
+	if c.PayloadProtection.Enabled && c.PayloadProtection.KeyFile == "" && os.Getenv("LUMEN_PAYLOAD_KEYS") == "" {
+		errs.addf("payload_protection.key_file or LUMEN_PAYLOAD_KEYS is required when enabled")
+	}
+	if c.Chunk.EnableAuto {
+		if c.Chunk.Threshold < 0 {
+			errs.addf("chunk.threshold must be non-negative")
+		}
+		if c.Chunk.MaxChunkBytes <= 0 {
+			errs.addf("chunk.max_chunk_bytes must be positive when chunk.enable_auto is set")
+		} else if c.Chunk.MaxChunkBytes > MaxMessageBytes {
+			errs.addf("chunk.max_chunk_bytes (%d) must not exceed %d, the gRPC message size limit nodes enforce", c.Chunk.MaxChunkBytes, MaxMessageBytes)
+		}
+		if c.Chunk.ParallelStreams < 0 || c.Chunk.ParallelStreams > MaxParallelStreams {
+			errs.addf("chunk.parallel_streams (%d) must be between 0 and %d", c.Chunk.ParallelStreams, MaxParallelStreams)
+		}
+		if c.Chunk.ParallelThreshold < 0 {
+			errs.addf("chunk.parallel_threshold must be non-negative")
+		}
+		if c.Chunk.SendRetries < 0 {
+			errs.addf("chunk.send_retries must be non-negative")
+		}
+		prefixes := make([]string, 0, len(c.Chunk.Profiles))
+		for prefix := range c.Chunk.Profiles {
+			prefixes = append(prefixes, prefix)
+		}
+		sort.Strings(prefixes)
+		for _, prefix := range prefixes {
+			switch mode := c.Chunk.Profiles[prefix]; mode {
+			case ChunkSplitBytes, ChunkSplitRunes, ChunkSplitWhitespace, ChunkSplitJSON:
+			default:
+				errs.addf("chunk.profiles[%q] must be one of %s, %s, %s or %s, got %q", prefix,
+					ChunkSplitBytes, ChunkSplitRunes, ChunkSplitWhitespace, ChunkSplitJSON, mode)
+			}
+		}
+	}
+	if c.Metrics.LatencyWindow < 0 {
+		errs.addf("metrics.latency_window must be non-negative")
+	}
+	if c.CostHints.TimeoutMultiplier < 0 {
+		errs.addf("cost_hints.timeout_multiplier must be non-negative")
+	}
+	if c.CostHints.MinTimeout < 0 || c.CostHints.MaxTimeout < 0 {
+		errs.addf("cost_hints.min_timeout and cost_hints.max_timeout must be non-negative")
+	} else if c.CostHints.MinTimeout > 0 && c.CostHints.MaxTimeout > 0 && c.CostHints.MaxTimeout < c.CostHints.MinTimeout {
+		errs.addf("cost_hints.max_timeout must be at least cost_hints.min_timeout")
+	}
+	if c.CostHints.MinChunkBytes < 0 || c.CostHints.MaxChunkBytes < 0 {
+		errs.addf("cost_hints.min_chunk_bytes and cost_hints.max_chunk_bytes must be non-negative")
+	} else if c.CostHints.MaxChunkBytes > MaxMessageBytes {
+		errs.addf("cost_hints.max_chunk_bytes (%d) must not exceed %d, the gRPC message size limit nodes enforce", c.CostHints.MaxChunkBytes, MaxMessageBytes)
+	} else if c.CostHints.MinChunkBytes > 0 && c.CostHints.MaxChunkBytes > 0 && c.CostHints.MaxChunkBytes < c.CostHints.MinChunkBytes {
+		errs.addf("cost_hints.max_chunk_bytes must be at least cost_hints.min_chunk_bytes")
+	}
+	if c.Jobs.TTL < 0 {
+		errs.addf("jobs.ttl must be non-negative")
+	}
+	if c.Queue.MaxBytes < 0 {
+		errs.addf("queue.max_bytes must be non-negative")
+	}
+	if c.Queue.Concurrency < 0 {
+		errs.addf("queue.concurrency must be non-negative")
+	}
+	if c.Queue.MaxAttempts < 0 {
+		errs.addf("queue.max_attempts must be non-negative")
+	}
+	if c.Queue.RetryBackoff < 0 || c.Queue.MaxRetryBackoff < 0 || c.Queue.AttemptTimeout < 0 {
+		errs.addf("queue.retry_backoff, queue.max_retry_backoff and queue.attempt_timeout must be non-negative")
+	}
+	if c.Queue.RetryBackoff > 0 && c.Queue.MaxRetryBackoff > 0 && c.Queue.MaxRetryBackoff < c.Queue.RetryBackoff {
+		errs.addf("queue.max_retry_backoff must be at least queue.retry_backoff")
+	}
+	if c.Queue.ResultsDir != "" && c.Queue.ResultsDir == c.Queue.Dir {
+		errs.addf("queue.results_dir must differ from queue.dir")
+	}
+	if c.TaskState.GCInterval < 0 {
+		errs.addf("task_state.gc_interval must be non-negative")
+	}
+	if c.TaskState.GCInterval > 0 && c.TaskState.Retention <= 0 {
+		errs.addf("task_state.retention must be positive when task_state.gc_interval is set")
+	}
+	if c.Pool.MaxConnections < 0 {
+		errs.addf("pool.max_connections must be non-negative")
+	}
+	if c.Pool.MaxIdleTime < 0 {
+		errs.addf("pool.max_idle_time must be non-negative")
+	}
+	if c.Pool.MaxLifetime < 0 {
+		errs.addf("pool.max_lifetime must be non-negative")
+	}
+	if c.Pool.HealthCheck && c.Pool.HealthInterval <= 0 {
+		errs.addf("pool.health_interval must be positive when health_check is enabled")
+	}
+	if c.Pool.StreamWarnThreshold < 0 {
+		errs.addf("pool.stream_warn_threshold must be non-negative")
+	}
+	if c.Pool.StreamMaxAge < 0 {
+		errs.addf("pool.stream_max_age must be non-negative")
+	}
+	if c.Pool.Strategy != "" && !ValidStrategy(c.Pool.Strategy) {
+		errs.addf("pool.strategy %q must be round_robin, least_latency or custom", c.Pool.Strategy)
+	}
+	scores := make([]string, 0, len(c.Pool.ScoreWeights))
+	for score := range c.Pool.ScoreWeights {
+		scores = append(scores, score)
+	}
+	sort.Strings(scores)
+	for _, score := range scores {
+		w := c.Pool.ScoreWeights[score]
+		switch {
+		case score != ScoreCustom && score != ScoreLoad && score != ScoreLatency:
+			errs.addf("pool.score_weights: unknown score %q (want custom, load or latency)", score)
+		case w < 0 || math.IsInf(w, 0) || math.IsNaN(w):
+			errs.addf("pool.score_weights[%q] must be a non-negative number", score)
+		}
+	}
+	minTasks := make([]string, 0, len(c.Pool.MinHealthyNodes))
+	for task := range c.Pool.MinHealthyNodes {
+		minTasks = append(minTasks, task)
+	}
+	sort.Strings(minTasks)
+	for _, task := range minTasks {
+		switch {
+		case strings.TrimSpace(task) == "":
+			errs.addf("pool.min_healthy_nodes: task name must not be empty")
+		case c.Pool.MinHealthyNodes[task] < 0:
+			errs.addf("pool.min_healthy_nodes[%q] must be non-negative", task)
+		}
+	}
+	if c.Pool.CallbackPanicLimit < 0 {
+		errs.addf("pool.callback_panic_limit must be non-negative")
+	}
+	if c.Pool.KeepAlive < 0 {
+		errs.addf("pool.keep_alive must be non-negative")
+	}
+	if c.Pool.KeepAlive > 0 && c.Pool.KeepAliveTimeout <= 0 {
+		errs.addf("pool.keep_alive_timeout must be positive when pool.keep_alive is set")
+	}
+	if c.Pool.MaxMessageSize < 0 {
+		errs.addf("pool.max_message_size must be non-negative")
+	}
+	if c.Pool.Compression != "" && c.Pool.Compression != CompressionNone && c.Pool.Compression != CompressionGzip {
+		errs.addf("pool.compression %q must be none or gzip", c.Pool.Compression)
+	}
+	validateTransport(&errs, "pool.tls", c.Pool.TLS, false)
+	validatePerNode(&errs, c.Pool.PerNode)
+	if h := c.Pool.Hysteresis; h.FailThreshold < 0 || h.RecoverThreshold < 0 || h.FlapLimit < 0 {
+		errs.addf("pool.hysteresis fail_threshold, recover_threshold and flap_limit must be non-negative")
+	} else if h.FlapLimit > 0 && (h.FlapWindow <= 0 || h.SuspectTime <= 0) {
+		errs.addf("pool.hysteresis.flap_window and suspect_time must be positive when flap_limit is set")
+	}
+	if h := c.Pool.Hysteresis; h.Smoothing < 0 || h.Smoothing > 1 {
+		errs.addf("pool.hysteresis.smoothing must be between 0 and 1")
+	}
+	if o := c.Pool.Outlier; o.Enabled {
+		if o.Interval <= 0 {
+			errs.addf("pool.outlier.interval must be positive when outlier detection is enabled")
+		}
+		if o.Window < o.Interval {
+			errs.addf("pool.outlier.window (%s) must be at least pool.outlier.interval (%s)", o.Window, o.Interval)
+		}
+		if o.MinRequests < 1 {
+			errs.addf("pool.outlier.min_requests must be at least 1")
+		}
+		if !(o.ErrorRateFactor > 1) || math.IsInf(o.ErrorRateFactor, 0) {
+			errs.addf("pool.outlier.error_rate_factor must be a number above 1")
+		}
+		if o.LatencyFactor != 0 && (!(o.LatencyFactor > 1) || math.IsInf(o.LatencyFactor, 0)) {
+			errs.addf("pool.outlier.latency_factor must be 0 or a number above 1")
+		}
+		if o.EjectionTime <= 0 {
+			errs.addf("pool.outlier.ejection_time must be positive")
+		}
+		if o.MaxEjectionPercent < 1 || o.MaxEjectionPercent > 100 {
+			errs.addf("pool.outlier.max_ejection_percent must be between 1 and 100")
+		}
+		if !(o.ProbeFraction > 0 && o.ProbeFraction <= 1) {
+			errs.addf("pool.outlier.probe_fraction must be above 0 and at most 1")
+		}
+	}
+	if c.Usage.Retention < 0 {
+		errs.addf("usage.retention must be non-negative")
+	}
+	if c.Usage.QuotaWindow < 0 {
+		errs.addf("usage.quota_window must be non-negative")
+	} else if c.Usage.Retention > 0 && c.Usage.QuotaWindow > c.Usage.Retention {
+		errs.addf("usage.quota_window (%s) must not exceed usage.retention (%s)", c.Usage.QuotaWindow, c.Usage.Retention)
+	}
+	if c.Usage.QuotaPolicy != "" && c.Usage.QuotaPolicy != QuotaPolicyLog && c.Usage.QuotaPolicy != QuotaPolicyReject {
+		errs.addf("usage.quota_policy %q must be log or reject", c.Usage.QuotaPolicy)
+	}
+	tenants := make([]string, 0, len(c.Usage.Quotas))
+	for tenant := range c.Usage.Quotas {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		q := c.Usage.Quotas[tenant]
+		switch {
+		case strings.TrimSpace(tenant) == "":
+			errs.addf("usage.quotas: tenant name must not be empty")
+		case q.Requests < 0 || q.Bytes < 0 || q.Tokens < 0 || q.GPUTime < 0:
+			errs.addf("usage.quotas[%q]: limits must be non-negative", tenant)
 		}
 	}
 	if !validLogLevel[c.Logging.Level] {
-		return fmt.Errorf("invalid log level: %s", c.Logging.Level)
+		errs.addf("logging.level %q must be one of debug, info, warn, error, fatal", c.Logging.Level)
 	}
 	if !validLogFormat[c.Logging.Format] {
-		return fmt.Errorf("invalid log format: %s", c.Logging.Format)
+		errs.addf("logging.format %q must be json or text", c.Logging.Format)
 	}
-	return nil
+	return errs.orNil()
 }
 
+// MaxMessageBytes is the largest gRPC message a node accepts by default;
+// every chunk has to fit in one message.
+const MaxMessageBytes = 4 << 20
+
 var validLogLevel = map[string]bool{"debug": true, "info": true, "warn": true, "error": true, "fatal": true}
 var validLogFormat = map[string]bool{"json": true, "text": true}
 
-// SaveConfig writes the configuration to a YAML file.
+// SaveConfig writes the configuration to a YAML file, with a comment above
+// each field describing it.
 func (c *Config) SaveConfig(path string) error {
-	data, err := yaml.Marshal(c)
+	data, err := c.AnnotatedYAML(nil)
 	if err != nil {
 		return fmt.Errorf("marshal: %w", err)
 	}
@@ -245,6 +1459,41 @@ func (c *Config) SaveConfig(path string) error {
 	return nil
 }
 
+// splitList splits a comma-separated environment value, dropping blanks.
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// validateNodePatterns checks discovery node filter patterns: a
+// "cluster=<name>" label, a CIDR or IP, or a path.Match glob.
+func validateNodePatterns(errs *ValidationErrors, field string, patterns []string) {
+	for _, raw := range patterns {
+		p := strings.TrimSpace(raw)
+		switch {
+		case p == "":
+			errs.addf("%s entries must not be empty", field)
+		case strings.HasPrefix(p, "cluster="):
+			if p == "cluster=" {
+				errs.addf("%s entry %q names no cluster", field, raw)
+			}
+		case strings.Contains(p, "/"):
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				errs.addf("%s entry %q is not a valid CIDR: %w", field, raw, err)
+			}
+		default:
+			if _, err := path.Match(p, ""); err != nil {
+				errs.addf("%s entry %q is not a valid glob: %w", field, raw, err)
+			}
+		}
+	}
+}
+
 func parsePort(s string) (int, error) {
 	return strconv.Atoi(strings.TrimSpace(s))
 }
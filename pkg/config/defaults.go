@@ -15,13 +15,27 @@ func DefaultConfig() *Config {
 			RediscoveryBackoffMin: 10 * time.Second,
 			RediscoveryBackoffMax: 2 * time.Minute,
 			ScanInterval:          30 * time.Second,
+			ProbeTimeout:          10 * time.Second,
+			MaxConcurrentProbes:   4,
+			ProvisionalTraffic:    true,
+			NotifyWindow:          200 * time.Millisecond,
+			MaxCatalogAge:         10 * time.Minute,
 			MDNSEnabled:           true,
 			BrokerURL:             "",
+			Push: PushConfig{
+				HeartbeatTimeout: 30 * time.Second,
+			},
 		},
 		Broker: BrokerConfig{
-			Enabled: true,
-			Host:    "0.0.0.0",
-			Port:    5866,
+			Enabled:      true,
+			Host:         "0.0.0.0",
+			Port:         5866,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  2 * time.Minute,
+			Election: ElectionConfig{
+				LeaseTimeout: 15 * time.Second,
+			},
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
@@ -32,6 +46,75 @@ func DefaultConfig() *Config {
 			EnableAuto:    true,
 			Threshold:     1 << 20,    // 1 MiB
 			MaxChunkBytes: 256 * 1024, // 256 KiB
+			// Parallel upload is opt-in: set ParallelStreams to enable it.
+			ParallelThreshold: 16 << 20, // 16 MiB
+			SendRetries:       3,
+			Profiles: map[string]string{
+				"text/":            ChunkSplitRunes,
+				"application/json": ChunkSplitJSON,
+			},
+		},
+		Pool: PoolConfig{
+			MaxConnections:      0, // unlimited
+			MaxIdleTime:         30 * time.Minute,
+			MaxLifetime:         0,
+			HealthCheck:         true,
+			HealthInterval:      30 * time.Second,
+			StreamWarnThreshold: 256,
+			StreamMaxAge:        30 * time.Minute,
+			Strategy:            StrategyRoundRobin,
+			KeepAlive:           5 * time.Minute,
+			KeepAliveTimeout:    20 * time.Second,
+			MaxMessageSize:      MaxMessageBytes,
+			Compression:         CompressionNone,
+			TLS:                 TransportConfig{Mode: TransportInsecure},
+			Outlier: OutlierConfig{
+				Enabled:            false,
+				Interval:           10 * time.Second,
+				Window:             time.Minute,
+				MinRequests:        20,
+				ErrorRateFactor:    3,
+				LatencyFactor:      3,
+				EjectionTime:       30 * time.Second,
+				MaxEjectionPercent: 34,
+				ProbeFraction:      0.1,
+			},
+			Hysteresis: HysteresisConfig{
+				FailThreshold:    3,
+				RecoverThreshold: 2,
+				FlapLimit:        3,
+				FlapWindow:       10 * time.Minute,
+				SuspectTime:      5 * time.Minute,
+				Smoothing:        0.3,
+			},
+		},
+		CostHints: CostHintsConfig{
+			Enabled:           true,
+			TimeoutMultiplier: 10,
+			MinTimeout:        time.Second,
+			MaxTimeout:        10 * time.Minute,
+			MinChunkBytes:     16 * 1024, // 16 KiB
+			MaxChunkBytes:     MaxMessageBytes,
+		},
+		Usage: UsageConfig{
+			Retention:   24 * time.Hour,
+			QuotaWindow: time.Hour,
+			QuotaPolicy: QuotaPolicyLog,
+		},
+		TaskState: TaskStateConfig{
+			GCInterval: time.Minute,
+			Retention:  10 * time.Minute,
+		},
+		Jobs: JobsConfig{
+			TTL: time.Hour,
+		},
+		Queue: QueueConfig{
+			MaxBytes:        1 << 30,
+			Concurrency:     2,
+			MaxAttempts:     5,
+			RetryBackoff:    10 * time.Second,
+			MaxRetryBackoff: 10 * time.Minute,
+			AttemptTimeout:  10 * time.Minute,
 		},
 	}
 }
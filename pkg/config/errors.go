@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationErrors is returned by Validate and lists every violation found,
+// each message naming the offending YAML field. errors.Is and errors.As see
+// through to the individual errors.
+type ValidationErrors []error
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d problems: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the individual violations.
+func (e ValidationErrors) Unwrap() []error {
+	return e
+}
+
+func (e *ValidationErrors) addf(format string, args ...any) {
+	*e = append(*e, fmt.Errorf(format, args...))
+}
+
+// orNil keeps Validate returning a nil error interface when nothing failed.
+func (e ValidationErrors) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
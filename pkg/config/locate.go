@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// SearchPaths returns the locations LocateAndLoad tries, in order, when no
+// explicit path is given:
+//
+//  1. ./lumen.yaml
+//  2. $XDG_CONFIG_HOME/lumen/config.yaml (default ~/.config/lumen/config.yaml)
+//  3. /etc/lumen/config.yaml, or %APPDATA%\lumen\config.yaml on Windows
+//
+// Locations whose base directory cannot be determined are left out.
+func SearchPaths() []string {
+	paths := []string{"lumen.yaml"}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "lumen", "config.yaml"))
+	} else if home, err := os.UserHomeDir(); err == nil && runtime.GOOS != "windows" {
+		paths = append(paths, filepath.Join(home, ".config", "lumen", "config.yaml"))
+	}
+
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("APPDATA"); dir != "" {
+			paths = append(paths, filepath.Join(dir, "lumen", "config.yaml"))
+		}
+	} else {
+		paths = append(paths, filepath.Join("/etc", "lumen", "config.yaml"))
+	}
+	return paths
+}
+
+// Locate resolves the config file to load. A non-empty explicitPath is
+// returned as-is and must exist; otherwise the first existing entry of
+// SearchPaths is returned, or "" when there is none.
+func Locate(explicitPath string) (string, error) {
+	if explicitPath != "" {
+		if _, err := os.Stat(explicitPath); err != nil {
+			return "", fmt.Errorf("config %s: %w", explicitPath, err)
+		}
+		return explicitPath, nil
+	}
+	for _, path := range SearchPaths() {
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && !info.IsDir():
+			return path, nil
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			return "", fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+	return "", nil
+}
+
+// LocateAndLoad finds the config file with Locate and loads it with
+// LoadConfig. It returns the path that was loaded, which is "" when no file
+// was found and the defaults (plus environment overrides) are in effect.
+func LocateAndLoad(explicitPath string) (*Config, string, error) {
+	path, err := Locate(explicitPath)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, path, err
+	}
+	return cfg, path, nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Preset names mirror the annotated YAML files under examples/configs.
+const (
+	PresetBasic       = "basic"
+	PresetBrave       = "brave"
+	PresetLightweight = "lightweight"
+	PresetMinimal     = "minimal"
+)
+
+var presets = map[string]func(*Config){
+	// basic is DefaultConfig unchanged: a typical personal computer.
+	PresetBasic: func(*Config) {},
+	// brave favours discovery latency on fast, well-connected networks.
+	PresetBrave: func(c *Config) {
+		c.Discovery.ResolveTimeout = 5 * time.Second
+		c.Discovery.ConnectTimeout = 5 * time.Second
+		c.Discovery.RediscoveryBackoffMin = 5 * time.Second
+		c.Discovery.RediscoveryBackoffMax = time.Minute
+		c.Discovery.ScanInterval = 15 * time.Second
+		c.Chunk.Threshold = 4 << 20
+		c.Chunk.MaxChunkBytes = 1 << 20
+		c.Pool.HealthInterval = 15 * time.Second
+	},
+	// lightweight trades discovery latency for lower background CPU.
+	PresetLightweight: func(c *Config) {
+		c.Discovery.ResolveTimeout = 15 * time.Second
+		c.Discovery.RediscoveryBackoffMin = 15 * time.Second
+		c.Discovery.RediscoveryBackoffMax = 3 * time.Minute
+		c.Discovery.ScanInterval = 45 * time.Second
+		c.Pool.MaxConnections = 8
+		c.Pool.MaxIdleTime = 10 * time.Minute
+		c.Pool.HealthInterval = time.Minute
+	},
+	// minimal targets constrained devices such as a Raspberry Pi.
+	PresetMinimal: func(c *Config) {
+		c.Discovery.ResolveTimeout = 20 * time.Second
+		c.Discovery.ConnectTimeout = 15 * time.Second
+		c.Discovery.RediscoveryBackoffMin = 30 * time.Second
+		c.Discovery.RediscoveryBackoffMax = 5 * time.Minute
+		c.Discovery.ScanInterval = time.Minute
+		c.Discovery.MaxConcurrentProbes = 2
+		c.Broker.Port = 8081
+		c.Logging.Level = "warn"
+		c.Logging.Format = "text"
+		c.Chunk.Threshold = 256 * 1024
+		c.Chunk.MaxChunkBytes = 64 * 1024
+		c.Pool.MaxConnections = 3
+		c.Pool.MaxIdleTime = 5 * time.Minute
+		c.Pool.HealthInterval = 2 * time.Minute
+	},
+}
+
+// PresetConfig returns a fresh configuration for the named preset.
+// Names are case-insensitive; an empty name selects the basic preset.
+func PresetConfig(name string) (*Config, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = PresetBasic
+	}
+	apply, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(PresetNames(), ", "))
+	}
+	cfg := DefaultConfig()
+	apply(cfg)
+	return cfg, nil
+}
+
+// PresetNames returns the names of all built-in presets in sorted order.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
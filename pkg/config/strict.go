@@ -0,0 +1,255 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeStrict decodes the YAML in data over cfg. Unlike yaml.Unmarshal it
+// rejects keys that match no field, so a typo such as load_balencer fails
+// instead of being dropped. Every unknown key and every value that does not
+// parse as its field's type is reported, each naming its line, its dotted
+// YAML path and the offending value.
+func decodeStrict(data []byte, cfg *Config) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	var errs ValidationErrors
+	checkNode(&errs, doc.Content[0], reflect.TypeOf(cfg).Elem(), "")
+	if err := errs.orNil(); err != nil {
+		return err
+	}
+
+	// checkNode found nothing; KnownFields backs it up for anything it
+	// does not model.
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+var (
+	durationType    = reflect.TypeOf(time.Duration(0))
+	unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+)
+
+// checkNode reports every problem decoding n into a value of type t would
+// hit. path is n's dotted YAML path.
+func checkNode(errs *ValidationErrors, n *yaml.Node, t reflect.Type, path string) {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.ShortTag() == "!!null" {
+		return
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		if err := n.Decode(reflect.New(t).Interface()); err != nil {
+			problem(errs, n.Line, path, "%v", err)
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		checkNode(errs, n, t.Elem(), path)
+	case reflect.Interface:
+		// Anything decodes into an interface.
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			problem(errs, n.Line, path, "expected a mapping of settings, got %s", describeNode(n))
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Value == "<<" {
+				checkMerge(errs, value, t, path)
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				problem(errs, key.Line, joinPath(path, key.Value), "unknown key%s", suggestKey(key.Value, fields))
+				continue
+			}
+			checkNode(errs, value, field, joinPath(path, key.Value))
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			problem(errs, n.Line, path, "expected a mapping, got %s", describeNode(n))
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			checkNode(errs, key, t.Key(), path)
+			checkNode(errs, value, t.Elem(), joinPath(path, key.Value))
+		}
+	case reflect.Slice, reflect.Array:
+		if n.Kind != yaml.SequenceNode {
+			problem(errs, n.Line, path, "expected a list, got %s", describeNode(n))
+			return
+		}
+		for i, item := range n.Content {
+			checkNode(errs, item, t.Elem(), path+"["+strconv.Itoa(i)+"]")
+		}
+	default:
+		if n.Kind != yaml.ScalarNode {
+			problem(errs, n.Line, path, "expected %s, got %s", scalarName(t), describeNode(n))
+			return
+		}
+		if err := n.Decode(reflect.New(t).Interface()); err != nil {
+			problem(errs, n.Line, path, "invalid %s %q%s", scalarName(t), n.Value, scalarHint(t, n.Value))
+		}
+	}
+}
+
+// checkMerge checks the value of a "<<" merge key, a mapping or a list of
+// mappings whose keys merge into the enclosing one.
+func checkMerge(errs *ValidationErrors, n *yaml.Node, t reflect.Type, path string) {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind == yaml.SequenceNode {
+		for _, item := range n.Content {
+			checkNode(errs, item, t, path)
+		}
+		return
+	}
+	checkNode(errs, n, t, path)
+}
+
+// yamlFields maps the YAML keys of struct type t to their field types,
+// following yaml.v3's rules: the tag name, else the lowercased field name,
+// with ",inline" fields contributing their own keys.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, v := range yamlFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// suggestKey names the known key closest to an unknown one, if any is
+// within two edits of it.
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return " (did you mean " + best + "?)"
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func scalarName(t reflect.Type) string {
+	if t == durationType {
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return t.Kind().String()
+}
+
+// scalarHint suggests the expected form of a value that failed to parse.
+func scalarHint(t reflect.Type, value string) string {
+	if t == durationType {
+		return " (want a number with a unit, e.g. 500ms, 30s or 5m)"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return " (want true or false)"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value != "" && value[0] >= '0' && value[0] <= '9' {
+			return " (sizes and counts are plain integers, e.g. 1048576)"
+		}
+	}
+	return ""
+}
+
+func describeNode(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return strconv.Quote(n.Value)
+}
+
+// problem records a violation at line of the node at path.
+func problem(errs *ValidationErrors, line int, path, format string, args ...any) {
+	if path == "" {
+		path = "top level"
+	}
+	errs.addf("line %d: %s: %s", line, path, fmt.Sprintf(format, args...))
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Transport modes for TransportConfig.Mode.
+const (
+	TransportInsecure = "insecure"
+	TransportTLS      = "tls"
+)
+
+// TransportConfig secures the gRPC connections to nodes.
+type TransportConfig struct {
+	// Mode is "insecure" (plaintext) or "tls"; empty means insecure. In
+	// pool.tls the other fields still apply with insecure, to nodes that
+	// advertise tls=required.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+	// CAFile is a PEM bundle of roots the node's certificate must chain to;
+	// empty uses the system roots.
+	CAFile string `yaml:"ca_file,omitempty" json:"ca_file,omitempty"`
+	// CertFile and KeyFile are the client certificate presented to nodes
+	// that require mutual TLS. Set both or neither.
+	CertFile string `yaml:"cert_file,omitempty" json:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty" json:"key_file,omitempty"`
+	// ServerName is the identity the node's certificate must carry: a DNS
+	// SAN, or a SPIFFE ID ("spiffe://trust-domain/path") matched against its
+	// URI SANs. Empty verifies the host the node was discovered at.
+	ServerName string `yaml:"server_name,omitempty" json:"server_name,omitempty"`
+}
+
+// IsTLS reports whether connections using t are encrypted.
+func (t TransportConfig) IsTLS() bool {
+	return t.Mode == TransportTLS
+}
+
+// SPIFFEID reports whether ServerName is a SPIFFE ID rather than a DNS name.
+func (t TransportConfig) SPIFFEID() bool {
+	return strings.HasPrefix(t.ServerName, "spiffe://")
+}
+
+// NodeTransport returns the transport for a node known by any of ids (its
+// advertised ID and its deployment-qualified ID). An exact key in PerNode
+// wins over a pattern; among matching patterns the longest, then the
+// lexically smallest, wins. Nodes no entry matches use p.TLS; matched
+// reports whether an entry applied.
+func (p PoolConfig) NodeTransport(ids ...string) (t TransportConfig, pattern string, matched bool) {
+	for _, id := range ids {
+		if t, ok := p.PerNode[id]; ok {
+			return t, id, true
+		}
+	}
+	for _, pattern := range perNodePatterns(p.PerNode) {
+		for _, id := range ids {
+			if ok, _ := path.Match(pattern, id); ok {
+				return p.PerNode[pattern], pattern, true
+			}
+		}
+	}
+	return p.TLS, "", false
+}
+
+// perNodePatterns orders PerNode keys in match precedence.
+func perNodePatterns(perNode map[string]TransportConfig) []string {
+	patterns := make([]string, 0, len(perNode))
+	for pattern := range perNode {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	return patterns
+}
+
+// validateTransport reports every problem with t, prefixing each message
+// with field, the YAML path t was read from. Per-node entries that are
+// insecure never use their TLS fields, so setting them is an error there.
+func validateTransport(errs *ValidationErrors, field string, t TransportConfig, perNode bool) {
+	switch t.Mode {
+	case "", TransportInsecure:
+		if perNode && (t.CAFile != "" || t.CertFile != "" || t.KeyFile != "" || t.ServerName != "") {
+			errs.addf("%s: ca_file, cert_file, key_file and server_name require mode tls", field)
+			return
+		}
+	case TransportTLS:
+	default:
+		errs.addf("%s.mode %q must be insecure or tls", field, t.Mode)
+		return
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		errs.addf("%s: cert_file and key_file must be set together", field)
+	}
+	for _, f := range []struct{ name, file string }{{"ca_file", t.CAFile}, {"cert_file", t.CertFile}, {"key_file", t.KeyFile}} {
+		if f.file == "" {
+			continue
+		}
+		if _, err := os.Stat(f.file); err != nil {
+			errs.addf("%s.%s: %w", field, f.name, err)
+		}
+	}
+	if t.SPIFFEID() {
+		u, err := url.Parse(t.ServerName)
+		if err != nil || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+			errs.addf("%s.server_name %q is not a valid SPIFFE ID (spiffe://trust-domain/path)", field, t.ServerName)
+		}
+	}
+}
+
+func validatePerNode(errs *ValidationErrors, perNode map[string]TransportConfig) {
+	for _, pattern := range perNodePatterns(perNode) {
+		field := fmt.Sprintf("pool.per_node[%q]", pattern)
+		if strings.TrimSpace(pattern) == "" {
+			errs.addf("%s: pattern must not be empty", field)
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs.addf("%s: invalid pattern: %w", field, err)
+			continue
+		}
+		validateTransport(errs, field, perNode[pattern], true)
+	}
+}
@@ -98,6 +98,19 @@ func (r *BrokerResolver) connect(ctx context.Context, wsURL string, ch chan<- No
 		return conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(5*time.Second))
 	})
 
+	// A pong proves the broker is still feeding this connection, so every
+	// broker node it last reported is current.
+	conn.SetPongHandler(func(string) error {
+		select {
+		case ch <- NodeEvent{Type: NodeRefreshed, Source: SourceBroker}:
+		case <-ctx.Done():
+		}
+		return nil
+	})
+	pingCtx, stopPing := context.WithCancel(ctx)
+	defer stopPing()
+	go r.pingLoop(pingCtx, conn)
+
 	// Read pump.
 	for {
 		_, raw, err := conn.ReadMessage()
@@ -120,3 +133,23 @@ func (r *BrokerResolver) connect(ctx context.Context, wsURL string, ch chan<- No
 		}
 	}
 }
+
+// brokerPingInterval is how often the resolver pings the broker; each pong
+// refreshes the broker's nodes in the catalog.
+const brokerPingInterval = 30 * time.Second
+
+func (r *BrokerResolver) pingLoop(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(brokerPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+				r.logger.Debug("broker ping failed", zap.Error(err))
+				return
+			}
+		}
+	}
+}
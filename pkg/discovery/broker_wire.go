@@ -3,9 +3,9 @@ package discovery
 import (
 	"encoding/json"
 	"fmt"
-	"net"
-	"strconv"
 	"strings"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils/netaddr"
 )
 
 // brokerNodeEvent is the wire JSON format received from the Broker WebSocket.
@@ -83,6 +83,7 @@ func nodeEventFromBroker(eventType NodeEventType, n brokerNode, defaultDeploymen
 		Txt:          txt,
 	}.Normalized()
 	ev := eventFromResolved(eventType, resolved)
+	ev.Source = SourceBroker
 	ev.ExplicitRemove = explicitRemove
 	return ev
 }
@@ -91,12 +92,11 @@ func brokerAddresses(n brokerNode) ([]string, int) {
 	port := n.Port
 	addresses := append([]string(nil), n.Addresses...)
 	if n.Address != "" {
-		host, parsedPort, err := splitHostPort(n.Address)
-		if err == nil {
+		if addr, err := netaddr.ParseHostPort(n.Address, 0); err == nil {
 			if port == 0 {
-				port = parsedPort
+				port = addr.Port
 			}
-			addresses = append(addresses, host)
+			addresses = append(addresses, addr.Host)
 		} else {
 			addresses = append(addresses, n.Address)
 		}
@@ -104,18 +104,6 @@ func brokerAddresses(n brokerNode) ([]string, int) {
 	return addresses, port
 }
 
-func splitHostPort(address string) (string, int, error) {
-	host, portString, err := net.SplitHostPort(address)
-	if err != nil {
-		return "", 0, err
-	}
-	port, err := strconv.Atoi(portString)
-	if err != nil {
-		return "", 0, err
-	}
-	return host, port, nil
-}
-
 func joinCSV(values []string) string {
 	out := make([]string, 0, len(values))
 	seen := make(map[string]struct{}, len(values))
@@ -0,0 +1,147 @@
+package discovery
+
+import (
+	"sort"
+	"time"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// CapabilityDiff describes how a node's advertised capabilities changed
+// between two successful capability fetches.
+type CapabilityDiff struct {
+	NodeID        string    `json:"node_id"`
+	At            time.Time `json:"at"`
+	TasksAdded    []string  `json:"tasks_added,omitempty"`
+	TasksRemoved  []string  `json:"tasks_removed,omitempty"`
+	ModelsAdded   []string  `json:"models_added,omitempty"`
+	ModelsRemoved []string  `json:"models_removed,omitempty"`
+	// ServicesAdded and ServicesRemoved list services that appeared or
+	// disappeared entirely; their runtime and concurrency are not repeated
+	// in the change lists below.
+	ServicesAdded      []string            `json:"services_added,omitempty"`
+	ServicesRemoved    []string            `json:"services_removed,omitempty"`
+	RuntimeChanges     []RuntimeChange     `json:"runtime_changes,omitempty"`
+	ConcurrencyChanges []ConcurrencyChange `json:"concurrency_changes,omitempty"`
+}
+
+// RuntimeChange records a service whose runtime changed.
+type RuntimeChange struct {
+	Service string `json:"service"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// ConcurrencyChange records a service whose suggested max concurrency changed.
+type ConcurrencyChange struct {
+	Service string `json:"service"`
+	Old     uint32 `json:"old"`
+	New     uint32 `json:"new"`
+}
+
+// Empty reports whether the diff records no change.
+func (d *CapabilityDiff) Empty() bool {
+	return d == nil || (len(d.TasksAdded) == 0 && len(d.TasksRemoved) == 0 &&
+		len(d.ModelsAdded) == 0 && len(d.ModelsRemoved) == 0 &&
+		len(d.ServicesAdded) == 0 && len(d.ServicesRemoved) == 0 &&
+		len(d.RuntimeChanges) == 0 && len(d.ConcurrencyChanges) == 0)
+}
+
+// DiffCapabilities compares two capability sets of the same node. Services
+// are matched by name; all lists are sorted. NodeID and At are left for the
+// caller to fill in.
+func DiffCapabilities(old, new []*pb.Capability) CapabilityDiff {
+	var diff CapabilityDiff
+	diff.TasksAdded, diff.TasksRemoved = setDiff(capabilityTasks(old), capabilityTasks(new))
+	diff.ModelsAdded, diff.ModelsRemoved = setDiff(capabilityModels(old), capabilityModels(new))
+
+	oldSvc, newSvc := capabilitiesByService(old), capabilitiesByService(new)
+	diff.ServicesAdded, diff.ServicesRemoved = setDiff(keySet(oldSvc), keySet(newSvc))
+	for _, name := range sortedKeys(newSvc) {
+		before, ok := oldSvc[name]
+		if !ok {
+			continue
+		}
+		after := newSvc[name]
+		if before.GetRuntime() != after.GetRuntime() {
+			diff.RuntimeChanges = append(diff.RuntimeChanges, RuntimeChange{
+				Service: name, Old: before.GetRuntime(), New: after.GetRuntime(),
+			})
+		}
+		if before.GetMaxConcurrency() != after.GetMaxConcurrency() {
+			diff.ConcurrencyChanges = append(diff.ConcurrencyChanges, ConcurrencyChange{
+				Service: name, Old: before.GetMaxConcurrency(), New: after.GetMaxConcurrency(),
+			})
+		}
+	}
+	return diff
+}
+
+func capabilityTasks(caps []*pb.Capability) map[string]struct{} {
+	out := make(map[string]struct{})
+	for _, cap := range caps {
+		for _, task := range cap.GetTasks() {
+			if task.GetName() != "" {
+				out[task.GetName()] = struct{}{}
+			}
+		}
+	}
+	return out
+}
+
+func capabilityModels(caps []*pb.Capability) map[string]struct{} {
+	out := make(map[string]struct{})
+	for _, cap := range caps {
+		for _, id := range cap.GetModelIds() {
+			if id != "" {
+				out[id] = struct{}{}
+			}
+		}
+	}
+	return out
+}
+
+func capabilitiesByService(caps []*pb.Capability) map[string]*pb.Capability {
+	out := make(map[string]*pb.Capability)
+	for _, cap := range caps {
+		if cap != nil {
+			out[cap.GetServiceName()] = cap
+		}
+	}
+	return out
+}
+
+func keySet[V any](m map[string]V) map[string]struct{} {
+	out := make(map[string]struct{}, len(m))
+	for k := range m {
+		out[k] = struct{}{}
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// setDiff returns the sorted members only in new (added) and only in old
+// (removed).
+func setDiff(old, new map[string]struct{}) (added, removed []string) {
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			added = append(added, k)
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
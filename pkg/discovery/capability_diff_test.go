@@ -0,0 +1,38 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+func TestDiffCapabilitiesServices(t *testing.T) {
+	old := []*pb.Capability{
+		{ServiceName: "clip", ModelIds: []string{"clip-b32"}, Tasks: []*pb.IOTask{{Name: "embed"}}},
+		{ServiceName: "ocr", ModelIds: []string{"ppocr"}, Tasks: []*pb.IOTask{{Name: "ocr"}}},
+	}
+	new := []*pb.Capability{
+		{ServiceName: "clip", ModelIds: []string{"clip-b32"}, Tasks: []*pb.IOTask{{Name: "embed"}}},
+		{ServiceName: "face", ModelIds: []string{"scrfd"}, Runtime: "cpu", Tasks: []*pb.IOTask{{Name: "face"}}},
+	}
+
+	got := DiffCapabilities(old, new)
+	want := CapabilityDiff{
+		TasksAdded:      []string{"face"},
+		TasksRemoved:    []string{"ocr"},
+		ModelsAdded:     []string{"scrfd"},
+		ModelsRemoved:   []string{"ppocr"},
+		ServicesAdded:   []string{"face"},
+		ServicesRemoved: []string{"ocr"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diff = %+v\nwant %+v", got, want)
+	}
+	if got.Empty() {
+		t.Fatal("diff reported empty")
+	}
+	if d := DiffCapabilities(old, old); !d.Empty() {
+		t.Fatalf("self diff = %+v, want empty", d)
+	}
+}
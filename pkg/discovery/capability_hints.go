@@ -0,0 +1,134 @@
+package discovery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TXT keys under which a node may advertise its capabilities, so the pool
+// can route to it before its GetCapabilities answer arrives.
+const (
+	// TasksTxtKey lists the tasks the node serves, comma-separated; "\,"
+	// and "\\" escape a comma and a backslash within a name.
+	TasksTxtKey = "tasks"
+	// RuntimeTxtKey names the node's runtime, e.g. "cuda".
+	RuntimeTxtKey = "runtime"
+	// MaxConcurrencyTxtKey is how many requests the node serves at once.
+	MaxConcurrencyTxtKey = "max_concurrency"
+)
+
+// MaxTXTStringLen is the longest string, "key=value" included, a DNS TXT
+// record holds (RFC 6763 §6.1).
+const MaxTXTStringLen = 255
+
+// CapabilityHints are the capabilities a node advertises in TXT records. They
+// are provisional: GetCapabilities confirms or corrects them.
+type CapabilityHints struct {
+	Tasks          []string `json:"tasks,omitempty"`
+	Runtime        string   `json:"runtime,omitempty"`
+	MaxConcurrency int      `json:"max_concurrency,omitempty"`
+	// Problems describe malformed hints that were ignored.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Empty reports whether the hints name no task, so they cannot route.
+func (h CapabilityHints) Empty() bool {
+	return len(h.Tasks) == 0
+}
+
+// ParseCapabilityHints reads the capability hints in a node's TXT records.
+// Malformed hints are skipped and described in Problems rather than failing
+// the whole set.
+func ParseCapabilityHints(txt map[string]string) CapabilityHints {
+	var h CapabilityHints
+	if raw, ok := txt[TasksTxtKey]; ok {
+		names, err := splitEscapedList(raw)
+		if err != nil {
+			h.Problems = append(h.Problems, fmt.Sprintf("%s: %v", TasksTxtKey, err))
+		}
+		seen := make(map[string]bool, len(names))
+		for _, name := range names {
+			switch {
+			case name == "" || seen[name]:
+			case !printableTaskName(name):
+				h.Problems = append(h.Problems, fmt.Sprintf("%s: invalid task name %q", TasksTxtKey, name))
+			default:
+				seen[name] = true
+				h.Tasks = append(h.Tasks, name)
+			}
+		}
+	}
+	h.Runtime = strings.TrimSpace(txt[RuntimeTxtKey])
+	if raw := strings.TrimSpace(txt[MaxConcurrencyTxtKey]); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			h.Problems = append(h.Problems, fmt.Sprintf("%s: %q is not a positive integer", MaxConcurrencyTxtKey, raw))
+		} else {
+			h.MaxConcurrency = n
+		}
+	}
+	return h
+}
+
+// dropCutOffEntry drops the last entry of a list that filled a whole TXT
+// string, record being the "key=value" string: the advertiser may have cut it
+// off to fit, and a cut-off task name would name a task the node does not
+// serve. It reports whether it dropped one.
+func dropCutOffEntry(record, list string) (string, bool) {
+	if len(record) < MaxTXTStringLen || strings.HasSuffix(list, ",") {
+		return list, false
+	}
+	// The last separator is the last comma not escaped by an odd run of
+	// backslashes.
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i] != ',' {
+			continue
+		}
+		backslashes := 0
+		for j := i - 1; j >= 0 && list[j] == '\\'; j-- {
+			backslashes++
+		}
+		if backslashes%2 == 0 {
+			return list[:i], true
+		}
+	}
+	return "", true
+}
+
+// splitEscapedList splits a comma-separated list in which a backslash
+// escapes the next character, trimming each entry. A trailing lone
+// backslash is an error; the entries before it are still returned.
+func splitEscapedList(raw string) ([]string, error) {
+	var out []string
+	var cur strings.Builder
+	escaped := false
+	for _, r := range raw {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			out = append(out, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if escaped {
+		return out, fmt.Errorf("dangling escape at the end of %q", raw)
+	}
+	return append(out, strings.TrimSpace(cur.String())), nil
+}
+
+func printableTaskName(name string) bool {
+	for _, r := range name {
+		if !unicode.IsPrint(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCapabilityHints(t *testing.T) {
+	tests := []struct {
+		name         string
+		txt          map[string]string
+		tasks        []string
+		runtime      string
+		concurrency  int
+		wantProblems int
+	}{
+		{
+			name:        "full set",
+			txt:         map[string]string{"tasks": "clip_text_embed, face_detect", "runtime": "cuda", "max_concurrency": "8"},
+			tasks:       []string{"clip_text_embed", "face_detect"},
+			runtime:     "cuda",
+			concurrency: 8,
+		},
+		{
+			name:  "escaped comma and backslash",
+			txt:   map[string]string{"tasks": `odd\,name,back\\slash,plain`},
+			tasks: []string{"odd,name", `back\slash`, "plain"},
+		},
+		{
+			name:  "empty entries and duplicates",
+			txt:   map[string]string{"tasks": ",embed,,embed,ocr,"},
+			tasks: []string{"embed", "ocr"},
+		},
+		{
+			name:         "dangling escape",
+			txt:          map[string]string{"tasks": `embed,ocr\`},
+			tasks:        []string{"embed"},
+			wantProblems: 1,
+		},
+		{
+			name:         "unprintable and spaced names",
+			txt:          map[string]string{"tasks": "embed,face detect,ocr\x07"},
+			tasks:        []string{"embed"},
+			wantProblems: 2,
+		},
+		{
+			name:         "bad concurrency",
+			txt:          map[string]string{"tasks": "embed", "max_concurrency": "lots"},
+			tasks:        []string{"embed"},
+			wantProblems: 1,
+		},
+		{name: "nothing advertised", txt: map[string]string{"v": "1.2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := ParseCapabilityHints(tt.txt)
+			if !reflect.DeepEqual(h.Tasks, tt.tasks) {
+				t.Errorf("Tasks = %q, want %q", h.Tasks, tt.tasks)
+			}
+			if h.Runtime != tt.runtime || h.MaxConcurrency != tt.concurrency {
+				t.Errorf("runtime, concurrency = %q, %d, want %q, %d", h.Runtime, h.MaxConcurrency, tt.runtime, tt.concurrency)
+			}
+			if len(h.Problems) != tt.wantProblems {
+				t.Errorf("Problems = %q, want %d", h.Problems, tt.wantProblems)
+			}
+			if h.Empty() != (len(tt.tasks) == 0) {
+				t.Errorf("Empty() = %v", h.Empty())
+			}
+		})
+	}
+}
+
+// fullTasksRecord returns a "tasks=" TXT string of exactly MaxTXTStringLen
+// bytes whose last entry is cut off, like an advertiser truncating to fit.
+func fullTasksRecord(last string) string {
+	record := "tasks=" + last
+	for len(record) < MaxTXTStringLen {
+		record += "x"
+	}
+	return record[:MaxTXTStringLen]
+}
+
+func TestParseTXTKeysAndLengthLimit(t *testing.T) {
+	txt, truncated := parseTXT([]string{"Tasks=embed", "tasks=ocr", "RUNTIME=cuda", "=orphan", "flag"})
+	if truncated || txt["tasks"] != "embed" || txt["runtime"] != "cuda" || len(txt) != 3 {
+		t.Fatalf("txt = %v, truncated = %v: want lower-cased keys with the first value winning", txt, truncated)
+	}
+
+	long := strings.Repeat("task_name_", 20) // 200 bytes, one entry
+	record := fullTasksRecord("embed," + long + ",face_detect_with_a_long_na")
+	txt, truncated = parseTXT([]string{record})
+	if !truncated || txt["tasks"] != "embed,"+long {
+		t.Fatalf("full record: tasks = %q, truncated = %v: want its cut-off last entry dropped", txt["tasks"], truncated)
+	}
+
+	escaped := fullTasksRecord(`embed,` + long + `,a\,b`)
+	txt, _ = parseTXT([]string{escaped})
+	if got := ParseCapabilityHints(txt).Tasks; !reflect.DeepEqual(got, []string{"embed", long}) {
+		t.Fatalf("escaped comma in the cut-off entry: tasks = %q", got)
+	}
+
+	short := "tasks=embed,ocr"
+	if txt, truncated = parseTXT([]string{short}); truncated || txt["tasks"] != "embed,ocr" {
+		t.Fatalf("short record: tasks = %q, truncated = %v", txt["tasks"], truncated)
+	}
+	closed := fullTasksRecord("")[:MaxTXTStringLen-1] + ","
+	if _, truncated = parseTXT([]string{closed}); truncated {
+		t.Fatal("a full record ending in a separator was treated as cut off")
+	}
+}
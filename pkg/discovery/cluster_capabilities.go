@@ -0,0 +1,150 @@
+package discovery
+
+import (
+	"sort"
+	"strings"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// CapabilityFilter restricts AggregateCapabilities to matching services.
+// Empty fields match everything.
+type CapabilityFilter struct {
+	// Runtime matches a service whose runtime contains it, ignoring case, so
+	// "cuda" matches "onnxrt-cuda".
+	Runtime string `json:"runtime,omitempty"`
+	// Precision matches a service advertising it among its precisions,
+	// ignoring case.
+	Precision string `json:"precision,omitempty"`
+	// ModelID matches a service serving exactly this model.
+	ModelID string `json:"model_id,omitempty"`
+}
+
+// Matches reports whether a service capability passes the filter.
+func (f CapabilityFilter) Matches(cap *pb.Capability) bool {
+	if cap == nil {
+		return false
+	}
+	if f.Runtime != "" && !strings.Contains(strings.ToLower(cap.GetRuntime()), strings.ToLower(f.Runtime)) {
+		return false
+	}
+	if f.Precision != "" && !containsFold(cap.GetPrecisions(), f.Precision) {
+		return false
+	}
+	if f.ModelID != "" && !contains(cap.GetModelIds(), f.ModelID) {
+		return false
+	}
+	return true
+}
+
+// TaskCapability is the cluster-wide view of one task. All lists are sorted
+// and de-duplicated.
+type TaskCapability struct {
+	Task       string   `json:"task"`
+	Nodes      []string `json:"nodes"`
+	Services   []string `json:"services"`
+	Runtimes   []string `json:"runtimes,omitempty"`
+	Precisions []string `json:"precisions,omitempty"`
+	ModelIDs   []string `json:"model_ids,omitempty"`
+	// MaxConcurrency sums the suggested max concurrency of every serving
+	// (node, service) pair.
+	MaxConcurrency uint32 `json:"max_concurrency"`
+}
+
+// ClusterCapabilities maps task names to what the active nodes offer for
+// them.
+type ClusterCapabilities struct {
+	Tasks map[string]*TaskCapability `json:"tasks"`
+}
+
+// TaskNames returns the aggregated task names in sorted order.
+func (c ClusterCapabilities) TaskNames() []string {
+	names := make([]string, 0, len(c.Tasks))
+	for name := range c.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AggregateCapabilities merges the capabilities of the active nodes by task,
+// keeping only services that pass filter.
+func AggregateCapabilities(nodes []*NodeInfo, filter CapabilityFilter) ClusterCapabilities {
+	type taskSets struct {
+		nodes, services, runtimes, precisions, models map[string]struct{}
+		concurrency                                   uint32
+	}
+	sets := make(map[string]*taskSets)
+
+	for _, node := range nodes {
+		if node == nil || !node.IsActive() {
+			continue
+		}
+		for _, cap := range node.Capabilities {
+			if !filter.Matches(cap) {
+				continue
+			}
+			for _, task := range cap.GetTasks() {
+				name := task.GetName()
+				if name == "" {
+					continue
+				}
+				s, ok := sets[name]
+				if !ok {
+					s = &taskSets{
+						nodes: map[string]struct{}{}, services: map[string]struct{}{},
+						runtimes: map[string]struct{}{}, precisions: map[string]struct{}{},
+						models: map[string]struct{}{},
+					}
+					sets[name] = s
+				}
+				s.nodes[node.ID] = struct{}{}
+				s.services[cap.GetServiceName()] = struct{}{}
+				addNonEmpty(s.runtimes, cap.GetRuntime())
+				addNonEmpty(s.precisions, cap.GetPrecisions()...)
+				addNonEmpty(s.models, cap.GetModelIds()...)
+				s.concurrency += cap.GetMaxConcurrency()
+			}
+		}
+	}
+
+	out := ClusterCapabilities{Tasks: make(map[string]*TaskCapability, len(sets))}
+	for name, s := range sets {
+		out.Tasks[name] = &TaskCapability{
+			Task:           name,
+			Nodes:          sortedKeys(s.nodes),
+			Services:       sortedKeys(s.services),
+			Runtimes:       sortedKeys(s.runtimes),
+			Precisions:     sortedKeys(s.precisions),
+			ModelIDs:       sortedKeys(s.models),
+			MaxConcurrency: s.concurrency,
+		}
+	}
+	return out
+}
+
+func addNonEmpty(set map[string]struct{}, values ...string) {
+	for _, v := range values {
+		if v != "" {
+			set[v] = struct{}{}
+		}
+	}
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, want string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, want) {
+			return true
+		}
+	}
+	return false
+}
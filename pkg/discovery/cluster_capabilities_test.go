@@ -0,0 +1,95 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+func capNode(id string, status NodeStatus, caps ...*pb.Capability) *NodeInfo {
+	return &NodeInfo{ID: id, Status: status, Capabilities: caps}
+}
+
+func ioTasks(names ...string) []*pb.IOTask {
+	out := make([]*pb.IOTask, 0, len(names))
+	for _, n := range names {
+		out = append(out, &pb.IOTask{Name: n})
+	}
+	return out
+}
+
+func heterogeneousCluster() []*NodeInfo {
+	return []*NodeInfo{
+		capNode("cpu-1", NodeStatusActive, &pb.Capability{
+			ServiceName: "clip", Runtime: "onnxrt-cpu", Precisions: []string{"fp32"},
+			ModelIds: []string{"clip-b32"}, MaxConcurrency: 2, Tasks: ioTasks("embed"),
+		}),
+		capNode("gpu-1", NodeStatusActive,
+			&pb.Capability{
+				ServiceName: "clip", Runtime: "onnxrt-cuda", Precisions: []string{"fp16", "fp32"},
+				ModelIds: []string{"clip-l14"}, MaxConcurrency: 8, Tasks: ioTasks("embed"),
+			},
+			&pb.Capability{
+				ServiceName: "ocr", Runtime: "onnxrt-cuda", Precisions: []string{"FP16"},
+				ModelIds: []string{"ppocr-v4"}, MaxConcurrency: 4, Tasks: ioTasks("ocr"),
+			},
+		),
+		// Not active: ignored even though it advertises a task.
+		capNode("gpu-down", NodeStatusError, &pb.Capability{
+			ServiceName: "face", Runtime: "onnxrt-cuda", Tasks: ioTasks("face"),
+		}),
+	}
+}
+
+func TestAggregateCapabilitiesMergesByTask(t *testing.T) {
+	got := AggregateCapabilities(heterogeneousCluster(), CapabilityFilter{})
+
+	if names := got.TaskNames(); !reflect.DeepEqual(names, []string{"embed", "ocr"}) {
+		t.Fatalf("tasks = %v, want [embed ocr]", names)
+	}
+	want := &TaskCapability{
+		Task:           "embed",
+		Nodes:          []string{"cpu-1", "gpu-1"},
+		Services:       []string{"clip"},
+		Runtimes:       []string{"onnxrt-cpu", "onnxrt-cuda"},
+		Precisions:     []string{"fp16", "fp32"},
+		ModelIDs:       []string{"clip-b32", "clip-l14"},
+		MaxConcurrency: 10,
+	}
+	if !reflect.DeepEqual(got.Tasks["embed"], want) {
+		t.Fatalf("embed = %+v\nwant %+v", got.Tasks["embed"], want)
+	}
+}
+
+func TestAggregateCapabilitiesFilters(t *testing.T) {
+	nodes := heterogeneousCluster()
+	tests := []struct {
+		name      string
+		filter    CapabilityFilter
+		wantTasks []string
+		wantEmbed []string // serving nodes for embed, if present
+	}{
+		{name: "runtime", filter: CapabilityFilter{Runtime: "CUDA"}, wantTasks: []string{"embed", "ocr"}, wantEmbed: []string{"gpu-1"}},
+		{name: "runtime without match", filter: CapabilityFilter{Runtime: "coreml"}, wantTasks: []string{}},
+		{name: "precision ignores case", filter: CapabilityFilter{Precision: "fp16"}, wantTasks: []string{"embed", "ocr"}, wantEmbed: []string{"gpu-1"}},
+		{name: "precision shared", filter: CapabilityFilter{Precision: "fp32"}, wantTasks: []string{"embed"}, wantEmbed: []string{"cpu-1", "gpu-1"}},
+		{name: "model", filter: CapabilityFilter{ModelID: "clip-b32"}, wantTasks: []string{"embed"}, wantEmbed: []string{"cpu-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AggregateCapabilities(nodes, tt.filter)
+			if names := got.TaskNames(); !reflect.DeepEqual(names, tt.wantTasks) {
+				t.Fatalf("tasks = %v, want %v", names, tt.wantTasks)
+			}
+			if tt.wantEmbed != nil && !reflect.DeepEqual(got.Tasks["embed"].Nodes, tt.wantEmbed) {
+				t.Fatalf("embed nodes = %v, want %v", got.Tasks["embed"].Nodes, tt.wantEmbed)
+			}
+		})
+	}
+
+	gpu := AggregateCapabilities(nodes, CapabilityFilter{Runtime: "cuda"})
+	if c := gpu.Tasks["embed"].MaxConcurrency; c != 8 {
+		t.Fatalf("GPU embed concurrency = %d, want 8", c)
+	}
+}
@@ -2,20 +2,70 @@ package discovery
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Backoff between attempts to start a backend whose Watch failed. Variables
+// so tests can shorten them.
+var (
+	backendRetryMin = 1 * time.Second
+	backendRetryMax = 1 * time.Minute
 )
 
+// BackendFailure describes a discovery backend that failed to start and is
+// being retried in the background.
+type BackendFailure struct {
+	Backend   string    `json:"backend"`
+	Error     string    `json:"error"`
+	Since     time.Time `json:"since"`
+	Attempts  int       `json:"attempts"`
+	NextRetry time.Time `json:"next_retry"`
+}
+
+// DiscoveryStatus reports whether every configured discovery backend is
+// running. Discovery is degraded while any backend is down and the others
+// carry on without it.
+type DiscoveryStatus struct {
+	Degraded bool             `json:"degraded"`
+	Failed   []BackendFailure `json:"failed_backends,omitempty"`
+	// Catalog is the node catalog's freshness, when the reporter tracks it.
+	// A stale catalog does not set Degraded, which is about backends.
+	Catalog *CatalogFreshness `json:"catalog,omitempty"`
+}
+
+// StatusReporter is implemented by resolvers that can keep running with
+// some of their backends down.
+type StatusReporter interface {
+	Status() DiscoveryStatus
+}
+
 // CompositeResolver fans in events from multiple discovery backends so that
 // mDNS, Broker push, and static nodes can run side by side. Backends emit
 // nodes under distinct identities, so the downstream resolver/pool layers
 // handle merging naturally.
 type CompositeResolver struct {
 	resolvers []NodeResolver
+	logger    *zap.Logger
+
+	mu       sync.Mutex
+	failures map[int]*BackendFailure // keyed by index into resolvers
 }
 
 // NewCompositeResolver combines the given backends. Nil entries are dropped;
 // a single backend is returned as-is.
 func NewCompositeResolver(resolvers ...NodeResolver) NodeResolver {
+	return NewCompositeResolverWithLogger(nil, resolvers...)
+}
+
+// NewCompositeResolverWithLogger is NewCompositeResolver with a logger for
+// backends that fail to start.
+func NewCompositeResolverWithLogger(logger *zap.Logger, resolvers ...NodeResolver) NodeResolver {
 	out := make([]NodeResolver, 0, len(resolvers))
 	for _, r := range resolvers {
 		if r != nil {
@@ -25,35 +75,66 @@ func NewCompositeResolver(resolvers ...NodeResolver) NodeResolver {
 	if len(out) == 1 {
 		return out[0]
 	}
-	return &CompositeResolver{resolvers: out}
+	return &CompositeResolver{resolvers: out, logger: ensureLogger(logger)}
 }
 
-// Watch starts every backend and merges their event channels. It fails if any
-// backend fails to start; the merged channel closes when all backends close
-// (all backends stop on ctx cancellation).
+// Watch starts every backend and merges their event channels. A backend that
+// fails to start is logged, reported by Status and retried with backoff
+// while the others run; its events join the merged channel once it starts.
+// Watch fails only if every backend fails. The merged channel closes when
+// all backends close (all backends stop on ctx cancellation).
 func (c *CompositeResolver) Watch(ctx context.Context) (<-chan NodeEvent, error) {
-	channels := make([]<-chan NodeEvent, 0, len(c.resolvers))
-	for _, r := range c.resolvers {
-		ch, err := r.Watch(ctx)
-		if err != nil {
-			return nil, err
+	channels := make([]<-chan NodeEvent, len(c.resolvers))
+	startErrs := make([]error, len(c.resolvers))
+	var failed []error
+	for i, r := range c.resolvers {
+		channels[i], startErrs[i] = r.Watch(ctx)
+		if startErrs[i] != nil {
+			failed = append(failed, fmt.Errorf("%s discovery: %w", backendName(r), startErrs[i]))
 		}
-		channels = append(channels, ch)
+	}
+	if len(failed) == len(c.resolvers) {
+		return nil, errors.Join(failed...)
 	}
 
 	out := make(chan NodeEvent, 32)
+	forward := func(ch <-chan NodeEvent) {
+		for ev := range ch {
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
 	var wg sync.WaitGroup
-	wg.Add(len(channels))
-	for _, ch := range channels {
+	wg.Add(len(c.resolvers))
+	for i, ch := range channels {
+		if startErrs[i] != nil {
+			c.logger.Warn("discovery backend failed to start, continuing degraded without it",
+				zap.String("backend", backendName(c.resolvers[i])),
+				zap.Error(startErrs[i]),
+			)
+			failure := BackendFailure{
+				Backend:   backendName(c.resolvers[i]),
+				Error:     startErrs[i].Error(),
+				Since:     time.Now(),
+				Attempts:  1,
+				NextRetry: time.Now().Add(backendRetryMin),
+			}
+			c.setFailure(i, &failure)
+			go func(i int) {
+				defer wg.Done()
+				if ch := c.retry(ctx, i, failure); ch != nil {
+					forward(ch)
+				}
+			}(i)
+			continue
+		}
 		go func(ch <-chan NodeEvent) {
 			defer wg.Done()
-			for ev := range ch {
-				select {
-				case out <- ev:
-				case <-ctx.Done():
-					return
-				}
-			}
+			forward(ch)
 		}(ch)
 	}
 	go func() {
@@ -62,3 +143,81 @@ func (c *CompositeResolver) Watch(ctx context.Context) (<-chan NodeEvent, error)
 	}()
 	return out, nil
 }
+
+// retry restarts backend i, whose failure to start is recorded in failure,
+// with exponential backoff until it starts or ctx is cancelled. It returns
+// the backend's channel, or nil on cancellation.
+func (c *CompositeResolver) retry(ctx context.Context, i int, failure BackendFailure) <-chan NodeEvent {
+	r := c.resolvers[i]
+	backoff := backendRetryMin
+	for {
+		select {
+		case <-ctx.Done():
+			c.setFailure(i, nil)
+			return nil
+		case <-time.After(backoff):
+		}
+		ch, err := r.Watch(ctx)
+		if err == nil {
+			c.setFailure(i, nil)
+			c.logger.Info("discovery backend started, no longer degraded",
+				zap.String("backend", failure.Backend),
+				zap.Int("attempts", failure.Attempts+1),
+			)
+			return ch
+		}
+		c.logger.Debug("discovery backend still failing to start",
+			zap.String("backend", failure.Backend),
+			zap.Error(err),
+		)
+
+		backoff *= 2
+		if backoff > backendRetryMax {
+			backoff = backendRetryMax
+		}
+		failure.Error = err.Error()
+		failure.Attempts++
+		failure.NextRetry = time.Now().Add(backoff)
+		c.setFailure(i, &failure)
+	}
+}
+
+func (c *CompositeResolver) setFailure(i int, f *BackendFailure) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f == nil {
+		delete(c.failures, i)
+		return
+	}
+	if c.failures == nil {
+		c.failures = make(map[int]*BackendFailure)
+	}
+	copied := *f
+	c.failures[i] = &copied
+}
+
+// Status reports the backends that failed to start and are being retried.
+func (c *CompositeResolver) Status() DiscoveryStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var st DiscoveryStatus
+	for _, f := range c.failures {
+		st.Failed = append(st.Failed, *f)
+	}
+	sort.Slice(st.Failed, func(a, b int) bool { return st.Failed[a].Backend < st.Failed[b].Backend })
+	st.Degraded = len(st.Failed) > 0
+	return st
+}
+
+// backendName names a discovery backend in logs and status reports.
+func backendName(r NodeResolver) string {
+	switch r.(type) {
+	case *MDNSResolver:
+		return "mdns"
+	case *BrokerResolver:
+		return "broker"
+	case *StaticResolver:
+		return "static"
+	}
+	return fmt.Sprintf("%T", r)
+}
@@ -3,6 +3,8 @@ package discovery
 import (
 	"context"
 	"errors"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -66,18 +68,95 @@ func TestCompositeResolverSingleBackendPassthrough(t *testing.T) {
 	}
 }
 
-// TestCompositeResolverPropagatesBackendWatchError characterizes the current
-// fail-fast fan-out: if any backend's Watch call fails to start, the whole
-// composite fails to start rather than degrading to the remaining backends.
-func TestCompositeResolverPropagatesBackendWatchError(t *testing.T) {
+// flakyResolver fails to start until it has been asked fails times.
+type flakyResolver struct {
+	fails int32
+	calls atomic.Int32
+	ch    chan NodeEvent
+}
+
+func (f *flakyResolver) Watch(ctx context.Context) (<-chan NodeEvent, error) {
+	if f.calls.Add(1) <= f.fails {
+		return nil, errors.New("no multicast route")
+	}
+	return f.ch, nil
+}
+
+func shortenBackendRetry(t *testing.T) {
+	t.Helper()
+	oldMin, oldMax := backendRetryMin, backendRetryMax
+	backendRetryMin, backendRetryMax = 10*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { backendRetryMin, backendRetryMax = oldMin, oldMax })
+}
+
+// TestCompositeResolverDegradesWhenABackendFailsToStart checks that a
+// backend failing to start leaves the others running, is reported by
+// Status, and joins the merged channel once a retry succeeds.
+func TestCompositeResolverDegradesWhenABackendFailsToStart(t *testing.T) {
+	shortenBackendRetry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	good := NewStaticResolver([]string{"10.0.0.1:50051"}, "", nil)
+	flaky := &flakyResolver{fails: 3, ch: make(chan NodeEvent, 1)}
+	composite := NewCompositeResolver(good, flaky).(*CompositeResolver)
+
+	merged, err := composite.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch with one failing backend: %v", err)
+	}
+	st := composite.Status()
+	if !st.Degraded || len(st.Failed) != 1 || st.Failed[0].Error != "no multicast route" || st.Failed[0].Attempts != 1 {
+		t.Fatalf("Status after a failed start = %+v, want degraded with the failure", st)
+	}
+	if ev := awaitEvent(t, merged, 2*time.Second); ev.Addresses[0] != "10.0.0.1:50051" {
+		t.Fatalf("event from the running backend = %+v", ev)
+	}
+
+	flaky.ch <- NodeEvent{Type: NodeDiscovered, Addresses: []string{"10.0.0.2:50051"}}
+	if ev := awaitEvent(t, merged, 2*time.Second); ev.Addresses[0] != "10.0.0.2:50051" {
+		t.Fatalf("event from the recovered backend = %+v", ev)
+	}
+	if st := composite.Status(); st.Degraded || len(st.Failed) != 0 {
+		t.Fatalf("Status after recovery = %+v, want healthy", st)
+	}
+	if calls := flaky.calls.Load(); calls != 4 {
+		t.Fatalf("backend started after %d attempts, want 4", calls)
+	}
+}
+
+func TestCompositeResolverFailsWhenEveryBackendFails(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	a := &fakeResolver{err: errors.New("boom")}
+	b := &fakeResolver{err: errors.New("bang")}
+	_, err := NewCompositeResolver(a, b).Watch(ctx)
+	if err == nil || !strings.Contains(err.Error(), "boom") || !strings.Contains(err.Error(), "bang") {
+		t.Fatalf("Watch with every backend failing = %v, want both errors", err)
+	}
+}
+
+func TestCompositeResolverStopsRetryingOnCancel(t *testing.T) {
+	shortenBackendRetry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
 	good := NewStaticResolver([]string{"10.0.0.1:50051"}, "", nil)
-	bad := &fakeResolver{err: errors.New("boom")}
+	composite := NewCompositeResolver(good, &fakeResolver{err: errors.New("boom")}).(*CompositeResolver)
+	merged, err := composite.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	awaitEvent(t, merged, 2*time.Second)
 
-	if _, err := NewCompositeResolver(good, bad).Watch(ctx); err == nil {
-		t.Fatal("expected an error when one backend fails to start, got nil")
+	cancel()
+	select {
+	case _, ok := <-merged:
+		if ok {
+			t.Fatal("expected close, got event")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("merged channel did not close while a backend was being retried")
 	}
 }
 
@@ -0,0 +1,80 @@
+package discovery
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// FeaturesExtraKey is the capability Extra key under which a node lists the
+// wire features it understands, comma-separated, e.g. "parallel_upload,progress".
+// The client turns on a feature-dependent behavior for a node only when the
+// node lists it, so older nodes never see request Meta they do not know.
+const FeaturesExtraKey = "features"
+
+// CapabilityErrorExtraKey is the capability Extra key under which a node
+// reports that the service's backend failed, with the reason as its value.
+// A node serving several services streams such a capability for a failing
+// one instead of failing the whole fetch; the client keeps that service's
+// previous capability, marked stale, and refreshes the others.
+const CapabilityErrorExtraKey = "error"
+
+// Wire features a node may advertise under FeaturesExtraKey.
+const (
+	// FeatureParallelUpload: the node reassembles one chunked payload sent
+	// over several streams (request Meta lumen.upload_part).
+	FeatureParallelUpload = "parallel_upload"
+	// FeatureProgress: the node sends progress frames before its final
+	// response (response Meta lumen.progress).
+	FeatureProgress = "progress"
+	// FeatureChecksum: the node verifies per-chunk checksums.
+	FeatureChecksum = "checksum"
+	// FeatureZstd: the node accepts zstd-compressed payloads.
+	FeatureZstd = "zstd"
+	// FeatureResume: the node resumes an interrupted upload on a new stream
+	// (request Meta lumen.resume_from).
+	FeatureResume = "resume"
+	// FeatureJobs: the node runs a request as an asynchronous job when asked
+	// to (request Meta lumen.job) and answers status, result and cancel
+	// requests for it.
+	FeatureJobs = "jobs"
+)
+
+// KnownFeatures returns the wire features this SDK knows, sorted.
+func KnownFeatures() []string {
+	return []string{FeatureChecksum, FeatureJobs, FeatureParallelUpload, FeatureProgress, FeatureResume, FeatureZstd}
+}
+
+// FeaturesFromCapabilities returns the features advertised across caps,
+// sorted and without duplicates. Names are lowercased; unknown names are
+// kept, so a newer node's features show up in NodeInfo. A "parallel_upload"
+// Extra key that is "true" or a count above zero, the form nodes used
+// before FeaturesExtraKey, also advertises FeatureParallelUpload.
+func FeaturesFromCapabilities(caps []*pb.Capability) []string {
+	var features []string
+	for _, cap := range caps {
+		extra := cap.GetExtra()
+		for _, name := range strings.Split(extra[FeaturesExtraKey], ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				features = append(features, name)
+			}
+		}
+		if v, ok := extra[FeatureParallelUpload]; ok {
+			n, err := strconv.Atoi(v)
+			enabled, _ := strconv.ParseBool(v)
+			if enabled || (err == nil && n > 0) {
+				features = append(features, FeatureParallelUpload)
+			}
+		}
+	}
+	slices.Sort(features)
+	return slices.Compact(features)
+}
+
+// SupportsFeature reports whether the node advertised the wire feature name
+// when its capabilities were last fetched.
+func (n *NodeInfo) SupportsFeature(name string) bool {
+	return slices.Contains(n.Features, strings.ToLower(name))
+}
@@ -0,0 +1,33 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+func TestFeaturesFromCapabilities(t *testing.T) {
+	caps := []*pb.Capability{
+		{ServiceName: "clip", Extra: map[string]string{FeaturesExtraKey: "zstd, Progress,,future_thing"}},
+		{ServiceName: "ocr", Extra: map[string]string{FeaturesExtraKey: "progress", FeatureParallelUpload: "2"}},
+		{ServiceName: "face", Extra: map[string]string{FeatureParallelUpload: "false"}},
+		nil,
+	}
+	got := FeaturesFromCapabilities(caps)
+	want := []string{"future_thing", FeatureParallelUpload, FeatureProgress, FeatureZstd}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("features = %v, want %v", got, want)
+	}
+
+	node := &NodeInfo{Features: got}
+	if !node.SupportsFeature("ZSTD") || node.SupportsFeature(FeatureChecksum) {
+		t.Fatalf("SupportsFeature disagrees with %v", got)
+	}
+	if (&NodeInfo{}).SupportsFeature(FeatureProgress) {
+		t.Fatal("a node without fetched capabilities supports a feature")
+	}
+	if got := FeaturesFromCapabilities([]*pb.Capability{{Extra: map[string]string{FeatureParallelUpload: "0"}}}); len(got) != 0 {
+		t.Fatalf("parallel_upload=0 advertised %v", got)
+	}
+}
@@ -0,0 +1,172 @@
+package discovery
+
+import (
+	"sync"
+	"time"
+)
+
+// Discovery backends, as named in NodeEvent.Source. SourcePush is declared
+// with the push resolver.
+const (
+	SourceMDNS   = "mdns"
+	SourceBroker = "broker"
+	SourceStatic = "static"
+)
+
+// CatalogFreshness is how current the node catalog is: how long since the
+// backends last vouched for the nodes in it. mDNS vouches for a node each
+// scan that finds it, the Broker for all of its nodes while its WebSocket
+// answers pings, a pushed node with each heartbeat, and a static node with
+// each passing health check.
+type CatalogFreshness struct {
+	// LastScan is when the last mDNS scan completed; zero before the first.
+	LastScan time.Time `json:"last_scan,omitempty"`
+	// Age is how long ago the least recently vouched-for node was vouched
+	// for; zero for an empty catalog.
+	Age time.Duration `json:"age"`
+	// Oldest is that node's key.
+	Oldest string `json:"oldest,omitempty"`
+	// MaxAge is the age past which the catalog is stale; zero when
+	// staleness is not enforced.
+	MaxAge time.Duration `json:"max_age,omitempty"`
+	// Stale is set while Age exceeds MaxAge.
+	Stale bool `json:"stale"`
+	// Since is when the catalog became stale, while it is.
+	Since time.Time `json:"since,omitempty"`
+}
+
+// CatalogTracker follows CatalogFreshness from the discovery events a
+// consumer sees. It is safe for concurrent use.
+type CatalogTracker struct {
+	maxAge time.Duration
+	clock  func() time.Time
+	// exempt lists sources whose nodes never go stale.
+	exempt map[string]bool
+
+	mu       sync.Mutex
+	nodes    map[string]vouch
+	lastScan time.Time
+	since    time.Time // when the catalog went stale; zero while fresh
+}
+
+type vouch struct {
+	source string
+	at     time.Time
+}
+
+// NewCatalogTracker returns a tracker that reports the catalog stale once
+// a node has gone unvouched for longer than maxAge; zero never does. clock
+// is the time it measures on; nil means time.Now. Nodes from the exempt
+// sources never go stale, e.g. SourceStatic when static nodes are not
+// health-checked.
+func NewCatalogTracker(maxAge time.Duration, clock func() time.Time, exempt ...string) *CatalogTracker {
+	if clock == nil {
+		clock = time.Now
+	}
+	t := &CatalogTracker{
+		maxAge: maxAge,
+		clock:  clock,
+		exempt: make(map[string]bool, len(exempt)),
+		nodes:  make(map[string]vouch),
+	}
+	for _, source := range exempt {
+		t.exempt[source] = true
+	}
+	return t
+}
+
+// Observe updates the catalog from ev. key is the node the event is about,
+// as the consumer keys it; it is ignored for events about a whole source.
+func (t *CatalogTracker) Observe(ev NodeEvent, key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock()
+	switch ev.Type {
+	case NodeDiscovered:
+		if key != "" && !t.exempt[ev.Source] {
+			t.nodes[key] = vouch{source: ev.Source, at: now}
+		}
+	case NodeExpired:
+		// The backend no longer vouches for the node either way.
+		delete(t.nodes, key)
+	case NodeRefreshed:
+		if !ev.Identity.IsZero() {
+			if v, ok := t.nodes[key]; ok {
+				v.at = now
+				t.nodes[key] = v
+			}
+			return
+		}
+		for k, v := range t.nodes {
+			if v.source == ev.Source {
+				v.at = now
+				t.nodes[k] = v
+			}
+		}
+	case ScanCompleted:
+		if ev.Source == SourceMDNS {
+			t.lastScan = now
+		}
+	}
+}
+
+// Vouch records that the node with key is known to be current, such as
+// after a passing health check. Nodes the tracker does not follow are
+// ignored.
+func (t *CatalogTracker) Vouch(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if v, ok := t.nodes[key]; ok {
+		v.at = t.clock()
+		t.nodes[key] = v
+	}
+}
+
+// Source returns the backend the node with key came from, if the tracker
+// follows it.
+func (t *CatalogTracker) Source(key string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v, ok := t.nodes[key]
+	return v.source, ok
+}
+
+// Check returns the catalog's freshness now, and whether it changed
+// between fresh and stale since the previous Check.
+func (t *CatalogTracker) Check() (f CatalogFreshness, changed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock()
+	f = t.freshnessLocked(now)
+	switch {
+	case f.Stale && t.since.IsZero():
+		t.since, changed = now, true
+	case !f.Stale && !t.since.IsZero():
+		t.since, changed = time.Time{}, true
+	}
+	f.Since = t.since
+	return f, changed
+}
+
+// Freshness returns the catalog's freshness now without recording a
+// transition; Since is as of the last Check.
+func (t *CatalogTracker) Freshness() CatalogFreshness {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f := t.freshnessLocked(t.clock())
+	if f.Stale {
+		f.Since = t.since
+	}
+	return f
+}
+
+func (t *CatalogTracker) freshnessLocked(now time.Time) CatalogFreshness {
+	f := CatalogFreshness{LastScan: t.lastScan, MaxAge: t.maxAge}
+	for key, v := range t.nodes {
+		if age := now.Sub(v.at); age > f.Age || f.Oldest == "" {
+			f.Age, f.Oldest = age, key
+		}
+	}
+	f.Stale = t.maxAge > 0 && f.Age > t.maxAge
+	return f
+}
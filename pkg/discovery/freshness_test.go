@@ -0,0 +1,116 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable clock for CatalogTracker.
+type fakeClock struct{ now time.Time }
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1_700_000_000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func discovered(source, id string) NodeEvent {
+	return NodeEvent{Type: NodeDiscovered, Source: source, Identity: NewNodeIdentity("lab", id)}
+}
+
+// refreshed is a NodeRefreshed event for node id, or for all of source's
+// nodes when id is empty.
+func refreshed(source, id string) NodeEvent {
+	ev := NodeEvent{Type: NodeRefreshed, Source: source}
+	if id != "" {
+		ev.Identity = NewNodeIdentity("lab", id)
+	}
+	return ev
+}
+
+func TestCatalogTrackerGoesStaleAndRecovers(t *testing.T) {
+	clock := newFakeClock()
+	tr := NewCatalogTracker(time.Minute, clock.Now)
+	tr.Observe(discovered(SourcePush, "gpu-1"), "gpu-1")
+
+	clock.Advance(30 * time.Second)
+	if f, changed := tr.Check(); f.Stale || changed || f.Age != 30*time.Second || f.Oldest != "gpu-1" {
+		t.Fatalf("after 30s: %+v changed=%v, want fresh at 30s", f, changed)
+	}
+
+	clock.Advance(31 * time.Second)
+	f, changed := tr.Check()
+	if !f.Stale || !changed || !f.Since.Equal(clock.now) {
+		t.Fatalf("after 61s: %+v changed=%v, want newly stale", f, changed)
+	}
+	if _, changed := tr.Check(); changed {
+		t.Fatal("second Check while stale reported a change")
+	}
+
+	tr.Observe(refreshed(SourcePush, "gpu-1"), "gpu-1")
+	f, changed = tr.Check()
+	if f.Stale || !changed || f.Age != 0 || !f.Since.IsZero() {
+		t.Fatalf("after heartbeat: %+v changed=%v, want fresh again", f, changed)
+	}
+}
+
+func TestCatalogTrackerSourceWideRefresh(t *testing.T) {
+	clock := newFakeClock()
+	tr := NewCatalogTracker(time.Minute, clock.Now)
+	tr.Observe(discovered(SourceBroker, "b-1"), "b-1")
+	tr.Observe(discovered(SourceBroker, "b-2"), "b-2")
+	tr.Observe(discovered(SourcePush, "p-1"), "p-1")
+
+	clock.Advance(2 * time.Minute)
+	tr.Observe(refreshed(SourceBroker, ""), "")
+	f := tr.Freshness()
+	if !f.Stale || f.Oldest != "p-1" {
+		t.Fatalf("freshness = %+v, want only the push node stale", f)
+	}
+
+	tr.Observe(NodeEvent{Type: NodeExpired, Source: SourcePush}, "p-1")
+	if f := tr.Freshness(); f.Stale || f.Age != 0 {
+		t.Fatalf("freshness after expiry = %+v, want fresh", f)
+	}
+}
+
+func TestCatalogTrackerScansAndVouches(t *testing.T) {
+	clock := newFakeClock()
+	tr := NewCatalogTracker(time.Minute, clock.Now, SourceStatic)
+	tr.Observe(discovered(SourceStatic, "s-1"), "s-1")
+	tr.Observe(discovered(SourceMDNS, "m-1"), "m-1")
+
+	clock.Advance(time.Minute + time.Second)
+	if f := tr.Freshness(); !f.Stale || f.Oldest != "m-1" {
+		t.Fatalf("freshness = %+v, want the mDNS node stale and the exempt static node ignored", f)
+	}
+	tr.Vouch("s-1") // not followed: a no-op
+	if _, ok := tr.Source("s-1"); ok {
+		t.Fatal("exempt static node is tracked")
+	}
+
+	// A scan that finds the node rediscovers it and then completes.
+	tr.Observe(discovered(SourceMDNS, "m-1"), "m-1")
+	tr.Observe(NodeEvent{Type: ScanCompleted, Source: SourceMDNS}, "")
+	f := tr.Freshness()
+	if f.Stale || !f.LastScan.Equal(clock.now) {
+		t.Fatalf("freshness after scan = %+v, want fresh with LastScan now", f)
+	}
+
+	clock.Advance(2 * time.Minute)
+	tr.Vouch("m-1")
+	if f := tr.Freshness(); f.Stale {
+		t.Fatalf("freshness after Vouch = %+v, want fresh", f)
+	}
+}
+
+func TestCatalogTrackerZeroMaxAgeNeverStale(t *testing.T) {
+	clock := newFakeClock()
+	tr := NewCatalogTracker(0, clock.Now)
+	tr.Observe(discovered(SourcePush, "gpu-1"), "gpu-1")
+	clock.Advance(24 * time.Hour)
+	if f, changed := tr.Check(); f.Stale || changed || f.Age != 24*time.Hour {
+		t.Fatalf("freshness = %+v changed=%v, want age reported but never stale", f, changed)
+	}
+}
@@ -0,0 +1,37 @@
+package discovery
+
+import "time"
+
+// JobState is where an asynchronous job is in its lifecycle.
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// Done reports whether s is final: the job will not change state again.
+func (s JobState) Done() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// JobStatus describes an asynchronous job as last reported by the node
+// running it. Jobs are forgotten at ExpiresAt, which every status query
+// moves on.
+type JobStatus struct {
+	ID     string   `json:"id"`
+	Task   string   `json:"task"`
+	NodeID string   `json:"node_id"`
+	State  JobState `json:"state"`
+	// Progress is the percentage of the work done, 0 to 100, when the node
+	// reports it.
+	Progress float64 `json:"progress"`
+	// Error is why the job failed; empty unless State is JobFailed.
+	Error       string    `json:"error,omitempty"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
@@ -3,10 +3,13 @@ package discovery
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
 	"github.com/hashicorp/mdns"
 
 	"go.uber.org/zap"
@@ -22,14 +25,17 @@ const (
 // channel. It implements the NodeResolver interface.
 //
 // It runs a polling loop that periodically queries for mDNS services. Nodes not
-// seen for consecutive polls are expired.
+// seen for consecutive polls are expired. Nodes the filter set with SetFilter
+// rejects are never emitted, so they are never probed or connected.
 type MDNSResolver struct {
 	serviceType  string
 	domain       string
 	deploymentID string
 	pollInterval time.Duration
 	queryTimeout time.Duration
+	filter       *NodeFilter
 	logger       *zap.Logger
+	clock        utils.Clock
 }
 
 // NewMDNSResolver creates an mDNS-based resolver.
@@ -63,11 +69,59 @@ func NewMDNSResolver(cfg *config.DiscoveryConfig, logger *zap.Logger) *MDNSResol
 		pollInterval: pollInterval,
 		queryTimeout: queryTimeout,
 		logger:       ensureLogger(logger),
+		clock:        utils.RealClock,
 	}
 }
 
-// Watch starts mDNS polling and emits NodeEvent values on the returned channel.
+// SetClock sets the clock the polls are spaced on; nil is the wall clock.
+// Call it before Watch.
+func (r *MDNSResolver) SetClock(clock utils.Clock) {
+	r.clock = utils.ClockOrReal(clock)
+}
+
+// SetFilter makes the resolver drop nodes f rejects. When f is updated,
+// nodes already emitted that it now rejects are expired at once. Call it
+// before Watch.
+func (r *MDNSResolver) SetFilter(f *NodeFilter) {
+	r.filter = f
+}
+
+// mdnsGroup is the IPv4 multicast group and port mDNS queries use.
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// mdnsSocketCheck is checkMDNSSockets; a variable so tests can simulate a
+// host without multicast.
+var mdnsSocketCheck = checkMDNSSockets
+
+// checkMDNSSockets opens and closes the sockets every mDNS query binds, so a
+// host that cannot do mDNS is found once at Watch rather than on every poll.
+func checkMDNSSockets() error {
+	uconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return fmt.Errorf("bind udp4 socket: %w", err)
+	}
+	uconn.Close()
+	mconn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return fmt.Errorf("join multicast group %s: %w", mdnsGroup, err)
+	}
+	mconn.Close()
+	return nil
+}
+
+// Watch starts mDNS polling and emits NodeEvent values on the returned
+// channel. It fails with ErrCodeDiscoveryFailed if the host cannot open the
+// sockets mDNS needs.
 func (r *MDNSResolver) Watch(ctx context.Context) (<-chan NodeEvent, error) {
+	if err := mdnsSocketCheck(); err != nil {
+		return nil, utils.Wrap(err, utils.ErrCodeDiscoveryFailed,
+			"mDNS discovery cannot start. Likely causes: no interface with a multicast route "+
+				"(e.g. a container without host networking, or only a VPN link up), "+
+				"missing permission to open UDP port 5353, or a firewall blocking it. "+
+				"Fix the network, or set discovery.broker_url or discovery.static_nodes "+
+				"to find nodes without mDNS",
+			map[string]string{"group": mdnsGroup.String(), "service": r.serviceType})
+	}
 	ch := make(chan NodeEvent, 32)
 	go r.pollLoop(ctx, ch)
 	return ch, nil
@@ -82,9 +136,13 @@ func (r *MDNSResolver) pollLoop(ctx context.Context, ch chan<- NodeEvent) {
 	defer close(ch)
 
 	known := make(map[string]*knownNode)
+	// denied holds the nodes the filter rejected, so each is logged once
+	// rather than on every poll. It is cleared when the filter changes.
+	denied := make(map[string]bool)
+	filterChanged := r.filter.Changed()
 
 	for {
-		seen := r.runQuery(ctx, ch, known)
+		seen, ok := r.runQuery(ctx, ch, known, denied)
 		if ctx.Err() != nil {
 			return
 		}
@@ -96,7 +154,7 @@ func (r *MDNSResolver) pollLoop(ctx context.Context, ch chan<- NodeEvent) {
 			}
 			kn.misses++
 			if kn.misses >= missThreshold {
-				event := eventFromResolved(NodeExpired, kn.resolved)
+				event := mdnsEvent(NodeExpired, kn.resolved)
 				r.logger.Info("mDNS node expired",
 					zap.String("id", key),
 					zap.Int("missed_polls", kn.misses),
@@ -109,17 +167,76 @@ func (r *MDNSResolver) pollLoop(ctx context.Context, ch chan<- NodeEvent) {
 				delete(known, key)
 			}
 		}
+		if ok {
+			select {
+			case ch <- NodeEvent{Type: ScanCompleted, Source: SourceMDNS}:
+			case <-ctx.Done():
+				return
+			}
+		}
 
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(r.pollInterval):
+		case <-r.clock.After(r.pollInterval):
+		case <-filterChanged:
+			filterChanged = r.filter.Changed()
+			clear(denied)
+			if !r.refilter(ctx, ch, known) {
+				return
+			}
+		}
+	}
+}
+
+// refilter expires the known nodes the filter now rejects. Nodes it newly
+// admits are picked up by the query that follows. It returns false if ctx
+// ended first.
+func (r *MDNSResolver) refilter(ctx context.Context, ch chan<- NodeEvent, known map[string]*knownNode) bool {
+	for key, kn := range known {
+		ok, reason := r.filter.Allows(kn.resolved)
+		if ok {
+			continue
+		}
+		r.logger.Info("mDNS node removed by discovery filter",
+			zap.String("id", key),
+			zap.String("reason", reason),
+		)
+		event := mdnsEvent(NodeExpired, kn.resolved)
+		event.ExplicitRemove = true
+		select {
+		case ch <- event:
+		case <-ctx.Done():
+			return false
 		}
+		delete(known, key)
 	}
+	return true
 }
 
-func (r *MDNSResolver) runQuery(ctx context.Context, ch chan<- NodeEvent, known map[string]*knownNode) map[string]bool {
-	seen := make(map[string]bool)
+// admit reports whether the filter accepts resolved, logging a rejected
+// node at debug the first time it is seen.
+func (r *MDNSResolver) admit(resolved ResolvedNode, denied map[string]bool) bool {
+	ok, reason := r.filter.Allows(resolved)
+	if ok {
+		return true
+	}
+	key := resolved.Key()
+	if !denied[key] {
+		denied[key] = true
+		r.logger.Debug("mDNS node rejected by discovery filter",
+			zap.String("id", key),
+			zap.String("instance", resolved.InstanceName),
+			zap.String("reason", reason),
+		)
+	}
+	return false
+}
+
+// runQuery runs one mDNS query, emitting an event per admitted node it
+// finds, and returns the nodes seen. ok is false if the query failed.
+func (r *MDNSResolver) runQuery(ctx context.Context, ch chan<- NodeEvent, known map[string]*knownNode, denied map[string]bool) (seen map[string]bool, ok bool) {
+	seen = make(map[string]bool)
 
 	entries := make(chan *mdns.ServiceEntry, 16)
 	params := &mdns.QueryParam{
@@ -138,7 +255,7 @@ func (r *MDNSResolver) runQuery(ctx context.Context, ch chan<- NodeEvent, known
 				continue
 			}
 			resolved := r.resolvedNodeFromMDNS(entry)
-			if resolved.Identity.IsZero() {
+			if resolved.Identity.IsZero() || !r.admit(resolved, denied) {
 				continue
 			}
 			key := resolved.Key()
@@ -151,7 +268,7 @@ func (r *MDNSResolver) runQuery(ctx context.Context, ch chan<- NodeEvent, known
 				known[key] = &knownNode{resolved: resolved}
 			}
 
-			event := eventFromResolved(NodeDiscovered, resolved)
+			event := mdnsEvent(NodeDiscovered, resolved)
 			r.logger.Info("mDNS node resolved",
 				zap.String("id", key),
 				zap.Strings("addresses", event.Addresses),
@@ -166,13 +283,14 @@ func (r *MDNSResolver) runQuery(ctx context.Context, ch chan<- NodeEvent, known
 
 	queryCtx, cancel := context.WithTimeout(ctx, r.queryTimeout+time.Second)
 	defer cancel()
-	if err := mdns.QueryContext(queryCtx, params); err != nil && ctx.Err() == nil {
+	err := mdns.QueryContext(queryCtx, params)
+	if err != nil && ctx.Err() == nil {
 		r.logger.Warn("mDNS query failed", zap.Error(err))
 	}
 	close(entries)
 	<-doneCh
 
-	return seen
+	return seen, err == nil
 }
 
 func (r *MDNSResolver) resolvedNodeFromMDNS(entry *mdns.ServiceEntry) ResolvedNode {
@@ -180,11 +298,26 @@ func (r *MDNSResolver) resolvedNodeFromMDNS(entry *mdns.ServiceEntry) ResolvedNo
 		return ResolvedNode{}
 	}
 
+	txt, truncated := parseTXT(entry.InfoFields)
 	instance := extractInstanceName(entry.Name, r.serviceType, r.domain)
 	if instance == "" {
-		instance = fmt.Sprintf("%s:%d", entry.Host, entry.Port)
+		instance = net.JoinHostPort(entry.Host, strconv.Itoa(entry.Port))
+	}
+	if truncated {
+		r.logger.Debug("mDNS tasks record fills a whole TXT string; dropped its last entry as possibly cut off",
+			zap.String("instance", instance))
+	}
+	if hints := ParseCapabilityHints(txt); len(hints.Problems) > 0 {
+		r.logger.Debug("mDNS node advertised malformed capability hints",
+			zap.String("instance", instance),
+			zap.Strings("problems", hints.Problems))
 	}
+	// A node that advertises its own ID keeps it across address and
+	// instance-name changes; otherwise fall back to the instance name.
 	identity := ParseNodeIdentity(instance, r.deploymentID)
+	if id := AdvertisedNodeID(txt); id != "" {
+		identity = NewNodeIdentity(r.deploymentID, id)
+	}
 
 	var addresses []string
 	if entry.AddrV4 != nil {
@@ -202,7 +335,7 @@ func (r *MDNSResolver) resolvedNodeFromMDNS(entry *mdns.ServiceEntry) ResolvedNo
 		HostName:     strings.TrimSuffix(entry.Host, "."),
 		Addresses:    addresses,
 		Port:         entry.Port,
-		Txt:          parseTXT(entry.InfoFields),
+		Txt:          txt,
 	}.Normalized()
 }
 
@@ -220,6 +353,13 @@ func extractInstanceName(fullName, serviceType, domain string) string {
 	return fullName
 }
 
+// mdnsEvent is eventFromResolved for an mDNS node.
+func mdnsEvent(eventType NodeEventType, resolved ResolvedNode) NodeEvent {
+	ev := eventFromResolved(eventType, resolved)
+	ev.Source = SourceMDNS
+	return ev
+}
+
 func eventFromResolved(eventType NodeEventType, resolved ResolvedNode) NodeEvent {
 	resolved = resolved.Normalized()
 	endpoints := resolved.CandidateEndpoints()
@@ -233,16 +373,29 @@ func eventFromResolved(eventType NodeEventType, resolved ResolvedNode) NodeEvent
 	}
 }
 
-func parseTXT(records []string) map[string]string {
+// parseTXT reads a node's TXT strings as "key=value" pairs. Keys are
+// case-insensitive and the first string of a key wins (RFC 6763 §6.4). A
+// tasks list that fills a whole string loses its last, possibly cut-off,
+// entry; truncated reports it.
+func parseTXT(records []string) (txt map[string]string, truncated bool) {
 	out := make(map[string]string, len(records))
 	for _, record := range records {
 		key, value, ok := splitTXT(record)
 		if !ok {
 			continue
 		}
+		key = strings.ToLower(key)
+		if _, dup := out[key]; dup {
+			continue
+		}
+		if key == TasksTxtKey {
+			var cut bool
+			value, cut = dropCutOffEntry(record, value)
+			truncated = truncated || cut
+		}
 		out[key] = value
 	}
-	return out
+	return out, truncated
 }
 
 func splitTXT(record string) (string, string, bool) {
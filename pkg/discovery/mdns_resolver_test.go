@@ -2,10 +2,13 @@ package discovery
 
 import (
 	"context"
+	"errors"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
 	"github.com/hashicorp/mdns"
 )
 
@@ -68,6 +71,45 @@ func TestMDNSResolvedNodeKeepsCandidatesAndTXT(t *testing.T) {
 	}
 }
 
+func TestMDNSResolvedNodePrefersAdvertisedNodeID(t *testing.T) {
+	resolver := &MDNSResolver{
+		serviceType:  "_lumen._tcp",
+		domain:       "local",
+		deploymentID: "lab",
+	}
+	first := resolver.resolvedNodeFromMDNS(&mdns.ServiceEntry{
+		Name:       "lab-node-1._lumen._tcp.local.",
+		Host:       "host.local.",
+		Port:       5866,
+		AddrV4:     net.ParseIP("192.168.1.20"),
+		InfoFields: []string{"node_id=6f1c2a"},
+	})
+	// Same node after a new DHCP lease and an mDNS instance rename.
+	moved := resolver.resolvedNodeFromMDNS(&mdns.ServiceEntry{
+		Name:       "lab-node-1 (2)._lumen._tcp.local.",
+		Host:       "host.local.",
+		Port:       5866,
+		AddrV4:     net.ParseIP("192.168.1.77"),
+		InfoFields: []string{"node_id=6f1c2a"},
+	})
+	if first.Key() != "lab-6f1c2a" || moved.Key() != first.Key() {
+		t.Fatalf("keys = %q, %q, want both lab-6f1c2a", first.Key(), moved.Key())
+	}
+	if moved.Endpoint() != "192.168.1.77:5866" {
+		t.Fatalf("endpoint = %q, want the new address", moved.Endpoint())
+	}
+
+	uuid := resolver.resolvedNodeFromMDNS(&mdns.ServiceEntry{
+		Name:       "lab-node-2._lumen._tcp.local.",
+		Port:       5866,
+		AddrV4:     net.ParseIP("192.168.1.21"),
+		InfoFields: []string{"uuid=9d4e"},
+	})
+	if uuid.Key() != "lab-9d4e" {
+		t.Fatalf("uuid key = %q, want lab-9d4e", uuid.Key())
+	}
+}
+
 func TestMDNSResolvedNodeMissingAddressIsNotEndpoint(t *testing.T) {
 	resolver := &MDNSResolver{
 		serviceType:  "_lumen._tcp",
@@ -187,3 +229,19 @@ func TestPollLoopContextCancellation(t *testing.T) {
 		t.Fatal("channel not closed after context cancellation")
 	}
 }
+
+func TestMDNSWatchFailsWithoutMulticast(t *testing.T) {
+	old := mdnsSocketCheck
+	mdnsSocketCheck = func() error { return errors.New("setsockopt: no such device") }
+	t.Cleanup(func() { mdnsSocketCheck = old })
+
+	_, err := NewMDNSResolver(nil, nil).Watch(context.Background())
+	if !utils.HasErrorCode(err, utils.ErrCodeDiscoveryFailed) {
+		t.Fatalf("Watch = %v, want DISCOVERY_FAILED", err)
+	}
+	for _, want := range []string{"multicast route", "broker_url", "no such device"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ClusterTxtKey is the TXT label a node advertises its cluster under;
+// "cluster=<name>" node patterns match it.
+const ClusterTxtKey = "cluster"
+
+// NodeFilter decides which discovered nodes are adopted, from allow and
+// deny patterns. A pattern is one of:
+//
+//   - "cluster=<name>": the node's TXT "cluster" label equals name;
+//   - a CIDR such as "10.1.0.0/16", or a single IP: one of the node's
+//     addresses is in it;
+//   - anything else: a glob (path.Match syntax, e.g. "lab-*") over the
+//     node's instance name or ID.
+//
+// A node matching a deny pattern is rejected even if an allow pattern
+// matches it too. With allow patterns set, a node must match one of them;
+// with none, every node not denied is adopted. The zero value and a nil
+// *NodeFilter adopt every node.
+//
+// Update replaces the patterns at run time; resolvers watching Changed
+// re-evaluate the nodes they already adopted.
+type NodeFilter struct {
+	mu      sync.RWMutex
+	allow   []nodePattern
+	deny    []nodePattern
+	changed chan struct{}
+}
+
+// NewNodeFilter parses allow and deny into a NodeFilter. It fails on a
+// malformed CIDR or glob.
+func NewNodeFilter(allow, deny []string) (*NodeFilter, error) {
+	f := &NodeFilter{}
+	if err := f.Update(allow, deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Update replaces the filter's patterns and wakes every Changed channel.
+// On error the filter keeps its previous patterns.
+func (f *NodeFilter) Update(allow, deny []string) error {
+	allowPatterns, err := parseNodePatterns(allow)
+	if err != nil {
+		return err
+	}
+	denyPatterns, err := parseNodePatterns(deny)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.allow, f.deny = allowPatterns, denyPatterns
+	if f.changed != nil {
+		close(f.changed)
+	}
+	f.changed = make(chan struct{})
+	return nil
+}
+
+// Changed returns a channel closed at the next Update. A nil filter never
+// changes.
+func (f *NodeFilter) Changed() <-chan struct{} {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.changed == nil {
+		f.changed = make(chan struct{})
+	}
+	return f.changed
+}
+
+// Empty reports whether the filter has no patterns and so adopts every
+// node.
+func (f *NodeFilter) Empty() bool {
+	if f == nil {
+		return true
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.allow) == 0 && len(f.deny) == 0
+}
+
+// Allows reports whether node is adopted and, when it is not, why.
+func (f *NodeFilter) Allows(node ResolvedNode) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, p := range f.deny {
+		if p.match(node) {
+			return false, fmt.Sprintf("matches deny pattern %q", p.raw)
+		}
+	}
+	if len(f.allow) == 0 {
+		return true, ""
+	}
+	for _, p := range f.allow {
+		if p.match(node) {
+			return true, ""
+		}
+	}
+	return false, "matches no allow pattern"
+}
+
+// ValidateNodePattern reports whether pattern is a well-formed node
+// pattern.
+func ValidateNodePattern(pattern string) error {
+	_, err := parseNodePattern(pattern)
+	return err
+}
+
+type nodePattern struct {
+	raw     string
+	cluster string
+	network *net.IPNet
+	glob    string
+}
+
+func parseNodePatterns(raw []string) ([]nodePattern, error) {
+	out := make([]nodePattern, 0, len(raw))
+	for _, r := range raw {
+		p, err := parseNodePattern(r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func parseNodePattern(raw string) (nodePattern, error) {
+	s := strings.TrimSpace(raw)
+	p := nodePattern{raw: s}
+	switch {
+	case s == "":
+		return p, fmt.Errorf("empty node pattern")
+	case strings.HasPrefix(s, ClusterTxtKey+"="):
+		p.cluster = strings.TrimPrefix(s, ClusterTxtKey+"=")
+		if p.cluster == "" {
+			return p, fmt.Errorf("node pattern %q names no cluster", raw)
+		}
+	case strings.Contains(s, "/"):
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return p, fmt.Errorf("node pattern %q is not a valid CIDR: %w", raw, err)
+		}
+		p.network = network
+	case net.ParseIP(s) != nil:
+		ip := net.ParseIP(s)
+		bits := 8 * len(ip.To16())
+		if v4 := ip.To4(); v4 != nil {
+			ip, bits = v4, 32
+		}
+		p.network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	default:
+		if _, err := path.Match(s, ""); err != nil {
+			return p, fmt.Errorf("node pattern %q is not a valid glob: %w", raw, err)
+		}
+		p.glob = s
+	}
+	return p, nil
+}
+
+func (p nodePattern) match(node ResolvedNode) bool {
+	switch {
+	case p.cluster != "":
+		return node.Txt[ClusterTxtKey] == p.cluster
+	case p.network != nil:
+		for _, addr := range node.Addresses {
+			if ip := net.ParseIP(addr); ip != nil && p.network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range []string{node.InstanceName, node.Identity.NodeID} {
+		if name == "" {
+			continue
+		}
+		if ok, _ := path.Match(p.glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
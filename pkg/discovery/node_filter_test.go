@@ -0,0 +1,182 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+func filterTestNode(instance, addr, cluster string) ResolvedNode {
+	node := ResolvedNode{
+		Identity:     NewNodeIdentity("lab", instance),
+		InstanceName: instance,
+		Addresses:    []string{addr},
+		Port:         50051,
+	}
+	if cluster != "" {
+		node.Txt = map[string]string{ClusterTxtKey: cluster}
+	}
+	return node.Normalized()
+}
+
+func TestNodeFilterPatterns(t *testing.T) {
+	gpu := filterTestNode("gpu-1", "10.1.2.3", "prod")
+	cpu := filterTestNode("cpu-1", "192.168.1.7", "staging")
+	v6 := filterTestNode("edge-1", "fd00::7", "")
+
+	tests := []struct {
+		name        string
+		allow, deny []string
+		want        map[string]bool
+	}{
+		{name: "no patterns", want: map[string]bool{"gpu-1": true, "cpu-1": true, "edge-1": true}},
+		{name: "name glob", allow: []string{"gpu-*"}, want: map[string]bool{"gpu-1": true, "cpu-1": false, "edge-1": false}},
+		{name: "node id glob", allow: []string{"edge-?"}, want: map[string]bool{"gpu-1": false, "cpu-1": false, "edge-1": true}},
+		{name: "cidr", allow: []string{"10.1.0.0/16", "fd00::/8"}, want: map[string]bool{"gpu-1": true, "cpu-1": false, "edge-1": true}},
+		{name: "single ip", deny: []string{"192.168.1.7"}, want: map[string]bool{"gpu-1": true, "cpu-1": false, "edge-1": true}},
+		{name: "cluster label", allow: []string{"cluster=prod"}, want: map[string]bool{"gpu-1": true, "cpu-1": false, "edge-1": false}},
+		{name: "deny only", deny: []string{"cluster=staging"}, want: map[string]bool{"gpu-1": true, "cpu-1": false, "edge-1": true}},
+		{
+			name:  "deny wins over allow",
+			allow: []string{"10.0.0.0/8", "cpu-*"},
+			deny:  []string{"gpu-*"},
+			want:  map[string]bool{"gpu-1": false, "cpu-1": true, "edge-1": false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewNodeFilter(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatalf("NewNodeFilter: %v", err)
+			}
+			for _, node := range []ResolvedNode{gpu, cpu, v6} {
+				ok, reason := f.Allows(node)
+				if ok != tt.want[node.InstanceName] {
+					t.Errorf("Allows(%s) = %v (%s), want %v", node.InstanceName, ok, reason, tt.want[node.InstanceName])
+				}
+				if !ok && reason == "" {
+					t.Errorf("Allows(%s) rejected without a reason", node.InstanceName)
+				}
+			}
+		})
+	}
+}
+
+func TestNodeFilterRejectsMalformedPatterns(t *testing.T) {
+	for _, p := range []string{"", "10.0.0.0/33", "gpu-[", "cluster="} {
+		if _, err := NewNodeFilter([]string{p}, nil); err == nil {
+			t.Errorf("NewNodeFilter(%q) succeeded", p)
+		}
+	}
+	var f *NodeFilter
+	if ok, _ := f.Allows(filterTestNode("any", "10.0.0.1", "")); !ok || !f.Empty() {
+		t.Fatal("a nil filter must admit every node")
+	}
+}
+
+func TestNodeFilterUpdateSignalsChange(t *testing.T) {
+	f, err := NewNodeFilter(nil, nil)
+	if err != nil {
+		t.Fatalf("NewNodeFilter: %v", err)
+	}
+	changed := f.Changed()
+	if err := f.Update(nil, []string{"gpu-["}); err == nil {
+		t.Fatal("Update with a malformed pattern succeeded")
+	}
+	select {
+	case <-changed:
+		t.Fatal("a failed Update signalled a change")
+	default:
+	}
+	if err := f.Update(nil, []string{"gpu-*"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatal("Update did not signal a change")
+	}
+	if ok, _ := f.Allows(filterTestNode("gpu-1", "10.0.0.1", "")); ok {
+		t.Fatal("gpu-1 admitted after it was denied")
+	}
+}
+
+func TestMDNSRefilterExpiresNowDeniedNodes(t *testing.T) {
+	f, err := NewNodeFilter(nil, nil)
+	if err != nil {
+		t.Fatalf("NewNodeFilter: %v", err)
+	}
+	r := &MDNSResolver{filter: f, logger: ensureLogger(nil)}
+	gpu := filterTestNode("gpu-1", "10.1.2.3", "prod")
+	cpu := filterTestNode("cpu-1", "192.168.1.7", "staging")
+	known := map[string]*knownNode{gpu.Key(): {resolved: gpu}, cpu.Key(): {resolved: cpu}}
+
+	denied := make(map[string]bool)
+	if !r.admit(gpu, denied) {
+		t.Fatal("gpu-1 rejected by an empty filter")
+	}
+	if err := f.Update(nil, []string{"cluster=staging"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if r.admit(cpu, denied) || !denied[cpu.Key()] {
+		t.Fatal("cpu-1 admitted after its cluster was denied")
+	}
+
+	ch := make(chan NodeEvent, 4)
+	if !r.refilter(context.Background(), ch, known) {
+		t.Fatal("refilter stopped early")
+	}
+	close(ch)
+	var events []NodeEvent
+	for ev := range ch {
+		events = append(events, ev)
+	}
+	if len(events) != 1 || events[0].Type != NodeExpired || !events[0].ExplicitRemove || events[0].Identity.NodeID != "cpu-1" {
+		t.Fatalf("events = %+v, want an explicit removal of cpu-1", events)
+	}
+	if _, ok := known[cpu.Key()]; ok || len(known) != 1 {
+		t.Fatalf("known = %v, want only gpu-1 left", known)
+	}
+}
+
+func TestPushResolverAllowlist(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f, err := NewNodeFilter([]string{"cluster=prod", "gpu-*"}, nil)
+	if err != nil {
+		t.Fatalf("NewNodeFilter: %v", err)
+	}
+	r := NewPushResolver("lab", time.Minute, nil)
+	r.SetFilter(f)
+	ch, err := r.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	if _, err := r.Register(PushRegistration{NodeID: "cpu-1", Address: "10.0.0.9:50051"}); !utils.HasErrorCode(err, utils.ErrCodeForbidden) {
+		t.Fatalf("Register of a node off the allowlist = %v, want FORBIDDEN", err)
+	}
+	for _, reg := range []PushRegistration{
+		{NodeID: "gpu-1", Address: "10.0.0.10:50051"},
+		{NodeID: "cpu-2", Address: "10.0.0.11:50051", Txt: map[string]string{ClusterTxtKey: "prod"}},
+	} {
+		if _, err := r.Register(reg); err != nil {
+			t.Fatalf("Register %s: %v", reg.NodeID, err)
+		}
+	}
+	collectEvents(t, ch, 2)
+
+	if err := f.Update([]string{"gpu-*"}, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	ev := collectEvents(t, ch, 1)[0]
+	if ev.Type != NodeExpired || !ev.ExplicitRemove || ev.Identity.NodeID != "cpu-2" {
+		t.Fatalf("event after narrowing the allowlist = %+v, want cpu-2 removed", ev)
+	}
+	if nodes := r.Nodes(); len(nodes) != 1 || nodes[0].NodeID != "gpu-1" {
+		t.Fatalf("nodes = %+v, want only gpu-1", nodes)
+	}
+}
@@ -0,0 +1,135 @@
+package discovery
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// NodeQuery selects and pages nodes from a node list, as GET /v1/nodes
+// and `lumen-hostd node list` do. Zero fields select every node.
+type NodeQuery struct {
+	// Status matches the node's status exactly.
+	Status NodeStatus `json:"status,omitempty"`
+	// Task matches a node serving the task, versioned and wildcard
+	// advertisements included, as selection matches it.
+	Task string `json:"task,omitempty"`
+	// Runtime matches a node with a service whose runtime contains it,
+	// ignoring case, as CapabilityFilter does.
+	Runtime string `json:"runtime,omitempty"`
+	// Labels must all match the node's metadata; see ParseLabelSelectors.
+	Labels []LabelSelector `json:"labels,omitempty"`
+	// Offset skips that many matching nodes; Limit caps the page, zero
+	// meaning no cap.
+	Offset int `json:"offset,omitempty"`
+	Limit  int `json:"limit,omitempty"`
+}
+
+// LabelSelector matches one metadata key of a node: "key=value" requires
+// the value, "key!=value" any other value or none, and a bare "key" the
+// key's presence. Values compare as their string form.
+type LabelSelector struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	// Op is "=", "!=" or "" for presence.
+	Op string `json:"op,omitempty"`
+}
+
+// ParseLabelSelectors parses comma-separated selectors such as
+// "cluster=lab,gpu,zone!=b".
+func ParseLabelSelectors(s string) ([]LabelSelector, error) {
+	var out []LabelSelector
+	for _, raw := range strings.Split(s, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		var sel LabelSelector
+		switch {
+		case strings.Contains(raw, "!="):
+			sel.Key, sel.Value, _ = strings.Cut(raw, "!=")
+			sel.Op = "!="
+		case strings.Contains(raw, "="):
+			sel.Key, sel.Value, _ = strings.Cut(raw, "=")
+			sel.Op = "="
+		default:
+			sel.Key = raw
+		}
+		sel.Key, sel.Value = strings.TrimSpace(sel.Key), strings.TrimSpace(sel.Value)
+		if sel.Key == "" {
+			return nil, fmt.Errorf("label selector %q names no key", raw)
+		}
+		out = append(out, sel)
+	}
+	return out, nil
+}
+
+func (s LabelSelector) String() string {
+	return s.Key + s.Op + s.Value
+}
+
+// Matches reports whether node's metadata satisfies the selector.
+func (s LabelSelector) Matches(node *NodeInfo) bool {
+	v, ok := node.Metadata[s.Key]
+	switch s.Op {
+	case "=":
+		return ok && fmt.Sprint(v) == s.Value
+	case "!=":
+		return !ok || fmt.Sprint(v) != s.Value
+	}
+	return ok
+}
+
+// Matches reports whether node passes every filter of q; Offset and Limit
+// do not apply.
+func (q NodeQuery) Matches(node *NodeInfo) bool {
+	if node == nil {
+		return false
+	}
+	if q.Status != "" && node.Status != q.Status {
+		return false
+	}
+	if q.Task != "" && !node.SupportsTask(q.Task) {
+		return false
+	}
+	if q.Runtime != "" {
+		filter := CapabilityFilter{Runtime: q.Runtime}
+		if !slices.ContainsFunc(node.Capabilities, filter.Matches) {
+			return false
+		}
+	}
+	for _, sel := range q.Labels {
+		if !sel.Matches(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the page of nodes q selects, in SortNodes order, and how
+// many nodes match in all. nodes itself is left as it is.
+func (q NodeQuery) Apply(nodes []*NodeInfo) (page []*NodeInfo, total int) {
+	matched := make([]*NodeInfo, 0, len(nodes))
+	for _, node := range nodes {
+		if q.Matches(node) {
+			matched = append(matched, node)
+		}
+	}
+	SortNodes(matched)
+	total = len(matched)
+	start := min(max(q.Offset, 0), total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+	return matched[start:end], total
+}
+
+// SortNodes orders nodes by ID, then address, so listings are stable from
+// one call to the next.
+func SortNodes(nodes []*NodeInfo) {
+	slices.SortFunc(nodes, func(a, b *NodeInfo) int {
+		return cmp.Or(cmp.Compare(a.ID, b.ID), cmp.Compare(a.Address, b.Address))
+	})
+}
@@ -0,0 +1,83 @@
+package discovery
+
+import (
+	"fmt"
+	"testing"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+func TestParseLabelSelectors(t *testing.T) {
+	got, err := ParseLabelSelectors(" cluster=lab, gpu ,zone!=b,")
+	if err != nil {
+		t.Fatalf("ParseLabelSelectors: %v", err)
+	}
+	want := []LabelSelector{{Key: "cluster", Op: "=", Value: "lab"}, {Key: "gpu"}, {Key: "zone", Op: "!=", Value: "b"}}
+	if len(got) != len(want) {
+		t.Fatalf("selectors = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("selector %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if _, err := ParseLabelSelectors("=lab"); err == nil {
+		t.Fatal("selector without a key was accepted")
+	}
+}
+
+func TestNodeQueryFiltersAndPages(t *testing.T) {
+	node := func(id string, status NodeStatus, runtime string, meta map[string]interface{}, tasks ...string) *NodeInfo {
+		n := &NodeInfo{ID: id, Status: status, Metadata: meta}
+		for _, task := range tasks {
+			n.Tasks = append(n.Tasks, &pb.IOTask{Name: task})
+		}
+		n.Capabilities = []*pb.Capability{{ServiceName: "svc", Runtime: runtime, Tasks: n.Tasks}}
+		return n
+	}
+	nodes := []*NodeInfo{
+		node("d", NodeStatusActive, "onnxrt-cuda", map[string]interface{}{"zone": "a", "gpu": "true"}, "ocr"),
+		node("b", NodeStatusActive, "onnxrt-cpu", map[string]interface{}{"zone": "b"}, "ocr", "face"),
+		node("a", NodeStatusDraining, "onnxrt-cuda", map[string]interface{}{"zone": "a", "gpu": "true"}, "ocr"),
+		node("c", NodeStatusActive, "onnxrt-cuda", map[string]interface{}{"zone": "a", "gpu": "true"}, "ocr*"),
+		nil,
+	}
+	ids := func(page []*NodeInfo) []string {
+		out := make([]string, 0, len(page))
+		for _, n := range page {
+			out = append(out, n.ID)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		query NodeQuery
+		want  []string
+		total int
+	}{
+		{name: "all sorted", want: []string{"a", "b", "c", "d"}, total: 4},
+		{name: "status", query: NodeQuery{Status: NodeStatusDraining}, want: []string{"a"}, total: 1},
+		{name: "task with wildcard node", query: NodeQuery{Task: "ocr_v2"}, want: []string{"c"}, total: 1},
+		{name: "runtime", query: NodeQuery{Runtime: "CUDA"}, want: []string{"a", "c", "d"}, total: 3},
+		{name: "labels", query: NodeQuery{Labels: []LabelSelector{{Key: "gpu"}, {Key: "zone", Op: "!=", Value: "b"}}}, want: []string{"a", "c", "d"}, total: 3},
+		{
+			name:  "combined and paged",
+			query: NodeQuery{Status: NodeStatusActive, Task: "ocr", Labels: []LabelSelector{{Key: "zone", Op: "=", Value: "a"}}, Offset: 1, Limit: 1},
+			want:  []string{"d"},
+			total: 2,
+		},
+		{name: "offset past the end", query: NodeQuery{Offset: 9, Limit: 2}, want: []string{}, total: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, total := tt.query.Apply(nodes)
+			if got := ids(page); total != tt.total || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("page = %v of %d, want %v of %d", got, total, tt.want, tt.total)
+			}
+		})
+	}
+	if nodes[0].ID != "d" {
+		t.Fatal("Apply reordered its input")
+	}
+}
@@ -0,0 +1,144 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StatusReason says why a node has its status, for programs: log
+// filters, alerts and dashboards match on it. StatusTransition.Detail
+// says more for people.
+type StatusReason string
+
+const (
+	// StatusReasonConnecting: starting, the connection is being set up.
+	StatusReasonConnecting StatusReason = "connecting"
+	// StatusReasonConnected: active, the connection is up and no health
+	// check has said otherwise.
+	StatusReasonConnected StatusReason = "connected"
+	// StatusReasonConnectionFailed: error, the node cannot be dialled.
+	StatusReasonConnectionFailed StatusReason = "connection_failed"
+	// StatusReasonConnectionLost: error, an established connection dropped.
+	StatusReasonConnectionLost StatusReason = "connection_lost"
+	// StatusReasonConnectionClosed: unknown, the pool shut the node's
+	// connection down, as it does when it closes.
+	StatusReasonConnectionClosed StatusReason = "connection_closed"
+	// StatusReasonConnectionRestored: active again once the connection
+	// that dropped came back.
+	StatusReasonConnectionRestored StatusReason = "connection_restored"
+	// StatusReasonHealthCheckFailed: error after failed health checks in a
+	// row.
+	StatusReasonHealthCheckFailed StatusReason = "health_check_failed"
+	// StatusReasonHealthCheckPassed: active again after passed health
+	// checks in a row.
+	StatusReasonHealthCheckPassed StatusReason = "health_check_passed"
+	// StatusReasonFlapping: suspect, the node went to error too often
+	// within the flap window.
+	StatusReasonFlapping StatusReason = "flapping"
+	// StatusReasonSuspectElapsed: error once a suspect node's time is up
+	// without enough passed checks.
+	StatusReasonSuspectElapsed StatusReason = "suspect_time_elapsed"
+	// StatusReasonCapabilityFetchFailed: quarantined, capability fetches
+	// keep failing.
+	StatusReasonCapabilityFetchFailed StatusReason = "capability_fetch_failed"
+	// StatusReasonCapabilitiesUnconfirmed: provisional, the node is known
+	// only by its TXT hints.
+	StatusReasonCapabilitiesUnconfirmed StatusReason = "capabilities_unconfirmed"
+	// StatusReasonStaleServices: degraded, some services were not
+	// refreshed by the last capability fetch.
+	StatusReasonStaleServices StatusReason = "stale_services"
+	// StatusReasonOutlierEjected: ejected by outlier detection.
+	StatusReasonOutlierEjected StatusReason = "outlier_ejected"
+	// StatusReasonOperatorDrain: draining, an operator called DrainNode.
+	StatusReasonOperatorDrain StatusReason = "operator_drain"
+)
+
+// NodeStatusChanged reports a node's move from one status to another.
+type NodeStatusChanged struct {
+	NodeID string `json:"node_id"`
+	StatusTransition
+}
+
+// StatusHistoryLen is how many transitions a NodeState keeps.
+const StatusHistoryLen = 8
+
+// ErrIllegalStatusTransition is returned by NodeState.TransitionTo for a
+// move CanTransition rejects.
+var ErrIllegalStatusTransition = errors.New("illegal node status transition")
+
+// knownStatuses are the statuses a node can have.
+var knownStatuses = map[NodeStatus]bool{
+	NodeStatusUnknown:     true,
+	NodeStatusStarting:    true,
+	NodeStatusProvisional: true,
+	NodeStatusActive:      true,
+	NodeStatusDegraded:    true,
+	NodeStatusSuspect:     true,
+	NodeStatusError:       true,
+	NodeStatusQuarantined: true,
+	NodeStatusDraining:    true,
+	NodeStatusEjected:     true,
+}
+
+// CanTransition reports whether a node may move from status from to
+// status to. A status is derived from independent layers (connection,
+// health checks, capability fetches, outlier detection, drains), any of
+// which can change at any time, so most moves are legal. A known node goes
+// back to unknown when the pool shuts its connection down. The illegal
+// moves are:
+//   - any move from or to a status that is not a NodeStatus constant;
+//   - unknown to suspect: only a node with a history of health checks can
+//     be flapping.
+//
+// Staying in the same status is not a move and is always allowed.
+func CanTransition(from, to NodeStatus) bool {
+	if !knownStatuses[from] || !knownStatuses[to] {
+		return false
+	}
+	switch {
+	case from == to:
+		return true
+	case from == NodeStatusUnknown && to == NodeStatusSuspect:
+		return false
+	}
+	return true
+}
+
+// NodeState is a node's status, why it has it and since when, with the
+// transitions that led there. The zero value is a node of unknown status.
+// Change it only through TransitionTo, which keeps the fields consistent.
+type NodeState struct {
+	Status NodeStatus   `json:"status"`
+	Reason StatusReason `json:"reason,omitempty"`
+	Since  time.Time    `json:"since,omitempty"`
+	// History holds the latest transitions, oldest first, at most
+	// StatusHistoryLen.
+	History []StatusTransition `json:"history,omitempty"`
+}
+
+// TransitionTo moves the state to status to at time at, for reason, and
+// records the transition in History. It returns the transition, or nil
+// when the status is already to; Reason and Since then stay those of the
+// move that got there. A move CanTransition rejects leaves the state as it
+// was and returns an error wrapping ErrIllegalStatusTransition.
+func (s *NodeState) TransitionTo(to NodeStatus, reason StatusReason, detail string, at time.Time) (*StatusTransition, error) {
+	from := s.Status
+	if from == "" {
+		from = NodeStatusUnknown
+	}
+	if !CanTransition(from, to) {
+		return nil, fmt.Errorf("%w: %s to %s (%s)", ErrIllegalStatusTransition, from, to, reason)
+	}
+	if from == to {
+		s.Status = to
+		return nil, nil
+	}
+	t := StatusTransition{From: from, To: to, At: at, Reason: reason, Detail: detail}
+	s.Status, s.Reason, s.Since = to, reason, at
+	s.History = append(s.History, t)
+	if len(s.History) > StatusHistoryLen {
+		s.History = s.History[len(s.History)-StatusHistoryLen:]
+	}
+	return &t, nil
+}
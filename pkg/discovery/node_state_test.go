@@ -0,0 +1,83 @@
+package discovery
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestCanTransition checks every move between statuses, the unknown ones
+// included, against the list of legal moves.
+func TestCanTransition(t *testing.T) {
+	statuses := []NodeStatus{
+		NodeStatusUnknown, NodeStatusStarting, NodeStatusProvisional, NodeStatusActive, NodeStatusDegraded,
+		NodeStatusSuspect, NodeStatusError, NodeStatusQuarantined, NodeStatusDraining, NodeStatusEjected,
+		"", "bogus",
+	}
+	if len(statuses) != len(knownStatuses)+2 {
+		t.Fatalf("the test lists %d statuses, the package knows %d", len(statuses)-2, len(knownStatuses))
+	}
+	// illegal lists, per known status, the known statuses it may not move to.
+	illegal := map[NodeStatus][]NodeStatus{
+		NodeStatusUnknown: {NodeStatusSuspect},
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := knownStatuses[from] && knownStatuses[to]
+			for _, bad := range illegal[from] {
+				if to == bad {
+					want = false
+				}
+			}
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%q, %q) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestNodeStateTransitionTo(t *testing.T) {
+	start := time.Now()
+	var s NodeState
+
+	tr, err := s.TransitionTo(NodeStatusStarting, StatusReasonConnecting, "", start)
+	if err != nil || tr == nil || tr.From != NodeStatusUnknown || tr.To != NodeStatusStarting {
+		t.Fatalf("first transition = %+v, %v; want unknown to starting", tr, err)
+	}
+	tr, err = s.TransitionTo(NodeStatusActive, StatusReasonConnected, "", start.Add(time.Second))
+	if err != nil || tr == nil || s.Status != NodeStatusActive || s.Reason != StatusReasonConnected || !s.Since.Equal(start.Add(time.Second)) {
+		t.Fatalf("to active: %+v, %v; state %+v", tr, err, s)
+	}
+
+	// Staying put is no transition and keeps when and why the node got there.
+	tr, err = s.TransitionTo(NodeStatusActive, StatusReasonHealthCheckPassed, "", start.Add(time.Minute))
+	if err != nil || tr != nil || s.Reason != StatusReasonConnected || !s.Since.Equal(start.Add(time.Second)) || len(s.History) != 2 {
+		t.Fatalf("same status: %+v, %v; state %+v", tr, err, s)
+	}
+
+	// An illegal move changes nothing.
+	var fresh NodeState
+	tr, err = fresh.TransitionTo(NodeStatusSuspect, StatusReasonFlapping, "", start)
+	if !errors.Is(err, ErrIllegalStatusTransition) || tr != nil || fresh.Status != "" || len(fresh.History) != 0 {
+		t.Fatalf("illegal move: %+v, %v; state %+v", tr, err, fresh)
+	}
+	if _, err := s.TransitionTo("bogus", "", "", start); !errors.Is(err, ErrIllegalStatusTransition) {
+		t.Fatalf("move to an unknown status: error = %v", err)
+	}
+
+	// History keeps the latest StatusHistoryLen transitions, oldest first.
+	at := start.Add(2 * time.Second)
+	for i := 0; i < StatusHistoryLen; i++ {
+		s.TransitionTo(NodeStatusError, StatusReasonHealthCheckFailed, "3 failed checks", at)
+		at = at.Add(time.Second)
+		s.TransitionTo(NodeStatusActive, StatusReasonHealthCheckPassed, "", at)
+		at = at.Add(time.Second)
+	}
+	if len(s.History) != StatusHistoryLen {
+		t.Fatalf("history holds %d transitions, want %d", len(s.History), StatusHistoryLen)
+	}
+	first, last := s.History[0], s.History[len(s.History)-1]
+	if first.To != NodeStatusError || first.Detail != "3 failed checks" || last.To != NodeStatusActive || !last.At.Equal(s.Since) {
+		t.Fatalf("history runs from %+v to %+v", first, last)
+	}
+}
@@ -120,7 +120,64 @@ func (n ResolvedNode) Runtime() string {
 }
 
 func (n ResolvedNode) HintTasks() []string {
-	return splitCSV(n.Txt["tasks"])
+	return ParseCapabilityHints(n.Txt).Tasks
+}
+
+// AdvertisedNodeID returns the stable node ID a node publishes in its
+// "node_id" (or "uuid") TXT record, or "" when it publishes none.
+func AdvertisedNodeID(txt map[string]string) string {
+	if id := strings.TrimSpace(txt["node_id"]); id != "" {
+		return id
+	}
+	return strings.TrimSpace(txt["uuid"])
+}
+
+// NodeAddressChanged reports that a node kept its identity but moved to a
+// new endpoint, e.g. after a DHCP lease change. The pool migrates the
+// connection in place, so state keyed by node ID carries over.
+type NodeAddressChanged struct {
+	NodeID     string    `json:"node_id"`
+	OldAddress string    `json:"old_address"`
+	NewAddress string    `json:"new_address"`
+	At         time.Time `json:"at"`
+}
+
+// NodeDrained reports that a drained node has no request in flight, so it
+// can be stopped without failing any.
+type NodeDrained struct {
+	NodeID string    `json:"node_id"`
+	Since  time.Time `json:"since"`
+	At     time.Time `json:"at"`
+}
+
+// Outlier ejection reasons, NodeEjection.Reason.
+const (
+	EjectedForErrorRate = "error_rate"
+	EjectedForLatency   = "latency"
+)
+
+// NodeEjection describes a node outlier detection ejected from selection
+// for answering far worse than the rest of the cluster. It is reported when
+// the node is ejected and again, with Ejected false, when re-admission
+// starts; the node then takes a growing share of requests until RampUntil.
+type NodeEjection struct {
+	NodeID  string `json:"node_id"`
+	Ejected bool   `json:"ejected"`
+	// Reason is EjectedForErrorRate or EjectedForLatency.
+	Reason string `json:"reason"`
+	// ErrorRate and Latency (median) are the node's over the detection
+	// window that got it ejected, next to the cluster medians.
+	ErrorRate       float64       `json:"error_rate"`
+	MedianErrorRate float64       `json:"median_error_rate"`
+	Latency         time.Duration `json:"latency_ns"`
+	MedianLatency   time.Duration `json:"median_latency_ns"`
+	// Count is the number of consecutive ejections, which doubles Until's
+	// distance from Since each time.
+	Count     int       `json:"count"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+	RampUntil time.Time `json:"ramp_until,omitempty"`
+	At        time.Time `json:"at"`
 }
 
 // NodeAvailability describes operational-session availability. It is more
@@ -150,27 +207,6 @@ func (a NodeAvailability) NodeStatus() NodeStatus {
 	}
 }
 
-func splitCSV(raw string) []string {
-	if raw == "" {
-		return nil
-	}
-	parts := strings.Split(raw, ",")
-	out := make([]string, 0, len(parts))
-	seen := make(map[string]struct{}, len(parts))
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-		if _, ok := seen[part]; ok {
-			continue
-		}
-		seen[part] = struct{}{}
-		out = append(out, part)
-	}
-	return out
-}
-
 func normalizeAddresses(addresses []string) []string {
 	seen := make(map[string]struct{}, len(addresses))
 	out := make([]string, 0, len(addresses))
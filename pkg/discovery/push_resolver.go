@@ -0,0 +1,394 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils/netaddr"
+	"go.uber.org/zap"
+)
+
+// Txt keys set on pushed nodes.
+const (
+	// SourceTxtKey names the backend a node came from; pushed nodes carry
+	// SourcePush.
+	SourceTxtKey = "source"
+	// LoadTxtKey is the node's last pushed load, in [0, 1].
+	LoadTxtKey = "load"
+
+	SourcePush = "push"
+)
+
+// PushRegistration is what a node announces when it registers itself.
+type PushRegistration struct {
+	// NodeID identifies the node within the resolver's deployment. A node
+	// registering again under the same ID replaces its registration.
+	NodeID string `json:"node_id"`
+	// Address is the node's gRPC endpoint, "host:port".
+	Address string   `json:"address"`
+	Tasks   []string `json:"tasks,omitempty"`
+	// Txt carries the same hints as an mDNS TXT record, e.g. "v" and
+	// "runtime".
+	Txt  map[string]string `json:"txt,omitempty"`
+	Load *float64          `json:"load,omitempty"`
+}
+
+// PushedNode is a node registered with a PushResolver.
+type PushedNode struct {
+	NodeID        string    `json:"node_id"`
+	Key           string    `json:"key"`
+	Address       string    `json:"address"`
+	Tasks         []string  `json:"tasks,omitempty"`
+	Load          *float64  `json:"load,omitempty"`
+	RegisteredAt  time.Time `json:"registered_at"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+	// ExpiresAt is when the node is dropped unless it sends a heartbeat or
+	// an update first.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type pushedNode struct {
+	resolved      ResolvedNode
+	tasks         []string
+	load          *float64
+	registeredAt  time.Time
+	lastHeartbeat time.Time
+}
+
+// PushResolver is the NodeResolver for nodes that register themselves,
+// e.g. through a Host Broker's /v1/push routes, where multicast is not
+// available. Its events feed the same pool as mDNS and static nodes.
+//
+// Pushed nodes are never expired by discovery TTLs: heartbeats are their
+// liveness. Any call from a node counts as a heartbeat, and a node silent
+// for longer than the heartbeat timeout is removed as if it deregistered.
+//
+// An allowlist set with SetFilter rejects registrations it does not admit.
+type PushResolver struct {
+	deploymentID string
+	timeout      time.Duration
+	filter       *NodeFilter
+	logger       *zap.Logger
+	clock        utils.Clock
+
+	mu       sync.Mutex
+	nodes    map[string]*pushedNode // keyed by NodeID
+	watchers map[chan NodeEvent]struct{}
+}
+
+// NewPushResolver creates a resolver whose nodes expire after missing
+// heartbeats for timeout.
+func NewPushResolver(deploymentID string, timeout time.Duration, logger *zap.Logger) *PushResolver {
+	if deploymentID == "" {
+		deploymentID = DefaultDeploymentID
+	}
+	return &PushResolver{
+		deploymentID: deploymentID,
+		timeout:      timeout,
+		logger:       ensureLogger(logger),
+		clock:        utils.RealClock,
+		nodes:        make(map[string]*pushedNode),
+		watchers:     make(map[chan NodeEvent]struct{}),
+	}
+}
+
+// Watch emits a NodeDiscovered event for every registered node, then one
+// per registration or update, a NodeRefreshed event per heartbeat and a
+// NodeExpired event per node that deregisters or misses its heartbeats,
+// until ctx is cancelled.
+func (r *PushResolver) Watch(ctx context.Context) (<-chan NodeEvent, error) {
+	ch := make(chan NodeEvent, 64)
+	r.mu.Lock()
+	for _, node := range r.nodes {
+		r.sendLocked(ch, eventFromResolved(NodeDiscovered, node.resolved))
+	}
+	r.watchers[ch] = struct{}{}
+	r.mu.Unlock()
+
+	filterChanged := r.filter.Changed()
+	go func() {
+		sweep := r.clock.NewTicker(r.sweepInterval())
+		defer sweep.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				r.mu.Lock()
+				delete(r.watchers, ch)
+				close(ch)
+				r.mu.Unlock()
+				return
+			case now := <-sweep.C():
+				r.expire(now)
+			case <-filterChanged:
+				filterChanged = r.filter.Changed()
+				r.refilter()
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// SetClock sets the clock heartbeats are timed and expiry sweeps run on;
+// nil is the wall clock. Call it before Watch and Register.
+func (r *PushResolver) SetClock(clock utils.Clock) {
+	r.clock = utils.ClockOrReal(clock)
+}
+
+// SetFilter makes Register reject nodes f does not admit. When f is updated,
+// registered nodes it now rejects are removed. Call it before Watch.
+func (r *PushResolver) SetFilter(f *NodeFilter) {
+	r.filter = f
+}
+
+// refilter removes the registered nodes the filter now rejects.
+func (r *PushResolver) refilter() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, node := range r.nodes {
+		if ok, reason := r.filter.Allows(node.resolved); !ok {
+			r.removeLocked(id, node, "discovery filter: "+reason)
+		}
+	}
+}
+
+func (r *PushResolver) sweepInterval() time.Duration {
+	return max(r.timeout/4, 10*time.Millisecond)
+}
+
+// Register adds or replaces the node's registration and returns its pool
+// key. An invalid registration is rejected with an INVALID error, and one
+// the filter does not admit with a FORBIDDEN error.
+func (r *PushResolver) Register(reg PushRegistration) (string, error) {
+	nodeID := strings.TrimSpace(reg.NodeID)
+	if nodeID == "" {
+		return "", utils.InvalidError("node_id is required")
+	}
+	addr, err := netaddr.ParseHostPort(reg.Address, 0)
+	if err != nil {
+		return "", utils.InvalidError(err.Error())
+	}
+	if err := validateLoad(reg.Load); err != nil {
+		return "", err
+	}
+
+	txt := make(map[string]string, len(reg.Txt)+2)
+	for k, v := range reg.Txt {
+		txt[k] = v
+	}
+	txt[SourceTxtKey] = SourcePush
+	now := r.clock.Now()
+	node := &pushedNode{
+		resolved: ResolvedNode{
+			Identity:     NewNodeIdentity(r.deploymentID, nodeID),
+			InstanceName: nodeID,
+			Addresses:    []string{addr.Host},
+			Port:         addr.Port,
+			Txt:          txt,
+		}.Normalized(),
+		tasks:         mergeSorted(nil, reg.Tasks),
+		load:          reg.Load,
+		registeredAt:  now,
+		lastHeartbeat: now,
+	}
+	node.syncTxt()
+	if ok, reason := r.filter.Allows(node.resolved); !ok {
+		r.logger.Debug("push registration rejected by discovery filter",
+			zap.String("id", node.resolved.Key()),
+			zap.String("reason", reason),
+		)
+		return "", utils.ForbiddenError(fmt.Sprintf("node %s is not allowed to register: %s", nodeID, reason))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[nodeID] = node
+	r.broadcastLocked(eventFromResolved(NodeDiscovered, node.resolved))
+	r.logger.Info("node registered by push",
+		zap.String("id", node.resolved.Key()),
+		zap.String("address", reg.Address),
+		zap.Strings("tasks", node.tasks),
+	)
+	return node.resolved.Key(), nil
+}
+
+// Heartbeat extends the node's registration. It fails with NODE_NOT_FOUND
+// when the node is not registered, e.g. after it expired; the node should
+// register again.
+func (r *PushResolver) Heartbeat(nodeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, err := r.nodeLocked(nodeID)
+	if err != nil {
+		return err
+	}
+	node.lastHeartbeat = r.clock.Now()
+	r.broadcastLocked(NodeEvent{Type: NodeRefreshed, Identity: node.resolved.Identity})
+	return nil
+}
+
+// UpdateLoad records the node's load, in [0, 1], and counts as a
+// heartbeat.
+func (r *PushResolver) UpdateLoad(nodeID string, load float64) error {
+	if err := validateLoad(&load); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, err := r.nodeLocked(nodeID)
+	if err != nil {
+		return err
+	}
+	node.lastHeartbeat = r.clock.Now()
+	node.load = &load
+	node.syncTxt()
+	r.broadcastLocked(eventFromResolved(NodeDiscovered, node.resolved))
+	return nil
+}
+
+// UpdateCapabilities applies the task changes of diff to the node's
+// advertised tasks and counts as a heartbeat. Other fields of diff are
+// ignored: the pool still fetches full capabilities from the node itself.
+func (r *PushResolver) UpdateCapabilities(nodeID string, diff CapabilityDiff) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, err := r.nodeLocked(nodeID)
+	if err != nil {
+		return err
+	}
+	node.lastHeartbeat = r.clock.Now()
+	removed := make(map[string]bool, len(diff.TasksRemoved))
+	for _, task := range diff.TasksRemoved {
+		removed[task] = true
+	}
+	tasks := node.tasks[:0:0]
+	for _, task := range node.tasks {
+		if !removed[task] {
+			tasks = append(tasks, task)
+		}
+	}
+	node.tasks = mergeSorted(tasks, diff.TasksAdded)
+	node.syncTxt()
+	r.broadcastLocked(eventFromResolved(NodeDiscovered, node.resolved))
+	return nil
+}
+
+// Deregister removes the node at once.
+func (r *PushResolver) Deregister(nodeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	node, err := r.nodeLocked(nodeID)
+	if err != nil {
+		return err
+	}
+	r.removeLocked(nodeID, node, "deregistered")
+	return nil
+}
+
+// Nodes lists the registered nodes, sorted by NodeID.
+func (r *PushResolver) Nodes() []PushedNode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]PushedNode, 0, len(r.nodes))
+	for id, node := range r.nodes {
+		out = append(out, PushedNode{
+			NodeID:        id,
+			Key:           node.resolved.Key(),
+			Address:       node.resolved.Endpoint(),
+			Tasks:         append([]string(nil), node.tasks...),
+			Load:          node.load,
+			RegisteredAt:  node.registeredAt,
+			LastHeartbeat: node.lastHeartbeat,
+			ExpiresAt:     node.lastHeartbeat.Add(r.timeout),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NodeID < out[j].NodeID })
+	return out
+}
+
+// expire removes the nodes whose last heartbeat is older than the timeout.
+func (r *PushResolver) expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, node := range r.nodes {
+		if now.Sub(node.lastHeartbeat) > r.timeout {
+			r.removeLocked(id, node, "missed heartbeats")
+		}
+	}
+}
+
+func (r *PushResolver) removeLocked(nodeID string, node *pushedNode, reason string) {
+	delete(r.nodes, nodeID)
+	ev := eventFromResolved(NodeExpired, node.resolved)
+	ev.ExplicitRemove = true
+	r.broadcastLocked(ev)
+	r.logger.Info("pushed node removed", zap.String("id", node.resolved.Key()), zap.String("reason", reason))
+}
+
+func (r *PushResolver) nodeLocked(nodeID string) (*pushedNode, error) {
+	node, ok := r.nodes[strings.TrimSpace(nodeID)]
+	if !ok {
+		return nil, utils.NodeNotFoundError(nodeID)
+	}
+	return node, nil
+}
+
+func (r *PushResolver) broadcastLocked(ev NodeEvent) {
+	for ch := range r.watchers {
+		r.sendLocked(ch, ev)
+	}
+}
+
+// sendLocked delivers ev without blocking callers of the push API on a
+// slow watcher; an event that does not fit is dropped and logged.
+func (r *PushResolver) sendLocked(ch chan NodeEvent, ev NodeEvent) {
+	ev.Source = SourcePush
+	select {
+	case ch <- ev:
+	default:
+		r.logger.Warn("push discovery watcher is behind; dropping event", zap.String("id", ev.Identity.Key()))
+	}
+}
+
+// syncTxt writes the node's tasks and load into its Txt, where the pool
+// reads them like any other discovery hint.
+func (n *pushedNode) syncTxt() {
+	txt := make(map[string]string, len(n.resolved.Txt))
+	for k, v := range n.resolved.Txt {
+		txt[k] = v
+	}
+	txt["tasks"] = strings.Join(n.tasks, ",")
+	delete(txt, LoadTxtKey)
+	if n.load != nil {
+		txt[LoadTxtKey] = strconv.FormatFloat(*n.load, 'f', -1, 64)
+	}
+	n.resolved.Txt = txt
+}
+
+func validateLoad(load *float64) error {
+	if load != nil && !(*load >= 0 && *load <= 1) {
+		return utils.InvalidError(fmt.Sprintf("load must be between 0 and 1, got %g", *load))
+	}
+	return nil
+}
+
+// mergeSorted returns the sorted union of a and b without blanks.
+func mergeSorted(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, list := range [][]string{a, b} {
+		for _, s := range list {
+			if s = strings.TrimSpace(s); s != "" && !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,144 @@
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+func TestPushResolverRegistersAndUpdates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewPushResolver("lab", time.Minute, nil)
+	ch, err := r.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	load := 0.25
+	key, err := r.Register(PushRegistration{NodeID: "gpu-1", Address: "10.0.0.9:50051", Tasks: []string{"ocr"}, Txt: map[string]string{"v": "1.2.0"}, Load: &load})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if key != "lab-gpu-1" {
+		t.Fatalf("key = %q, want lab-gpu-1", key)
+	}
+	ev := collectEvents(t, ch, 1)[0]
+	if ev.Type != NodeDiscovered || ev.Addresses[0] != "10.0.0.9:50051" {
+		t.Fatalf("registration event = %+v", ev)
+	}
+	if ev.Txt[SourceTxtKey] != SourcePush || ev.Txt[LoadTxtKey] != "0.25" || ev.Txt["v"] != "1.2.0" {
+		t.Fatalf("txt = %v, want source, load and the node's own hints", ev.Txt)
+	}
+
+	if err := r.UpdateCapabilities("gpu-1", CapabilityDiff{TasksAdded: []string{"face_detect"}, TasksRemoved: []string{"ocr"}}); err != nil {
+		t.Fatalf("UpdateCapabilities: %v", err)
+	}
+	if ev := collectEvents(t, ch, 1)[0]; !reflect.DeepEqual(ev.Tasks, []string{"face_detect"}) {
+		t.Fatalf("tasks after diff = %v, want [face_detect]", ev.Tasks)
+	}
+
+	if err := r.UpdateLoad("gpu-1", 1.5); !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+		t.Fatalf("UpdateLoad(1.5) = %v, want INVALID", err)
+	}
+	if err := r.Heartbeat("gpu-2"); !utils.HasErrorCode(err, utils.ErrCodeNodeNotFound) {
+		t.Fatalf("Heartbeat of unknown node = %v, want NODE_NOT_FOUND", err)
+	}
+
+	if err := r.Deregister("gpu-1"); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+	if ev := collectEvents(t, ch, 1)[0]; ev.Type != NodeExpired || !ev.ExplicitRemove {
+		t.Fatalf("deregistration event = %+v, want an explicit removal", ev)
+	}
+	if nodes := r.Nodes(); len(nodes) != 0 {
+		t.Fatalf("nodes after deregistration = %+v", nodes)
+	}
+}
+
+func TestPushResolverExpiresSilentNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := utils.NewFakeClock(time.Unix(0, 0))
+	r := NewPushResolver("", time.Minute, nil)
+	r.SetClock(clock)
+	ch, err := r.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	for _, id := range []string{"alive", "silent"} {
+		if _, err := r.Register(PushRegistration{NodeID: id, Address: "10.0.0.9:50051"}); err != nil {
+			t.Fatalf("Register %s: %v", id, err)
+		}
+	}
+	collectEvents(t, ch, 2)
+
+	// The sweep runs on its own goroutine: keep the clock moving, one sweep
+	// interval at a time, until it removes the silent node.
+	clock.BlockUntil(1)
+	for i := 0; len(r.Nodes()) == 2; i++ {
+		if i == 100 {
+			t.Fatalf("silent node still registered %s after its last heartbeat", clock.Now().Sub(time.Unix(0, 0)))
+		}
+		clock.Advance(r.sweepInterval())
+		if err := r.Heartbeat("alive"); err != nil {
+			t.Fatalf("Heartbeat: %v", err)
+		}
+		if i < 3 && len(r.Nodes()) != 2 {
+			t.Fatalf("silent node removed %s after its last heartbeat, within the timeout", clock.Now().Sub(time.Unix(0, 0)))
+		}
+	}
+
+	ev := collectEvents(t, ch, 1)[0]
+	for ev.Type == NodeRefreshed {
+		ev = collectEvents(t, ch, 1)[0]
+	}
+	if ev.Type != NodeExpired || !ev.ExplicitRemove || ev.Identity.NodeID != "silent" {
+		t.Fatalf("expiry event = %+v, want silent removed", ev)
+	}
+	if nodes := r.Nodes(); len(nodes) != 1 || nodes[0].NodeID != "alive" {
+		t.Fatalf("nodes = %+v, want only the node that kept sending heartbeats", nodes)
+	}
+}
+
+func TestPushResolverHeartbeatRefreshesNode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := NewPushResolver("", time.Minute, nil)
+	ch, err := r.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if _, err := r.Register(PushRegistration{NodeID: "gpu-1", Address: "10.0.0.9:50051"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	collectEvents(t, ch, 1)
+	if err := r.Heartbeat("gpu-1"); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	ev := collectEvents(t, ch, 1)[0]
+	if ev.Type != NodeRefreshed || ev.Identity.NodeID != "gpu-1" || ev.Source != SourcePush {
+		t.Fatalf("heartbeat event = %+v, want gpu-1 refreshed by push", ev)
+	}
+}
+
+func TestPushResolverRejectsInvalidRegistrations(t *testing.T) {
+	r := NewPushResolver("", time.Minute, nil)
+	bad := -0.1
+	for _, reg := range []PushRegistration{
+		{Address: "10.0.0.9:50051"},
+		{NodeID: "a", Address: "10.0.0.9"},
+		{NodeID: "a", Address: "10.0.0.9:0"},
+		{NodeID: "a", Address: "10.0.0.9:50051", Load: &bad},
+	} {
+		if _, err := r.Register(reg); !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+			t.Errorf("Register(%+v) = %v, want INVALID", reg, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package discovery
+
+import "time"
+
+// QueueItemState is where a request added with EnqueueInfer is in the
+// durable queue.
+type QueueItemState string
+
+const (
+	// QueuePending: waiting for a node, or for its next attempt.
+	QueuePending QueueItemState = "pending"
+	// QueueCompleted: a node answered; the result waits to be delivered.
+	QueueCompleted QueueItemState = "completed"
+	// QueueDead: every attempt failed; the request waits for a retry.
+	QueueDead QueueItemState = "dead"
+)
+
+// QueueItem describes one request in the durable queue.
+type QueueItem struct {
+	ID            string         `json:"id"`
+	CorrelationID string         `json:"correlation_id"`
+	Task          string         `json:"task"`
+	Topic         string         `json:"topic"`
+	State         QueueItemState `json:"state"`
+	// Attempts counts the failed attempts since the request was queued or
+	// last retried from the dead letters.
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	// Bytes is the size of the queued request on disk, and of its
+	// response once one is stored.
+	Bytes         int64     `json:"bytes"`
+	EnqueuedAt    time.Time `json:"enqueued_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+// QueueStatus describes the durable queue. The counters run since the
+// client started; the rest reflects the queue on disk.
+type QueueStatus struct {
+	Enabled bool `json:"enabled"`
+	// Depth counts requests not yet answered: pending and in flight.
+	Depth    int `json:"depth"`
+	InFlight int `json:"in_flight"`
+	// Undelivered counts answered requests whose result has not reached a
+	// handler or the results directory.
+	Undelivered int   `json:"undelivered"`
+	Dead        int   `json:"dead"`
+	Bytes       int64 `json:"bytes"`
+	MaxBytes    int64 `json:"max_bytes"`
+
+	Dispatched   int64 `json:"dispatched"`
+	Completed    int64 `json:"completed"`
+	Failed       int64 `json:"failed"`
+	DeadLettered int64 `json:"dead_lettered"`
+	// DispatchRate is completed requests per second over the last minute.
+	DispatchRate float64 `json:"dispatch_rate"`
+
+	// DeadLetters lists the dead requests, oldest first.
+	DeadLetters []QueueItem `json:"dead_letters,omitempty"`
+}
@@ -0,0 +1,13 @@
+package discovery
+
+// TaskReadiness is whether enough nodes can take requests for a task.
+// Healthy counts the nodes a request could be routed to now: Ready, not
+// cooling down, held, draining or ejected. Required is the task's minimum
+// (pool.min_healthy_nodes, at least 1), and Ready reports Healthy reaching
+// it.
+type TaskReadiness struct {
+	Task     string `json:"task"`
+	Healthy  int    `json:"healthy"`
+	Required int    `json:"required"`
+	Ready    bool   `json:"ready"`
+}
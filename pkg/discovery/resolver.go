@@ -15,7 +15,8 @@ const (
 	NodeDiscovered    NodeEventType = iota // a service instance or resolved address was discovered
 	NodeExpired                            // DNS-SD TTL expired or a push backend explicitly revoked the node
 	NodeResolveFailed                      // address resolution failed; this is not a liveness verdict
-
+	NodeRefreshed                          // the backend reconfirmed a node (or, with no Identity, all of its nodes) unchanged
+	ScanCompleted                          // a polling backend finished a scan; see CatalogFreshness.LastScan
 )
 
 // NodeEvent carries a single operational discovery notification.
@@ -29,6 +30,10 @@ type NodeEvent struct {
 	Txt       map[string]string // TXT key/value records
 	Err       error             // set when Type is NodeResolveFailed
 
+	// Source is the backend that emitted the event: SourceMDNS,
+	// SourceBroker, SourcePush or SourceStatic.
+	Source string
+
 	// ExplicitRemove is true when the producer knows the node should be
 	// removed, such as a Broker "removed" event. mDNS TTL expiry should leave
 	// this false because stale DNS-SD records are not liveness proof.
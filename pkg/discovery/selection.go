@@ -0,0 +1,71 @@
+package discovery
+
+import "time"
+
+// SelectionReason says why a node was not eligible to serve a request.
+type SelectionReason string
+
+const (
+	SelectionNotReady        SelectionReason = "not_ready"        // connection is not Ready
+	SelectionUnsupportedTask SelectionReason = "unsupported_task" // node does not advertise the task
+	SelectionCoolingDown     SelectionReason = "cooling_down"     // repeated failures; cooldown has not expired
+	SelectionProbeSkipped    SelectionReason = "probe_skipped"    // not Ready, and only probed when no Ready node qualifies
+	SelectionDraining        SelectionReason = "draining"         // drained; takes no new requests
+	SelectionExcluded        SelectionReason = "excluded"         // given a negative score by the custom strategy's scorer
+	SelectionEjected         SelectionReason = "ejected"          // ejected by outlier detection for answering far worse than the cluster
+	SelectionUnhealthy       SelectionReason = "unhealthy"        // in error or suspect after failing health checks; only probed when no healthy node qualifies
+	SelectionProvisional     SelectionReason = "provisional"      // capabilities not fetched yet, and discovery.provisional_traffic is off
+)
+
+// SelectionExplanation describes how the client would route a request for
+// Task right now, without dispatching one: every known node with the reason
+// it was passed over, and the node the next request would go to.
+type SelectionExplanation struct {
+	Task string    `json:"task"`
+	At   time.Time `json:"at"`
+	// Strategy names how the pick is made among eligible nodes.
+	Strategy   string               `json:"strategy"`
+	Candidates []SelectionCandidate `json:"candidates"`
+	// Pick is the node ID the next request would go to; empty when no node
+	// qualifies, in which case Error says what the request would fail with.
+	Pick string `json:"pick,omitempty"`
+	// Probe is true when no Ready node qualified and Pick is a node whose
+	// cooldown expired, tried again to see whether it recovered.
+	Probe bool   `json:"probe,omitempty"`
+	Error string `json:"error,omitempty"`
+	// LastPick is the latest request actually routed for Task, if any.
+	LastPick *SelectionDecision `json:"last_pick,omitempty"`
+}
+
+// SelectionDecision records where one request was routed.
+type SelectionDecision struct {
+	Task     string    `json:"task"`
+	At       time.Time `json:"at"`
+	Strategy string    `json:"strategy"`
+	NodeID   string    `json:"node_id"`
+	// Candidates is the number of nodes eligible for the request.
+	Candidates int `json:"candidates"`
+	// Probe is true when no Ready node qualified and NodeID was tried
+	// after its cooldown expired.
+	Probe bool `json:"probe,omitempty"`
+	// Pinned is true when the request had to go to NodeID, such as an
+	// extra stream of a parallel upload, so Strategy did not apply.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// SelectionCandidate is one node's verdict in a SelectionExplanation.
+type SelectionCandidate struct {
+	NodeID   string          `json:"node_id"`
+	Address  string          `json:"address"`
+	State    string          `json:"state"`
+	Eligible bool            `json:"eligible"`
+	Reason   SelectionReason `json:"reason,omitempty"`
+	// CooldownUntil is set while the node is cooling down after failures.
+	CooldownUntil time.Time `json:"cooldown_until,omitempty"`
+	// Quarantined reports that the node's capability fetch keeps failing;
+	// it is still routed to on its advertised task hints.
+	Quarantined bool `json:"quarantined,omitempty"`
+	// RecentErrors are the node's latest errors, oldest first, when it was
+	// passed over for its health: not Ready, cooling down or unhealthy.
+	RecentErrors []RecentError `json:"recent_errors,omitempty"`
+}
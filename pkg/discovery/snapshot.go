@@ -34,6 +34,17 @@ func CloneNode(node *NodeInfo) *NodeInfo {
 		Version:      node.Version,
 		Runtime:      node.Runtime,
 		LastSeen:     node.LastSeen,
+
+		LastCapabilityChange: node.LastCapabilityChange,
+		NextProbe:            node.NextProbe,
+	}
+	if node.LastCapabilityDiff != nil {
+		diff := *node.LastCapabilityDiff
+		out.LastCapabilityDiff = &diff
+	}
+	if node.LastError != nil {
+		lastErr := *node.LastError
+		out.LastError = &lastErr
 	}
 
 	if node.Metadata != nil {
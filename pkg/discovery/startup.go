@@ -0,0 +1,45 @@
+package discovery
+
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// How a subsystem came up, in StartupReport.
+const (
+	StartupOK       = "ok"
+	StartupDegraded = "degraded"
+	StartupFailed   = "failed"
+)
+
+// ComponentStatus is the state one subsystem was left in by client startup,
+// with the error behind anything but StartupOK.
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// StartupReport describes how each subsystem of a client came up. Status is
+// StartupFailed when startup failed, StartupDegraded when it succeeded
+// with some component not ok, and StartupOK otherwise.
+type StartupReport struct {
+	Status     string                     `json:"status"`
+	StartedAt  time.Time                  `json:"started_at"`
+	Duration   time.Duration              `json:"duration_ns"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// MarshalLogObject logs the report as structured fields, one per component,
+// so it can be passed to zap.Object.
+func (r *StartupReport) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("status", r.Status)
+	enc.AddDuration("duration", r.Duration)
+	for name, c := range r.Components {
+		enc.AddString(name, c.Status)
+		if c.Error != "" {
+			enc.AddString(name+"_error", c.Error)
+		}
+	}
+	return nil
+}
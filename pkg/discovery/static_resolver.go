@@ -2,10 +2,9 @@ package discovery
 
 import (
 	"context"
-	"net"
-	"strconv"
 	"strings"
 
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils/netaddr"
 	"go.uber.org/zap"
 )
 
@@ -22,8 +21,9 @@ type StaticResolver struct {
 	logger       *zap.Logger
 }
 
-// NewStaticResolver creates a resolver for a fixed endpoint list. Invalid
-// entries (not "host:port") are skipped with a warning.
+// NewStaticResolver creates a resolver for a fixed endpoint list of
+// "host:port" or "[v6]:port" entries. Invalid entries are skipped with a
+// warning.
 func NewStaticResolver(endpoints []string, deploymentID string, logger *zap.Logger) *StaticResolver {
 	if deploymentID == "" {
 		deploymentID = DefaultDeploymentID
@@ -45,16 +45,18 @@ func (r *StaticResolver) Watch(ctx context.Context) (<-chan NodeEvent, error) {
 		if endpoint == "" {
 			continue
 		}
-		resolved, ok := r.resolveEndpoint(endpoint)
-		if !ok {
-			r.logger.Warn("skipping invalid static node endpoint", zap.String("endpoint", endpoint))
+		resolved, err := r.resolveEndpoint(endpoint)
+		if err != nil {
+			r.logger.Warn("skipping invalid static node endpoint", zap.String("endpoint", endpoint), zap.Error(err))
 			continue
 		}
 		r.logger.Info("static node resolved",
 			zap.String("id", resolved.Key()),
 			zap.Strings("addresses", resolved.CandidateEndpoints()),
 		)
-		events = append(events, eventFromResolved(NodeDiscovered, resolved))
+		ev := eventFromResolved(NodeDiscovered, resolved)
+		ev.Source = SourceStatic
+		events = append(events, ev)
 	}
 
 	ch := make(chan NodeEvent, len(events))
@@ -72,14 +74,10 @@ func (r *StaticResolver) Watch(ctx context.Context) (<-chan NodeEvent, error) {
 	return ch, nil
 }
 
-func (r *StaticResolver) resolveEndpoint(endpoint string) (ResolvedNode, bool) {
-	host, portString, err := net.SplitHostPort(endpoint)
+func (r *StaticResolver) resolveEndpoint(endpoint string) (ResolvedNode, error) {
+	addr, err := netaddr.ParseHostPort(endpoint, 0)
 	if err != nil {
-		return ResolvedNode{}, false
-	}
-	port, err := strconv.Atoi(portString)
-	if err != nil || port <= 0 || port > 65535 || strings.TrimSpace(host) == "" {
-		return ResolvedNode{}, false
+		return ResolvedNode{}, err
 	}
 
 	// The endpoint itself is the stable identity: static nodes have no
@@ -88,7 +86,7 @@ func (r *StaticResolver) resolveEndpoint(endpoint string) (ResolvedNode, bool) {
 	return ResolvedNode{
 		Identity:     identity,
 		InstanceName: identity.NodeID,
-		Addresses:    []string{host},
-		Port:         port,
-	}.Normalized(), true
+		Addresses:    []string{addr.Host},
+		Port:         addr.Port,
+	}.Normalized(), nil
 }
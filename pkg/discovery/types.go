@@ -5,6 +5,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/edwinzhancn/lumen-sdk/pkg/tasks"
 	pb "github.com/edwinzhancn/lumen-sdk/proto"
 )
 
@@ -21,12 +22,103 @@ type NodeInfo struct {
 	Models       []*ModelInfo           `json:"models,omitempty"`
 	LastSeen     time.Time              `json:"last_seen"`
 	Tasks        []*pb.IOTask           `json:"tasks,omitempty"`
+	// Features are the wire features the node advertised under
+	// FeaturesExtraKey; see SupportsFeature.
+	Features []string `json:"features,omitempty"`
+	// Source is the discovery backend the node came from when it says so:
+	// SourcePush for nodes that registered themselves.
+	Source string `json:"source,omitempty"`
+	// Load is the load in [0, 1] the node last pushed; zero for nodes that
+	// do not report one.
+	Load float64 `json:"load,omitempty"`
+
+	// LastCapabilityChange is when a capability re-fetch last found a
+	// difference, and LastCapabilityDiff is that difference. Both are zero
+	// until the node's capabilities change after the first fetch.
+	LastCapabilityChange time.Time       `json:"last_capability_change,omitempty"`
+	LastCapabilityDiff   *CapabilityDiff `json:"last_capability_diff,omitempty"`
+
+	// NextProbe is when a quarantined node's capabilities are fetched again.
+	NextProbe time.Time `json:"next_probe,omitempty"`
+
+	// StaleServices are the services whose capabilities the last fetch
+	// could not refresh: the node reported them failing, or the fetch broke
+	// off before them. Their previously fetched capabilities are kept, so
+	// their tasks stay routable until a fetch refreshes or drops them.
+	StaleServices []string `json:"stale_services,omitempty"`
+
+	// LastError is the most recent connection failure, kept after the node
+	// recovers so repeated failures can be diagnosed; nil if it never failed.
+	LastError *NodeError `json:"last_error,omitempty"`
+	// RecentErrors are the node's latest failures of any operation, oldest
+	// first. Node lists leave it empty; it is filled in for a single node,
+	// as GET /v1/nodes/{id} serves it.
+	RecentErrors []RecentError `json:"recent_errors,omitempty"`
+
+	// InFlight counts requests routed to the node that have not finished.
+	InFlight int `json:"in_flight"`
+	// RequestFailures counts the node's consecutive failed requests and
+	// connection attempts, HealthCheckFailures its consecutive failed
+	// Health RPCs. Either reset on the next success.
+	RequestFailures     int `json:"request_failures"`
+	HealthCheckFailures int `json:"health_check_failures"`
+	// DrainingSince is when the node was drained; zero unless Status is
+	// NodeStatusDraining.
+	DrainingSince time.Time `json:"draining_since,omitempty"`
+	// Ejection is the node's outlier ejection while it is ejected or being
+	// re-admitted; nil otherwise.
+	Ejection *NodeEjection `json:"ejection,omitempty"`
+
+	// StatusReason is why the node has its Status, and StatusSince when it
+	// got it; see NodeState.
+	StatusReason StatusReason `json:"status_reason,omitempty"`
+	StatusSince  time.Time    `json:"status_since,omitempty"`
+
+	// HealthScore is the exponentially smoothed share of passed health
+	// checks, 1 for a node never checked. Flaps counts its transitions to
+	// error within the flap window, SuspectUntil is when a suspect node
+	// takes regular traffic again and StatusHistory lists its latest
+	// status transitions, oldest first.
+	HealthScore   float64            `json:"health_score,omitempty"`
+	Flaps         int                `json:"flaps,omitempty"`
+	SuspectUntil  time.Time          `json:"suspect_until,omitempty"`
+	StatusHistory []StatusTransition `json:"status_history,omitempty"`
 
 	connections    int64           `json:"-"`
 	supportedTasks map[string]bool `json:"-"`
 	mu             sync.RWMutex    `json:"-"`
 }
 
+// NodeError describes a failed connection attempt to a node. Code is a
+// utils.ErrorCode: CONNECTION_FAILED for transient failures (refused,
+// unreachable, reset), UNAUTHORIZED when the TLS handshake is rejected and
+// INVALID when the address cannot be dialled at all.
+type NodeError struct {
+	Code      string    `json:"code"`
+	Message   string    `json:"message"`
+	Retryable bool      `json:"retryable"`
+	At        time.Time `json:"at"`
+}
+
+// Operations a RecentError can come from.
+const (
+	NodeOpDial       = "dial"       // the node's connection failed or was lost
+	NodeOpHealth     = "health"     // a Health RPC failed
+	NodeOpCapability = "capability" // a capability fetch failed
+	NodeOpInfer      = "infer"      // a request routed to the node failed
+)
+
+// RecentError is one failure involving a node. Code is a utils.ErrorCode,
+// Op one of the NodeOp constants, and CorrelationID the failed request's
+// when Op is NodeOpInfer. Message is truncated.
+type RecentError struct {
+	At            time.Time `json:"at"`
+	Op            string    `json:"op"`
+	Code          string    `json:"code"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Message       string    `json:"message"`
+}
+
 type ModelInfo struct {
 	ID      string `json:"id"`
 	Name    string `json:"name"`
@@ -41,17 +133,95 @@ const (
 	NodeStatusStarting NodeStatus = "starting"
 	NodeStatusActive   NodeStatus = "active"
 	NodeStatusError    NodeStatus = "error"
+	// NodeStatusQuarantined marks a node whose capability fetch keeps
+	// failing; it is probed again at NodeInfo.NextProbe.
+	NodeStatusQuarantined NodeStatus = "quarantined"
+	// NodeStatusDraining marks a node taken out of selection with
+	// DrainNode; NodeInfo.InFlight counts the requests it is finishing.
+	NodeStatusDraining NodeStatus = "draining"
+	// NodeStatusEjected marks a node outlier detection took out of
+	// selection until NodeInfo.Ejection.Until.
+	NodeStatusEjected NodeStatus = "ejected"
+	// NodeStatusSuspect marks a node whose health checks kept flapping; it
+	// takes only probe traffic until NodeInfo.SuspectUntil.
+	NodeStatusSuspect NodeStatus = "suspect"
+	// NodeStatusProvisional marks a connected node known only by the
+	// capabilities it hinted in TXT records, until GetCapabilities confirms
+	// them. It takes requests unless discovery.provisional_traffic is off.
+	NodeStatusProvisional NodeStatus = "provisional"
+	// NodeStatusDegraded marks an active node some of whose services the
+	// last capability fetch could not refresh; see NodeInfo.StaleServices.
+	// It takes requests like an active node.
+	NodeStatusDegraded NodeStatus = "degraded"
 )
 
+// StatusTransition is one change of a node's status.
+type StatusTransition struct {
+	From NodeStatus `json:"from"`
+	To   NodeStatus `json:"to"`
+	At   time.Time  `json:"at"`
+	// Reason is why, one of the StatusReason constants; Detail says more
+	// for people, e.g. "3 failed checks".
+	Reason StatusReason `json:"reason"`
+	Detail string       `json:"detail,omitempty"`
+}
+
+// Clone returns a copy of n's exported fields. Maps, slices and pointers
+// are shared with n.
+func (n *NodeInfo) Clone() *NodeInfo {
+	return &NodeInfo{
+		ID:                   n.ID,
+		Address:              n.Address,
+		Status:               n.Status,
+		StatusReason:         n.StatusReason,
+		StatusSince:          n.StatusSince,
+		Availability:         n.Availability,
+		Metadata:             n.Metadata,
+		Capabilities:         n.Capabilities,
+		Version:              n.Version,
+		Runtime:              n.Runtime,
+		Models:               n.Models,
+		LastSeen:             n.LastSeen,
+		Tasks:                n.Tasks,
+		Features:             n.Features,
+		Source:               n.Source,
+		Load:                 n.Load,
+		LastCapabilityChange: n.LastCapabilityChange,
+		LastCapabilityDiff:   n.LastCapabilityDiff,
+		StaleServices:        n.StaleServices,
+		NextProbe:            n.NextProbe,
+		LastError:            n.LastError,
+		RecentErrors:         n.RecentErrors,
+		InFlight:             n.InFlight,
+		RequestFailures:      n.RequestFailures,
+		HealthCheckFailures:  n.HealthCheckFailures,
+		DrainingSince:        n.DrainingSince,
+		Ejection:             n.Ejection,
+		HealthScore:          n.HealthScore,
+		Flaps:                n.Flaps,
+		SuspectUntil:         n.SuspectUntil,
+		StatusHistory:        n.StatusHistory,
+	}
+}
+
+// IsActive reports whether the node takes regular traffic: its status is
+// active or degraded. Provisional and suspect nodes take only some; see
+// IsProvisional.
 func (n *NodeInfo) IsActive() bool {
-	return n.Status == NodeStatusActive
+	return n.Status == NodeStatusActive || n.Status == NodeStatusDegraded
+}
+
+// IsProvisional reports whether the node is connected but its capabilities
+// are still only the ones it hinted in discovery.
+func (n *NodeInfo) IsProvisional() bool {
+	return n.Status == NodeStatusProvisional
 }
 
 func (n *NodeInfo) SupportsTask(task string) bool {
 	n.mu.RLock()
 	cache := n.supportedTasks
 	if cache != nil {
-		supported := cache[task]
+		supported := supportsTaskIn(cache, task)
 		n.mu.RUnlock()
 		return supported
 	}
@@ -62,7 +232,21 @@ func (n *NodeInfo) SupportsTask(task string) bool {
 	if n.supportedTasks == nil {
 		n.rebuildSupportedTasksCacheLocked()
 	}
-	return n.supportedTasks[task]
+	return supportsTaskIn(n.supportedTasks, task)
+}
+
+// supportsTaskIn reports whether any task in set matches task, trying the
+// exact name before versioned and wildcard entries.
+func supportsTaskIn(set map[string]bool, task string) bool {
+	if set[task] {
+		return true
+	}
+	for advertised := range set {
+		if tasks.Match(task, advertised) {
+			return true
+		}
+	}
+	return false
 }
 
 func (n *NodeInfo) SupportsServiceTask(service, task string) bool {
@@ -76,7 +260,7 @@ func (n *NodeInfo) SupportsServiceTask(service, task string) bool {
 			continue
 		}
 		for _, ioTask := range capability.GetTasks() {
-			if ioTask.GetName() == task {
+			if tasks.Match(task, ioTask.GetName()) {
 				return true
 			}
 		}
@@ -91,7 +275,7 @@ func (n *NodeInfo) MatchingServices(task string) []string {
 	var services []string
 	for _, capability := range n.Capabilities {
 		for _, ioTask := range capability.GetTasks() {
-			if ioTask.GetName() == task {
+			if tasks.Match(task, ioTask.GetName()) {
 				service := capability.GetServiceName()
 				if service != "" && !seen[service] {
 					seen[service] = true
@@ -0,0 +1,26 @@
+package discovery
+
+import "time"
+
+// TenantUsage is what one tenant's requests consumed. Tokens and GPUTimeMs
+// only count responses from nodes that report them.
+type TenantUsage struct {
+	Requests int64 `json:"requests"`
+	Failed   int64 `json:"failed"`
+	// Rejected counts requests refused because the tenant was over quota;
+	// they are not included in Requests.
+	Rejected int64 `json:"rejected,omitempty"`
+	// BytesIn and BytesOut are request and response payload bytes.
+	BytesIn        int64   `json:"bytes_in"`
+	BytesOut       int64   `json:"bytes_out"`
+	Tokens         int64   `json:"tokens"`
+	GPUTimeMs      float64 `json:"gpu_time_ms"`
+	TotalLatencyNs int64   `json:"total_latency_ns"`
+}
+
+// UsageReport is per-tenant usage over [Since, Until).
+type UsageReport struct {
+	Since   time.Time              `json:"since"`
+	Until   time.Time              `json:"until"`
+	Tenants map[string]TenantUsage `json:"tenants"`
+}
@@ -0,0 +1,290 @@
+package hostbroker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Roles of a Broker running with leader election.
+const (
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+)
+
+// LeaseRecord is the content of the lease file shared by the Brokers of an
+// active/standby pair.
+type LeaseRecord struct {
+	// Holder is the ID of the Broker holding the lease.
+	Holder  string    `json:"holder"`
+	Renewed time.Time `json:"renewed"`
+	// Expires is when a standby may take the lease over unless the holder
+	// renews it first.
+	Expires time.Time `json:"expires"`
+}
+
+// Live reports whether the lease is still held at now.
+func (r *LeaseRecord) Live(now time.Time) bool {
+	return r != nil && now.Before(r.Expires)
+}
+
+// LeaseStatus is a Broker's view of the election, reported under election
+// in /v1/health.
+type LeaseStatus struct {
+	Role string `json:"role"` // "leader" or "standby"
+	// ID is this Broker's ID in the lease.
+	ID        string `json:"id"`
+	LeaseFile string `json:"lease_file"`
+	// Leader is the ID of the lease holder; empty while nobody holds it.
+	Leader       string    `json:"leader,omitempty"`
+	LeaseExpires time.Time `json:"lease_expires"`
+	// LastError is the last failure to read or write the lease file.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// ReadLease reads the lease file at path. It returns nil and no error when
+// the file does not exist.
+func ReadLease(path string) (*LeaseRecord, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read lease: %w", err)
+	}
+	var rec LeaseRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parse lease %s: %w", path, err)
+	}
+	return &rec, nil
+}
+
+// LeaseElector elects one leader among Brokers sharing a lease file, e.g.
+// on a network mount both hosts see. The leader renews the lease every
+// third of its timeout; a standby takes it over once it has gone
+// unrenewed for the whole timeout.
+//
+// Wherever the outcome is unclear it favors no Broker serving over two: a
+// leader steps down as soon as it fails to renew or finds another holder,
+// and a standby that wrote the lease only leads once a re-read after a
+// settling delay still shows it as the holder.
+type LeaseElector struct {
+	path   string
+	id     string
+	ttl    time.Duration
+	logger *zap.Logger
+	clock  utils.Clock
+
+	mu     sync.Mutex
+	status LeaseStatus
+	// renewed is when this Broker last wrote the lease as leader.
+	renewed time.Time
+}
+
+// NewLeaseElector returns an elector for the Broker id contending for the
+// lease file at path, which expires ttl after each renewal.
+func NewLeaseElector(path, id string, ttl time.Duration, logger *zap.Logger) *LeaseElector {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &LeaseElector{
+		path:   path,
+		id:     id,
+		ttl:    ttl,
+		logger: logger,
+		clock:  utils.RealClock,
+		status: LeaseStatus{Role: RoleStandby, ID: id, LeaseFile: path},
+	}
+}
+
+// SetClock sets the clock leases are written, renewed and judged on; nil
+// is the wall clock. Call it before Run.
+func (e *LeaseElector) SetClock(clock utils.Clock) {
+	e.clock = utils.ClockOrReal(clock)
+}
+
+// Status returns this Broker's current view of the election.
+func (e *LeaseElector) Status() *LeaseStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	status := e.status
+	return &status
+}
+
+// IsLeader reports whether this Broker holds the lease.
+func (e *LeaseElector) IsLeader() bool {
+	return e.Status().Role == RoleLeader
+}
+
+// Run contends for the lease until ctx is done, calling onElected when this
+// Broker becomes leader and onDemoted when it stops being leader, including
+// when ctx ends. A leader leaving cleanly removes the lease so a standby
+// takes over without waiting for it to expire.
+func (e *LeaseElector) Run(ctx context.Context, onElected, onDemoted func()) {
+	ticker := e.clock.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		switch wasLeader, leader := e.IsLeader(), e.step(ctx); {
+		case leader && !wasLeader:
+			e.logger.Info("elected Broker leader", zap.String("id", e.id), zap.String("lease_file", e.path))
+			onElected()
+		case !leader && wasLeader:
+			e.logger.Warn("stepped down as Broker leader", zap.String("id", e.id), zap.String("error", e.Status().LastError))
+			onDemoted()
+		}
+		select {
+		case <-ctx.Done():
+			if e.IsLeader() {
+				e.release()
+				onDemoted()
+			}
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// step runs one round of the election and reports whether this Broker is
+// leader afterwards.
+func (e *LeaseElector) step(ctx context.Context) bool {
+	now := e.clock.Now()
+	rec, err := ReadLease(e.path)
+	if err != nil {
+		e.setStandby(rec, err)
+		return false
+	}
+
+	if e.IsLeader() {
+		e.mu.Lock()
+		lapsed := now.Sub(e.renewed) >= e.ttl
+		e.mu.Unlock()
+		switch {
+		case rec == nil || rec.Holder != e.id:
+			e.setStandby(rec, fmt.Errorf("lease taken over by %q", holderOf(rec)))
+			return false
+		case lapsed:
+			// Renewing a lease that already expired could overlap a
+			// standby that took it over in the meantime.
+			e.setStandby(rec, fmt.Errorf("lease lapsed before it was renewed"))
+			return false
+		}
+		written, ok := e.renew(now)
+		if ok {
+			e.lead(written)
+		}
+		return ok
+	}
+
+	if rec.Live(now) && rec.Holder != e.id {
+		e.setStandby(rec, nil)
+		return false
+	}
+	written, ok := e.renew(now)
+	if !ok {
+		return false
+	}
+	// Another standby may have written the lease at the same moment; the
+	// one whose write survives leads. Until then this Broker stays a
+	// standby.
+	select {
+	case <-ctx.Done():
+		e.setStandby(nil, nil)
+		return false
+	case <-e.clock.After(e.settleDelay()):
+	}
+	rec, err = ReadLease(e.path)
+	if err != nil || rec == nil || rec.Holder != e.id {
+		e.setStandby(rec, err)
+		return false
+	}
+	e.lead(written)
+	return true
+}
+
+// settleDelay is how long a standby that wrote the lease waits before
+// re-reading it to confirm it won.
+func (e *LeaseElector) settleDelay() time.Duration {
+	return min(e.ttl/10, time.Second)
+}
+
+// renew writes the lease as held by this Broker and returns what it wrote.
+// A failed write leaves the Broker a standby. Writing does not make it
+// leader; lead does.
+func (e *LeaseElector) renew(now time.Time) (LeaseRecord, bool) {
+	rec := LeaseRecord{Holder: e.id, Renewed: now, Expires: now.Add(e.ttl)}
+	if err := writeLease(e.path, e.id, rec); err != nil {
+		e.setStandby(nil, err)
+		return LeaseRecord{}, false
+	}
+	return rec, true
+}
+
+// lead records this Broker as leader under rec, the lease it wrote.
+func (e *LeaseElector) lead(rec LeaseRecord) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.renewed = rec.Renewed
+	e.status.Role = RoleLeader
+	e.status.Leader = e.id
+	e.status.LeaseExpires = rec.Expires
+	e.status.LastError = ""
+}
+
+// release removes the lease if this Broker still holds it.
+func (e *LeaseElector) release() {
+	if rec, err := ReadLease(e.path); err == nil && rec != nil && rec.Holder == e.id {
+		if err := os.Remove(e.path); err != nil {
+			e.logger.Warn("failed to release Broker lease", zap.Error(err))
+		}
+	}
+	e.setStandby(nil, nil)
+}
+
+func (e *LeaseElector) setStandby(rec *LeaseRecord, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status.Role = RoleStandby
+	e.status.Leader = ""
+	e.status.LeaseExpires = time.Time{}
+	if rec.Live(e.clock.Now()) {
+		e.status.Leader = rec.Holder
+		e.status.LeaseExpires = rec.Expires
+	}
+	e.status.LastError = ""
+	if err != nil {
+		e.status.LastError = err.Error()
+	}
+}
+
+func holderOf(rec *LeaseRecord) string {
+	if rec == nil {
+		return ""
+	}
+	return rec.Holder
+}
+
+// writeLease replaces the lease file atomically, so a reader never sees a
+// partial record.
+func writeLease(path, id string, rec LeaseRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+"."+id+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write lease: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write lease: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package hostbroker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+// runElector runs e until the test ends and returns channels receiving its
+// elections and demotions.
+func runElector(t *testing.T, e *LeaseElector) (stop context.CancelFunc, elected, demoted chan struct{}) {
+	t.Helper()
+	elected, demoted = make(chan struct{}, 4), make(chan struct{}, 4)
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(runCtx, func() { elected <- struct{}{} }, func() { demoted <- struct{}{} })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return cancel, elected, demoted
+}
+
+func waitSignal(t *testing.T, ch chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestLeaseElectorFailsOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.lease")
+	ttl := 300 * time.Millisecond
+	a := NewLeaseElector(path, "hub-a", ttl, nil)
+	b := NewLeaseElector(path, "hub-b", ttl, nil)
+
+	stopA, electedA, demotedA := runElector(t, a)
+	waitSignal(t, electedA, "hub-a to be elected")
+	_, electedB, _ := runElector(t, b)
+
+	time.Sleep(2 * ttl)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("roles a=%+v b=%+v, want a leading and b standing by", a.Status(), b.Status())
+	}
+	if st := b.Status(); st.Leader != "hub-a" || st.LeaseExpires.IsZero() {
+		t.Fatalf("standby status = %+v, want hub-a as leader", st)
+	}
+
+	// A leader stopping cleanly releases the lease to the standby.
+	stopA()
+	waitSignal(t, demotedA, "hub-a to step down")
+	waitSignal(t, electedB, "hub-b to take over")
+	rec, err := ReadLease(path)
+	if err != nil || rec.Holder != "hub-b" {
+		t.Fatalf("lease = %+v, %v; want held by hub-b", rec, err)
+	}
+}
+
+func TestLeaseElectorWaitsForExpiry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.lease")
+	ttl := 300 * time.Millisecond
+	// A leader that died without releasing its lease.
+	if err := writeLease(path, "hub-a", LeaseRecord{Holder: "hub-a", Renewed: time.Now(), Expires: time.Now().Add(ttl)}); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewLeaseElector(path, "hub-b", ttl, nil)
+	start := time.Now()
+	_, elected, _ := runElector(t, b)
+	waitSignal(t, elected, "hub-b to take over the expired lease")
+	if waited := time.Since(start); waited < ttl/2 {
+		t.Fatalf("took over after %v, before the lease expired", waited)
+	}
+}
+
+// TestLeaseElectorStandbyLeadsOnlyOnceConfirmed checks a standby that wrote
+// the lease reports standby until its re-read confirms it holds it.
+func TestLeaseElectorStandbyLeadsOnlyOnceConfirmed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.lease")
+	clock := utils.NewFakeClock(time.Unix(1_700_000_000, 0))
+	e := NewLeaseElector(path, "hub-a", 5*time.Second, nil) // settles for 500ms
+	e.SetClock(clock)
+	led := make(chan bool, 1)
+	go func() { led <- e.step(context.Background()) }()
+
+	// The lease is written before the settling wait starts.
+	clock.BlockUntil(1)
+	if rec, err := ReadLease(path); err != nil || rec == nil || rec.Holder != "hub-a" {
+		t.Fatalf("lease while settling = %+v, %v; want held by hub-a", rec, err)
+	}
+	if st := e.Status(); st.Role != RoleStandby {
+		t.Fatalf("status while settling = %+v, want standby", st)
+	}
+	clock.Advance(e.settleDelay())
+	if !<-led || !e.IsLeader() {
+		t.Fatalf("status after settling = %+v, want leader", e.Status())
+	}
+	if st := e.Status(); !st.LeaseExpires.Equal(time.Unix(1_700_000_005, 0)) {
+		t.Fatalf("lease expires %v, want 5s after it was written on the elector's clock", st.LeaseExpires)
+	}
+}
+
+func TestLeaseElectorStepsDownWhenLeaseTaken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.lease")
+	ttl := 300 * time.Millisecond
+	a := NewLeaseElector(path, "hub-a", ttl, nil)
+	_, elected, demoted := runElector(t, a)
+	waitSignal(t, elected, "hub-a to be elected")
+
+	// Another Broker claims the lease, e.g. after this one was paused.
+	if err := writeLease(path, "hub-b", LeaseRecord{Holder: "hub-b", Renewed: time.Now(), Expires: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	waitSignal(t, demoted, "hub-a to step down")
+	if st := a.Status(); st.Role != RoleStandby || st.Leader != "hub-b" {
+		t.Fatalf("status = %+v, want standby behind hub-b", st)
+	}
+}
+
+func TestServerHealthReportsElection(t *testing.T) {
+	status := &LeaseStatus{Role: RoleLeader, ID: "hub-a", Leader: "hub-a", LeaseExpires: time.Now().Add(time.Minute)}
+	_, baseURL := startTestServerWithOptions(t, nil, ServerOptions{Election: func() *LeaseStatus { return status }})
+
+	resp, err := http.Get(baseURL + "/v1/health")
+	if err != nil {
+		t.Fatalf("GET /v1/health: %v", err)
+	}
+	defer resp.Body.Close()
+	var body healthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Election == nil || body.Election.Role != RoleLeader || body.Election.ID != "hub-a" {
+		t.Fatalf("election = %+v, want hub-a leading", body.Election)
+	}
+}
@@ -24,6 +24,7 @@ type nodeWatchHub struct {
 	handler fiber.Handler
 
 	watchOnce sync.Once
+	unwatch   func() // set once the catalog is watched; guarded by mu
 	mu        sync.Mutex
 	clients   map[*ws.Conn]struct{}
 	prevNodes map[string]struct{}
@@ -53,7 +54,10 @@ func (h *nodeWatchHub) upgrade(c *fiber.Ctx) error {
 func (h *nodeWatchHub) serve(conn *ws.Conn) {
 	h.watchOnce.Do(func() {
 		if h.catalog != nil {
-			h.catalog.WatchNodes(h.broadcast)
+			unwatch := h.catalog.WatchNodes(h.broadcast)
+			h.mu.Lock()
+			h.unwatch = unwatch
+			h.mu.Unlock()
 		}
 	})
 
@@ -102,12 +106,19 @@ func (h *nodeWatchHub) dropClient(conn *ws.Conn) {
 // The lock is held for the whole call (matching broadcast) so a
 // connection's own teardown can't race this same object concurrently with
 // gofiber-contrib's *ws.Conn recycling into its package-level sync.Pool.
+//
+// Close also stops watching the catalog, which outlives the server.
 func (h *nodeWatchHub) Close() {
 	h.mu.Lock()
-	defer h.mu.Unlock()
+	unwatch := h.unwatch
+	h.unwatch = nil
 	for conn := range h.clients {
 		_ = conn.SetReadDeadline(time.Now())
 	}
+	h.mu.Unlock()
+	if unwatch != nil {
+		unwatch()
+	}
 }
 
 // broadcast diffs the active node set against the previous one and pushes
@@ -0,0 +1,391 @@
+package hostbroker
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiRoute documents one route registered by setupRoutes. The OpenAPI
+// document is generated from this table and the response types' JSON tags,
+// so it cannot drift from the wire shapes the handlers encode.
+type apiRoute struct {
+	method  string
+	path    string // fiber syntax, e.g. /v1/nodes/:id
+	summary string
+	query   []string
+	// request is a value of the JSON request body type, if any.
+	request any
+	// responses maps a status code to a value of the response body type;
+	// a nil value documents a response without a JSON body.
+	responses map[int]any
+	// contentType overrides application/json for non-JSON responses.
+	contentType string
+	// docsOnly marks routes registered only when docs are enabled.
+	docsOnly bool
+}
+
+var apiRoutes = []apiRoute{
+	{method: http.MethodGet, path: "/v1/health", summary: "Broker liveness, build version and whether discovery is degraded",
+		responses: map[int]any{http.StatusOK: healthResponse{}}},
+	{method: http.MethodGet, path: "/v1/ready", summary: "Whether each task has its minimum of healthy nodes: the given comma-separated tasks, or those with a minimum configured",
+		query:     []string{"tasks"},
+		responses: map[int]any{http.StatusOK: readyResponse{}, http.StatusServiceUnavailable: readyResponse{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodGet, path: "/v1/version", summary: "Build version, platform and enabled optional routes of the Broker",
+		responses: map[int]any{http.StatusOK: VersionInfo{}}},
+	{method: http.MethodGet, path: "/v1/nodes", summary: "Known nodes sorted by ID, filtered by status, task, runtime and comma-separated label selectors (key=value, key!=value, key), paged by offset and limit, with the total matched",
+		query:     []string{"status", "task", "runtime", "labels", "offset", "limit"},
+		responses: map[int]any{http.StatusOK: nodesResponse{}, http.StatusBadRequest: errorResponse{}}},
+	{method: http.MethodGet, path: "/v1/nodes/watch", summary: "WebSocket stream of WsNodeEvent messages: a snapshot, then added/removed events",
+		responses: map[int]any{http.StatusSwitchingProtocols: wsNodeEvent{}, http.StatusUpgradeRequired: nil}},
+	{method: http.MethodGet, path: "/v1/nodes/:id", summary: "One node, including its last capability change and recent errors",
+		responses: map[int]any{http.StatusOK: discovery.NodeInfo{}, http.StatusNotFound: errorResponse{}}},
+	{method: http.MethodPost, path: "/v1/nodes/:id/drain", summary: "Stop routing new requests to the node while in-flight ones finish, optionally until a timeout such as 30m",
+		query:     []string{"timeout"},
+		responses: map[int]any{http.StatusOK: discovery.NodeInfo{}, http.StatusBadRequest: errorResponse{}, http.StatusNotFound: errorResponse{}, http.StatusNotImplemented: errorResponse{}, http.StatusServiceUnavailable: errorResponse{}}},
+	{method: http.MethodPost, path: "/v1/nodes/:id/undrain", summary: "Return a drained node to selection",
+		responses: map[int]any{http.StatusOK: discovery.NodeInfo{}, http.StatusNotFound: errorResponse{}, http.StatusNotImplemented: errorResponse{}, http.StatusServiceUnavailable: errorResponse{}}},
+	{method: http.MethodGet, path: "/v1/capabilities", summary: "Cluster capabilities merged by task",
+		query:     []string{"runtime", "precision", "model"},
+		responses: map[int]any{http.StatusOK: discovery.ClusterCapabilities{}}},
+	{method: http.MethodGet, path: "/v1/tasks/:name/explain", summary: "How a request for the task would be routed: every node's verdict and the next pick",
+		responses: map[int]any{http.StatusOK: discovery.SelectionExplanation{}, http.StatusNotImplemented: errorResponse{}, http.StatusServiceUnavailable: errorResponse{}}},
+	{method: http.MethodGet, path: "/v1/usage", summary: "Per-tenant request usage over since..until (RFC 3339) or a trailing window such as 1h",
+		query:     []string{"since", "until", "window"},
+		responses: map[int]any{http.StatusOK: discovery.UsageReport{}, http.StatusBadRequest: errorResponse{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodGet, path: "/v1/jobs", summary: "Asynchronous jobs submitted through the SDK and not yet expired, oldest first",
+		responses: map[int]any{http.StatusOK: jobsResponse{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodGet, path: "/v1/jobs/:id", summary: "A job's state and progress, as its node reports it",
+		responses: map[int]any{http.StatusOK: discovery.JobStatus{}, http.StatusNotFound: errorResponse{}, http.StatusNotImplemented: errorResponse{}, http.StatusServiceUnavailable: errorResponse{}, http.StatusGatewayTimeout: errorResponse{}, statusClientClosedRequest: errorResponse{}}},
+	{method: http.MethodDelete, path: "/v1/jobs/:id", summary: "Cancel a job and return its state afterwards",
+		responses: map[int]any{http.StatusOK: discovery.JobStatus{}, http.StatusNotFound: errorResponse{}, http.StatusNotImplemented: errorResponse{}, http.StatusServiceUnavailable: errorResponse{}, http.StatusGatewayTimeout: errorResponse{}, statusClientClosedRequest: errorResponse{}}},
+	{method: http.MethodGet, path: "/v1/queue", summary: "Durable queue depth, dispatch counters and dead letters",
+		responses: map[int]any{http.StatusOK: discovery.QueueStatus{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodPost, path: "/v1/queue/retry-dead", summary: "Queue every dead letter again with a fresh attempt count",
+		responses: map[int]any{http.StatusOK: retryDeadResponse{}, http.StatusInternalServerError: errorResponse{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodGet, path: "/v1/push/nodes", summary: "Nodes registered by push, with their heartbeat deadlines",
+		responses: map[int]any{http.StatusOK: pushNodesResponse{}, http.StatusUnauthorized: errorResponse{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodPost, path: "/v1/push/nodes", summary: "Register a node, or replace its registration; needs the push bearer token",
+		request:   discovery.PushRegistration{},
+		responses: map[int]any{http.StatusCreated: discovery.PushedNode{}, http.StatusBadRequest: errorResponse{}, http.StatusUnauthorized: errorResponse{}, http.StatusForbidden: errorResponse{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodPost, path: "/v1/push/nodes/:id/heartbeat", summary: "Keep a pushed node registered; 404 means it expired and must register again",
+		responses: map[int]any{http.StatusNoContent: nil, http.StatusNotFound: errorResponse{}, http.StatusUnauthorized: errorResponse{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodPut, path: "/v1/push/nodes/:id/load", summary: "Report a pushed node's load in [0, 1]; counts as a heartbeat",
+		request:   pushLoadRequest{},
+		responses: map[int]any{http.StatusNoContent: nil, http.StatusBadRequest: errorResponse{}, http.StatusNotFound: errorResponse{}, http.StatusUnauthorized: errorResponse{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodPost, path: "/v1/push/nodes/:id/capabilities", summary: "Apply the task changes of a capability diff to a pushed node; counts as a heartbeat",
+		request:   discovery.CapabilityDiff{},
+		responses: map[int]any{http.StatusNoContent: nil, http.StatusBadRequest: errorResponse{}, http.StatusNotFound: errorResponse{}, http.StatusUnauthorized: errorResponse{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodDelete, path: "/v1/push/nodes/:id", summary: "Deregister a pushed node at once",
+		responses: map[int]any{http.StatusNoContent: nil, http.StatusNotFound: errorResponse{}, http.StatusUnauthorized: errorResponse{}, http.StatusNotImplemented: errorResponse{}}},
+	{method: http.MethodGet, path: "/openapi.json", summary: "This OpenAPI document",
+		responses: map[int]any{http.StatusOK: map[string]any{}}},
+	{method: http.MethodGet, path: "/docs", summary: "Swagger UI for this API", contentType: fiber.MIMETextHTMLCharsetUTF8,
+		responses: map[int]any{http.StatusOK: nil}, docsOnly: true},
+}
+
+// ---- OpenAPI 3 document ----
+
+type openAPIDoc struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIOperation struct {
+	Summary     string                      `json:"summary,omitempty"`
+	OperationID string                      `json:"operationId"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// buildOpenAPI generates the document for the routes a Server registers.
+func buildOpenAPI(version VersionInfo, docs bool) *openAPIDoc {
+	sb := &schemaBuilder{schemas: make(map[string]*openAPISchema), names: make(map[reflect.Type]string)}
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "Lumen Host Broker",
+			Version:     version.Version,
+			Description: "Discovery-only control plane: node listing, capabilities and push discovery. It serves no inference routes.",
+		},
+		Paths:      make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{Schemas: sb.schemas},
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "dev"
+	}
+
+	for _, route := range apiRoutes {
+		if route.docsOnly && !docs {
+			continue
+		}
+		path, params := openAPIPath(route.path)
+		op := &openAPIOperation{
+			Summary:     route.summary,
+			OperationID: operationID(route.method, route.path),
+			Responses:   make(map[string]*openAPIResponse, len(route.responses)),
+		}
+		for _, name := range params {
+			op.Parameters = append(op.Parameters, openAPIParameter{Name: name, In: "path", Required: true, Schema: &openAPISchema{Type: "string"}})
+		}
+		for _, name := range route.query {
+			op.Parameters = append(op.Parameters, openAPIParameter{Name: name, In: "query", Schema: &openAPISchema{Type: "string"}})
+		}
+		if route.request != nil {
+			op.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content:  map[string]openAPIMediaType{fiber.MIMEApplicationJSON: {Schema: sb.schemaFor(reflect.TypeOf(route.request))}},
+			}
+		}
+		for code, body := range route.responses {
+			resp := &openAPIResponse{Description: statusText(code)}
+			if body != nil || route.contentType != "" {
+				contentType := route.contentType
+				if contentType == "" {
+					contentType = fiber.MIMEApplicationJSON
+				}
+				media := openAPIMediaType{}
+				if body != nil {
+					media.Schema = sb.schemaFor(reflect.TypeOf(body))
+				} else {
+					media.Schema = &openAPISchema{Type: "string"}
+				}
+				resp.Content = map[string]openAPIMediaType{contentType: media}
+			}
+			op.Responses[strconv.Itoa(code)] = resp
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*openAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(route.method)] = op
+	}
+	return doc
+}
+
+// openAPIPath converts fiber's ":param" segments to "{param}" and returns
+// the parameter names.
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			name := strings.TrimSuffix(seg[1:], "?")
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	upper := true
+	for _, r := range path {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// schemaBuilder turns Go types into JSON schemas following encoding/json's
+// rules, registering named structs as reusable components.
+type schemaBuilder struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (sb *schemaBuilder) schemaFor(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: sb.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: sb.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return sb.structRef(t)
+	default:
+		// interface{} and anything else JSON can hold: any value.
+		return &openAPISchema{}
+	}
+}
+
+// structRef registers t under components/schemas and returns a $ref to it.
+// Unnamed structs are inlined.
+func (sb *schemaBuilder) structRef(t reflect.Type) *openAPISchema {
+	if t.Name() == "" {
+		return sb.structSchema(t)
+	}
+	name, ok := sb.names[t]
+	if !ok {
+		name = sb.componentName(t)
+		sb.names[t] = name
+		// Register before walking the fields so recursive types terminate.
+		sb.schemas[name] = &openAPISchema{}
+		*sb.schemas[name] = *sb.structSchema(t)
+	}
+	return &openAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+func (sb *schemaBuilder) componentName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	candidate := string(name)
+	if _, taken := sb.schemas[candidate]; taken {
+		pkg := t.PkgPath()
+		candidate = pkg[strings.LastIndex(pkg, "/")+1:] + "_" + candidate
+	}
+	return candidate
+}
+
+func (sb *schemaBuilder) structSchema(t reflect.Type) *openAPISchema {
+	s := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	sb.addFields(s, t)
+	return s
+}
+
+func (sb *schemaBuilder) addFields(s *openAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				sb.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = sb.schemaFor(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// ---- handlers ----
+
+func openAPIHandler(version VersionInfo, docs bool) fiber.Handler {
+	doc := buildOpenAPI(version, docs)
+	return func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusOK).JSON(doc)
+	}
+}
+
+// docsPage loads Swagger UI from a CDN and points it at /openapi.json.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Lumen Host Broker API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+func docsHandler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(fiber.StatusOK).SendString(docsPage)
+}
+
+// statusText is http.StatusText, which also names the non-standard 499.
+func statusText(code int) string {
+	if code == statusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(code)
+}
@@ -0,0 +1,174 @@
+package hostbroker
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPICoversEveryRoute fails when a route is registered without an
+// apiRoutes entry, so the served document cannot fall behind the server.
+func TestOpenAPICoversEveryRoute(t *testing.T) {
+	for _, docs := range []bool{false, true} {
+		srv := NewServerWithOptions(nil, VersionInfo{Version: "test"}, ServerOptions{Docs: docs}, nil)
+		doc := buildOpenAPI(VersionInfo{Version: "test"}, docs)
+
+		registered := make(map[string]bool)
+		for _, r := range srv.App().GetRoutes(true) {
+			if r.Method == http.MethodHead {
+				continue
+			}
+			path, _ := openAPIPath(r.Path)
+			key := strings.ToLower(r.Method) + " " + path
+			registered[key] = true
+			if doc.Paths[path][strings.ToLower(r.Method)] == nil {
+				t.Errorf("docs=%v: route %s is not in the OpenAPI document", docs, key)
+			}
+		}
+		for path, ops := range doc.Paths {
+			for method := range ops {
+				if !registered[method+" "+path] {
+					t.Errorf("docs=%v: OpenAPI documents %s %s, which is not registered", docs, method, path)
+				}
+			}
+		}
+	}
+}
+
+var componentNameRE = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// TestOpenAPIDocumentIsValid checks the generated document against the
+// OpenAPI 3.0 structural rules it could break: required top-level fields,
+// unique operation IDs, declared path parameters, status-code keys and
+// resolvable $refs.
+func TestOpenAPIDocumentIsValid(t *testing.T) {
+	_, baseURL := startTestServer(t, nil)
+	resp, err := http.Get(baseURL + "/openapi.json")
+	if err != nil {
+		t.Fatalf("GET /openapi.json: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var doc openAPIDoc
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.0.") || doc.Info.Title == "" || doc.Info.Version == "" {
+		t.Fatalf("header = %q %+v, want openapi 3.0.x with title and version", doc.OpenAPI, doc.Info)
+	}
+	for name := range doc.Components.Schemas {
+		if !componentNameRE.MatchString(name) {
+			t.Errorf("component name %q is not allowed", name)
+		}
+	}
+
+	var checkSchema func(where string, s *openAPISchema)
+	checkSchema = func(where string, s *openAPISchema) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" {
+			name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+			if _, ok := doc.Components.Schemas[name]; !ok || name == s.Ref {
+				t.Errorf("%s: unresolved $ref %q", where, s.Ref)
+			}
+		}
+		for _, req := range s.Required {
+			if _, ok := s.Properties[req]; !ok {
+				t.Errorf("%s: required %q is not a property", where, req)
+			}
+		}
+		checkSchema(where, s.Items)
+		checkSchema(where, s.AdditionalProperties)
+		for prop, ps := range s.Properties {
+			checkSchema(where+"."+prop, ps)
+		}
+	}
+	for name, s := range doc.Components.Schemas {
+		checkSchema(name, s)
+	}
+
+	ids := make(map[string]bool)
+	paramRE := regexp.MustCompile(`\{([^}]+)\}`)
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			where := method + " " + path
+			if op.OperationID == "" || ids[op.OperationID] {
+				t.Errorf("%s: missing or duplicate operationId %q", where, op.OperationID)
+			}
+			ids[op.OperationID] = true
+			if len(op.Responses) == 0 {
+				t.Errorf("%s: no responses", where)
+			}
+			for code, r := range op.Responses {
+				if !regexp.MustCompile(`^[1-5][0-9][0-9]$`).MatchString(code) || r.Description == "" {
+					t.Errorf("%s: bad response %q %+v", where, code, r)
+				}
+				for _, media := range r.Content {
+					checkSchema(where, media.Schema)
+				}
+			}
+			declared := make(map[string]bool)
+			for _, p := range op.Parameters {
+				if p.In == "path" {
+					declared[p.Name] = p.Required
+				}
+			}
+			for _, m := range paramRE.FindAllStringSubmatch(path, -1) {
+				if !declared[m[1]] {
+					t.Errorf("%s: path parameter %q is not declared as required", where, m[1])
+				}
+			}
+		}
+	}
+}
+
+func TestOpenAPIDescribesNodeInfo(t *testing.T) {
+	doc := buildOpenAPI(VersionInfo{}, false)
+	node, ok := doc.Components.Schemas["NodeInfo"]
+	if !ok {
+		t.Fatal("NodeInfo component missing")
+	}
+	for _, prop := range []string{"id", "address", "status", "capabilities", "last_capability_diff"} {
+		if _, ok := node.Properties[prop]; !ok {
+			t.Errorf("NodeInfo has no %q property", prop)
+		}
+	}
+	if caps := node.Properties["capabilities"]; caps.Items == nil || caps.Items.Ref != "#/components/schemas/Capability" {
+		t.Errorf("capabilities = %+v, want an array of Capability", caps)
+	}
+	if _, ok := doc.Components.Schemas["Capability"].Properties["state"]; ok {
+		t.Error("unexported proto fields leaked into Capability")
+	}
+	if doc.Paths["/docs"] != nil {
+		t.Error("/docs documented although docs are disabled")
+	}
+}
+
+func TestServerDocsPage(t *testing.T) {
+	srv := NewServerWithOptions(nil, VersionInfo{}, ServerOptions{Docs: true}, nil)
+	req, _ := http.NewRequest(http.MethodGet, "/docs", nil)
+	resp, err := srv.App().Test(req)
+	if err != nil {
+		t.Fatalf("GET /docs: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("status = %d content-type = %q, want 200 text/html", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	srv = NewServer(nil, VersionInfo{}, nil)
+	resp, err = srv.App().Test(req)
+	if err != nil {
+		t.Fatalf("GET /docs: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status without docs = %d, want 404", resp.StatusCode)
+	}
+}
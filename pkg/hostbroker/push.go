@@ -0,0 +1,134 @@
+package hostbroker
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// PushRegistrar is implemented by catalogs that accept nodes registering
+// themselves, such as *client.LumenClient with discovery.push enabled. When
+// the catalog passed to NewServer implements it and returns a registry, the
+// /v1/push routes serve it to callers presenting ServerOptions.PushToken;
+// otherwise they answer 501.
+type PushRegistrar interface {
+	PushRegistry() *discovery.PushResolver
+}
+
+type pushNodesResponse struct {
+	Nodes []discovery.PushedNode `json:"nodes"`
+}
+
+type pushLoadRequest struct {
+	Load float64 `json:"load"`
+}
+
+func pushRegistry(catalog NodeCatalog) *discovery.PushResolver {
+	if registrar, ok := catalog.(PushRegistrar); ok {
+		return registrar.PushRegistry()
+	}
+	return nil
+}
+
+// pushAuth guards the /v1/push routes: they answer 501 without a push
+// registry and 401 unless the request carries "Authorization: Bearer
+// <token>". An empty token rejects every request.
+func pushAuth(catalog NodeCatalog, token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if pushRegistry(catalog) == nil {
+			return c.Status(fiber.StatusNotImplemented).JSON(errorResponse{Error: "node catalog does not accept pushed nodes"})
+		}
+		presented, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="lumen-push"`)
+			return c.Status(fiber.StatusUnauthorized).JSON(errorResponse{Error: "missing or invalid push token"})
+		}
+		return c.Next()
+	}
+}
+
+func pushNodesHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusOK).JSON(pushNodesResponse{Nodes: pushRegistry(catalog).Nodes()})
+	}
+}
+
+// pushRegisterHandler registers the node in the body, replacing an earlier
+// registration under the same node_id, and answers with the node.
+func pushRegisterHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var reg discovery.PushRegistration
+		if err := c.BodyParser(&reg); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(errorResponse{Error: "invalid registration: " + err.Error()})
+		}
+		registry := pushRegistry(catalog)
+		if _, err := registry.Register(reg); err != nil {
+			return pushError(c, err)
+		}
+		for _, node := range registry.Nodes() {
+			if node.NodeID == strings.TrimSpace(reg.NodeID) {
+				return c.Status(fiber.StatusCreated).JSON(node)
+			}
+		}
+		// Expired between Register and Nodes, which only a zero heartbeat
+		// timeout allows.
+		return c.Status(fiber.StatusNotFound).JSON(errorResponse{Error: "node " + reg.NodeID + " not found"})
+	}
+}
+
+func pushHeartbeatHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return pushResult(c, pushRegistry(catalog).Heartbeat(c.Params("id")))
+	}
+}
+
+func pushLoadHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req pushLoadRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(errorResponse{Error: "invalid load update: " + err.Error()})
+		}
+		return pushResult(c, pushRegistry(catalog).UpdateLoad(c.Params("id"), req.Load))
+	}
+}
+
+func pushCapabilitiesHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var diff discovery.CapabilityDiff
+		if err := c.BodyParser(&diff); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(errorResponse{Error: "invalid capability diff: " + err.Error()})
+		}
+		return pushResult(c, pushRegistry(catalog).UpdateCapabilities(c.Params("id"), diff))
+	}
+}
+
+func pushDeregisterHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return pushResult(c, pushRegistry(catalog).Deregister(c.Params("id")))
+	}
+}
+
+// pushResult answers 204 for a successful update, else the matching error.
+func pushResult(c *fiber.Ctx, err error) error {
+	if err != nil {
+		return pushError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func pushError(c *fiber.Ctx, err error) error {
+	switch {
+	case utils.HasErrorCode(err, utils.ErrCodeNodeNotFound):
+		// The node expired or never registered; it should register again.
+		return c.Status(fiber.StatusNotFound).JSON(errorResponse{Error: err.Error()})
+	case utils.HasErrorCode(err, utils.ErrCodeInvalid):
+		return c.Status(fiber.StatusBadRequest).JSON(errorResponse{Error: err.Error()})
+	case utils.HasErrorCode(err, utils.ErrCodeForbidden):
+		// The node is not on discovery.push.allow_nodes.
+		return c.Status(fiber.StatusForbidden).JSON(errorResponse{Error: err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,128 @@
+package hostbroker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+)
+
+// pushCatalog is a fakeCatalog that accepts pushed nodes.
+type pushCatalog struct {
+	fakeCatalog
+	registry *discovery.PushResolver
+}
+
+func (p *pushCatalog) PushRegistry() *discovery.PushResolver { return p.registry }
+
+func pushRequest(t *testing.T, method, url, token, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestPushRoutesRegisterAndUpdateNodes(t *testing.T) {
+	catalog := &pushCatalog{registry: discovery.NewPushResolver("", time.Minute, nil)}
+	_, baseURL := startTestServerWithOptions(t, catalog, ServerOptions{PushToken: "s3cret"})
+	nodes := baseURL + "/v1/push/nodes"
+
+	resp := pushRequest(t, http.MethodPost, nodes, "s3cret", `{"node_id":"gpu-1","address":"10.0.0.9:50051","tasks":["ocr"]}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("register status = %d, want 201", resp.StatusCode)
+	}
+	var node discovery.PushedNode
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if node.Key != "local-gpu-1" || node.ExpiresAt.IsZero() {
+		t.Fatalf("registered node = %+v", node)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/gpu-1/heartbeat", "", http.StatusNoContent},
+		{http.MethodPut, "/gpu-1/load", `{"load":0.5}`, http.StatusNoContent},
+		{http.MethodPut, "/gpu-1/load", `{"load":2}`, http.StatusBadRequest},
+		{http.MethodPost, "/gpu-1/capabilities", `{"tasks_added":["face_detect"]}`, http.StatusNoContent},
+		{http.MethodPost, "/gpu-2/heartbeat", "", http.StatusNotFound},
+	} {
+		if resp := pushRequest(t, tc.method, nodes+tc.path, "s3cret", tc.body); resp.StatusCode != tc.want {
+			t.Errorf("%s %s = %d, want %d", tc.method, tc.path, resp.StatusCode, tc.want)
+		}
+	}
+	got := catalog.registry.Nodes()
+	if len(got) != 1 || got[0].Load == nil || *got[0].Load != 0.5 || strings.Join(got[0].Tasks, ",") != "face_detect,ocr" {
+		t.Fatalf("pushed nodes = %+v", got)
+	}
+
+	if resp := pushRequest(t, http.MethodDelete, nodes+"/gpu-1", "s3cret", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("deregister status = %d, want 204", resp.StatusCode)
+	}
+	if got := catalog.registry.Nodes(); len(got) != 0 {
+		t.Fatalf("pushed nodes after deregistration = %+v", got)
+	}
+}
+
+func TestPushRoutesRequireToken(t *testing.T) {
+	catalog := &pushCatalog{registry: discovery.NewPushResolver("", time.Minute, nil)}
+	_, baseURL := startTestServerWithOptions(t, catalog, ServerOptions{PushToken: "s3cret"})
+	body := `{"node_id":"gpu-1","address":"10.0.0.9:50051"}`
+
+	for _, token := range []string{"", "wrong"} {
+		resp := pushRequest(t, http.MethodPost, baseURL+"/v1/push/nodes", token, body)
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("token %q: status = %d, want 401 with a challenge", token, resp.StatusCode)
+		}
+	}
+	if got := catalog.registry.Nodes(); len(got) != 0 {
+		t.Fatalf("unauthenticated registration was accepted: %+v", got)
+	}
+
+	// Without a configured token nothing authenticates.
+	_, openURL := startTestServerWithOptions(t, catalog, ServerOptions{})
+	if resp := pushRequest(t, http.MethodPost, openURL+"/v1/push/nodes", "", body); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status without a configured token = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestPushRoutesNotImplementedWithoutRegistry(t *testing.T) {
+	_, baseURL := startTestServerWithOptions(t, &fakeCatalog{}, ServerOptions{PushToken: "s3cret"})
+	if resp := pushRequest(t, http.MethodGet, baseURL+"/v1/push/nodes", "s3cret", ""); resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("status = %d, want 501", resp.StatusCode)
+	}
+}
+
+func TestPushRoutesRejectNodesOffTheAllowlist(t *testing.T) {
+	filter, err := discovery.NewNodeFilter([]string{"gpu-*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := discovery.NewPushResolver("", time.Minute, nil)
+	registry.SetFilter(filter)
+	_, baseURL := startTestServerWithOptions(t, &pushCatalog{registry: registry}, ServerOptions{PushToken: "s3cret"})
+
+	resp := pushRequest(t, http.MethodPost, baseURL+"/v1/push/nodes", "s3cret", `{"node_id":"cpu-1","address":"10.0.0.9:50051"}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", resp.StatusCode)
+	}
+	if got := registry.Nodes(); len(got) != 0 {
+		t.Fatalf("rejected registration was kept: %+v", got)
+	}
+}
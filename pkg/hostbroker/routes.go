@@ -1,23 +1,87 @@
 package hostbroker
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
 // setupRoutes registers the Host Broker's discovery-only route set:
-// health, version, nodes, and nodes/watch. It must never register inference
-// routes (/v1/infer, streaming, LLM/MCP endpoints) — that is the one hard
-// invariant of this package.
-func setupRoutes(app *fiber.App, watch *nodeWatchHub, version VersionInfo, catalog NodeCatalog) {
+// health, ready, version, nodes, nodes/watch, nodes/:id, nodes/:id/drain
+// and undrain, capabilities, tasks/:name/explain, usage, jobs and jobs/:id,
+// queue and queue/retry-dead, and
+// the push registration routes under /v1/push, plus the
+// OpenAPI document describing them and, when enabled, its Swagger UI. It must
+// never register inference routes (/v1/infer, streaming, LLM/MCP endpoints) —
+// that is the one hard invariant of this package. Every route added here
+// needs an entry in apiRoutes.
+func setupRoutes(app *fiber.App, watch *nodeWatchHub, version VersionInfo, catalog NodeCatalog, opts ServerOptions) {
 	v1 := app.Group("/v1")
-	v1.Get("/health", healthHandler)
+	v1.Get("/health", healthHandler(version, catalog, opts.Election))
+	v1.Get("/ready", readyHandler(catalog))
 	v1.Get("/version", versionHandler(version))
 	v1.Get("/nodes", nodesHandler(catalog))
 	v1.Get("/nodes/watch", watch.upgrade)
+	v1.Get("/nodes/:id", nodeDetailHandler(catalog))
+	v1.Post("/nodes/:id/drain", drainHandler(catalog, true))
+	v1.Post("/nodes/:id/undrain", drainHandler(catalog, false))
+	v1.Get("/capabilities", capabilitiesHandler(catalog))
+	v1.Get("/tasks/:name/explain", explainHandler(catalog))
+	v1.Get("/usage", usageHandler(catalog))
+	v1.Get("/jobs", jobsHandler(catalog))
+	v1.Get("/jobs/:id", jobHandler(catalog, false))
+	v1.Delete("/jobs/:id", jobHandler(catalog, true))
+	v1.Get("/queue", queueHandler(catalog))
+	v1.Post("/queue/retry-dead", retryDeadHandler(catalog))
+
+	push := v1.Group("/push", pushAuth(catalog, opts.PushToken))
+	push.Get("/nodes", pushNodesHandler(catalog))
+	push.Post("/nodes", pushRegisterHandler(catalog))
+	push.Post("/nodes/:id/heartbeat", pushHeartbeatHandler(catalog))
+	push.Put("/nodes/:id/load", pushLoadHandler(catalog))
+	push.Post("/nodes/:id/capabilities", pushCapabilitiesHandler(catalog))
+	push.Delete("/nodes/:id", pushDeregisterHandler(catalog))
+
+	app.Get("/openapi.json", openAPIHandler(version, opts.Docs))
+	if opts.Docs {
+		app.Get("/docs", docsHandler)
+	}
 }
 
-func healthHandler(c *fiber.Ctx) error {
-	return c.Status(fiber.StatusOK).JSON(healthResponse{Status: "healthy"})
+// healthHandler answers 200 while the Broker is up. The status is
+// "degraded" while a discovery backend of the catalog is down, with the
+// failed backends under discovery, while the catalog is stale, with its
+// freshness under discovery.catalog, or when the catalog started degraded,
+// with its startup report under components. With leader election, the
+// Broker's role and lease are under election.
+func healthHandler(version VersionInfo, catalog NodeCatalog, election func() *LeaseStatus) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		resp := healthResponse{Status: "healthy", Version: version}
+		if reporter, ok := catalog.(DiscoveryStatusReporter); ok {
+			status := reporter.DiscoveryStatus()
+			resp.Discovery = &status
+			if status.Degraded || (status.Catalog != nil && status.Catalog.Stale) {
+				resp.Status = "degraded"
+			}
+		}
+		if reporter, ok := catalog.(StartupReporter); ok {
+			if report := reporter.GetStartupReport(); report != nil {
+				resp.Components = report.Components
+				if report.Status != discovery.StartupOK {
+					resp.Status = "degraded"
+				}
+			}
+		}
+		if election != nil {
+			resp.Election = election()
+		}
+		return c.Status(fiber.StatusOK).JSON(resp)
+	}
 }
 
 func versionHandler(version VersionInfo) fiber.Handler {
@@ -26,11 +90,300 @@ func versionHandler(version VersionInfo) fiber.Handler {
 	}
 }
 
+// nodesHandler serves the known nodes sorted by ID, filtered by the status,
+// task, runtime and labels query parameters and paged by offset and limit.
 func nodesHandler(catalog NodeCatalog) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		if catalog == nil {
-			return c.Status(fiber.StatusOK).JSON(nodesResponse{})
+		query, err := nodeQueryFromRequest(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(errorResponse{Error: err.Error()})
+		}
+		var nodes []*discovery.NodeInfo
+		if catalog != nil {
+			nodes = catalog.GetNodes()
+		}
+		page, total := query.Apply(nodes)
+		return c.Status(fiber.StatusOK).JSON(nodesResponse{Nodes: page, Total: total})
+	}
+}
+
+// nodeQueryFromRequest reads a discovery.NodeQuery from the query string.
+func nodeQueryFromRequest(c *fiber.Ctx) (discovery.NodeQuery, error) {
+	query := discovery.NodeQuery{
+		Status:  discovery.NodeStatus(c.Query("status")),
+		Task:    c.Query("task"),
+		Runtime: c.Query("runtime"),
+	}
+	labels, err := discovery.ParseLabelSelectors(c.Query("labels"))
+	if err != nil {
+		return query, err
+	}
+	query.Labels = labels
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"offset", &query.Offset}, {"limit", &query.Limit}} {
+		if v := c.Query(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return query, fmt.Errorf("%s must be a non-negative integer", p.name)
+			}
+			*p.dst = n
+		}
+	}
+	return query, nil
+}
+
+// nodeDetailHandler serves one node, including its last capability change
+// (last_capability_change / last_capability_diff) when there has been one
+// and its recent errors when the catalog is a NodeErrorReporter.
+func nodeDetailHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if catalog != nil {
+			for _, node := range catalog.GetNodes() {
+				if node != nil && node.ID == id {
+					if reporter, ok := catalog.(NodeErrorReporter); ok {
+						node = node.Clone()
+						node.RecentErrors = reporter.RecentNodeErrors(id)
+					}
+					return c.Status(fiber.StatusOK).JSON(node)
+				}
+			}
+		}
+		return c.Status(fiber.StatusNotFound).JSON(errorResponse{Error: "node " + id + " not found"})
+	}
+}
+
+// drainHandler drains (drain) or undrains the node named in the path and
+// serves it as it stands afterwards. A drain takes an optional timeout
+// (e.g. "30m") after which the node is undrained. Apps watching the Broker
+// see a drained node as removed, since it is no longer active.
+func drainHandler(catalog NodeCatalog, drain bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		drainer, ok := catalog.(NodeDrainer)
+		if !ok {
+			return c.Status(fiber.StatusNotImplemented).JSON(errorResponse{Error: "node catalog does not route requests"})
+		}
+		id := c.Params("id")
+		var err error
+		if drain {
+			var timeout time.Duration
+			if v := c.Query("timeout"); v != "" {
+				timeout, err = time.ParseDuration(v)
+				if err != nil || timeout <= 0 {
+					return c.Status(fiber.StatusBadRequest).JSON(errorResponse{Error: "timeout must be a positive duration such as 30m"})
+				}
+			}
+			err = drainer.DrainNodeFor(id, timeout)
+		} else {
+			err = drainer.UndrainNode(id)
+		}
+		switch {
+		case utils.HasErrorCode(err, utils.ErrCodeNodeNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse{Error: "node " + id + " not found"})
+		case err != nil:
+			return c.Status(fiber.StatusServiceUnavailable).JSON(errorResponse{Error: err.Error()})
+		}
+		for _, node := range catalog.GetNodes() {
+			if node != nil && node.ID == id {
+				return c.Status(fiber.StatusOK).JSON(node)
+			}
+		}
+		return c.Status(fiber.StatusNotFound).JSON(errorResponse{Error: "node " + id + " not found"})
+	}
+}
+
+// statusClientClosedRequest is the non-standard status, first used by
+// nginx, for a request its caller abandoned.
+const statusClientClosedRequest = 499
+
+// errorStatus maps an error from a catalog call that reached a node to an
+// HTTP status: 499 when the request was cancelled, 504 when it timed out
+// and 503 otherwise, so a caller going away is not reported as a 5xx.
+func errorStatus(err error) int {
+	switch {
+	case utils.HasErrorCode(err, utils.ErrCodeCancelled):
+		return statusClientClosedRequest
+	case utils.HasErrorCode(err, utils.ErrCodeTimeout):
+		return fiber.StatusGatewayTimeout
+	}
+	return fiber.StatusServiceUnavailable
+}
+
+// capabilitiesHandler serves the cluster capabilities merged by task,
+// filtered by the runtime, precision and model query parameters.
+func capabilitiesHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filter := discovery.CapabilityFilter{
+			Runtime:   c.Query("runtime"),
+			Precision: c.Query("precision"),
+			ModelID:   c.Query("model"),
+		}
+		var nodes []*discovery.NodeInfo
+		if catalog != nil {
+			nodes = catalog.GetNodes()
+		}
+		return c.Status(fiber.StatusOK).JSON(discovery.AggregateCapabilities(nodes, filter))
+	}
+}
+
+// explainHandler reports how the catalog would route a request for the task
+// named in the path, without dispatching one.
+func explainHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		explainer, ok := catalog.(SelectionExplainer)
+		if !ok {
+			return c.Status(fiber.StatusNotImplemented).JSON(errorResponse{Error: "node catalog does not route requests"})
+		}
+		exp, err := explainer.ExplainSelection(c.UserContext(), c.Params("name"))
+		if err != nil {
+			return c.Status(errorStatus(err)).JSON(errorResponse{Error: err.Error()})
+		}
+		return c.Status(fiber.StatusOK).JSON(exp)
+	}
+}
+
+// readyHandler reports whether each task has its minimum of healthy nodes:
+// the comma-separated tasks given, or those the catalog requires a minimum
+// for. It answers 200 when all do and 503 otherwise.
+func readyHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		reporter, ok := catalog.(TaskReadinessReporter)
+		if !ok {
+			return c.Status(fiber.StatusNotImplemented).JSON(errorResponse{Error: "node catalog does not route requests"})
+		}
+		var tasks []string
+		for _, task := range strings.Split(c.Query("tasks"), ",") {
+			if task = strings.TrimSpace(task); task != "" {
+				tasks = append(tasks, task)
+			}
+		}
+		resp := readyResponse{Ready: true, Tasks: reporter.TaskReadiness(tasks...)}
+		for _, task := range resp.Tasks {
+			resp.Ready = resp.Ready && task.Ready
+		}
+		status := fiber.StatusOK
+		if !resp.Ready {
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(resp)
+	}
+}
+
+// usageHandler serves per-tenant usage. The window is since..until
+// (RFC 3339), or the trailing window duration (e.g. "1h"); omitted bounds
+// mean as far back as the catalog keeps and now.
+func usageHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		reporter, ok := catalog.(UsageReporter)
+		if !ok {
+			return c.Status(fiber.StatusNotImplemented).JSON(errorResponse{Error: "node catalog does not account usage"})
+		}
+		var since, until time.Time
+		for _, p := range []struct {
+			name string
+			dst  *time.Time
+		}{{"since", &since}, {"until", &until}} {
+			if v := c.Query(p.name); v != "" {
+				t, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(errorResponse{Error: p.name + " must be an RFC 3339 time: " + err.Error()})
+				}
+				*p.dst = t
+			}
+		}
+		if v := c.Query("window"); v != "" {
+			window, err := time.ParseDuration(v)
+			if err != nil || window <= 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(errorResponse{Error: "window must be a positive duration such as 1h"})
+			}
+			if !since.IsZero() {
+				return c.Status(fiber.StatusBadRequest).JSON(errorResponse{Error: "window and since are mutually exclusive"})
+			}
+			end := until
+			if end.IsZero() {
+				end = time.Now()
+			}
+			since = end.Add(-window)
+		}
+		return c.Status(fiber.StatusOK).JSON(reporter.Usage(since, until))
+	}
+}
+
+// jobsHandler lists the asynchronous jobs the catalog remembers.
+func jobsHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		jobs, ok := catalog.(JobManager)
+		if !ok {
+			return c.Status(fiber.StatusNotImplemented).JSON(errorResponse{Error: "node catalog does not run jobs"})
+		}
+		return c.Status(fiber.StatusOK).JSON(jobsResponse{Jobs: jobs.Jobs()})
+	}
+}
+
+// jobHandler serves the job named in the path as its node reports it,
+// after asking the node to cancel it when cancel is set.
+func jobHandler(catalog NodeCatalog, cancel bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		jobs, ok := catalog.(JobManager)
+		if !ok {
+			return c.Status(fiber.StatusNotImplemented).JSON(errorResponse{Error: "node catalog does not run jobs"})
+		}
+		id := c.Params("id")
+		var err error
+		if cancel {
+			err = jobs.CancelJob(c.UserContext(), id)
+		}
+		var job *discovery.JobStatus
+		if err == nil {
+			job, err = jobs.JobStatus(c.UserContext(), id)
+		}
+		switch {
+		case utils.HasErrorCode(err, utils.ErrCodeNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(errorResponse{Error: "job " + id + " not found"})
+		case err != nil:
+			return c.Status(errorStatus(err)).JSON(errorResponse{Error: err.Error()})
+		}
+		return c.Status(fiber.StatusOK).JSON(job)
+	}
+}
+
+// queueManager returns the catalog's durable queue, or answers 501 when it
+// has none or it is disabled.
+func queueManager(c *fiber.Ctx, catalog NodeCatalog) (QueueManager, error) {
+	queue, ok := catalog.(QueueManager)
+	if !ok {
+		return nil, c.Status(fiber.StatusNotImplemented).JSON(errorResponse{Error: "node catalog has no durable queue"})
+	}
+	if !queue.QueueStatus().Enabled {
+		return nil, c.Status(fiber.StatusNotImplemented).JSON(errorResponse{Error: "durable queue is disabled; set queue.dir"})
+	}
+	return queue, nil
+}
+
+// queueHandler serves the durable queue's depth, counters and dead letters.
+func queueHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		queue, err := queueManager(c, catalog)
+		if queue == nil {
+			return err
+		}
+		return c.Status(fiber.StatusOK).JSON(queue.QueueStatus())
+	}
+}
+
+// retryDeadHandler queues the durable queue's dead letters again.
+func retryDeadHandler(catalog NodeCatalog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		queue, err := queueManager(c, catalog)
+		if queue == nil {
+			return err
+		}
+		retried, err := queue.RetryDeadLetters()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(errorResponse{Error: err.Error()})
 		}
-		return c.Status(fiber.StatusOK).JSON(nodesResponse{Nodes: catalog.GetNodes()})
+		return c.Status(fiber.StatusOK).JSON(retryDeadResponse{Retried: retried})
 	}
 }
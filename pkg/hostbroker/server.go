@@ -9,10 +9,17 @@
 package hostbroker
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"os"
 	"time"
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"github.com/edwinzhancn/lumen-sdk/pkg/version"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"go.uber.org/zap"
 )
 
@@ -23,17 +30,94 @@ import (
 // later without an API break here.
 type NodeCatalog interface {
 	GetNodes() []*discovery.NodeInfo
-	WatchNodes(cb func([]*discovery.NodeInfo))
+	// WatchNodes registers cb for node-list changes and returns the func
+	// that unregisters it.
+	WatchNodes(cb func([]*discovery.NodeInfo)) (unsubscribe func())
 }
 
-// VersionInfo is build-time version metadata surfaced at GET /v1/version.
-// Callers populate this from ldflags-injected main package variables.
-type VersionInfo struct {
-	Version   string `json:"version"`
-	Commit    string `json:"commit"`
-	BuildTime string `json:"build_time"`
+// SelectionExplainer is implemented by catalogs that route requests
+// themselves, such as *client.LumenClient. When the catalog passed to
+// NewServer implements it, GET /v1/tasks/:name/explain reports how a request
+// for the task would be routed; otherwise that route answers 501.
+type SelectionExplainer interface {
+	ExplainSelection(ctx context.Context, task string) (*discovery.SelectionExplanation, error)
 }
 
+// UsageReporter is implemented by catalogs that account for requests per
+// tenant, such as *client.LumenClient. When the catalog passed to NewServer
+// implements it, GET /v1/usage serves its report; otherwise that route
+// answers 501.
+type UsageReporter interface {
+	Usage(since, until time.Time) *discovery.UsageReport
+}
+
+// NodeDrainer is implemented by catalogs that route requests themselves,
+// such as *client.LumenClient. When the catalog passed to NewServer
+// implements it, POST /v1/nodes/:id/drain and /undrain take a node out of
+// and back into selection; otherwise those routes answer 501.
+type NodeDrainer interface {
+	DrainNodeFor(nodeID string, timeout time.Duration) error
+	UndrainNode(nodeID string) error
+}
+
+// JobManager is implemented by catalogs that run asynchronous jobs on
+// nodes, such as *client.LumenClient. When the catalog passed to NewServer
+// implements it, GET /v1/jobs, GET /v1/jobs/:id and DELETE /v1/jobs/:id list,
+// query and cancel the jobs it submitted; otherwise those routes answer 501.
+// Jobs are submitted through the SDK only: the Broker serves no inference.
+type JobManager interface {
+	Jobs() []discovery.JobStatus
+	JobStatus(ctx context.Context, id string) (*discovery.JobStatus, error)
+	CancelJob(ctx context.Context, id string) error
+}
+
+// QueueManager is implemented by catalogs with a durable request queue,
+// such as *client.LumenClient. When the catalog passed to NewServer
+// implements it, GET /v1/queue describes the queue and POST
+// /v1/queue/retry-dead queues its dead letters again; otherwise those routes
+// answer 501, as they do while the queue is disabled. Requests are queued
+// through the SDK only: the Broker serves no inference.
+type QueueManager interface {
+	QueueStatus() discovery.QueueStatus
+	RetryDeadLetters() (int, error)
+}
+
+// DiscoveryStatusReporter is implemented by catalogs that discover nodes
+// from several backends and keep running when some fail to start. /v1/health
+// reports status "degraded" while any is down, or while the catalog is stale.
+type DiscoveryStatusReporter interface {
+	DiscoveryStatus() discovery.DiscoveryStatus
+}
+
+// StartupReporter is implemented by catalogs that report how their
+// subsystems came up, such as *client.LumenClient. /v1/health lists the
+// components under components and reports status "degraded" unless startup
+// was clean.
+type StartupReporter interface {
+	GetStartupReport() *discovery.StartupReport
+}
+
+// NodeErrorReporter is implemented by catalogs that keep each node's latest
+// failures, such as *client.LumenClient. When the catalog passed to NewServer
+// implements it, GET /v1/nodes/:id lists them under recent_errors.
+type NodeErrorReporter interface {
+	RecentNodeErrors(nodeID string) []discovery.RecentError
+}
+
+// TaskReadinessReporter is implemented by catalogs that require a minimum
+// of healthy nodes per task, such as *client.LumenClient. When the catalog
+// passed to NewServer implements it, GET /v1/ready answers 503 while a task
+// is below its minimum, so orchestration can hold a rollout until it is
+// met; otherwise that route answers 501.
+type TaskReadinessReporter interface {
+	TaskReadiness(tasks ...string) []discovery.TaskReadiness
+}
+
+// VersionInfo is build-time version metadata surfaced at GET /v1/version
+// and in /v1/health. Callers populate it with version.Get(); when Features
+// is nil, NewServerWithOptions lists the optional routes it serves.
+type VersionInfo = version.Info
+
 // Server is the Host Broker's HTTP/WebSocket surface.
 type Server struct {
 	app    *fiber.App
@@ -41,27 +125,100 @@ type Server struct {
 	logger *zap.Logger
 }
 
-// NewServer constructs a Server. catalog may be nil only in tests exercising
-// the health/version routes in isolation; production callers must pass a
-// real NodeCatalog.
+// ServerOptions controls optional parts of the Server's route surface and
+// its connection timeouts.
+type ServerOptions struct {
+	// Docs serves a Swagger UI for the /openapi.json document at /docs.
+	Docs bool
+	// ReadTimeout, WriteTimeout and IdleTimeout are as in
+	// config.BrokerConfig. A request not fully read within ReadTimeout is
+	// answered with 408. Zero means no limit.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// PushToken is the bearer token nodes must present to the /v1/push
+	// routes; with it empty they reject every request.
+	PushToken string
+	// Election, when set, reports the Broker's role in an active/standby
+	// pair under election in /v1/health.
+	Election func() *LeaseStatus
+}
+
+// NewServer constructs a Server with default options. catalog may be nil only
+// in tests exercising the health/version routes in isolation; production
+// callers must pass a real NodeCatalog.
 func NewServer(catalog NodeCatalog, version VersionInfo, logger *zap.Logger) *Server {
+	return NewServerWithOptions(catalog, version, ServerOptions{}, logger)
+}
+
+// NewServerWithOptions constructs a Server.
+func NewServerWithOptions(catalog NodeCatalog, version VersionInfo, opts ServerOptions, logger *zap.Logger) *Server {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
 	app := fiber.New(fiber.Config{
 		AppName:               "Lumen Host Broker",
 		DisableStartupMessage: true,
+		ReadTimeout:           opts.ReadTimeout,
+		WriteTimeout:          opts.WriteTimeout,
+		IdleTimeout:           opts.IdleTimeout,
 	})
 
+	if version.Features == nil {
+		version.Features = serverFeatures(catalog, opts)
+	}
 	s := &Server{
 		app:    app,
 		watch:  newNodeWatchHub(catalog, logger),
 		logger: logger,
 	}
-	setupRoutes(app, s.watch, version, catalog)
+	app.Use(requestid.New(), requestLogger(logger))
+	setupRoutes(app, s.watch, version, catalog, opts)
 	return s
 }
 
+// requestLogger seeds each request's context with logger carrying the
+// request's X-Request-ID, so what the catalog logs while serving it, such as
+// a job status query sent to a node, can be tied to the HTTP request.
+func requestLogger(logger *zap.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.GetRespHeader(fiber.HeaderXRequestID)
+		c.SetUserContext(utils.WithRequestLogger(c.UserContext(), logger, zap.String("request_id", id)))
+		return c.Next()
+	}
+}
+
+// serverFeatures names the optional routes a Server for catalog and opts
+// serves.
+func serverFeatures(catalog NodeCatalog, opts ServerOptions) []string {
+	features := []string{}
+	if opts.Docs {
+		features = append(features, "docs")
+	}
+	if _, ok := catalog.(SelectionExplainer); ok {
+		features = append(features, "explain")
+	}
+	if _, ok := catalog.(UsageReporter); ok {
+		features = append(features, "usage")
+	}
+	if _, ok := catalog.(NodeDrainer); ok {
+		features = append(features, "drain")
+	}
+	if _, ok := catalog.(JobManager); ok {
+		features = append(features, "jobs")
+	}
+	if _, ok := catalog.(QueueManager); ok {
+		features = append(features, "queue")
+	}
+	if _, ok := catalog.(TaskReadinessReporter); ok {
+		features = append(features, "ready")
+	}
+	if pushRegistry(catalog) != nil {
+		features = append(features, "push")
+	}
+	return features
+}
+
 // App exposes the underlying Fiber app, e.g. for tests that need to attach a
 // pre-bound listener.
 func (s *Server) App() *fiber.App {
@@ -74,6 +231,53 @@ func (s *Server) Start(addr string) error {
 	return s.app.Listen(addr)
 }
 
+// StartUnix runs the HTTP server on a unix domain socket at path, blocking
+// until it stops or fails. A stale socket file from an earlier run is
+// removed first; a path that is still being served, or that is not a socket,
+// is left alone and reported as an error. The socket is chmod'ed to mode so
+// filesystem permissions control who may connect.
+func (s *Server) StartUnix(path string, mode os.FileMode) error {
+	ln, err := ListenUnix(path, mode)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve runs the HTTP server on an already-bound listener, blocking until it
+// stops or fails.
+func (s *Server) Serve(ln net.Listener) error {
+	s.logger.Info("starting Host Broker server", zap.String("address", ln.Addr().String()))
+	return s.app.Listener(ln)
+}
+
+// ListenUnix binds the unix socket used by StartUnix. Callers that need
+// bind errors synchronously can call it themselves and pass the listener to
+// Serve.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("broker socket %s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("broker socket %s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale broker socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("chmod broker socket: %w", err)
+	}
+	return ln, nil
+}
+
 // Shutdown gracefully stops the server: no new connections, waits for
 // in-flight regular HTTP requests. It does not close already-hijacked
 // /v1/nodes/watch connections; call Close for that.
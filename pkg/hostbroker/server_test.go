@@ -1,18 +1,29 @@
 package hostbroker
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
 	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"github.com/gofiber/fiber/v2"
 	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 // fakeCatalog is a minimal, directly-controllable NodeCatalog test double.
@@ -28,10 +39,11 @@ func (f *fakeCatalog) GetNodes() []*discovery.NodeInfo {
 	return f.nodes
 }
 
-func (f *fakeCatalog) WatchNodes(cb func([]*discovery.NodeInfo)) {
+func (f *fakeCatalog) WatchNodes(cb func([]*discovery.NodeInfo)) func() {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.watchers = append(f.watchers, cb)
+	return func() {}
 }
 
 func (f *fakeCatalog) set(nodes []*discovery.NodeInfo) {
@@ -61,8 +73,13 @@ func activeNode(id, addr string, tasks ...string) *discovery.NodeInfo {
 // the base HTTP URL.
 func startTestServer(t *testing.T, catalog NodeCatalog) (*Server, string) {
 	t.Helper()
+	return startTestServerWithOptions(t, catalog, ServerOptions{})
+}
+
+func startTestServerWithOptions(t *testing.T, catalog NodeCatalog, opts ServerOptions) (*Server, string) {
+	t.Helper()
 
-	srv := NewServer(catalog, VersionInfo{Version: "test"}, nil)
+	srv := NewServerWithOptions(catalog, VersionInfo{Version: "test"}, opts, nil)
 	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatalf("listen: %v", err)
@@ -88,13 +105,111 @@ func TestServerHealthEndpoint(t *testing.T) {
 	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
-	if body.Status != "healthy" {
-		t.Fatalf("status = %q, want healthy", body.Status)
+	if body.Status != "healthy" || body.Version.Version != "test" {
+		t.Fatalf("health = %+v, want healthy with version test", body)
+	}
+}
+
+type degradedCatalog struct {
+	fakeCatalog
+	status discovery.DiscoveryStatus
+}
+
+func (d *degradedCatalog) DiscoveryStatus() discovery.DiscoveryStatus { return d.status }
+
+func TestServerHealthReportsDegradedDiscovery(t *testing.T) {
+	catalog := &degradedCatalog{status: discovery.DiscoveryStatus{
+		Degraded: true,
+		Failed:   []discovery.BackendFailure{{Backend: "mdns", Error: "no multicast route", Attempts: 2}},
+	}}
+	_, baseURL := startTestServer(t, catalog)
+
+	resp, err := http.Get(baseURL + "/v1/health")
+	if err != nil {
+		t.Fatalf("GET /v1/health: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200 while degraded", resp.StatusCode)
+	}
+	var body healthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Status != "degraded" || body.Discovery == nil || len(body.Discovery.Failed) != 1 || body.Discovery.Failed[0].Backend != "mdns" {
+		t.Fatalf("health = %+v, want degraded naming mdns", body)
+	}
+}
+
+func TestServerHealthReportsStaleCatalog(t *testing.T) {
+	lastScan := time.Now().Add(-20 * time.Minute)
+	catalog := &degradedCatalog{status: discovery.DiscoveryStatus{
+		Catalog: &discovery.CatalogFreshness{
+			LastScan: lastScan,
+			Age:      20 * time.Minute,
+			Oldest:   "local/gpu-1",
+			MaxAge:   10 * time.Minute,
+			Stale:    true,
+		},
+	}}
+	_, baseURL := startTestServer(t, catalog)
+
+	resp, err := http.Get(baseURL + "/v1/health")
+	if err != nil {
+		t.Fatalf("GET /v1/health: %v", err)
+	}
+	defer resp.Body.Close()
+	var body healthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Status != "degraded" || body.Discovery == nil || body.Discovery.Catalog == nil || !body.Discovery.Catalog.LastScan.Equal(lastScan) {
+		t.Fatalf("health = %+v, want degraded with the catalog's last scan", body)
+	}
+}
+
+type startupCatalog struct {
+	fakeCatalog
+	report *discovery.StartupReport
+}
+
+func (s *startupCatalog) GetStartupReport() *discovery.StartupReport { return s.report }
+
+func TestServerHealthReportsStartupComponents(t *testing.T) {
+	for _, tc := range []struct {
+		name, status, want string
+	}{
+		{name: "clean", status: discovery.StartupOK, want: "healthy"},
+		{name: "degraded", status: discovery.StartupDegraded, want: "degraded"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			catalog := &startupCatalog{report: &discovery.StartupReport{
+				Status: tc.status,
+				Components: map[string]discovery.ComponentStatus{
+					"pool":  {Status: discovery.StartupOK},
+					"nodes": {Status: tc.status},
+				},
+			}}
+			_, baseURL := startTestServer(t, catalog)
+
+			resp, err := http.Get(baseURL + "/v1/health")
+			if err != nil {
+				t.Fatalf("GET /v1/health: %v", err)
+			}
+			defer resp.Body.Close()
+			var body healthResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Status != tc.want || body.Components["nodes"].Status != tc.status || len(body.Components) != 2 {
+				t.Fatalf("health = %+v, want %s with the startup components", body, tc.want)
+			}
+		})
 	}
 }
 
 func TestServerVersionEndpoint(t *testing.T) {
-	srv := NewServer(nil, VersionInfo{Version: "1.2.3", Commit: "abc123", BuildTime: "2026-07-10T00:00:00Z"}, nil)
+	srv := NewServerWithOptions(nil, VersionInfo{Version: "1.2.3", Commit: "abc123", BuildTime: "2026-07-10T00:00:00Z"}, ServerOptions{Docs: true}, nil)
 	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatalf("listen: %v", err)
@@ -114,6 +229,10 @@ func TestServerVersionEndpoint(t *testing.T) {
 	if body.Version != "1.2.3" || body.Commit != "abc123" {
 		t.Fatalf("version = %+v, want Version=1.2.3 Commit=abc123", body)
 	}
+	// The nil catalog explains no selections and reports no usage.
+	if len(body.Features) != 1 || body.Features[0] != "docs" {
+		t.Fatalf("features = %v, want [docs]", body.Features)
+	}
 }
 
 func TestServerNodesEndpoint(t *testing.T) {
@@ -134,6 +253,602 @@ func TestServerNodesEndpoint(t *testing.T) {
 	}
 }
 
+// shuffledCatalog returns its nodes in a different order on every call, as
+// a catalog backed by a map does.
+type shuffledCatalog struct {
+	fakeCatalog
+	calls int
+}
+
+func (s *shuffledCatalog) GetNodes() []*discovery.NodeInfo {
+	nodes := s.fakeCatalog.GetNodes()
+	s.calls++
+	out := make([]*discovery.NodeInfo, 0, len(nodes))
+	out = append(out, nodes[s.calls%len(nodes):]...)
+	return append(out, nodes[:s.calls%len(nodes)]...)
+}
+
+func TestServerNodesEndpointFiltersAndPages(t *testing.T) {
+	node := func(id string, status discovery.NodeStatus, zone string, tasks ...string) *discovery.NodeInfo {
+		n := activeNode(id, "10.0.0.1:50051", tasks...)
+		n.Status = status
+		n.Metadata = map[string]interface{}{"zone": zone}
+		return n
+	}
+	catalog := &shuffledCatalog{fakeCatalog: fakeCatalog{nodes: []*discovery.NodeInfo{
+		node("node-e", discovery.NodeStatusActive, "a", "ocr"),
+		node("node-b", discovery.NodeStatusActive, "a", "ocr", "face"),
+		node("node-d", discovery.NodeStatusDraining, "a", "ocr"),
+		node("node-a", discovery.NodeStatusActive, "b", "ocr"),
+		node("node-c", discovery.NodeStatusActive, "a", "face"),
+	}}}
+	_, baseURL := startTestServer(t, catalog)
+
+	get := func(query string) (int, nodesResponse) {
+		t.Helper()
+		resp, err := http.Get(baseURL + "/v1/nodes" + query)
+		if err != nil {
+			t.Fatalf("GET /v1/nodes%s: %v", query, err)
+		}
+		defer resp.Body.Close()
+		var body nodesResponse
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+	ids := func(body nodesResponse) string {
+		var out []string
+		for _, n := range body.Nodes {
+			out = append(out, n.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	for range 3 {
+		if _, body := get(""); ids(body) != "node-a,node-b,node-c,node-d,node-e" || body.Total != 5 {
+			t.Fatalf("nodes = %s of %d, want all five sorted by ID on every call", ids(body), body.Total)
+		}
+	}
+
+	for _, tc := range []struct{ query, want string }{
+		{"?status=active&task=ocr&labels=zone%3Da", "node-b,node-e"},
+		{"?status=active&task=ocr&labels=zone%3Da&limit=1", "node-b"},
+		{"?status=active&task=ocr&labels=zone%3Da&offset=1&limit=1", "node-e"},
+		{"?task=face&labels=zone!%3Db", "node-b,node-c"},
+	} {
+		status, body := get(tc.query)
+		if status != http.StatusOK || ids(body) != tc.want {
+			t.Fatalf("%s: HTTP %d nodes %s, want %s", tc.query, status, ids(body), tc.want)
+		}
+		if tc.want == "node-e" && body.Total != 2 {
+			t.Fatalf("%s: total = %d, want every match counted", tc.query, body.Total)
+		}
+	}
+
+	for _, query := range []string{"?limit=-1", "?offset=x", "?labels=%3Da"} {
+		if status, _ := get(query); status != http.StatusBadRequest {
+			t.Fatalf("%s: HTTP %d, want 400", query, status)
+		}
+	}
+}
+
+func TestServerNodeDetailEndpoint(t *testing.T) {
+	changed := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	node := activeNode("node-a", "10.0.0.1:50051", "ocr")
+	node.LastCapabilityChange = changed
+	node.LastCapabilityDiff = &discovery.CapabilityDiff{NodeID: "node-a", At: changed, TasksAdded: []string{"ocr"}}
+	_, baseURL := startTestServer(t, &fakeCatalog{nodes: []*discovery.NodeInfo{node}})
+
+	resp, err := http.Get(baseURL + "/v1/nodes/node-a")
+	if err != nil {
+		t.Fatalf("GET /v1/nodes/node-a: %v", err)
+	}
+	defer resp.Body.Close()
+	var body discovery.NodeInfo
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !body.LastCapabilityChange.Equal(changed) || body.LastCapabilityDiff == nil ||
+		len(body.LastCapabilityDiff.TasksAdded) != 1 {
+		t.Fatalf("last change = %v, diff = %+v", body.LastCapabilityChange, body.LastCapabilityDiff)
+	}
+
+	missing, err := http.Get(baseURL + "/v1/nodes/node-z")
+	if err != nil {
+		t.Fatalf("GET /v1/nodes/node-z: %v", err)
+	}
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown node status = %d, want 404", missing.StatusCode)
+	}
+}
+
+// erroringCatalog is a fakeCatalog that keeps each node's recent errors.
+type erroringCatalog struct {
+	fakeCatalog
+	errs map[string][]discovery.RecentError
+}
+
+func (e *erroringCatalog) RecentNodeErrors(nodeID string) []discovery.RecentError {
+	return e.errs[nodeID]
+}
+
+func TestServerNodeDetailListsRecentErrors(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	node := activeNode("node-a", "10.0.0.1:50051", "ocr")
+	catalog := &erroringCatalog{
+		fakeCatalog: fakeCatalog{nodes: []*discovery.NodeInfo{node}},
+		errs: map[string][]discovery.RecentError{"node-a": {
+			{At: at, Op: discovery.NodeOpHealth, Code: "UNAVAILABLE", Message: "not serving"},
+			{At: at.Add(time.Second), Op: discovery.NodeOpInfer, Code: "TIMEOUT", CorrelationID: "req-7", Message: "deadline exceeded"},
+		}},
+	}
+	_, baseURL := startTestServer(t, catalog)
+
+	detail, err := http.Get(baseURL + "/v1/nodes/node-a")
+	if err != nil {
+		t.Fatalf("GET /v1/nodes/node-a: %v", err)
+	}
+	defer detail.Body.Close()
+	var body discovery.NodeInfo
+	if err := json.NewDecoder(detail.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.RecentErrors) != 2 || body.RecentErrors[1].CorrelationID != "req-7" || body.RecentErrors[0].Op != discovery.NodeOpHealth {
+		t.Fatalf("recent errors = %+v", body.RecentErrors)
+	}
+	if node.RecentErrors != nil {
+		t.Fatal("node detail modified the catalog's node")
+	}
+
+	var list nodesResponse
+	resp, err := http.Get(baseURL + "/v1/nodes")
+	if err != nil {
+		t.Fatalf("GET /v1/nodes: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Nodes) != 1 || list.Nodes[0].RecentErrors != nil {
+		t.Fatalf("node list = %+v, want recent errors left out", list.Nodes)
+	}
+}
+
+// readinessCatalog is a fakeCatalog with healthy node counts per task and
+// a minimum for face.
+type readinessCatalog struct {
+	fakeCatalog
+	healthy map[string]int
+}
+
+func (r *readinessCatalog) TaskReadiness(tasks ...string) []discovery.TaskReadiness {
+	if len(tasks) == 0 {
+		tasks = []string{"face"}
+	}
+	var out []discovery.TaskReadiness
+	for _, task := range tasks {
+		required := 1
+		if task == "face" {
+			required = 2
+		}
+		out = append(out, discovery.TaskReadiness{Task: task, Healthy: r.healthy[task], Required: required, Ready: r.healthy[task] >= required})
+	}
+	return out
+}
+
+func TestServerReadyEndpoint(t *testing.T) {
+	catalog := &readinessCatalog{healthy: map[string]int{"face": 2, "ocr": 0}}
+	_, baseURL := startTestServer(t, catalog)
+
+	get := func(path string) (int, readyResponse) {
+		t.Helper()
+		resp, err := http.Get(baseURL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var body readyResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.StatusCode, body
+	}
+	if code, body := get("/v1/ready"); code != http.StatusOK || !body.Ready || len(body.Tasks) != 1 || body.Tasks[0].Healthy != 2 {
+		t.Fatalf("status = %d, body = %+v, want face ready", code, body)
+	}
+	if code, body := get("/v1/ready?tasks=face,%20ocr"); code != http.StatusServiceUnavailable || body.Ready || len(body.Tasks) != 2 || body.Tasks[1].Task != "ocr" {
+		t.Fatalf("status = %d, body = %+v, want ocr not ready", code, body)
+	}
+
+	_, plainURL := startTestServer(t, &fakeCatalog{})
+	plain, err := http.Get(plainURL + "/v1/ready")
+	if err != nil {
+		t.Fatalf("GET /v1/ready: %v", err)
+	}
+	plain.Body.Close()
+	if plain.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("status without a readiness reporter = %d, want 501", plain.StatusCode)
+	}
+}
+
+// explainingCatalog is a fakeCatalog that also routes requests.
+type explainingCatalog struct {
+	fakeCatalog
+	exp *discovery.SelectionExplanation
+}
+
+func (e *explainingCatalog) ExplainSelection(_ context.Context, task string) (*discovery.SelectionExplanation, error) {
+	exp := *e.exp
+	exp.Task = task
+	return &exp, nil
+}
+
+func TestServerExplainEndpoint(t *testing.T) {
+	catalog := &explainingCatalog{exp: &discovery.SelectionExplanation{
+		Strategy: "round_robin",
+		Pick:     "node-a",
+		Candidates: []discovery.SelectionCandidate{
+			{NodeID: "node-a", State: "READY", Eligible: true},
+			{NodeID: "node-b", State: "READY", Reason: discovery.SelectionUnsupportedTask},
+		},
+	}}
+	_, baseURL := startTestServer(t, catalog)
+
+	resp, err := http.Get(baseURL + "/v1/tasks/ocr/explain")
+	if err != nil {
+		t.Fatalf("GET /v1/tasks/ocr/explain: %v", err)
+	}
+	defer resp.Body.Close()
+	var body discovery.SelectionExplanation
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Task != "ocr" || body.Pick != "node-a" || len(body.Candidates) != 2 ||
+		body.Candidates[1].Reason != discovery.SelectionUnsupportedTask {
+		t.Fatalf("status = %d, body = %+v", resp.StatusCode, body)
+	}
+
+	_, plainURL := startTestServer(t, &fakeCatalog{})
+	plain, err := http.Get(plainURL + "/v1/tasks/ocr/explain")
+	if err != nil {
+		t.Fatalf("GET /v1/tasks/ocr/explain: %v", err)
+	}
+	plain.Body.Close()
+	if plain.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("status without explainer = %d, want 501", plain.StatusCode)
+	}
+}
+
+// drainingCatalog is a fakeCatalog that drains nodes by setting their
+// status, recording the timeout it was given.
+type drainingCatalog struct {
+	fakeCatalog
+	timeout time.Duration
+}
+
+func (d *drainingCatalog) setStatus(nodeID string, status discovery.NodeStatus) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, node := range d.nodes {
+		if node.ID == nodeID {
+			node.Status = status
+			return nil
+		}
+	}
+	return utils.NodeNotFoundError(nodeID)
+}
+
+func (d *drainingCatalog) DrainNodeFor(nodeID string, timeout time.Duration) error {
+	d.timeout = timeout
+	return d.setStatus(nodeID, discovery.NodeStatusDraining)
+}
+
+func (d *drainingCatalog) UndrainNode(nodeID string) error {
+	return d.setStatus(nodeID, discovery.NodeStatusActive)
+}
+
+func TestServerDrainEndpoints(t *testing.T) {
+	catalog := &drainingCatalog{}
+	catalog.set([]*discovery.NodeInfo{activeNode("gpu-1", "10.0.0.1:50051", "ocr")})
+	_, baseURL := startTestServer(t, catalog)
+
+	post := func(path string) (int, *discovery.NodeInfo) {
+		t.Helper()
+		resp, err := http.Post(baseURL+path, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		node := &discovery.NodeInfo{}
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(node); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return resp.StatusCode, node
+	}
+
+	if code, node := post("/v1/nodes/gpu-1/drain?timeout=30m"); code != http.StatusOK || node.Status != discovery.NodeStatusDraining || catalog.timeout != 30*time.Minute {
+		t.Fatalf("drain: status %d, node %s, timeout %v; want 200, draining, 30m", code, node.Status, catalog.timeout)
+	}
+	if code, node := post("/v1/nodes/gpu-1/undrain"); code != http.StatusOK || node.Status != discovery.NodeStatusActive {
+		t.Fatalf("undrain: status %d, node %s; want 200 and active", code, node.Status)
+	}
+	if code, _ := post("/v1/nodes/gpu-1/drain?timeout=soon"); code != http.StatusBadRequest {
+		t.Fatalf("drain with a bad timeout: status %d, want 400", code)
+	}
+	if code, _ := post("/v1/nodes/gpu-9/drain"); code != http.StatusNotFound {
+		t.Fatalf("drain of an unknown node: status %d, want 404", code)
+	}
+
+	_, plainURL := startTestServer(t, &fakeCatalog{})
+	resp, err := http.Post(plainURL+"/v1/nodes/gpu-1/drain", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST drain: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("status without drainer = %d, want 501", resp.StatusCode)
+	}
+}
+
+// usageCatalog is a fakeCatalog that accounts usage, recording the window
+// it was asked for.
+type usageCatalog struct {
+	fakeCatalog
+	since, until time.Time
+}
+
+func (u *usageCatalog) Usage(since, until time.Time) *discovery.UsageReport {
+	u.since, u.until = since, until
+	return &discovery.UsageReport{Since: since, Until: until, Tenants: map[string]discovery.TenantUsage{
+		"team-a": {Requests: 3, Tokens: 120},
+	}}
+}
+
+func TestServerUsageEndpoint(t *testing.T) {
+	catalog := &usageCatalog{}
+	_, baseURL := startTestServer(t, catalog)
+
+	resp, err := http.Get(baseURL + "/v1/usage?window=1h&until=2026-01-02T15:00:00Z")
+	if err != nil {
+		t.Fatalf("GET /v1/usage: %v", err)
+	}
+	defer resp.Body.Close()
+	var body discovery.UsageReport
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Tenants["team-a"].Tokens != 120 {
+		t.Fatalf("status = %d, body = %+v", resp.StatusCode, body)
+	}
+	wantUntil := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	if !catalog.until.Equal(wantUntil) || !catalog.since.Equal(wantUntil.Add(-time.Hour)) {
+		t.Fatalf("window = %s..%s, want the hour before %s", catalog.since, catalog.until, wantUntil)
+	}
+
+	for _, query := range []string{"since=yesterday", "window=-1h", "window=1h&since=2026-01-02T14:00:00Z"} {
+		bad, err := http.Get(baseURL + "/v1/usage?" + query)
+		if err != nil {
+			t.Fatalf("GET /v1/usage?%s: %v", query, err)
+		}
+		bad.Body.Close()
+		if bad.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, bad.StatusCode)
+		}
+	}
+
+	_, plainURL := startTestServer(t, &fakeCatalog{})
+	plain, err := http.Get(plainURL + "/v1/usage")
+	if err != nil {
+		t.Fatalf("GET /v1/usage: %v", err)
+	}
+	plain.Body.Close()
+	if plain.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("status without usage reporter = %d, want 501", plain.StatusCode)
+	}
+}
+
+// jobCatalog is a fakeCatalog running one job, job-1, that cancelling
+// moves to cancelled.
+type jobCatalog struct {
+	fakeCatalog
+	job discovery.JobStatus
+}
+
+func (j *jobCatalog) Jobs() []discovery.JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return []discovery.JobStatus{j.job}
+}
+
+func (j *jobCatalog) JobStatus(_ context.Context, id string) (*discovery.JobStatus, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if id != j.job.ID {
+		return nil, utils.NotFoundError("job " + id + " not found")
+	}
+	job := j.job
+	return &job, nil
+}
+
+func (j *jobCatalog) CancelJob(_ context.Context, id string) error {
+	if _, err := j.JobStatus(context.Background(), id); err != nil {
+		return err
+	}
+	j.mu.Lock()
+	j.job.State = discovery.JobCancelled
+	j.mu.Unlock()
+	return nil
+}
+
+func TestServerJobEndpoints(t *testing.T) {
+	catalog := &jobCatalog{job: discovery.JobStatus{ID: "job-1", Task: "ocr", NodeID: "gpu-1", State: discovery.JobRunning, Progress: 40}}
+	_, baseURL := startTestServer(t, catalog)
+
+	do := func(method, path string, out any) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, baseURL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	var list jobsResponse
+	if code := do(http.MethodGet, "/v1/jobs", &list); code != http.StatusOK || len(list.Jobs) != 1 || list.Jobs[0].ID != "job-1" {
+		t.Fatalf("list: status %d, jobs %+v", code, list.Jobs)
+	}
+	var job discovery.JobStatus
+	if code := do(http.MethodGet, "/v1/jobs/job-1", &job); code != http.StatusOK || job.State != discovery.JobRunning || job.Progress != 40 {
+		t.Fatalf("status: %d, job %+v; want 200, running at 40%%", code, job)
+	}
+	if code := do(http.MethodDelete, "/v1/jobs/job-1", &job); code != http.StatusOK || job.State != discovery.JobCancelled {
+		t.Fatalf("cancel: %d, job %+v; want 200 and cancelled", code, job)
+	}
+	if code := do(http.MethodGet, "/v1/jobs/job-9", &job); code != http.StatusNotFound {
+		t.Fatalf("unknown job: status %d, want 404", code)
+	}
+
+	_, plainURL := startTestServer(t, &fakeCatalog{})
+	resp, err := http.Get(plainURL + "/v1/jobs")
+	if err != nil {
+		t.Fatalf("GET /v1/jobs: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Fatalf("status without job manager = %d, want 501", resp.StatusCode)
+	}
+}
+
+// failingJobCatalog is a jobCatalog whose status calls fail with err.
+type failingJobCatalog struct {
+	jobCatalog
+	err error
+}
+
+func (j *failingJobCatalog) JobStatus(context.Context, string) (*discovery.JobStatus, error) {
+	return nil, j.err
+}
+
+func TestServerMapsCancelledAndTimedOutCallsOffThe5xxRange(t *testing.T) {
+	cases := map[int]error{
+		statusClientClosedRequest:     utils.CancelledError("request cancelled"),
+		http.StatusGatewayTimeout:     utils.TimeoutError("request deadline exceeded"),
+		http.StatusServiceUnavailable: utils.ConnectionFailedError("gpu-1"),
+	}
+	for want, err := range cases {
+		_, baseURL := startTestServer(t, &failingJobCatalog{err: err})
+		resp, getErr := http.Get(baseURL + "/v1/jobs/job-1")
+		if getErr != nil {
+			t.Fatalf("GET /v1/jobs/job-1: %v", getErr)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%v: status %d, want %d", err, resp.StatusCode, want)
+		}
+	}
+}
+
+// queueCatalog is a fakeCatalog with a durable queue holding one dead
+// letter, which retrying moves back to pending.
+type queueCatalog struct {
+	fakeCatalog
+	status discovery.QueueStatus
+}
+
+func (q *queueCatalog) QueueStatus() discovery.QueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.status
+}
+
+func (q *queueCatalog) RetryDeadLetters() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := len(q.status.DeadLetters)
+	q.status.Depth += n
+	q.status.Dead = 0
+	q.status.DeadLetters = nil
+	return n, nil
+}
+
+func TestServerQueueEndpoints(t *testing.T) {
+	catalog := &queueCatalog{status: discovery.QueueStatus{
+		Enabled: true, Depth: 2, Dead: 1,
+		DeadLetters: []discovery.QueueItem{{ID: "q-1", Task: "ocr", State: discovery.QueueDead, Attempts: 5}},
+	}}
+	_, baseURL := startTestServer(t, catalog)
+
+	var status discovery.QueueStatus
+	resp, err := http.Get(baseURL + "/v1/queue")
+	if err != nil {
+		t.Fatalf("GET /v1/queue: %v", err)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || status.Depth != 2 || len(status.DeadLetters) != 1 {
+		t.Fatalf("status: %d %+v (%v); want 200, depth 2 and one dead letter", resp.StatusCode, status, err)
+	}
+
+	resp, err = http.Post(baseURL+"/v1/queue/retry-dead", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /v1/queue/retry-dead: %v", err)
+	}
+	var retried retryDeadResponse
+	err = json.NewDecoder(resp.Body).Decode(&retried)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || retried.Retried != 1 {
+		t.Fatalf("retry-dead: %d %+v (%v); want 200 and 1 retried", resp.StatusCode, retried, err)
+	}
+	if status := catalog.QueueStatus(); status.Depth != 3 || status.Dead != 0 {
+		t.Fatalf("after retry: %+v, want depth 3 and no dead letters", status)
+	}
+
+	catalog.mu.Lock()
+	catalog.status.Enabled = false
+	catalog.mu.Unlock()
+	_, plainURL := startTestServer(t, &fakeCatalog{})
+	for _, url := range []string{baseURL, plainURL} {
+		resp, err := http.Get(url + "/v1/queue")
+		if err != nil {
+			t.Fatalf("GET /v1/queue: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotImplemented {
+			t.Fatalf("status without an enabled queue = %d, want 501", resp.StatusCode)
+		}
+	}
+}
+
+func TestServerCapabilitiesEndpointFilters(t *testing.T) {
+	cpu := activeNode("cpu-1", "10.0.0.1:50051")
+	cpu.Capabilities = []*pb.Capability{{ServiceName: "clip", Runtime: "onnxrt-cpu", Tasks: []*pb.IOTask{{Name: "embed"}}}}
+	gpu := activeNode("gpu-1", "10.0.0.2:50051")
+	gpu.Capabilities = []*pb.Capability{{ServiceName: "ocr", Runtime: "onnxrt-cuda", Tasks: []*pb.IOTask{{Name: "ocr"}}}}
+	_, baseURL := startTestServer(t, &fakeCatalog{nodes: []*discovery.NodeInfo{cpu, gpu}})
+
+	resp, err := http.Get(baseURL + "/v1/capabilities?runtime=cuda")
+	if err != nil {
+		t.Fatalf("GET /v1/capabilities: %v", err)
+	}
+	defer resp.Body.Close()
+	var body discovery.ClusterCapabilities
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Tasks) != 1 || body.Tasks["ocr"] == nil || body.Tasks["ocr"].Nodes[0] != "gpu-1" {
+		t.Fatalf("tasks = %+v, want only ocr on gpu-1", body.Tasks)
+	}
+}
+
 // TestServerDoesNotExposeInferenceRoutes is the one hard invariant of this
 // package: a discovery-only Broker must never register /v1/infer or other
 // inference-facing routes, even by accident in a future edit.
@@ -219,6 +934,99 @@ func TestServerCloseDisconnectsWatchClients(t *testing.T) {
 	}
 }
 
+func TestServerReadTimeoutAnswersSlowRequests(t *testing.T) {
+	_, baseURL := startTestServerWithOptions(t, nil, ServerOptions{ReadTimeout: 200 * time.Millisecond})
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(baseURL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	// Headers that never finish.
+	if _, err := conn.Write([]byte("GET /v1/health HTTP/1.1\r\nHost: broker\r\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	start := time.Now()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Fatalf("status = %d, want 408", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("408 after %s, want it once the 200ms read timeout passes", elapsed)
+	}
+}
+
+func TestServerIdleTimeoutClosesKeepAliveConnections(t *testing.T) {
+	_, baseURL := startTestServerWithOptions(t, nil, ServerOptions{
+		ReadTimeout: 5 * time.Second,
+		IdleTimeout: 200 * time.Millisecond,
+	})
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(baseURL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("GET /v1/health HTTP/1.1\r\nHost: broker\r\n\r\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	// The connection stays open for the next request only as long as the
+	// idle timeout, well short of the read timeout.
+	start := time.Now()
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Fatalf("read on idle connection = %v, want EOF", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("idle connection closed after %s, want about 200ms", elapsed)
+	}
+}
+
+func TestServerTimeoutsSpareWatchClients(t *testing.T) {
+	catalog := &fakeCatalog{}
+	srv, baseURL := startTestServerWithOptions(t, catalog, ServerOptions{
+		ReadTimeout:  100 * time.Millisecond,
+		WriteTimeout: 100 * time.Millisecond,
+		IdleTimeout:  100 * time.Millisecond,
+	})
+	wsURL := "ws" + baseURL[len("http"):] + "/v1/nodes/watch"
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	waitFor(t, func() bool {
+		srv.watch.mu.Lock()
+		defer srv.watch.mu.Unlock()
+		return len(srv.watch.clients) == 1
+	})
+
+	time.Sleep(300 * time.Millisecond)
+	catalog.set([]*discovery.NodeInfo{activeNode("node-a", "10.0.0.1:50051", "ocr")})
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("read after the HTTP timeouts passed: %v", err)
+	}
+}
+
 func waitFor(t *testing.T, cond func() bool) {
 	t.Helper()
 	deadline := time.Now().Add(3 * time.Second)
@@ -244,3 +1052,115 @@ func nextEvent(t *testing.T, events <-chan discovery.NodeEvent) discovery.NodeEv
 		return discovery.NodeEvent{}
 	}
 }
+
+// socketPath returns a short temp socket path; t.TempDir paths can exceed
+// the unix socket path limit on macOS.
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "lhb")
+	if err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "broker.sock")
+}
+
+func unixHTTPClient(path string) *http.Client {
+	return &http.Client{
+		Timeout: 2 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+}
+
+func TestServerStartUnixServesHealth(t *testing.T) {
+	path := socketPath(t)
+	// A stale socket file left behind by a crashed run.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen stale: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	srv := NewServer(nil, VersionInfo{Version: "test"}, nil)
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.StartUnix(path, 0o600) }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	client := unixHTTPClient(path)
+	waitFor(t, func() bool {
+		resp, err := client.Get("http://unix/v1/health")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	})
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("socket mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// A second server must not steal a socket that is still being served.
+	if _, err := ListenUnix(path, 0o600); err == nil {
+		t.Fatal("expected ListenUnix to refuse a live socket")
+	}
+	select {
+	case err := <-errCh:
+		t.Fatalf("StartUnix returned early: %v", err)
+	default:
+	}
+}
+
+func TestListenUnixRefusesRegularFile(t *testing.T) {
+	path := socketPath(t)
+	if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenUnix(path, 0o600); err == nil {
+		t.Fatal("expected an error for a non-socket path")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("regular file was removed: %v", err)
+	}
+}
+
+// loggingJobCatalog logs through the request logger when a job is queried.
+type loggingJobCatalog struct {
+	jobCatalog
+}
+
+func (j *loggingJobCatalog) JobStatus(ctx context.Context, id string) (*discovery.JobStatus, error) {
+	utils.LoggerFrom(ctx).Info("job status queried", zap.String("job_id", id))
+	return j.jobCatalog.JobStatus(ctx, id)
+}
+
+func TestServerSeedsRequestLoggerWithRequestID(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	catalog := &loggingJobCatalog{jobCatalog{job: discovery.JobStatus{ID: "job-1", State: discovery.JobRunning}}}
+	srv := NewServerWithOptions(catalog, VersionInfo{Version: "test"}, ServerOptions{}, zap.New(core))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/jobs/job-1", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "req-42")
+	resp, err := srv.App().Test(req)
+	if err != nil {
+		t.Fatalf("GET /v1/jobs/job-1: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get(fiber.HeaderXRequestID); got != "req-42" {
+		t.Fatalf("X-Request-ID = %q, want req-42", got)
+	}
+	lines := logs.FilterMessage("job status queried").All()
+	if len(lines) != 1 || lines[0].ContextMap()["request_id"] != "req-42" {
+		t.Fatalf("log lines = %+v, want one carrying request_id req-42", lines)
+	}
+}
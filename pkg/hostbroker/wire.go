@@ -5,11 +5,37 @@ import (
 )
 
 type healthResponse struct {
-	Status string `json:"status"`
+	Status    string                     `json:"status"` // "healthy" or "degraded"
+	Version   VersionInfo                `json:"version"`
+	Discovery *discovery.DiscoveryStatus `json:"discovery,omitempty"`
+	// Components is how each subsystem of the catalog came up.
+	Components map[string]discovery.ComponentStatus `json:"components,omitempty"`
+	// Election is the Broker's role when it runs with leader election.
+	Election *LeaseStatus `json:"election,omitempty"`
 }
 
+// nodesResponse is a page of nodes; Total counts every node the query
+// matched.
 type nodesResponse struct {
 	Nodes []*discovery.NodeInfo `json:"nodes"`
+	Total int                   `json:"total"`
+}
+
+type jobsResponse struct {
+	Jobs []discovery.JobStatus `json:"jobs"`
+}
+
+type readyResponse struct {
+	Ready bool                      `json:"ready"`
+	Tasks []discovery.TaskReadiness `json:"tasks"`
+}
+
+type retryDeadResponse struct {
+	Retried int `json:"retried"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
 }
 
 // ---- /v1/nodes/watch wire format ----
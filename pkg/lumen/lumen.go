@@ -0,0 +1,394 @@
+// Package lumen is the shortest path from zero to an inference result.
+//
+// Connect wires together the config, logging, discovery and client lifecycle
+// that pkg/client otherwise leaves to the caller, and the returned Client
+// exposes one typed method per built-in task:
+//
+//	c, err := lumen.Connect(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer c.Close()
+//
+//	emb, err := c.Embed(ctx, "hello world")
+//
+// Everything here is a thin layer over pkg/client, pkg/config and pkg/types;
+// the embedded client.Client stays available for anything the facade does
+// not cover. Application code can depend on the API interface and use New
+// with a clientmock.Client in tests.
+package lumen
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Option customises Connect.
+type Option func(*options)
+
+type options struct {
+	preset      string
+	configPath  string
+	staticNodes []string
+	logLevel    string
+	logger      *zap.Logger
+}
+
+// WithPreset selects a built-in configuration preset (see
+// config.PresetNames). The default is the basic preset.
+func WithPreset(name string) Option {
+	return func(o *options) { o.preset = name }
+}
+
+// WithConfigFile loads configuration from a YAML file instead of a preset.
+// LUMEN_* environment overrides still apply.
+func WithConfigFile(path string) Option {
+	return func(o *options) { o.configPath = path }
+}
+
+// WithStaticNodes pins node endpoints ("host:port") in addition to whatever
+// discovery the preset or config file enables.
+func WithStaticNodes(addrs ...string) Option {
+	return func(o *options) { o.staticNodes = append(o.staticNodes, addrs...) }
+}
+
+// WithLogLevel overrides the configured log level (debug, info, warn, error).
+func WithLogLevel(level string) Option {
+	return func(o *options) { o.logLevel = level }
+}
+
+// WithLogger uses the given logger instead of building one from the config.
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+// API is the surface of Client: the full client.Client plus one typed
+// method per built-in task.
+type API interface {
+	client.Client
+	Embed(ctx context.Context, text string) (*types.EmbeddingV1, error)
+	EmbedImage(ctx context.Context, image []byte) (*types.EmbeddingV1, error)
+	EmbedInput(ctx context.Context, input string, preferBinary bool) (*types.EmbeddingV1, string, error)
+	Classify(ctx context.Context, image []byte, topK int) (*types.LabelsV1, error)
+	DetectFaces(ctx context.Context, image []byte, opts ...types.FaceRecognitionOption) (*types.FaceV1, error)
+	DetectFacesTiled(ctx context.Context, image []byte, tile TileOptions, opts ...types.FaceRecognitionOption) (*types.FaceV1, error)
+	OCR(ctx context.Context, image []byte, opts ...types.OCRRequestOption) (*types.OCRV1, error)
+	Generate(ctx context.Context, task string, image []byte, opts ...types.ImageTextGenerationRequestOption) (*types.TextGenerationV1, error)
+	GenerateText(ctx context.Context, prompt string, opts ...types.ImageTextGenerationRequestOption) (*types.TextGenerationV1, error)
+}
+
+var _ API = (*Client)(nil)
+
+// Client is a started client.Client with typed convenience methods.
+type Client struct {
+	client.Client
+
+	ownsLogger bool
+	logger     *zap.Logger
+}
+
+// Connect builds a configuration, creates and starts a client, and waits for
+// the first node to report its capabilities (bounded by the configured
+// connect timeout). The caller must Close the returned client.
+func Connect(ctx context.Context, opts ...Option) (*Client, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg, err := buildConfig(o)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := o.logger
+	ownsLogger := false
+	if logger == nil {
+		logger, err = newLogger(cfg.Logging)
+		if err != nil {
+			return nil, fmt.Errorf("create logger: %w", err)
+		}
+		ownsLogger = true
+	}
+
+	lc, err := client.NewLumenClient(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := lc.Start(ctx); err != nil {
+		_ = lc.Close()
+		return nil, fmt.Errorf("start client: %w", err)
+	}
+
+	return &Client{Client: lc, ownsLogger: ownsLogger, logger: logger}, nil
+}
+
+// New wraps an existing client, such as a started *client.LumenClient or a
+// clientmock.Client. The caller keeps ownership of its logger.
+func New(c client.Client) *Client {
+	return &Client{Client: c}
+}
+
+// Close stops discovery, closes all node connections and flushes the logger
+// if Connect created it.
+func (c *Client) Close() error {
+	err := c.Client.Close()
+	if c.ownsLogger {
+		_ = c.logger.Sync()
+	}
+	return err
+}
+
+func buildConfig(o options) (*config.Config, error) {
+	var cfg *config.Config
+	var err error
+	if o.configPath != "" {
+		cfg, err = config.LoadConfig(o.configPath)
+	} else {
+		cfg, err = config.PresetConfig(o.preset)
+		if err == nil {
+			err = cfg.LoadFromEnv()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(o.staticNodes) > 0 {
+		cfg.Discovery.Enabled = true
+		cfg.Discovery.StaticNodes = append(cfg.Discovery.StaticNodes, o.staticNodes...)
+	}
+	if o.logLevel != "" {
+		cfg.Logging.Level = strings.ToLower(strings.TrimSpace(o.logLevel))
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+func newLogger(cfg config.LoggingConfig) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	zapConfig := zap.NewProductionConfig()
+	if cfg.Format == "text" {
+		zapConfig.Encoding = "console"
+	}
+	zapConfig.Level = zap.NewAtomicLevelAt(level)
+	if cfg.Output != "" {
+		zapConfig.OutputPaths = []string{cfg.Output}
+	}
+	return zapConfig.Build()
+}
+
+// --- typed task helpers ---
+
+// Embed returns the semantic embedding of text.
+func (c *Client) Embed(ctx context.Context, text string) (*types.EmbeddingV1, error) {
+	req := types.NewInferRequest(types.TaskSemanticTextEmbed).
+		ForSemanticTextEmbed(text).
+		Build()
+	resp, err := c.Infer(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return types.ParseInferResponse(resp).AsEmbeddingResponse()
+}
+
+// EmbedImage returns the semantic embedding of an encoded image.
+func (c *Client) EmbedImage(ctx context.Context, image []byte) (*types.EmbeddingV1, error) {
+	embReq, err := types.NewEmbeddingRequest(image)
+	if err != nil {
+		return nil, err
+	}
+	req := types.NewInferRequest(types.TaskSemanticImageEmbed).
+		ForSemanticImageEmbed(embReq.Payload, embReq.PayloadMime).
+		Build()
+	resp, err := c.Infer(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return types.ParseInferResponse(resp).AsEmbeddingResponse()
+}
+
+// EmbedInput embeds input as text or as an image, whichever
+// types.DetectEmbeddingInput finds it is, and returns the detected type with
+// the embedding. Plain text and base64 images need no hint; preferBinary
+// makes base64 that decodes to text embed the decoded text.
+func (c *Client) EmbedInput(ctx context.Context, input string, preferBinary bool) (*types.EmbeddingV1, string, error) {
+	embReq, err := types.DetectEmbeddingInput(input, preferBinary)
+	if err != nil {
+		return nil, "", err
+	}
+	var req *pb.InferRequest
+	if strings.HasPrefix(embReq.PayloadMime, "image/") {
+		req = types.NewInferRequest(types.TaskSemanticImageEmbed).
+			ForSemanticImageEmbed(embReq.Payload, embReq.PayloadMime).
+			Build()
+	} else {
+		req = types.NewInferRequest(types.TaskSemanticTextEmbed).
+			ForSemanticTextEmbed(string(embReq.Payload)).
+			Build()
+	}
+	resp, err := c.Infer(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	emb, err := types.ParseInferResponse(resp).AsEmbeddingResponse()
+	if err != nil {
+		return nil, "", err
+	}
+	return emb, embReq.PayloadMime, nil
+}
+
+// Classify runs BioCLIP classification on an encoded image. A topK of zero
+// leaves the node default in place.
+func (c *Client) Classify(ctx context.Context, image []byte, topK int) (*types.LabelsV1, error) {
+	classReq, err := types.NewClassificationRequest(image, types.WithTopK(topK))
+	if err != nil {
+		return nil, err
+	}
+	req := types.NewInferRequest(types.TaskBioCLIPClassify).
+		ForBioCLIPClassify(classReq.Payload, classReq.PayloadMime, topK).
+		Build()
+	resp, err := c.Infer(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return types.ParseInferResponse(resp).AsClassificationResponse()
+}
+
+// DetectFaces runs face detection and recognition on an encoded image.
+func (c *Client) DetectFaces(ctx context.Context, image []byte, opts ...types.FaceRecognitionOption) (*types.FaceV1, error) {
+	faceReq, err := types.NewFaceRecognitionRequest(image, opts...)
+	if err != nil {
+		return nil, err
+	}
+	req := types.NewInferRequest(types.TaskFaceRecognition).
+		ForFaceDetection(faceReq, types.TaskFaceRecognition).
+		Build()
+	resp, err := c.Infer(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return types.ParseInferResponse(resp).AsFaceResponse()
+}
+
+// TileOptions controls DetectFacesTiled.
+type TileOptions struct {
+	// Size is the tile edge in pixels.
+	Size int
+	// Overlap is how many pixels neighbouring tiles share; it should exceed
+	// the largest expected face.
+	Overlap int
+	// IoUThreshold is the overlap above which detections from different
+	// tiles are considered duplicates. Zero means 0.5.
+	IoUThreshold float32
+}
+
+// DetectFacesTiled runs face detection on an image too large to send in one
+// piece: it splits the image with types.TileImage, detects faces on every
+// tile concurrently and merges the results with types.MergeDetections. Boxes
+// in the result are in source-image coordinates. The first tile failure
+// cancels the remaining tiles and is returned.
+func (c *Client) DetectFacesTiled(ctx context.Context, image []byte, tile TileOptions, opts ...types.FaceRecognitionOption) (*types.FaceV1, error) {
+	tiles, err := types.TileImage(image, tile.Size, tile.Overlap)
+	if err != nil {
+		return nil, err
+	}
+	iou := tile.IoUThreshold
+	if iou == 0 {
+		iou = 0.5
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]*types.FaceV1, len(tiles))
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		firstErr error
+	)
+	for i, t := range tiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := c.DetectFaces(ctx, t.Data, opts...)
+			if err != nil {
+				failOnce.Do(func() {
+					firstErr = fmt.Errorf("tile at (%d,%d): %w", t.X, t.Y, err)
+					cancel()
+				})
+				return
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return types.MergeDetections(tiles, results, iou), nil
+}
+
+// OCR extracts text regions from an encoded image.
+func (c *Client) OCR(ctx context.Context, image []byte, opts ...types.OCRRequestOption) (*types.OCRV1, error) {
+	ocrReq, err := types.NewOCRRequest(image, opts...)
+	if err != nil {
+		return nil, err
+	}
+	req := types.NewInferRequest(types.TaskOCR).
+		ForOCR(ocrReq, types.TaskOCR).
+		Build()
+	resp, err := c.Infer(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return types.ParseInferResponse(resp).AsOCRResponse()
+}
+
+// Generate runs image-conditioned text generation against task, the name a
+// vision-language node advertises (e.g. "vlm").
+func (c *Client) Generate(ctx context.Context, task string, image []byte, opts ...types.ImageTextGenerationRequestOption) (*types.TextGenerationV1, error) {
+	genReq, err := types.NewImageTextGenerationRequest(image, opts...)
+	if err != nil {
+		return nil, err
+	}
+	req := types.NewInferRequest(task).
+		ForImageTextGeneration(genReq, task).
+		Build()
+	resp, err := c.Infer(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return types.ParseInferResponse(resp).AsTextGenerationResponse()
+}
+
+// GenerateText runs text-only generation against types.TaskTextGeneration.
+// Pass an empty prompt with types.WithMessages for chat-format nodes.
+func (c *Client) GenerateText(ctx context.Context, prompt string, opts ...types.ImageTextGenerationRequestOption) (*types.TextGenerationV1, error) {
+	genReq, err := types.NewTextGenerationRequest(prompt, opts...)
+	if err != nil {
+		return nil, err
+	}
+	req := types.NewInferRequest(types.TaskTextGeneration).
+		ForTextGeneration(genReq, types.TaskTextGeneration).
+		Build()
+	resp, err := c.Infer(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return types.ParseInferResponse(resp).AsTextGenerationResponse()
+}
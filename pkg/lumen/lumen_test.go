@@ -0,0 +1,132 @@
+package lumen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client/clientmock"
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+)
+
+func TestBuildConfigAppliesOptions(t *testing.T) {
+	cfg, err := buildConfig(options{
+		preset:      config.PresetMinimal,
+		staticNodes: []string{"10.0.0.5:50051"},
+		logLevel:    "DEBUG",
+	})
+	if err != nil {
+		t.Fatalf("buildConfig: %v", err)
+	}
+	if cfg.Broker.Port != 8081 {
+		t.Fatalf("broker port = %d, want minimal preset 8081", cfg.Broker.Port)
+	}
+	if len(cfg.Discovery.StaticNodes) != 1 || cfg.Discovery.StaticNodes[0] != "10.0.0.5:50051" {
+		t.Fatalf("static nodes = %v", cfg.Discovery.StaticNodes)
+	}
+	if cfg.Logging.Level != "debug" {
+		t.Fatalf("log level = %q, want debug", cfg.Logging.Level)
+	}
+}
+
+func TestBuildConfigRejectsUnknownPreset(t *testing.T) {
+	if _, err := buildConfig(options{preset: "turbo"}); err == nil {
+		t.Fatal("expected error for unknown preset")
+	}
+}
+
+func TestBuildConfigRejectsInvalidStaticNode(t *testing.T) {
+	if _, err := buildConfig(options{staticNodes: []string{"no-port"}}); err == nil {
+		t.Fatal("expected validation error for static node without port")
+	}
+}
+
+func TestClientWrapsMock(t *testing.T) {
+	mock := clientmock.New().On(types.TaskSemanticTextEmbed, clientmock.Reply(
+		clientmock.JSONResult("embedding_v1", types.EmbeddingV1{Vector: []float32{0.5, 0.5}, Dim: 2}),
+	))
+	c := New(mock)
+
+	emb, err := c.Embed(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if emb.Dim != 2 {
+		t.Fatalf("dim = %d, want 2", emb.Dim)
+	}
+	mock.AssertInferCalledWith(t, types.TaskSemanticTextEmbed, func(p []byte) bool { return string(p) == "hello" })
+}
+
+func TestDetectFacesTiledMergesTiles(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 150, 80))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	// Every tile reports a face at the same tile-local position, so the two
+	// tiles (origins x=0 and x=50) yield distinct global boxes.
+	mock := clientmock.New().On(types.TaskFaceRecognition, clientmock.Reply(
+		clientmock.JSONResult("face_v1", types.FaceV1{
+			Faces: []types.Face{{BBox: []float32{10, 10, 30, 30}, Confidence: 0.9}},
+			Count: 1,
+		}),
+	))
+
+	faces, err := New(mock).DetectFacesTiled(context.Background(), buf.Bytes(), TileOptions{Size: 100, Overlap: 50})
+	if err != nil {
+		t.Fatalf("DetectFacesTiled: %v", err)
+	}
+	mock.AssertCallCount(t, types.TaskFaceRecognition, 2)
+	if faces.Count != 2 || faces.Faces[1].BBox[0] != 60 {
+		t.Fatalf("faces = %+v, want boxes at x=10 and x=60", faces.Faces)
+	}
+}
+
+func TestEmbedInputRoutesByDetectedType(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	reply := clientmock.Reply(clientmock.JSONResult("embedding_v1", types.EmbeddingV1{Vector: []float32{1}, Dim: 1}))
+	mock := clientmock.New().
+		On(types.TaskSemanticTextEmbed, reply).
+		On(types.TaskSemanticImageEmbed, reply)
+	c := New(mock)
+
+	if _, mime, err := c.EmbedInput(context.Background(), "hello", false); err != nil || mime != "text/plain" {
+		t.Fatalf("EmbedInput(text) = %q, %v; want text/plain", mime, err)
+	}
+	mock.AssertInferCalledWith(t, types.TaskSemanticTextEmbed, func(p []byte) bool { return string(p) == "hello" })
+
+	b64 := base64.StdEncoding.EncodeToString(buf.Bytes())
+	if _, mime, err := c.EmbedInput(context.Background(), b64, false); err != nil || mime != "image/png" {
+		t.Fatalf("EmbedInput(base64 png) = %q, %v; want image/png", mime, err)
+	}
+	mock.AssertInferCalledWith(t, types.TaskSemanticImageEmbed, func(p []byte) bool { return bytes.Equal(p, buf.Bytes()) })
+}
+
+func TestGenerateTextSendsPrompt(t *testing.T) {
+	mock := clientmock.New().On(types.TaskTextGeneration, clientmock.Reply(
+		clientmock.JSONResult("text_generation_v1", types.TextGenerationV1{Text: "Why did the gopher...", FinishReason: "stop", GeneratedTokens: 5}),
+	))
+	c := New(mock)
+
+	gen, err := c.GenerateText(context.Background(), "Tell me a joke", types.WithSeed(7))
+	if err != nil {
+		t.Fatalf("GenerateText: %v", err)
+	}
+	if gen.Text != "Why did the gopher..." || gen.FinishReason != "stop" {
+		t.Fatalf("generation = %+v", gen)
+	}
+	mock.AssertInferCalledWith(t, types.TaskTextGeneration, func(p []byte) bool { return string(p) == "Tell me a joke" })
+
+	if _, err := c.GenerateText(context.Background(), ""); err == nil {
+		t.Fatal("expected an empty prompt to be rejected")
+	}
+	mock.AssertCallCount(t, types.TaskTextGeneration, 1)
+}
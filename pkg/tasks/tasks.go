@@ -0,0 +1,108 @@
+// Package tasks defines how a requested task name matches the task names
+// nodes advertise. Nodes may advertise a plain name ("clip_text_embed"), a
+// versioned one ("clip_text_embed@v2") or a family wildcard ("ocr.*"):
+//
+//   - A name matches itself.
+//   - An unversioned request matches every version of that name, so
+//     "clip_text_embed" is served by a node advertising "clip_text_embed@v2".
+//     A request that pins a version, "clip_text_embed@v1", matches only that
+//     version.
+//   - An advertised name ending in "*" matches every requested name that
+//     starts with the part before it, with or without a version. A bare "*"
+//     matches nothing.
+//
+// Routing, node capability checks and request validation all go through
+// Match, so the rules live here only.
+package tasks
+
+import (
+	"strconv"
+	"strings"
+)
+
+// VersionSeparator separates a task name from its version.
+const VersionSeparator = "@"
+
+// Wildcard ends an advertised task family.
+const Wildcard = "*"
+
+// Split returns the name and version of task; version is "" when task is
+// unversioned.
+func Split(task string) (name, version string) {
+	name, version, _ = strings.Cut(task, VersionSeparator)
+	return name, version
+}
+
+// Name returns task without its version.
+func Name(task string) string {
+	name, _ := Split(task)
+	return name
+}
+
+// IsPattern reports whether an advertised task matches names other than
+// itself: a versioned name or a wildcard.
+func IsPattern(advertised string) bool {
+	return strings.Contains(advertised, VersionSeparator) || strings.HasSuffix(advertised, Wildcard)
+}
+
+// Match reports whether a node advertising advertised can serve a request
+// for requested. Empty names match nothing.
+func Match(requested, advertised string) bool {
+	if requested == "" || advertised == "" {
+		return false
+	}
+	if requested == advertised {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(advertised, Wildcard); ok {
+		return prefix != "" && strings.HasPrefix(Name(requested), prefix)
+	}
+	name, version := Split(requested)
+	if version != "" {
+		return false
+	}
+	advName, advVersion := Split(advertised)
+	return advVersion != "" && advName == name
+}
+
+// Resolve returns the advertised task a request for requested runs as on a
+// node advertising advertised, and whether there is one. An exact match
+// wins, then the highest matching version, then a wildcard, which resolves
+// to requested itself.
+func Resolve(requested string, advertised []string) (string, bool) {
+	var versioned string
+	wildcard := false
+	for _, adv := range advertised {
+		if !Match(requested, adv) {
+			continue
+		}
+		switch {
+		case adv == requested:
+			return adv, true
+		case strings.HasSuffix(adv, Wildcard):
+			wildcard = true
+		case versioned == "" || versionLess(versioned, adv):
+			versioned = adv
+		}
+	}
+	if versioned != "" {
+		return versioned, true
+	}
+	if wildcard {
+		return requested, true
+	}
+	return "", false
+}
+
+// versionLess orders the versions of a and b: numerically when both are
+// numbers with an optional "v" prefix, as strings otherwise.
+func versionLess(a, b string) bool {
+	_, va := Split(a)
+	_, vb := Split(b)
+	na, errA := strconv.Atoi(strings.TrimPrefix(va, "v"))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(vb, "v"))
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return va < vb
+}
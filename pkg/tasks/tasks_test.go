@@ -0,0 +1,83 @@
+package tasks
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		requested, advertised string
+		want                  bool
+	}{
+		{"clip_text_embed", "clip_text_embed", true},
+		{"clip_text_embed", "clip_image_embed", false},
+		{"", "", false},
+		{"clip_text_embed", "", false},
+		{"", "clip_text_embed", false},
+
+		// An unversioned request takes any version.
+		{"clip_text_embed", "clip_text_embed@v2", true},
+		{"clip_text_embed", "clip_text_embed@2026-01", true},
+		{"clip_text", "clip_text_embed@v2", false},
+		{"clip_text_embed_x", "clip_text_embed@v2", false},
+		// A pinned version takes only that version.
+		{"clip_text_embed@v1", "clip_text_embed@v1", true},
+		{"clip_text_embed@v1", "clip_text_embed@v2", false},
+		{"clip_text_embed@v1", "clip_text_embed", false},
+
+		// Wildcards match by prefix, versioned or not.
+		{"ocr.detect", "ocr.*", true},
+		{"ocr.detect@v3", "ocr.*", true},
+		{"ocr", "ocr.*", false},
+		{"ocrx.detect", "ocr.*", false},
+		{"ocr_v2", "ocr*", true},
+		{"anything", "*", false},
+		{"ocr.*", "ocr.*", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.requested, tt.advertised); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.requested, tt.advertised, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		requested  string
+		advertised []string
+		want       string
+		wantOK     bool
+	}{
+		{"exact", "face_detect", []string{"face_detect@v2", "face_detect"}, "face_detect", true},
+		{"highest version", "face_detect", []string{"face_detect@v2", "face_detect@v10", "face_detect@v9"}, "face_detect@v10", true},
+		{"non-numeric versions", "face_detect", []string{"face_detect@beta", "face_detect@alpha"}, "face_detect@beta", true},
+		{"pinned version", "face_detect@v2", []string{"face_detect@v10", "face_detect@v2"}, "face_detect@v2", true},
+		{"version before wildcard", "ocr.detect", []string{"ocr.*", "ocr.detect@v1"}, "ocr.detect@v1", true},
+		{"wildcard", "ocr.detect", []string{"clip_text_embed", "ocr.*"}, "ocr.detect", true},
+		{"no match", "ocr.detect", []string{"clip_text_embed", "face_detect@v1"}, "", false},
+		{"pinned version missing", "face_detect@v3", []string{"face_detect@v2", "face_detect"}, "", false},
+		{"nothing advertised", "face_detect", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Resolve(tt.requested, tt.advertised)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Fatalf("Resolve(%q, %v) = %q, %v; want %q, %v", tt.requested, tt.advertised, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct{ task, name, version string }{
+		{"clip_text_embed", "clip_text_embed", ""},
+		{"clip_text_embed@v2", "clip_text_embed", "v2"},
+		{"a@b@c", "a", "b@c"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		name, version := Split(tt.task)
+		if name != tt.name || version != tt.version {
+			t.Errorf("Split(%q) = %q, %q; want %q, %q", tt.task, name, version, tt.name, tt.version)
+		}
+	}
+}
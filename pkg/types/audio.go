@@ -0,0 +1,63 @@
+package types
+
+import (
+	"encoding/base64"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// AudioV1 represents synthesized audio, e.g. the output of a "tts" task.
+//
+// Unlike the JSON schemas, audio results are the raw encoded bytes with an
+// audio/* result MIME type, so the model and sample rate come from the
+// response's MIME parameters and metadata rather than from the body.
+//
+// Role in project: Output structure for text-to-speech tasks.
+type AudioV1 struct {
+	Audio []byte `json:"audio"`
+	// Mime is the audio media type without parameters, e.g. "audio/wav".
+	Mime string `json:"mime"`
+	// SampleRate is taken from the MIME "rate" parameter
+	// ("audio/pcm;rate=16000") or the "sample_rate" metadata; 0 if neither
+	// is present, as is usual for self-describing containers like WAV.
+	SampleRate int    `json:"sample_rate,omitempty"`
+	ModelID    string `json:"model_id,omitempty"`
+}
+
+// IsAudioMime reports whether mimeType is an audio/* media type, with or
+// without parameters.
+func IsAudioMime(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	return err == nil && strings.HasPrefix(mediaType, "audio/")
+}
+
+// DataURL returns the audio as a base64 data URL, e.g.
+// "data:audio/wav;base64,UklGR...", which browsers play directly. A known
+// sample rate is kept as the MIME "rate" parameter.
+func (a *AudioV1) DataURL() string {
+	mediaType := a.Mime
+	if a.SampleRate > 0 {
+		mediaType += ";rate=" + strconv.Itoa(a.SampleRate)
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(a.Audio)
+}
+
+func newAudioV1(data []byte, mimeType string, meta map[string]string) *AudioV1 {
+	mediaType, params, _ := mime.ParseMediaType(mimeType)
+	audio := &AudioV1{
+		Audio:   make([]byte, len(data)),
+		Mime:    mediaType,
+		ModelID: meta["model_id"],
+	}
+	copy(audio.Audio, data)
+
+	rate := params["rate"]
+	if rate == "" {
+		rate = meta["sample_rate"]
+	}
+	if n, err := strconv.Atoi(rate); err == nil && n > 0 {
+		audio.SampleRate = n
+	}
+	return audio
+}
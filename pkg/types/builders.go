@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	pb "github.com/edwinzhancn/lumen-sdk/proto"
@@ -243,6 +244,12 @@ func (b *InferRequestBuilder) ForClassification(req *ClassificationRequest, task
 	b.req.Payload = payload
 	b.req.PayloadMime = req.PayloadMime
 	b.req.Task = task
+	if req.TopK > 0 {
+		b.WithMeta(MetaTopK, strconv.Itoa(req.TopK))
+	}
+	if req.ConfidenceThreshold > 0 {
+		b.WithMeta("confidence_threshold", fmt.Sprintf("%.3f", req.ConfidenceThreshold))
+	}
 	return b
 }
 
@@ -445,3 +452,38 @@ func (b *InferRequestBuilder) ForImageTextGeneration(req *ImageTextGenerationReq
 
 	return b
 }
+
+// ForTextGeneration configures the builder for a text-only generation (LLM)
+// request.
+//
+// It sets the prompt or messages payload of req and transfers its metadata,
+// just as ForImageTextGeneration does for a VLM request. Responses parse with
+// AsTextGenerationResponse either way.
+//
+// Parameters:
+//   - req: The request built by NewTextGenerationRequest
+//   - task: The task name from node capabilities, usually TaskTextGeneration
+//
+// Example:
+//
+//	genReq, err := types.NewTextGenerationRequest("Summarise: ...",
+//	    types.WithMaxTokens(128),
+//	    types.WithStopSequences([]string{"\n\n"}))
+//	req := types.NewInferRequest(types.TaskTextGeneration).
+//	    ForTextGeneration(genReq, types.TaskTextGeneration).
+//	    Build()
+//
+//	result, err := client.Infer(ctx, req)
+//	genResp, _ := types.ParseInferResponse(result).AsTextGenerationResponse()
+//	fmt.Println(genResp.Text)
+func (b *InferRequestBuilder) ForTextGeneration(req *TextGenerationRequest, task string) *InferRequestBuilder {
+	b.req.Payload = req.Payload
+	b.req.PayloadMime = req.PayloadMime
+	b.req.Task = task
+
+	for key, value := range req.Meta {
+		b.WithMeta(key, value)
+	}
+
+	return b
+}
@@ -90,6 +90,41 @@ func (l LabelsV1) TopK(k int) []Label {
 type ClassificationRequest struct {
 	Payload     []byte `json:"payload"`
 	PayloadMime string `json:"payload_mime"`
+	// TopK asks for the K best labels; 0 leaves the node default.
+	TopK int `json:"top_k,omitempty"`
+	// ConfidenceThreshold drops labels scored below it; 0 leaves the node
+	// default.
+	ConfidenceThreshold float32 `json:"confidence_threshold,omitempty"`
+
+	// strict rejects out-of-range thresholds instead of clamping them.
+	strict bool
+}
+
+// ClassificationOption configures a ClassificationRequest.
+type ClassificationOption func(*ClassificationRequest)
+
+// WithTopK asks for the k most confident labels; k must be non-negative,
+// and 0 leaves the node default.
+func WithTopK(k int) ClassificationOption {
+	return func(req *ClassificationRequest) {
+		req.TopK = k
+	}
+}
+
+// WithClassificationConfidenceThreshold drops labels scored below
+// threshold (0.0 to 1.0).
+func WithClassificationConfidenceThreshold(threshold float32) ClassificationOption {
+	return func(req *ClassificationRequest) {
+		req.ConfidenceThreshold = threshold
+	}
+}
+
+// WithStrictClassificationValidation makes NewClassificationRequest reject
+// thresholds outside [0, 1] instead of clamping them into range.
+func WithStrictClassificationValidation() ClassificationOption {
+	return func(req *ClassificationRequest) {
+		req.strict = true
+	}
 }
 
 // NewClassificationRequest creates a new ClassificationRequest with automatic MIME detection.
@@ -128,17 +163,35 @@ type ClassificationRequest struct {
 //	result, _ := client.Infer(ctx, inferReq)
 //	labels, _ := types.ParseInferResponse(result).AsClassificationResponse()
 //	fmt.Printf("Scene: %s\n", labels.TopK(1)[0].Label)
-func NewClassificationRequest(payload []byte) (*ClassificationRequest, error) {
+func NewClassificationRequest(payload []byte, opts ...ClassificationOption) (*ClassificationRequest, error) {
 	mime := mimetype.Detect(payload)
 	mimeString := mime.String()
 
 	// Check if detected MIME type matches any supported image type
 	if mimetype.EqualsAny(mimeString, SupportedImageMimeTypes...) {
-		return &ClassificationRequest{
+		req := &ClassificationRequest{
 			Payload:     payload,
 			PayloadMime: mimeString,
-		}, nil
+		}
+		for _, opt := range opts {
+			opt(req)
+		}
+		if err := req.validate(); err != nil {
+			return nil, err
+		}
+		return req, nil
 	}
 
 	return nil, fmt.Errorf("unsupported payload type: %s", mimeString)
 }
+
+// validate checks the options set on req: TopK must not be negative, and
+// a threshold outside [0, 1] is clamped unless strict.
+func (req *ClassificationRequest) validate() error {
+	errs := optionErrors{request: "classification", strict: req.strict}
+	if req.TopK < 0 {
+		errs.addf("top_k must be non-negative (0 is the node default), got %d", req.TopK)
+	}
+	errs.threshold("confidence_threshold", &req.ConfidenceThreshold)
+	return errs.err()
+}
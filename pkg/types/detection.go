@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/gabriel-vasile/mimetype"
 )
@@ -79,6 +80,9 @@ type FaceRecognitionRequest struct {
 	FaceSizeMin                  float32 `json:"face_size_min,omitempty"`
 	FaceSizeMax                  float32 `json:"face_size_max,omitempty"`
 	MaxFaces                     int     `json:"max_faces,omitempty"` // -1 means no limit
+
+	// strict rejects out-of-range thresholds instead of clamping them.
+	strict bool
 }
 
 // FaceRecognitionOption is a function type for configuring face detection requests.
@@ -112,6 +116,14 @@ func WithDetectionConfidenceThreshold(threshold float32) FaceRecognitionOption {
 	}
 }
 
+// WithStrictFaceValidation makes NewFaceRecognitionRequest reject
+// thresholds outside [0, 1] instead of clamping them into range.
+func WithStrictFaceValidation() FaceRecognitionOption {
+	return func(req *FaceRecognitionRequest) {
+		req.strict = true
+	}
+}
+
 // WithNmsThreshold 设置 NMS 阈值
 func WithNmsThreshold(threshold float32) FaceRecognitionOption {
 	return func(req *FaceRecognitionRequest) {
@@ -214,9 +226,34 @@ func NewFaceRecognitionRequest(payload []byte, opts ...FaceRecognitionOption) (*
 		for _, opt := range opts {
 			opt(req)
 		}
+		if err := req.validate(); err != nil {
+			return nil, err
+		}
 
 		return req, nil
 	}
 
 	return nil, fmt.Errorf("unsupported payload type: %s", mimeString)
 }
+
+// validate checks the options set on req. Thresholds outside [0, 1] are
+// clamped unless strict; MaxFaces must be -1, 0 (unset) or positive, face
+// sizes non-negative with FaceSizeMin below FaceSizeMax when both are set.
+func (req *FaceRecognitionRequest) validate() error {
+	errs := optionErrors{request: "face recognition", strict: req.strict}
+	errs.threshold("detection_confidence_threshold", &req.DetectionConfidenceThreshold)
+	errs.threshold("nms_threshold", &req.NmsThreshold)
+	if req.MaxFaces < -1 {
+		errs.addf("max_faces must be -1 (no limit) or at least 1, got %d", req.MaxFaces)
+	}
+	if req.FaceSizeMin < 0 || math.IsNaN(float64(req.FaceSizeMin)) {
+		errs.addf("face_size_min must be non-negative, got %g", req.FaceSizeMin)
+	}
+	if req.FaceSizeMax < 0 || math.IsNaN(float64(req.FaceSizeMax)) {
+		errs.addf("face_size_max must be non-negative, got %g", req.FaceSizeMax)
+	}
+	if req.FaceSizeMin > 0 && req.FaceSizeMax > 0 && req.FaceSizeMin >= req.FaceSizeMax {
+		errs.addf("face_size_min %g must be below face_size_max %g", req.FaceSizeMin, req.FaceSizeMax)
+	}
+	return errs.err()
+}
@@ -29,6 +29,12 @@
 //	    log.Fatal(err)
 //	}
 //
+// Embedding, label, face and OCR results are versioned by a "schema_version"
+// response meta key (or top-level result field). Older revisions are
+// translated to the current types; newer ones parse best-effort and leave a
+// CompatibilityWarning on the parser; unreadable revisions fail with a
+// CODEC_MISMATCH LumenError.
+//
 // # Embedding Operations
 //
 // Work with vector embeddings for semantic search:
@@ -71,6 +77,10 @@
 //	    ForFaceDetection(faceReq, "face_detection").
 //	    Build()
 //
+// Images too large for one request can be split with TileImage, detected
+// tile by tile, and recombined in source coordinates with MergeDetections,
+// which also removes faces found twice in overlapping tiles.
+//
 // # Role in Project
 //
 // The types package provides the data layer for ML operations, ensuring
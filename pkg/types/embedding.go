@@ -1,8 +1,11 @@
 package types
 
 import (
+	"encoding/base64"
 	"fmt"
 	"math"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/gabriel-vasile/mimetype"
 )
@@ -349,3 +352,48 @@ func NewEmbeddingRequest(payload []byte) (*EmbeddingRequest, error) {
 
 	return nil, fmt.Errorf("unsupported payload type: %s", mimeString)
 }
+
+// DetectEmbeddingInput decides whether input, as a caller passed it to an
+// embed call that takes either kind, is text or base64-encoded data, and
+// returns the request to send. Input that is not valid base64 is text.
+// Input that decodes to a supported image is that image. Input that
+// decodes to text, or to data of no supported type, is ambiguous: it is
+// taken literally as text unless preferBinary is set, in which case the
+// decoded text is used, or the unsupported type is an error.
+//
+// PayloadMime on the result is "text/plain" or the detected image type.
+func DetectEmbeddingInput(input string, preferBinary bool) (*EmbeddingRequest, error) {
+	if input == "" {
+		return nil, fmt.Errorf("empty embedding input")
+	}
+	literal := &EmbeddingRequest{Payload: []byte(input), PayloadMime: "text/plain"}
+	decoded, ok := decodeBase64(input)
+	if !ok {
+		return literal, nil
+	}
+
+	mime := mimetype.Detect(decoded).String()
+	switch {
+	case mimetype.EqualsAny(mime, SupportedImageMimeTypes...):
+		return &EmbeddingRequest{Payload: decoded, PayloadMime: mime}, nil
+	case !preferBinary:
+		return literal, nil
+	case mimetype.EqualsAny(mime, SupportedTextMimeTypes...) && utf8.Valid(decoded):
+		return &EmbeddingRequest{Payload: decoded, PayloadMime: "text/plain"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported payload type: %s", mime)
+	}
+}
+
+// decodeBase64 decodes s as standard base64, padded or not, ignoring
+// surrounding whitespace.
+func decodeBase64(s string) ([]byte, bool) {
+	s = strings.TrimSpace(s)
+	if data, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return data, true
+	}
+	if data, err := base64.RawStdEncoding.DecodeString(s); err == nil {
+		return data, true
+	}
+	return nil, false
+}
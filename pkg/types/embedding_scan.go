@@ -0,0 +1,165 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+var errNotJSONObject = errors.New("expected a JSON object")
+
+// scanEmbeddingVector decodes the "vector" field of an embedding result, or
+// "embedding" as revision 1 named it, into vec and returns the raw
+// "schema_version" field if there is one. It reads the top-level object in
+// place and skips other fields without decoding them; result is checked
+// with json.Valid first, so the scanner only has to follow the structure.
+func scanEmbeddingVector(result []byte, vec []float32) ([]float32, bool, json.RawMessage, error) {
+	if !json.Valid(result) {
+		// Let the standard decoder describe what is wrong.
+		var v any
+		if err := json.Unmarshal(result, &v); err != nil {
+			return nil, false, nil, err
+		}
+		return nil, false, nil, errNotJSONObject
+	}
+	s := jsonScanner{b: result}
+	if s.next() != '{' {
+		return nil, false, nil, errNotJSONObject
+	}
+	s.pos++
+	var (
+		found   bool
+		version json.RawMessage
+	)
+	for s.next() == '"' {
+		key := s.key()
+		s.next() // ':'
+		s.pos++
+		s.next()
+		switch string(key) {
+		case "vector", "embedding":
+			var err error
+			if vec, err = s.float32Array(vec[:0]); err != nil {
+				return nil, false, nil, err
+			}
+			found = true
+		case SchemaVersionMetaKey:
+			start := s.pos
+			s.skipValue()
+			version = result[start:s.pos]
+		default:
+			s.skipValue()
+		}
+		if s.next() == ',' {
+			s.pos++
+		}
+	}
+	return vec, found, version, nil
+}
+
+// jsonScanner walks valid JSON.
+type jsonScanner struct {
+	b   []byte
+	pos int
+}
+
+// next skips whitespace and returns the byte at the current position, or 0
+// at the end.
+func (s *jsonScanner) next() byte {
+	for s.pos < len(s.b) {
+		switch c := s.b[s.pos]; c {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return c
+		}
+	}
+	return 0
+}
+
+// key consumes the string at the current position and returns it. Names
+// without escapes, which covers every field name the scanner looks for, are
+// returned without allocating.
+func (s *jsonScanner) key() []byte {
+	start := s.pos
+	escaped := s.skipString()
+	if !escaped {
+		return s.b[start+1 : s.pos-1]
+	}
+	var str string
+	_ = json.Unmarshal(s.b[start:s.pos], &str)
+	return []byte(str)
+}
+
+// skipString consumes the string at the current position and reports
+// whether it holds escapes.
+func (s *jsonScanner) skipString() (escaped bool) {
+	for s.pos++; s.b[s.pos] != '"'; s.pos++ {
+		if s.b[s.pos] == '\\' {
+			escaped = true
+			s.pos++
+		}
+	}
+	s.pos++
+	return escaped
+}
+
+// skipValue consumes the value at the current position.
+func (s *jsonScanner) skipValue() {
+	depth := 0
+	for s.pos < len(s.b) {
+		switch s.b[s.pos] {
+		case '"':
+			s.skipString()
+			if depth == 0 {
+				return
+			}
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				s.pos++
+				return
+			}
+		case ',', ' ', '\t', '\n', '\r':
+			if depth == 0 {
+				return
+			}
+		}
+		s.pos++
+	}
+}
+
+// float32Array appends the numbers of the array at the current position to
+// dst. null decodes to a nil slice.
+func (s *jsonScanner) float32Array(dst []float32) ([]float32, error) {
+	if s.b[s.pos] == 'n' {
+		s.skipValue()
+		return nil, nil
+	}
+	if s.b[s.pos] != '[' {
+		return nil, errors.New("vector is not an array")
+	}
+	s.pos++
+	for s.next() != ']' {
+		start := s.pos
+		for s.pos < len(s.b) {
+			if c := s.b[s.pos]; c == ',' || c == ']' || c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+				break
+			}
+			s.pos++
+		}
+		f, err := strconv.ParseFloat(string(s.b[start:s.pos]), 32)
+		if err != nil {
+			return nil, errors.New("vector holds a non-number: " + string(s.b[start:s.pos]))
+		}
+		dst = append(dst, float32(f))
+		if s.next() == ',' {
+			s.pos++
+		}
+	}
+	s.pos++
+	return dst, nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strconv"
 
+	"strings"
+
 	"github.com/gabriel-vasile/mimetype"
 )
 
@@ -114,6 +116,13 @@ func WithStopSequences(stopSequences []string) ImageTextGenerationRequestOption
 	}
 }
 
+// WithSeed sets the random seed, so sampled generations can be reproduced.
+func WithSeed(seed int64) ImageTextGenerationRequestOption {
+	return func(req *ImageTextGenerationRequest) {
+		req.Meta["seed"] = strconv.FormatInt(seed, 10)
+	}
+}
+
 // WithPrompt sets the text prompt for the generation request.
 func WithPrompt(prompt string) ImageTextGenerationRequestOption {
 	return func(req *ImageTextGenerationRequest) {
@@ -166,11 +175,16 @@ func NewImageTextGenerationRequest(payload []byte, opts ...ImageTextGenerationRe
 		return nil, fmt.Errorf("unsupported payload type: %s", mimeString)
 	}
 
-	req := &ImageTextGenerationRequest{
-		Payload:     payload,
-		PayloadMime: mimeString,
-		Meta:        make(map[string]string),
-	}
+	req := newGenerationRequest(opts)
+	req.Payload = payload
+	req.PayloadMime = mimeString
+	return req, nil
+}
+
+// newGenerationRequest returns a request with the default generation
+// parameters and opts applied, and no payload.
+func newGenerationRequest(opts []ImageTextGenerationRequestOption) *ImageTextGenerationRequest {
+	req := &ImageTextGenerationRequest{Meta: make(map[string]string)}
 
 	// Set default values
 	req.Meta["max_new_tokens"] = "512"
@@ -184,7 +198,88 @@ func NewImageTextGenerationRequest(payload []byte, opts ...ImageTextGenerationRe
 	for _, opt := range opts {
 		opt(req)
 	}
+	return req
+}
 
+// TextGenerationRequest represents a request for text-only generation (LLM).
+//
+// It carries either a prompt or chat-format messages, never both, together
+// with the same generation parameters as ImageTextGenerationRequest. The
+// prompt is sent as a text/plain payload and the messages as an
+// application/json {"messages": [...]} object; both are also kept in Meta
+// under "prompt" and "messages", where VLM-style nodes read them.
+//
+// Example:
+//
+//	req, err := types.NewTextGenerationRequest("Write a haiku about autumn",
+//		types.WithMaxTokens(64),
+//		types.WithTemperature(0.7))
+//
+//	inferReq := types.NewInferRequest(types.TaskTextGeneration).
+//	    ForTextGeneration(req, types.TaskTextGeneration).
+//	    Build()
+type TextGenerationRequest struct {
+	Payload     []byte            `json:"payload"`
+	PayloadMime string            `json:"payload_mime"`
+	Meta        map[string]string `json:"meta"`
+}
+
+// NewTextGenerationRequest creates a text-only generation request.
+//
+// The prompt may be empty when WithMessages supplies the conversation
+// instead. The sampling options of ImageTextGenerationRequest apply
+// unchanged, with the same defaults.
+//
+// Parameters:
+//   - prompt: The text to complete, or "" for a chat-format request
+//   - opts: Optional functions to configure generation parameters
+//
+// Returns:
+//   - *TextGenerationRequest: Configured request ready for ForTextGeneration()
+//   - error: An INVALID LumenError when there is neither a prompt nor
+//     messages, or both
+//
+// Example:
+//
+//	req, err := types.NewTextGenerationRequest("",
+//		types.WithMessages([]map[string]string{
+//			{"role": "system", "content": "You are terse."},
+//			{"role": "user", "content": "Name three primes."},
+//		}),
+//		types.WithSeed(42))
+func NewTextGenerationRequest(prompt string, opts ...ImageTextGenerationRequestOption) (*TextGenerationRequest, error) {
+	meta := newGenerationRequest(opts).Meta
+	errs := optionErrors{request: "text generation"}
+	if prompt != "" {
+		if p, ok := meta["prompt"]; ok && p != prompt {
+			errs.addf("prompt given both as an argument and through WithPrompt")
+		}
+		meta["prompt"] = prompt
+	}
+
+	prompt, messages := meta["prompt"], meta["messages"]
+	hasMessages := messages != "" && messages != "[]" && messages != "null"
+	switch {
+	case prompt != "" && strings.TrimSpace(prompt) == "":
+		errs.addf("prompt is blank")
+	case prompt != "" && hasMessages:
+		errs.addf("prompt and messages are mutually exclusive")
+	case prompt == "" && !hasMessages:
+		errs.addf("a prompt or messages is required")
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	if !hasMessages {
+		delete(meta, "messages")
+	}
+
+	req := &TextGenerationRequest{Meta: meta}
+	if hasMessages {
+		req.Payload, req.PayloadMime = []byte(`{"messages":`+messages+`}`), "application/json"
+	} else {
+		req.Payload, req.PayloadMime = []byte(prompt), "text/plain"
+	}
 	return req, nil
 }
 
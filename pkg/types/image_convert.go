@@ -0,0 +1,101 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"mime"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// imageCodecs are the image MIME types ConvertImage reads, each with the
+// format it writes them as, or -1 for types it only reads.
+var imageCodecs = map[string]imaging.Format{
+	"image/jpeg": imaging.JPEG,
+	"image/png":  imaging.PNG,
+	"image/gif":  -1,
+	"image/webp": -1,
+}
+
+// convertJPEGQuality is the quality ConvertImage encodes JPEG at.
+const convertJPEGQuality = 90
+
+// CanConvertImage reports whether ConvertImage can turn an image of MIME
+// type from into one of MIME type to: PNG and JPEG either way, and GIF (its
+// first frame) or WebP into either.
+func CanConvertImage(from, to string) bool {
+	from, to = BaseMIME(from), BaseMIME(to)
+	_, readable := imageCodecs[from]
+	format, ok := imageCodecs[to]
+	return readable && ok && format >= 0 && from != to
+}
+
+// ConvertImage decodes an encoded image and re-encodes it as MIME type to,
+// which must be "image/jpeg" or "image/png". A GIF yields its first frame,
+// and transparency is flattened onto white when encoding JPEG.
+func ConvertImage(payload []byte, to string) ([]byte, error) {
+	to = BaseMIME(to)
+	format, ok := imageCodecs[to]
+	if !ok || format < 0 {
+		return nil, fmt.Errorf("cannot encode images as %s", to)
+	}
+	src, _, err := image.Decode(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	var opts []imaging.EncodeOption
+	if format == imaging.JPEG {
+		src = flattenAlpha(src)
+		opts = append(opts, imaging.JPEGQuality(convertJPEGQuality))
+	}
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, src, format, opts...); err != nil {
+		return nil, fmt.Errorf("encode %s: %w", to, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// flattenAlpha draws img over white unless it is already opaque, since JPEG
+// would otherwise render transparent pixels black.
+func flattenAlpha(img image.Image) image.Image {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return img
+	}
+	bounds := img.Bounds()
+	bg := imaging.New(bounds.Dx(), bounds.Dy(), color.White)
+	return imaging.Overlay(bg, img, image.Point{}, 1)
+}
+
+// BaseMIME returns a MIME type without its parameters, lower-cased:
+// "Image/PNG; q=1" becomes "image/png". Unparseable input is only trimmed
+// and lower-cased.
+func BaseMIME(s string) string {
+	if media, _, err := mime.ParseMediaType(s); err == nil {
+		return media
+	}
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// MIMEAccepted reports whether a payload of MIME type m matches one of the
+// accepted types, which may be wildcards such as "image/*" or "*/*".
+// Parameters are ignored on both sides. An empty accepted list accepts
+// everything.
+func MIMEAccepted(m string, accepted []string) bool {
+	if len(accepted) == 0 {
+		return true
+	}
+	m = BaseMIME(m)
+	major, _, _ := strings.Cut(m, "/")
+	for _, a := range accepted {
+		switch a = BaseMIME(a); a {
+		case m, "*/*", "*":
+			return true
+		case major + "/*":
+			return true
+		}
+	}
+	return false
+}
@@ -49,6 +49,9 @@ type OCRRequest struct {
 	DetectionThreshold   float32 `json:"detection_threshold,omitempty"`
 	RecognitionThreshold float32 `json:"recognition_threshold,omitempty"`
 	UseAngleCls          bool    `json:"use_angle_cls,omitempty"`
+
+	// strict rejects out-of-range thresholds instead of clamping them.
+	strict bool
 }
 
 type OCRRequestOption func(*OCRRequest)
@@ -65,6 +68,14 @@ func WithRecognitionThreshold(threshold float32) OCRRequestOption {
 	}
 }
 
+// WithStrictOCRValidation makes NewOCRRequest reject thresholds outside
+// [0, 1] instead of clamping them into range.
+func WithStrictOCRValidation() OCRRequestOption {
+	return func(req *OCRRequest) {
+		req.strict = true
+	}
+}
+
 func WithUseAngleCls(useAngleCls bool) OCRRequestOption {
 	return func(req *OCRRequest) {
 		req.UseAngleCls = useAngleCls
@@ -108,7 +119,19 @@ func NewOCRRequest(payload []byte, opts ...OCRRequestOption) (*OCRRequest, error
 		for _, opt := range opts {
 			opt(req)
 		}
+		if err := req.validate(); err != nil {
+			return nil, err
+		}
 		return req, nil
 	}
 	return nil, fmt.Errorf("unsupported payload type: %s", mimeString)
 }
+
+// validate checks the thresholds set on req, clamping those outside [0, 1]
+// unless strict.
+func (req *OCRRequest) validate() error {
+	errs := optionErrors{request: "OCR", strict: req.strict}
+	errs.threshold("detection_threshold", &req.DetectionThreshold)
+	errs.threshold("recognition_threshold", &req.RecognitionThreshold)
+	return errs.err()
+}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+// optionErrors collects every invalid option of a request, so the caller
+// fixes them in one go rather than one error at a time.
+type optionErrors struct {
+	request  string
+	strict   bool
+	problems []string
+}
+
+func (e *optionErrors) addf(format string, args ...any) {
+	e.problems = append(e.problems, fmt.Sprintf(format, args...))
+}
+
+// threshold checks an option that must lie in [0, 1]; 0 leaves it to the
+// node. Outside that range it is clamped, or reported under strict
+// validation. NaN is always reported.
+func (e *optionErrors) threshold(name string, v *float32) {
+	switch {
+	case math.IsNaN(float64(*v)):
+		e.addf("%s must be between 0 and 1, got NaN", name)
+	case *v >= 0 && *v <= 1:
+	case e.strict:
+		e.addf("%s must be between 0 and 1, got %g", name, *v)
+	default:
+		*v = float32(math.Max(0, math.Min(1, float64(*v))))
+	}
+}
+
+// err returns an INVALID error listing every problem, or nil. The problems
+// are also its Details.
+func (e *optionErrors) err() error {
+	if len(e.problems) == 0 {
+		return nil
+	}
+	return utils.InvalidError(fmt.Sprintf("invalid %s options: %s", e.request, strings.Join(e.problems, "; ")), e.problems)
+}
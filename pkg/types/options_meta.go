@@ -0,0 +1,107 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReservedMetaPrefix starts the request Meta keys the SDK itself sets, such
+// as lumen.client_version. Caller options may not use it.
+const ReservedMetaPrefix = "lumen."
+
+// MaxOptionsMetaBytes caps the total size, keys plus values, of the Meta
+// MetaFromOptions produces.
+const MaxOptionsMetaBytes = 16 << 10
+
+// MetaFromOptions flattens node-specific options, such as the options
+// object of a JSON request, into request Meta:
+//   - strings are kept as they are;
+//   - booleans become "true" or "false";
+//   - numbers are written in decimal without an exponent ("512", "0.25");
+//   - objects and arrays are JSON-encoded.
+//
+// It fails with an INVALID error naming every rejected key: an empty key, a
+// key under ReservedMetaPrefix, null, NaN, infinities and values with no
+// JSON form (functions, channels, complex numbers), and a result over
+// MaxOptionsMetaBytes. A nil or empty opts gives nil Meta.
+func MetaFromOptions(opts map[string]any) (map[string]string, error) {
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	errs := &optionErrors{request: "node"}
+	meta := make(map[string]string, len(opts))
+	size := 0
+	for _, k := range keys {
+		switch {
+		case strings.TrimSpace(k) == "":
+			errs.addf("option keys must not be empty")
+			continue
+		case strings.HasPrefix(k, ReservedMetaPrefix):
+			errs.addf("option %q uses the reserved prefix %q", k, ReservedMetaPrefix)
+			continue
+		}
+		v, err := stringifyOption(opts[k])
+		if err != nil {
+			errs.addf("option %q: %v", k, err)
+			continue
+		}
+		meta[k] = v
+		size += len(k) + len(v)
+	}
+	if size > MaxOptionsMetaBytes {
+		errs.addf("options take %d bytes, more than %d", size, MaxOptionsMetaBytes)
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+func stringifyOption(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", fmt.Errorf("null has no string form")
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	case float32:
+		return formatOptionFloat(float64(v), 32)
+	case float64:
+		return formatOptionFloat(v, 64)
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Pointer:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("cannot be JSON-encoded: %v", err)
+		}
+		return string(data), nil
+	default:
+		return "", fmt.Errorf("%T has no string form", v)
+	}
+}
+
+func formatOptionFloat(f float64, bits int) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("%g has no string form", f)
+	}
+	return strconv.FormatFloat(f, 'f', -1, bits), nil
+}
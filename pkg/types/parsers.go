@@ -3,6 +3,8 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	pb "github.com/edwinzhancn/lumen-sdk/proto"
 )
@@ -11,7 +13,7 @@ import (
 //
 // This parser handles the deserialization and type conversion of protobuf responses
 // into Go structs. It validates response MIME types and ensures the correct schema
-// is used for each response type (embedding, classification, face detection).
+// is used for each response type (embedding, classification, face detection, audio).
 //
 // Role in project: Bridges the gap between protobuf responses and Go application code.
 // Provides type safety and validation to prevent runtime errors from mismatched response types.
@@ -25,7 +27,8 @@ import (
 //	    log.Fatalf("Failed to parse response: %v", err)
 //	}
 type InferResponseParser struct {
-	resp *pb.InferResponse
+	resp    *pb.InferResponse
+	warning *CompatibilityWarning
 }
 
 // ParseInferResponse creates a new parser for the given inference response.
@@ -92,7 +95,7 @@ func (p *InferResponseParser) AsFaceResponse() (*FaceV1, error) {
 	}
 
 	var result FaceV1
-	if err := json.Unmarshal(p.resp.Result, &result); err != nil {
+	if err := p.decodeVersioned("face_v1", &result); err != nil {
 		return nil, fmt.Errorf("failed to parse detection response: %w", err)
 	}
 	return &result, nil
@@ -139,12 +142,66 @@ func (p *InferResponseParser) AsEmbeddingResponse() (*EmbeddingV1, error) {
 	}
 
 	var result EmbeddingV1
-	if err := json.Unmarshal(p.resp.Result, &result); err != nil {
+	if err := p.decodeVersioned("embedding_v1", &result); err != nil {
 		return nil, fmt.Errorf("failed to parse embedding response: %w", err)
 	}
 	return &result, nil
 }
 
+// AsEmbeddingVector parses only the vector of an embedding response.
+//
+// It scans the result in one pass, decoding the vector straight into a
+// []float32 and skipping every other field without materializing it, so it
+// allocates far less than AsEmbeddingResponse. Use it for high-volume embedding where
+// the model ID and dimension are not needed, ideally with the "vector"
+// projection requested (client.WithResultProjection). Nodes that ignore the
+// projection send the full result, which parses the same way. Results of a
+// schema revision other than 1 or 2 take the AsEmbeddingResponse path.
+//
+// Example:
+//
+//	ctx = client.WithResultProjection(ctx, "vector")
+//	result, _ := c.Infer(ctx, req)
+//	vec, err := types.ParseInferResponse(result).AsEmbeddingVector()
+func (p *InferResponseParser) AsEmbeddingVector() ([]float32, error) {
+	return p.AsEmbeddingVectorInto(nil)
+}
+
+// AsEmbeddingVectorInto is AsEmbeddingVector decoding into the storage of
+// buf, which is grown when too small. Callers in a tight loop pass the
+// previous result back to avoid allocating a vector per response; the
+// returned slice is then only valid until the next call.
+func (p *InferResponseParser) AsEmbeddingVectorInto(buf []float32) ([]float32, error) {
+	if p.resp.ResultMime != "application/json;schema=embedding_v1" {
+		return nil, fmt.Errorf("unexpected response type: %s", p.resp.ResultMime)
+	}
+
+	vec, found, rawVersion, err := scanEmbeddingVector(p.resp.Result, buf[:0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse embedding response: %w", err)
+	}
+	version := CurrentSchemaVersion("embedding_v1")
+	if raw, ok := p.resp.Meta[SchemaVersionMetaKey]; ok {
+		version, err = strconv.Atoi(strings.TrimSpace(raw))
+	} else if rawVersion != nil {
+		version, err = parseSchemaVersion(rawVersion)
+	}
+	if err != nil || version < 1 || version > CurrentSchemaVersion("embedding_v1") {
+		// Let the full decoder report the error or the compatibility
+		// warning.
+		emb, err := p.AsEmbeddingResponse()
+		if err != nil {
+			return nil, err
+		}
+		return append(buf[:0], emb.Vector...), nil
+	}
+	p.warning = nil
+	if !found {
+		return nil, fmt.Errorf("failed to parse embedding response: no vector")
+	}
+	return vec, nil
+}
+
 // AsClassificationResponse parses the response as image classification results.
 //
 // This method validates the MIME type (application/json;schema=labels_v1) and deserializes
@@ -186,7 +243,7 @@ func (p *InferResponseParser) AsClassificationResponse() (*LabelsV1, error) {
 	}
 
 	var result LabelsV1
-	if err := json.Unmarshal(p.resp.Result, &result); err != nil {
+	if err := p.decodeVersioned("labels_v1", &result); err != nil {
 		return nil, fmt.Errorf("failed to parse classification response: %w", err)
 	}
 	return &result, nil
@@ -208,12 +265,63 @@ func (p *InferResponseParser) AsOCRResponse() (*OCRV1, error) {
 	}
 
 	var result OCRV1
-	if err := json.Unmarshal(p.resp.Result, &result); err != nil {
+	if err := p.decodeVersioned("ocr_v1", &result); err != nil {
 		return nil, fmt.Errorf("failed to parse OCR response: %w", err)
 	}
 	return &result, nil
 }
 
+// AsAudioResponse parses the response as synthesized audio.
+//
+// This method accepts any audio/* result MIME type (e.g. audio/wav,
+// audio/pcm;rate=24000) and returns the audio bytes with the media type,
+// sample rate and model ID reported alongside them.
+//
+// Returns:
+//   - *AudioV1: The audio bytes and their format
+//   - error: Non-nil if the result is not audio or is empty
+//
+// Role in project: Type-safe conversion for text-to-speech responses.
+//
+// Example:
+//
+//	result, _ := client.Infer(ctx, ttsReq)
+//	audio, err := types.ParseInferResponse(result).AsAudioResponse()
+//	if err != nil {
+//	    log.Fatalf("Failed to parse audio: %v", err)
+//	}
+//	os.WriteFile("speech.wav", audio.Audio, 0o644)
+func (p *InferResponseParser) AsAudioResponse() (*AudioV1, error) {
+	if !IsAudioMime(p.resp.ResultMime) {
+		return nil, fmt.Errorf("unexpected response type: %s", p.resp.ResultMime)
+	}
+	if len(p.resp.Result) == 0 {
+		return nil, fmt.Errorf("failed to parse audio response: empty result")
+	}
+	return newAudioV1(p.resp.Result, p.resp.ResultMime, p.resp.Meta), nil
+}
+
+// CompatibilityWarning returns the warning recorded by the last typed parse,
+// or nil. It is set when the node reported a schema_version newer than this
+// SDK supports and the result was decoded best-effort.
+//
+// Example:
+//
+//	parser := types.ParseInferResponse(result)
+//	faces, err := parser.AsFaceResponse()
+//	if w := parser.CompatibilityWarning(); w != nil {
+//	    log.Printf("upgrade the SDK: %s", w)
+//	}
+func (p *InferResponseParser) CompatibilityWarning() *CompatibilityWarning {
+	return p.warning
+}
+
+func (p *InferResponseParser) decodeVersioned(schema string, out any) error {
+	warning, err := decodeVersionedResult(schema, p.resp.Result, p.resp.Meta, out)
+	p.warning = warning
+	return err
+}
+
 // AsTextGenerationResponse parses the response as text generation results.
 //
 // This method validates that the response has the correct MIME type (application/json;schema=text_generation_v1)
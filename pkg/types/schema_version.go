@@ -0,0 +1,216 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+// SchemaVersionMetaKey is the response meta key carrying the revision of the
+// result schema. Nodes may instead embed a top-level "schema_version" field
+// in the JSON result; the meta key wins when both are present. Responses
+// without either are treated as the current revision.
+const SchemaVersionMetaKey = "schema_version"
+
+// CompatibilityWarning reports a response whose schema revision is newer
+// than this SDK understands. The result was decoded best-effort: fields the
+// SDK does not know about were dropped.
+type CompatibilityWarning struct {
+	Schema    string `json:"schema"`
+	Version   int    `json:"version"`
+	Supported int    `json:"supported"`
+}
+
+func (w *CompatibilityWarning) String() string {
+	return fmt.Sprintf("%s schema_version %d is newer than supported version %d; unknown fields were ignored",
+		w.Schema, w.Version, w.Supported)
+}
+
+// schemaTranslator rewrites a result from one revision into the layout of
+// the next revision.
+type schemaTranslator func(map[string]any) error
+
+// schemaSpec describes the revisions of one result schema. translators[v]
+// upgrades revision v to v+1, so every revision from oldest to current has a
+// path to the current layout.
+type schemaSpec struct {
+	current     int
+	oldest      int
+	translators map[int]schemaTranslator
+}
+
+// resultSchemas lists the versioned result schemas. Revision 2 is the layout
+// modelled by the Go types; revision 1 is the earlier layout:
+//   - embedding_v1: the vector was named "embedding" and "dim" was optional
+//   - labels_v1:    labels were [label, score] pairs
+//   - face_v1:      face confidence was named "score" and "count" was optional
+//   - ocr_v1:       item confidence was named "score" and "count" was optional
+var resultSchemas = map[string]schemaSpec{
+	"embedding_v1": {current: 2, oldest: 1, translators: map[int]schemaTranslator{1: translateEmbeddingR1}},
+	"labels_v1":    {current: 2, oldest: 1, translators: map[int]schemaTranslator{1: translateLabelsR1}},
+	"face_v1":      {current: 2, oldest: 1, translators: map[int]schemaTranslator{1: translateFaceR1}},
+	"ocr_v1":       {current: 2, oldest: 1, translators: map[int]schemaTranslator{1: translateOCRR1}},
+}
+
+// CurrentSchemaVersion returns the schema revision this SDK produces types
+// for, or 0 for unversioned schemas.
+func CurrentSchemaVersion(schema string) int {
+	return resultSchemas[schema].current
+}
+
+// decodeVersionedResult decodes result into out after upgrading it to the
+// current revision of schema. It returns a warning for newer revisions and a
+// ErrCodecMismatch LumenError for revisions it cannot read.
+func decodeVersionedResult(schema string, result []byte, meta map[string]string, out any) (*CompatibilityWarning, error) {
+	spec, ok := resultSchemas[schema]
+	if !ok {
+		return nil, json.Unmarshal(result, out)
+	}
+
+	version, err := schemaVersionOf(meta, result, spec.current)
+	if err != nil {
+		return nil, utils.Wrap(err, utils.ErrCodecMismatch, fmt.Sprintf("%s: invalid schema_version", schema))
+	}
+	if version == spec.current {
+		return nil, json.Unmarshal(result, out)
+	}
+	if version < spec.oldest {
+		return nil, incompatibleSchema(schema, version, spec, nil)
+	}
+
+	if version > spec.current {
+		// Unknown fields are dropped by the decoder; a failure means the
+		// layout changed in a way this SDK cannot follow.
+		if err := json.Unmarshal(result, out); err != nil {
+			return nil, incompatibleSchema(schema, version, spec, err)
+		}
+		return &CompatibilityWarning{Schema: schema, Version: version, Supported: spec.current}, nil
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(result, &doc); err != nil {
+		return nil, err
+	}
+	for v := version; v < spec.current; v++ {
+		if err := spec.translators[v](doc); err != nil {
+			return nil, incompatibleSchema(schema, version, spec, err)
+		}
+	}
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(upgraded, out); err != nil {
+		return nil, incompatibleSchema(schema, version, spec, err)
+	}
+	return nil, nil
+}
+
+// schemaVersionOf reads the revision from meta or, failing that, from a
+// top-level "schema_version" field of the result.
+func schemaVersionOf(meta map[string]string, result []byte, current int) (int, error) {
+	if raw, ok := meta[SchemaVersionMetaKey]; ok {
+		return strconv.Atoi(strings.TrimSpace(raw))
+	}
+	var peek struct {
+		SchemaVersion json.RawMessage `json:"schema_version"`
+	}
+	if err := json.Unmarshal(result, &peek); err != nil {
+		return 0, err
+	}
+	if len(peek.SchemaVersion) == 0 {
+		return current, nil
+	}
+	return parseSchemaVersion(peek.SchemaVersion)
+}
+
+// parseSchemaVersion reads a "schema_version" field, a number or a numeric
+// string.
+func parseSchemaVersion(raw json.RawMessage) (int, error) {
+	var n int
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n, nil
+	}
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return 0, fmt.Errorf("schema_version must be an integer, got %s", raw)
+	}
+	return strconv.Atoi(strings.TrimSpace(str))
+}
+
+func incompatibleSchema(schema string, version int, spec schemaSpec, cause error) error {
+	msg := fmt.Sprintf("%s schema_version %d is incompatible with supported versions %d-%d",
+		schema, version, spec.oldest, spec.current)
+	details := map[string]int{"version": version, "supported": spec.current}
+	if cause != nil {
+		return utils.Wrap(cause, utils.ErrCodecMismatch, msg, details)
+	}
+	return utils.NewLumenError(utils.ErrCodecMismatch, msg, details)
+}
+
+// --- revision 1 -> 2 translators ---
+
+func translateEmbeddingR1(doc map[string]any) error {
+	renameField(doc, "embedding", "vector")
+	if _, ok := doc["dim"]; !ok {
+		vec, _ := doc["vector"].([]any)
+		doc["dim"] = len(vec)
+	}
+	return nil
+}
+
+func translateLabelsR1(doc map[string]any) error {
+	labels, ok := doc["labels"].([]any)
+	if !ok {
+		return nil
+	}
+	for i, entry := range labels {
+		pair, ok := entry.([]any)
+		if !ok {
+			continue
+		}
+		if len(pair) != 2 {
+			return fmt.Errorf("label %d: expected [label, score] pair, got %d elements", i, len(pair))
+		}
+		labels[i] = map[string]any{"label": pair[0], "score": pair[1]}
+	}
+	return nil
+}
+
+func translateFaceR1(doc map[string]any) error {
+	faces, _ := doc["faces"].([]any)
+	for _, entry := range faces {
+		if face, ok := entry.(map[string]any); ok {
+			renameField(face, "score", "confidence")
+		}
+	}
+	if _, ok := doc["count"]; !ok {
+		doc["count"] = len(faces)
+	}
+	return nil
+}
+
+func translateOCRR1(doc map[string]any) error {
+	items, _ := doc["items"].([]any)
+	for _, entry := range items {
+		if item, ok := entry.(map[string]any); ok {
+			renameField(item, "score", "confidence")
+		}
+	}
+	if _, ok := doc["count"]; !ok {
+		doc["count"] = len(items)
+	}
+	return nil
+}
+
+func renameField(m map[string]any, from, to string) {
+	if v, ok := m[from]; ok {
+		if _, exists := m[to]; !exists {
+			m[to] = v
+		}
+		delete(m, from)
+	}
+}
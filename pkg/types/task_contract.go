@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/edwinzhancn/lumen-sdk/pkg/tasks"
 	pb "github.com/edwinzhancn/lumen-sdk/proto"
 	"github.com/gabriel-vasile/mimetype"
 )
@@ -15,6 +16,7 @@ const (
 	TaskBioCLIPClassify    = "bioclip_classify"
 	TaskOCR                = "ocr"
 	TaskFaceRecognition    = "face_recognition"
+	TaskTextGeneration     = "text_generation"
 
 	ServiceCLIP    = "clip"
 	ServiceBioCLIP = "bioclip"
@@ -175,7 +177,9 @@ func ValidateTaskRequest(req *pb.InferRequest) error {
 	}
 
 	isTensor := strings.EqualFold(mime, DefaultTensorMIME)
-	switch req.Task {
+	// A versioned task ("semantic_text_embed@v2") takes the same payload
+	// as its unversioned name.
+	switch tasks.Name(req.Task) {
 	case TaskSemanticTextEmbed:
 		if isTensor {
 			return fmt.Errorf("%s does not support tensor input", TaskSemanticTextEmbed)
@@ -206,6 +210,13 @@ func ValidateTaskRequest(req *pb.InferRequest) error {
 			return validateDetTensorTask(req, PreprocessInsightFaceDet, true)
 		}
 		return validateRawImageMIME(mime)
+	case TaskTextGeneration:
+		if isTensor {
+			return fmt.Errorf("%s does not support tensor input", TaskTextGeneration)
+		}
+		if mime != "text/plain" && mime != "application/json" {
+			return fmt.Errorf("%s requires text/plain or application/json payload_mime", TaskTextGeneration)
+		}
 	default:
 		if _, err := ValidateTensorFastPath(req, TensorValidationOptions{}); err != nil {
 			return err
@@ -0,0 +1,180 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// Tile is one region of a larger image produced by TileImage.
+//
+// X and Y are the tile's top-left corner in the source image; detections
+// made on Data are translated back to source coordinates by adding them.
+type Tile struct {
+	Data        []byte `json:"-"`
+	PayloadMime string `json:"payload_mime"`
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+// TileImage splits an encoded image into tiles of at most tileSize×tileSize
+// pixels whose neighbours share overlap pixels, so objects straddling a seam
+// appear whole in at least one tile when they are smaller than the overlap.
+//
+// Tiles are returned row by row. JPEG sources are re-encoded as JPEG and
+// everything else as PNG. An image no larger than tileSize yields a single
+// tile covering it.
+//
+// Example:
+//
+//	tiles, _ := types.TileImage(scan, 1024, 128)
+//	results := make([]*types.FaceV1, len(tiles))
+//	for i, tile := range tiles {
+//	    faceReq, _ := types.NewFaceRecognitionRequest(tile.Data)
+//	    resp, _ := client.Infer(ctx, types.NewInferRequest(types.TaskFaceRecognition).
+//	        ForFaceDetection(faceReq, types.TaskFaceRecognition).Build())
+//	    results[i], _ = types.ParseInferResponse(resp).AsFaceResponse()
+//	}
+//	merged := types.MergeDetections(tiles, results, 0.5)
+func TileImage(img []byte, tileSize, overlap int) ([]Tile, error) {
+	if tileSize <= 0 {
+		return nil, fmt.Errorf("tile size must be positive, got %d", tileSize)
+	}
+	if overlap < 0 || overlap >= tileSize {
+		return nil, fmt.Errorf("overlap must be in [0, %d), got %d", tileSize, overlap)
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(img))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	outFormat, mime := imaging.PNG, "image/png"
+	if format == "jpeg" {
+		outFormat, mime = imaging.JPEG, "image/jpeg"
+	}
+
+	bounds := src.Bounds()
+	xs := tileOrigins(bounds.Dx(), tileSize, overlap)
+	ys := tileOrigins(bounds.Dy(), tileSize, overlap)
+	tiles := make([]Tile, 0, len(xs)*len(ys))
+	for _, y := range ys {
+		for _, x := range xs {
+			w := min(tileSize, bounds.Dx()-x)
+			h := min(tileSize, bounds.Dy()-y)
+			rect := image.Rect(bounds.Min.X+x, bounds.Min.Y+y, bounds.Min.X+x+w, bounds.Min.Y+y+h)
+			var buf bytes.Buffer
+			if err := imaging.Encode(&buf, imaging.Crop(src, rect), outFormat); err != nil {
+				return nil, fmt.Errorf("encode tile at (%d,%d): %w", x, y, err)
+			}
+			tiles = append(tiles, Tile{Data: buf.Bytes(), PayloadMime: mime, X: x, Y: y, Width: w, Height: h})
+		}
+	}
+	return tiles, nil
+}
+
+// tileOrigins returns the tile start offsets along one axis of length n. The
+// last tile is aligned to the far edge so every tile except a lone one is
+// full-sized.
+func tileOrigins(n, size, overlap int) []int {
+	if n <= size {
+		return []int{0}
+	}
+	stride := size - overlap
+	var origins []int
+	for o := 0; ; o += stride {
+		if o+size >= n {
+			origins = append(origins, n-size)
+			return origins
+		}
+		origins = append(origins, o)
+	}
+}
+
+// MergeDetections combines per-tile face detection results into one result in
+// source-image coordinates.
+//
+// results[i] must belong to tiles[i]; nil entries are skipped. Bounding boxes
+// and landmarks are shifted by the tile origin, then duplicates found in
+// overlapping tiles are removed with greedy non-maximum suppression: faces are
+// taken in descending confidence order and any face whose box overlaps an
+// already kept one with IoU above iouThreshold is dropped. ModelID is taken
+// from the first non-nil result.
+func MergeDetections(tiles []Tile, results []*FaceV1, iouThreshold float32) *FaceV1 {
+	merged := &FaceV1{Faces: []Face{}}
+	var candidates []Face
+	for i, res := range results {
+		if res == nil || i >= len(tiles) {
+			continue
+		}
+		if merged.ModelID == "" {
+			merged.ModelID = res.ModelID
+		}
+		dx, dy := float32(tiles[i].X), float32(tiles[i].Y)
+		for _, f := range res.Faces {
+			candidates = append(candidates, Face{
+				BBox:       offsetPoints(f.BBox, dx, dy),
+				Confidence: f.Confidence,
+				Landmarks:  offsetPoints(f.Landmarks, dx, dy),
+				Embedding:  f.Embedding,
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].Confidence > candidates[b].Confidence
+	})
+	for _, c := range candidates {
+		keep := true
+		for _, k := range merged.Faces {
+			if boxIoU(c.BBox, k.BBox) > iouThreshold {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			merged.Faces = append(merged.Faces, c)
+		}
+	}
+	merged.Count = len(merged.Faces)
+	return merged
+}
+
+// offsetPoints shifts a flat [x0, y0, x1, y1, ...] slice by (dx, dy).
+func offsetPoints(pts []float32, dx, dy float32) []float32 {
+	if pts == nil {
+		return nil
+	}
+	out := make([]float32, len(pts))
+	for i, v := range pts {
+		if i%2 == 0 {
+			out[i] = v + dx
+		} else {
+			out[i] = v + dy
+		}
+	}
+	return out
+}
+
+// boxIoU returns the intersection over union of two [x1, y1, x2, y2] boxes,
+// or 0 if either is malformed.
+func boxIoU(a, b []float32) float32 {
+	if len(a) < 4 || len(b) < 4 {
+		return 0
+	}
+	iw := min(a[2], b[2]) - max(a[0], b[0])
+	ih := min(a[3], b[3]) - max(a[1], b[1])
+	if iw <= 0 || ih <= 0 {
+		return 0
+	}
+	inter := iw * ih
+	union := (a[2]-a[0])*(a[3]-a[1]) + (b[2]-b[0])*(b[3]-b[1]) - inter
+	if union <= 0 {
+		return 0
+	}
+	return inter / union
+}
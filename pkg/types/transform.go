@@ -0,0 +1,146 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// TransformMetaKey is the response Meta key listing the transforms applied
+// to a result after it left the node, comma-separated in the order they ran,
+// e.g. "dimensions=256,precision=4". Consumers comparing results with
+// full-precision ones can tell them apart by it.
+const TransformMetaKey = "lumen.transform"
+
+// Transform rewrites a typed result, such as an EmbeddingV1 or OCRV1. It
+// returns a new value and leaves its argument unchanged. Name describes it
+// for TransformMetaKey.
+type Transform[T any] struct {
+	Name  string
+	Apply func(T) T
+}
+
+// Chain composes transforms into one that applies them in order and is
+// named after all of them.
+func Chain[T any](ts ...Transform[T]) Transform[T] {
+	names := make([]string, 0, len(ts))
+	for _, t := range ts {
+		if t.Name != "" {
+			names = append(names, t.Name)
+		}
+	}
+	return Transform[T]{
+		Name: strings.Join(names, ","),
+		Apply: func(v T) T {
+			for _, t := range ts {
+				v = t.Apply(v)
+			}
+			return v
+		},
+	}
+}
+
+// TruncateDimensions keeps the first n values of an embedding, which suits
+// models trained to front-load information (Matryoshka embeddings).
+// Embeddings with at most n values are unchanged. Cosine similarity needs
+// the result re-normalized; chain NormalizeEmbedding after it.
+func TruncateDimensions(n int) Transform[EmbeddingV1] {
+	return Transform[EmbeddingV1]{
+		Name: fmt.Sprintf("dimensions=%d", n),
+		Apply: func(e EmbeddingV1) EmbeddingV1 {
+			if n <= 0 || len(e.Vector) <= n {
+				return e
+			}
+			e.Vector = append([]float32(nil), e.Vector[:n]...)
+			e.Dim = n
+			return e
+		},
+	}
+}
+
+// MeanPoolDimensions reduces an embedding to n values, each the mean of a
+// contiguous run of the original ones; runs differ in length by at most one
+// when n does not divide the dimension. Embeddings with at most n values are
+// unchanged.
+func MeanPoolDimensions(n int) Transform[EmbeddingV1] {
+	return Transform[EmbeddingV1]{
+		Name: fmt.Sprintf("dimensions=%d/mean", n),
+		Apply: func(e EmbeddingV1) EmbeddingV1 {
+			dim := len(e.Vector)
+			if n <= 0 || dim <= n {
+				return e
+			}
+			pooled := make([]float32, n)
+			for i := range pooled {
+				lo, hi := i*dim/n, (i+1)*dim/n
+				var sum float64
+				for _, v := range e.Vector[lo:hi] {
+					sum += float64(v)
+				}
+				pooled[i] = float32(sum / float64(hi-lo))
+			}
+			e.Vector = pooled
+			e.Dim = n
+			return e
+		},
+	}
+}
+
+// NormalizeEmbedding scales an embedding to unit length; see
+// EmbeddingV1.Normalize.
+func NormalizeEmbedding() Transform[EmbeddingV1] {
+	return Transform[EmbeddingV1]{Name: "normalize", Apply: EmbeddingV1.Normalize}
+}
+
+// RoundVector rounds each value of an embedding to the given number of
+// decimal places, so it serializes to fewer JSON bytes.
+func RoundVector(decimals int) Transform[EmbeddingV1] {
+	scale := math.Pow10(decimals)
+	return Transform[EmbeddingV1]{
+		Name: fmt.Sprintf("precision=%d", decimals),
+		Apply: func(e EmbeddingV1) EmbeddingV1 {
+			rounded := make([]float32, len(e.Vector))
+			for i, v := range e.Vector {
+				rounded[i] = float32(math.Round(float64(v)*scale) / scale)
+			}
+			e.Vector = rounded
+			return e
+		},
+	}
+}
+
+// MinFaceConfidence drops faces detected with confidence below min and
+// updates Count.
+func MinFaceConfidence(min float32) Transform[FaceV1] {
+	return Transform[FaceV1]{
+		Name: fmt.Sprintf("min_confidence=%g", min),
+		Apply: func(f FaceV1) FaceV1 {
+			kept := make([]Face, 0, len(f.Faces))
+			for _, face := range f.Faces {
+				if face.Confidence >= min {
+					kept = append(kept, face)
+				}
+			}
+			f.Faces, f.Count = kept, len(kept)
+			return f
+		},
+	}
+}
+
+// MinOCRConfidence drops text regions recognized with confidence below min
+// and updates Count.
+func MinOCRConfidence(min float32) Transform[OCRV1] {
+	return Transform[OCRV1]{
+		Name: fmt.Sprintf("min_confidence=%g", min),
+		Apply: func(o OCRV1) OCRV1 {
+			kept := make([]OCRItem, 0, len(o.Items))
+			for _, item := range o.Items {
+				if item.Confidence >= min {
+					kept = append(kept, item)
+				}
+			}
+			o.Items, o.Count = kept, len(kept)
+			return o
+		},
+	}
+}
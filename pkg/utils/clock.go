@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time source of a time-driven component: its timestamps,
+// tickers, timeouts and sleeps. Components default to RealClock; tests pass
+// a FakeClock and move it with Advance, so intervals and expiries are
+// exercised in microseconds and in a fixed order.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// Ticker delivers ticks on C until stopped, like a *time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer fires once, like a *time.Timer: on C for NewTimer, by calling its
+// func for AfterFunc, whose C is nil.
+type Timer interface {
+	C() <-chan time.Time
+	// Stop keeps the timer from firing; it reports false if the timer
+	// already fired or was stopped.
+	Stop() bool
+}
+
+// RealClock is the wall clock.
+var RealClock Clock = realClock{}
+
+// ClockOrReal returns clock, or RealClock when it is nil.
+func ClockOrReal(clock Clock) Clock {
+	if clock == nil {
+		return RealClock
+	}
+	return clock
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop() bool          { return t.t.Stop() }
+
+// FakeClock is a Clock that only moves when Advance is called. Timers and
+// tickers due by the new time fire in order, each at its own due time.
+// Unlike a time.Ticker, which drops ticks nobody reads, a fake ticker hands
+// each tick over: Advance waits until it is received or the ticker is
+// stopped, so a component has seen every tick once Advance returns. For
+// the same reason an AfterFunc func runs on Advance's goroutine and has
+// returned before Advance moves on.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration // zero for a one-shot timer
+	ch     chan time.Time
+	fn     func() // run instead of sending on ch, for AfterFunc
+	// stopped is closed when a ticker is stopped.
+	stopped chan struct{}
+}
+
+// NewFakeClock returns a FakeClock reading start.
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the clock's time once it has been
+// advanced by d. A d of zero or less fires at once.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.addLocked(&fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// NewTimer returns a timer that receives the clock's time on C once the
+// clock has been advanced by d. A d of zero or less fires at once.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- c.now
+	} else {
+		c.addLocked(w)
+	}
+	return &fakeTimer{clock: c, w: w}
+}
+
+// AfterFunc returns a timer that calls f once the clock has been advanced
+// by d.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), fn: f}
+	c.addLocked(w)
+	return &fakeTimer{clock: c, w: w}
+}
+
+// Sleep blocks until the clock has been advanced by d.
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// NewTicker returns a ticker firing every d of clock time. It panics if d
+// is not positive, like time.NewTicker.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("utils: non-positive interval for FakeClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), period: d, ch: make(chan time.Time), stopped: make(chan struct{})}
+	c.addLocked(w)
+	return &fakeTicker{clock: c, w: w}
+}
+
+func (c *FakeClock) addLocked(w *fakeWaiter) {
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+}
+
+func (c *FakeClock) removeLocked(w *fakeWaiter) bool {
+	for i, other := range c.waiters {
+		if other == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker due
+// on the way. It waits for each tick to be received, without holding the
+// clock, so the receiver may read the clock meanwhile.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	target := c.now.Add(d)
+	for {
+		var next *fakeWaiter
+		for _, w := range c.waiters {
+			if !w.at.After(target) && (next == nil || w.at.Before(next.at)) {
+				next = w
+			}
+		}
+		if next == nil {
+			break
+		}
+		c.now = next.at
+		if next.fn != nil {
+			c.removeLocked(next)
+			c.mu.Unlock()
+			next.fn()
+			c.mu.Lock()
+			continue
+		}
+		if next.period == 0 {
+			c.removeLocked(next)
+			next.ch <- c.now
+			continue
+		}
+		next.at = next.at.Add(next.period)
+		now := c.now
+		c.mu.Unlock()
+		select {
+		case next.ch <- now:
+		case <-next.stopped:
+		}
+		c.mu.Lock()
+	}
+	c.now = target
+}
+
+// Waiters returns how many timers and tickers are pending.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil waits until at least n timers and tickers are pending, so a
+// test can advance the clock knowing the component is waiting on it.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+type fakeTicker struct {
+	clock *FakeClock
+	w     *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, w := range t.clock.waiters {
+		if w == t.w {
+			t.clock.waiters = append(t.clock.waiters[:i], t.clock.waiters[i+1:]...)
+			close(t.w.stopped)
+			return
+		}
+	}
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	w     *fakeWaiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.w.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.removeLocked(t.w)
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+)
+
+// PayloadKeysEnv holds payload-protection keys directly, as a comma-separated
+// list of "id:base64key" entries. It takes precedence over the key file.
+const PayloadKeysEnv = "LUMEN_PAYLOAD_KEYS"
+
+var (
+	// ErrUnknownKeyID is returned by Open when the ciphertext was sealed with
+	// a key that is not loaded (e.g. retired after rotation).
+	ErrUnknownKeyID = errors.New("payload protection: unknown key id")
+	// ErrDecrypt is returned by Open for malformed or tampered ciphertexts.
+	ErrDecrypt = errors.New("payload protection: decryption failed")
+)
+
+// PayloadCipher seals payload-derived data with AES-GCM before it is written
+// anywhere outside process memory.
+//
+// Every ciphertext is prefixed with the ID of the key that sealed it, so keys
+// can be rotated: Seal always uses the active key while Open accepts any
+// loaded key. Callers persisting cache entries should treat any Open error
+// as a cache miss rather than a hard failure.
+//
+// Ciphertext layout: len(id) (1 byte) | id | nonce | sealed data.
+type PayloadCipher struct {
+	active string
+	aeads  map[string]cipher.AEAD
+}
+
+// NewPayloadCipher builds a cipher from raw AES keys (16, 24 or 32 bytes)
+// keyed by ID. activeID selects the key used for new ciphertexts.
+func NewPayloadCipher(activeID string, keys map[string][]byte) (*PayloadCipher, error) {
+	if _, ok := keys[activeID]; !ok {
+		return nil, InvalidError(fmt.Sprintf("active key %q is not among the loaded keys", activeID))
+	}
+	c := &PayloadCipher{active: activeID, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, InvalidError(fmt.Sprintf("key id %q must be 1-255 bytes", id))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, Wrap(err, ErrCodeInvalid, fmt.Sprintf("key %q", id))
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, Wrap(err, ErrCodeInternal, fmt.Sprintf("key %q", id))
+		}
+		c.aeads[id] = aead
+	}
+	return c, nil
+}
+
+// ActiveKeyID returns the ID of the key used by Seal.
+func (c *PayloadCipher) ActiveKeyID() string {
+	return c.active
+}
+
+// Seal encrypts plaintext with the active key.
+func (c *PayloadCipher) Seal(plaintext []byte) ([]byte, error) {
+	aead := c.aeads[c.active]
+	header := 1 + len(c.active)
+	out := make([]byte, header+aead.NonceSize(), header+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out[0] = byte(len(c.active))
+	copy(out[1:], c.active)
+	nonce := out[header:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, Wrap(err, ErrCodeInternal, "generate nonce")
+	}
+	return aead.Seal(out, nonce, plaintext, []byte(c.active)), nil
+}
+
+// Open decrypts a ciphertext produced by Seal with any loaded key.
+func (c *PayloadCipher) Open(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 1 {
+		return nil, ErrDecrypt
+	}
+	idLen := int(ciphertext[0])
+	if len(ciphertext) < 1+idLen {
+		return nil, ErrDecrypt
+	}
+	id := string(ciphertext[1 : 1+idLen])
+	aead, ok := c.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, id)
+	}
+	rest := ciphertext[1+idLen:]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// ZeroBytes overwrites b with zeros. Use it on plaintext buffers once they
+// have been sealed or consumed.
+func ZeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// LoadPayloadCipher builds a PayloadCipher from configuration. It returns
+// (nil, nil) when payload protection is disabled.
+//
+// Keys come from the LUMEN_PAYLOAD_KEYS environment variable when set,
+// otherwise from cfg.KeyFile (one "id:base64key" entry per line; blank lines
+// and lines starting with # are ignored). The active key is cfg.ActiveKeyID,
+// or the first entry when unset.
+func LoadPayloadCipher(cfg config.PayloadProtectionConfig) (*PayloadCipher, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var entries []string
+	if env := os.Getenv(PayloadKeysEnv); env != "" {
+		entries = strings.Split(env, ",")
+	} else {
+		f, err := os.Open(cfg.KeyFile)
+		if err != nil {
+			return nil, Wrap(err, ErrCodeInvalid, "open payload key file")
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			entries = append(entries, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, Wrap(err, ErrCodeInvalid, "read payload key file")
+		}
+	}
+
+	keys := make(map[string][]byte)
+	active := strings.TrimSpace(cfg.ActiveKeyID)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, InvalidError("payload key entries must be id:base64key")
+		}
+		id = strings.TrimSpace(id)
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, Wrap(err, ErrCodeInvalid, fmt.Sprintf("decode payload key %q", id))
+		}
+		keys[id] = key
+		if active == "" {
+			active = id
+		}
+	}
+	if len(keys) == 0 {
+		return nil, InvalidError("payload protection is enabled but no keys were loaded")
+	}
+	return NewPayloadCipher(active, keys)
+}
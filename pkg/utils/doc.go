@@ -7,6 +7,8 @@
 //   - Retry mechanisms with exponential backoff
 //   - Circuit breaker for fault tolerance
 //   - Health monitoring utilities
+//   - A Clock abstraction with a FakeClock for deterministic tests
+//   - AES-GCM payload protection for data persisted outside memory
 //
 // # Structured Errors
 //
@@ -41,6 +43,13 @@
 //	    zap.String("operation", "inference"),
 //	    zap.Duration("duration", elapsed))
 //
+// Tie the log lines of one request together by carrying a logger in its
+// context. The client adds correlation_id, task and, once a node is picked,
+// node_id:
+//
+//	ctx = utils.WithRequestLogger(ctx, utils.Logger, zap.String("request_id", id))
+//	utils.LoggerFrom(ctx).Info("request received")
+//
 // # Retry Logic
 //
 // Add resilience with automatic retries:
@@ -74,6 +83,18 @@
 //	    }
 //	}
 //
+// # Clocks
+//
+// Time-driven components take a Clock rather than reading the wall clock:
+// RetryConfig.Clock, NewHealthMonitorWithClock, and SetClock on the push and
+// mDNS resolvers. A test passes a FakeClock and moves it by hand:
+//
+//	clock := utils.NewFakeClock(time.Now())
+//	hm := utils.NewHealthMonitorWithClock(30*time.Second, clock)
+//	go hm.Start(ctx)
+//	clock.BlockUntil(1)          // the monitor's ticker is armed
+//	clock.Advance(time.Minute)   // two rounds of checks, no waiting
+//
 // # Error Aggregation
 //
 // Collect multiple errors in batch operations:
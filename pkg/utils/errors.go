@@ -24,6 +24,7 @@ const (
 	ErrCodeNotFound     ErrorCode = "NOT_FOUND"
 	ErrCodeUnauthorized ErrorCode = "UNAUTHORIZED"
 	ErrCodeForbidden    ErrorCode = "FORBIDDEN"
+	ErrCodeCancelled    ErrorCode = "CANCELLED" // The caller cancelled the request
 
 	// Lumen特定错误码
 	ErrCodeNodeNotFound       ErrorCode = "NODE_NOT_FOUND"
@@ -191,6 +192,10 @@ func TimeoutError(message string, details ...interface{}) *LumenError {
 	return NewLumenError(ErrCodeTimeout, message, details...)
 }
 
+func CancelledError(message string, details ...interface{}) *LumenError {
+	return NewLumenError(ErrCodeCancelled, message, details...)
+}
+
 func UnavailableError(message string, details ...interface{}) *LumenError {
 	return NewLumenError(ErrCodeUnavailable, message, details...)
 }
@@ -230,16 +230,24 @@ type HealthMonitor struct {
 	results  map[string]*HealthCheckResult
 	mu       sync.RWMutex
 	interval time.Duration
+	clock    Clock
 	stopCh   chan struct{}
 	running  bool
 }
 
 // NewHealthMonitor 创建健康监控器
 func NewHealthMonitor(interval time.Duration) *HealthMonitor {
+	return NewHealthMonitorWithClock(interval, nil)
+}
+
+// NewHealthMonitorWithClock returns a monitor whose check interval runs on
+// clock rather than the wall clock; nil is RealClock.
+func NewHealthMonitorWithClock(interval time.Duration, clock Clock) *HealthMonitor {
 	return &HealthMonitor{
 		checkers: make(map[string]HealthChecker),
 		results:  make(map[string]*HealthCheckResult),
 		interval: interval,
+		clock:    ClockOrReal(clock),
 		stopCh:   make(chan struct{}),
 	}
 }
@@ -324,7 +332,10 @@ func (hm *HealthMonitor) Start(ctx context.Context) {
 	hm.running = true
 	hm.mu.Unlock()
 
-	ticker := time.NewTicker(hm.interval)
+	hm.mu.RLock()
+	stopCh := hm.stopCh
+	hm.mu.RUnlock()
+	ticker := hm.clock.NewTicker(hm.interval)
 	defer ticker.Stop()
 
 	for {
@@ -332,9 +343,9 @@ func (hm *HealthMonitor) Start(ctx context.Context) {
 		case <-ctx.Done():
 			hm.Stop()
 			return
-		case <-hm.stopCh:
+		case <-stopCh:
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			hm.CheckAll(ctx)
 		}
 	}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"os"
 	"strings"
 
@@ -112,6 +113,39 @@ func InitLogger(cfg *config.LoggingConfig) {
 	Sugar = Logger.Sugar()
 }
 
+type requestLoggerKey struct{}
+
+// WithRequestLogger returns a copy of ctx carrying logger with fields added,
+// for the log lines of one request. Components that log on behalf of the
+// request take it back with LoggerFrom, so every line carries the same
+// fields, such as the correlation ID. A nil logger extends the logger ctx
+// already carries, or Logger.
+//
+// Example:
+//
+//	ctx = utils.WithRequestLogger(ctx, logger, zap.String("request_id", id))
+//	resp, err := client.Infer(ctx, req) // lines also carry correlation_id and task
+func WithRequestLogger(ctx context.Context, logger *zap.Logger, fields ...zap.Field) context.Context {
+	if logger == nil {
+		logger = LoggerFrom(ctx)
+	}
+	return context.WithValue(ctx, requestLoggerKey{}, logger.With(fields...))
+}
+
+// LoggerFrom returns the request logger ctx carries, or Logger.
+func LoggerFrom(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(requestLoggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return Logger
+}
+
+// HasRequestLogger reports whether ctx carries a request logger.
+func HasRequestLogger(ctx context.Context) bool {
+	_, ok := ctx.Value(requestLoggerKey{}).(*zap.Logger)
+	return ok
+}
+
 // parseLogLevel 解析日志级别
 func parseLogLevel(level string) zapcore.Level {
 	switch strings.ToLower(level) {
@@ -0,0 +1,257 @@
+// Package netaddr parses the network addresses users write in configuration
+// and on the command line: a bare host, host:port, scheme://host:port, and
+// IPv6 literals with or without brackets.
+//
+// It imports only the standard library, so every package can use it,
+// pkg/config included.
+package netaddr
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidHost reports a host that is neither an IP literal nor a
+	// valid hostname.
+	ErrInvalidHost = errors.New("invalid host")
+	// ErrInvalidPort reports a port that is not a number in 1-65535.
+	ErrInvalidPort = errors.New("invalid port")
+	// ErrMissingPort reports an address without a port where no default
+	// applies.
+	ErrMissingPort = errors.New("missing port")
+	// ErrInvalidAddress reports an address that cannot be parsed at all.
+	ErrInvalidAddress = errors.New("invalid address")
+)
+
+// AddressError is the error the parse functions return; Err is one
+// of the package's sentinel errors, possibly wrapped with detail.
+type AddressError struct {
+	Address string
+	Err     error
+}
+
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("address %q: %v", e.Address, e.Err)
+}
+
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+// Address is a parsed address. Host holds an IP literal in its canonical
+// form, without brackets, or a lower-cased hostname.
+type Address struct {
+	// Scheme is the lower-cased scheme the address was written with, or
+	// empty.
+	Scheme string
+	Host   string
+	Port   int
+}
+
+// ParseAddress parses s in any of the forms
+//
+//	host                 hub.local, 10.0.0.5, ::1, fe80::1%eth0
+//	host:port            hub.local:5866, 10.0.0.5:50051
+//	[v6]:port            [::1]:5866
+//	scheme://host:port   http://hub.local:5866, https://[::1]
+//
+// An address without a port takes the well-known port of its scheme (80
+// for http, 443 for https), else defaultPort. With neither, and
+// defaultPort 0, the port is missing. A URL may end in "/" but carry no
+// other path, query or credentials.
+func ParseAddress(s string, defaultPort int) (Address, error) {
+	addr, err := parse(strings.TrimSpace(s), defaultPort)
+	if err != nil {
+		return Address{}, &AddressError{Address: s, Err: err}
+	}
+	return addr, nil
+}
+
+func parse(s string, defaultPort int) (Address, error) {
+	if s == "" {
+		return Address{}, fmt.Errorf("%w: empty", ErrInvalidAddress)
+	}
+	var addr Address
+	hostport := s
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return Address{}, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+		}
+		if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return Address{}, fmt.Errorf("%w: only scheme, host and port are allowed", ErrInvalidAddress)
+		}
+		addr.Scheme = strings.ToLower(u.Scheme)
+		hostport = u.Host
+		if port, ok := schemePorts[addr.Scheme]; ok {
+			defaultPort = port
+		}
+	}
+
+	host, port, err := splitHostPort(hostport)
+	if err != nil {
+		return Address{}, err
+	}
+	if addr.Host, err = normalizeHost(host); err != nil {
+		return Address{}, err
+	}
+	switch {
+	case port != "":
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return Address{}, fmt.Errorf("%w %q: must be a number in 1-65535", ErrInvalidPort, port)
+		}
+		addr.Port = n
+	case defaultPort > 0:
+		addr.Port = defaultPort
+	default:
+		return Address{}, ErrMissingPort
+	}
+	return addr, nil
+}
+
+// ParseHostPort is ParseAddress for an address that takes no scheme, such
+// as a gRPC endpoint: "host:port", "[v6]:port", or a bare host when
+// defaultPort is set.
+func ParseHostPort(s string, defaultPort int) (Address, error) {
+	if strings.Contains(s, "://") {
+		return Address{}, &AddressError{Address: s, Err: fmt.Errorf("%w: a host:port address takes no scheme", ErrInvalidAddress)}
+	}
+	return ParseAddress(s, defaultPort)
+}
+
+// ParseHost parses s as a host alone: a hostname, an IP literal, or an
+// IPv6 literal in brackets. It returns the host as Address.Host holds it,
+// and an error for an address with a scheme or port.
+func ParseHost(s string) (string, error) {
+	host, err := parseHost(strings.TrimSpace(s))
+	if err != nil {
+		return "", &AddressError{Address: s, Err: err}
+	}
+	return host, nil
+}
+
+func parseHost(s string) (string, error) {
+	if strings.Contains(s, "://") {
+		return "", fmt.Errorf("%w: a host takes no scheme", ErrInvalidAddress)
+	}
+	host, port, err := splitHostPort(s)
+	if err != nil {
+		return "", err
+	}
+	if port != "" {
+		return "", fmt.Errorf("%w %q: a host takes no port", ErrInvalidPort, port)
+	}
+	return normalizeHost(host)
+}
+
+// schemePorts are the ports of schemes written without one.
+var schemePorts = map[string]int{"http": 80, "https": 443}
+
+// splitHostPort splits s into a host and a possibly empty port, telling an
+// IPv6 literal's colons from a port's.
+func splitHostPort(s string) (host, port string, err error) {
+	switch {
+	case s == "":
+		return "", "", fmt.Errorf("%w: empty host", ErrInvalidHost)
+	case strings.HasPrefix(s, "["):
+		if strings.HasSuffix(s, "]") {
+			host = s[1 : len(s)-1]
+			break
+		}
+		if host, port, err = net.SplitHostPort(s); err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+		}
+		if port == "" {
+			return "", "", fmt.Errorf("%w \"\": empty after the colon", ErrInvalidPort)
+		}
+		if ip, perr := netip.ParseAddr(host); perr != nil || !ip.Is6() {
+			return "", "", fmt.Errorf("%w %q: brackets hold IPv6 literals only", ErrInvalidHost, host)
+		}
+	case strings.Count(s, ":") > 1:
+		// More than one colon without brackets: an IPv6 literal, no port.
+		host = s
+	case strings.Contains(s, ":"):
+		host, port, _ = strings.Cut(s, ":")
+		if port == "" {
+			return "", "", fmt.Errorf("%w \"\": empty after the colon", ErrInvalidPort)
+		}
+	default:
+		host = s
+	}
+	return host, port, nil
+}
+
+// normalizeHost returns host's canonical form, rejecting anything that is
+// not an IP literal or an RFC 1123 hostname (underscores allowed, as mDNS
+// names carry them).
+func normalizeHost(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("%w: empty host", ErrInvalidHost)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return ip.String(), nil
+	}
+	if strings.Contains(host, ":") {
+		return "", fmt.Errorf("%w %q: not an IPv6 literal", ErrInvalidHost, host)
+	}
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if name == "" || len(name) > 253 {
+		return "", fmt.Errorf("%w %q", ErrInvalidHost, host)
+	}
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if !validLabel(label) {
+			return "", fmt.Errorf("%w %q", ErrInvalidHost, host)
+		}
+	}
+	// No top-level domain is all digits: this is a malformed IPv4 literal
+	// such as 10.0.0.256.
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", fmt.Errorf("%w %q: not an IPv4 literal", ErrInvalidHost, host)
+	}
+	return name, nil
+}
+
+func validLabel(label string) bool {
+	if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, r := range label {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// HostPort returns the address as host:port, bracketing an IPv6 host, for
+// dialing or binding.
+func (a Address) HostPort() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// URL returns the address as a URL with its scheme, or defaultScheme when
+// it has none.
+func (a Address) URL(defaultScheme string) string {
+	scheme := a.Scheme
+	if scheme == "" {
+		scheme = defaultScheme
+	}
+	return scheme + "://" + a.HostPort()
+}
+
+// String returns the address as written back: a URL when it has a scheme,
+// host:port otherwise.
+func (a Address) String() string {
+	if a.Scheme != "" {
+		return a.URL("")
+	}
+	return a.HostPort()
+}
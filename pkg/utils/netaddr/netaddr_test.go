@@ -0,0 +1,148 @@
+package netaddr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		in          string
+		defaultPort int
+		want        Address
+		wantErr     error
+	}{
+		// Bare hosts take the default port.
+		{"hub.local", 5866, Address{Host: "hub.local", Port: 5866}, nil},
+		{"Hub.Local.", 5866, Address{Host: "hub.local", Port: 5866}, nil},
+		{"10.0.0.5", 50051, Address{Host: "10.0.0.5", Port: 50051}, nil},
+		{"::1", 5866, Address{Host: "::1", Port: 5866}, nil},
+		{"2001:DB8::1", 5866, Address{Host: "2001:db8::1", Port: 5866}, nil},
+		{"fe80::1%eth0", 5866, Address{Host: "fe80::1%eth0", Port: 5866}, nil},
+		{"[::1]", 5866, Address{Host: "::1", Port: 5866}, nil},
+		{"_lumen-node.local", 1, Address{Host: "_lumen-node.local", Port: 1}, nil},
+		{"  hub.local  ", 5866, Address{Host: "hub.local", Port: 5866}, nil},
+
+		// An explicit port wins over the default.
+		{"hub.local:9000", 5866, Address{Host: "hub.local", Port: 9000}, nil},
+		{"10.0.0.5:50051", 0, Address{Host: "10.0.0.5", Port: 50051}, nil},
+		{"[::1]:5866", 0, Address{Host: "::1", Port: 5866}, nil},
+		{"[fe80::1%eth0]:5866", 0, Address{Host: "fe80::1%eth0", Port: 5866}, nil},
+		{"localhost:65535", 0, Address{Host: "localhost", Port: 65535}, nil},
+
+		// URLs keep their scheme; without a port they take the scheme's.
+		{"http://hub.local:5866", 0, Address{Scheme: "http", Host: "hub.local", Port: 5866}, nil},
+		{"HTTP://hub.local:5866/", 0, Address{Scheme: "http", Host: "hub.local", Port: 5866}, nil},
+		{"http://hub.local", 5866, Address{Scheme: "http", Host: "hub.local", Port: 80}, nil},
+		{"https://[::1]", 0, Address{Scheme: "https", Host: "::1", Port: 443}, nil},
+		{"https://[::1]:8443", 0, Address{Scheme: "https", Host: "::1", Port: 8443}, nil},
+		{"grpc://node-1:50051", 0, Address{Scheme: "grpc", Host: "node-1", Port: 50051}, nil},
+		{"grpc://node-1", 50051, Address{Scheme: "grpc", Host: "node-1", Port: 50051}, nil},
+
+		// Ports.
+		{"hub.local", 0, Address{}, ErrMissingPort},
+		{"::1", 0, Address{}, ErrMissingPort},
+		{"grpc://node-1", 0, Address{}, ErrMissingPort},
+		{"hub.local:", 5866, Address{}, ErrInvalidPort},
+		{"hub.local:0", 0, Address{}, ErrInvalidPort},
+		{"hub.local:65536", 0, Address{}, ErrInvalidPort},
+		{"hub.local:-1", 0, Address{}, ErrInvalidPort},
+		{"hub.local:http", 0, Address{}, ErrInvalidPort},
+		{"[::1]:", 0, Address{}, ErrInvalidPort},
+		{"http://hub.local:99999", 0, Address{}, ErrInvalidPort},
+
+		// Hosts.
+		{"hub local", 5866, Address{}, ErrInvalidHost},
+		{"-hub.local", 5866, Address{}, ErrInvalidHost},
+		{"hub..local", 5866, Address{}, ErrInvalidHost},
+		{"10.0.0.256", 5866, Address{}, ErrInvalidHost},
+		{"[10.0.0.5]:5866", 0, Address{}, ErrInvalidHost},
+		{"[hub.local]:5866", 0, Address{}, ErrInvalidHost},
+		{"2001:db8::g", 5866, Address{}, ErrInvalidHost},
+		{":5866", 0, Address{}, ErrInvalidHost},
+		{"http://:5866", 0, Address{}, ErrInvalidHost},
+
+		// Anything else.
+		{"", 5866, Address{}, ErrInvalidAddress},
+		{"   ", 5866, Address{}, ErrInvalidAddress},
+		{"[::1", 5866, Address{}, ErrInvalidAddress},
+		{"http://hub.local:5866/v1", 0, Address{}, ErrInvalidAddress},
+		{"http://user:pw@hub.local", 0, Address{}, ErrInvalidAddress},
+		{"http://hub.local?x=1", 0, Address{}, ErrInvalidAddress},
+	}
+	for _, tt := range tests {
+		got, err := ParseAddress(tt.in, tt.defaultPort)
+		if tt.wantErr != nil {
+			var addrErr *AddressError
+			if !errors.Is(err, tt.wantErr) || !errors.As(err, &addrErr) || addrErr.Address != tt.in {
+				t.Errorf("ParseAddress(%q, %d) error = %v, want an AddressError wrapping %v", tt.in, tt.defaultPort, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAddress(%q, %d) = %+v, %v; want %+v", tt.in, tt.defaultPort, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseHostPort(t *testing.T) {
+	if got, err := ParseHostPort("[::1]:50051", 0); err != nil || got.HostPort() != "[::1]:50051" {
+		t.Errorf("ParseHostPort([::1]:50051) = %v, %v", got, err)
+	}
+	if _, err := ParseHostPort("http://node-1:50051", 0); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("ParseHostPort with a scheme: error = %v, want ErrInvalidAddress", err)
+	}
+}
+
+func TestParseHost(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr error
+	}{
+		{"0.0.0.0", "0.0.0.0", nil},
+		{"::", "::", nil},
+		{"[::]", "::", nil},
+		{"Hub.Local", "hub.local", nil},
+		{"hub.local:5866", "", ErrInvalidPort},
+		{"[::1]:5866", "", ErrInvalidPort},
+		{"http://hub.local", "", ErrInvalidAddress},
+		{"", "", ErrInvalidHost},
+		{"hub_local!", "", ErrInvalidHost},
+	}
+	for _, tt := range tests {
+		got, err := ParseHost(tt.in)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseHost(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseHost(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestAddressFormatting(t *testing.T) {
+	tests := []struct {
+		addr          Address
+		hostPort, url string
+		str           string
+	}{
+		{Address{Host: "hub.local", Port: 5866}, "hub.local:5866", "http://hub.local:5866", "hub.local:5866"},
+		{Address{Host: "::1", Port: 5866}, "[::1]:5866", "http://[::1]:5866", "[::1]:5866"},
+		{Address{Scheme: "https", Host: "10.0.0.5", Port: 443}, "10.0.0.5:443", "https://10.0.0.5:443", "https://10.0.0.5:443"},
+	}
+	for _, tt := range tests {
+		if got := tt.addr.HostPort(); got != tt.hostPort {
+			t.Errorf("%+v.HostPort() = %q, want %q", tt.addr, got, tt.hostPort)
+		}
+		if got := tt.addr.URL("http"); got != tt.url {
+			t.Errorf("%+v.URL() = %q, want %q", tt.addr, got, tt.url)
+		}
+		if got := tt.addr.String(); got != tt.str {
+			t.Errorf("%+v.String() = %q, want %q", tt.addr, got, tt.str)
+		}
+	}
+}
@@ -32,6 +32,9 @@ type RetryConfig struct {
 	Backoff     time.Duration `json:"backoff"`
 	MaxBackoff  time.Duration `json:"max_backoff"`
 	Multiplier  float64       `json:"multiplier"`
+	// Clock times the waits between attempts and the MaxBackoff limit;
+	// nil is the wall clock.
+	Clock Clock `json:"-"`
 }
 
 // DefaultRetryConfig 默认重试配置
@@ -207,9 +210,7 @@ func Retry(ctx context.Context, config *RetryConfig, fn RetryFunc) error {
 	}
 
 	// 创建重试策略
-	policy := retry.NewExponential(config.Backoff)
-	policy = retry.WithMaxRetries(uint64(config.MaxAttempts-1), policy)
-	policy = retry.WithMaxDuration(config.MaxBackoff, policy)
+	policy := retryPolicy(ctx, config)
 
 	var lastErr error
 	err := retry.Do(ctx, policy, func(ctx context.Context) error {
@@ -235,6 +236,39 @@ func Retry(ctx context.Context, config *RetryConfig, fn RetryFunc) error {
 	return nil
 }
 
+// retryPolicy returns the go-retry backoff config describes. With a Clock
+// set, MaxBackoff is measured on it and each wait is taken on it, leaving
+// go-retry nothing to wait for itself.
+func retryPolicy(ctx context.Context, config *RetryConfig) retry.Backoff {
+	policy := retry.NewExponential(config.Backoff)
+	policy = retry.WithMaxRetries(uint64(config.MaxAttempts-1), policy)
+	if config.Clock == nil {
+		return retry.WithMaxDuration(config.MaxBackoff, policy)
+	}
+
+	// As retry.WithMaxDuration, on config.Clock.
+	clock := config.Clock
+	start := clock.Now()
+	return retry.BackoffFunc(func() (time.Duration, bool) {
+		left := config.MaxBackoff - clock.Now().Sub(start)
+		if left <= 0 {
+			return 0, true
+		}
+		wait, stop := policy.Next()
+		if stop {
+			return 0, true
+		}
+		if wait <= 0 || wait > left {
+			wait = left
+		}
+		select {
+		case <-ctx.Done():
+		case <-clock.After(wait):
+		}
+		return 0, false
+	})
+}
+
 // RetryWithCallback 带回调的重试执行
 type RetryCallback func(attempt int, err error)
 
@@ -244,9 +278,7 @@ func RetryWithCallback(ctx context.Context, config *RetryConfig, fn RetryFunc, c
 	}
 
 	attempt := 0
-	policy := retry.NewExponential(config.Backoff)
-	policy = retry.WithMaxRetries(uint64(config.MaxAttempts-1), policy)
-	policy = retry.WithMaxDuration(config.MaxBackoff, policy)
+	policy := retryPolicy(ctx, config)
 
 	var lastErr error
 	err := retry.Do(ctx, policy, func(ctx context.Context) error {
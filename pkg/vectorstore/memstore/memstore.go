@@ -0,0 +1,124 @@
+// Package memstore is an in-memory vectorstore.VectorStore, for tests and
+// apps small enough to scan every vector on each query.
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"github.com/edwinzhancn/lumen-sdk/pkg/vectorstore"
+)
+
+// Store keeps records in memory and ranks them by cosine similarity. It is
+// safe for concurrent use.
+type Store struct {
+	mu      sync.RWMutex
+	dim     int
+	records map[string]vectorstore.Record
+}
+
+var _ vectorstore.VectorStore = (*Store)(nil)
+
+// New returns an empty Store for vectors of dim dimensions. Zero takes the
+// dimension of the first record upserted.
+func New(dim int) *Store {
+	return &Store{dim: dim, records: make(map[string]vectorstore.Record)}
+}
+
+// Upsert stores copies of records, replacing any with the same ID. A
+// record with no ID, or whose vector does not have the store's dimension,
+// fails the whole call with an INVALID error and nothing is stored.
+func (s *Store) Upsert(_ context.Context, records []vectorstore.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dim := s.dim
+	if dim == 0 && len(records) > 0 {
+		dim = len(records[0].Vector)
+	}
+	if err := vectorstore.CheckDimension(records, dim); err != nil {
+		return err
+	}
+	for i, r := range records {
+		if r.ID == "" {
+			return utils.InvalidError(fmt.Sprintf("record %d has no ID", i))
+		}
+	}
+	s.dim = dim
+	for _, r := range records {
+		s.records[r.ID] = vectorstore.Record{
+			ID:     r.ID,
+			Vector: slices.Clone(r.Vector),
+			Meta:   maps.Clone(r.Meta),
+		}
+	}
+	return nil
+}
+
+// Query returns up to k records by descending cosine similarity to
+// vector, ties broken by ID. A vector of the wrong dimension is an INVALID
+// error.
+func (s *Store) Query(_ context.Context, vector []float32, k int) ([]vectorstore.Match, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if k <= 0 || len(s.records) == 0 {
+		return nil, nil
+	}
+	if len(vector) != s.dim {
+		return nil, utils.InvalidError(fmt.Sprintf("query vector has %d dimensions, want %d", len(vector), s.dim))
+	}
+	matches := make([]vectorstore.Match, 0, len(s.records))
+	for _, r := range s.records {
+		matches = append(matches, vectorstore.Match{Record: r, Score: cosine(vector, r.Vector)})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	matches = matches[:min(k, len(matches))]
+	for i := range matches {
+		matches[i].Vector = slices.Clone(matches[i].Vector)
+		matches[i].Meta = maps.Clone(matches[i].Meta)
+	}
+	return matches, nil
+}
+
+// Get returns a copy of the record stored under id.
+func (s *Store) Get(id string) (vectorstore.Record, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.records[id]
+	if !ok {
+		return vectorstore.Record{}, false
+	}
+	return vectorstore.Record{ID: r.ID, Vector: slices.Clone(r.Vector), Meta: maps.Clone(r.Meta)}, true
+}
+
+// Len returns the number of records stored.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// cosine is the cosine similarity of a and b, which have the same length;
+// zero if either is a zero vector.
+func cosine(a, b []float32) float32 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(na*nb))
+}
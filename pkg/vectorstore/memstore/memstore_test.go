@@ -0,0 +1,63 @@
+package memstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"github.com/edwinzhancn/lumen-sdk/pkg/vectorstore"
+)
+
+func TestQueryRanksByCosine(t *testing.T) {
+	s := New(0)
+	ctx := context.Background()
+	err := s.Upsert(ctx, []vectorstore.Record{
+		{ID: "east", Vector: []float32{1, 0}},
+		{ID: "north", Vector: []float32{0, 1}},
+		{ID: "northeast", Vector: []float32{2, 2}},
+		{ID: "also-east", Vector: []float32{5, 0}},
+	})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+
+	matches, err := s.Query(ctx, []float32{1, 0.1}, 3)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	want := []string{"also-east", "east", "northeast"}
+	if len(matches) != len(want) {
+		t.Fatalf("got %d matches, want %d", len(matches), len(want))
+	}
+	for i, id := range want {
+		if matches[i].ID != id {
+			t.Errorf("match %d = %s (%.3f), want %s", i, matches[i].ID, matches[i].Score, id)
+		}
+	}
+
+	matches[0].Vector[0] = 42
+	if r, _ := s.Get("also-east"); r.Vector[0] != 5 {
+		t.Error("a returned match aliases the stored vector")
+	}
+}
+
+func TestUpsertChecksDimension(t *testing.T) {
+	s := New(2)
+	ctx := context.Background()
+	err := s.Upsert(ctx, []vectorstore.Record{
+		{ID: "ok", Vector: []float32{1, 0}},
+		{ID: "wide", Vector: []float32{1, 0, 0}},
+	})
+	if !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+		t.Fatalf("Upsert = %v, want INVALID", err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("a rejected upsert stored %d records", s.Len())
+	}
+	if err := s.Upsert(ctx, []vectorstore.Record{{ID: "ok", Vector: []float32{1, 0}}}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if _, err := s.Query(ctx, []float32{1}, 1); !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+		t.Errorf("Query with the wrong dimension = %v, want INVALID", err)
+	}
+}
@@ -0,0 +1,278 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// DefaultPipelineBatchSize is how many items EmbedAndStore sends per
+// InferBatch call, and so per Upsert, unless PipelineOptions.BatchSize says
+// otherwise.
+const DefaultPipelineBatchSize = 32
+
+// Batcher runs inference requests as a batch. *client.LumenClient is one.
+type Batcher interface {
+	InferBatch(ctx context.Context, reqs []*pb.InferRequest, opts client.BatchOptions) ([]client.BatchItem, error)
+}
+
+// Item is one input of EmbedAndStore.
+type Item struct {
+	// ID is the ID the item's records are stored under; empty uses the
+	// request's correlation ID.
+	ID      string
+	Request *pb.InferRequest
+	// Meta is stored with each of the item's records.
+	Meta map[string]string
+}
+
+// Extractor turns a response into the records it yields. A record's ID is
+// appended to its item's ID after a "/"; an empty ID stands for the item's
+// ID itself.
+type Extractor func(resp *pb.InferResponse) ([]Record, error)
+
+// PipelineOptions tune EmbedAndStore. The zero value works.
+type PipelineOptions struct {
+	// BatchSize is how many items go to each InferBatch call and their
+	// records to each Upsert; zero means DefaultPipelineBatchSize.
+	BatchSize int
+	// Batch is passed to InferBatch.
+	Batch client.BatchOptions
+	// Dimension is the number of dimensions every vector must have. Zero
+	// takes that of the first vector.
+	Dimension int
+	// SkipNormalize stores vectors as the node returned them rather than
+	// scaled to unit length.
+	SkipNormalize bool
+	// Extract turns responses into records; nil means ExtractVectors.
+	Extract Extractor
+	// Retry governs retrying an Upsert that failed transiently; nil means
+	// utils.DefaultRetryConfig.
+	Retry *utils.RetryConfig
+	// OnProgress is called after each batch is stored.
+	OnProgress func(PipelineProgress)
+}
+
+// PipelineProgress is how far EmbedAndStore has got.
+type PipelineProgress struct {
+	// Total is the number of items; Done have been embedded and stored, or
+	// have failed.
+	Total int `json:"total"`
+	Done  int `json:"done"`
+	// Stored is the number of records upserted so far, Failed the number
+	// of items that failed.
+	Stored int `json:"stored"`
+	Failed int `json:"failed"`
+}
+
+// ItemError is an item EmbedAndStore could not embed or turn into records.
+type ItemError struct {
+	// Index is the item's position in the input.
+	Index int
+	ID    string
+	Err   error
+}
+
+func (e ItemError) Error() string {
+	return fmt.Sprintf("item %d (%s): %v", e.Index, e.ID, e.Err)
+}
+
+func (e ItemError) Unwrap() error { return e.Err }
+
+// PipelineResult is the outcome of EmbedAndStore.
+type PipelineResult struct {
+	// Stored is the number of records upserted.
+	Stored int
+	// Failed lists the items that failed, in input order. They do not fail
+	// the others.
+	Failed []ItemError
+}
+
+// EmbedAndStore embeds items through b.InferBatch, opts.BatchSize at a time,
+// and upserts the vectors of each batch into store. Each vector is checked
+// against the dimension and normalized unless opts.SkipNormalize is set. An
+// item that fails to embed, or whose vectors do not fit, is reported in
+// PipelineResult.Failed without stopping the rest. An Upsert that still
+// fails after opts.Retry stops the pipeline with its error, as does a
+// canceled ctx; the result then counts what was stored before. Items with
+// neither an ID nor a correlation ID, or without a request, are rejected
+// with an INVALID error before anything is sent.
+func EmbedAndStore(ctx context.Context, b Batcher, items []Item, store VectorStore, opts PipelineOptions) (PipelineResult, error) {
+	var result PipelineResult
+	ids := make([]string, len(items))
+	for i, item := range items {
+		if item.Request == nil {
+			return result, utils.InvalidError(fmt.Sprintf("item %d has no request", i))
+		}
+		ids[i] = item.ID
+		if ids[i] == "" {
+			ids[i] = item.Request.GetCorrelationId()
+		}
+		if ids[i] == "" {
+			return result, utils.InvalidError(fmt.Sprintf("item %d has neither an ID nor a correlation ID", i))
+		}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultPipelineBatchSize
+	}
+	if opts.Extract == nil {
+		opts.Extract = ExtractVectors
+	}
+	if opts.Retry == nil {
+		opts.Retry = utils.DefaultRetryConfig()
+	}
+
+	dim := opts.Dimension
+	progress := PipelineProgress{Total: len(items)}
+	for start := 0; start < len(items); start += opts.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		batch := items[start:min(start+opts.BatchSize, len(items))]
+		reqs := make([]*pb.InferRequest, len(batch))
+		for i, item := range batch {
+			reqs[i] = item.Request
+		}
+		outcomes, err := b.InferBatch(ctx, reqs, opts.Batch)
+		if err != nil && !errors.Is(err, client.ErrBatchFailed) {
+			return result, fmt.Errorf("embed items %d-%d: %w", start, start+len(batch)-1, err)
+		}
+
+		var records []Record
+		for i, outcome := range outcomes {
+			idx := start + i
+			recs, err := itemRecords(outcome, batch[i], ids[idx], opts, &dim)
+			if err != nil {
+				result.Failed = append(result.Failed, ItemError{Index: idx, ID: ids[idx], Err: err})
+				continue
+			}
+			records = append(records, recs...)
+		}
+		if len(records) > 0 {
+			err := utils.Retry(ctx, opts.Retry, func(ctx context.Context) error {
+				return store.Upsert(ctx, records)
+			})
+			if err != nil {
+				return result, fmt.Errorf("upsert %d records: %w", len(records), err)
+			}
+			result.Stored += len(records)
+		}
+
+		progress.Done += len(batch)
+		progress.Stored = result.Stored
+		progress.Failed = len(result.Failed)
+		if opts.OnProgress != nil {
+			opts.OnProgress(progress)
+		}
+	}
+	return result, nil
+}
+
+// itemRecords turns one batch outcome into records under id, checking them
+// against *dim, which the first vector fixes when it is zero.
+func itemRecords(outcome client.BatchItem, item Item, id string, opts PipelineOptions, dim *int) ([]Record, error) {
+	if outcome.Err != nil {
+		return nil, outcome.Err
+	}
+	recs, err := opts.Extract(outcome.Response)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, nil
+	}
+	for i := range recs {
+		if recs[i].ID == "" {
+			recs[i].ID = id
+		} else {
+			recs[i].ID = id + "/" + recs[i].ID
+		}
+		recs[i].Meta = mergeMeta(item.Meta, recs[i].Meta)
+	}
+	if *dim == 0 {
+		*dim = len(recs[0].Vector)
+	}
+	if err := CheckDimension(recs, *dim); err != nil {
+		return nil, err
+	}
+	if !opts.SkipNormalize {
+		for i := range recs {
+			recs[i].Vector = Normalize(recs[i].Vector)
+		}
+	}
+	return recs, nil
+}
+
+func mergeMeta(item, extracted map[string]string) map[string]string {
+	if len(item) == 0 && len(extracted) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(item)+len(extracted))
+	for k, v := range item {
+		out[k] = v
+	}
+	for k, v := range extracted {
+		out[k] = v
+	}
+	return out
+}
+
+const (
+	embeddingMime = "application/json;schema=embedding_v1"
+	faceMime      = "application/json;schema=face_v1"
+)
+
+// ExtractVectors is the default Extractor. An embedding_v1 response yields
+// one record under the item's ID. A face_v1 response yields one record per
+// face that carries an embedding, with ID "face-<n>" and the face's
+// "face_index", "confidence" and "bbox" ("x1,y1,x2,y2") as metadata; a
+// photo without faces yields none. Any other response is an error.
+func ExtractVectors(resp *pb.InferResponse) ([]Record, error) {
+	parser := types.ParseInferResponse(resp)
+	switch resp.GetResultMime() {
+	case embeddingMime:
+		vec, err := parser.AsEmbeddingVector()
+		if err != nil {
+			return nil, err
+		}
+		return []Record{{Vector: vec}}, nil
+	case faceMime:
+	default:
+		return nil, utils.InvalidError(fmt.Sprintf("no vectors in a %q response", resp.GetResultMime()))
+	}
+	faces, err := parser.AsFaceResponse()
+	if err != nil {
+		return nil, err
+	}
+	var recs []Record
+	for i, face := range faces.Faces {
+		if len(face.Embedding) == 0 {
+			continue
+		}
+		recs = append(recs, Record{
+			ID:     "face-" + strconv.Itoa(i),
+			Vector: face.Embedding,
+			Meta: map[string]string{
+				"face_index": strconv.Itoa(i),
+				"confidence": strconv.FormatFloat(float64(face.Confidence), 'f', -1, 32),
+				"bbox":       joinFloats(face.BBox),
+			},
+		})
+	}
+	return recs, nil
+}
+
+func joinFloats(vs []float32) string {
+	parts := make([]string, len(vs))
+	for i, v := range vs {
+		parts[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return strings.Join(parts, ",")
+}
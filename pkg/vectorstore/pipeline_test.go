@@ -0,0 +1,212 @@
+package vectorstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"github.com/edwinzhancn/lumen-sdk/pkg/vectorstore"
+	"github.com/edwinzhancn/lumen-sdk/pkg/vectorstore/memstore"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// fakeBatcher answers each request with the outcome registered under its
+// correlation ID, and counts InferBatch calls.
+type fakeBatcher struct {
+	outcomes map[string]client.BatchItem
+	calls    atomic.Int32
+}
+
+func (b *fakeBatcher) InferBatch(_ context.Context, reqs []*pb.InferRequest, _ client.BatchOptions) ([]client.BatchItem, error) {
+	b.calls.Add(1)
+	items := make([]client.BatchItem, len(reqs))
+	failed := 0
+	for i, req := range reqs {
+		items[i] = b.outcomes[req.CorrelationId]
+		if items[i].Err != nil {
+			failed++
+		}
+	}
+	if failed == len(reqs) {
+		return items, client.ErrBatchFailed
+	}
+	return items, nil
+}
+
+func embeddingItem(t *testing.T, vec ...float32) client.BatchItem {
+	t.Helper()
+	body, err := json.Marshal(types.EmbeddingV1{Vector: vec, Dim: len(vec)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.BatchItem{Response: &pb.InferResponse{ResultMime: "application/json;schema=embedding_v1", Result: body}}
+}
+
+func faceItem(t *testing.T, faces ...types.Face) client.BatchItem {
+	t.Helper()
+	body, err := json.Marshal(types.FaceV1{Faces: faces, Count: len(faces)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.BatchItem{Response: &pb.InferResponse{ResultMime: "application/json;schema=face_v1", Result: body}}
+}
+
+func request(id string) *pb.InferRequest {
+	return &pb.InferRequest{CorrelationId: id, Task: types.TaskSemanticTextEmbed}
+}
+
+func TestEmbedAndStore(t *testing.T) {
+	b := &fakeBatcher{outcomes: map[string]client.BatchItem{
+		"a":      embeddingItem(t, 3, 4),
+		"b":      embeddingItem(t, 0, 2),
+		"broken": {Err: utils.InvalidError("rejected")},
+		"wide":   embeddingItem(t, 1, 2, 3),
+		"c":      embeddingItem(t, 1, 0),
+	}}
+	items := []vectorstore.Item{
+		{Request: request("a"), Meta: map[string]string{"path": "a.jpg"}},
+		{ID: "photo-b", Request: request("b")},
+		{Request: request("broken")},
+		{Request: request("wide")},
+		{Request: request("c")},
+	}
+	store := memstore.New(0)
+	var progress []vectorstore.PipelineProgress
+
+	res, err := vectorstore.EmbedAndStore(context.Background(), b, items, store, vectorstore.PipelineOptions{
+		BatchSize:  2,
+		OnProgress: func(p vectorstore.PipelineProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("EmbedAndStore: %v", err)
+	}
+	if res.Stored != 3 || store.Len() != 3 {
+		t.Fatalf("stored %d records, store has %d; want 3", res.Stored, store.Len())
+	}
+	if len(res.Failed) != 2 || res.Failed[0].ID != "broken" || res.Failed[1].ID != "wide" {
+		t.Fatalf("Failed = %v, want broken and wide", res.Failed)
+	}
+	if !utils.HasErrorCode(res.Failed[1].Err, utils.ErrCodeInvalid) {
+		t.Errorf("dimension mismatch error = %v, want INVALID", res.Failed[1].Err)
+	}
+	if b.calls.Load() != 3 {
+		t.Errorf("InferBatch called %d times, want 3", b.calls.Load())
+	}
+
+	a, ok := store.Get("a")
+	if !ok || a.Meta["path"] != "a.jpg" {
+		t.Fatalf("record a = %+v, %v", a, ok)
+	}
+	if math.Abs(float64(a.Vector[0])-0.6) > 1e-6 || math.Abs(float64(a.Vector[1])-0.8) > 1e-6 {
+		t.Errorf("record a vector = %v, want normalized [0.6 0.8]", a.Vector)
+	}
+	if _, ok := store.Get("photo-b"); !ok {
+		t.Error("record stored under the correlation ID instead of the item ID")
+	}
+
+	want := []vectorstore.PipelineProgress{
+		{Total: 5, Done: 2, Stored: 2},
+		{Total: 5, Done: 4, Stored: 2, Failed: 2},
+		{Total: 5, Done: 5, Stored: 3, Failed: 2},
+	}
+	if len(progress) != len(want) {
+		t.Fatalf("progress = %+v, want %+v", progress, want)
+	}
+	for i := range want {
+		if progress[i] != want[i] {
+			t.Errorf("progress[%d] = %+v, want %+v", i, progress[i], want[i])
+		}
+	}
+}
+
+func TestEmbedAndStoreFaces(t *testing.T) {
+	b := &fakeBatcher{outcomes: map[string]client.BatchItem{
+		"group": faceItem(t,
+			types.Face{BBox: []float32{1, 2, 3, 4}, Confidence: 0.9, Embedding: []float32{1, 0}},
+			types.Face{BBox: []float32{5, 6, 7, 8}, Confidence: 0.5},
+			types.Face{BBox: []float32{9, 9, 9, 9}, Confidence: 0.8, Embedding: []float32{0, 1}},
+		),
+		"empty": faceItem(t),
+	}}
+	store := memstore.New(2)
+	items := []vectorstore.Item{{Request: request("group")}, {Request: request("empty")}}
+
+	res, err := vectorstore.EmbedAndStore(context.Background(), b, items, store, vectorstore.PipelineOptions{})
+	if err != nil {
+		t.Fatalf("EmbedAndStore: %v", err)
+	}
+	if res.Stored != 2 || len(res.Failed) != 0 {
+		t.Fatalf("result = %+v, want 2 stored and no failures", res)
+	}
+	r, ok := store.Get("group/face-2")
+	if !ok {
+		t.Fatal("no record for the third face")
+	}
+	if r.Meta["face_index"] != "2" || r.Meta["bbox"] != "9,9,9,9" || r.Meta["confidence"] != "0.8" {
+		t.Errorf("face meta = %v", r.Meta)
+	}
+}
+
+// flakyStore fails its first Upsert transiently.
+type flakyStore struct {
+	*memstore.Store
+	calls atomic.Int32
+}
+
+func (s *flakyStore) Upsert(ctx context.Context, records []vectorstore.Record) error {
+	if s.calls.Add(1) == 1 {
+		return utils.UnavailableError("store restarting")
+	}
+	return s.Store.Upsert(ctx, records)
+}
+
+func TestEmbedAndStoreRetriesUpsert(t *testing.T) {
+	b := &fakeBatcher{outcomes: map[string]client.BatchItem{"a": embeddingItem(t, 1, 1)}}
+	store := &flakyStore{Store: memstore.New(0)}
+	retry := &utils.RetryConfig{Enabled: true, MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1}
+
+	res, err := vectorstore.EmbedAndStore(context.Background(), b, []vectorstore.Item{{Request: request("a")}}, store, vectorstore.PipelineOptions{Retry: retry})
+	if err != nil {
+		t.Fatalf("EmbedAndStore: %v", err)
+	}
+	if res.Stored != 1 || store.calls.Load() != 2 {
+		t.Fatalf("stored %d after %d upserts, want 1 after 2", res.Stored, store.calls.Load())
+	}
+}
+
+func TestEmbedAndStoreRejectsUnidentifiedItems(t *testing.T) {
+	b := &fakeBatcher{}
+	for name, item := range map[string]vectorstore.Item{
+		"no request": {ID: "a"},
+		"no id":      {Request: &pb.InferRequest{}},
+	} {
+		_, err := vectorstore.EmbedAndStore(context.Background(), b, []vectorstore.Item{item}, memstore.New(0), vectorstore.PipelineOptions{})
+		if !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+			t.Errorf("%s: err = %v, want INVALID", name, err)
+		}
+	}
+	if b.calls.Load() != 0 {
+		t.Error("InferBatch called for invalid input")
+	}
+}
+
+func TestEmbedAndStoreStopsOnUpsertFailure(t *testing.T) {
+	b := &fakeBatcher{outcomes: map[string]client.BatchItem{"a": embeddingItem(t, 1, 1), "b": embeddingItem(t, 1, 2)}}
+	store := memstore.New(3)
+	items := []vectorstore.Item{{Request: request("a")}, {Request: request("b")}}
+
+	_, err := vectorstore.EmbedAndStore(context.Background(), b, items, store, vectorstore.PipelineOptions{BatchSize: 1})
+	if !utils.HasErrorCode(errors.Unwrap(err), utils.ErrCodeInvalid) {
+		t.Fatalf("err = %v, want the store's INVALID error", err)
+	}
+	if b.calls.Load() != 1 {
+		t.Errorf("InferBatch called %d times after the failed upsert, want 1", b.calls.Load())
+	}
+}
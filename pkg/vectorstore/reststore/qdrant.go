@@ -0,0 +1,73 @@
+package reststore
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/vectorstore"
+)
+
+// qdrantIDKey is the payload key the record ID is kept under, since Qdrant
+// point IDs must be UUIDs or integers.
+const qdrantIDKey = "lumen_id"
+
+// Qdrant returns a Config for the collection of a Qdrant server, which must
+// already exist with the embeddings' size and the cosine distance. Points
+// get the uuid of the record ID, which is kept with the metadata in the
+// payload; apiKey may be empty.
+func Qdrant(baseURL, collection, apiKey string) Config {
+	cfg := Config{
+		BaseURL: baseURL,
+		Vars:    map[string]string{"collection": collection},
+		Upsert: Endpoint{
+			Method: "PUT",
+			Path:   `/collections/{{pathEscape .Vars.collection}}/points?wait=true`,
+			Body: `{"points":[{{range $i, $r := .Records}}{{if $i}},{{end}}` +
+				`{"id":{{json (uuid $r.ID)}},"vector":{{json $r.Vector}},"payload":{{json (merge $r.Meta "` + qdrantIDKey + `" $r.ID)}}}` +
+				`{{end}}]}`,
+		},
+		Query: Endpoint{
+			Method: "POST",
+			Path:   `/collections/{{pathEscape .Vars.collection}}/points/search`,
+			Body:   `{"vector":{{json .Vector}},"limit":{{.K}},"with_payload":true}`,
+		},
+		DecodeQuery: decodeQdrant,
+	}
+	if apiKey != "" {
+		cfg.Headers = map[string]string{"api-key": apiKey}
+	}
+	return cfg
+}
+
+func decodeQdrant(body []byte) ([]vectorstore.Match, error) {
+	var resp struct {
+		Result []struct {
+			ID      any            `json:"id"`
+			Score   float32        `json:"score"`
+			Payload map[string]any `json:"payload"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	matches := make([]vectorstore.Match, 0, len(resp.Result))
+	for _, p := range resp.Result {
+		m := vectorstore.Match{Record: vectorstore.Record{ID: fmt.Sprint(p.ID)}, Score: p.Score}
+		for k, v := range p.Payload {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			if k == qdrantIDKey {
+				m.ID = s
+				continue
+			}
+			if m.Meta == nil {
+				m.Meta = make(map[string]string, len(p.Payload))
+			}
+			m.Meta[k] = s
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
@@ -0,0 +1,233 @@
+// Package reststore is a vectorstore.VectorStore for any vector database
+// with a REST/JSON API. Each operation is an Endpoint whose path and body
+// are text/template templates, so a new database needs a Config rather
+// than a driver; Qdrant has a preset.
+//
+//	store, err := reststore.New(reststore.Qdrant("http://localhost:6333", "faces", ""))
+//
+// Templates see the Config's Vars as .Vars, the records of an upsert as
+// .Records and the vector and count of a query as .Vector and .K. Besides
+// text/template's builtins they may call:
+//
+//   - json: the JSON encoding of a value;
+//   - uuid: a name-based (version 5) UUID for a string, for databases that
+//     only take UUID or integer IDs;
+//   - merge: a copy of a string map with one more key and value;
+//   - pathEscape: url.PathEscape.
+package reststore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"github.com/edwinzhancn/lumen-sdk/pkg/vectorstore"
+)
+
+// maxErrorBody is how much of an error response is quoted in the error.
+const maxErrorBody = 512
+
+// Endpoint is one HTTP call of the store.
+type Endpoint struct {
+	// Method defaults to POST.
+	Method string
+	// Path is appended to Config.BaseURL; it may include a query string.
+	Path string
+	// Body renders the JSON request body.
+	Body string
+}
+
+// Config describes a database's REST API.
+type Config struct {
+	// BaseURL is the database's URL, e.g. "http://localhost:6333".
+	BaseURL string
+	// Vars are passed to the templates as .Vars, e.g. a collection name.
+	Vars map[string]string
+	// Headers are set on every request, e.g. an API key.
+	Headers map[string]string
+	// Upsert stores .Records; Query finds the .K records nearest .Vector.
+	Upsert Endpoint
+	Query  Endpoint
+	// DecodeQuery parses the body of a successful query response.
+	DecodeQuery func(body []byte) ([]vectorstore.Match, error)
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// UpsertData is what Upsert templates are executed with.
+type UpsertData struct {
+	Vars    map[string]string
+	Records []vectorstore.Record
+}
+
+// QueryData is what Query templates are executed with.
+type QueryData struct {
+	Vars   map[string]string
+	Vector []float32
+	K      int
+}
+
+// Store is a VectorStore over HTTP. It is safe for concurrent use.
+type Store struct {
+	cfg                   Config
+	upsertPath, queryPath *template.Template
+	upsertBody, queryBody *template.Template
+}
+
+var _ vectorstore.VectorStore = (*Store)(nil)
+
+// New parses cfg's templates. A missing BaseURL, Upsert or Query path or
+// DecodeQuery, or a template that does not parse, is an INVALID error.
+func New(cfg Config) (*Store, error) {
+	switch {
+	case cfg.BaseURL == "":
+		return nil, utils.InvalidError("reststore: BaseURL is required")
+	case cfg.Upsert.Path == "" || cfg.Query.Path == "":
+		return nil, utils.InvalidError("reststore: Upsert and Query paths are required")
+	case cfg.DecodeQuery == nil:
+		return nil, utils.InvalidError("reststore: DecodeQuery is required")
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	s := &Store{cfg: cfg}
+	for _, t := range []struct {
+		dst       **template.Template
+		name, src string
+	}{
+		{&s.upsertPath, "upsert path", cfg.Upsert.Path},
+		{&s.upsertBody, "upsert body", cfg.Upsert.Body},
+		{&s.queryPath, "query path", cfg.Query.Path},
+		{&s.queryBody, "query body", cfg.Query.Body},
+	} {
+		tmpl, err := template.New(t.name).Funcs(funcs).Parse(t.src)
+		if err != nil {
+			return nil, utils.Wrap(err, utils.ErrCodeInvalid, "reststore: parse "+t.name)
+		}
+		*t.dst = tmpl
+	}
+	return s, nil
+}
+
+// Upsert sends records to the Upsert endpoint.
+func (s *Store) Upsert(ctx context.Context, records []vectorstore.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	data := UpsertData{Vars: s.cfg.Vars, Records: records}
+	_, err := s.call(ctx, s.cfg.Upsert.Method, s.upsertPath, s.upsertBody, data)
+	return err
+}
+
+// Query sends vector and k to the Query endpoint and decodes the matches.
+func (s *Store) Query(ctx context.Context, vector []float32, k int) ([]vectorstore.Match, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	data := QueryData{Vars: s.cfg.Vars, Vector: vector, K: k}
+	body, err := s.call(ctx, s.cfg.Query.Method, s.queryPath, s.queryBody, data)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := s.cfg.DecodeQuery(body)
+	if err != nil {
+		return nil, utils.Wrap(err, utils.ErrCodeInternal, "reststore: decode query response")
+	}
+	return matches, nil
+}
+
+// call renders and sends one request, returning the response body. Server
+// errors and 429 are UNAVAILABLE, and so retried by utils.Retry; other
+// failures are not.
+func (s *Store) call(ctx context.Context, method string, path, body *template.Template, data any) ([]byte, error) {
+	var p, b bytes.Buffer
+	if err := path.Execute(&p, data); err != nil {
+		return nil, utils.Wrap(err, utils.ErrCodeInvalid, "reststore: render "+path.Name())
+	}
+	if err := body.Execute(&b, data); err != nil {
+		return nil, utils.Wrap(err, utils.ErrCodeInvalid, "reststore: render "+body.Name())
+	}
+	if method == "" {
+		method = http.MethodPost
+	}
+	target := strings.TrimRight(s.cfg.BaseURL, "/") + p.String()
+	req, err := http.NewRequestWithContext(ctx, method, target, &b)
+	if err != nil {
+		return nil, utils.Wrap(err, utils.ErrCodeInvalid, "reststore: build request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, utils.Wrap(err, utils.ErrCodeConnectionFailed, fmt.Sprintf("reststore: %s %s", method, target))
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, utils.Wrap(err, utils.ErrCodeConnectionFailed, "reststore: read response")
+	}
+	if resp.StatusCode/100 == 2 {
+		return respBody, nil
+	}
+
+	code := utils.ErrCodeInvalid
+	switch {
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests:
+		code = utils.ErrCodeUnavailable
+	case resp.StatusCode == http.StatusUnauthorized:
+		code = utils.ErrCodeUnauthorized
+	case resp.StatusCode == http.StatusForbidden:
+		code = utils.ErrCodeForbidden
+	}
+	if len(respBody) > maxErrorBody {
+		respBody = respBody[:maxErrorBody]
+	}
+	return nil, utils.NewLumenError(code, fmt.Sprintf("reststore: %s %s: %s", method, target, resp.Status), map[string]string{
+		"body": string(respBody),
+	})
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"uuid":       nameUUID,
+	"pathEscape": url.PathEscape,
+	"merge": func(m map[string]string, k, v string) map[string]string {
+		out := maps.Clone(m)
+		if out == nil {
+			out = make(map[string]string, 1)
+		}
+		out[k] = v
+		return out
+	},
+}
+
+// uuidNamespace is the RFC 4122 URL namespace, under which nameUUID
+// derives its UUIDs.
+var uuidNamespace = [16]byte{0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+
+// nameUUID returns the version 5 UUID of name, so the same ID always maps
+// to the same UUID.
+func nameUUID(name string) string {
+	h := sha1.New()
+	h.Write(uuidNamespace[:])
+	h.Write([]byte(name))
+	var u [16]byte
+	copy(u[:], h.Sum(nil))
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,108 @@
+package reststore
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	"github.com/edwinzhancn/lumen-sdk/pkg/vectorstore"
+)
+
+func TestQdrant(t *testing.T) {
+	var upsert map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		switch r.Method + " " + r.URL.RequestURI() {
+		case "PUT /collections/my%20faces/points?wait=true":
+			if err := json.Unmarshal(body, &upsert); err != nil {
+				t.Errorf("upsert body %s: %v", body, err)
+			}
+			_, _ = io.WriteString(w, `{"result":{"status":"completed"},"status":"ok"}`)
+		case "POST /collections/my%20faces/points/search":
+			var q map[string]any
+			if err := json.Unmarshal(body, &q); err != nil || q["limit"] != float64(2) || q["with_payload"] != true {
+				t.Errorf("search body %s: %v", body, err)
+			}
+			_, _ = io.WriteString(w, `{"result":[{"id":"x","score":0.9,"payload":{"lumen_id":"a.jpg/face-0","path":"a.jpg","n":1}}]}`)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.RequestURI())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := New(Qdrant(srv.URL, "my faces", "secret"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+	err = s.Upsert(ctx, []vectorstore.Record{
+		{ID: "a.jpg/face-0", Vector: []float32{0.6, 0.8}, Meta: map[string]string{"path": "a.jpg"}},
+		{ID: "b.jpg", Vector: []float32{1, 0}},
+	})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	points, _ := upsert["points"].([]any)
+	if len(points) != 2 {
+		t.Fatalf("upserted %v, want 2 points", upsert)
+	}
+	p := points[0].(map[string]any)
+	if p["id"] != nameUUID("a.jpg/face-0") {
+		t.Errorf("point id = %v, want %s", p["id"], nameUUID("a.jpg/face-0"))
+	}
+	if payload := p["payload"].(map[string]any); payload["lumen_id"] != "a.jpg/face-0" || payload["path"] != "a.jpg" {
+		t.Errorf("payload = %v", payload)
+	}
+
+	matches, err := s.Query(ctx, []float32{1, 0}, 2)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(matches) != 1 || matches[0].ID != "a.jpg/face-0" || matches[0].Score != 0.9 || matches[0].Meta["path"] != "a.jpg" {
+		t.Fatalf("matches = %+v", matches)
+	}
+
+	s.cfg.Headers = nil
+	if err := s.Upsert(ctx, []vectorstore.Record{{ID: "c", Vector: []float32{1, 0}}}); !utils.HasErrorCode(err, utils.ErrCodeUnauthorized) {
+		t.Errorf("Upsert without the key = %v, want UNAUTHORIZED", err)
+	}
+}
+
+func TestServerErrorsAreRetryable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	s, err := New(Qdrant(srv.URL, "faces", ""))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = s.Upsert(context.Background(), []vectorstore.Record{{ID: "a", Vector: []float32{1}}})
+	if !utils.IsRetryable(err) {
+		t.Errorf("Upsert on a 503 = %v, want a retryable error", err)
+	}
+}
+
+func TestNewRejectsBadTemplates(t *testing.T) {
+	cfg := Qdrant("http://localhost:6333", "faces", "")
+	cfg.Query.Body = `{"vector":{{json .Vector}`
+	if _, err := New(cfg); !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+		t.Errorf("New with a broken template = %v, want INVALID", err)
+	}
+}
+
+func TestNameUUID(t *testing.T) {
+	// As Python's uuid.uuid5(uuid.NAMESPACE_URL, "http://www.example.com/").
+	if got, want := nameUUID("http://www.example.com/"), "fcde3c85-2270-590f-9e7c-ee003d65e0e2"; got != want {
+		t.Errorf("nameUUID = %s, want %s", got, want)
+	}
+}
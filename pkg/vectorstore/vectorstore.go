@@ -0,0 +1,80 @@
+// Package vectorstore stores Lumen embeddings, such as face or CLIP
+// vectors, in a vector database and finds the nearest ones again.
+//
+// VectorStore is the small interface a database adapter implements. Two
+// come with the SDK, each in its own subpackage so the core module needs no
+// database driver:
+//
+//   - memstore keeps records in memory, for tests and small apps;
+//   - reststore talks to any database with a REST/JSON API through
+//     endpoint templates, with a preset for Qdrant.
+//
+// EmbedAndStore is the glue between the two sides: it sends items through
+// InferBatch, checks and normalizes the vectors, maps each to an ID and
+// upserts them in batches, retrying transient store failures.
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+// Record is one vector with the ID it is stored under and string metadata
+// stored alongside it.
+type Record struct {
+	ID     string            `json:"id"`
+	Vector []float32         `json:"vector"`
+	Meta   map[string]string `json:"meta,omitempty"`
+}
+
+// Match is a record returned by Query with its similarity to the query
+// vector; higher is closer.
+type Match struct {
+	Record
+	Score float32 `json:"score"`
+}
+
+// VectorStore stores records and finds the ones nearest a vector.
+// Implementations must be safe for concurrent use.
+type VectorStore interface {
+	// Upsert stores records, replacing any with the same ID.
+	Upsert(ctx context.Context, records []Record) error
+	// Query returns up to k records nearest vector, closest first.
+	Query(ctx context.Context, vector []float32, k int) ([]Match, error)
+}
+
+// Normalize returns vec scaled to unit length, so a dot product of two
+// normalized vectors is their cosine similarity. A zero vector is returned
+// as it is.
+func Normalize(vec []float32) []float32 {
+	var sum float64
+	for _, v := range vec {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vec
+	}
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(vec))
+	for i, v := range vec {
+		out[i] = v / norm
+	}
+	return out
+}
+
+// CheckDimension returns an INVALID error naming the first record whose
+// vector does not have dim entries, or that has none at all.
+func CheckDimension(records []Record, dim int) error {
+	for _, r := range records {
+		if len(r.Vector) == 0 {
+			return utils.InvalidError(fmt.Sprintf("record %q has an empty vector", r.ID))
+		}
+		if dim > 0 && len(r.Vector) != dim {
+			return utils.InvalidError(fmt.Sprintf("record %q has %d dimensions, want %d", r.ID, len(r.Vector), dim))
+		}
+	}
+	return nil
+}
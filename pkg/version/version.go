@@ -0,0 +1,98 @@
+// Package version reports which build of the Lumen SDK is running.
+//
+// Release builds set Version, Commit and BuildTime with -ldflags:
+//
+//	-X github.com/edwinzhancn/lumen-sdk/pkg/version.Version=v1.2.3
+//	-X github.com/edwinzhancn/lumen-sdk/pkg/version.Commit=abc1234
+//	-X github.com/edwinzhancn/lumen-sdk/pkg/version.BuildTime=2026-07-10T00:00:00Z
+//
+// Binaries built without them, and applications that import the SDK as a
+// module, fall back to what the Go toolchain recorded in the binary.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Module is the SDK's module path.
+const Module = "github.com/edwinzhancn/lumen-sdk"
+
+// Build information, populated by -ldflags at build time.
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info is the version metadata of a running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version,omitempty"`
+	OS        string `json:"os,omitempty"`
+	Arch      string `json:"arch,omitempty"`
+	// Features lists the optional surfaces the serving process has
+	// enabled, e.g. "docs" or "usage" for the Host Broker.
+	Features []string `json:"features,omitempty"`
+}
+
+// Get returns the running build's Info. Fields not set with -ldflags come
+// from the binary's build info: the SDK module's version (when the SDK is a
+// dependency, or was built with go install ...@version), and the VCS
+// revision and commit time when the SDK is the main module.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		fillFromBuildInfo(&info, bi)
+	}
+	return info
+}
+
+func fillFromBuildInfo(info *Info, bi *debug.BuildInfo) {
+	mod := &bi.Main
+	if bi.Main.Path != Module {
+		mod = nil
+		for _, dep := range bi.Deps {
+			if dep.Path == Module {
+				mod = dep
+				if dep.Replace != nil {
+					mod = dep.Replace
+				}
+				break
+			}
+		}
+	}
+	if mod == nil {
+		return
+	}
+	if info.Version == "dev" && mod.Version != "" && mod.Version != "(devel)" {
+		info.Version = mod.Version
+	}
+	if mod != &bi.Main {
+		return
+	}
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "vcs.revision" && info.Commit == "unknown":
+			info.Commit = s.Value
+		case s.Key == "vcs.time" && info.BuildTime == "unknown":
+			info.BuildTime = s.Value
+		}
+	}
+}
+
+// String formats i for logs and CLI output, e.g.
+// "v1.2.3 (commit abc1234, built 2026-07-10T00:00:00Z, go1.25.0 linux/amd64)".
+func (i Info) String() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s %s/%s)", i.Version, i.Commit, i.BuildTime, i.GoVersion, i.OS, i.Arch)
+}
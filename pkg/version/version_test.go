@@ -0,0 +1,62 @@
+package version
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestFillFromBuildInfoMainModule(t *testing.T) {
+	bi := &debug.BuildInfo{
+		Main: debug.Module{Path: Module, Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc1234"},
+			{Key: "vcs.time", Value: "2026-07-10T00:00:00Z"},
+		},
+	}
+	info := Info{Version: "dev", Commit: "unknown", BuildTime: "unknown"}
+	fillFromBuildInfo(&info, bi)
+	if info.Version != "dev" || info.Commit != "abc1234" || info.BuildTime != "2026-07-10T00:00:00Z" {
+		t.Fatalf("info = %+v, want dev with the VCS revision and time", info)
+	}
+
+	// -ldflags values win.
+	info = Info{Version: "v1.2.3", Commit: "fff0000", BuildTime: "then"}
+	fillFromBuildInfo(&info, bi)
+	if info.Version != "v1.2.3" || info.Commit != "fff0000" || info.BuildTime != "then" {
+		t.Fatalf("info = %+v, want the -ldflags values kept", info)
+	}
+}
+
+func TestFillFromBuildInfoDependency(t *testing.T) {
+	bi := &debug.BuildInfo{
+		Main: debug.Module{Path: "example.com/app", Version: "v0.1.0"},
+		Deps: []*debug.Module{
+			{Path: "example.com/other", Version: "v9.9.9"},
+			{Path: Module, Version: "v1.4.0"},
+		},
+		Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "app-commit"}},
+	}
+	info := Info{Version: "dev", Commit: "unknown", BuildTime: "unknown"}
+	fillFromBuildInfo(&info, bi)
+	// The application's revision is not the SDK's.
+	if info.Version != "v1.4.0" || info.Commit != "unknown" {
+		t.Fatalf("info = %+v, want the SDK dependency version and no commit", info)
+	}
+
+	bi.Deps[1].Replace = &debug.Module{Path: "../lumen-sdk"}
+	info = Info{Version: "dev", Commit: "unknown", BuildTime: "unknown"}
+	fillFromBuildInfo(&info, bi)
+	if info.Version != "dev" {
+		t.Fatalf("version = %q, want dev for a local replacement", info.Version)
+	}
+}
+
+func TestGetReportsPlatform(t *testing.T) {
+	info := Get()
+	if info.GoVersion == "" || info.OS == "" || info.Arch == "" {
+		t.Fatalf("info = %+v, want the Go version and platform", info)
+	}
+	if info.Version == "" || info.Commit == "" || info.BuildTime == "" {
+		t.Fatalf("info = %+v, want every build field set", info)
+	}
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,6 +45,14 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("Expected rediscovery_backoff_max 2m, got %s", config.Discovery.RediscoveryBackoffMax)
 	}
 
+	if config.Discovery.ProbeTimeout != 10*time.Second {
+		t.Errorf("Expected probe_timeout 10s, got %s", config.Discovery.ProbeTimeout)
+	}
+
+	if config.Discovery.MaxConcurrentProbes != 4 {
+		t.Errorf("Expected max_concurrent_probes 4, got %d", config.Discovery.MaxConcurrentProbes)
+	}
+
 	if config.Broker.Port != 5866 {
 		t.Errorf("Expected Broker port 5866, got %d", config.Broker.Port)
 	}
@@ -96,6 +108,62 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid discovery - negative max concurrent probes",
+			config: &config2.Config{
+				Discovery: config2.DiscoveryConfig{
+					Enabled:               true,
+					ServiceType:           "_lumen._tcp",
+					DeploymentID:          "local",
+					ResolveTimeout:        time.Second,
+					ConnectTimeout:        time.Second,
+					RediscoveryBackoffMin: time.Second,
+					RediscoveryBackoffMax: time.Second,
+					MaxConcurrentProbes:   -1,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid discovery - negative notify window",
+			config: &config2.Config{
+				Discovery: config2.DiscoveryConfig{
+					Enabled:               true,
+					ServiceType:           "_lumen._tcp",
+					DeploymentID:          "local",
+					ResolveTimeout:        time.Second,
+					ConnectTimeout:        time.Second,
+					RediscoveryBackoffMin: time.Second,
+					RediscoveryBackoffMax: time.Second,
+					NotifyWindow:          -time.Millisecond,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid metrics - negative latency window",
+			config: &config2.Config{
+				Logging: config2.LoggingConfig{Level: "info", Format: "json"},
+				Metrics: config2.MetricsConfig{LatencyWindow: -time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid pool - negative max connections",
+			config: &config2.Config{
+				Logging: config2.LoggingConfig{Level: "info", Format: "json"},
+				Pool:    config2.PoolConfig{MaxConnections: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid pool - health check without interval",
+			config: &config2.Config{
+				Logging: config2.LoggingConfig{Level: "info", Format: "json"},
+				Pool:    config2.PoolConfig{HealthCheck: true},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid broker port",
 			config: &config2.Config{
@@ -103,6 +171,71 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid broker socket",
+			config: &config2.Config{
+				Logging: config2.LoggingConfig{Level: "info", Format: "json"},
+				Broker:  config2.BrokerConfig{Enabled: true, Socket: "/run/lumen/broker.sock", SocketMode: "0660"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid broker - socket and port both set",
+			config: &config2.Config{
+				Logging: config2.LoggingConfig{Level: "info", Format: "json"},
+				Broker:  config2.BrokerConfig{Enabled: true, Port: 5866, Socket: "/run/lumen/broker.sock"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid broker - bad socket mode",
+			config: &config2.Config{
+				Logging: config2.LoggingConfig{Level: "info", Format: "json"},
+				Broker:  config2.BrokerConfig{Enabled: true, Socket: "/run/lumen/broker.sock", SocketMode: "rw-rw----"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid broker - negative read timeout",
+			config: &config2.Config{
+				Logging: config2.LoggingConfig{Level: "info", Format: "json"},
+				Broker:  config2.BrokerConfig{Enabled: true, Port: 5866, ReadTimeout: -time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid broker - election without lease file",
+			config: &config2.Config{
+				Logging: config2.LoggingConfig{Level: "info", Format: "json"},
+				Broker: config2.BrokerConfig{Enabled: true, Port: 5866,
+					Election: config2.ElectionConfig{Enabled: true, LeaseTimeout: 15 * time.Second}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid pool - flap limit without suspect time",
+			config: &config2.Config{
+				Logging: config2.LoggingConfig{Level: "info", Format: "json"},
+				Pool:    config2.PoolConfig{Hysteresis: config2.HysteresisConfig{FlapLimit: 3, FlapWindow: time.Minute}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid task state - gc without retention",
+			config: &config2.Config{
+				Logging:   config2.LoggingConfig{Level: "info", Format: "json"},
+				TaskState: config2.TaskStateConfig{GCInterval: time.Minute},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid jobs - negative ttl",
+			config: &config2.Config{
+				Logging: config2.LoggingConfig{Level: "info", Format: "json"},
+				Jobs:    config2.JobsConfig{TTL: -time.Minute},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid log level",
 			config: &config2.Config{
@@ -230,6 +363,26 @@ func TestLoadFromEnvBrokerURL(t *testing.T) {
 	}
 }
 
+func TestLoadFromEnvNodeFilters(t *testing.T) {
+	t.Setenv("LUMEN_DISCOVERY_ALLOW_NODES", "lab-*, 10.1.0.0/16")
+	t.Setenv("LUMEN_DISCOVERY_DENY_NODES", "cluster=staging")
+	t.Setenv("LUMEN_DISCOVERY_PUSH_ALLOW_NODES", "edge-*")
+
+	cfg := config2.DefaultConfig()
+	if err := cfg.LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv() error = %v", err)
+	}
+	if got := cfg.Discovery.AllowNodes; len(got) != 2 || got[0] != "lab-*" || got[1] != "10.1.0.0/16" {
+		t.Errorf("AllowNodes = %q", got)
+	}
+	if got := cfg.Discovery.DenyNodes; len(got) != 1 || got[0] != "cluster=staging" {
+		t.Errorf("DenyNodes = %q", got)
+	}
+	if got := cfg.Discovery.Push.AllowNodes; len(got) != 1 || got[0] != "edge-*" {
+		t.Errorf("Push.AllowNodes = %q", got)
+	}
+}
+
 func TestLoadFromEnvRejectsInvalidValues(t *testing.T) {
 	tests := []struct {
 		name string
@@ -239,6 +392,21 @@ func TestLoadFromEnvRejectsInvalidValues(t *testing.T) {
 		{name: "duration", key: "LUMEN_DISCOVERY_CONNECT_TIMEOUT", env: "soon"},
 		{name: "boolean", key: "LUMEN_DISCOVERY_MDNS_ENABLED", env: "sometimes"},
 		{name: "port", key: "LUMEN_BROKER_PORT", env: "not-a-port"},
+		{name: "broker read timeout", key: "LUMEN_BROKER_READ_TIMEOUT", env: "patient"},
+		{name: "probe concurrency", key: "LUMEN_DISCOVERY_MAX_CONCURRENT_PROBES", env: "many"},
+		{name: "notify window", key: "LUMEN_DISCOVERY_NOTIFY_WINDOW", env: "brief"},
+		{name: "max catalog age", key: "LUMEN_DISCOVERY_MAX_CATALOG_AGE", env: "old"},
+		{name: "fail on stale catalog", key: "LUMEN_DISCOVERY_FAIL_ON_STALE_CATALOG", env: "sometimes"},
+		{name: "provisional traffic", key: "LUMEN_DISCOVERY_PROVISIONAL_TRAFFIC", env: "eventually"},
+		{name: "latency window", key: "LUMEN_METRICS_LATENCY_WINDOW", env: "forever"},
+		{name: "pool connections", key: "LUMEN_POOL_MAX_CONNECTIONS", env: "lots"},
+		{name: "pool health check", key: "LUMEN_POOL_HEALTH_CHECK", env: "maybe"},
+		{name: "pool health interval", key: "LUMEN_POOL_HEALTH_INTERVAL", env: "often"},
+		{name: "usage quota window", key: "LUMEN_USAGE_QUOTA_WINDOW", env: "daily"},
+		{name: "fallback", key: "LUMEN_FALLBACK_ENABLED", env: "offline"},
+		{name: "cost hints timeout multiplier", key: "LUMEN_COST_HINTS_TIMEOUT_MULTIPLIER", env: "tenfold"},
+		{name: "cost hints max timeout", key: "LUMEN_COST_HINTS_MAX_TIMEOUT", env: "ages"},
+		{name: "cost hints chunk bytes", key: "LUMEN_COST_HINTS_MAX_CHUNK_BYTES", env: "big"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -250,6 +418,135 @@ func TestLoadFromEnvRejectsInvalidValues(t *testing.T) {
 	}
 }
 
+func TestLoadFromEnvProbeSettings(t *testing.T) {
+	t.Setenv("LUMEN_DISCOVERY_PROBE_TIMEOUT", "2s")
+	t.Setenv("LUMEN_DISCOVERY_MAX_CONCURRENT_PROBES", "8")
+	t.Setenv("LUMEN_DISCOVERY_PROVISIONAL_TRAFFIC", "false")
+
+	config := config2.DefaultConfig()
+	if !config.Discovery.ProvisionalTraffic {
+		t.Fatal("provisional_traffic should default to true")
+	}
+	if err := config.LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv() error = %v", err)
+	}
+	if config.Discovery.ProbeTimeout != 2*time.Second {
+		t.Errorf("Expected probe_timeout 2s, got %s", config.Discovery.ProbeTimeout)
+	}
+	if config.Discovery.MaxConcurrentProbes != 8 {
+		t.Errorf("Expected max_concurrent_probes 8, got %d", config.Discovery.MaxConcurrentProbes)
+	}
+	if config.Discovery.ProvisionalTraffic {
+		t.Error("Expected provisional_traffic false")
+	}
+}
+
+func TestCatalogFreshnessFromEnv(t *testing.T) {
+	t.Setenv("LUMEN_DISCOVERY_MAX_CATALOG_AGE", "3m")
+	t.Setenv("LUMEN_DISCOVERY_FAIL_ON_STALE_CATALOG", "true")
+
+	cfg := config2.DefaultConfig()
+	if cfg.Discovery.MaxCatalogAge != 10*time.Minute || cfg.Discovery.FailOnStaleCatalog {
+		t.Fatalf("defaults: max_catalog_age=%s fail_on_stale_catalog=%v, want 10m and false",
+			cfg.Discovery.MaxCatalogAge, cfg.Discovery.FailOnStaleCatalog)
+	}
+	if err := cfg.LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv: %v", err)
+	}
+	if cfg.Discovery.MaxCatalogAge != 3*time.Minute || !cfg.Discovery.FailOnStaleCatalog {
+		t.Fatalf("max_catalog_age=%s fail_on_stale_catalog=%v, want 3m and true",
+			cfg.Discovery.MaxCatalogAge, cfg.Discovery.FailOnStaleCatalog)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestLoadFromEnvPoolSettings(t *testing.T) {
+	t.Setenv("LUMEN_POOL_MAX_CONNECTIONS", "5")
+	t.Setenv("LUMEN_POOL_MAX_IDLE_TIME", "2m")
+	t.Setenv("LUMEN_POOL_MAX_LIFETIME", "1h")
+	t.Setenv("LUMEN_POOL_HEALTH_CHECK", "false")
+	t.Setenv("LUMEN_POOL_HEALTH_INTERVAL", "45s")
+	t.Setenv("LUMEN_POOL_STREAM_WARN_THRESHOLD", "64")
+	t.Setenv("LUMEN_POOL_STREAM_MAX_AGE", "10m")
+	t.Setenv("LUMEN_POOL_STRATEGY", "custom")
+	t.Setenv("LUMEN_POOL_RANDOM_TIE_BREAK", "true")
+	t.Setenv("LUMEN_POOL_CALLBACK_PANIC_LIMIT", "5")
+	t.Setenv("LUMEN_POOL_KEEP_ALIVE", "90s")
+	t.Setenv("LUMEN_POOL_KEEP_ALIVE_TIMEOUT", "10s")
+	t.Setenv("LUMEN_POOL_MAX_MESSAGE_SIZE", "16777216")
+	t.Setenv("LUMEN_POOL_COMPRESSION", "gzip")
+	t.Setenv("LUMEN_POOL_TLS_MODE", "tls")
+	t.Setenv("LUMEN_POOL_TLS_CA_FILE", "/etc/lumen/ca.pem")
+	t.Setenv("LUMEN_POOL_TLS_CERT_FILE", "/etc/lumen/client.pem")
+	t.Setenv("LUMEN_POOL_TLS_KEY_FILE", "/etc/lumen/client-key.pem")
+	t.Setenv("LUMEN_POOL_TLS_SERVER_NAME", "spiffe://lab.example/node")
+	t.Setenv("LUMEN_POOL_OUTLIER_ENABLED", "true")
+	t.Setenv("LUMEN_POOL_OUTLIER_INTERVAL", "5s")
+	t.Setenv("LUMEN_POOL_OUTLIER_WINDOW", "30s")
+	t.Setenv("LUMEN_POOL_OUTLIER_MIN_REQUESTS", "50")
+	t.Setenv("LUMEN_POOL_OUTLIER_ERROR_RATE_FACTOR", "2.5")
+	t.Setenv("LUMEN_POOL_OUTLIER_LATENCY_FACTOR", "0")
+	t.Setenv("LUMEN_POOL_OUTLIER_EJECTION_TIME", "1m")
+	t.Setenv("LUMEN_POOL_OUTLIER_MAX_EJECTION_PERCENT", "20")
+	t.Setenv("LUMEN_POOL_OUTLIER_PROBE_FRACTION", "0.25")
+	t.Setenv("LUMEN_POOL_HYSTERESIS_FAIL_THRESHOLD", "4")
+	t.Setenv("LUMEN_POOL_HYSTERESIS_RECOVER_THRESHOLD", "3")
+	t.Setenv("LUMEN_POOL_HYSTERESIS_FLAP_LIMIT", "0")
+	t.Setenv("LUMEN_POOL_HYSTERESIS_FLAP_WINDOW", "15m")
+	t.Setenv("LUMEN_POOL_HYSTERESIS_SUSPECT_TIME", "1m")
+	t.Setenv("LUMEN_POOL_HYSTERESIS_SMOOTHING", "0.5")
+
+	config := config2.DefaultConfig()
+	if err := config.LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv() error = %v", err)
+	}
+	want := config2.PoolConfig{
+		MaxConnections:      5,
+		MaxIdleTime:         2 * time.Minute,
+		MaxLifetime:         time.Hour,
+		HealthCheck:         false,
+		HealthInterval:      45 * time.Second,
+		StreamWarnThreshold: 64,
+		StreamMaxAge:        10 * time.Minute,
+		Strategy:            config2.StrategyCustom,
+		RandomTieBreak:      true,
+		CallbackPanicLimit:  5,
+		KeepAlive:           90 * time.Second,
+		KeepAliveTimeout:    10 * time.Second,
+		MaxMessageSize:      16 << 20,
+		Compression:         config2.CompressionGzip,
+		TLS: config2.TransportConfig{
+			Mode:       "tls",
+			CAFile:     "/etc/lumen/ca.pem",
+			CertFile:   "/etc/lumen/client.pem",
+			KeyFile:    "/etc/lumen/client-key.pem",
+			ServerName: "spiffe://lab.example/node",
+		},
+		Outlier: config2.OutlierConfig{
+			Enabled:            true,
+			Interval:           5 * time.Second,
+			Window:             30 * time.Second,
+			MinRequests:        50,
+			ErrorRateFactor:    2.5,
+			EjectionTime:       time.Minute,
+			MaxEjectionPercent: 20,
+			ProbeFraction:      0.25,
+		},
+		Hysteresis: config2.HysteresisConfig{
+			FailThreshold:    4,
+			RecoverThreshold: 3,
+			FlapWindow:       15 * time.Minute,
+			SuspectTime:      time.Minute,
+			Smoothing:        0.5,
+		},
+	}
+	if !reflect.DeepEqual(config.Pool, want) {
+		t.Errorf("pool = %+v, want %+v", config.Pool, want)
+	}
+}
+
 func TestSaveAndLoadConfig(t *testing.T) {
 	// 创建临时配置文件
 	tmpFile := "/tmp/test_lumen_config.yaml"
@@ -259,6 +556,8 @@ func TestSaveAndLoadConfig(t *testing.T) {
 	originalConfig := config2.DefaultConfig()
 	originalConfig.Logging.Level = "debug"
 	originalConfig.Broker.Port = 9090
+	originalConfig.Pool.MaxConnections = 4
+	originalConfig.Pool.MaxLifetime = 90 * time.Minute
 
 	// 保存配置
 	err := originalConfig.SaveConfig(tmpFile)
@@ -280,4 +579,522 @@ func TestSaveAndLoadConfig(t *testing.T) {
 	if loadedConfig.Broker.Port != 9090 {
 		t.Errorf("Expected Broker port 9090, got %d", loadedConfig.Broker.Port)
 	}
+
+	if !reflect.DeepEqual(loadedConfig.Pool, originalConfig.Pool) {
+		t.Errorf("Expected pool %+v, got %+v", originalConfig.Pool, loadedConfig.Pool)
+	}
+}
+
+func TestPresetConfig(t *testing.T) {
+	basic, err := config2.PresetConfig("")
+	if err != nil {
+		t.Fatalf("PresetConfig(\"\") error = %v", err)
+	}
+	if basic.Discovery.ScanInterval != config2.DefaultConfig().Discovery.ScanInterval {
+		t.Errorf("basic preset should match DefaultConfig")
+	}
+
+	for _, name := range config2.PresetNames() {
+		cfg, err := config2.PresetConfig(name)
+		if err != nil {
+			t.Fatalf("PresetConfig(%q) error = %v", name, err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("preset %q does not validate: %v", name, err)
+		}
+	}
+
+	minimal, _ := config2.PresetConfig("Minimal")
+	if minimal.Logging.Level != "warn" || minimal.Chunk.MaxChunkBytes != 64*1024 {
+		t.Errorf("unexpected minimal preset: %+v", minimal)
+	}
+
+	if minimal.Pool.MaxConnections == 0 || minimal.Pool.HealthInterval <= config2.DefaultConfig().Pool.HealthInterval {
+		t.Errorf("minimal preset should limit pool connections and health checks: %+v", minimal.Pool)
+	}
+
+	if _, err := config2.PresetConfig("turbo"); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+}
+
+func TestBrokerSocketFromEnv(t *testing.T) {
+	t.Setenv("LUMEN_BROKER_SOCKET", "/run/lumen/broker.sock")
+	t.Setenv("LUMEN_BROKER_SOCKET_MODE", "0600")
+
+	cfg := config2.DefaultConfig()
+	if err := cfg.LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv: %v", err)
+	}
+	if cfg.Broker.Socket != "/run/lumen/broker.sock" || cfg.Broker.Port != 0 {
+		t.Fatalf("broker = %+v, want the socket and no TCP port", cfg.Broker)
+	}
+	mode, err := cfg.Broker.SocketFileMode()
+	if err != nil || mode != 0o600 {
+		t.Fatalf("socket mode = %v, %v; want 0600", mode, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestBrokerElectionFromEnv(t *testing.T) {
+	t.Setenv("LUMEN_BROKER_ELECTION_ENABLED", "true")
+	t.Setenv("LUMEN_BROKER_ELECTION_LEASE_FILE", "/mnt/shared/broker.lease")
+	t.Setenv("LUMEN_BROKER_ELECTION_LEASE_TIMEOUT", "10s")
+	t.Setenv("LUMEN_BROKER_ELECTION_ID", "hub-a")
+
+	cfg := config2.DefaultConfig()
+	if err := cfg.LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv: %v", err)
+	}
+	want := config2.ElectionConfig{Enabled: true, LeaseFile: "/mnt/shared/broker.lease", LeaseTimeout: 10 * time.Second, ID: "hub-a"}
+	if cfg.Broker.Election != want {
+		t.Fatalf("election = %+v, want %+v", cfg.Broker.Election, want)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cfg.Broker.Election.LeaseTimeout = 100 * time.Millisecond
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("Validate accepted a sub-second lease timeout")
+	}
+}
+
+func TestQueueFromEnv(t *testing.T) {
+	t.Setenv("LUMEN_QUEUE_DIR", "/var/lib/lumen/queue")
+	t.Setenv("LUMEN_QUEUE_MAX_BYTES", "1048576")
+	t.Setenv("LUMEN_QUEUE_CONCURRENCY", "8")
+	t.Setenv("LUMEN_QUEUE_MAX_ATTEMPTS", "3")
+	t.Setenv("LUMEN_QUEUE_RETRY_BACKOFF", "1s")
+	t.Setenv("LUMEN_QUEUE_MAX_RETRY_BACKOFF", "1m")
+	t.Setenv("LUMEN_QUEUE_ATTEMPT_TIMEOUT", "30s")
+	t.Setenv("LUMEN_QUEUE_RESULTS_DIR", "/var/lib/lumen/results")
+
+	cfg := config2.DefaultConfig()
+	if err := cfg.LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv: %v", err)
+	}
+	want := config2.QueueConfig{
+		Dir:             "/var/lib/lumen/queue",
+		MaxBytes:        1 << 20,
+		Concurrency:     8,
+		MaxAttempts:     3,
+		RetryBackoff:    time.Second,
+		MaxRetryBackoff: time.Minute,
+		AttemptTimeout:  30 * time.Second,
+		ResultsDir:      "/var/lib/lumen/results",
+	}
+	if cfg.Queue != want {
+		t.Fatalf("queue = %+v, want %+v", cfg.Queue, want)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	t.Setenv("LUMEN_QUEUE_CONCURRENCY", "many")
+	if err := config2.DefaultConfig().LoadFromEnv(); err == nil {
+		t.Fatal("LoadFromEnv accepted a non-numeric queue concurrency")
+	}
+}
+
+func TestCostHintsFromEnv(t *testing.T) {
+	t.Setenv("LUMEN_COST_HINTS_ENABLED", "false")
+	t.Setenv("LUMEN_COST_HINTS_TIMEOUT_MULTIPLIER", "4.5")
+	t.Setenv("LUMEN_COST_HINTS_MIN_TIMEOUT", "500ms")
+	t.Setenv("LUMEN_COST_HINTS_MAX_TIMEOUT", "2m")
+	t.Setenv("LUMEN_COST_HINTS_MIN_CHUNK_BYTES", "65536")
+	t.Setenv("LUMEN_COST_HINTS_MAX_CHUNK_BYTES", "1048576")
+
+	cfg := config2.DefaultConfig()
+	if !cfg.CostHints.Enabled {
+		t.Fatal("cost_hints should be enabled by default")
+	}
+	if err := cfg.LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv: %v", err)
+	}
+	want := config2.CostHintsConfig{
+		TimeoutMultiplier: 4.5,
+		MinTimeout:        500 * time.Millisecond,
+		MaxTimeout:        2 * time.Minute,
+		MinChunkBytes:     64 << 10,
+		MaxChunkBytes:     1 << 20,
+	}
+	if cfg.CostHints != want {
+		t.Fatalf("cost_hints = %+v, want %+v", cfg.CostHints, want)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := config2.DefaultConfig()
+	cfg.Discovery.ServiceType = ""
+	cfg.Pool.MaxConnections = -1
+	cfg.Logging.Level = "loud"
+
+	err := cfg.Validate()
+	var list config2.ValidationErrors
+	if !errors.As(err, &list) {
+		t.Fatalf("Validate() = %v, want ValidationErrors", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("got %d errors, want 3: %v", len(list), err)
+	}
+	if !strings.HasPrefix(err.Error(), "3 problems: ") {
+		t.Errorf("error %q should lead with the problem count", err)
+	}
+	for _, want := range []string{"discovery.service_type", "pool.max_connections", `logging.level "loud"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestValidateCrossFieldChecks(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*config2.Config)
+		want   []string
+	}{
+		{
+			name: "chunk larger than a gRPC message",
+			mutate: func(c *config2.Config) {
+				c.Chunk.MaxChunkBytes = 8 << 20
+			},
+			want: []string{"chunk.max_chunk_bytes (8388608) must not exceed 4194304"},
+		},
+		{
+			name: "inverted cost hint bounds",
+			mutate: func(c *config2.Config) {
+				c.CostHints.MinTimeout = time.Minute
+				c.CostHints.MaxTimeout = time.Second
+				c.CostHints.MaxChunkBytes = 8 << 20
+			},
+			want: []string{
+				"cost_hints.max_timeout must be at least cost_hints.min_timeout",
+				"cost_hints.max_chunk_bytes (8388608) must not exceed 4194304",
+			},
+		},
+		{
+			name: "too many parallel upload streams",
+			mutate: func(c *config2.Config) {
+				c.Chunk.ParallelStreams = 8
+				c.Chunk.ParallelThreshold = -1
+				c.Chunk.SendRetries = -1
+			},
+			want: []string{
+				"chunk.parallel_streams (8) must be between 0 and 4",
+				"chunk.parallel_threshold must be non-negative",
+				"chunk.send_retries must be non-negative",
+			},
+		},
+		{
+			name: "resolve outlasts the scan interval",
+			mutate: func(c *config2.Config) {
+				c.Discovery.ResolveTimeout = time.Minute
+			},
+			want: []string{"discovery.resolve_timeout (1m0s) must not exceed discovery.scan_interval (30s)"},
+		},
+		{
+			name: "failing on a stale catalog that is never stale",
+			mutate: func(c *config2.Config) {
+				c.Discovery.MaxCatalogAge = 0
+				c.Discovery.FailOnStaleCatalog = true
+			},
+			want: []string{"discovery.fail_on_stale_catalog requires discovery.max_catalog_age"},
+		},
+		{
+			name: "negative catalog age",
+			mutate: func(c *config2.Config) {
+				c.Discovery.MaxCatalogAge = -time.Minute
+			},
+			want: []string{"discovery.max_catalog_age must be non-negative"},
+		},
+		{
+			name: "no discovery backend",
+			mutate: func(c *config2.Config) {
+				c.Discovery.MDNSEnabled = false
+			},
+			want: []string{"discovery needs a backend when enabled"},
+		},
+		{
+			name: "queue backoff cap below the first backoff",
+			mutate: func(c *config2.Config) {
+				c.Queue.Dir = "/var/lib/lumen/queue"
+				c.Queue.ResultsDir = "/var/lib/lumen/queue"
+				c.Queue.MaxRetryBackoff = time.Second
+			},
+			want: []string{
+				"queue.max_retry_backoff must be at least queue.retry_backoff",
+				"queue.results_dir must differ from queue.dir",
+			},
+		},
+		{
+			name: "keepalive without a timeout",
+			mutate: func(c *config2.Config) {
+				c.Pool.KeepAliveTimeout = 0
+			},
+			want: []string{"pool.keep_alive_timeout must be positive when pool.keep_alive is set"},
+		},
+		{
+			name: "bad message size and compression",
+			mutate: func(c *config2.Config) {
+				c.Pool.MaxMessageSize = -1
+				c.Pool.Compression = "zstd"
+			},
+			want: []string{
+				"pool.max_message_size must be non-negative",
+				`pool.compression "zstd" must be none or gzip`,
+			},
+		},
+		{
+			name: "bad per-node transport entries",
+			mutate: func(c *config2.Config) {
+				c.Pool.PerNode = map[string]config2.TransportConfig{
+					"gpu-[":     {Mode: "tls"},
+					"lab-*":     {Mode: "mtls"},
+					"legacy-?":  {Mode: "insecure", CAFile: "/etc/lumen/ca.pem"},
+					"secure-01": {Mode: "tls", CertFile: "/etc/lumen/client.pem", ServerName: "spiffe://"},
+				}
+			},
+			want: []string{
+				`pool.per_node["secure-01"]: cert_file and key_file must be set together`,
+				`pool.per_node["secure-01"].cert_file: stat /etc/lumen/client.pem`,
+				`pool.per_node["secure-01"].server_name "spiffe://" is not a valid SPIFFE ID`,
+				`pool.per_node["legacy-?"]: ca_file, cert_file, key_file and server_name require mode tls`,
+				`pool.per_node["gpu-["]: invalid pattern`,
+				`pool.per_node["lab-*"].mode "mtls" must be insecure or tls`,
+			},
+		},
+		{
+			name: "bad chunk profile",
+			mutate: func(c *config2.Config) {
+				c.Chunk.Profiles = map[string]string{"text/": "sentences", "image/": config2.ChunkSplitBytes}
+			},
+			want: []string{
+				`chunk.profiles["text/"] must be one of bytes, runes, whitespace or json, got "sentences"`,
+			},
+		},
+		{
+			name: "bad selection strategy",
+			mutate: func(c *config2.Config) {
+				c.Pool.Strategy = "least_connections"
+				c.Pool.ScoreWeights = map[string]float64{"load": -1, "vram": 2, "custom": 1}
+			},
+			want: []string{
+				`pool.strategy "least_connections" must be round_robin, least_latency or custom`,
+				`pool.score_weights["load"] must be a non-negative number`,
+				`pool.score_weights: unknown score "vram" (want custom, load or latency)`,
+			},
+		},
+		{
+			name: "bad min healthy nodes",
+			mutate: func(c *config2.Config) {
+				c.Pool.MinHealthyNodes = map[string]int{"face_recognition": -1, " ": 2, "ocr": 0}
+			},
+			want: []string{
+				`pool.min_healthy_nodes: task name must not be empty`,
+				`pool.min_healthy_nodes["face_recognition"] must be non-negative`,
+			},
+		},
+		{
+			name:   "negative callback panic limit",
+			mutate: func(c *config2.Config) { c.Pool.CallbackPanicLimit = -1 },
+			want:   []string{"pool.callback_panic_limit must be non-negative"},
+		},
+		{
+			name: "bad outlier detection",
+			mutate: func(c *config2.Config) {
+				c.Pool.Outlier.Enabled = true
+				c.Pool.Outlier.Window = 5 * time.Second
+				c.Pool.Outlier.ErrorRateFactor = 1
+				c.Pool.Outlier.MaxEjectionPercent = 0
+				c.Pool.Outlier.ProbeFraction = 1.5
+			},
+			want: []string{
+				"pool.outlier.window (5s) must be at least pool.outlier.interval (10s)",
+				"pool.outlier.error_rate_factor must be a number above 1",
+				"pool.outlier.max_ejection_percent must be between 1 and 100",
+				"pool.outlier.probe_fraction must be above 0 and at most 1",
+			},
+		},
+		{
+			name: "bad usage accounting",
+			mutate: func(c *config2.Config) {
+				c.Usage.QuotaWindow = 48 * time.Hour
+				c.Usage.QuotaPolicy = "throttle"
+				c.Usage.Quotas = map[string]config2.TenantQuota{"team-a": {Tokens: -1}}
+			},
+			want: []string{
+				"usage.quota_window (48h0m0s) must not exceed usage.retention (24h0m0s)",
+				`usage.quota_policy "throttle" must be log or reject`,
+				`usage.quotas["team-a"]: limits must be non-negative`,
+			},
+		},
+		{
+			name: "malformed node filter patterns",
+			mutate: func(c *config2.Config) {
+				c.Discovery.AllowNodes = []string{"lab-*", "10.1.0.0/33"}
+				c.Discovery.DenyNodes = []string{"lab-[", "cluster=prod"}
+				c.Discovery.Push.AllowNodes = []string{"cluster="}
+			},
+			want: []string{
+				`discovery.allow_nodes entry "10.1.0.0/33" is not a valid CIDR`,
+				`discovery.deny_nodes entry "lab-[" is not a valid glob`,
+				`discovery.push.allow_nodes entry "cluster=" names no cluster`,
+			},
+		},
+		{
+			name: "broker url without scheme and bad log format",
+			mutate: func(c *config2.Config) {
+				c.Discovery.BrokerURL = "broker:5866"
+				c.Logging.Format = "xml"
+			},
+			want: []string{
+				`discovery.broker_url "broker:5866" must be an http:// or https:// URL`,
+				`logging.format "xml" must be json or text`,
+			},
+		},
+		{
+			name: "static nodes and broker host that do not parse",
+			mutate: func(c *config2.Config) {
+				c.Discovery.StaticNodes = []string{"[fd00::5]:50051", "node-1.lan:50051", "10.0.0.5", "http://node-2:50051", "node-3:70000"}
+				c.Broker.Enabled = true
+				c.Broker.Host = "hub.local:5866"
+			},
+			want: []string{
+				`discovery.static_nodes entry must be host:port or [v6]:port: address "10.0.0.5": missing port`,
+				`address "http://node-2:50051": invalid address`,
+				`address "node-3:70000": invalid port "70000"`,
+				`broker.host must be a hostname or IP address: address "hub.local:5866": invalid port "5866"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config2.DefaultConfig()
+			tt.mutate(cfg)
+			var list config2.ValidationErrors
+			if err := cfg.Validate(); !errors.As(err, &list) {
+				t.Fatalf("Validate() = %v, want ValidationErrors", err)
+			}
+			if len(list) != len(tt.want) {
+				t.Fatalf("got %d errors %v, want %d", len(list), list, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(list[i].Error(), want) {
+					t.Errorf("error %d = %q, want it to contain %q", i, list[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolNodeTransport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lumen.yaml")
+	if err := os.WriteFile(path, []byte(`
+pool:
+  tls:
+    mode: insecure
+  per_node:
+    "lab-*":
+      mode: tls
+      server_name: lab.example
+    "lab-gpu-*":
+      mode: tls
+      server_name: spiffe://lab.example/gpu
+    lab-legacy:
+      mode: insecure
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config2.LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		ids         []string
+		wantPattern string
+		wantServer  string
+	}{
+		{[]string{"lab-3", "local-lab-3"}, "lab-*", "lab.example"},
+		{[]string{"lab-gpu-1", "local-lab-gpu-1"}, "lab-gpu-*", "spiffe://lab.example/gpu"},
+		{[]string{"lab-legacy", "local-lab-legacy"}, "lab-legacy", ""},
+		{[]string{"edge-1", "local-edge-1"}, "", ""},
+	}
+	for _, tt := range tests {
+		got, pattern, matched := cfg.Pool.NodeTransport(tt.ids...)
+		if pattern != tt.wantPattern || matched != (tt.wantPattern != "") || got.ServerName != tt.wantServer {
+			t.Errorf("NodeTransport(%v) = %+v, %q, %v; want pattern %q", tt.ids, got, pattern, matched, tt.wantPattern)
+		}
+	}
+	if got, _, _ := cfg.Pool.NodeTransport("edge-1"); got.IsTLS() {
+		t.Errorf("unmatched node got %+v, want the insecure global setting", got)
+	}
+}
+
+func TestLocateAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+
+	cfg, path, err := config2.LocateAndLoad("")
+	if err != nil || path != "" {
+		t.Fatalf("LocateAndLoad with no files = %q, %v; want defaults", path, err)
+	}
+	if cfg.Broker.Port != config2.DefaultConfig().Broker.Port {
+		t.Errorf("expected default config, got broker %+v", cfg.Broker)
+	}
+
+	xdgPath := filepath.Join(dir, "xdg", "lumen", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(xdgPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(xdgPath, []byte("broker:\n  port: 7001\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, path, err = config2.LocateAndLoad("")
+	if err != nil || path != xdgPath || cfg.Broker.Port != 7001 {
+		t.Fatalf("LocateAndLoad = port %d from %q, %v; want 7001 from %q", cfg.Broker.Port, path, err, xdgPath)
+	}
+
+	// ./lumen.yaml takes precedence over the XDG location.
+	if err := os.WriteFile("lumen.yaml", []byte("broker:\n  port: 7002\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, path, err = config2.LocateAndLoad("")
+	if err != nil || path != "lumen.yaml" || cfg.Broker.Port != 7002 {
+		t.Fatalf("LocateAndLoad = port %d from %q, %v; want 7002 from lumen.yaml", cfg.Broker.Port, path, err)
+	}
+
+	cfg, path, err = config2.LocateAndLoad(xdgPath)
+	if err != nil || path != xdgPath || cfg.Broker.Port != 7001 {
+		t.Fatalf("explicit path = port %d from %q, %v; want 7001", cfg.Broker.Port, path, err)
+	}
+	if _, _, err := config2.LocateAndLoad(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Fatal("expected an error for a missing explicit path")
+	}
+}
+
+func TestSaveConfigWritesComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lumen.yaml")
+	if err := config2.DefaultConfig().SaveConfig(path); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# Host Broker control plane\nbroker:") {
+		t.Errorf("saved config lacks field comments:\n%s", data)
+	}
 }
@@ -0,0 +1,166 @@
+// Package fixtures is the corpus of node responses the typed parsers are
+// tested against. Each file under responses/ is one InferResponse as a node
+// sent it, captured with tools/fixturecapture so the corpus tracks what
+// nodes actually emit rather than what the SDK expects them to.
+//
+// To refresh the corpus from a running node:
+//
+//	LUMEN_FIXTURE_NODE=127.0.0.1:50051 go generate ./test/fixtures
+package fixtures
+
+//go:generate go run ../../tools/fixturecapture -manifest capture.json -out responses
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+//go:embed responses/*.json
+var responses embed.FS
+
+//go:embed images
+var images embed.FS
+
+// Fixture is one captured node response. JSON results are stored verbatim
+// under result so diffs of a refreshed corpus stay readable; any other
+// result, such as audio, is stored base64-encoded under result_base64.
+type Fixture struct {
+	// Name is the file name under responses/ without ".json".
+	Name string `json:"-"`
+	// Task is the task the request was sent for.
+	Task string `json:"task"`
+	// Service is the node service that answered, when known.
+	Service    string    `json:"service,omitempty"`
+	CapturedAt time.Time `json:"captured_at,omitempty"`
+
+	ResultMime   string            `json:"result_mime,omitempty"`
+	ResultSchema string            `json:"result_schema,omitempty"`
+	Meta         map[string]string `json:"meta,omitempty"`
+	Result       json.RawMessage   `json:"result,omitempty"`
+	ResultBase64 []byte            `json:"result_base64,omitempty"`
+	Error        *Error            `json:"error,omitempty"`
+}
+
+// Error is the error a node reported in place of a result.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// Names returns the names of every fixture in the corpus, sorted.
+func Names() []string {
+	entries, _ := responses.ReadDir("responses")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load returns the fixture with name.
+func Load(name string) (*Fixture, error) {
+	data, err := responses.ReadFile(path.Join("responses", name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", name, err)
+	}
+	f := &Fixture{Name: name}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", name, err)
+	}
+	if len(f.Result) > 0 && len(f.ResultBase64) > 0 {
+		return nil, fmt.Errorf("fixture %s: has both result and result_base64", name)
+	}
+	return f, nil
+}
+
+// All loads every fixture in the corpus, in name order.
+func All() ([]*Fixture, error) {
+	var out []*Fixture
+	for _, name := range Names() {
+		f, err := Load(name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// Response returns the fixture as the final InferResponse the node sent.
+func (f *Fixture) Response() *pb.InferResponse {
+	resp := &pb.InferResponse{
+		CorrelationId: f.Name,
+		IsFinal:       true,
+		ResultMime:    f.ResultMime,
+		ResultSchema:  f.ResultSchema,
+		Meta:          make(map[string]string, len(f.Meta)),
+	}
+	for k, v := range f.Meta {
+		resp.Meta[k] = v
+	}
+	if len(f.Result) > 0 {
+		resp.Result = append([]byte(nil), f.Result...)
+	} else if len(f.ResultBase64) > 0 {
+		resp.Result = append([]byte(nil), f.ResultBase64...)
+	}
+	if f.Error != nil {
+		resp.Error = &pb.Error{
+			Code:    pb.ErrorCode(pb.ErrorCode_value[f.Error.Code]),
+			Message: f.Error.Message,
+			Detail:  f.Error.Detail,
+		}
+	}
+	return resp
+}
+
+// FromResponse records resp, the answer to a request for task, as the
+// fixture name.
+func FromResponse(name, task string, resp *pb.InferResponse) *Fixture {
+	f := &Fixture{
+		Name:         name,
+		Task:         task,
+		CapturedAt:   time.Now().UTC().Truncate(time.Second),
+		ResultMime:   resp.GetResultMime(),
+		ResultSchema: resp.GetResultSchema(),
+		Meta:         resp.GetMeta(),
+	}
+	if result := resp.GetResult(); len(result) > 0 {
+		if strings.Contains(f.ResultMime, "json") && json.Valid(result) {
+			f.Result = append(json.RawMessage(nil), result...)
+		} else {
+			f.ResultBase64 = append([]byte(nil), result...)
+		}
+	}
+	if e := resp.GetError(); e != nil {
+		f.Error = &Error{Code: e.GetCode().String(), Message: e.GetMessage(), Detail: e.GetDetail()}
+	}
+	return f
+}
+
+// Image returns the test image images/name, e.g. "photo.png". The images
+// are generated rather than captured; see README.md.
+func Image(name string) ([]byte, error) {
+	data, err := images.ReadFile(path.Join("images", name))
+	if err != nil {
+		return nil, fmt.Errorf("image fixture %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// Marshal encodes f as it is stored under responses/.
+func (f *Fixture) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
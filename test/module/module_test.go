@@ -0,0 +1,127 @@
+// Package module_test checks that the SDK builds as one module under its
+// canonical path, the way an external consumer sees it after
+//
+//	go get github.com/edwinzhancn/lumen-sdk/pkg/client
+package module_test
+
+import (
+	"bufio"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	// Every public package, by the path consumers import it with.
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/client"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/client/clientmock"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/client/sim"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/config"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/hostbroker"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/lumen"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/tasks"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/types"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/vectorstore"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/vectorstore/memstore"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/vectorstore/reststore"
+	_ "github.com/edwinzhancn/lumen-sdk/pkg/version"
+	_ "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+const modulePath = "github.com/edwinzhancn/lumen-sdk"
+
+// moduleRoot is the directory of the SDK's go.mod.
+func moduleRoot(t *testing.T) string {
+	t.Helper()
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestGoModDeclaresCanonicalPath(t *testing.T) {
+	f, err := os.Open(filepath.Join(moduleRoot(t), "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if mod, ok := strings.CutPrefix(strings.TrimSpace(sc.Text()), "module "); ok {
+			if mod = strings.Trim(strings.TrimSpace(mod), `"`); mod != modulePath {
+				t.Fatalf("go.mod declares module %q, want %q", mod, modulePath)
+			}
+			return
+		}
+	}
+	t.Fatal("go.mod has no module directive")
+}
+
+// TestImportsUseCanonicalPath fails on any import that is neither in the
+// standard library nor a dotted module path, such as "Lumen-SDK/pkg/client",
+// which only resolves with a replace directive.
+func TestImportsUseCanonicalPath(t *testing.T) {
+	root := moduleRoot(t)
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "testdata" || d.Name() == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, imp := range f.Imports {
+			p, _ := strconv.Unquote(imp.Path.Value)
+			if strings.Contains(strings.SplitN(p, "/", 2)[0], ".") || p == "C" || isStdlib(p) {
+				continue
+			}
+			rel, _ := filepath.Rel(root, path)
+			t.Errorf("%s imports %q; use %s/...", rel, p, modulePath)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func isStdlib(path string) bool {
+	pkg, err := build.Default.Import(path, "", build.FindOnly)
+	return err == nil && pkg.Goroot
+}
+
+// TestModuleBuilds builds cmd/, pkg/ and examples/ together, as a consumer
+// of the module would.
+func TestModuleBuilds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the whole module")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not on PATH")
+	}
+	cmd := exec.Command(goTool, "build", "./...")
+	cmd.Dir = moduleRoot(t)
+	cmd.Env = append(os.Environ(), "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build ./...: %v\n%s", err, out)
+	}
+}
@@ -1,7 +1,13 @@
 package client_test
 
 import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/quick"
+	"unicode/utf8"
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/client"
 	"github.com/edwinzhancn/lumen-sdk/pkg/config"
@@ -252,3 +258,189 @@ func TestChunkPayloadNegativeMaxChunkBytes(t *testing.T) {
 		t.Error("Expected error for negative MaxChunkBytes, got nil")
 	}
 }
+
+// TestPlanChunksBoundaries checks PlanChunks predicts ChunkPayload exactly
+// around the threshold and the max chunk size.
+func TestPlanChunksBoundaries(t *testing.T) {
+	cfgs := []config.ChunkConfig{
+		{EnableAuto: true, Threshold: 1024, MaxChunkBytes: 256},
+		{EnableAuto: true, Threshold: 256, MaxChunkBytes: 1024},
+		{EnableAuto: true, Threshold: 0, MaxChunkBytes: 100},
+		{EnableAuto: false, Threshold: 1024, MaxChunkBytes: 256},
+	}
+	for _, cfg := range cfgs {
+		var sizes []int
+		for _, edge := range []int{0, cfg.Threshold, cfg.MaxChunkBytes, 2 * cfg.MaxChunkBytes, 4 * cfg.Threshold} {
+			for _, d := range []int{-1, 0, 1} {
+				if edge+d >= 0 {
+					sizes = append(sizes, edge+d)
+				}
+			}
+		}
+		for _, size := range sizes {
+			chunks, err := client.ChunkPayload(make([]byte, size), cfg)
+			if err != nil {
+				t.Fatalf("ChunkPayload(%d, %+v) error = %v", size, cfg, err)
+			}
+			info, err := client.PlanChunks(size, cfg)
+			if err != nil {
+				t.Fatalf("PlanChunks(%d, %+v) error = %v", size, cfg, err)
+			}
+			if info.Chunks != len(chunks) || info.ChunkBytes != len(chunks[0]) || info.PayloadBytes != size {
+				t.Errorf("PlanChunks(%d, %+v) = %+v, ChunkPayload gave %d chunks of %d", size, cfg, info, len(chunks), len(chunks[0]))
+			}
+			if info.Chunked() != (size > cfg.Threshold && size > cfg.MaxChunkBytes && cfg.EnableAuto) {
+				t.Errorf("PlanChunks(%d, %+v).Chunked() = %v", size, cfg, info.Chunked())
+			}
+		}
+	}
+
+	if _, err := client.PlanChunks(10, config.ChunkConfig{EnableAuto: true}); err == nil {
+		t.Error("expected an error for MaxChunkBytes 0")
+	}
+}
+
+func TestPreviewChunking(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Chunk = config.ChunkConfig{EnableAuto: true, Threshold: 1000, MaxChunkBytes: 300}
+	c, err := client.NewLumenClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("NewLumenClient() error = %v", err)
+	}
+	tests := []struct{ size, chunks, chunkBytes int }{
+		{1000, 1, 1000},
+		{1001, 4, 300},
+		{1200, 4, 300},
+		{1201, 5, 300},
+	}
+	for _, tt := range tests {
+		if chunks, chunkBytes := c.PreviewChunking(tt.size); chunks != tt.chunks || chunkBytes != tt.chunkBytes {
+			t.Errorf("PreviewChunking(%d) = %d, %d; want %d, %d", tt.size, chunks, chunkBytes, tt.chunks, tt.chunkBytes)
+		}
+	}
+}
+
+// TestChunkPayloadExactMultiple checks a payload of whole chunks ends
+// without an empty trailing chunk.
+func TestChunkPayloadExactMultiple(t *testing.T) {
+	cfg := config.ChunkConfig{EnableAuto: true, MaxChunkBytes: 256}
+	chunks, err := client.ChunkPayload(make([]byte, 4*256), cfg)
+	if err != nil {
+		t.Fatalf("ChunkPayload() error = %v", err)
+	}
+	if len(chunks) != 4 {
+		t.Fatalf("got %d chunks, want 4", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if len(chunk) != 256 {
+			t.Errorf("chunk %d has %d bytes, want 256", i, len(chunk))
+		}
+	}
+}
+
+func TestChunkPayloadForTextKeepsCharacters(t *testing.T) {
+	cfg := config.ChunkConfig{
+		EnableAuto:    true,
+		MaxChunkBytes: 10,
+		Profiles:      map[string]string{"text/": config.ChunkSplitRunes},
+	}
+	payload := []byte(strings.Repeat("héllo wörld 日本語 ", 20))
+
+	chunks, err := client.ChunkPayloadFor(payload, "text/plain; charset=utf-8", cfg)
+	if err != nil {
+		t.Fatalf("ChunkPayloadFor() error = %v", err)
+	}
+	for i, chunk := range chunks {
+		if !utf8.Valid(chunk) || len(chunk) > cfg.MaxChunkBytes || len(chunk) == 0 {
+			t.Fatalf("chunk %d = %q, want 1 to %d bytes of whole characters", i, chunk, cfg.MaxChunkBytes)
+		}
+	}
+
+	// Binary payloads keep splitting by bytes.
+	bin, err := client.ChunkPayloadFor(payload, "image/png", cfg)
+	if err != nil {
+		t.Fatalf("ChunkPayloadFor() error = %v", err)
+	}
+	if want := (len(payload) + 9) / 10; len(bin) != want {
+		t.Errorf("image payload: got %d chunks, want %d", len(bin), want)
+	}
+}
+
+func TestChunkPayloadForWhitespace(t *testing.T) {
+	cfg := config.ChunkConfig{
+		EnableAuto:    true,
+		MaxChunkBytes: 12,
+		Profiles: map[string]string{
+			"text/":         config.ChunkSplitRunes,
+			"text/markdown": config.ChunkSplitWhitespace,
+		},
+	}
+	payload := []byte("hello world\nsplit at a space ok")
+
+	chunks, err := client.ChunkPayloadFor(payload, "text/markdown", cfg)
+	if err != nil {
+		t.Fatalf("ChunkPayloadFor() error = %v", err)
+	}
+	want := []string{"hello world\n", "split at a ", "space ok"}
+	var got []string
+	for _, chunk := range chunks {
+		got = append(got, string(chunk))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("chunks = %q, want %q", got, want)
+	}
+}
+
+// TestChunkPayloadForReassembles checks, for random payloads, sizes and
+// profiles, that the chunks concatenate to the payload, that none is empty
+// or over MaxChunkBytes, and that text chunks hold whole characters.
+func TestChunkPayloadForReassembles(t *testing.T) {
+	profiles := map[string]string{
+		"text/plain":       config.ChunkSplitRunes,
+		"text/":            config.ChunkSplitWhitespace,
+		"application/json": config.ChunkSplitJSON,
+	}
+	mimes := []string{"text/plain", "text/csv", "application/json", "image/jpeg", "application/octet-stream", ""}
+	alphabet := []rune("ab \n{}\"é日🙂")
+
+	property := func(seed int64, size uint16, maxChunk uint8, binary bool) bool {
+		rng := rand.New(rand.NewSource(seed))
+		mime := mimes[rng.Intn(len(mimes))]
+		var payload []byte
+		if binary {
+			payload = make([]byte, int(size)%4096)
+			rng.Read(payload)
+		} else {
+			var sb strings.Builder
+			for sb.Len() < int(size)%4096 {
+				sb.WriteRune(alphabet[rng.Intn(len(alphabet))])
+			}
+			payload = []byte(sb.String())
+		}
+		cfg := config.ChunkConfig{EnableAuto: true, MaxChunkBytes: int(maxChunk)%64 + 1, Profiles: profiles}
+
+		chunks, err := client.ChunkPayloadFor(payload, mime, cfg)
+		if err != nil {
+			t.Logf("ChunkPayloadFor(%d bytes, %q, %d) error = %v", len(payload), mime, cfg.MaxChunkBytes, err)
+			return false
+		}
+		if !bytes.Equal(bytes.Join(chunks, nil), payload) {
+			t.Logf("chunks of %d bytes as %q do not reassemble", len(payload), mime)
+			return false
+		}
+		for _, chunk := range chunks {
+			if len(chunk) > cfg.MaxChunkBytes || (len(chunk) == 0 && len(payload) > 0) {
+				t.Logf("chunk of %d bytes under max %d", len(chunk), cfg.MaxChunkBytes)
+				return false
+			}
+			if strings.HasPrefix(mime, "text/") && !binary && cfg.MaxChunkBytes >= utf8.UTFMax && !utf8.Valid(chunk) {
+				t.Logf("%q chunk %q splits a character", mime, chunk)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,114 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+)
+
+// TestLoadFileRejectsMalformedFixtures pins the message LoadFile gives for
+// each file in testdata/invalid. Every fixture must have an entry, so a new
+// one cannot go unchecked.
+func TestLoadFileRejectsMalformedFixtures(t *testing.T) {
+	want := map[string][]string{
+		"unknown_key.yaml":      {"line 3: discovery.load_balencer: unknown key"},
+		"misspelled_field.yaml": {"line 2: discovery.scan_intervall: unknown key (did you mean scan_interval?)"},
+		"unknown_section.yaml":  {"line 1: cache: unknown key"},
+		"per_node_unknown_key.yaml": {
+			"line 5: pool.per_node.gpu-*.ca: unknown key",
+		},
+		"bad_duration.yaml": {
+			`line 2: discovery.scan_interval: invalid duration "5minutes" (want a number with a unit, e.g. 500ms, 30s or 5m)`,
+		},
+		"duration_without_unit.yaml": {
+			`line 2: broker.read_timeout: invalid duration "30" (want a number with a unit, e.g. 500ms, 30s or 5m)`,
+		},
+		"bad_size.yaml": {
+			`line 2: chunk.max_chunk_bytes: invalid integer "1MB" (sizes and counts are plain integers, e.g. 1048576)`,
+		},
+		"bad_bool.yaml":            {`line 2: broker.docs: invalid boolean "maybe" (want true or false)`},
+		"bad_port.yaml":            {`line 2: broker.port: invalid integer "http"`},
+		"section_not_mapping.yaml": {`line 1: logging: expected a mapping of settings, got "debug"`},
+		"list_expected.yaml":       {`line 2: discovery.static_nodes: expected a list, got "10.0.0.5:50051"`},
+		"several_problems.yaml": {
+			`line 2: discovery.enabled: invalid boolean "yes please" (want true or false)`,
+			`line 3: discovery.notify_window: invalid duration "soon" (want a number with a unit, e.g. 500ms, 30s or 5m)`,
+			"line 5: logging.levle: unknown key (did you mean level?)",
+		},
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata", "invalid", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range files {
+		name := filepath.Base(path)
+		if name == "syntax_error.yaml" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			msgs, ok := want[name]
+			if !ok {
+				t.Fatalf("no expected messages for %s", name)
+			}
+			_, err := config.LoadFile(path)
+			var problems config.ValidationErrors
+			if !errors.As(err, &problems) {
+				t.Fatalf("LoadFile error = %v, want a ValidationErrors", err)
+			}
+			if len(problems) != len(msgs) {
+				t.Fatalf("got %d problems, want %d: %v", len(problems), len(msgs), err)
+			}
+			for i, p := range problems {
+				if p.Error() != msgs[i] {
+					t.Errorf("problem %d:\n got: %s\nwant: %s", i, p, msgs[i])
+				}
+			}
+			if !strings.HasPrefix(err.Error(), "parse config "+path+": ") {
+				t.Errorf("error %q does not name the file", err)
+			}
+		})
+	}
+}
+
+func TestLoadFileReportsSyntaxErrors(t *testing.T) {
+	_, err := config.LoadFile(filepath.Join("testdata", "invalid", "syntax_error.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("LoadFile error = %v, want a syntax error naming line 2", err)
+	}
+}
+
+// TestExampleConfigsAreStrict keeps the shipped example configs loadable
+// as fields are renamed.
+func TestExampleConfigsAreStrict(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "..", "examples", "configs", "*.yaml"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example configs found: %v", err)
+	}
+	for _, path := range files {
+		if _, err := config.LoadFile(path); err != nil {
+			t.Errorf("LoadFile(%s): %v", path, err)
+		}
+	}
+}
+
+func TestSavedConfigLoadsStrictly(t *testing.T) {
+	for _, name := range config.PresetNames() {
+		cfg, err := config.PresetConfig(name)
+		if err != nil {
+			t.Fatalf("PresetConfig(%s): %v", name, err)
+		}
+		path := filepath.Join(t.TempDir(), name+".yaml")
+		if err := cfg.SaveConfig(path); err != nil {
+			t.Fatalf("SaveConfig: %v", err)
+		}
+		if _, err := config.LoadFile(path); err != nil {
+			data, _ := os.ReadFile(path)
+			t.Fatalf("LoadFile of saved %s preset: %v\n%s", name, err, data)
+		}
+	}
+}
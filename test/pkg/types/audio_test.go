@@ -0,0 +1,69 @@
+package types_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+func TestParseInferResponseAsAudioResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		mime     string
+		meta     map[string]string
+		wantMime string
+		wantRate int
+	}{
+		{name: "wav", mime: "audio/wav", meta: map[string]string{"model_id": "kokoro"}, wantMime: "audio/wav"},
+		{name: "pcm rate param", mime: "audio/pcm;rate=24000", wantMime: "audio/pcm", wantRate: 24000},
+		{name: "rate from meta", mime: "audio/pcm", meta: map[string]string{"sample_rate": "16000"}, wantMime: "audio/pcm", wantRate: 16000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte("RIFF....WAVEfmt ")
+			resp := &pb.InferResponse{Result: data, ResultMime: tt.mime, Meta: tt.meta}
+			audio, err := types.ParseInferResponse(resp).AsAudioResponse()
+			if err != nil {
+				t.Fatalf("AsAudioResponse() error = %v", err)
+			}
+			if !bytes.Equal(audio.Audio, data) || audio.Mime != tt.wantMime || audio.SampleRate != tt.wantRate {
+				t.Fatalf("audio = %+v, want mime %q rate %d", audio, tt.wantMime, tt.wantRate)
+			}
+			if audio.ModelID != tt.meta["model_id"] {
+				t.Errorf("ModelID = %q, want %q", audio.ModelID, tt.meta["model_id"])
+			}
+			data[0] = 'X'
+			if audio.Audio[0] != 'R' {
+				t.Error("audio aliases the response buffer")
+			}
+		})
+	}
+}
+
+func TestParseInferResponseAsAudioResponseRejects(t *testing.T) {
+	for _, resp := range []*pb.InferResponse{
+		{Result: []byte("{}"), ResultMime: "application/json;schema=embedding_v1"},
+		{Result: nil, ResultMime: "audio/wav"},
+	} {
+		if _, err := types.ParseInferResponse(resp).AsAudioResponse(); err == nil {
+			t.Errorf("AsAudioResponse(%q, %d bytes) succeeded, want error", resp.ResultMime, len(resp.Result))
+		}
+	}
+}
+
+func TestAudioDataURL(t *testing.T) {
+	tests := []struct {
+		audio types.AudioV1
+		want  string
+	}{
+		{types.AudioV1{Audio: []byte("RIFF"), Mime: "audio/wav"}, "data:audio/wav;base64,UklGRg=="},
+		{types.AudioV1{Audio: []byte{0, 1}, Mime: "audio/pcm", SampleRate: 24000}, "data:audio/pcm;rate=24000;base64,AAE="},
+	}
+	for _, tt := range tests {
+		if got := tt.audio.DataURL(); got != tt.want {
+			t.Errorf("DataURL() = %q, want %q", got, tt.want)
+		}
+	}
+}
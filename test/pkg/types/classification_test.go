@@ -2,9 +2,11 @@ package types_test
 
 import (
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
 )
 
 func TestLabelsV1TopK(t *testing.T) {
@@ -309,3 +311,56 @@ func TestLabelsV1TopKScoreOrdering(t *testing.T) {
 		}
 	}
 }
+
+func TestClassificationOptionValidation(t *testing.T) {
+	payload := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46}
+	tests := []struct {
+		name     string
+		opts     []types.ClassificationOption
+		wantErrs []string
+	}{
+		{name: "top k 1", opts: []types.ClassificationOption{types.WithTopK(1)}},
+		{name: "top k unset", opts: []types.ClassificationOption{types.WithTopK(0)}},
+		{name: "negative top k", opts: []types.ClassificationOption{types.WithTopK(-1)}, wantErrs: []string{"top_k"}},
+		{name: "threshold clamped", opts: []types.ClassificationOption{types.WithClassificationConfidenceThreshold(7)}},
+		{
+			name:     "strict reports every problem",
+			opts:     []types.ClassificationOption{types.WithStrictClassificationValidation(), types.WithTopK(-3), types.WithClassificationConfidenceThreshold(7)},
+			wantErrs: []string{"top_k", "confidence_threshold"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := types.NewClassificationRequest(payload, tt.opts...)
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("NewClassificationRequest: %v", err)
+				}
+				if req.ConfidenceThreshold > 1 {
+					t.Fatalf("threshold = %g, want clamped to 1", req.ConfidenceThreshold)
+				}
+				return
+			}
+			if !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+				t.Fatalf("error = %v, want INVALID", err)
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestForClassificationSetsOptionMeta(t *testing.T) {
+	payload := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46}
+	classReq, err := types.NewClassificationRequest(payload, types.WithTopK(3), types.WithClassificationConfidenceThreshold(0.25))
+	if err != nil {
+		t.Fatalf("NewClassificationRequest: %v", err)
+	}
+	req := types.NewInferRequest("classify").ForClassification(classReq, "classify").Build()
+	if req.Meta[types.MetaTopK] != "3" || req.Meta["confidence_threshold"] != "0.250" {
+		t.Fatalf("meta = %v, want top_k 3 and confidence_threshold 0.250", req.Meta)
+	}
+}
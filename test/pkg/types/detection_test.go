@@ -1,9 +1,12 @@
 package types_test
 
 import (
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
 )
 
 func TestNewFaceRecognitionRequestBasic(t *testing.T) {
@@ -329,3 +332,59 @@ func TestFaceOptionalFields(t *testing.T) {
 		t.Error("Expected Embedding to be empty for optional field")
 	}
 }
+
+func TestFaceRecognitionOptionValidation(t *testing.T) {
+	payload := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46}
+	nan := float32(math.NaN())
+	tests := []struct {
+		name       string
+		opts       []types.FaceRecognitionOption
+		wantErrs   []string // substrings, one per expected problem
+		wantThresh float32
+		wantNms    float32
+	}{
+		{name: "thresholds at bounds", opts: []types.FaceRecognitionOption{types.WithDetectionConfidenceThreshold(1), types.WithNmsThreshold(0)}, wantThresh: 1},
+		{name: "threshold above 1 clamped", opts: []types.FaceRecognitionOption{types.WithDetectionConfidenceThreshold(1.5), types.WithNmsThreshold(2)}, wantThresh: 1, wantNms: 1},
+		{name: "negative threshold clamped", opts: []types.FaceRecognitionOption{types.WithDetectionConfidenceThreshold(-0.2)}, wantThresh: 0},
+		{
+			name:     "threshold above 1 strict",
+			opts:     []types.FaceRecognitionOption{types.WithStrictFaceValidation(), types.WithDetectionConfidenceThreshold(1.5), types.WithNmsThreshold(1.01)},
+			wantErrs: []string{"detection_confidence_threshold", "nms_threshold"},
+		},
+		{name: "NaN threshold", opts: []types.FaceRecognitionOption{types.WithNmsThreshold(nan)}, wantErrs: []string{"nms_threshold"}},
+		{name: "max faces -1", opts: []types.FaceRecognitionOption{types.WithMaxFaces(-1)}},
+		{name: "max faces 1", opts: []types.FaceRecognitionOption{types.WithMaxFaces(1)}},
+		{name: "max faces -2", opts: []types.FaceRecognitionOption{types.WithMaxFaces(-2)}, wantErrs: []string{"max_faces"}},
+		{name: "negative face size", opts: []types.FaceRecognitionOption{types.WithFaceSizeMin(-1)}, wantErrs: []string{"face_size_min"}},
+		{name: "min below max", opts: []types.FaceRecognitionOption{types.WithFaceSizeMin(10), types.WithFaceSizeMax(11)}},
+		{name: "min equal to max", opts: []types.FaceRecognitionOption{types.WithFaceSizeMin(10), types.WithFaceSizeMax(10)}, wantErrs: []string{"below face_size_max"}},
+		{name: "only max set", opts: []types.FaceRecognitionOption{types.WithFaceSizeMax(5)}},
+		{
+			name:     "every problem reported",
+			opts:     []types.FaceRecognitionOption{types.WithMaxFaces(-5), types.WithFaceSizeMax(-3), types.WithFaceSizeMin(20), types.WithStrictFaceValidation(), types.WithNmsThreshold(3)},
+			wantErrs: []string{"max_faces", "face_size_max must be non-negative", "nms_threshold"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := types.NewFaceRecognitionRequest(payload, tt.opts...)
+			if len(tt.wantErrs) > 0 {
+				if !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+					t.Fatalf("error = %v, want INVALID", err)
+				}
+				for _, want := range tt.wantErrs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewFaceRecognitionRequest: %v", err)
+			}
+			if req.DetectionConfidenceThreshold != tt.wantThresh || req.NmsThreshold != tt.wantNms {
+				t.Fatalf("thresholds = %g, %g; want %g, %g", req.DetectionConfidenceThreshold, req.NmsThreshold, tt.wantThresh, tt.wantNms)
+			}
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package types_test
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"image"
+	"image/jpeg"
 	"math"
 	"strings"
 	"testing"
@@ -449,3 +453,52 @@ func TestEmbeddingV1AestheticScoreJSON(t *testing.T) {
 		t.Errorf("nil aesthetic score should be omitted, got %s", b)
 	}
 }
+
+func TestDetectEmbeddingInput(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	jpegB64 := base64.StdEncoding.EncodeToString(jpg.Bytes())
+	textB64 := base64.StdEncoding.EncodeToString([]byte("a photo of a cat"))
+
+	tests := []struct {
+		name         string
+		input        string
+		preferBinary bool
+		wantMime     string
+		wantPayload  []byte
+		wantErr      bool
+	}{
+		{name: "plain text", input: "a photo of a cat", wantMime: "text/plain", wantPayload: []byte("a photo of a cat")},
+		{name: "base64 jpeg", input: jpegB64, wantMime: "image/jpeg", wantPayload: jpg.Bytes()},
+		{name: "base64 jpeg preferring binary", input: jpegB64, preferBinary: true, wantMime: "image/jpeg", wantPayload: jpg.Bytes()},
+		{name: "base64 text is literal", input: textB64, wantMime: "text/plain", wantPayload: []byte(textB64)},
+		{name: "base64 text preferring binary", input: textB64, preferBinary: true, wantMime: "text/plain", wantPayload: []byte("a photo of a cat")},
+		{name: "malformed base64", input: "aGVsbG8=!", wantMime: "text/plain", wantPayload: []byte("aGVsbG8=!")},
+		{name: "short word that decodes to bytes", input: "test", wantMime: "text/plain", wantPayload: []byte("test")},
+		{name: "unsupported binary preferring binary", input: "test", preferBinary: true, wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := types.DetectEmbeddingInput(tt.input, tt.preferBinary)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("DetectEmbeddingInput = %+v, want an error", req)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DetectEmbeddingInput: %v", err)
+			}
+			if req.PayloadMime != tt.wantMime {
+				t.Errorf("mime = %q, want %q", req.PayloadMime, tt.wantMime)
+			}
+			if !bytes.Equal(req.Payload, tt.wantPayload) {
+				t.Errorf("payload = %q, want %q", req.Payload, tt.wantPayload)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package types_test
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"github.com/edwinzhancn/lumen-sdk/test/fixtures"
+)
+
+// fixtureParser is one typed parser, with the checks every value it
+// returns must pass beyond being fully populated.
+type fixtureParser struct {
+	parse func(*types.InferResponseParser) (any, error)
+	check func(t *testing.T, v any)
+}
+
+var fixtureParsers = map[string]fixtureParser{
+	"embedding": {
+		parse: func(p *types.InferResponseParser) (any, error) { return p.AsEmbeddingResponse() },
+		check: func(t *testing.T, v any) {
+			e := v.(*types.EmbeddingV1)
+			if e.Dim != len(e.Vector) {
+				t.Errorf("dim %d for a vector of %d", e.Dim, len(e.Vector))
+			}
+		},
+	},
+	"classification": {
+		parse: func(p *types.InferResponseParser) (any, error) { return p.AsClassificationResponse() },
+		check: func(t *testing.T, v any) {
+			l := v.(*types.LabelsV1)
+			for i, label := range l.Labels {
+				if label.Score < 0 || label.Score > 1 {
+					t.Errorf("label %q score %v outside [0, 1]", label.Label, label.Score)
+				}
+				if i > 0 && label.Score > l.Labels[i-1].Score {
+					t.Errorf("labels not sorted by score: %+v", l.Labels)
+				}
+			}
+		},
+	},
+	"face": {
+		parse: func(p *types.InferResponseParser) (any, error) { return p.AsFaceResponse() },
+		check: func(t *testing.T, v any) {
+			f := v.(*types.FaceV1)
+			if f.Count != len(f.Faces) {
+				t.Errorf("count %d for %d faces", f.Count, len(f.Faces))
+			}
+			for i, face := range f.Faces {
+				if len(face.BBox) != 4 || face.BBox[2] <= face.BBox[0] || face.BBox[3] <= face.BBox[1] {
+					t.Errorf("face %d bbox %v is not [x1, y1, x2, y2]", i, face.BBox)
+				}
+				if len(face.Landmarks) != 0 && len(face.Landmarks) != 10 {
+					t.Errorf("face %d has %d landmark coordinates, want 5 points", i, len(face.Landmarks))
+				}
+			}
+		},
+	},
+	"ocr": {
+		parse: func(p *types.InferResponseParser) (any, error) { return p.AsOCRResponse() },
+		check: func(t *testing.T, v any) {
+			o := v.(*types.OCRV1)
+			if o.Count != len(o.Items) {
+				t.Errorf("count %d for %d items", o.Count, len(o.Items))
+			}
+			for i, item := range o.Items {
+				if len(item.Box) < 4 {
+					t.Errorf("item %d box has %d points", i, len(item.Box))
+				}
+				for _, pt := range item.Box {
+					if len(pt) != 2 {
+						t.Errorf("item %d box point %v is not [x, y]", i, pt)
+					}
+				}
+			}
+		},
+	},
+	"text_generation": {
+		parse: func(p *types.InferResponseParser) (any, error) { return p.AsTextGenerationResponse() },
+		check: func(t *testing.T, v any) {
+			if g := v.(*types.TextGenerationV1); !g.IsValidFinishReason() {
+				t.Errorf("finish reason %q is not one of %v", g.FinishReason, types.ValidFinishReasons)
+			}
+		},
+	},
+	"audio": {
+		parse: func(p *types.InferResponseParser) (any, error) { return p.AsAudioResponse() },
+		check: func(t *testing.T, v any) {
+			if a := v.(*types.AudioV1); !strings.HasPrefix(a.Mime, "audio/") || strings.Contains(a.Mime, ";") {
+				t.Errorf("mime %q is not a bare audio type", a.Mime)
+			}
+		},
+	},
+}
+
+// fixtureCases names the parser each fixture must parse with, and the
+// fields the node legitimately leaves out of it; every other parser must
+// reject the fixture. Error fixtures have no parser.
+var fixtureCases = map[string]struct {
+	parser   string
+	optional []string
+}{
+	"embedding_text":            {parser: "embedding", optional: []string{"AestheticScore"}},
+	"embedding_image_aesthetic": {parser: "embedding"},
+	"classification_bioclip":    {parser: "classification"},
+	"face_bbox_only":            {parser: "face", optional: []string{"Faces[].Landmarks", "Faces[].Embedding"}},
+	"face_landmarks":            {parser: "face", optional: []string{"Faces[].Embedding"}},
+	"face_landmarks_embeddings": {parser: "face"},
+	"ocr_ppocr":                 {parser: "ocr"},
+	"text_generation_metadata":  {parser: "text_generation"},
+	"tts_wav":                   {parser: "audio"},
+	"tts_pcm":                   {parser: "audio"},
+	"error_invalid_argument":    {},
+	"error_unavailable":         {},
+}
+
+func TestFixtureCorpusIsCovered(t *testing.T) {
+	names := fixtures.Names()
+	if len(names) == 0 {
+		t.Fatal("fixture corpus is empty")
+	}
+	for _, name := range names {
+		if _, ok := fixtureCases[name]; !ok {
+			t.Errorf("fixture %s has no entry in fixtureCases", name)
+		}
+	}
+	for name := range fixtureCases {
+		if _, err := fixtures.Load(name); err != nil {
+			t.Errorf("fixtureCases lists %s: %v", name, err)
+		}
+	}
+}
+
+func TestParsersAgainstFixtures(t *testing.T) {
+	all, err := fixtures.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parserNames := make([]string, 0, len(fixtureParsers))
+	for name := range fixtureParsers {
+		parserNames = append(parserNames, name)
+	}
+	sort.Strings(parserNames)
+
+	for _, f := range all {
+		tc, ok := fixtureCases[f.Name]
+		if !ok {
+			continue
+		}
+		for _, name := range parserNames {
+			p := fixtureParsers[name]
+			t.Run(f.Name+"/"+name, func(t *testing.T) {
+				parser := types.ParseInferResponse(f.Response())
+				got, err := p.parse(parser)
+				if name != tc.parser {
+					if err == nil {
+						t.Fatalf("parsed a %s fixture: %+v", f.ResultMime, got)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("parse: %v", err)
+				}
+				if w := parser.CompatibilityWarning(); w != nil {
+					t.Errorf("compatibility warning: %s", w)
+				}
+				for _, field := range unpopulated(reflect.ValueOf(got), "") {
+					if !contains(tc.optional, field) {
+						t.Errorf("field %s is empty", field)
+					}
+				}
+				p.check(t, got)
+			})
+		}
+	}
+}
+
+func TestErrorFixturesCarryTheNodeError(t *testing.T) {
+	for name, tc := range fixtureCases {
+		if tc.parser != "" {
+			continue
+		}
+		f, err := fixtures.Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := f.Response().GetError()
+		if e == nil || e.Code == pb.ErrorCode_ERROR_CODE_UNSPECIFIED || e.Message == "" {
+			t.Errorf("%s: error = %+v, want a code and a message", name, e)
+		}
+		if len(f.Response().GetResult()) != 0 {
+			t.Errorf("%s: error response carries a result", name)
+		}
+	}
+}
+
+// unpopulated returns the paths of the zero-valued fields of v, with
+// slice elements written as Field[]. Scalar slice elements, such as vector
+// components or audio samples, may be zero.
+func unpopulated(v reflect.Value, path string) []string {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return []string{path}
+		}
+		return unpopulated(v.Elem(), path)
+	case reflect.Struct:
+		var out []string
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := field.Name
+			if path != "" {
+				name = path + "." + name
+			}
+			out = append(out, unpopulated(v.Field(i), name)...)
+		}
+		return out
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return []string{path}
+		}
+		switch v.Type().Elem().Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Struct, reflect.Slice:
+		default:
+			return nil
+		}
+		seen := map[string]bool{}
+		var out []string
+		for i := 0; i < v.Len(); i++ {
+			for _, p := range unpopulated(v.Index(i), path+"[]") {
+				if !seen[p] {
+					seen[p] = true
+					out = append(out, p)
+				}
+			}
+		}
+		return out
+	default:
+		if v.IsZero() {
+			return []string{path}
+		}
+		return nil
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
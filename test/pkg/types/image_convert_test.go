@@ -0,0 +1,120 @@
+package types_test
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/test/fixtures"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+func loadImage(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := fixtures.Image(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestConvertImagePairs(t *testing.T) {
+	tests := []struct {
+		fixture, from, to string
+		width, height     int
+	}{
+		{fixture: "photo.png", from: "image/png", to: "image/jpeg", width: 64, height: 48},
+		{fixture: "photo.jpg", from: "image/jpeg", to: "image/png", width: 64, height: 48},
+		{fixture: "alpha.png", from: "image/png", to: "image/jpeg", width: 32, height: 32},
+		{fixture: "frames.gif", from: "image/gif", to: "image/jpeg", width: 24, height: 16},
+		{fixture: "frames.gif", from: "image/gif", to: "image/png", width: 24, height: 16},
+		{fixture: "pixel.webp", from: "image/webp", to: "image/jpeg", width: 1, height: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture+"->"+tt.to, func(t *testing.T) {
+			if !types.CanConvertImage(tt.from, tt.to) {
+				t.Fatalf("CanConvertImage(%s, %s) = false", tt.from, tt.to)
+			}
+			out, err := types.ConvertImage(loadImage(t, tt.fixture), tt.to)
+			if err != nil {
+				t.Fatalf("ConvertImage: %v", err)
+			}
+			if got := mimetype.Detect(out).String(); got != tt.to {
+				t.Fatalf("converted image is %s, want %s", got, tt.to)
+			}
+			img, _, err := image.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("decode converted image: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != tt.width || b.Dy() != tt.height {
+				t.Fatalf("converted image is %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.width, tt.height)
+			}
+		})
+	}
+}
+
+func TestConvertImageFirstGIFFrameAndFlattenedAlpha(t *testing.T) {
+	out, err := types.ConvertImage(loadImage(t, "frames.gif"), "image/png")
+	if err != nil {
+		t.Fatalf("ConvertImage: %v", err)
+	}
+	img, _, _ := image.Decode(bytes.NewReader(out))
+	if r, _, b, _ := img.At(5, 5).RGBA(); r>>8 != 255 || b>>8 != 0 {
+		t.Fatalf("pixel = %v, want the red first frame", img.At(5, 5))
+	}
+
+	out, err = types.ConvertImage(loadImage(t, "alpha.png"), "image/jpeg")
+	if err != nil {
+		t.Fatalf("ConvertImage: %v", err)
+	}
+	img, _, _ = image.Decode(bytes.NewReader(out))
+	// The leftmost column is fully transparent: white once flattened, not
+	// black.
+	if r, g, b, _ := img.At(0, 16).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Fatalf("transparent pixel = %v, want white", img.At(0, 16))
+	}
+}
+
+func TestCanConvertImage(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"image/png", "image/jpeg", true},
+		{"Image/JPEG", "image/png; q=1", true},
+		{"image/png", "image/png", false},
+		{"image/png", "image/gif", false},
+		{"image/webp", "image/jpeg", true},
+		{"image/avif", "image/jpeg", false},
+		{"text/plain", "image/png", false},
+	}
+	for _, tt := range tests {
+		if got := types.CanConvertImage(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanConvertImage(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+	if _, err := types.ConvertImage(loadImage(t, "header.avif"), "image/jpeg"); err == nil {
+		t.Fatal("expected AVIF, which cannot be decoded, to fail")
+	}
+}
+
+func TestMIMEAccepted(t *testing.T) {
+	tests := []struct {
+		mime     string
+		accepted []string
+		want     bool
+	}{
+		{"image/png", nil, true},
+		{"image/png", []string{"image/jpeg", "image/png"}, true},
+		{"image/png", []string{"image/jpeg"}, false},
+		{"image/png", []string{"image/*"}, true},
+		{"audio/pcm;rate=16000", []string{"audio/pcm"}, true},
+		{"text/plain", []string{"*/*"}, true},
+	}
+	for _, tt := range tests {
+		if got := types.MIMEAccepted(tt.mime, tt.accepted); got != tt.want {
+			t.Errorf("MIMEAccepted(%s, %v) = %v, want %v", tt.mime, tt.accepted, got, tt.want)
+		}
+	}
+}
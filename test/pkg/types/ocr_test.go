@@ -1,9 +1,11 @@
 package types_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
 )
 
 func TestNewOCRRequest(t *testing.T) {
@@ -62,3 +64,44 @@ func TestNewOCRRequestInvalidPayload(t *testing.T) {
 		t.Error("Expected error for invalid payload, got nil")
 	}
 }
+
+func TestOCROptionValidation(t *testing.T) {
+	payload := []byte{0xFF, 0xD8, 0xFF, 0xE0}
+	tests := []struct {
+		name          string
+		opts          []types.OCRRequestOption
+		wantErrs      []string
+		wantDetection float32
+		wantRecog     float32
+	}{
+		{name: "in range", opts: []types.OCRRequestOption{types.WithDetectionThreshold(0.3), types.WithRecognitionThreshold(1)}, wantDetection: 0.3, wantRecog: 1},
+		{name: "clamped", opts: []types.OCRRequestOption{types.WithDetectionThreshold(-1), types.WithRecognitionThreshold(1.2)}, wantDetection: 0, wantRecog: 1},
+		{
+			name:     "strict reports both",
+			opts:     []types.OCRRequestOption{types.WithStrictOCRValidation(), types.WithDetectionThreshold(-1), types.WithRecognitionThreshold(1.2)},
+			wantErrs: []string{"detection_threshold", "recognition_threshold"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := types.NewOCRRequest(payload, tt.opts...)
+			if len(tt.wantErrs) > 0 {
+				if !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+					t.Fatalf("error = %v, want INVALID", err)
+				}
+				for _, want := range tt.wantErrs {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewOCRRequest: %v", err)
+			}
+			if req.DetectionThreshold != tt.wantDetection || req.RecognitionThreshold != tt.wantRecog {
+				t.Fatalf("thresholds = %g, %g; want %g, %g", req.DetectionThreshold, req.RecognitionThreshold, tt.wantDetection, tt.wantRecog)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package types_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+func TestMetaFromOptionsStringifiesValues(t *testing.T) {
+	var opts map[string]any
+	if err := json.Unmarshal([]byte(`{
+		"max_faces": 5,
+		"nms_threshold": 0.25,
+		"upscale": 1000000,
+		"use_angle_cls": true,
+		"lang": "en",
+		"stop": ["\n", "END"],
+		"roi": {"x": 10, "y": 20}
+	}`), &opts); err != nil {
+		t.Fatal(err)
+	}
+	opts["beams"] = int64(4)
+
+	meta, err := types.MetaFromOptions(opts)
+	if err != nil {
+		t.Fatalf("MetaFromOptions: %v", err)
+	}
+	want := map[string]string{
+		"max_faces":     "5",
+		"nms_threshold": "0.25",
+		"upscale":       "1000000",
+		"use_angle_cls": "true",
+		"lang":          "en",
+		"stop":          `["\n","END"]`,
+		"roi":           `{"x":10,"y":20}`,
+		"beams":         "4",
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Fatalf("meta = %v, want %v", meta, want)
+	}
+}
+
+func TestMetaFromOptionsRejectsBadKeysAndValues(t *testing.T) {
+	_, err := types.MetaFromOptions(map[string]any{
+		"lumen.client_version": "9.9.9",
+		"callback":             func() {},
+		"gain":                 math.Inf(1),
+		"missing":              nil,
+		"ok":                   "fine",
+	})
+	if !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+		t.Fatalf("err = %v, want INVALID", err)
+	}
+	for _, key := range []string{"lumen.client_version", "callback", "gain", "missing"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q does not name %s", err, key)
+		}
+	}
+	if strings.Contains(err.Error(), `"ok"`) {
+		t.Errorf("error %q names a valid option", err)
+	}
+
+	big := map[string]any{"prompt": strings.Repeat("x", types.MaxOptionsMetaBytes)}
+	if _, err := types.MetaFromOptions(big); !utils.HasErrorCode(err, utils.ErrCodeInvalid) {
+		t.Fatalf("oversized options: err = %v, want INVALID", err)
+	}
+	if meta, err := types.MetaFromOptions(nil); meta != nil || err != nil {
+		t.Fatalf("nil options = %v, %v; want nil, nil", meta, err)
+	}
+}
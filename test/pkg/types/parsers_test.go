@@ -136,6 +136,93 @@ func TestParseInferResponseAsEmbeddingResponseWrongMime(t *testing.T) {
 	}
 }
 
+func TestParseInferResponseAsEmbeddingVector(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  string
+		meta    map[string]string
+		warning bool
+	}{
+		{"full result", `{"vector":[0.5,-1,2.25],"dim":3,"model_id":"m","aesthetic_score":6.4,"extra":{"a":[1,{"b":2}]}}`, nil, false},
+		{"projected", `{"vector":[0.5,-1,2.25]}`, nil, false},
+		{"vector last", `{"model_id":"m\\\"]}","dim":3,"vector":[0.5,-1,2.25]}`, nil, false},
+		{"escaped name", "{\"ve\\u0063tor\" : [ 5e-1 , -1.0,\n2.25 ] }", nil, false},
+		{"revision 1 in result", `{"embedding":[0.5,-1,2.25],"schema_version":1}`, nil, false},
+		{"revision 1 in meta", `{"embedding":[0.5,-1,2.25],"model_id":"m"}`, map[string]string{types.SchemaVersionMetaKey: "1"}, false},
+		{"newer revision", `{"vector":[0.5,-1,2.25],"schema_version":3,"tokens":7}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := types.ParseInferResponse(&pb.InferResponse{
+				Result:     []byte(tt.result),
+				ResultMime: "application/json;schema=embedding_v1",
+				Meta:       tt.meta,
+			})
+			vec, err := parser.AsEmbeddingVector()
+			if err != nil {
+				t.Fatalf("AsEmbeddingVector() error = %v", err)
+			}
+			if len(vec) != 3 || vec[0] != 0.5 || vec[1] != -1 || vec[2] != 2.25 {
+				t.Fatalf("vector = %v, want [0.5 -1 2.25]", vec)
+			}
+			if got := parser.CompatibilityWarning() != nil; got != tt.warning {
+				t.Fatalf("CompatibilityWarning() = %v, want warning %v", parser.CompatibilityWarning(), tt.warning)
+			}
+		})
+	}
+}
+
+func TestParseInferResponseAsEmbeddingVectorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		result string
+		mime   string
+	}{
+		{"wrong mime", `{"vector":[1]}`, "application/json;schema=labels_v1"},
+		{"no vector", `{"model_id":"m"}`, ""},
+		{"not an object", `[1,2]`, ""},
+		{"invalid json", `{"vector":[1,`, ""},
+		{"unsupported revision", `{"vector":[1],"schema_version":0}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mime := tt.mime
+			if mime == "" {
+				mime = "application/json;schema=embedding_v1"
+			}
+			parser := types.ParseInferResponse(&pb.InferResponse{Result: []byte(tt.result), ResultMime: mime})
+			if vec, err := parser.AsEmbeddingVector(); err == nil {
+				t.Fatalf("AsEmbeddingVector() = %v, want an error", vec)
+			}
+		})
+	}
+}
+
+func TestParseInferResponseAsEmbeddingVectorIntoReusesBuffer(t *testing.T) {
+	buf := make([]float32, 0, 8)
+	parser := types.ParseInferResponse(&pb.InferResponse{
+		Result:     []byte(`{"vector":[1,2,3,4]}`),
+		ResultMime: "application/json;schema=embedding_v1",
+	})
+	vec, err := parser.AsEmbeddingVectorInto(buf)
+	if err != nil {
+		t.Fatalf("AsEmbeddingVectorInto() error = %v", err)
+	}
+	if len(vec) != 4 || &vec[0] != &buf[:1][0] {
+		t.Fatalf("vector = %v, want [1 2 3 4] in the caller's buffer", vec)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		vec, _ = parser.AsEmbeddingVectorInto(vec)
+	})
+	full := testing.AllocsPerRun(100, func() {
+		_, _ = parser.AsEmbeddingResponse()
+	})
+	if allocs >= full {
+		t.Fatalf("AsEmbeddingVectorInto allocates %v times per call, AsEmbeddingResponse %v", allocs, full)
+	}
+}
+
 func TestParseInferResponseAsClassificationResponse(t *testing.T) {
 	labelsData := types.LabelsV1{
 		Labels: []types.Label{
@@ -0,0 +1,166 @@
+package types_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// schemaGolden pairs a versioned result schema with the value every golden
+// revision under testdata/schemas/<schema>/ must parse to.
+type schemaGolden struct {
+	schema string
+	want   any
+	parse  func(*types.InferResponseParser) (any, error)
+}
+
+var schemaGoldens = []schemaGolden{
+	{
+		schema: "embedding_v1",
+		want:   &types.EmbeddingV1{Vector: []float32{0.25, -0.5, 0.125}, Dim: 3, ModelID: "clip-vit-b32"},
+		parse:  func(p *types.InferResponseParser) (any, error) { return p.AsEmbeddingResponse() },
+	},
+	{
+		schema: "labels_v1",
+		want: &types.LabelsV1{
+			Labels:  []types.Label{{Label: "cat", Score: 0.75}, {Label: "dog", Score: 0.25}},
+			ModelID: "bioclip-2",
+		},
+		parse: func(p *types.InferResponseParser) (any, error) { return p.AsClassificationResponse() },
+	},
+	{
+		schema: "face_v1",
+		want: &types.FaceV1{
+			Faces: []types.Face{{
+				BBox:       []float32{10, 20, 110, 140},
+				Confidence: 0.5,
+				Landmarks:  []float32{30, 40, 70, 40, 50, 60},
+			}},
+			Count:   1,
+			ModelID: "buffalo_l",
+		},
+		parse: func(p *types.InferResponseParser) (any, error) { return p.AsFaceResponse() },
+	},
+	{
+		schema: "ocr_v1",
+		want: &types.OCRV1{
+			Items:   []types.OCRItem{{Box: [][]int{{0, 0}, {40, 0}, {40, 12}, {0, 12}}, Text: "Lumen", Confidence: 0.75}},
+			Count:   1,
+			ModelID: "ppocr-v4",
+		},
+		parse: func(p *types.InferResponseParser) (any, error) { return p.AsOCRResponse() },
+	},
+}
+
+func loadSchemaGolden(t *testing.T, schema string, revision int) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "schemas", schema, "r"+strconv.Itoa(revision)+".json"))
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	return data
+}
+
+func schemaResponse(schema string, result []byte, meta map[string]string) *pb.InferResponse {
+	return &pb.InferResponse{
+		Result:     result,
+		ResultMime: "application/json;schema=" + schema,
+		Meta:       meta,
+	}
+}
+
+func TestSchemaGoldensParseToCurrentTypes(t *testing.T) {
+	for _, g := range schemaGoldens {
+		current := types.CurrentSchemaVersion(g.schema)
+		for revision := 1; revision <= current; revision++ {
+			t.Run(g.schema+"/r"+strconv.Itoa(revision), func(t *testing.T) {
+				resp := schemaResponse(g.schema, loadSchemaGolden(t, g.schema, revision),
+					map[string]string{types.SchemaVersionMetaKey: strconv.Itoa(revision)})
+				parser := types.ParseInferResponse(resp)
+				got, err := g.parse(parser)
+				if err != nil {
+					t.Fatalf("parse: %v", err)
+				}
+				if !reflect.DeepEqual(got, g.want) {
+					t.Fatalf("got %+v, want %+v", got, g.want)
+				}
+				if w := parser.CompatibilityWarning(); w != nil {
+					t.Fatalf("unexpected compatibility warning: %s", w)
+				}
+			})
+		}
+	}
+}
+
+func TestSchemaWithoutVersionIsCurrent(t *testing.T) {
+	for _, g := range schemaGoldens {
+		current := types.CurrentSchemaVersion(g.schema)
+		parser := types.ParseInferResponse(schemaResponse(g.schema, loadSchemaGolden(t, g.schema, current), nil))
+		got, err := g.parse(parser)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", g.schema, err)
+		}
+		if !reflect.DeepEqual(got, g.want) {
+			t.Fatalf("%s: got %+v, want %+v", g.schema, got, g.want)
+		}
+	}
+}
+
+func TestSchemaNewerVersionParsesWithWarning(t *testing.T) {
+	for _, g := range schemaGoldens {
+		current := types.CurrentSchemaVersion(g.schema)
+		// The newer golden embeds its schema_version in the result body.
+		parser := types.ParseInferResponse(schemaResponse(g.schema, loadSchemaGolden(t, g.schema, current+1), nil))
+		got, err := g.parse(parser)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", g.schema, err)
+		}
+		if !reflect.DeepEqual(got, g.want) {
+			t.Fatalf("%s: got %+v, want %+v", g.schema, got, g.want)
+		}
+		w := parser.CompatibilityWarning()
+		if w == nil {
+			t.Fatalf("%s: expected a compatibility warning", g.schema)
+		}
+		if w.Schema != g.schema || w.Version != current+1 || w.Supported != current {
+			t.Fatalf("%s: warning = %+v", g.schema, w)
+		}
+	}
+}
+
+func TestSchemaIncompatibleVersionsFail(t *testing.T) {
+	tests := []struct {
+		name   string
+		result string
+		meta   map[string]string
+	}{
+		{name: "older than supported", result: `{"labels": []}`, meta: map[string]string{types.SchemaVersionMetaKey: "0"}},
+		{name: "newer with changed layout", result: `{"schema_version": 9, "labels": {"cat": 0.75}}`},
+		{name: "malformed version", result: `{"labels": []}`, meta: map[string]string{types.SchemaVersionMetaKey: "two"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := types.ParseInferResponse(schemaResponse("labels_v1", []byte(tt.result), tt.meta)).AsClassificationResponse()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			var lumenErr *utils.LumenError
+			if !errors.As(err, &lumenErr) || lumenErr.Code != utils.ErrCodecMismatch {
+				t.Fatalf("error = %v, want a %s LumenError", err, utils.ErrCodecMismatch)
+			}
+		})
+	}
+
+	_, err := types.ParseInferResponse(schemaResponse("labels_v1", []byte(`{"schema_version": 9, "labels": {"cat": 0.75}}`), nil)).AsClassificationResponse()
+	if msg := err.Error(); !strings.Contains(msg, "schema_version 9") || !strings.Contains(msg, "1-2") {
+		t.Fatalf("error %q should name the received and supported versions", msg)
+	}
+}
@@ -0,0 +1,105 @@
+package types_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+func TestNewTextGenerationRequest(t *testing.T) {
+	req, err := types.NewTextGenerationRequest("Tell me a joke",
+		types.WithMaxTokens(64),
+		types.WithTemperature(0.7),
+		types.WithTopP(0.9),
+		types.WithStopSequences([]string{"\n\n"}),
+		types.WithSeed(42),
+	)
+	if err != nil {
+		t.Fatalf("NewTextGenerationRequest: %v", err)
+	}
+	if string(req.Payload) != "Tell me a joke" || req.PayloadMime != "text/plain" {
+		t.Fatalf("payload = %q (%s), want the prompt as text/plain", req.Payload, req.PayloadMime)
+	}
+	want := map[string]string{
+		"prompt":         "Tell me a joke",
+		"max_new_tokens": "64",
+		"temperature":    "0.7",
+		"top_p":          "0.9",
+		"stop_sequences": `["\n\n"]`,
+		"seed":           "42",
+		"do_sample":      "false",
+	}
+	for k, v := range want {
+		if req.Meta[k] != v {
+			t.Errorf("meta[%s] = %q, want %q", k, req.Meta[k], v)
+		}
+	}
+	if _, ok := req.Meta["messages"]; ok {
+		t.Error("prompt request carries messages")
+	}
+
+	built := types.NewInferRequest(types.TaskTextGeneration).ForTextGeneration(req, types.TaskTextGeneration).Build()
+	if built.Task != types.TaskTextGeneration || built.Meta["seed"] != "42" {
+		t.Fatalf("built request = %+v", built)
+	}
+	if err := types.ValidateTaskRequest(built); err != nil {
+		t.Fatalf("ValidateTaskRequest: %v", err)
+	}
+}
+
+func TestNewTextGenerationRequestWithMessages(t *testing.T) {
+	messages := []map[string]string{{"role": "user", "content": "Hi"}}
+	req, err := types.NewTextGenerationRequest("", types.WithMessages(messages))
+	if err != nil {
+		t.Fatalf("NewTextGenerationRequest: %v", err)
+	}
+	if req.PayloadMime != "application/json" || req.Meta["messages"] != `[{"content":"Hi","role":"user"}]` {
+		t.Fatalf("payload mime = %s, meta = %v", req.PayloadMime, req.Meta)
+	}
+	var got struct {
+		Messages []map[string]string `json:"messages"`
+	}
+	if err := json.Unmarshal(req.Payload, &got); err != nil || len(got.Messages) != 1 || got.Messages[0]["content"] != "Hi" {
+		t.Fatalf("payload %s = %v, %v; want the messages object", req.Payload, got, err)
+	}
+	built := types.NewInferRequest(types.TaskTextGeneration).ForTextGeneration(req, types.TaskTextGeneration).Build()
+	if err := types.ValidateTaskRequest(built); err != nil {
+		t.Fatalf("ValidateTaskRequest: %v", err)
+	}
+}
+
+func TestNewTextGenerationRequestRejectsInvalidInput(t *testing.T) {
+	messages := types.WithMessages([]map[string]string{{"role": "user", "content": "Hi"}})
+	tests := []struct {
+		name   string
+		prompt string
+		opts   []types.ImageTextGenerationRequestOption
+		want   string
+	}{
+		{name: "empty", want: "a prompt or messages is required"},
+		{name: "empty messages", opts: []types.ImageTextGenerationRequestOption{types.WithMessages(nil)}, want: "a prompt or messages is required"},
+		{name: "blank", prompt: " \n\t", want: "prompt is blank"},
+		{name: "prompt and messages", prompt: "Hi", opts: []types.ImageTextGenerationRequestOption{messages}, want: "mutually exclusive"},
+		{name: "WithPrompt and messages", opts: []types.ImageTextGenerationRequestOption{types.WithPrompt("Hi"), messages}, want: "mutually exclusive"},
+		{name: "two prompts", prompt: "Hi", opts: []types.ImageTextGenerationRequestOption{types.WithPrompt("Bye")}, want: "WithPrompt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := types.NewTextGenerationRequest(tt.prompt, tt.opts...)
+			if !utils.HasErrorCode(err, utils.ErrCodeInvalid) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want an INVALID error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTaskRequestTextGeneration(t *testing.T) {
+	req := &pb.InferRequest{Task: types.TaskTextGeneration, Payload: []byte{0xFF, 0xD8, 0xFF}, PayloadMime: "image/jpeg"}
+	if err := types.ValidateTaskRequest(req); err == nil {
+		t.Fatal("expected an image payload to be rejected for text_generation")
+	}
+}
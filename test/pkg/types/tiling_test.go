@@ -0,0 +1,153 @@
+package types_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestTileImageCoversImageWithOverlap(t *testing.T) {
+	tiles, err := types.TileImage(encodePNG(t, 250, 120), 100, 20)
+	if err != nil {
+		t.Fatalf("TileImage: %v", err)
+	}
+
+	// x origins: 0, 80, 150 (last aligned to the edge); y origins: 0, 20.
+	type origin struct{ x, y, w, h int }
+	want := []origin{
+		{0, 0, 100, 100}, {80, 0, 100, 100}, {150, 0, 100, 100},
+		{0, 20, 100, 100}, {80, 20, 100, 100}, {150, 20, 100, 100},
+	}
+	if len(tiles) != len(want) {
+		t.Fatalf("got %d tiles, want %d", len(tiles), len(want))
+	}
+	for i, tile := range tiles {
+		got := origin{tile.X, tile.Y, tile.Width, tile.Height}
+		if got != want[i] {
+			t.Errorf("tile %d = %+v, want %+v", i, got, want[i])
+		}
+		if tile.PayloadMime != "image/png" {
+			t.Errorf("tile %d mime = %q", i, tile.PayloadMime)
+		}
+		decoded, err := png.Decode(bytes.NewReader(tile.Data))
+		if err != nil {
+			t.Fatalf("tile %d: decode: %v", i, err)
+		}
+		if b := decoded.Bounds(); b.Dx() != tile.Width || b.Dy() != tile.Height {
+			t.Errorf("tile %d decoded size %dx%d", i, b.Dx(), b.Dy())
+		}
+		// The synthetic gradient encodes source coordinates in R and G.
+		r, g, _, _ := decoded.At(0, 0).RGBA()
+		if int(r>>8) != tile.X || int(g>>8) != tile.Y {
+			t.Errorf("tile %d top-left pixel from (%d,%d), want (%d,%d)", i, r>>8, g>>8, tile.X, tile.Y)
+		}
+	}
+}
+
+func TestTileImageSmallImageIsOneTile(t *testing.T) {
+	tiles, err := types.TileImage(encodePNG(t, 40, 30), 100, 10)
+	if err != nil {
+		t.Fatalf("TileImage: %v", err)
+	}
+	if len(tiles) != 1 || tiles[0].Width != 40 || tiles[0].Height != 30 {
+		t.Fatalf("tiles = %+v, want one 40x30 tile", tiles)
+	}
+}
+
+func TestTileImageRejectsBadGeometry(t *testing.T) {
+	img := encodePNG(t, 10, 10)
+	if _, err := types.TileImage(img, 0, 0); err == nil {
+		t.Error("expected an error for zero tile size")
+	}
+	if _, err := types.TileImage(img, 10, 10); err == nil {
+		t.Error("expected an error for overlap >= tile size")
+	}
+	if _, err := types.TileImage([]byte("not an image"), 10, 2); err == nil {
+		t.Error("expected a decode error")
+	}
+}
+
+func TestMergeDetectionsTranslatesAndDedupsAcrossSeams(t *testing.T) {
+	tiles := []types.Tile{
+		{X: 0, Y: 0, Width: 100, Height: 100},
+		{X: 80, Y: 0, Width: 100, Height: 100},
+	}
+	results := []*types.FaceV1{
+		{
+			ModelID: "scrfd",
+			Faces: []types.Face{
+				// Straddles the seam; seen fully by tile 0.
+				{BBox: []float32{85, 10, 98, 30}, Confidence: 0.9, Landmarks: []float32{90, 20}},
+				// Only in tile 0.
+				{BBox: []float32{10, 10, 30, 30}, Confidence: 0.8},
+			},
+		},
+		{
+			ModelID: "scrfd",
+			Faces: []types.Face{
+				// The same face seen by tile 1, slightly clipped and lower scored.
+				{BBox: []float32{5, 10, 18, 30}, Confidence: 0.7},
+				// Only in tile 1.
+				{BBox: []float32{50, 50, 70, 80}, Confidence: 0.95},
+			},
+		},
+	}
+
+	merged := types.MergeDetections(tiles, results, 0.5)
+
+	want := &types.FaceV1{
+		ModelID: "scrfd",
+		Count:   3,
+		Faces: []types.Face{
+			{BBox: []float32{130, 50, 150, 80}, Confidence: 0.95},
+			{BBox: []float32{85, 10, 98, 30}, Confidence: 0.9, Landmarks: []float32{90, 20}},
+			{BBox: []float32{10, 10, 30, 30}, Confidence: 0.8},
+		},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Fatalf("merged = %+v, want %+v", merged, want)
+	}
+}
+
+func TestMergeDetectionsKeepsDistinctNeighbours(t *testing.T) {
+	tiles := []types.Tile{{X: 0, Y: 0}, {X: 0, Y: 100}}
+	results := []*types.FaceV1{
+		{Faces: []types.Face{{BBox: []float32{0, 90, 20, 100}, Confidence: 0.6}}},
+		nil,
+		{Faces: []types.Face{{BBox: []float32{0, 0, 20, 10}, Confidence: 0.6}}},
+	}
+
+	merged := types.MergeDetections(tiles, results, 0.3)
+	if merged.Count != 1 {
+		t.Fatalf("count = %d, want 1 (nil and out-of-range results skipped)", merged.Count)
+	}
+
+	results[1] = &types.FaceV1{Faces: []types.Face{{BBox: []float32{0, 0, 20, 10}, Confidence: 0.5}}}
+	merged = types.MergeDetections(tiles, results, 0.3)
+	if merged.Count != 2 {
+		t.Fatalf("count = %d, want 2 touching but non-overlapping faces", merged.Count)
+	}
+	if got := merged.Faces[1].BBox; !reflect.DeepEqual(got, []float32{0, 100, 20, 110}) {
+		t.Fatalf("second face bbox = %v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package types_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/types"
+)
+
+func TestEmbeddingTransforms(t *testing.T) {
+	e := types.EmbeddingV1{Vector: []float32{0.123456, 0.5, -0.25, 0.75, 1, 2, 3}, Dim: 7, ModelID: "m"}
+
+	truncated := types.TruncateDimensions(3).Apply(e)
+	if !reflect.DeepEqual(truncated.Vector, []float32{0.123456, 0.5, -0.25}) || truncated.Dim != 3 {
+		t.Errorf("TruncateDimensions(3) = %v (dim %d)", truncated.Vector, truncated.Dim)
+	}
+	if len(e.Vector) != 7 {
+		t.Fatalf("transform modified its argument: %v", e.Vector)
+	}
+
+	// 7 values into 3 runs of 2, 2 and 3.
+	pooled := types.MeanPoolDimensions(3).Apply(e)
+	if want := []float32{(0.123456 + 0.5) / 2, 0.25, 2}; !reflect.DeepEqual(pooled.Vector, want) || pooled.Dim != 3 {
+		t.Errorf("MeanPoolDimensions(3) = %v, want %v", pooled.Vector, want)
+	}
+
+	if same := types.TruncateDimensions(10).Apply(e); len(same.Vector) != 7 {
+		t.Errorf("TruncateDimensions past the dimension changed the vector: %v", same.Vector)
+	}
+
+	chain := types.Chain(types.TruncateDimensions(2), types.RoundVector(2))
+	if chain.Name != "dimensions=2,precision=2" {
+		t.Errorf("chain name = %q", chain.Name)
+	}
+	if got := chain.Apply(e); !reflect.DeepEqual(got.Vector, []float32{0.12, 0.5}) || got.ModelID != "m" {
+		t.Errorf("chain = %+v", got)
+	}
+
+	unit := types.Chain(types.TruncateDimensions(2), types.NormalizeEmbedding()).Apply(e)
+	if m := unit.Magnitude(); m < 0.999 || m > 1.001 {
+		t.Errorf("normalized magnitude = %v", m)
+	}
+}
+
+func TestConfidenceTransforms(t *testing.T) {
+	faces := types.FaceV1{Faces: []types.Face{{Confidence: 0.9}, {Confidence: 0.2}, {Confidence: 0.5}}, Count: 3}
+	got := types.MinFaceConfidence(0.5).Apply(faces)
+	if got.Count != 2 || len(got.Faces) != 2 || got.Faces[1].Confidence != 0.5 {
+		t.Errorf("MinFaceConfidence(0.5) = %+v", got)
+	}
+
+	ocr := types.OCRV1{Items: []types.OCRItem{{Text: "a", Confidence: 0.1}, {Text: "b", Confidence: 0.8}}, Count: 2}
+	kept := types.MinOCRConfidence(0.3).Apply(ocr)
+	if kept.Count != 1 || kept.Items[0].Text != "b" {
+		t.Errorf("MinOCRConfidence(0.3) = %+v", kept)
+	}
+	if ocr.Count != 2 || len(ocr.Items) != 2 {
+		t.Errorf("transform modified its argument: %+v", ocr)
+	}
+}
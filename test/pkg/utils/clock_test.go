@@ -0,0 +1,89 @@
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+var clockStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClockFiresTimersInOrder(t *testing.T) {
+	clock := utils.NewFakeClock(clockStart)
+	late := clock.After(3 * time.Second)
+	early := clock.After(time.Second)
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-early:
+		t.Fatal("timer fired before it was due")
+	default:
+	}
+
+	clock.Advance(5 * time.Second)
+	if got := <-early; !got.Equal(clockStart.Add(time.Second)) {
+		t.Errorf("early timer fired at %v, want its due time", got.Sub(clockStart))
+	}
+	if got := <-late; !got.Equal(clockStart.Add(3 * time.Second)) {
+		t.Errorf("late timer fired at %v, want its due time", got.Sub(clockStart))
+	}
+	if got := clock.Now(); !got.Equal(clockStart.Add(5500 * time.Millisecond)) {
+		t.Errorf("Now() = %v after advancing, want 5.5s", got.Sub(clockStart))
+	}
+	if n := clock.Waiters(); n != 0 {
+		t.Errorf("%d waiters left after every timer fired", n)
+	}
+}
+
+func TestFakeClockTickerHandsOverEveryTick(t *testing.T) {
+	clock := utils.NewFakeClock(clockStart)
+	ticker := clock.NewTicker(time.Second)
+
+	ticks := make(chan time.Time, 10)
+	go func() {
+		for tick := range ticker.C() {
+			ticks <- tick
+		}
+	}()
+	clock.Advance(3500 * time.Millisecond)
+
+	for i := 1; i <= 3; i++ {
+		if got := <-ticks; !got.Equal(clockStart.Add(time.Duration(i) * time.Second)) {
+			t.Errorf("tick %d at %v, want %ds", i, got.Sub(clockStart), i)
+		}
+	}
+
+	ticker.Stop()
+	// A stopped ticker no longer holds Advance up.
+	clock.Advance(time.Hour)
+	if n := clock.Waiters(); n != 0 {
+		t.Errorf("%d waiters left after the ticker stopped", n)
+	}
+}
+
+func TestFakeClockTimersStopAndRunFuncs(t *testing.T) {
+	clock := utils.NewFakeClock(clockStart)
+	var ran []time.Time
+	clock.AfterFunc(2*time.Second, func() { ran = append(ran, clock.Now()) })
+	stopped := clock.AfterFunc(time.Second, func() { t.Error("stopped AfterFunc ran") })
+	timer := clock.NewTimer(time.Second)
+
+	if !stopped.Stop() {
+		t.Fatal("Stop on a pending timer reported false")
+	}
+	if stopped.Stop() {
+		t.Fatal("second Stop reported true")
+	}
+	clock.Advance(3 * time.Second)
+	// The func has run by the time Advance returns.
+	if len(ran) != 1 || !ran[0].Equal(clockStart.Add(2*time.Second)) {
+		t.Fatalf("AfterFunc ran at %v, want once at 2s", ran)
+	}
+	if got := <-timer.C(); !got.Equal(clockStart.Add(time.Second)) {
+		t.Errorf("timer fired at %v, want 1s", got.Sub(clockStart))
+	}
+	if timer.Stop() {
+		t.Error("Stop on a fired timer reported true")
+	}
+}
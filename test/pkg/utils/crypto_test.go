@@ -0,0 +1,126 @@
+package utils_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+var (
+	testKeyOld = bytes.Repeat([]byte{0x11}, 32)
+	testKeyNew = bytes.Repeat([]byte{0x22}, 32)
+)
+
+func TestPayloadCipherRoundTrip(t *testing.T) {
+	c, err := utils.NewPayloadCipher("k1", map[string][]byte{"k1": testKeyOld})
+	if err != nil {
+		t.Fatalf("NewPayloadCipher() error = %v", err)
+	}
+
+	plaintext := []byte("face embedding bytes")
+	sealed, err := c.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatal("ciphertext contains plaintext")
+	}
+
+	opened, err := c.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Fatalf("Open() = %q, want %q", opened, plaintext)
+	}
+}
+
+func TestPayloadCipherWrongKey(t *testing.T) {
+	writer, _ := utils.NewPayloadCipher("k1", map[string][]byte{"k1": testKeyOld})
+	reader, _ := utils.NewPayloadCipher("k1", map[string][]byte{"k1": testKeyNew})
+
+	sealed, err := writer.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if _, err := reader.Open(sealed); !errors.Is(err, utils.ErrDecrypt) {
+		t.Fatalf("Open() with wrong key error = %v, want ErrDecrypt", err)
+	}
+
+	other, _ := utils.NewPayloadCipher("k9", map[string][]byte{"k9": testKeyOld})
+	if _, err := other.Open(sealed); !errors.Is(err, utils.ErrUnknownKeyID) {
+		t.Fatalf("Open() with unknown key id error = %v, want ErrUnknownKeyID", err)
+	}
+
+	sealed[len(sealed)-1] ^= 0xff
+	if _, err := writer.Open(sealed); !errors.Is(err, utils.ErrDecrypt) {
+		t.Fatalf("Open() of tampered ciphertext error = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestPayloadCipherRotation(t *testing.T) {
+	before, _ := utils.NewPayloadCipher("k1", map[string][]byte{"k1": testKeyOld})
+	oldSealed, _ := before.Seal([]byte("written before rotation"))
+
+	after, err := utils.NewPayloadCipher("k2", map[string][]byte{"k1": testKeyOld, "k2": testKeyNew})
+	if err != nil {
+		t.Fatalf("NewPayloadCipher() error = %v", err)
+	}
+	if got, err := after.Open(oldSealed); err != nil || string(got) != "written before rotation" {
+		t.Fatalf("Open() of old ciphertext = %q, %v", got, err)
+	}
+
+	newSealed, _ := after.Seal([]byte("written after rotation"))
+	if _, err := before.Open(newSealed); !errors.Is(err, utils.ErrUnknownKeyID) {
+		t.Fatalf("new ciphertext should use k2, old cipher error = %v", err)
+	}
+}
+
+func TestLoadPayloadCipherFromKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payload.keys")
+	contents := "# rotated 2026-10\n" +
+		"k2:" + base64.StdEncoding.EncodeToString(testKeyNew) + "\n" +
+		"k1:" + base64.StdEncoding.EncodeToString(testKeyOld) + "\n"
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := utils.LoadPayloadCipher(config.PayloadProtectionConfig{Enabled: true, KeyFile: path})
+	if err != nil {
+		t.Fatalf("LoadPayloadCipher() error = %v", err)
+	}
+	if c.ActiveKeyID() != "k2" {
+		t.Fatalf("ActiveKeyID() = %q, want first entry k2", c.ActiveKeyID())
+	}
+
+	disabled, err := utils.LoadPayloadCipher(config.PayloadProtectionConfig{})
+	if err != nil || disabled != nil {
+		t.Fatalf("disabled LoadPayloadCipher() = %v, %v; want nil, nil", disabled, err)
+	}
+}
+
+func TestLoadPayloadCipherFromEnv(t *testing.T) {
+	t.Setenv(utils.PayloadKeysEnv, "k1:"+base64.StdEncoding.EncodeToString(testKeyOld)+",k2:"+base64.StdEncoding.EncodeToString(testKeyNew))
+
+	c, err := utils.LoadPayloadCipher(config.PayloadProtectionConfig{Enabled: true, ActiveKeyID: "k2"})
+	if err != nil {
+		t.Fatalf("LoadPayloadCipher() error = %v", err)
+	}
+	if c.ActiveKeyID() != "k2" {
+		t.Fatalf("ActiveKeyID() = %q, want k2", c.ActiveKeyID())
+	}
+}
+
+func TestZeroBytes(t *testing.T) {
+	b := []byte("plaintext")
+	utils.ZeroBytes(b)
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatalf("ZeroBytes left %q", b)
+	}
+}
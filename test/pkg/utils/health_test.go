@@ -0,0 +1,45 @@
+package utils_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+type countingChecker struct{ checks atomic.Int32 }
+
+func (c *countingChecker) Name() string { return "counting" }
+
+func (c *countingChecker) Check(context.Context) *utils.HealthCheckResult {
+	c.checks.Add(1)
+	return &utils.HealthCheckResult{Status: utils.StatusHealthy}
+}
+
+func TestHealthMonitorChecksEveryInterval(t *testing.T) {
+	clock := utils.NewFakeClock(clockStart)
+	hm := utils.NewHealthMonitorWithClock(30*time.Second, clock)
+	checker := &countingChecker{}
+	hm.AddChecker(checker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hm.Start(ctx)
+	clock.BlockUntil(1)
+
+	clock.Advance(29 * time.Second)
+	if n := checker.checks.Load(); n != 0 {
+		t.Fatalf("checked %d times before the interval passed", n)
+	}
+	// Each tick is received only once the previous round of checks is done,
+	// so after the fourth tick three rounds have certainly run.
+	clock.Advance(91 * time.Second)
+	if n := checker.checks.Load(); n < 3 {
+		t.Errorf("checked %d times after four intervals, want at least 3", n)
+	}
+	if _, ok := hm.GetResult("counting"); !ok {
+		t.Error("no result recorded for the checker")
+	}
+}
@@ -0,0 +1,81 @@
+package utils_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+)
+
+// retryAttempts runs Retry with fn always failing with err, advancing clock
+// through each wait, and returns when every attempt started.
+func retryAttempts(t *testing.T, config *utils.RetryConfig, clock *utils.FakeClock, err error) []time.Duration {
+	t.Helper()
+	var attempts []time.Duration
+	done := make(chan error, 1)
+	go func() {
+		done <- utils.Retry(context.Background(), config, func(context.Context) error {
+			attempts = append(attempts, clock.Now().Sub(clockStart))
+			return err
+		})
+	}()
+	for {
+		select {
+		case got := <-done:
+			if !errors.Is(got, err) {
+				t.Errorf("Retry returned %v, want the last error", got)
+			}
+			return attempts
+		default:
+		}
+		if clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		clock.Advance(10 * time.Millisecond)
+	}
+}
+
+func TestRetryBacksOffExponentially(t *testing.T) {
+	clock := utils.NewFakeClock(clockStart)
+	config := &utils.RetryConfig{
+		Enabled:     true,
+		MaxAttempts: 4,
+		Backoff:     100 * time.Millisecond,
+		MaxBackoff:  time.Minute,
+		Multiplier:  2,
+		Clock:       clock,
+	}
+	got := retryAttempts(t, config, clock, utils.NewRetryableError(errors.New("unavailable"), true))
+	want := []time.Duration{0, 100 * time.Millisecond, 300 * time.Millisecond, 700 * time.Millisecond}
+	if !slices.Equal(got, want) {
+		t.Errorf("attempts at %v, want %v", got, want)
+	}
+}
+
+func TestRetryStopsAtMaxBackoff(t *testing.T) {
+	clock := utils.NewFakeClock(clockStart)
+	config := &utils.RetryConfig{
+		Enabled:    true,
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: 250 * time.Millisecond,
+		Clock:      clock,
+	}
+	got := retryAttempts(t, config, clock, utils.NewRetryableError(errors.New("unavailable"), true))
+	want := []time.Duration{0, 100 * time.Millisecond, 250 * time.Millisecond}
+	if !slices.Equal(got, want) {
+		t.Errorf("attempts at %v, want %v", got, want)
+	}
+}
+
+func TestRetryGivesUpOnPermanentErrors(t *testing.T) {
+	clock := utils.NewFakeClock(clockStart)
+	config := &utils.RetryConfig{Enabled: true, MaxAttempts: 4, Backoff: time.Second, MaxBackoff: time.Minute, Clock: clock}
+	got := retryAttempts(t, config, clock, utils.NewRetryableError(errors.New("invalid"), false))
+	if len(got) != 1 {
+		t.Errorf("permanent error attempted %d times, want 1", len(got))
+	}
+}
@@ -0,0 +1,352 @@
+//go:build soak
+
+package soak
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"github.com/edwinzhancn/lumen-sdk/pkg/utils"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Tasks every fake node serves.
+const (
+	taskEmbed  = "soak_embed"  // echoes the payload
+	taskUpload = "soak_upload" // answers {"bytes":N} once the whole payload arrived
+	taskStream = "soak_stream" // sends streamFrames partial frames, then the echo
+)
+
+const streamFrames = 3
+
+var soakTasks = []string{taskEmbed, taskUpload, taskStream}
+
+// action is a step of a node's lifecycle script.
+type action string
+
+const (
+	actJoin    action = "join"    // start a server and register it
+	actLeave   action = "leave"   // deregister; the server lingers to catch late requests
+	actCrash   action = "crash"   // stop the server without deregistering
+	actSlow    action = "slow"    // add latency to every request
+	actRecover action = "recover" // remove the latency
+)
+
+// step is an action at an offset from the start of the run.
+type step struct {
+	at  time.Duration
+	act action
+}
+
+// script returns a lifecycle for a churning node over d, drawn from rng.
+// Every node starts joined; steps are 1-4s apart.
+func script(rng *rand.Rand, d time.Duration) []step {
+	var steps []step
+	up, slow := true, false
+	for at := time.Second + time.Duration(rng.Int63n(int64(3*time.Second))); at < d; at += time.Second + time.Duration(rng.Int63n(int64(3*time.Second))) {
+		var act action
+		switch r := rng.Float64(); {
+		case !up:
+			act = actJoin
+		case r < 0.3:
+			act = actLeave
+		case r < 0.6:
+			act = actCrash
+		case slow:
+			act = actRecover
+		default:
+			act = actSlow
+		}
+		switch act {
+		case actJoin:
+			up, slow = true, false
+		case actLeave, actCrash:
+			up = false
+		case actSlow:
+			slow = true
+		case actRecover:
+			slow = false
+		}
+		steps = append(steps, step{at: at, act: act})
+	}
+	return steps
+}
+
+// fakeNode is an inference server that registers itself with the client's
+// push registry and follows a lifecycle script.
+type fakeNode struct {
+	id       string
+	registry *discovery.PushResolver
+	history  *history
+	// heartbeat is how often a joined node heartbeats.
+	heartbeat time.Duration
+	// linger is how long a node that left keeps serving, so requests still
+	// routed to it are seen rather than refused.
+	linger time.Duration
+	// grace is how long after leaving a node may still receive requests.
+	grace time.Duration
+
+	// late counts requests that reached the node more than grace after it
+	// left.
+	late   atomic.Int64
+	served atomic.Int64
+	delay  atomic.Int64 // nanoseconds added to every request
+
+	mu        sync.Mutex
+	key       string
+	instance  *instance
+	downSince time.Time // zero while joined
+	crashed   bool      // whether the node went down by crashing
+	stopHB    context.CancelFunc
+
+	wg sync.WaitGroup // lingering servers and heartbeat loops
+}
+
+// instance is one server a node ran between a join and a leave or crash.
+type instance struct {
+	pb.UnimplementedInferenceServer
+	node   *fakeNode
+	server *grpc.Server
+	addr   string
+
+	mu     sync.Mutex
+	leftAt time.Time
+}
+
+func (n *fakeNode) apply(act action, rng *rand.Rand) error {
+	n.history.add("%s %s", n.id, act)
+	switch act {
+	case actJoin:
+		return n.join()
+	case actLeave:
+		return n.leave()
+	case actCrash:
+		n.crash()
+	case actSlow:
+		d := 50*time.Millisecond + time.Duration(rng.Int63n(int64(250*time.Millisecond)))
+		n.delay.Store(int64(d))
+	case actRecover:
+		n.delay.Store(0)
+	}
+	return nil
+}
+
+func (n *fakeNode) join() error {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	inst := &instance{node: n, server: grpc.NewServer(), addr: lis.Addr().String()}
+	pb.RegisterInferenceServer(inst.server, inst)
+	go func() { _ = inst.server.Serve(lis) }()
+
+	key, err := n.register(inst.addr)
+	if err != nil {
+		inst.server.Stop()
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	n.mu.Lock()
+	n.key, n.instance, n.downSince, n.crashed, n.stopHB = key, inst, time.Time{}, false, cancel
+	n.mu.Unlock()
+	n.delay.Store(0)
+
+	n.wg.Add(1)
+	go n.heartbeatLoop(ctx, inst.addr)
+	return nil
+}
+
+func (n *fakeNode) register(addr string) (string, error) {
+	return n.registry.Register(discovery.PushRegistration{NodeID: n.id, Address: addr, Tasks: soakTasks})
+}
+
+// heartbeatLoop keeps the registration alive until ctx ends. A heartbeat
+// the registry refuses means it expired a node that was heartbeating; the
+// node registers again and the event is recorded.
+func (n *fakeNode) heartbeatLoop(ctx context.Context, addr string) {
+	defer n.wg.Done()
+	t := time.NewTicker(n.heartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if err := n.registry.Heartbeat(n.id); err != nil && ctx.Err() == nil {
+			n.history.add("%s heartbeat refused: %v", n.id, err)
+			if utils.HasErrorCode(err, utils.ErrCodeNodeNotFound) {
+				_, _ = n.register(addr)
+			}
+		}
+	}
+}
+
+func (n *fakeNode) leave() error {
+	n.mu.Lock()
+	inst := n.instance
+	n.instance, n.downSince = nil, time.Now()
+	n.stopHB()
+	n.mu.Unlock()
+	if inst == nil {
+		return nil
+	}
+	inst.mu.Lock()
+	inst.leftAt = time.Now()
+	inst.mu.Unlock()
+	err := n.registry.Deregister(n.id)
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		time.Sleep(n.linger)
+		inst.server.Stop()
+	}()
+	return err
+}
+
+func (n *fakeNode) crash() {
+	n.mu.Lock()
+	inst := n.instance
+	n.instance, n.downSince, n.crashed = nil, time.Now(), true
+	n.stopHB()
+	n.mu.Unlock()
+	if inst != nil {
+		inst.server.Stop()
+	}
+}
+
+// up reports whether the node is joined.
+func (n *fakeNode) up() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.instance != nil
+}
+
+// removedFor reports how long the node with key has been down, when that
+// is longer than the client may take to stop routing to it: grace after a
+// leave, and grace plus expiry after a crash, which the client only learns
+// of from missed heartbeats.
+func (n *fakeNode) removedFor(key string, at time.Time, expiry time.Duration) (time.Duration, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if key != n.key || n.instance != nil || n.downSince.IsZero() {
+		return 0, false
+	}
+	allowed := n.grace
+	if n.crashed {
+		allowed += expiry
+	}
+	down := at.Sub(n.downSince)
+	return down, down > allowed
+}
+
+// shutdown stops the node for good, waiting for lingering servers.
+func (n *fakeNode) shutdown() {
+	n.mu.Lock()
+	inst := n.instance
+	n.instance = nil
+	if n.stopHB != nil {
+		n.stopHB()
+	}
+	n.mu.Unlock()
+	if inst != nil {
+		_ = n.registry.Deregister(n.id)
+		inst.server.Stop()
+	}
+	n.wg.Wait()
+}
+
+func (s *instance) Infer(stream grpc.BidiStreamingServer[pb.InferRequest, pb.InferResponse]) error {
+	var first *pb.InferRequest
+	var payload []byte
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first == nil {
+			first = req
+			s.observe()
+		}
+		payload = append(payload, req.Payload...)
+		if req.Total <= 1 || req.Seq+1 >= req.Total {
+			break
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	if d := time.Duration(s.node.delay.Load()); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+
+	result := payload
+	switch first.Task {
+	case taskUpload:
+		result = []byte(`{"bytes":` + strconv.Itoa(len(payload)) + `}`)
+	case taskStream:
+		for i := range streamFrames {
+			err := stream.Send(&pb.InferResponse{CorrelationId: first.CorrelationId, Seq: uint64(i), Result: []byte(fmt.Sprint(i))})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	s.node.served.Add(1)
+	return stream.Send(&pb.InferResponse{
+		CorrelationId: first.CorrelationId,
+		IsFinal:       true,
+		Result:        result,
+		ResultMime:    first.PayloadMime,
+	})
+}
+
+// observe counts a request reaching the instance more than grace after its
+// node left.
+func (s *instance) observe() {
+	s.mu.Lock()
+	leftAt := s.leftAt
+	s.mu.Unlock()
+	if !leftAt.IsZero() && time.Since(leftAt) > s.node.grace {
+		s.node.late.Add(1)
+		s.node.history.add("VIOLATION: %s received a request %v after leaving", s.node.id, time.Since(leftAt).Round(time.Millisecond))
+	}
+}
+
+func (s *instance) GetCapabilities(context.Context, *emptypb.Empty) (*pb.Capability, error) {
+	return s.capability(), nil
+}
+
+func (s *instance) StreamCapabilities(_ *emptypb.Empty, stream grpc.ServerStreamingServer[pb.Capability]) error {
+	return stream.Send(s.capability())
+}
+
+func (s *instance) Health(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (s *instance) capability() *pb.Capability {
+	c := &pb.Capability{ServiceName: "soak"}
+	for _, task := range soakTasks {
+		c.Tasks = append(c.Tasks, &pb.IOTask{Name: task})
+	}
+	return c
+}
@@ -0,0 +1,463 @@
+//go:build soak
+
+// Package soak runs the client against a churning cluster of fake nodes
+// under mixed traffic and checks invariants throughout. It is opt-in and
+// long-running:
+//
+//	go test -tags=soak ./test/soak -v
+//	LUMEN_SOAK_DURATION=10m LUMEN_SOAK_QPS=200 go test -tags=soak ./test/soak -timeout 15m
+//
+// Nodes register through the client's push registry. A third of them stay
+// up throughout; the others join, leave, crash, slow down and recover on
+// schedules drawn from LUMEN_SOAK_SEED. Meanwhile the driver sends small
+// embeds, chunked uploads, streams and batches, and node filters are
+// reloaded every few seconds. The run fails on:
+//
+//   - a goroutine count above the post-warm-up baseline plus
+//     LUMEN_SOAK_GOROUTINE_SLACK, or goroutines left behind after Close;
+//   - a request reaching, or routed to, a node past its removal grace;
+//   - metrics counters that go backwards or do not add up;
+//   - a panic, or a response answering the wrong request;
+//   - an error rate above LUMEN_SOAK_MAX_ERROR_RATE while a node was up.
+//
+// On failure the goroutine stacks and the event history are logged, and
+// written to LUMEN_SOAK_DUMP_DIR when it is set.
+package soak
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	"github.com/edwinzhancn/lumen-sdk/pkg/config"
+	"github.com/edwinzhancn/lumen-sdk/pkg/discovery"
+	"go.uber.org/zap"
+)
+
+// options are the knobs of a soak run, read from LUMEN_SOAK_* variables.
+type options struct {
+	duration       time.Duration // LUMEN_SOAK_DURATION
+	qps            int           // LUMEN_SOAK_QPS
+	workers        int           // LUMEN_SOAK_WORKERS
+	nodes          int           // LUMEN_SOAK_NODES
+	payloadBytes   int           // LUMEN_SOAK_PAYLOAD_BYTES
+	seed           int64         // LUMEN_SOAK_SEED
+	maxErrorRate   float64       // LUMEN_SOAK_MAX_ERROR_RATE
+	goroutineSlack int           // LUMEN_SOAK_GOROUTINE_SLACK
+	grace          time.Duration // LUMEN_SOAK_REMOVAL_GRACE
+	dumpDir        string        // LUMEN_SOAK_DUMP_DIR
+}
+
+func loadOptions(t *testing.T) options {
+	t.Helper()
+	o := options{
+		duration:       30 * time.Second,
+		qps:            50,
+		workers:        32,
+		nodes:          6,
+		payloadBytes:   1 << 20,
+		seed:           time.Now().UnixNano(),
+		maxErrorRate:   0.05,
+		goroutineSlack: 300,
+		grace:          2 * time.Second,
+		dumpDir:        os.Getenv("LUMEN_SOAK_DUMP_DIR"),
+	}
+	for name, dst := range map[string]any{
+		"LUMEN_SOAK_DURATION":        &o.duration,
+		"LUMEN_SOAK_QPS":             &o.qps,
+		"LUMEN_SOAK_WORKERS":         &o.workers,
+		"LUMEN_SOAK_NODES":           &o.nodes,
+		"LUMEN_SOAK_PAYLOAD_BYTES":   &o.payloadBytes,
+		"LUMEN_SOAK_SEED":            &o.seed,
+		"LUMEN_SOAK_MAX_ERROR_RATE":  &o.maxErrorRate,
+		"LUMEN_SOAK_GOROUTINE_SLACK": &o.goroutineSlack,
+		"LUMEN_SOAK_REMOVAL_GRACE":   &o.grace,
+	} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		var err error
+		switch dst := dst.(type) {
+		case *time.Duration:
+			*dst, err = time.ParseDuration(v)
+		case *int:
+			*dst, err = strconv.Atoi(v)
+		case *int64:
+			*dst, err = strconv.ParseInt(v, 10, 64)
+		case *float64:
+			*dst, err = strconv.ParseFloat(v, 64)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if o.nodes < 2 || o.qps <= 0 || o.workers <= 0 || o.duration <= 0 {
+		t.Fatalf("LUMEN_SOAK_NODES must be at least 2 and QPS, WORKERS and DURATION positive: %+v", o)
+	}
+	return o
+}
+
+// history is a bounded log of what happened during a run, dumped when it
+// fails.
+type history struct {
+	start time.Time
+
+	mu      sync.Mutex
+	entries []string
+	dropped int
+}
+
+const historyLimit = 20000
+
+func (h *history) add(format string, args ...any) {
+	entry := fmt.Sprintf("%9s  ", time.Since(h.start).Round(time.Millisecond)) + fmt.Sprintf(format, args...)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.entries) == historyLimit {
+		h.entries = h.entries[1:]
+		h.dropped++
+	}
+	h.entries = append(h.entries, entry)
+}
+
+// violations returns the entries recording a broken invariant.
+func (h *history) violations() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []string
+	for _, e := range h.entries {
+		if strings.Contains(e, "VIOLATION") {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (h *history) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var b strings.Builder
+	if h.dropped > 0 {
+		fmt.Fprintf(&b, "(%d earlier entries dropped)\n", h.dropped)
+	}
+	for _, e := range h.entries {
+		b.WriteString(e)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// soakConfig is the client configuration of a run: push discovery only,
+// fast expiry and health checks, and a chunk threshold below the upload
+// payload.
+func soakConfig(o options) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Discovery.MDNSEnabled = false
+	cfg.Discovery.Push.Enabled = true
+	cfg.Discovery.Push.HeartbeatTimeout = 2 * time.Second
+	cfg.Discovery.NotifyWindow = 50 * time.Millisecond
+	cfg.Pool.HealthInterval = time.Second
+	cfg.Chunk.Threshold = o.payloadBytes / 4
+	cfg.Chunk.MaxChunkBytes = 64 * 1024
+	return cfg
+}
+
+func TestSoak(t *testing.T) {
+	o := loadOptions(t)
+	t.Logf("soak: %v at %d qps over %d nodes, seed %d", o.duration, o.qps, o.nodes, o.seed)
+	rng := rand.New(rand.NewSource(o.seed))
+	hist := &history{start: time.Now()}
+	initialGoroutines := runtime.NumGoroutine()
+	defer func() {
+		if t.Failed() {
+			dump(t, o, hist)
+		}
+	}()
+
+	cfg := soakConfig(o)
+	c, err := client.NewLumenClient(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewLumenClient: %v", err)
+	}
+	expiry := cfg.Discovery.Push.HeartbeatTimeout
+
+	nodes := make([]*fakeNode, o.nodes)
+	anchors := max(1, o.nodes/3)
+	for i := range nodes {
+		nodes[i] = &fakeNode{
+			id:        fmt.Sprintf("soak-%d", i),
+			registry:  c.PushRegistry(),
+			history:   hist,
+			heartbeat: expiry / 4,
+			linger:    o.grace + time.Second,
+			grace:     o.grace,
+		}
+		if err := nodes[i].apply(actJoin, rng); err != nil {
+			t.Fatalf("node %d join: %v", i, err)
+		}
+	}
+
+	var selectionViolations sync.Map // node key -> struct{}, reported once per key
+	c.WatchSelections(func(d discovery.SelectionDecision) {
+		for _, n := range nodes {
+			if down, bad := n.removedFor(d.NodeID, d.At, expiry); bad {
+				if _, seen := selectionViolations.LoadOrStore(d.NodeID, struct{}{}); !seen {
+					hist.add("VIOLATION: %s selected for %s %v after going down", d.NodeID, d.Task, down.Round(time.Millisecond))
+				}
+			}
+		}
+	})
+	c.WatchNodes(func(list []*discovery.NodeInfo) {
+		var ids []string
+		for _, n := range list {
+			ids = append(ids, n.ID+"="+string(n.Status))
+		}
+		hist.add("nodes: %s", strings.Join(ids, " "))
+	})
+
+	startCtx, cancelStart := context.WithTimeout(context.Background(), 15*time.Second)
+	err = c.Start(startCtx)
+	cancelStart()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			_ = c.Close()
+		}
+	}()
+	waitFor(t, 15*time.Second, "every node to be healthy", func() bool {
+		return c.PoolStats().HealthyConnections >= o.nodes
+	})
+
+	scripts := make([][]step, len(nodes))
+	for i := anchors; i < len(nodes); i++ {
+		scripts[i] = script(rng, o.duration)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.duration)
+	defer cancel()
+	var bg sync.WaitGroup
+
+	// Lifecycles.
+	scriptSeed := rng.Int63()
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		runScripts(ctx, nodes, scripts, scriptSeed, hist)
+	}()
+
+	// Configuration reloads: filters that admit every node, swapped in and
+	// out.
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		t := time.NewTicker(3 * time.Second)
+		defer t.Stop()
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			d := cfg.Discovery
+			if i%2 == 0 {
+				d.DenyNodes = []string{"never-*"}
+				d.Push.AllowNodes = []string{"soak-*"}
+			}
+			if err := c.SetNodeFilters(d); err != nil {
+				hist.add("VIOLATION: filter reload failed: %v", err)
+				continue
+			}
+			hist.add("filters reloaded (%d)", i)
+		}
+	}()
+
+	drv := &driver{
+		client:  c,
+		payload: bytes.Repeat([]byte("lumen-soak"), o.payloadBytes/10+1)[:o.payloadBytes],
+		timeout: 15 * time.Second,
+		covered: func() bool {
+			for _, n := range nodes {
+				if n.up() {
+					return true
+				}
+			}
+			return false
+		},
+		history: hist,
+	}
+
+	// Invariant monitor. The goroutine baseline is taken once the driver's
+	// workers are running.
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		time.Sleep(time.Second)
+		limit := runtime.NumGoroutine() + o.goroutineSlack
+		hist.add("goroutine limit %d", limit)
+		var prev *client.ClientMetrics
+		t := time.NewTicker(500 * time.Millisecond)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			if n := runtime.NumGoroutine(); n > limit {
+				hist.add("VIOLATION: %d goroutines, limit %d", n, limit)
+			}
+			m := c.GetMetrics()
+			checkMetrics(hist, prev, m, false)
+			prev = m
+		}
+	}()
+
+	drv.run(ctx, o.qps, o.workers, rng)
+	bg.Wait()
+
+	// Final checks, with the traffic drained.
+	checkMetrics(hist, nil, c.GetMetrics(), true)
+	sum := drv.summary()
+	var served, late int64
+	for _, n := range nodes {
+		served += n.served.Load()
+		late += n.late.Load()
+	}
+	t.Logf("ops %v, failed %v, dropped ticks %d, served %d, error rate %.4f",
+		sum.ops, sum.failed, drv.dropped.Load(), served, sum.errorRate())
+	if sum.covered == 0 {
+		t.Error("no traffic was sent")
+	}
+	if rate := sum.errorRate(); rate > o.maxErrorRate {
+		t.Errorf("error rate %.4f with a node up, want at most %.4f; first errors:\n  %s",
+			rate, o.maxErrorRate, strings.Join(sum.firstErrors, "\n  "))
+	}
+	if p := drv.panics.Load(); p > 0 {
+		t.Errorf("%d operations panicked", p)
+	}
+	if late > 0 {
+		t.Errorf("%d requests reached nodes more than %v after they left", late, o.grace)
+	}
+	for _, v := range hist.violations() {
+		t.Error(v)
+	}
+
+	// Teardown must not leak.
+	_ = c.Close()
+	closed = true
+	for _, n := range nodes {
+		n.shutdown()
+	}
+	waitFor(t, 10*time.Second, "goroutines to return to the initial count", func() bool {
+		return runtime.NumGoroutine() <= initialGoroutines+5
+	})
+}
+
+// runScripts plays each node's script from the start of ctx until it ends.
+func runScripts(ctx context.Context, nodes []*fakeNode, scripts [][]step, seed int64, hist *history) {
+	type due struct {
+		node *fakeNode
+		step
+	}
+	var all []due
+	for i, s := range scripts {
+		for _, st := range s {
+			all = append(all, due{nodes[i], st})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].at < all[j].at })
+
+	rng := rand.New(rand.NewSource(seed))
+	start := time.Now()
+	for _, d := range all {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(start.Add(d.at))):
+		}
+		if err := d.node.apply(d.act, rng); err != nil {
+			hist.add("%s %s failed: %v", d.node.id, d.act, err)
+		}
+	}
+}
+
+// checkMetrics records a violation when m's outcomes exceed its total, or,
+// when drained, do not add up to it, or when a counter went backwards
+// since prev.
+func checkMetrics(hist *history, prev, m *client.ClientMetrics, drained bool) {
+	outcomes := m.SuccessRequests + m.FailedRequests + m.CancelledRequests
+	switch {
+	case outcomes > m.TotalRequests:
+		hist.add("VIOLATION: metrics count %d outcomes for %d requests", outcomes, m.TotalRequests)
+	case drained && outcomes != m.TotalRequests:
+		hist.add("VIOLATION: drained metrics count %d outcomes for %d requests", outcomes, m.TotalRequests)
+	}
+	if prev == nil {
+		return
+	}
+	for _, c := range []struct {
+		name       string
+		prev, curr int64
+	}{
+		{"total", prev.TotalRequests, m.TotalRequests},
+		{"success", prev.SuccessRequests, m.SuccessRequests},
+		{"failed", prev.FailedRequests, m.FailedRequests},
+		{"cancelled", prev.CancelledRequests, m.CancelledRequests},
+	} {
+		if c.curr < c.prev {
+			hist.add("VIOLATION: %s requests went from %d to %d", c.name, c.prev, c.curr)
+		}
+	}
+}
+
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// dump logs the goroutine stacks and the history, and writes them to
+// o.dumpDir when set.
+func dump(t *testing.T, o options, hist *history) {
+	var stacks bytes.Buffer
+	_ = pprof.Lookup("goroutine").WriteTo(&stacks, 2)
+	t.Logf("seed %d; event history:\n%s", o.seed, hist)
+	t.Logf("goroutines:\n%s", stacks.String())
+	if o.dumpDir == "" {
+		return
+	}
+	if err := os.MkdirAll(o.dumpDir, 0o755); err != nil {
+		t.Logf("dump: %v", err)
+		return
+	}
+	for name, data := range map[string][]byte{
+		"history.txt":    []byte(fmt.Sprintf("seed %d\n%s", o.seed, hist)),
+		"goroutines.txt": stacks.Bytes(),
+	} {
+		if err := os.WriteFile(filepath.Join(o.dumpDir, name), data, 0o644); err != nil {
+			t.Logf("dump %s: %v", name, err)
+		}
+	}
+	t.Logf("dump written to %s", o.dumpDir)
+}
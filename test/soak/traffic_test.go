@@ -0,0 +1,249 @@
+//go:build soak
+
+package soak
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/client"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+)
+
+// opKind is a kind of traffic the driver sends.
+type opKind string
+
+const (
+	opEmbed  opKind = "embed"  // a small Infer
+	opUpload opKind = "upload" // an Infer with a payload above the chunk threshold
+	opStream opKind = "stream" // an InferStream, collected
+	opBatch  opKind = "batch"  // an InferBatch of batchSize small requests
+)
+
+const batchSize = 8
+
+// mix is the share of each kind in the traffic, cumulatively.
+var mix = []struct {
+	upTo float64
+	kind opKind
+}{
+	{0.5, opEmbed},
+	{0.65, opUpload},
+	{0.8, opStream},
+	{1, opBatch},
+}
+
+// outcome is how one operation went.
+type outcome struct {
+	kind opKind
+	err  error
+	// covered is whether at least one node was joined and settled when the
+	// operation started; only covered operations count towards the error
+	// rate.
+	covered bool
+}
+
+// driver sends mixed traffic at a steady rate through a fixed set of
+// workers, so the goroutines it needs do not grow with latency.
+type driver struct {
+	client  *client.LumenClient
+	payload []byte
+	timeout time.Duration
+	covered func() bool
+	history *history
+
+	seq     atomic.Int64
+	dropped atomic.Int64 // ticks skipped because every worker was busy
+	panics  atomic.Int64
+
+	mu       sync.Mutex
+	outcomes []outcome
+}
+
+// run sends qps operations a second from workers goroutines until ctx ends,
+// then waits for the operations in flight.
+func (d *driver) run(ctx context.Context, qps, workers int, rng *rand.Rand) {
+	ops := make(chan opKind)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for kind := range ops {
+				d.do(kind)
+			}
+		}()
+	}
+
+	t := time.NewTicker(time.Second / time.Duration(qps))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			close(ops)
+			wg.Wait()
+			return
+		case <-t.C:
+		}
+		r := rng.Float64()
+		kind := mix[len(mix)-1].kind
+		for _, m := range mix {
+			if r < m.upTo {
+				kind = m.kind
+				break
+			}
+		}
+		select {
+		case ops <- kind:
+		default:
+			d.dropped.Add(1)
+		}
+	}
+}
+
+// do runs one operation, recording its outcome and any panic.
+func (d *driver) do(kind opKind) {
+	covered := d.covered()
+	defer func() {
+		if r := recover(); r != nil {
+			d.panics.Add(1)
+			d.history.add("VIOLATION: panic in %s: %v\n%s", kind, r, debug.Stack())
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	var err error
+	switch kind {
+	case opEmbed:
+		err = d.embed(ctx)
+	case opUpload:
+		err = d.upload(ctx)
+	case opStream:
+		err = d.stream(ctx)
+	case opBatch:
+		err = d.batch(ctx)
+	}
+	d.mu.Lock()
+	d.outcomes = append(d.outcomes, outcome{kind: kind, err: err, covered: covered})
+	d.mu.Unlock()
+}
+
+func (d *driver) request(task, mime string, payload []byte) *pb.InferRequest {
+	return &pb.InferRequest{
+		CorrelationId: "soak-" + strconv.FormatInt(d.seq.Add(1), 10),
+		Task:          task,
+		Payload:       payload,
+		PayloadMime:   mime,
+	}
+}
+
+func (d *driver) embed(ctx context.Context) error {
+	req := d.request(taskEmbed, "text/plain", []byte("hello soak"))
+	resp, err := d.client.Infer(ctx, req)
+	if err != nil {
+		return err
+	}
+	return checkEcho(req, resp)
+}
+
+func (d *driver) upload(ctx context.Context) error {
+	req := d.request(taskUpload, "application/octet-stream", d.payload)
+	resp, err := d.client.Infer(ctx, req)
+	if err != nil {
+		return err
+	}
+	if want := `{"bytes":` + strconv.Itoa(len(d.payload)) + `}`; string(resp.Result) != want {
+		return fmt.Errorf("upload %s: node saw %s, want %s", req.CorrelationId, resp.Result, want)
+	}
+	return nil
+}
+
+func (d *driver) stream(ctx context.Context) error {
+	req := d.request(taskStream, "text/plain", []byte("stream soak"))
+	frames, err := d.client.InferStream(ctx, req)
+	if err != nil {
+		return err
+	}
+	resp, err := client.CollectStream(ctx, frames)
+	if err != nil {
+		return err
+	}
+	return checkEcho(req, resp)
+}
+
+func (d *driver) batch(ctx context.Context) error {
+	reqs := make([]*pb.InferRequest, batchSize)
+	for i := range reqs {
+		reqs[i] = d.request(taskEmbed, "text/plain", []byte("batch item "+strconv.Itoa(i)))
+	}
+	items, err := d.client.InferBatch(ctx, reqs, client.BatchOptions{Concurrency: 4})
+	if err != nil {
+		return err
+	}
+	if len(items) != len(reqs) {
+		return fmt.Errorf("batch returned %d items for %d requests", len(items), len(reqs))
+	}
+	for i, item := range items {
+		if item.Err != nil {
+			return item.Err
+		}
+		if err := checkEcho(reqs[i], item.Response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkEcho verifies resp answers req, catching responses crossed between
+// requests.
+func checkEcho(req *pb.InferRequest, resp *pb.InferResponse) error {
+	if resp.CorrelationId != req.CorrelationId || !bytes.Equal(resp.Result, req.Payload) {
+		return fmt.Errorf("request %s got response %s with %q", req.CorrelationId, resp.CorrelationId, resp.Result)
+	}
+	return nil
+}
+
+// summary counts outcomes per kind.
+type summary struct {
+	ops, failed            map[opKind]int
+	covered, coveredFailed int
+	firstErrors            []string
+}
+
+func (d *driver) summary() summary {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := summary{ops: map[opKind]int{}, failed: map[opKind]int{}}
+	for _, o := range d.outcomes {
+		s.ops[o.kind]++
+		if o.covered {
+			s.covered++
+		}
+		if o.err == nil {
+			continue
+		}
+		s.failed[o.kind]++
+		if o.covered {
+			s.coveredFailed++
+		}
+		if len(s.firstErrors) < 10 {
+			s.firstErrors = append(s.firstErrors, fmt.Sprintf("%s: %v", o.kind, o.err))
+		}
+	}
+	return s
+}
+
+func (s summary) errorRate() float64 {
+	if s.covered == 0 {
+		return 0
+	}
+	return float64(s.coveredFailed) / float64(s.covered)
+}
@@ -0,0 +1,145 @@
+// Command fixturecapture refreshes the node response corpus under
+// test/fixtures/responses from a live node. It sends each request listed in
+// the capture manifest to the node and stores the response it gets back as
+// a fixture, so the typed parsers are tested against what nodes really
+// emit.
+//
+// It is run by go generate in test/fixtures:
+//
+//	LUMEN_FIXTURE_NODE=127.0.0.1:50051 LUMEN_FIXTURE_INPUTS=~/lumen-inputs go generate ./test/fixtures
+//
+// Without a node it captures nothing and exits successfully, so a plain
+// go generate ./... does not need one. Image inputs are not part of the
+// repository: payload_file entries are read from the inputs directory, and
+// entries whose input is missing are skipped. Use -only to capture some
+// entries, e.g. face responses from a node serving a detection-only model.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/edwinzhancn/lumen-sdk/pkg/lumen"
+	pb "github.com/edwinzhancn/lumen-sdk/proto"
+	"github.com/edwinzhancn/lumen-sdk/test/fixtures"
+)
+
+// entry is one request of the capture manifest.
+type entry struct {
+	Name    string `json:"name"`
+	Task    string `json:"task"`
+	Service string `json:"service,omitempty"`
+	// Text is sent as a text/plain payload; PayloadFile, relative to the
+	// inputs directory, is sent with PayloadMime.
+	Text        string            `json:"text,omitempty"`
+	PayloadFile string            `json:"payload_file,omitempty"`
+	PayloadMime string            `json:"payload_mime,omitempty"`
+	Meta        map[string]string `json:"meta,omitempty"`
+}
+
+func main() {
+	node := flag.String("node", os.Getenv("LUMEN_FIXTURE_NODE"), "node gRPC address (host:port); defaults to $LUMEN_FIXTURE_NODE")
+	inputs := flag.String("inputs", os.Getenv("LUMEN_FIXTURE_INPUTS"), "directory of payload files; defaults to $LUMEN_FIXTURE_INPUTS")
+	manifest := flag.String("manifest", "capture.json", "capture manifest")
+	out := flag.String("out", "responses", "directory the fixtures are written to")
+	only := flag.String("only", "", "comma-separated fixture names to capture; all when empty")
+	timeout := flag.Duration("timeout", 2*time.Minute, "timeout for each request")
+	flag.Parse()
+
+	if *node == "" {
+		fmt.Println("fixturecapture: no node given (-node or LUMEN_FIXTURE_NODE); corpus left as is")
+		return
+	}
+	if err := run(*node, *inputs, *manifest, *out, *only, *timeout); err != nil {
+		fmt.Fprintf(os.Stderr, "fixturecapture: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(node, inputs, manifest, out, only string, timeout time.Duration) error {
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		return err
+	}
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%s: %w", manifest, err)
+	}
+	wanted := map[string]bool{}
+	for _, name := range strings.Split(only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			wanted[name] = true
+		}
+	}
+
+	ctx := context.Background()
+	c, err := lumen.Connect(ctx, lumen.WithStaticNodes(node), lumen.WithLogLevel("warn"))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var captured, failed int
+	for _, e := range entries {
+		if len(wanted) > 0 && !wanted[e.Name] {
+			continue
+		}
+		req, err := e.request(inputs)
+		if err != nil {
+			fmt.Printf("skip %s: %v\n", e.Name, err)
+			continue
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, timeout)
+		resp, err := c.Infer(reqCtx, req)
+		cancel()
+		if err != nil {
+			fmt.Printf("fail %s: %v\n", e.Name, err)
+			failed++
+			continue
+		}
+		f := fixtures.FromResponse(e.Name, e.Task, resp)
+		f.Service = e.Service
+		data, err := f.Marshal()
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(out, e.Name+".json"), data, 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("captured %s\n", e.Name)
+		captured++
+	}
+	fmt.Printf("%d captured, %d failed\n", captured, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d requests failed", failed)
+	}
+	return nil
+}
+
+func (e entry) request(inputs string) (*pb.InferRequest, error) {
+	req := &pb.InferRequest{
+		CorrelationId: "fixture-" + e.Name,
+		Task:          e.Task,
+		Meta:          e.Meta,
+	}
+	switch {
+	case e.PayloadFile != "":
+		if inputs == "" {
+			return nil, fmt.Errorf("needs %s but no inputs directory is set", e.PayloadFile)
+		}
+		payload, err := os.ReadFile(filepath.Join(inputs, e.PayloadFile))
+		if err != nil {
+			return nil, err
+		}
+		req.Payload, req.PayloadMime = payload, e.PayloadMime
+	default:
+		req.Payload, req.PayloadMime = []byte(e.Text), "text/plain"
+	}
+	return req, nil
+}